@@ -2,29 +2,72 @@ package main
 
 import (
 	"avif2png/internal/cli"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
+// exitInterrupted is the conventional exit code for a process stopped by SIGINT
+const exitInterrupted = 130
+
 func main() {
+	os.Exit(run())
+}
+
+// run runs the converter and returns the exit code, so deferred cleanup
+// such as closing the --log-file happens before exiting
+func run() int {
 	config, err := cli.ParseFlags(os.Args[1:])
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "❌ Error: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 
-	if config.Verbose {
-		fmt.Println("🚀 Starting AVIF to PNG conversion...")
+	// With --log-file, the messages below are logged like those of the run
+	stdout, stderr := io.Writer(os.Stdout), io.Writer(os.Stderr)
+	if config.LogFile != "" {
+		logFile, err := cli.OpenLogFile(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error: failed to open log file: %v\n", err)
+			return 1
+		}
+		defer logFile.Close()
+		stdout, stderr = config.Stdout, config.Stderr
 	}
 
-	if err := cli.Run(config); err != nil {
-		fmt.Fprintf(os.Stderr, "❌ Error: %v\n", err)
-		os.Exit(1)
+	// The first Ctrl-C lets the current file finish and prints a partial summary
+	// Restoring the default handler afterwards makes a second Ctrl-C exit at once
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if config.Verbose && !config.ShowVersion {
+		fmt.Fprintln(stdout, "🚀 Starting AVIF to PNG conversion...")
+	}
+
+	if err := cli.RunContext(ctx, config); err != nil {
+		if errors.Is(err, cli.ErrInterrupted) {
+			return exitInterrupted
+		}
+		fmt.Fprintf(stderr, "❌ Error: %v\n", err)
+		return 1
+	}
+
+	// Keep stdout machine-readable in JSON mode
+	if config.JSON || config.ShowVersion {
+		return 0
 	}
 
 	if config.Verbose {
-		fmt.Println("🎉 Conversion completed successfully!")
+		fmt.Fprintln(stdout, "🎉 Conversion completed successfully!")
 	} else {
-		fmt.Println("✅ Done")
+		fmt.Fprintln(stdout, "✅ Done")
 	}
+	return 0
 }
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"avif2png/internal/converter"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// checksumManifestPath returns the path of the --checksums-manifest file of
+// a conversion, e.g. "output/SHA256SUMS"
+func checksumManifestPath(config *Config) string {
+	return filepath.Join(config.OutputDir, strings.ToUpper(config.Checksums)+"SUMS")
+}
+
+// writeChecksumManifest lists the checksums of the outputs converted into
+// config.OutputDir in its checksum manifest, in the format of sha256sum,
+// with paths relative to the output directory
+// The lines of an existing manifest are kept for outputs not converted this
+// time, such as those skipped as already existing, so a tree converted over
+// several runs keeps a complete manifest
+func writeChecksumManifest(config *Config, result *converter.ConversionResult) (string, error) {
+	path := checksumManifestPath(config)
+	lines := map[string]string{}
+	if data, err := os.ReadFile(path); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if _, name, ok := strings.Cut(line, "  "); ok {
+				lines[name] = line + "\n"
+			}
+		}
+	}
+
+	written := 0
+	for _, file := range result.Files {
+		if file.Checksum == "" {
+			continue
+		}
+		name, err := filepath.Rel(config.OutputDir, file.OutputPath)
+		if err != nil {
+			name = file.OutputPath
+		}
+		lines[filepath.ToSlash(name)] = converter.ChecksumLine(file.Checksum, name)
+		written++
+	}
+	if written == 0 {
+		return "", nil
+	}
+
+	names := make([]string, 0, len(lines))
+	for name := range lines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var manifest strings.Builder
+	for _, name := range names {
+		manifest.WriteString(lines[name])
+	}
+	if err := os.WriteFile(path, []byte(manifest.String()), 0644); err != nil {
+		return "", fmt.Errorf("failed to write checksum manifest: %w", err)
+	}
+	return path, nil
+}
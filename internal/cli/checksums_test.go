@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ==================== Checksum Manifest Tests ====================
+
+func TestRun_ChecksumManifest(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+
+	// Lines of other outputs survive, and stale lines are replaced
+	os.MkdirAll(outputDir, 0755)
+	os.WriteFile(filepath.Join(outputDir, "SHA256SUMS"), []byte("1111  old.png\n2222  a.png\n"), 0644)
+
+	config := &Config{InputPath: inputDir, OutputDir: outputDir, Checksums: "sha256", ChecksumManifest: true}
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "SHA256SUMS"))
+	if err != nil {
+		t.Fatalf("expected a manifest, got: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "  a.png") || strings.HasPrefix(lines[0], "2222") || lines[1] != "1111  old.png" {
+		t.Errorf("expected a.png with its new checksum and old.png, got:\n%s", data)
+	}
+
+	config.InputPath = filepath.Join(inputDir, "a.avif")
+	if err := Run(config); err == nil {
+		t.Error("expected an error for a single file input")
+	}
+}
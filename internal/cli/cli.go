@@ -2,174 +2,2133 @@ package cli
 
 import (
 	"avif2png/internal/converter"
+	"avif2png/internal/report"
+	"context"
+	"encoding/hex"
 	"errors"
 	"flag"
 	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
 	DefaultOutputDir = "./output"
+	// CollisionSkip, CollisionWarn and CollisionError are the values of
+	// --on-collision
+	CollisionSkip  = "skip"
+	CollisionWarn  = "warn"
+	CollisionError = "error"
+	// InputModeAuto, InputModeFile and InputModeDir are the values of --mode
+	InputModeAuto = "auto"
+	InputModeFile = "file"
+	InputModeDir  = "dir"
+	// DefaultSharpenAmount is the strength of --sharpen
+	DefaultSharpenAmount = 0.5
 )
 
+// ErrInterrupted is returned when a directory conversion is cancelled before
+// all files were processed
+var ErrInterrupted = errors.New("interrupted")
+
 // Config holds the CLI configuration
 type Config struct {
-	InputPath string
-	OutputDir string
-	Recursive bool
-	Verbose   bool
+	InputPath         string
+	OutputDir         string
+	TeeDirs           []string
+	FileMode          os.FileMode
+	Format            string
+	Profile           string
+	Preset            string
+	Quality           int
+	Recursive         bool
+	InputMode         string
+	MaxDepth          int
+	ScanWorkers       int
+	Workers           int
+	Verbose           bool
+	Debug             bool
+	IncludeHidden     bool
+	AnyExt            bool
+	Extensions        []string
+	Since             time.Time
+	FlattenSeparator  string
+	SanitizeNames     bool
+	ReplaceSpaces     string
+	FlattenHash       bool
+	Shard             string
+	ShardWidth        int
+	ShardDepth        int
+	Number            bool
+	NumberPadding     int
+	Limit             int
+	SkipNonImages     bool
+	Passthrough       bool
+	Dedupe            bool
+	Checksums         string
+	ChecksumManifest  bool
+	OnError           string
+	QuarantineDir     string
+	ConflictReport    bool
+	DryRun            bool
+	OnCollision       string
+	OutputTemplate    string
+	OutputTemplateNow bool
+	Map               string
+	Spec              string
+	ExtractAux        bool
+	AllItems          bool
+	MetaOnly          bool
+	PrimaryOnly       bool
+	UseThumbnail      bool
+	ThumbnailRequired bool
+	Interactive       bool
+	PreserveMtime     bool
+	StripMetadata     bool
+	Verify            bool
+	VerifyExisting    bool
+	SkipIsError       bool
+	Compare           bool
+	Optimize          bool
+	MaxOutputSize     int64
+	SharpenAmount     float64
+	CropPct           converter.CropPercent
+	Canvas            image.Point
+	PadColor          color.NRGBA
+	Dimensions        converter.DimensionBounds
+	Rotate            int
+	Trim              bool
+	TrimTolerance     int
+	SharpenRadius     float64
+	Interlace         bool
+	NormalizeGamma    bool
+	FixAlpha          bool
+	Chroma            string
+	ToAVIF            bool
+	AVIFQuality       int
+	Dither            bool
+	AutoFormat        bool
+	Exec              []string
+	Tar               bool
+	StdinList         bool
+	Benchmark         time.Duration
+	BenchmarkWorkers  int
+	ThreadsReport     bool
+	ProgressAddr      string
+	Timeout           time.Duration
+	PDFPath           string
+	SpriteSheet       string
+	SpriteSheetWidth  int
+	ReportPath        string
+	ReportPreviews    bool
+	JSON              bool
+	JSONIndent        int
+	LogFile           string
+	LogAppend         bool
+	ShowVersion       bool
+	// Features are the long names of the flags of this build, which
+	// --version lists so scripts can check for a flag before using it
+	Features []string
+
+	// Stdout and Stderr receive the console output of a run, and are
+	// os.Stdout and os.Stderr when nil; see OpenLogFile
+	Stdout io.Writer
+	Stderr io.Writer
 }
 
+// EnvPrefix is the prefix of environment variables that set flag defaults,
+// e.g. AVIF2PNG_OUTPUT for --output
+const EnvPrefix = "AVIF2PNG_"
+
 // ParseFlags parses command line arguments and returns a Config
+// Flags that are not given fall back to their AVIF2PNG_* environment
+// variable, then to the built-in default
 func ParseFlags(args []string) (*Config, error) {
+	return parseFlags(args, os.LookupEnv)
+}
+
+// parseFlags is ParseFlags with a custom environment lookup
+func parseFlags(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
 	fs := flag.NewFlagSet("avif2png", flag.ContinueOnError)
 
-	outputDir := fs.String("output", DefaultOutputDir, "Output directory for converted PNG files")
-	fs.StringVar(outputDir, "o", DefaultOutputDir, "Output directory (shorthand)")
+	outputDirs := &outputDirsFlag{dirs: []string{DefaultOutputDir}, replace: true}
+	fs.Var(outputDirs, "output", "Output directory for converted files; repeat it to copy each output to more directories")
+	fs.Var(outputDirs, "o", "Output directory (shorthand)")
+	checksums := fs.String("checksums", "", "Write the checksum of each output next to it, e.g. image.png.sha256 for sha256 ("+strings.Join(converter.ChecksumAlgorithms(), ", ")+")")
+	checksumManifest := fs.Bool("checksums-manifest", false, "Collect the --checksums of a directory, glob or tar conversion in one file in the output directory, such as SHA256SUMS, instead of a file per output")
+	fileMode := fs.String("file-mode", "", "Octal permissions of output files regardless of the umask, e.g. 0600 (default 0666 minus the umask)")
+
+	formatHelp := fmt.Sprintf("Output format (%s)", strings.Join(converter.Formats(), ", "))
+	profile := fs.String("profile", "", "Preset of options for a use case, overridden by any flag given ("+strings.Join(ProfileNames(), ", ")+")")
+	noPreset := fs.Bool("no-preset", false, "Ignore the "+PresetFileName+" files of the input and its parent directories")
+	format := fs.String("format", converter.DefaultFormat, formatHelp)
+	fs.StringVar(format, "f", converter.DefaultFormat, formatHelp+" (shorthand)")
+
+	gifOutput := fs.Bool("gif", false, "Write GIFs, keeping every frame of animated AVIFs (same as -f gif)")
+	dither := fs.Bool("dither", false, "Dither GIF outputs, which are reduced to 256 colors, to avoid banding in gradients")
+
+	quality := fs.Int("quality", converter.DefaultQuality, "Quality for lossy output formats (1-100)")
+
+	toAVIF := fs.Bool("to-avif", false, "Convert PNG and JPEG inputs to AVIF instead, at --avif-quality")
+	avifQuality := fs.Int("avif-quality", converter.DefaultAVIFQuality, "Quality of --to-avif outputs (1-100, 100 for lossless)")
 
 	recursive := fs.Bool("recursive", false, "Recursively process subdirectories")
+	inputMode := fs.String("mode", InputModeAuto, "How the input path is read: file (a single file), dir (a directory) or auto (a directory, a glob pattern or a file, whichever it is)")
 	fs.BoolVar(recursive, "r", false, "Recursively process subdirectories (shorthand)")
 
+	extensionList := fs.String("extensions", "", "Comma-separated extensions of the input files picked up in directories, globs and archives and accepted as a single input, e.g. .avif,.avifs (default .avif, or .png,.jpg,.jpeg with --to-avif)")
+	anyExt := fs.Bool("any-ext", false, "Accept a single input file with any extension, relying on decoding to check it is AVIF")
+
+	maxDepth := fs.Int("max-depth", 0, "Scan at most this many directory levels, counting the input directory as 1 (implies --recursive; 0 for unlimited)")
+	scanWorkers := fs.Int("scan-workers", 1, "Number of directories a recursive scan reads at the same time; higher values speed up huge trees on network storage, but files are found in no particular order")
+	jobs := fs.String("jobs", "1", "Number of files a directory, glob, --stdin-list or --map conversion converts at the same time, or auto to pick it from the CPU count and the size of each file")
+
+	includeHidden := fs.Bool("include-hidden", false, "Include hidden files (starting with '.') in directory scans")
+
+	since := fs.String("since", "", "Only convert files modified within a duration (e.g. 24h) or since a date (e.g. 2024-01-01)")
+
+	flattenSep := fs.String("flatten-separator", "", "Encode subdirectories into flat output names using this separator")
+	shard := fs.String("shard", "", "Spread outputs over subdirectories named after the first characters of their name or of its hash, e.g. ab/abcdef.png ("+converter.ShardName+" or "+converter.ShardHash+")")
+	shardWidth := fs.Int("shard-width", converter.DefaultShardWidth, "Number of characters of each --shard directory")
+	shardDepth := fs.Int("shard-depth", 1, "Number of nested --shard directories, e.g. 2 for ab/cd/abcdef.png")
+	flattenHash := fs.Bool("flatten-hash", false, "Give outputs whose name an earlier input of the run already took a short hash of their image instead, e.g. img_a1b2c3.png, so files of the same name in different subdirectories are all converted")
+	sanitizeNames := fs.Bool("sanitize-names", false, "Make output names valid on every OS: replace invalid and control characters, rename reserved names such as CON")
+	replaceSpaces := fs.String("replace-spaces", "", "Replace each run of spaces in output names with this string, e.g. - or _")
+	limit := fs.Int("limit", 0, "Convert only the first N files of a directory, glob or --map, in sorted path order, e.g. to try settings on a sample (0 converts all)")
+	number := fs.Bool("number", false, "Name the outputs of a directory conversion 0001.png, 0002.png, ... in the sorted order of their input paths")
+	numberPadding := fs.Int("number-padding", converter.DefaultNumberPadding, "Number of digits of --number outputs, padded with zeros (0 for none)")
+	onError := fs.String("on-error", converter.OnErrorSkip, "What to do when a file fails in a directory, glob or tar conversion: skip (go on), stop (convert nothing more) or quarantine (move inputs that fail to decode to --quarantine-dir, then go on)")
+	quarantineDir := fs.String("quarantine-dir", "", "Directory that --on-error quarantine moves undecodable inputs to")
+	passthrough := fs.Bool("passthrough-non-avif", false, "Detect PNG, JPEG and GIF files misnamed as AVIF, and copy those already in the output format as they are instead of re-encoding them")
+	skipNonImages := fs.Bool("skip-non-images", false, "Skip image sequences and files without a primary image that fail to decode, instead of failing them")
+	dedupe := fs.Bool("dedupe", false, "Skip files whose decoded image matches one already converted in this run, pointing them to the first output")
+	conflictReport := fs.Bool("flatten-conflict-report", false, "List output names claimed by more than one input, without converting")
+	dryRun := fs.Bool("dry-run", false, "List the output path of each input and whether it would be converted or skipped, without reading inputs or writing anything (as a JSON array with --json)")
+	onCollision := fs.String("on-collision", CollisionSkip, "What to do when several inputs map to the same output path: skip (convert the first, without checking first), warn (list them, then convert) or error (convert nothing)")
+
+	outputTemplate := fs.String("output-template", "", "Subdirectory template under the output directory, using {yyyy}, {mm} and {dd}")
+	templateTime := fs.String("template-time", "mtime", "Date used by --output-template: mtime (of the input) or now")
+	mapSpec := fs.String("map", "", "Convert the files matching an input glob to paths from an output template, e.g. 'in/**/*.avif=out/{1}/{2}.png', where {N} is what the N-th wildcard matched (replaces the input argument and --output)")
+	spec := fs.String("spec", "", "Run the jobs of a JSON batch spec read from this file, or from stdin for -, each an input, an output and options that override the flags (replaces the input argument and --output)")
+
+	extractAux := fs.Bool("extract-aux", false, "Also write auxiliary images (alpha masks, depth maps) as name_alpha/name_depth files")
+	allItems := fs.Bool("all-items", false, "Also write every top-level image of multi-image files as name_item0, name_item1, ... files")
+	metaOnly := fs.Bool("meta-only", false, "Write the metadata of each image (size, bit depth, alpha, ICC profile, Exif fields) as name.json instead of converting it")
+	primaryOnly := fs.Bool("primary-only", false, "Convert only the primary image, as quickly as possible: no auxiliary images, items or thumbnails, and only the first frame of animations")
+
+	useThumbnail := fs.Bool("use-thumbnail", false, "Convert the embedded thumbnail instead of the full-resolution image")
+	thumbnailFallback := fs.String("thumbnail-fallback", "full", "What --use-thumbnail does for files without a thumbnail: full (convert the full image) or error")
+
+	tarInput := fs.Bool("tar", false, "Read the input as a tar archive (optionally gzipped, - for stdin) and convert its AVIF entries")
+	stdinList := fs.Bool("stdin-list", false, "Convert the files listed on stdin, one path per line, as the lines arrive (replaces the input argument)")
+
+	timeout := fs.Duration("timeout", DefaultTimeout, "Time limit for downloading an http(s) input")
+
+	execCommand := fs.String("exec", "", "Run a command after each successful conversion; {input} and {output} are replaced with the file paths")
+
+	autoFormat := fs.Bool("auto-format", false, "Write each image as PNG or JPEG, whichever is smaller (JPEG only for opaque images, at --quality)")
+
+	optimize := fs.Bool("optimize", false, "Try several PNG compression levels and keep the smallest output (slower)")
+
+	maxOutputSize := fs.String("max-output-size", "", "Largest output file size, e.g. 500KB: JPEG quality is lowered and PNGs are downscaled to fit")
+
+	sharpenImages := fs.Bool("sharpen", false, "Apply an unsharp mask to images downscaled by --max-output-size or --canvas")
+	sharpenAmount := fs.Float64("sharpen-amount", DefaultSharpenAmount, "Strength of --sharpen, e.g. 0.5 (subtle) to 1.5 (strong)")
+	sharpenRadius := fs.Float64("sharpen-radius", converter.DefaultSharpenRadius, "Blur radius of --sharpen in pixels")
+
+	cropPct := fs.String("crop-pct", "", "Keep a region of each image given as left,top,right,bottom percentages of its size, e.g. 10,10,90,90")
+	rotateDegrees := fs.Int("rotate", 0, "Rotate images clockwise by 90, 180 or 270 degrees before encoding")
+	trimBorders := fs.Bool("trim", false, "Crop away uniform borders matching the color shared by the four corners")
+	canvas := fs.String("canvas", "", "Scale each image to fit a canvas of this size and center it there, padding the rest, e.g. 1920x1080")
+	padColor := fs.String("pad-color", "000000", "Color of the padding of --canvas, as RRGGBB or RRGGBBAA hex, e.g. ffffff or 00000000 (transparent)")
+	trimTolerance := fs.Int("trim-tolerance", converter.DefaultTrimTolerance, "Largest difference per 8-bit channel from the border color that --trim still crops (0-255)")
+	minWidth := fs.Int("min-width", 0, "Skip images narrower than this many pixels, checked after decoding (0 for no limit)")
+	minHeight := fs.Int("min-height", 0, "Skip images shorter than this many pixels, checked after decoding (0 for no limit)")
+	maxWidth := fs.Int("max-width", 0, "Skip images wider than this many pixels, checked after decoding (0 for no limit)")
+	maxHeight := fs.Int("max-height", 0, "Skip images taller than this many pixels, checked after decoding (0 for no limit)")
+	normalizeGamma := fs.Bool("normalize-gamma", false, "Convert images tagged with another transfer function than sRGB, such as BT.709, PQ or HLG, to sRGB gamma")
+	fixAlpha := fs.Bool("fix-alpha", false, "Un-premultiply the color of images with alpha that were premultiplied without their container saying so")
+
+	interlace := fs.Bool("interlace", false, "Write Adam7-interlaced PNGs that load progressively (usually larger)")
+
+	chroma := fs.String("chroma", converter.Chroma420, "Chroma subsampling of JPEG outputs: 420 (smaller) or 444 (sharper color edges)")
+
+	compare := fs.Bool("compare", false, "Compare existing outputs with a new conversion and list those that changed, without overwriting")
+
+	verify := fs.Bool("verify", false, "Re-decode each written output and check its dimensions")
+	verifyExisting := fs.Bool("verify-existing", false, "Check existing outputs before skipping them and convert again those that are not valid images of the expected size")
+	skipIsError := fs.Bool("skip-is-error", false, "Exit with an error when files are skipped because their output already exists")
+
+	preserveMtime := fs.Bool("preserve-mtime", false, "Give output files the modification time of their input")
+	stripMetadata := fs.Bool("strip-metadata", false, "Remove any metadata (EXIF, ICC profiles, text) from outputs before writing them")
+
+	interactive := fs.Bool("interactive", false, "Ask before overwriting each existing output file")
+	fs.BoolVar(interactive, "i", false, "Ask before overwriting each existing output file (shorthand)")
+
 	verbose := fs.Bool("verbose", false, "Enable verbose output")
 	fs.BoolVar(verbose, "v", false, "Enable verbose output (shorthand)")
+	debug := fs.Bool("vv", false, "Very verbose: also describe the container and codec of each input (implies --verbose)")
+
+	pdfPath := fs.String("pdf", "", "Combine a directory into a single PDF at this path, one image per page")
+	spriteSheet := fs.String("spritesheet", "", "Pack a directory into a single PNG sprite sheet at this path, with the position of each image in a .json file next to it")
+	spriteSheetWidth := fs.Int("spritesheet-width", converter.DefaultSpriteSheetWidth, "Width in pixels at which --spritesheet wraps into a new row")
+
+	reportPath := fs.String("report", "", "Write an HTML report of a directory conversion to this file")
+	reportPreviews := fs.Bool("report-previews", false, "Embed small previews of converted images in the HTML report")
+
+	benchmark := fs.Duration("benchmark", 0, "Convert the input (or a synthetic image if none is given) in memory repeatedly for this long and report throughput, latency and memory")
+	benchmarkWorkers := fs.Int("benchmark-workers", 1, "Number of conversions run at the same time by --benchmark")
+	threadsReport := fs.Bool("threads-report", false, "Report the parallelism achieved, peak memory and CPU time of a directory or archive conversion")
+	progressAddr := fs.String("progress-addr", "", "Serve the progress of a directory conversion as JSON at /status on this address (e.g. localhost:8080 or unix:/tmp/avif2png.sock) until it completes")
+
+	jsonOutput := fs.Bool("json", false, "Print the result of a directory conversion as JSON")
+	jsonIndent := fs.Int("json-indent", 0, "Pretty-print JSON output with this many spaces (0 for compact)")
+	logFile := fs.String("log-file", "", "Also write the console output to this file, with a timestamp on each line")
+	logAppend := fs.Bool("log-append", false, "Append to --log-file instead of truncating it")
+	showVersion := fs.Bool("version", false, "Print the version, AVIF decoder, output formats and features, then exit (as JSON with --json)")
 
 	fs.Usage = func() {
 		fmt.Fprintf(os.Stderr, "🖼️  AVIF to PNG Converter\n\n")
-		fmt.Fprintf(os.Stderr, "Usage: avif2png [options] <input.avif or directory>\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: avif2png [options] <input.avif, directory, glob pattern or URL>\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		fs.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nExamples:\n")
 		fmt.Fprintf(os.Stderr, "  # Convert single file\n")
 		fmt.Fprintf(os.Stderr, "  avif2png image.avif\n")
-		fmt.Fprintf(os.Stderr, "  avif2png -o ./converted image.avif\n\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -o ./converted image.avif\n")
+		fmt.Fprintf(os.Stderr, "  avif2png https://example.com/image.avif\n")
+		fmt.Fprintf(os.Stderr, "  avif2png 'photos/*.avif'\n\n")
 		fmt.Fprintf(os.Stderr, "  # Convert directory\n")
 		fmt.Fprintf(os.Stderr, "  avif2png my-images/\n")
 		fmt.Fprintf(os.Stderr, "  avif2png -r my-images/\n")
-		fmt.Fprintf(os.Stderr, "  avif2png -r -o ./converted my-images/\n\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r -o ./converted my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r -o ./web -o /mnt/backup/web my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --max-depth 2 my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r --scan-workers 16 /mnt/nfs/archive/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r --jobs auto my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r --flatten-separator _ my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r --flatten-conflict-report my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --number --number-padding 5 -o ./frames shots/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r --limit 20 --profile web -o ./sample huge-archive/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --sanitize-names -o /mnt/windows-share downloads/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --replace-spaces - -o ./public my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r --dedupe scraped/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r --min-width 1024 --min-height 768 photos/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r --extensions .avif,.avifs camera-roll/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r --skip-non-images downloads/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r --passthrough-non-avif scraped/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r --flatten-hash camera-roll/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r --shard hash --shard-depth 2 -o ./cdn huge-archive/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r --on-error quarantine --quarantine-dir ./bad uploads/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --file-mode 0600 -o ./private scans/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r --checksums sha256 --checksums-manifest -o ./cdn my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r --output-template {yyyy} --on-collision error my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --output-template {yyyy}/{mm} my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --map 'in/**/*.avif=out/{1}/{2}.png'\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --spec - < jobs.json\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --mode dir -o ./converted \"$INPUT\"\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --since 24h my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --report report.html --report-previews my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --json --json-indent 2 my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --dry-run --json --shard name -r my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --log-file convert.log --log-append my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -i -o ./converted my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --pdf album.pdf my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r --spritesheet assets/sprites.png --spritesheet-width 1024 icons/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --compare -o ./expected my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --verify-existing -o ./converted my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --skip-is-error -o ./clean-output my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --threads-report -r my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --progress-addr localhost:8080 -r my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --tar -o ./converted batch.tar.gz\n")
+		fmt.Fprintf(os.Stderr, "  find . -name '*.avif' | avif2png --stdin-list -o ./converted\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --exec 'pngquant --ext .png --force {output}' my-images/\n\n")
+		fmt.Fprintf(os.Stderr, "  # Convert to JPEG\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -f jpeg --quality 85 image.avif\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --auto-format --quality 80 my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -f jpeg --max-output-size 500KB my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --max-output-size 200KB --sharpen -o ./web my-images/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -f jpeg --chroma 444 screenshot.avif\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --trim --trim-tolerance 16 screenshots/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --crop-pct 0,0,50,100 spreads/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --canvas 1920x1080 --pad-color ffffff -o ./slides photos/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r --normalize-gamma -o ./uniform mixed-sources/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --fix-alpha dark-edged-logos/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --rotate 90 sideways-photos/\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -f jpeg --strip-metadata -o ./public my-images/\n\n")
+		fmt.Fprintf(os.Stderr, "  # Animated AVIF to a shareable animated GIF\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --gif --dither animation.avif\n\n")
+		fmt.Fprintf(os.Stderr, "  # The other way around: PNG and JPEG to AVIF\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --to-avif --avif-quality 70 -r photos/\n\n")
+		fmt.Fprintf(os.Stderr, "  # Progressive PNGs for the web\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --interlace -o ./web my-images/\n\n")
+		fmt.Fprintf(os.Stderr, "  # Quick gallery previews from embedded thumbnails\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --use-thumbnail -o ./previews my-images/\n\n")
+		fmt.Fprintf(os.Stderr, "  # Extract alpha masks and depth maps\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --extract-aux portrait.avif\n\n")
+		fmt.Fprintf(os.Stderr, "  # Export every image of a burst capture\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --all-items burst.avif\n\n")
+		fmt.Fprintf(os.Stderr, "  # Catalog a collection without converting it\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -r --meta-only -o ./catalog photos/\n\n")
+		fmt.Fprintf(os.Stderr, "  # Measure throughput for capacity planning\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --benchmark 30s --benchmark-workers 4 sample.avif\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --benchmark 10s --json\n\n")
+		fmt.Fprintf(os.Stderr, "  # Check the features of this build from a script\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --version --json\n\n")
+		fmt.Fprintf(os.Stderr, "  # Preset options for the web, overriding one of them\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --profile web --quality 90 -o ./web my-images/\n\n")
+		fmt.Fprintf(os.Stderr, "  # Convert without the avif2png.preset of the project\n")
+		fmt.Fprintf(os.Stderr, "  avif2png --no-preset my-images/\n\n")
+		fmt.Fprintf(os.Stderr, "  # Set defaults from the environment (flags take precedence)\n")
+		fmt.Fprintf(os.Stderr, "  AVIF2PNG_OUTPUT=./converted AVIF2PNG_FORMAT=jpeg avif2png my-images/\n\n")
 		fmt.Fprintf(os.Stderr, "  # Verbose mode\n")
 		fmt.Fprintf(os.Stderr, "  avif2png --verbose image.avif\n")
+		fmt.Fprintf(os.Stderr, "  avif2png -vv odd-colors.avif\n")
+	}
+
+	if err := applyEnv(fs, lookupEnv); err != nil {
+		return nil, err
 	}
+	// Output directories given on the command line replace that of the
+	// environment
+	outputDirs.replace = true
 
 	if err := fs.Parse(args); err != nil {
 		return nil, err
 	}
+	if *profile != "" {
+		if err := applyProfile(fs, *profile); err != nil {
+			return nil, err
+		}
+	}
 
+	// The preset of the project of the input comes after the flags and
+	// --profile, and before the profile it names itself and the environment
+	var presetPath string
+	if !*showVersion && !*noPreset {
+		start := "."
+		if args := fs.Args(); len(args) == 1 && args[0] != "-" && !isURL(args[0]) {
+			start = args[0]
+		}
+		path, err := findPreset(start)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up preset: %w", err)
+		}
+		if path != "" {
+			givenProfile := *profile
+			if err := applyPreset(fs, path); err != nil {
+				return nil, err
+			}
+			if givenProfile == "" && *profile != "" {
+				if err := applyProfile(fs, *profile); err != nil {
+					return nil, fmt.Errorf("invalid preset %s: %w", path, err)
+				}
+			}
+			presetPath = path
+		}
+	}
+
+	// A benchmark without an input uses a synthetic image
 	remainingArgs := fs.Args()
+	if *showVersion {
+		if len(remainingArgs) != 0 {
+			return nil, errors.New("--version takes no input")
+		}
+		remainingArgs = []string{""}
+	}
+	if *benchmark > 0 && len(remainingArgs) == 0 {
+		remainingArgs = []string{""}
+	}
+	if *mapSpec != "" {
+		if len(remainingArgs) != 0 {
+			return nil, errors.New("--map takes no input; the files come from its input glob")
+		}
+		remainingArgs = []string{""}
+	}
+	if *spec != "" {
+		if len(remainingArgs) != 0 {
+			return nil, errors.New("--spec takes no input; the files come from its jobs")
+		}
+		remainingArgs = []string{""}
+	}
+	if *stdinList {
+		if len(remainingArgs) != 0 {
+			return nil, errors.New("--stdin-list takes no input; the files come from stdin")
+		}
+		remainingArgs = []string{"-"}
+	}
 	if len(remainingArgs) != 1 {
 		return nil, errors.New("exactly one input file or directory is required")
 	}
 
-	return &Config{
-		InputPath: remainingArgs[0],
-		OutputDir: *outputDir,
-		Recursive: *recursive,
-		Verbose:   *verbose,
-	}, nil
-}
+	if !isKnownFormat(*format) {
+		return nil, fmt.Errorf("unsupported output format %q (supported: %s)", *format, strings.Join(converter.Formats(), ", "))
+	}
 
-// ValidateInputPath validates that the input path exists and is either a valid file or directory
-// Returns true if the path is a directory, false if it's a file
-func ValidateInputPath(path string) (isDir bool, err error) {
-	info, err := os.Stat(path)
-	if os.IsNotExist(err) {
-		return false, fmt.Errorf("input path does not exist: %s", path)
+	// AVIF is only written from PNG and JPEG inputs, so --format avif is
+	// --to-avif
+	if *format == "avif" {
+		*toAVIF = true
+	}
+	if *toAVIF {
+		if *gifOutput || *autoFormat || (flagSet(fs, "format", "f") && *format != "avif") {
+			return nil, errors.New("--to-avif cannot be combined with --format, --gif or --auto-format")
+		}
+		if flagSet(fs, "quality") {
+			return nil, errors.New("AVIF output uses --avif-quality instead of --quality")
+		}
+		if *useThumbnail || *extractAux || *allItems || *pdfPath != "" {
+			return nil, errors.New("--to-avif cannot be combined with --use-thumbnail, --extract-aux, --all-items or --pdf")
+		}
+		*format = "avif"
+	}
+	if flagSet(fs, "avif-quality") && !*toAVIF {
+		return nil, errors.New("--avif-quality requires --to-avif")
+	}
+	if *avifQuality < 1 || *avifQuality > 100 {
+		return nil, fmt.Errorf("--avif-quality must be between 1 and 100, got: %d", *avifQuality)
+	}
+
+	if *autoFormat && flagSet(fs, "format", "f") {
+		return nil, errors.New("--auto-format cannot be combined with --format")
+	}
+
+	if *gifOutput {
+		if *autoFormat || (flagSet(fs, "format", "f") && *format != "gif") {
+			return nil, errors.New("--gif cannot be combined with --format or --auto-format")
+		}
+		*format = "gif"
+	}
+	if *primaryOnly && (*extractAux || *allItems || *useThumbnail) {
+		return nil, errors.New("--primary-only cannot be combined with --extract-aux, --all-items or --use-thumbnail")
+	}
+	if *metaOnly && (flagSet(fs, "format", "f") || *gifOutput || *autoFormat || *toAVIF || *pdfPath != "" || *spriteSheet != "" ||
+		*maxOutputSize != "" || *stripMetadata || *extractAux || *allItems || *compare || *verify || *verifyExisting ||
+		*dedupe || *flattenHash || *execCommand != "" || *benchmark > 0) {
+		return nil, errors.New("--meta-only writes no images, so it cannot be combined with --format, --gif, --auto-format, --to-avif, --pdf, --spritesheet, --max-output-size, --strip-metadata, --extract-aux, --all-items, --compare, --verify, --verify-existing, --dedupe, --flatten-hash, --exec or --benchmark")
+	}
+
+	// A dry run decodes nothing, so options decided by the image cannot be
+	// planned, and writes nothing, so neither can those writing other files
+	if *dryRun && (*autoFormat || *dedupe || *flattenHash || *extractAux || *allItems || *useThumbnail || *passthrough || *skipNonImages ||
+		*minWidth > 0 || *minHeight > 0 || *maxWidth > 0 || *maxHeight > 0 || *compare || *verifyExisting || *interactive) {
+		return nil, errors.New("--dry-run does not decode images, so it cannot be combined with --auto-format, --dedupe, --flatten-hash, --extract-aux, --all-items, --use-thumbnail, --passthrough-non-avif, --skip-non-images, --min/--max-width or -height, --compare, --verify-existing or --interactive")
+	}
+	if *dryRun && (*pdfPath != "" || *spriteSheet != "" || *reportPath != "" || *logFile != "" || *checksumManifest ||
+		*execCommand != "" || *progressAddr != "" || *conflictReport || *benchmark > 0) {
+		return nil, errors.New("--dry-run writes nothing, so it cannot be combined with --pdf, --spritesheet, --report, --log-file, --checksums-manifest, --exec, --progress-addr, --flatten-conflict-report or --benchmark")
+	}
+
+	if *dither && *format != "gif" {
+		return nil, fmt.Errorf("--dither requires GIF output, got: %s", *format)
+	}
+
+	if *stripMetadata && !*autoFormat && !converter.CanStripMetadata(*format) {
+		return nil, fmt.Errorf("--strip-metadata supports PNG, JPEG and GIF output, got: %s", *format)
+	}
+
+	if *interlace && *format != "png" && !*autoFormat {
+		return nil, fmt.Errorf("--interlace requires PNG output, got: %s", *format)
+	}
+
+	if *chroma != converter.Chroma420 && *chroma != converter.Chroma444 {
+		return nil, fmt.Errorf("--chroma must be 420 or 444, got: %s", *chroma)
+	}
+	if flagSet(fs, "chroma") && *format != "jpeg" && *format != "avif" && !*autoFormat {
+		return nil, fmt.Errorf("--chroma requires JPEG or AVIF output, got: %s", *format)
+	}
+
+	if *quality < 1 || *quality > 100 {
+		return nil, fmt.Errorf("quality must be between 1 and 100, got: %d", *quality)
+	}
+
+	if *debug {
+		*verbose = true
+	}
+
+	if *onCollision != CollisionSkip && (*tarInput || *pdfPath != "") {
+		return nil, errors.New("--on-collision cannot be combined with --tar or --pdf")
+	}
+
+	if *jsonOutput && *verbose {
+		return nil, errors.New("--json cannot be combined with --verbose")
+	}
+
+	if *threadsReport && *pdfPath != "" {
+		return nil, errors.New("--threads-report cannot be combined with --pdf")
+	}
+	if *progressAddr != "" && *pdfPath != "" {
+		return nil, errors.New("--progress-addr cannot be combined with --pdf")
+	}
+
+	if err := converter.ValidateOutputTemplate(*outputTemplate); err != nil {
+		return nil, err
+	}
+	if flagSet(fs, "flatten-separator") {
+		if err := converter.ValidateFlattenSeparator(*flattenSep); err != nil {
+			return nil, fmt.Errorf("--flatten-separator: %w", err)
+		}
+	}
+	if flagSet(fs, "replace-spaces") {
+		if err := converter.ValidateSpaceReplacement(*replaceSpaces); err != nil {
+			return nil, fmt.Errorf("--replace-spaces: %w", err)
+		}
+	}
+
+	if *templateTime != "mtime" && *templateTime != "now" {
+		return nil, fmt.Errorf("--template-time must be mtime or now, got: %s", *templateTime)
+	}
+
+	if *maxDepth < 0 {
+		return nil, fmt.Errorf("--max-depth must not be negative, got: %d", *maxDepth)
+	}
+	if *numberPadding < 0 || *numberPadding > 9 {
+		return nil, fmt.Errorf("--number-padding must be between 0 and 9, got: %d", *numberPadding)
+	}
+	if !*number && flagSet(fs, "number-padding") {
+		return nil, errors.New("--number-padding requires --number")
+	}
+	if *number && (*flattenSep != "" || *outputTemplate != "" || *pdfPath != "") {
+		return nil, errors.New("--number cannot be combined with --flatten-separator, --output-template or --pdf")
+	}
+	if *mapSpec != "" {
+		if _, err := parseMapping(*mapSpec); err != nil {
+			return nil, err
+		}
+		if flagSet(fs, "output", "o") || *outputTemplate != "" || *flattenSep != "" || *number {
+			return nil, errors.New("--map sets the output paths; it cannot be combined with --output, --output-template, --flatten-separator or --number")
+		}
+		if *tarInput || *pdfPath != "" || *conflictReport || *benchmark > 0 || *progressAddr != "" {
+			return nil, errors.New("--map cannot be combined with --tar, --pdf, --flatten-conflict-report, --benchmark or --progress-addr")
+		}
+	}
+	if *spec != "" {
+		if *mapSpec != "" || flagSet(fs, "output", "o") || *outputTemplate != "" || *flattenSep != "" || *number {
+			return nil, errors.New("--spec sets the output paths; it cannot be combined with --map, --output, --output-template, --flatten-separator or --number")
+		}
+		if *tarInput || *pdfPath != "" || *spriteSheet != "" || *conflictReport || *benchmark > 0 || *progressAddr != "" || *threadsReport || *dedupe || *flattenHash || *checksumManifest || *limit > 0 {
+			return nil, errors.New("--spec cannot be combined with --tar, --pdf, --spritesheet, --flatten-conflict-report, --benchmark, --progress-addr, --threads-report, --dedupe, --flatten-hash, --checksums-manifest or --limit")
+		}
+		if *spec == "-" && *interactive {
+			return nil, errors.New("--spec - reads the jobs from stdin, so it cannot be combined with --interactive")
+		}
+	}
+	if *inputMode != InputModeAuto && *inputMode != InputModeFile && *inputMode != InputModeDir {
+		return nil, fmt.Errorf("--mode must be %s, %s or %s, got: %s", InputModeAuto, InputModeFile, InputModeDir, *inputMode)
+	}
+	if *inputMode != InputModeAuto && (*tarInput || *mapSpec != "" || *spec != "") {
+		return nil, errors.New("--mode cannot be combined with --tar, --map or --spec, which read their own inputs")
+	}
+	if *stdinList && (*tarInput || *mapSpec != "" || *spec != "" || *inputMode != InputModeAuto || *interactive || *benchmark > 0) {
+		return nil, errors.New("--stdin-list reads the files from stdin, so it cannot be combined with --tar, --map, --spec, --mode, --interactive or --benchmark")
+	}
+	if *stdinList && (*pdfPath != "" || *spriteSheet != "" || *conflictReport || *number || *onCollision != CollisionSkip) {
+		return nil, errors.New("--stdin-list converts each file as it is read, so it cannot be combined with --pdf, --spritesheet, --flatten-conflict-report, --number or --on-collision")
 	}
+	if len(outputDirs.dirs) > 1 && (*pdfPath != "" || *spriteSheet != "" || *compare || *conflictReport || *benchmark > 0) {
+		return nil, errors.New("more than one --output cannot be combined with --pdf, --spritesheet, --compare, --flatten-conflict-report or --benchmark")
+	}
+	if !converter.IsValidOnError(*onError) {
+		return nil, fmt.Errorf("--on-error must be %s, %s or %s, got: %s", converter.OnErrorSkip, converter.OnErrorStop, converter.OnErrorQuarantine, *onError)
+	}
+	if (*onError == converter.OnErrorQuarantine) != (*quarantineDir != "") {
+		return nil, errors.New("--on-error quarantine and --quarantine-dir must be given together")
+	}
+	if *onError != converter.OnErrorSkip && *pdfPath != "" {
+		return nil, errors.New("--on-error cannot be combined with --pdf")
+	}
+	if *flattenHash && (*pdfPath != "" || *onCollision == CollisionWarn || *onCollision == CollisionError) {
+		return nil, errors.New("--flatten-hash cannot be combined with --pdf or --on-collision warn/error, since outputs no longer collide")
+	}
+	if *passthrough && (*toAVIF || *pdfPath != "") {
+		return nil, errors.New("--passthrough-non-avif cannot be combined with --to-avif or --pdf")
+	}
+	if *normalizeGamma && (*toAVIF || *pdfPath != "" || *spriteSheet != "") {
+		return nil, errors.New("--normalize-gamma cannot be combined with --to-avif, --pdf or --spritesheet")
+	}
+	if *fixAlpha && (*toAVIF || *pdfPath != "" || *spriteSheet != "") {
+		return nil, errors.New("--fix-alpha cannot be combined with --to-avif, --pdf or --spritesheet")
+	}
+	if *limit < 0 {
+		return nil, fmt.Errorf("--limit must be 0 or more, got: %d", *limit)
+	}
+	if *limit > 0 && (*tarInput || *pdfPath != "") {
+		return nil, errors.New("--limit cannot be combined with --tar or --pdf")
+	}
+	if !converter.IsValidShard(*shard) {
+		return nil, fmt.Errorf("--shard must be %s or %s, got: %s", converter.ShardName, converter.ShardHash, *shard)
+	}
+	if *shardWidth < 1 || *shardDepth < 1 {
+		return nil, fmt.Errorf("--shard-width and --shard-depth must be at least 1, got: %d and %d", *shardWidth, *shardDepth)
+	}
+	if *shard == "" && flagSet(fs, "shard-width", "shard-depth") {
+		return nil, errors.New("--shard-width and --shard-depth require --shard")
+	}
+	if *shard != "" && *pdfPath != "" {
+		return nil, errors.New("--shard cannot be combined with --pdf")
+	}
+	var extensions []string
+	if *extensionList != "" {
+		var err error
+		if extensions, err = parseExtensions(*extensionList); err != nil {
+			return nil, err
+		}
+	}
+	if *checksums != "" && !converter.IsValidChecksum(*checksums) {
+		return nil, fmt.Errorf("--checksums must be one of %s, got: %s", strings.Join(converter.ChecksumAlgorithms(), ", "), *checksums)
+	}
+	if *checksumManifest && *checksums == "" {
+		return nil, errors.New("--checksums-manifest requires --checksums")
+	}
+	if *checksums != "" && *pdfPath != "" {
+		return nil, errors.New("--checksums cannot be combined with --pdf")
+	}
+	if *checksumManifest && *mapSpec != "" {
+		return nil, errors.New("--checksums-manifest cannot be combined with --map, whose outputs may be anywhere")
+	}
+	if *dedupe && *pdfPath != "" {
+		return nil, errors.New("--dedupe cannot be combined with --pdf")
+	}
+	if *spriteSheet != "" {
+		if !strings.EqualFold(filepath.Ext(*spriteSheet), ".png") {
+			return nil, fmt.Errorf("--spritesheet must be a .png path, got: %s", *spriteSheet)
+		}
+		if *pdfPath != "" || *toAVIF || *mapSpec != "" || *number || *shard != "" || *checksums != "" || *onError != converter.OnErrorSkip || *onCollision != CollisionSkip || *threadsReport || *progressAddr != "" || *conflictReport {
+			return nil, errors.New("--spritesheet cannot be combined with --pdf, --to-avif, --map, --number, --shard, --checksums, --on-error, --on-collision, --threads-report, --progress-addr or --flatten-conflict-report")
+		}
+	}
+	if *spriteSheetWidth < 1 {
+		return nil, fmt.Errorf("--spritesheet-width must be at least 1, got: %d", *spriteSheetWidth)
+	}
+
+	if *scanWorkers < 1 {
+		return nil, fmt.Errorf("--scan-workers must be at least 1, got: %d", *scanWorkers)
+	}
+	if *scanWorkers > 1 && !*recursive && *maxDepth == 0 {
+		return nil, errors.New("--scan-workers requires --recursive or --max-depth")
+	}
+	workers, err := parseJobs(*jobs)
 	if err != nil {
-		return false, fmt.Errorf("failed to access input path: %w", err)
+		return nil, err
+	}
+	if workers != 1 && (*tarInput || *spec != "" || *pdfPath != "" || *spriteSheet != "" || *benchmark > 0) {
+		return nil, errors.New("--jobs only applies to directory, glob, --stdin-list and --map conversions, so it cannot be combined with --tar, --spec, --pdf, --spritesheet or --benchmark")
+	}
+	if workers != 1 && (*interactive || *dedupe || *flattenHash) {
+		return nil, errors.New("--jobs cannot be combined with --interactive, --dedupe or --flatten-hash, which handle one file at a time")
 	}
 
-	// If it's a directory, just verify it's readable
-	if info.IsDir() {
-		return true, nil
+	if *onCollision != CollisionSkip && *onCollision != CollisionWarn && *onCollision != CollisionError {
+		return nil, fmt.Errorf("--on-collision must be skip, warn or error, got: %s", *onCollision)
 	}
 
-	// If it's a file, check extension
-	ext := strings.ToLower(filepath.Ext(path))
-	if ext != ".avif" {
-		return false, fmt.Errorf("input file must have .avif extension, got: %s", ext)
+	if *thumbnailFallback != "full" && *thumbnailFallback != "error" {
+		return nil, fmt.Errorf("--thumbnail-fallback must be full or error, got: %s", *thumbnailFallback)
 	}
 
-	return false, nil
-}
+	if *timeout <= 0 {
+		return nil, fmt.Errorf("--timeout must be positive, got: %s", *timeout)
+	}
 
-// ValidateInputFile validates that the input file exists and has .avif extension
-func ValidateInputFile(path string) error {
-	// Check if file exists
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return fmt.Errorf("input file does not exist: %s", path)
+	if *benchmark < 0 {
+		return nil, fmt.Errorf("--benchmark must not be negative, got: %s", *benchmark)
 	}
 
-	// Check extension
-	ext := strings.ToLower(filepath.Ext(path))
-	if ext != ".avif" {
-		return fmt.Errorf("input file must have .avif extension, got: %s", ext)
+	if *benchmarkWorkers < 1 {
+		return nil, fmt.Errorf("--benchmark-workers must be at least 1, got: %d", *benchmarkWorkers)
 	}
 
-	return nil
-}
+	if *jsonIndent < 0 {
+		return nil, fmt.Errorf("--json-indent must not be negative, got: %d", *jsonIndent)
+	}
+	if *logAppend && *logFile == "" {
+		return nil, errors.New("--log-append requires --log-file")
+	}
 
-// runSingleFileConversion handles conversion of a single AVIF file
-func runSingleFileConversion(config *Config) error {
-	return converter.AVIFToPNG(config.InputPath, config.OutputDir, config.Verbose)
-}
+	sinceTime, err := parseSince(*since, time.Now())
+	if err != nil {
+		return nil, err
+	}
 
-// runDirectoryConversion handles conversion of all AVIF files in a directory
-func runDirectoryConversion(config *Config) error {
-	result, err := converter.ConvertDirectory(config.InputPath, config.OutputDir, config.Recursive, config.Verbose)
+	outputFileMode, err := parseFileMode(*fileMode)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Print summary for non-verbose mode
-	if !config.Verbose && result.TotalFiles > 0 {
-		if result.Failed > 0 || result.Skipped > 0 {
-			fmt.Printf("✅ Converted %d/%d files", result.Successful, result.TotalFiles)
-			if result.Skipped > 0 {
-				fmt.Printf(" (%d skipped - already exist)", result.Skipped)
-			}
-			if result.Failed > 0 {
-				fmt.Printf(" (%d failed)", result.Failed)
-			}
-			fmt.Println()
-		} else {
-			fmt.Printf("✅ Converted %d file(s)\n", result.Successful)
+	maxOutputBytes, err := parseSize(*maxOutputSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --max-output-size: %w", err)
+	}
+
+	var sharpen float64
+	if *sharpenImages {
+		if maxOutputBytes == 0 && *canvas == "" {
+			return nil, errors.New("--sharpen requires --max-output-size or --canvas, the options that resize images")
+		}
+		if *sharpenAmount <= 0 || *sharpenRadius <= 0 {
+			return nil, fmt.Errorf("--sharpen-amount and --sharpen-radius must be positive, got: %g and %g", *sharpenAmount, *sharpenRadius)
 		}
+		sharpen = *sharpenAmount
+	} else if flagSet(fs, "sharpen-amount", "sharpen-radius") {
+		return nil, errors.New("--sharpen-amount and --sharpen-radius require --sharpen")
 	}
 
-	// Print verbose summary
-	if config.Verbose && result.TotalFiles > 0 {
-		fmt.Printf("\n📊 Summary: %d successful, %d skipped, %d failed\n",
-			result.Successful, result.Skipped, result.Failed)
+	if *trimTolerance < 0 || *trimTolerance > 255 {
+		return nil, fmt.Errorf("--trim-tolerance must be between 0 and 255, got: %d", *trimTolerance)
+	}
+	canvasSize, err := parseCanvas(*canvas)
+	if err != nil {
+		return nil, err
+	}
+	pad, err := parsePadColor(*padColor)
+	if err != nil {
+		return nil, err
+	}
+	if canvasSize == (image.Point{}) && flagSet(fs, "pad-color") {
+		return nil, errors.New("--pad-color requires --canvas")
+	}
+	crop, err := parseCropPct(*cropPct)
+	if err != nil {
+		return nil, err
+	}
+	if !converter.IsValidRotation(*rotateDegrees) {
+		return nil, fmt.Errorf("--rotate must be 0, 90, 180 or 270, got: %d", *rotateDegrees)
+	}
+	if !*trimBorders && flagSet(fs, "trim-tolerance") {
+		return nil, errors.New("--trim-tolerance requires --trim")
+	}
+	dimensions := converter.DimensionBounds{MinWidth: *minWidth, MinHeight: *minHeight, MaxWidth: *maxWidth, MaxHeight: *maxHeight}
+	if err := checkDimensionBounds(dimensions); err != nil {
+		return nil, err
+	}
+	if !dimensions.IsZero() && (*pdfPath != "" || *spriteSheet != "") {
+		return nil, errors.New("--min-width, --min-height, --max-width and --max-height cannot be combined with --pdf or --spritesheet")
 	}
 
-	// Print error details
-	if len(result.Errors) > 0 {
-		fmt.Fprintf(os.Stderr, "\n❌ Failed conversions:\n")
-		for _, fileErr := range result.Errors {
-			fmt.Fprintf(os.Stderr, "  - %s: %v\n", filepath.Base(fileErr.FilePath), fileErr.Error)
+	var execArgs []string
+	if *execCommand != "" {
+		if execArgs, err = converter.SplitCommand(*execCommand); err != nil {
+			return nil, fmt.Errorf("invalid --exec command: %w", err)
 		}
-		return fmt.Errorf("completed with %d error(s)", len(result.Errors))
 	}
 
-	// If no files were found
-	if result.TotalFiles == 0 {
-		fmt.Println("⚠️  No AVIF files found in directory")
+	return &Config{
+		InputPath:         remainingArgs[0],
+		OutputDir:         outputDirs.dirs[0],
+		TeeDirs:           outputDirs.dirs[1:],
+		FileMode:          outputFileMode,
+		Format:            *format,
+		Profile:           *profile,
+		Preset:            presetPath,
+		Quality:           *quality,
+		ToAVIF:            *toAVIF,
+		AVIFQuality:       *avifQuality,
+		Recursive:         *recursive || *maxDepth > 0,
+		InputMode:         *inputMode,
+		MaxDepth:          *maxDepth,
+		ScanWorkers:       *scanWorkers,
+		Workers:           workers,
+		Verbose:           *verbose,
+		Debug:             *debug,
+		IncludeHidden:     *includeHidden,
+		AnyExt:            *anyExt,
+		Extensions:        extensions,
+		Since:             sinceTime,
+		FlattenSeparator:  *flattenSep,
+		SanitizeNames:     *sanitizeNames,
+		ReplaceSpaces:     *replaceSpaces,
+		FlattenHash:       *flattenHash,
+		Limit:             *limit,
+		Shard:             *shard,
+		ShardWidth:        *shardWidth,
+		ShardDepth:        *shardDepth,
+		Number:            *number,
+		NumberPadding:     *numberPadding,
+		SkipNonImages:     *skipNonImages,
+		Passthrough:       *passthrough,
+		Dedupe:            *dedupe,
+		Checksums:         *checksums,
+		ChecksumManifest:  *checksumManifest,
+		OnError:           *onError,
+		QuarantineDir:     *quarantineDir,
+		ConflictReport:    *conflictReport,
+		DryRun:            *dryRun,
+		OnCollision:       *onCollision,
+		OutputTemplate:    *outputTemplate,
+		OutputTemplateNow: *templateTime == "now",
+		Map:               *mapSpec,
+		Spec:              *spec,
+		ExtractAux:        *extractAux,
+		AllItems:          *allItems,
+		MetaOnly:          *metaOnly,
+		PrimaryOnly:       *primaryOnly,
+		UseThumbnail:      *useThumbnail,
+		ThumbnailRequired: *thumbnailFallback == "error",
+		Interactive:       *interactive,
+		PreserveMtime:     *preserveMtime,
+		StripMetadata:     *stripMetadata,
+		Verify:            *verify,
+		VerifyExisting:    *verifyExisting,
+		SkipIsError:       *skipIsError,
+		Compare:           *compare,
+		Optimize:          *optimize,
+		MaxOutputSize:     maxOutputBytes,
+		SharpenAmount:     sharpen,
+		SharpenRadius:     *sharpenRadius,
+		CropPct:           crop,
+		Canvas:            canvasSize,
+		PadColor:          pad,
+		Dimensions:        dimensions,
+		Rotate:            *rotateDegrees,
+		Trim:              *trimBorders,
+		TrimTolerance:     *trimTolerance,
+		Interlace:         *interlace,
+		NormalizeGamma:    *normalizeGamma,
+		FixAlpha:          *fixAlpha,
+		Chroma:            *chroma,
+		Dither:            *dither,
+		AutoFormat:        *autoFormat,
+		Exec:              execArgs,
+		Tar:               *tarInput,
+		StdinList:         *stdinList,
+		Benchmark:         *benchmark,
+		BenchmarkWorkers:  *benchmarkWorkers,
+		ThreadsReport:     *threadsReport,
+		ProgressAddr:      *progressAddr,
+		Timeout:           *timeout,
+		PDFPath:           *pdfPath,
+		SpriteSheet:       *spriteSheet,
+		SpriteSheetWidth:  *spriteSheetWidth,
+		ReportPath:        *reportPath,
+		ReportPreviews:    *reportPreviews,
+		JSON:              *jsonOutput,
+		JSONIndent:        *jsonIndent,
+		LogFile:           *logFile,
+		LogAppend:         *logAppend,
+		ShowVersion:       *showVersion,
+		Features:          flagNames(fs),
+	}, nil
+}
+
+// envName returns the environment variable for a flag, e.g. AVIF2PNG_JSON_INDENT
+func envName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// outputDirsFlag is the value of --output, which may be repeated; the first
+// directory is the output directory, and the others get copies of each
+// output
+// While replace is set, the next value replaces the directories instead of
+// adding to them, as the first one given does with the default
+type outputDirsFlag struct {
+	dirs    []string
+	replace bool
+}
+
+func (f *outputDirsFlag) String() string {
+	if f == nil {
+		return ""
 	}
+	return strings.Join(f.dirs, ", ")
+}
 
+func (f *outputDirsFlag) Set(value string) error {
+	if f.replace {
+		f.dirs, f.replace = nil, false
+	}
+	f.dirs = append(f.dirs, value)
 	return nil
 }
 
-// Run executes the main application logic
-func Run(config *Config) error {
-	isDir, err := ValidateInputPath(config.InputPath)
-	if err != nil {
-		return err
+// applyEnv sets flags from their environment variables, so that flags given
+// on the command line, which are parsed afterwards, take precedence
+// The values are set as defaults, not as given flags: flagSet ignores them,
+// so they never conflict with other flags and --profile and presets replace
+// them
+// Shorthand flags share their value with the long form and are not looked up,
+// nor is --version, which is a command rather than a default
+func applyEnv(fs *flag.FlagSet, lookupEnv func(string) (string, bool)) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || len(f.Name) == 1 || f.Name == "version" {
+			return
+		}
+		value, ok := lookupEnv(envName(f.Name))
+		if !ok {
+			return
+		}
+		if setErr := f.Value.Set(value); setErr != nil {
+			err = fmt.Errorf("invalid %s: %w", envName(f.Name), setErr)
+		}
+	})
+	return err
+}
+
+// parseSince parses a --since value, either a duration relative to now
+// (e.g. "24h") or an absolute date ("2006-01-02" or RFC 3339)
+// An empty value returns the zero time, which disables the filter
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
 	}
 
-	if isDir {
-		return runDirectoryConversion(config)
+	if d, err := time.ParseDuration(value); err == nil {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("--since duration must be positive, got: %s", value)
+		}
+		return now.Add(-d), nil
+	}
+
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid --since value %q: expected a duration (e.g. 24h) or a date (e.g. 2024-01-01)", value)
+}
+
+// parseJobs parses a --jobs value, a number of files of at least 1, or
+// "auto" for converter.WorkersAuto
+func parseJobs(value string) (int, error) {
+	if value == "auto" {
+		return converter.WorkersAuto, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("--jobs must be a number of at least 1 or auto, got: %s", value)
+	}
+	return n, nil
+}
+
+// parseCropPct parses a --crop-pct value, four comma-separated percentages
+// left,top,right,bottom such as "10,10,90,90"; an empty value returns the
+// zero CropPercent, which does not crop
+func parseCropPct(value string) (converter.CropPercent, error) {
+	if value == "" {
+		return converter.CropPercent{}, nil
+	}
+
+	invalid := fmt.Errorf("--crop-pct must be left,top,right,bottom percentages with left < right and top < bottom, e.g. 10,10,90,90, got: %s", value)
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return converter.CropPercent{}, invalid
+	}
+	var pcts [4]float64
+	for i, part := range parts {
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(part), "%"), 64)
+		if err != nil || math.IsNaN(pct) {
+			return converter.CropPercent{}, invalid
+		}
+		pcts[i] = pct
+	}
+
+	crop := converter.CropPercent{Left: pcts[0], Top: pcts[1], Right: pcts[2], Bottom: pcts[3]}
+	if !crop.IsValid() {
+		return converter.CropPercent{}, invalid
+	}
+	return crop, nil
+}
+
+// checkDimensionBounds returns an error for negative bounds, or a minimum
+// above the maximum of the same side
+func checkDimensionBounds(b converter.DimensionBounds) error {
+	flags := []string{"min-width", "min-height", "max-width", "max-height"}
+	for i, value := range []int{b.MinWidth, b.MinHeight, b.MaxWidth, b.MaxHeight} {
+		if value < 0 {
+			return fmt.Errorf("--%s must be 0 or more, got: %d", flags[i], value)
+		}
+	}
+	if b.MaxWidth > 0 && b.MinWidth > b.MaxWidth {
+		return fmt.Errorf("--min-width %d is above --max-width %d", b.MinWidth, b.MaxWidth)
+	}
+	if b.MaxHeight > 0 && b.MinHeight > b.MaxHeight {
+		return fmt.Errorf("--min-height %d is above --max-height %d", b.MinHeight, b.MaxHeight)
+	}
+	return nil
+}
+
+// parseCanvas parses a --canvas value, a size such as "1920x1080"; an empty
+// value returns the zero size, which keeps images as they are
+func parseCanvas(value string) (image.Point, error) {
+	if value == "" {
+		return image.Point{}, nil
+	}
+
+	w, h, ok := strings.Cut(strings.ToLower(value), "x")
+	width, wErr := strconv.Atoi(w)
+	height, hErr := strconv.Atoi(h)
+	if !ok || wErr != nil || hErr != nil || width < 1 || height < 1 {
+		return image.Point{}, fmt.Errorf("--canvas must be WIDTHxHEIGHT in pixels, e.g. 1920x1080, got: %s", value)
+	}
+	return image.Pt(width, height), nil
+}
+
+// parsePadColor parses a --pad-color value, RRGGBB or RRGGBBAA hex digits
+// with an optional leading #; colors without alpha are opaque
+func parsePadColor(value string) (color.NRGBA, error) {
+	digits := strings.TrimPrefix(value, "#")
+	b, err := hex.DecodeString(digits)
+	if err != nil || (len(b) != 3 && len(b) != 4) {
+		return color.NRGBA{}, fmt.Errorf("--pad-color must be RRGGBB or RRGGBBAA hex, e.g. ffffff, got: %s", value)
+	}
+	c := color.NRGBA{R: b[0], G: b[1], B: b[2], A: 0xff}
+	if len(b) == 4 {
+		c.A = b[3]
+	}
+	return c, nil
+}
+
+// parseFileMode parses a --file-mode value, an octal permission such as
+// "0600" or "0o640"; an empty value returns 0, which keeps the default
+func parseFileMode(value string) (os.FileMode, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	mode, err := strconv.ParseUint(strings.TrimPrefix(value, "0o"), 8, 32)
+	if err != nil || mode == 0 || mode > 0777 {
+		return 0, fmt.Errorf("--file-mode must be an octal permission between 1 and 0777, such as 0600, got: %s", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// sizeUnits are the suffixes accepted by parseSize, longest first so that
+// "KiB" is not read as "B"
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+	{"KB", 1000}, {"MB", 1000 * 1000}, {"GB", 1000 * 1000 * 1000},
+	{"K", 1000}, {"M", 1000 * 1000}, {"G", 1000 * 1000 * 1000},
+	{"B", 1},
+}
+
+// parseSize parses a byte size such as "500KB", "1.5MB", "2MiB" or "1048576"
+// KB, MB and GB are powers of 1000, which is the stricter reading when a
+// limit may have meant either; an empty value returns 0
+func parseSize(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	number, multiplier := value, int64(1)
+	for _, unit := range sizeUnits {
+		if len(value) > len(unit.suffix) && strings.EqualFold(value[len(value)-len(unit.suffix):], unit.suffix) {
+			number, multiplier = strings.TrimSpace(value[:len(value)-len(unit.suffix)]), unit.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n*float64(multiplier) < 1 || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("expected a positive size such as 500KB, got: %s", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// flagSet reports whether any of the named flags was given on the command line
+func flagSet(fs *flag.FlagSet, names ...string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		for _, name := range names {
+			if f.Name == name {
+				set = true
+			}
+		}
+	})
+	return set
+}
+
+// isKnownFormat reports whether an encoder is registered for format
+func isKnownFormat(format string) bool {
+	for _, known := range converter.Formats() {
+		if format == known {
+			return true
+		}
+	}
+	return false
+}
+
+// converterOptions builds the converter options for this configuration
+// --to-avif outputs are encoded at AVIFQuality instead of Quality
+func (c *Config) converterOptions() converter.Options {
+	opts := converter.Options{
+		Format:            c.Format,
+		Quality:           c.Quality,
+		Recursive:         c.Recursive,
+		MaxDepth:          c.MaxDepth,
+		ScanWorkers:       c.ScanWorkers,
+		Workers:           c.Workers,
+		Verbose:           c.Verbose,
+		Debug:             c.Debug,
+		Log:               c.stdout(),
+		IncludeHidden:     c.IncludeHidden,
+		Since:             c.Since,
+		FlattenSeparator:  c.FlattenSeparator,
+		SanitizeNames:     c.SanitizeNames,
+		ReplaceSpaces:     c.ReplaceSpaces,
+		FlattenHash:       c.FlattenHash,
+		Limit:             c.Limit,
+		Shard:             c.Shard,
+		ShardWidth:        c.ShardWidth,
+		ShardDepth:        c.ShardDepth,
+		Number:            c.Number,
+		NumberPadding:     c.NumberPadding,
+		SkipNonImages:     c.SkipNonImages,
+		Passthrough:       c.Passthrough,
+		Dedupe:            c.Dedupe,
+		Checksum:          c.Checksums,
+		ChecksumManifest:  c.ChecksumManifest,
+		OnError:           c.OnError,
+		QuarantineDir:     c.QuarantineDir,
+		OutputTemplate:    c.OutputTemplate,
+		OutputTemplateNow: c.OutputTemplateNow,
+		Extensions:        c.Extensions,
+		ExtractAux:        c.ExtractAux,
+		AllItems:          c.AllItems,
+		MetaOnly:          c.MetaOnly,
+		DryRun:            c.DryRun,
+		PrimaryOnly:       c.PrimaryOnly,
+		UseThumbnail:      c.UseThumbnail,
+		ThumbnailRequired: c.ThumbnailRequired,
+		PreserveMtime:     c.PreserveMtime,
+		StripMetadata:     c.StripMetadata,
+		TrackResources:    c.ThreadsReport,
+		Verify:            c.Verify,
+		VerifyExisting:    c.VerifyExisting,
+		Compare:           c.Compare,
+		Optimize:          c.Optimize,
+		MaxOutputSize:     c.MaxOutputSize,
+		SharpenAmount:     c.SharpenAmount,
+		SharpenRadius:     c.SharpenRadius,
+		CropPct:           c.CropPct,
+		Canvas:            c.Canvas,
+		PadColor:          c.PadColor,
+		Dimensions:        c.Dimensions,
+		Rotate:            c.Rotate,
+		Trim:              c.Trim,
+		TrimTolerance:     c.TrimTolerance,
+		Interlace:         c.Interlace,
+		NormalizeGamma:    c.NormalizeGamma,
+		FixAlpha:          c.FixAlpha,
+		Chroma:            c.Chroma,
+		Dither:            c.Dither,
+		AutoFormat:        c.AutoFormat,
+		Exec:              c.Exec,
+		FileMode:          c.FileMode,
+		TeeDirs:           c.TeeDirs,
+		ToAVIF:            c.ToAVIF,
+	}
+	if c.ToAVIF {
+		opts.Quality = c.AVIFQuality
+	}
+	return opts
+}
+
+// ValidateInputPath validates that the input path exists and is either a valid file or directory
+// Returns true if the path is a directory, false if it's a file
+func ValidateInputPath(path string) (isDir bool, err error) {
+	return validateInputPath(path, converter.InputExtensions(false))
+}
+
+// validateInputPath is ValidateInputPath for files with one of exts
+// With no exts, whether a file is an image is left to the decoder
+func validateInputPath(path string, exts []string) (isDir bool, err error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, fmt.Errorf("input path does not exist: %s", path)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to access input path: %w", err)
+	}
+
+	// If it's a directory, just verify it's readable
+	if info.IsDir() {
+		return true, nil
+	}
+
+	// If it's a file, check extension
+	ext := strings.ToLower(filepath.Ext(path))
+	if len(exts) > 0 && !hasExtension(path, exts) {
+		return false, fmt.Errorf("input file must have %s extension, got: %s (use --any-ext to skip this check)", strings.Join(exts, " or "), ext)
+	}
+
+	return false, nil
+}
+
+// checkInputMode returns an error when an input path that is a directory,
+// or not, does not match an explicit --mode
+func checkInputMode(mode, path string, isDir bool) error {
+	switch {
+	case mode == InputModeFile && isDir:
+		return fmt.Errorf("--mode file requires a file input, got directory: %s (use --mode dir to convert its files)", path)
+	case mode == InputModeDir && !isDir:
+		return fmt.Errorf("--mode dir requires a directory input, got file: %s (use --mode file to convert it)", path)
+	}
+	return nil
+}
+
+// hasExtension reports whether path has one of the lowercase exts, in any case
+func hasExtension(path string, exts []string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, want := range exts {
+		if ext == want {
+			return true
+		}
+	}
+	return false
+}
+
+// inputKind names the files with one of exts, e.g. "AVIF" for ".avif"
+func inputKind(exts []string) string {
+	if len(exts) == 0 {
+		return "input"
+	}
+	names := make([]string, len(exts))
+	for i, ext := range exts {
+		names[i] = strings.ToUpper(strings.TrimPrefix(ext, "."))
+	}
+	return strings.Join(names, ", ")
+}
+
+// inputExtensions returns the extensions input files must have: those of
+// --extensions, or the default ones of the conversion; nil with --any-ext
+func (c *Config) inputExtensions() []string {
+	if c.AnyExt {
+		return nil
+	}
+	if len(c.Extensions) > 0 {
+		return c.Extensions
+	}
+	return converter.InputExtensions(c.ToAVIF)
+}
+
+// parseExtensions parses the comma-separated list of --extensions into
+// lowercase extensions with a leading dot, e.g. "avif, .AVIFS" into
+// [.avif .avifs]
+func parseExtensions(list string) ([]string, error) {
+	var exts []string
+	for _, entry := range strings.Split(list, ",") {
+		ext := strings.ToLower(strings.TrimSpace(entry))
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if ext == "." || strings.ContainsAny(ext[1:], `./\`) {
+			return nil, fmt.Errorf("invalid --extensions entry %q (use e.g. .avif,.avifs)", strings.TrimSpace(entry))
+		}
+		exts = append(exts, ext)
+	}
+	return exts, nil
+}
+
+// ValidateInputFile validates that the input file exists and has .avif extension
+func ValidateInputFile(path string) error {
+	return validateInputFile(path, converter.InputExtensions(false))
+}
+
+// validateInputFile is ValidateInputFile for files with one of exts
+func validateInputFile(path string, exts []string) error {
+	// Check if file exists
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return fmt.Errorf("input file does not exist: %s", path)
+	}
+
+	// Check extension
+	if !hasExtension(path, exts) {
+		return fmt.Errorf("input file must have %s extension, got: %s", strings.Join(exts, " or "), strings.ToLower(filepath.Ext(path)))
+	}
+
+	return nil
+}
+
+// normalizePath expands a leading "~" to the user's home directory and
+// cleans the path, which also removes any trailing separators
+func normalizePath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(os.PathSeparator)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to expand home directory: %w", err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+
+	return filepath.Clean(path), nil
+}
+
+// runSingleFileConversion handles conversion of a single AVIF file
+func runSingleFileConversion(ctx context.Context, config *Config) error {
+	opts := config.converterOptions()
+	opts.Prompt = config.overwritePrompt()
+	file, err := converter.ConvertFileContext(ctx, config.InputPath, config.OutputDir, opts)
+	if errors.Is(err, context.Canceled) {
+		return ErrInterrupted
+	}
+	if err != nil {
+		return err
+	}
+	if config.DryRun {
+		return reportPlan(config, &converter.ConversionResult{TotalFiles: 1, Files: []converter.FileResult{file}}, nil, "file")
+	}
+
+	if file.Status == converter.StatusSkipped {
+		if config.SkipIsError {
+			return fmt.Errorf("skipped %s: output %s", filepath.Base(config.InputPath), converter.SkipReasonExists)
+		}
+		if file.SkipReason == converter.SkipReasonDimensions {
+			fmt.Fprintf(config.stdout(), "⚠️  Skipped %s (%s: %dx%d)\n", filepath.Base(config.InputPath), file.SkipReason, file.Width, file.Height)
+		} else {
+			fmt.Fprintf(config.stdout(), "⚠️  Skipped %s (%s)\n", filepath.Base(config.InputPath), file.SkipReason)
+		}
+	}
+	return nil
+}
+
+// printSummary prints the counters of a (possibly partial) directory conversion
+func printSummary(config *Config, result *converter.ConversionResult) {
+	if result.TotalFiles == 0 {
+		return
+	}
+
+	skipped := result.Skipped()
+	partial := result.Successful+skipped+result.Failed < result.TotalFiles
+
+	// Print summary for non-verbose mode
+	if !config.Verbose {
+		if result.Failed > 0 || skipped > 0 || partial {
+			fmt.Fprintf(config.stdout(), "✅ Converted %d/%d files", result.Successful, result.TotalFiles)
+			if skipped > 0 {
+				fmt.Fprintf(config.stdout(), " (%s)", formatSkipped(skipped, result.SkippedReasons))
+			}
+			if result.Failed > 0 {
+				fmt.Fprintf(config.stdout(), " (%d failed", result.Failed)
+				if result.Empty > 0 {
+					fmt.Fprintf(config.stdout(), ", %d empty or truncated", result.Empty)
+				}
+				fmt.Fprintf(config.stdout(), ")")
+			}
+			fmt.Fprintln(config.stdout())
+		} else {
+			fmt.Fprintf(config.stdout(), "✅ Converted %d file(s)\n", result.Successful)
+		}
+		return
+	}
+
+	// Print verbose summary
+	fmt.Fprintf(config.stdout(), "\n📊 Summary: %d successful, %d skipped, %d failed",
+		result.Successful, skipped, result.Failed)
+	if result.Empty > 0 {
+		fmt.Fprintf(config.stdout(), " (%d empty or truncated)", result.Empty)
+	}
+	fmt.Fprintln(config.stdout())
+	if skipped > 0 {
+		fmt.Fprintf(config.stdout(), "   Skipped: %s\n", formatSkipReasons(result.SkippedReasons))
+	}
+	if result.Filtered() > 0 {
+		fmt.Fprintf(config.stdout(), "   Filtered out: %s\n", formatSkipReasons(result.FilteredReasons))
+	}
+	if counts := result.ExtensionCounts(); len(counts) > 0 {
+		fmt.Fprintf(config.stdout(), "   Extensions: %s\n", formatExtensionCounts(counts))
+	}
+}
+
+// formatExtensionCounts formats the extension counts of a conversion, e.g.
+// "42 .avif (40 converted), 3 .AVIF (3 converted)"
+func formatExtensionCounts(counts []converter.ExtensionCount) string {
+	parts := make([]string, 0, len(counts))
+	for _, count := range counts {
+		ext := count.Extension
+		if ext == "" {
+			ext = "without extension"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s (%d converted)", count.Seen, ext, count.Converted))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// printResources prints the resources measured by --threads-report
+func printResources(w io.Writer, result *converter.ConversionResult) {
+	usage := result.Resources
+	if usage == nil {
+		return
+	}
+
+	fmt.Fprintf(w, "⚙️  Resources: %s elapsed", usage.Elapsed.Round(time.Millisecond))
+	if usage.CPUTime > 0 {
+		fmt.Fprintf(w, ", %s CPU, parallelism %.2f", usage.CPUTime.Round(time.Millisecond), usage.Parallelism())
+	}
+	fmt.Fprintf(w, ", peak heap %.1f MB\n", float64(usage.PeakHeap)/(1<<20))
+}
+
+// filterHints tell how to include the files left out for each filter reason
+var filterHints = map[string]string{
+	converter.FilterReasonHidden: "Use --include-hidden to convert hidden files",
+	converter.FilterReasonSince:  "Use an earlier --since to convert older files",
+}
+
+// noFilesMessage explains a conversion that found no AVIF files in kind,
+// telling an empty input apart from one whose files were all filtered out
+func noFilesMessage(result *converter.ConversionResult, kind string) string {
+	filtered := result.Filtered()
+	if filtered == 0 {
+		return fmt.Sprintf("⚠️  No AVIF files found in %s", kind)
+	}
+
+	msg := fmt.Sprintf("⚠️  No AVIF files to convert in %s: %d filtered out (%s)", kind, filtered, formatSkipReasons(result.FilteredReasons))
+	reasons := make([]string, 0, len(result.FilteredReasons))
+	for reason, count := range result.FilteredReasons {
+		if count > 0 && filterHints[reason] != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		msg += "\n   " + filterHints[reason]
+	}
+	return msg
+}
+
+// formatSkipped describes the skipped files of a run, e.g. "3 skipped:
+// already exist", with the count of each reason only when there are
+// several, e.g. "3 skipped: 2 already exist, 1 duplicate"
+func formatSkipped(skipped int, reasons map[string]int) string {
+	for reason, count := range reasons {
+		if count == skipped {
+			return fmt.Sprintf("%d skipped: %s", skipped, reasonLabel(reason, count))
+		}
+	}
+	return fmt.Sprintf("%d skipped: %s", skipped, formatSkipReasons(reasons))
+}
+
+// reasonLabel returns reason as it reads after count, "already exist" for
+// several files
+func reasonLabel(reason string, count int) string {
+	if reason == converter.SkipReasonExists && count > 1 {
+		return "already exist"
+	}
+	return reason
+}
+
+// formatSkipReasons lists skip counts by reason, e.g. "2 already exist, 1 filtered"
+// Reasons are sorted so the output is stable
+func formatSkipReasons(reasons map[string]int) string {
+	names := make([]string, 0, len(reasons))
+	for reason, count := range reasons {
+		if count > 0 {
+			names = append(names, reason)
+		}
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, reason := range names {
+		parts = append(parts, fmt.Sprintf("%d %s", reasons[reason], reasonLabel(reason, reasons[reason])))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// printChangedOutputs lists the existing outputs that differ from a new
+// conversion in compare mode, with their PSNR
+func printChangedOutputs(w io.Writer, result *converter.ConversionResult) {
+	var changed []converter.FileResult
+	for _, file := range result.Files {
+		if file.SkipReason == converter.SkipReasonChanged {
+			changed = append(changed, file)
+		}
+	}
+	if len(changed) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n🔍 Changed outputs:\n")
+	for _, file := range changed {
+		if file.PSNR == 0 {
+			fmt.Fprintf(w, "  - %s (dimensions differ)\n", file.OutputPath)
+		} else {
+			fmt.Fprintf(w, "  - %s (PSNR %.2f dB)\n", file.OutputPath, file.PSNR)
+		}
+	}
+}
+
+// printBlankOutputs lists the converted images that look blank, which often
+// point at a bug in the tool that exported them
+func printBlankOutputs(w io.Writer, result *converter.ConversionResult) {
+	var blank []converter.FileResult
+	for _, file := range result.Files {
+		if file.Warning != "" {
+			blank = append(blank, file)
+		}
+	}
+	if len(blank) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n⚠️  %d image(s) look blank:\n", len(blank))
+	for _, file := range blank {
+		fmt.Fprintf(w, "  - %s (%s)\n", file.InputPath, file.Warning)
+	}
+}
+
+// printDuplicates lists the files skipped by --dedupe with the input each
+// one repeats, and the output bytes saved by not writing them
+func printDuplicates(w io.Writer, result *converter.ConversionResult) {
+	var duplicates []converter.FileResult
+	var saved int64
+	for _, file := range result.Files {
+		if file.DuplicateOf != "" {
+			duplicates = append(duplicates, file)
+			saved += file.OutputSize
+		}
+	}
+	if len(duplicates) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n♻️  Deduplicated %d file(s), saving %.1f MB:\n", len(duplicates), float64(saved)/(1<<20))
+	for _, file := range duplicates {
+		fmt.Fprintf(w, "  - %s = %s (%s)\n", file.InputPath, file.DuplicateOf, file.OutputPath)
+	}
+}
+
+// printQuarantined lists the inputs that --on-error quarantine moved away
+func printQuarantined(w io.Writer, result *converter.ConversionResult) {
+	var moved []converter.FileResult
+	for _, file := range result.Files {
+		if file.QuarantinePath != "" {
+			moved = append(moved, file)
+		}
+	}
+	if len(moved) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n🚧 Quarantined %d file(s) that failed to decode:\n", len(moved))
+	for _, file := range moved {
+		fmt.Fprintf(w, "  - %s -> %s\n", file.InputPath, file.QuarantinePath)
+	}
+}
+
+// printFileErrors lists the files that failed to convert
+func printFileErrors(w io.Writer, result *converter.ConversionResult) {
+	if len(result.Errors) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n❌ Failed conversions:\n")
+	for _, fileErr := range result.Errors {
+		fmt.Fprintf(w, "  - %s: %v\n", filepath.Base(fileErr.FilePath), fileErr.Error)
+	}
+}
+
+// runDirectoryConversion handles conversion of all AVIF files in a directory
+func runDirectoryConversion(ctx context.Context, config *Config) error {
+	opts := config.converterOptions()
+	opts.Prompt = config.overwritePrompt()
+	if config.OnCollision == CollisionWarn || config.OnCollision == CollisionError {
+		conflicts, err := converter.FindOutputConflicts(config.InputPath, config.OutputDir, opts)
+		if err != nil {
+			return err
+		}
+		if err := checkCollisions(config, conflicts); err != nil {
+			return err
+		}
+	}
+
+	var result *converter.ConversionResult
+	var err error
+	if config.PDFPath != "" {
+		result, err = converter.ConvertDirectoryToPDF(ctx, config.InputPath, config.PDFPath, opts)
+	} else if config.SpriteSheet != "" {
+		result, err = converter.ConvertDirectoryToSpriteSheet(ctx, config.InputPath, config.SpriteSheet, config.SpriteSheetWidth, opts)
+	} else {
+		result = &converter.ConversionResult{}
+		stopProgress, progressErr := startProgress(config, result)
+		if progressErr != nil {
+			return progressErr
+		}
+		err = converter.ConvertDirectoryInto(ctx, config.InputPath, config.OutputDir, opts, result)
+		stopProgress()
+	}
+
+	return reportConversion(config, result, err, "directory")
+}
+
+// startProgress serves the progress of result with --progress-addr, and
+// returns the function that stops serving it once the conversion completes
+func startProgress(config *Config, result *converter.ConversionResult) (stop func(), err error) {
+	if config.ProgressAddr == "" {
+		return func() {}, nil
+	}
+
+	server, err := serveProgress(config.ProgressAddr, result)
+	if err != nil {
+		return nil, err
+	}
+	if config.Verbose {
+		fmt.Fprintf(config.stdout(), "📡 Serving progress at %s\n", server.Location())
+	}
+	return server.Close, nil
+}
+
+// isGlobPattern reports whether path is a glob pattern to expand, for shells
+// that pass patterns through unexpanded or when the pattern is quoted
+// A file whose name merely contains glob characters is not a pattern
+func isGlobPattern(path string) bool {
+	if !strings.ContainsAny(path, "*?[") {
+		return false
+	}
+	_, err := os.Stat(path)
+	return os.IsNotExist(err)
+}
+
+// expandGlob returns the files matching pattern, in lexical order
+// Directories are left out, as are files without one of exts unless exts
+// is empty
+func expandGlob(pattern string, exts []string) ([]string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob pattern %s: %w", pattern, err)
+	}
+
+	var files []string
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if len(exts) > 0 && !hasExtension(match, exts) {
+			continue
+		}
+		files = append(files, match)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no %s files match %s", inputKind(exts), pattern)
+	}
+	return files, nil
+}
+
+// runGlobConversion converts the files matching the glob pattern given as
+// input, like a directory
+func runGlobConversion(ctx context.Context, config *Config) error {
+	files, err := expandGlob(config.InputPath, config.inputExtensions())
+	if err != nil {
+		return err
+	}
+
+	if config.Verbose {
+		fmt.Fprintf(config.stdout(), "🔎 Pattern %s matched %d file(s)\n", config.InputPath, len(files))
+	}
+
+	opts := config.converterOptions()
+	opts.Prompt = config.overwritePrompt()
+	if config.OnCollision == CollisionWarn || config.OnCollision == CollisionError {
+		if err := checkCollisions(config, converter.FindFileConflicts(files, config.OutputDir, opts)); err != nil {
+			return err
+		}
+	}
+
+	result, err := converter.ConvertFiles(ctx, files, config.OutputDir, opts)
+	return reportConversion(config, result, err, "pattern")
+}
+
+// runTarConversion converts the AVIF entries of a tar archive, read from
+// stdin when the input is "-"
+func runTarConversion(ctx context.Context, config *Config) error {
+	in := os.Stdin
+	if config.InputPath != "-" {
+		file, err := os.Open(config.InputPath)
+		if err != nil {
+			return fmt.Errorf("failed to open tar archive: %w", err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	if config.Verbose {
+		fmt.Fprintf(config.stdout(), "📦 Processing archive: %s\n", config.InputPath)
+	}
+
+	opts := config.converterOptions()
+	opts.Prompt = config.overwritePrompt()
+	result, err := converter.ConvertTar(ctx, in, config.OutputDir, opts)
+	return reportConversion(config, result, err, "archive")
+}
+
+// runListConversion converts the files listed on stdin with --stdin-list,
+// each as soon as its line is read
+func runListConversion(ctx context.Context, config *Config) error {
+	if config.Verbose {
+		fmt.Fprintln(config.stdout(), "📜 Reading the files to convert from stdin")
+	}
+
+	result := &converter.ConversionResult{}
+	stopProgress, err := startProgress(config, result)
+	if err != nil {
+		return err
+	}
+	err = converter.ConvertList(ctx, os.Stdin, config.OutputDir, config.converterOptions(), result)
+	stopProgress()
+	return reportConversion(config, result, err, "list")
+}
+
+// reportConversion prints the summary, errors and requested reports of a
+// bulk conversion of a directory or archive (the kind of input) and returns
+// the error to exit with
+func reportConversion(config *Config, result *converter.ConversionResult, err error, kind string) error {
+	if config.DryRun {
+		return reportPlan(config, result, err, kind)
+	}
+
+	// The result is always returned, so report what was done before any failure
+	if config.JSON {
+		if jsonErr := report.WriteJSON(config.stdout(), result, config.JSONIndent); jsonErr != nil && err == nil {
+			err = fmt.Errorf("failed to write JSON output: %w", jsonErr)
+		}
+	} else {
+		printSummary(config, result)
+		printResources(config.stdout(), result)
+		printChangedOutputs(config.stdout(), result)
+		printBlankOutputs(config.stdout(), result)
+		printDuplicates(config.stdout(), result)
+		printQuarantined(config.stdout(), result)
+	}
+
+	// Outputs converted before a failure are listed too
+	if config.ChecksumManifest {
+		path, manifestErr := writeChecksumManifest(config, result)
+		if manifestErr != nil && err == nil {
+			err = manifestErr
+		}
+		if path != "" && config.Verbose && !config.JSON {
+			fmt.Fprintf(config.stdout(), "🔏 Checksums written to %s\n", path)
+		}
+	}
+
+	// The report covers runs that stopped early, like the summary
+	if config.ReportPath != "" {
+		reportErr := report.WriteHTMLFile(config.ReportPath, result, config.ReportPreviews)
+		if reportErr != nil && err == nil {
+			err = reportErr
+		}
+		if reportErr == nil && config.Verbose {
+			fmt.Fprintf(config.stdout(), "📄 Report written to %s\n", config.ReportPath)
+		}
+	}
+
+	if errors.Is(err, context.Canceled) {
+		if !config.JSON {
+			done := result.Successful + result.Skipped() + result.Failed
+			fmt.Fprintf(config.stderr(), "⚠️  Interrupted after %d of %d file(s)\n", done, result.TotalFiles)
+		}
+		printFileErrors(config.stderr(), result)
+		return ErrInterrupted
+	}
+	if err != nil {
+		printFileErrors(config.stderr(), result)
+		return err
+	}
+
+	// Print error details
+	if len(result.Errors) > 0 {
+		printFileErrors(config.stderr(), result)
+		return fmt.Errorf("completed with %d error(s)", len(result.Errors))
+	}
+
+	// Stale outputs fail strict pipelines; other skips, such as identical
+	// outputs in --compare mode, are still successes
+	if exists := result.SkippedReasons[converter.SkipReasonExists]; config.SkipIsError && exists > 0 {
+		return fmt.Errorf("%d file(s) skipped because their output %s", exists, converter.SkipReasonExists)
+	}
+
+	// If no files were found
+	if result.TotalFiles == 0 && !config.JSON {
+		fmt.Fprintln(config.stdout(), noFilesMessage(result, kind))
+	}
+
+	return nil
+}
+
+// runConflictReport lists the output names that more than one input would be
+// written to, without converting anything
+// It fails when there are conflicts, so scripts can check a tree before a run
+func runConflictReport(config *Config) error {
+	conflicts, err := converter.FindOutputConflicts(config.InputPath, config.OutputDir, config.converterOptions())
+	if err != nil {
+		return err
+	}
+
+	if len(conflicts) == 0 {
+		fmt.Fprintln(config.stdout(), "✅ No output name conflicts")
+		return nil
+	}
+
+	printConflicts(config.stdout(), conflicts)
+	return fmt.Errorf("%d output name conflict(s); only the first input of each would be converted", len(conflicts))
+}
+
+// checkCollisions handles the output paths claimed by more than one input of
+// a batch, found before converting, as selected by --on-collision
+// The list goes to stderr, so it never mixes with JSON output
+func checkCollisions(config *Config, conflicts []converter.OutputConflict) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	printConflicts(config.stderr(), conflicts)
+	if config.OnCollision == CollisionError {
+		return fmt.Errorf("%d output path collision(s); nothing was converted (use --flatten-separator or --output-template to tell the outputs apart)", len(conflicts))
+	}
+	return nil
+}
+
+// printConflicts lists each output path with the inputs that claim it
+func printConflicts(w io.Writer, conflicts []converter.OutputConflict) {
+	fmt.Fprintf(w, "⚠️  %d output name(s) claimed by more than one input:\n", len(conflicts))
+	for _, conflict := range conflicts {
+		fmt.Fprintf(w, "  %s\n", conflict.OutputPath)
+		for _, inputPath := range conflict.InputPaths {
+			fmt.Fprintf(w, "    - %s\n", inputPath)
+		}
+	}
+}
+
+// syntheticBenchmarkSize is the width and height of the image benchmarked
+// when no input is given
+const syntheticBenchmarkSize = 512
+
+// runBenchmark converts the input, or a synthetic image, repeatedly in memory
+// and reports the throughput, latency and peak memory
+func runBenchmark(ctx context.Context, config *Config) error {
+	var data []byte
+	var err error
+	sample := config.InputPath
+	if sample == "" {
+		sample = fmt.Sprintf("synthetic %dx%d image", syntheticBenchmarkSize, syntheticBenchmarkSize)
+		data, err = converter.SyntheticAVIF(syntheticBenchmarkSize, syntheticBenchmarkSize)
+	} else {
+		data, err = os.ReadFile(config.InputPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load benchmark sample: %w", err)
+	}
+
+	if !config.JSON {
+		fmt.Fprintf(config.stdout(), "⏱️  Benchmarking %s for %s with %d worker(s)...\n", sample, config.Benchmark, config.BenchmarkWorkers)
+	}
+
+	bench := converter.BenchmarkOptions{Duration: config.Benchmark, Workers: config.BenchmarkWorkers}
+	result, err := converter.Benchmark(ctx, data, config.converterOptions(), bench)
+	if result == nil {
+		return err
+	}
+
+	if config.JSON {
+		if jsonErr := report.WriteBenchmarkJSON(config.stdout(), result, config.JSONIndent); jsonErr != nil {
+			return fmt.Errorf("failed to write JSON output: %w", jsonErr)
+		}
+	} else {
+		fmt.Fprintf(config.stdout(), "📊 %d conversion(s) in %s: %.1f/s\n", result.Conversions, result.Elapsed.Round(time.Millisecond), result.PerSecond)
+		fmt.Fprintf(config.stdout(), "   Latency: p50 %s, p99 %s\n", result.P50.Round(time.Microsecond), result.P99.Round(time.Microsecond))
+		fmt.Fprintf(config.stdout(), "   Peak heap: %.1f MB\n", float64(result.PeakHeap)/(1<<20))
+		fmt.Fprintf(config.stdout(), "   Sample: %dx%d, %d bytes in, %d bytes out\n", result.Width, result.Height, result.InputSize, result.OutputSize)
+		if result.Failed > 0 {
+			fmt.Fprintf(config.stdout(), "   ⚠️  %d conversion(s) failed\n", result.Failed)
+		}
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return ErrInterrupted
+	}
+	return err
+}
+
+// Run executes the main application logic
+// http(s) inputs are downloaded to a temporary file before conversion
+func Run(config *Config) error {
+	return RunContext(context.Background(), config)
+}
+
+// RunContext is like Run but stops a directory conversion once ctx is
+// cancelled, printing the partial summary and returning ErrInterrupted
+func RunContext(ctx context.Context, config *Config) error {
+	if config.ShowVersion {
+		return printVersion(config.stdout(), config)
+	}
+	if config.Verbose && config.Preset != "" {
+		fmt.Fprintf(config.stdout(), "🧩 Preset: %s\n", config.Preset)
+	}
+	if config.Map != "" {
+		return runMappedConversion(ctx, config)
+	}
+	if config.Spec != "" {
+		return runSpecConversion(ctx, config)
+	}
+	inputPath := config.InputPath
+	if config.Benchmark > 0 && inputPath == "" {
+		return runBenchmark(ctx, config)
+	}
+	if isURL(inputPath) {
+		if config.InputMode == InputModeDir {
+			return fmt.Errorf("--mode dir requires a directory input, got URL: %s", inputPath)
+		}
+		if config.Verbose {
+			fmt.Fprintf(config.stdout(), "🌐 Downloading: %s\n", inputPath)
+		}
+		timeout := config.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		localPath, cleanup, err := downloadInput(inputPath, timeout)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		inputPath = localPath
+	}
+
+	var err error
+	if inputPath != "-" {
+		if inputPath, err = normalizePath(inputPath); err != nil {
+			return err
+		}
+	}
+	outputDir, err := normalizePath(config.OutputDir)
+	if err != nil {
+		return err
+	}
+
+	normalized := *config
+	normalized.InputPath = inputPath
+	normalized.OutputDir = outputDir
+	normalized.TeeDirs = make([]string, len(config.TeeDirs))
+	for i, dir := range config.TeeDirs {
+		if normalized.TeeDirs[i], err = normalizePath(dir); err != nil {
+			return err
+		}
+	}
+	if config.PDFPath != "" {
+		if normalized.PDFPath, err = normalizePath(config.PDFPath); err != nil {
+			return err
+		}
+	}
+	if config.SpriteSheet != "" {
+		if normalized.SpriteSheet, err = normalizePath(config.SpriteSheet); err != nil {
+			return err
+		}
+	}
+	config = &normalized
+
+	if config.Benchmark > 0 {
+		return runBenchmark(ctx, config)
+	}
+
+	// The output directory is not written in PDF, sprite sheet and conflict
+	// report modes
+	if config.PDFPath == "" && config.SpriteSheet == "" && !config.ConflictReport {
+		for _, dir := range append([]string{config.OutputDir}, config.TeeDirs...) {
+			if err := converter.CheckOutputDir(dir); err != nil {
+				return fmt.Errorf("%w (use --output to choose a directory)", err)
+			}
+		}
+	}
+
+	if config.StdinList {
+		return runListConversion(ctx, config)
+	}
+
+	if config.Tar {
+		if config.ConflictReport {
+			return errors.New("--flatten-conflict-report cannot be combined with --tar")
+		}
+		if config.PDFPath != "" {
+			return errors.New("--pdf cannot be combined with --tar")
+		}
+		if config.SpriteSheet != "" {
+			return errors.New("--spritesheet cannot be combined with --tar")
+		}
+		if config.Number {
+			return errors.New("--number cannot be combined with --tar")
+		}
+		if config.ProgressAddr != "" {
+			return errors.New("--progress-addr requires a directory input")
+		}
+		return runTarConversion(ctx, config)
+	}
+
+	// Explicit modes read patterns literally, so a file named "shot[1].avif"
+	// that does not exist is reported as missing
+	if config.InputMode != InputModeFile && config.InputMode != InputModeDir && isGlobPattern(config.InputPath) {
+		if config.ConflictReport {
+			return errors.New("--flatten-conflict-report requires a directory input")
+		}
+		if config.PDFPath != "" {
+			return errors.New("--pdf requires a directory input")
+		}
+		if config.SpriteSheet != "" {
+			return errors.New("--spritesheet requires a directory input")
+		}
+		if config.Number {
+			return errors.New("--number requires a directory input")
+		}
+		if config.ProgressAddr != "" {
+			return errors.New("--progress-addr requires a directory input")
+		}
+		return runGlobConversion(ctx, config)
+	}
+
+	isDir, err := validateInputPath(config.InputPath, config.inputExtensions())
+	if err != nil {
+		return err
+	}
+	if err := checkInputMode(config.InputMode, config.InputPath, isDir); err != nil {
+		return err
+	}
+
+	if isDir && config.ConflictReport {
+		return runConflictReport(config)
+	}
+	if isDir {
+		return runDirectoryConversion(ctx, config)
+	}
+	if config.ConflictReport {
+		return errors.New("--flatten-conflict-report requires a directory input")
+	}
+	if config.Compare {
+		return errors.New("--compare requires a directory input")
+	}
+	if config.JSON && !config.DryRun {
+		return errors.New("--json requires a directory input, or --dry-run")
+	}
+	if config.Number {
+		return errors.New("--number requires a directory input")
+	}
+	if config.PDFPath != "" {
+		return errors.New("--pdf requires a directory input")
+	}
+	if config.SpriteSheet != "" {
+		return errors.New("--spritesheet requires a directory input")
+	}
+	if config.ThreadsReport {
+		return errors.New("--threads-report requires a directory, glob or tar input")
+	}
+	if config.ChecksumManifest {
+		return errors.New("--checksums-manifest requires a directory, glob or tar input")
+	}
+	if config.ReportPath != "" {
+		return errors.New("--report requires a directory, glob or tar input")
+	}
+	if config.ProgressAddr != "" {
+		return errors.New("--progress-addr requires a directory input")
 	}
-	return runSingleFileConversion(config)
+	return runSingleFileConversion(ctx, config)
 }
@@ -1,11 +1,19 @@
 package cli
 
 import (
+	"archive/tar"
+	"avif2png/internal/converter"
+	"context"
+	"errors"
 	"image"
 	"image/color"
+	"image/png"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gen2brain/avif"
 )
@@ -156,275 +164,2554 @@ func TestParseFlags_WithAllFlags(t *testing.T) {
 	}
 }
 
-func TestParseFlags_NoArguments(t *testing.T) {
-	args := []string{}
+func TestParseFlags_DefaultFormat(t *testing.T) {
+	config, err := ParseFlags([]string{"image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Format != "png" {
+		t.Errorf("expected Format 'png', got: %s", config.Format)
+	}
+	if config.Quality != converter.DefaultQuality {
+		t.Errorf("expected Quality %d, got: %d", converter.DefaultQuality, config.Quality)
+	}
+}
 
-	_, err := ParseFlags(args)
+func TestParseFlags_WithFormatFlag(t *testing.T) {
+	config, err := ParseFlags([]string{"-f", "jpeg", "--quality", "75", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Format != "jpeg" {
+		t.Errorf("expected Format 'jpeg', got: %s", config.Format)
+	}
+	if config.Quality != 75 {
+		t.Errorf("expected Quality 75, got: %d", config.Quality)
+	}
+}
+
+func TestParseFlags_UnknownFormat(t *testing.T) {
+	_, err := ParseFlags([]string{"--format", "bmp", "image.avif"})
 
 	if err == nil {
-		t.Fatal("expected error for no arguments, got nil")
+		t.Fatal("expected error for unknown format, got nil")
+	}
+}
+
+func TestParseFlags_InvalidQuality(t *testing.T) {
+	_, err := ParseFlags([]string{"--quality", "0", "image.avif"})
+
+	if err == nil {
+		t.Fatal("expected error for invalid quality, got nil")
+	}
+}
+
+func TestParseFlags_WithIncludeHidden(t *testing.T) {
+	config, err := ParseFlags([]string{"--include-hidden", "my-images/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.IncludeHidden {
+		t.Error("expected IncludeHidden to be true")
+	}
+}
+
+func TestParseFlags_WithFlattenSeparator(t *testing.T) {
+	config, err := ParseFlags([]string{"-r", "--flatten-separator", "_", "my-images/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.FlattenSeparator != "_" {
+		t.Errorf("expected FlattenSeparator '_', got: %s", config.FlattenSeparator)
+	}
+
+	for _, separator := range []string{"", " ", "/../../", "\\"} {
+		if _, err := ParseFlags([]string{"-r", "--flatten-separator", separator, "my-images/"}); err == nil {
+			t.Errorf("expected error for --flatten-separator %q", separator)
+		}
+	}
+}
+
+func TestParseFlags_WithOutputTemplate(t *testing.T) {
+	config, err := ParseFlags([]string{"--output-template", "{yyyy}/{mm}", "--template-time", "now", "my-images/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.OutputTemplate != "{yyyy}/{mm}" || !config.OutputTemplateNow {
+		t.Errorf("expected template with current time, got: %q (now=%v)", config.OutputTemplate, config.OutputTemplateNow)
+	}
+
+	if _, err := ParseFlags([]string{"--output-template", "{hh}", "my-images/"}); err == nil {
+		t.Error("expected error for unknown template token, got nil")
+	}
+	if _, err := ParseFlags([]string{"--output-template", "../../esc", "my-images/"}); err == nil {
+		t.Error("expected error for a template outside the output directory, got nil")
+	}
+	if _, err := ParseFlags([]string{"--template-time", "yesterday", "my-images/"}); err == nil {
+		t.Error("expected error for invalid --template-time, got nil")
+	}
+}
+
+func TestParseFlags_WithExtractAux(t *testing.T) {
+	config, err := ParseFlags([]string{"--extract-aux", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.ExtractAux {
+		t.Error("expected ExtractAux to be true")
+	}
+	if !config.converterOptions().ExtractAux {
+		t.Error("expected ExtractAux to be passed to the converter")
+	}
+}
+
+func TestParseFlags_WithAllItems(t *testing.T) {
+	config, err := ParseFlags([]string{"--all-items", "burst.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.AllItems {
+		t.Error("expected AllItems to be true")
+	}
+	if !config.converterOptions().AllItems {
+		t.Error("expected AllItems to be passed to the converter")
+	}
+}
+
+func TestParseFlags_WithPrimaryOnly(t *testing.T) {
+	config, err := ParseFlags([]string{"--primary-only", "--gif", "animation.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.converterOptions().PrimaryOnly {
+		t.Error("expected PrimaryOnly to be passed to the converter")
+	}
+
+	for _, flag := range []string{"--extract-aux", "--all-items", "--use-thumbnail"} {
+		if _, err := ParseFlags([]string{"--primary-only", flag, "image.avif"}); err == nil {
+			t.Errorf("expected --primary-only to be rejected with %s", flag)
+		}
+	}
+}
+
+func TestParseFlags_WithInteractive(t *testing.T) {
+	config, err := ParseFlags([]string{"-i", "my-images/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.Interactive {
+		t.Error("expected Interactive to be true")
+	}
+}
+
+func TestParseFlags_WithPreserveMtime(t *testing.T) {
+	config, err := ParseFlags([]string{"--preserve-mtime", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.PreserveMtime || !config.converterOptions().PreserveMtime {
+		t.Error("expected PreserveMtime to be set")
+	}
+}
+
+func TestParseFlags_WithPDF(t *testing.T) {
+	config, err := ParseFlags([]string{"--pdf", "album.pdf", "my-images/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.PDFPath != "album.pdf" {
+		t.Errorf("expected PDFPath 'album.pdf', got: %s", config.PDFPath)
+	}
+}
+
+func TestParseFlags_WithVerify(t *testing.T) {
+	config, err := ParseFlags([]string{"--verify", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.Verify || !config.converterOptions().Verify {
+		t.Error("expected Verify to be set")
+	}
+}
+
+func TestParseFlags_WithVerifyExisting(t *testing.T) {
+	config, err := ParseFlags([]string{"--verify-existing", "my-images/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.VerifyExisting || !config.converterOptions().VerifyExisting {
+		t.Error("expected VerifyExisting to be set")
+	}
+}
+
+func TestParseFlags_WithThreadsReport(t *testing.T) {
+	config, err := ParseFlags([]string{"--threads-report", "my-images/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.ThreadsReport || !config.converterOptions().TrackResources {
+		t.Error("expected ThreadsReport to enable TrackResources")
+	}
+
+	if _, err := ParseFlags([]string{"--threads-report", "--pdf", "album.pdf", "my-images/"}); err == nil {
+		t.Error("expected error for --threads-report with --pdf, got nil")
+	}
+}
+
+func TestParseFlags_WithStripMetadata(t *testing.T) {
+	config, err := ParseFlags([]string{"--strip-metadata", "-f", "jpeg", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.StripMetadata || !config.converterOptions().StripMetadata {
+		t.Error("expected StripMetadata to be set")
+	}
+}
+
+func TestParseFlags_WithTimeout(t *testing.T) {
+	config, err := ParseFlags([]string{"--timeout", "5s", "https://example.com/image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Timeout != 5*time.Second {
+		t.Errorf("expected Timeout 5s, got: %s", config.Timeout)
+	}
+
+	if _, err := ParseFlags([]string{"--timeout", "0s", "image.avif"}); err == nil {
+		t.Error("expected error for a zero timeout, got nil")
+	}
+}
+
+func TestParseFlags_WithExec(t *testing.T) {
+	config, err := ParseFlags([]string{"--exec", "upload --name 'my file' {output}", "my-images/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	want := []string{"upload", "--name", "my file", "{output}"}
+	if !reflect.DeepEqual(config.Exec, want) || !reflect.DeepEqual(config.converterOptions().Exec, want) {
+		t.Errorf("expected Exec %q, got: %q", want, config.Exec)
+	}
+
+	if _, err := ParseFlags([]string{"--exec", "upload 'unterminated", "my-images/"}); err == nil {
+		t.Error("expected error for an unterminated quote, got nil")
+	}
+}
+
+func TestParseFlags_WithAnyExt(t *testing.T) {
+	config, err := ParseFlags([]string{"--any-ext", "image.avifs"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.AnyExt {
+		t.Error("expected AnyExt to be set")
+	}
+}
+
+func TestParseFlags_WithUseThumbnail(t *testing.T) {
+	config, err := ParseFlags([]string{"--use-thumbnail", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	opts := config.converterOptions()
+	if !opts.UseThumbnail || opts.ThumbnailRequired {
+		t.Errorf("expected thumbnails with fallback to the full image, got: %+v", opts)
+	}
+
+	config, err = ParseFlags([]string{"--use-thumbnail", "--thumbnail-fallback", "error", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.converterOptions().ThumbnailRequired {
+		t.Error("expected ThumbnailRequired to be set")
+	}
+}
+
+func TestParseFlags_InvalidThumbnailFallback(t *testing.T) {
+	if _, err := ParseFlags([]string{"--thumbnail-fallback", "skip", "image.avif"}); err == nil {
+		t.Fatal("expected error for invalid --thumbnail-fallback")
+	}
+}
+
+func TestParseFlags_WithMaxDepth(t *testing.T) {
+	config, err := ParseFlags([]string{"--max-depth", "2", "images"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	opts := config.converterOptions()
+	if opts.MaxDepth != 2 || !opts.Recursive {
+		t.Errorf("expected a recursive scan limited to depth 2, got: %+v", opts)
+	}
+}
+
+func TestParseFlags_NegativeMaxDepth(t *testing.T) {
+	if _, err := ParseFlags([]string{"--max-depth", "-1", "images"}); err == nil {
+		t.Fatal("expected error for negative --max-depth")
+	}
+}
+
+func TestParseFlags_WithScanWorkers(t *testing.T) {
+	config, err := ParseFlags([]string{"-r", "--scan-workers", "16", "images"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.converterOptions().ScanWorkers != 16 {
+		t.Errorf("expected 16 scan workers, got: %d", config.ScanWorkers)
+	}
+
+	for _, args := range [][]string{
+		{"-r", "--scan-workers", "0", "images"},
+		{"--scan-workers", "4", "images"},
+	} {
+		if _, err := ParseFlags(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseFlags_WithJobs(t *testing.T) {
+	config, err := ParseFlags([]string{"--jobs", "4", "images"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.converterOptions().Workers != 4 {
+		t.Errorf("expected 4 workers, got: %d", config.Workers)
+	}
+
+	config, err = ParseFlags([]string{"--jobs", "auto", "images"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Workers != converter.WorkersAuto {
+		t.Errorf("expected auto workers, got: %d", config.Workers)
+	}
+
+	// One file at a time is the default, and works everywhere
+	if _, err := ParseFlags([]string{"--jobs", "1", "--tar", "images.tar"}); err != nil {
+		t.Errorf("expected --jobs 1 to be accepted with --tar, got: %v", err)
+	}
+
+	for _, args := range [][]string{
+		{"--jobs", "0", "images"},
+		{"--jobs", "many", "images"},
+		{"--jobs", "4", "--tar", "images.tar"},
+		{"--jobs", "auto", "--pdf", "album.pdf", "images"},
+		{"--jobs", "2", "--spritesheet", "sprites.png", "images"},
+		{"--jobs", "2", "--spec", "jobs.json"},
+		{"--jobs", "4", "-i", "images"},
+		{"--jobs", "auto", "--dedupe", "images"},
+		{"--jobs", "2", "--flatten-hash", "images"},
+	} {
+		if _, err := ParseFlags(args); err == nil || !strings.Contains(err.Error(), "--jobs") {
+			t.Errorf("%v: expected a --jobs error, got: %v", args, err)
+		}
+	}
+}
+
+func TestParseFlags_WithSanitizeNames(t *testing.T) {
+	config, err := ParseFlags([]string{"--sanitize-names", "downloads/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.converterOptions().SanitizeNames {
+		t.Error("expected SanitizeNames to be passed to the converter")
+	}
+}
+
+func TestParseFlags_WithReplaceSpaces(t *testing.T) {
+	config, err := ParseFlags([]string{"--replace-spaces", "-", "--sanitize-names", "downloads/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if opts := config.converterOptions(); opts.ReplaceSpaces != "-" || !opts.SanitizeNames {
+		t.Errorf("expected spaces to be replaced with - after sanitizing, got %q", opts.ReplaceSpaces)
+	}
+
+	for _, replacement := range []string{"", "/", " "} {
+		if _, err := ParseFlags([]string{"--replace-spaces", replacement, "downloads/"}); err == nil {
+			t.Errorf("expected error for --replace-spaces %q", replacement)
+		}
+	}
+}
+
+func TestParseFlags_WithFileMode(t *testing.T) {
+	for value, want := range map[string]os.FileMode{"0600": 0600, "640": 0640, "0o755": 0755} {
+		config, err := ParseFlags([]string{"--file-mode", value, "scans/"})
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if got := config.converterOptions().FileMode; got != want {
+			t.Errorf("%s: expected mode %o, got %o", value, want, got)
+		}
+	}
+
+	for _, value := range []string{"0", "0800", "1000", "rw-------", "-600"} {
+		if _, err := ParseFlags([]string{"--file-mode", value, "scans/"}); err == nil {
+			t.Errorf("expected error for --file-mode %s", value)
+		}
+	}
+}
+
+func TestParseFlags_WithDedupe(t *testing.T) {
+	config, err := ParseFlags([]string{"--dedupe", "scraped/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.converterOptions().Dedupe {
+		t.Error("expected Dedupe to be passed to the converter")
+	}
+
+	if _, err := ParseFlags([]string{"--dedupe", "--pdf", "out.pdf", "scraped/"}); err == nil {
+		t.Error("expected error for --dedupe with --pdf")
+	}
+}
+
+func TestParseFlags_WithOnError(t *testing.T) {
+	config, err := ParseFlags([]string{"uploads/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.OnError != converter.OnErrorSkip {
+		t.Errorf("expected --on-error to default to skip, got %q", config.OnError)
+	}
+
+	config, err = ParseFlags([]string{"--on-error", "quarantine", "--quarantine-dir", "bad", "uploads/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	opts := config.converterOptions()
+	if opts.OnError != converter.OnErrorQuarantine || opts.QuarantineDir != "bad" {
+		t.Errorf("expected the quarantine policy to be passed to the converter, got %q, %q", opts.OnError, opts.QuarantineDir)
+	}
+
+	for _, args := range [][]string{
+		{"--on-error", "abort", "uploads/"},
+		{"--on-error", "quarantine", "uploads/"},
+		{"--quarantine-dir", "bad", "uploads/"},
+		{"--on-error", "stop", "--quarantine-dir", "bad", "uploads/"},
+		{"--on-error", "stop", "--pdf", "out.pdf", "uploads/"},
+	} {
+		if _, err := ParseFlags(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseFlags_WithMap(t *testing.T) {
+	config, err := ParseFlags([]string{"--map", "in/*.avif=out/{1}.png"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Map != "in/*.avif=out/{1}.png" || config.InputPath != "" {
+		t.Errorf("expected the mapping without an input, got %q, %q", config.Map, config.InputPath)
+	}
+
+	for _, args := range [][]string{
+		{"--map", "in/*.avif=out/{1}.png", "in/"},
+		{"--map", "in/*.avif=out/{2}.png"},
+		{"--map", "in/*.avif=out/{1}.png", "-o", "out"},
+		{"--map", "in/*.avif=out/{1}.png", "--output-template", "{yyyy}"},
+		{"--map", "in/*.avif=out/{1}.png", "--tar"},
+	} {
+		if _, err := ParseFlags(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+
+	// Invalid classes are syntax errors, not panics
+	if _, err := ParseFlags([]string{"--map", "x/[z-a].avif=out/{1}.png"}); err == nil || !strings.Contains(err.Error(), "invalid --map input") {
+		t.Errorf("expected a --map syntax error, got: %v", err)
+	}
+}
+
+func TestParseFlags_WithFlattenHash(t *testing.T) {
+	config, err := ParseFlags([]string{"-r", "--flatten-hash", "camera-roll/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.converterOptions().FlattenHash {
+		t.Error("expected FlattenHash to be passed to the converter")
+	}
+
+	if _, err := ParseFlags([]string{"--flatten-hash", "--on-collision", "error", "camera-roll/"}); err == nil {
+		t.Error("expected error for --flatten-hash with --on-collision error")
+	}
+}
+
+func TestParseFlags_WithLimit(t *testing.T) {
+	config, err := ParseFlags([]string{"--limit", "20", "huge-archive/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.converterOptions().Limit != 20 {
+		t.Errorf("expected a limit of 20, got: %d", config.converterOptions().Limit)
+	}
+
+	for _, args := range [][]string{
+		{"--limit", "-1", "in/"},
+		{"--limit", "5", "--tar", "photos.tar"},
+		{"--limit", "5", "--pdf", "album.pdf", "in/"},
+	} {
+		if _, err := ParseFlags(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseFlags_WithShard(t *testing.T) {
+	config, err := ParseFlags([]string{"--shard", "hash", "--shard-depth", "2", "huge-archive/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	opts := config.converterOptions()
+	if opts.Shard != "hash" || opts.ShardWidth != 2 || opts.ShardDepth != 2 {
+		t.Errorf("expected hash shards 2 wide and 2 deep, got %q, %d and %d", opts.Shard, opts.ShardWidth, opts.ShardDepth)
+	}
+
+	for _, args := range [][]string{
+		{"--shard", "date", "in/"},
+		{"--shard", "name", "--shard-width", "0", "in/"},
+		{"--shard-depth", "2", "in/"},
+		{"--shard", "name", "--pdf", "album.pdf", "in/"},
+	} {
+		if _, err := ParseFlags(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseFlags_WithSkipNonImages(t *testing.T) {
+	config, err := ParseFlags([]string{"--skip-non-images", "downloads/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.converterOptions().SkipNonImages {
+		t.Error("expected SkipNonImages to be passed to the converter")
+	}
+}
+
+func TestParseFlags_WithChecksums(t *testing.T) {
+	config, err := ParseFlags([]string{"--checksums", "sha256", "--checksums-manifest", "my-images/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	opts := config.converterOptions()
+	if opts.Checksum != "sha256" || !opts.ChecksumManifest {
+		t.Errorf("expected the checksum settings to be passed to the converter, got %q, %v", opts.Checksum, opts.ChecksumManifest)
+	}
+
+	for _, args := range [][]string{
+		{"--checksums", "md5", "my-images/"},
+		{"--checksums-manifest", "my-images/"},
+		{"--checksums", "sha256", "--pdf", "out.pdf", "my-images/"},
+	} {
+		if _, err := ParseFlags(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseFlags_WithExtensions(t *testing.T) {
+	config, err := ParseFlags([]string{"--extensions", "avif, .AVIFS", "camera-roll/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := []string{".avif", ".avifs"}
+	if !reflect.DeepEqual(config.inputExtensions(), want) || !reflect.DeepEqual(config.converterOptions().Extensions, want) {
+		t.Errorf("expected %v, got %v", want, config.inputExtensions())
+	}
+
+	config, err = ParseFlags([]string{"camera-roll/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(config.inputExtensions(), []string{".avif"}) {
+		t.Errorf("expected .avif by default, got %v", config.inputExtensions())
+	}
+
+	for _, list := range []string{"", ".avif,", "..", "a/b", ".tar.gz"} {
+		if _, err := parseExtensions(list); err == nil {
+			t.Errorf("expected error for --extensions %q", list)
+		}
+	}
+}
+
+func TestRun_Extensions(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "clip.AVIFS")
+	createTestAVIF(t, inputPath)
+	outputDir := filepath.Join(testDir, "output")
+
+	config := &Config{InputPath: inputPath, OutputDir: outputDir}
+	if err := Run(config); err == nil {
+		t.Error("expected an error for an .avifs input by default")
+	}
+
+	config.Extensions = []string{".avif", ".avifs"}
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "clip.png")); err != nil {
+		t.Errorf("expected clip.png to exist, got: %v", err)
+	}
+}
+
+func TestParseFlags_WithNumber(t *testing.T) {
+	config, err := ParseFlags([]string{"--number", "shots/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	opts := config.converterOptions()
+	if !opts.Number || opts.NumberPadding != converter.DefaultNumberPadding {
+		t.Errorf("expected numbering with the default padding, got %v and %d", opts.Number, opts.NumberPadding)
+	}
+
+	for _, args := range [][]string{
+		{"--number", "--number-padding", "-1", "shots/"},
+		{"--number", "--number-padding", "10", "shots/"},
+		{"--number-padding", "3", "shots/"},
+		{"--number", "--flatten-separator", "_", "shots/"},
+		{"--number", "--output-template", "{yyyy}", "shots/"},
+	} {
+		if _, err := ParseFlags(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseFlags_WithFlattenConflictReport(t *testing.T) {
+	config, err := ParseFlags([]string{"-r", "--flatten-conflict-report", "images"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.ConflictReport {
+		t.Error("expected ConflictReport to be set")
+	}
+}
+
+// envLookup returns an environment lookup backed by env
+func envLookup(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+func TestParseFlags_EnvDefaults(t *testing.T) {
+	env := envLookup(map[string]string{
+		"AVIF2PNG_OUTPUT":    "/env/output",
+		"AVIF2PNG_FORMAT":    "jpeg",
+		"AVIF2PNG_QUALITY":   "70",
+		"AVIF2PNG_RECURSIVE": "true",
+	})
+
+	config, err := parseFlags([]string{"images"}, env)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.OutputDir != "/env/output" || config.Format != "jpeg" || config.Quality != 70 || !config.Recursive {
+		t.Errorf("expected defaults from the environment, got: %+v", config)
+	}
+}
+
+func TestParseFlags_FlagsOverrideEnv(t *testing.T) {
+	env := envLookup(map[string]string{
+		"AVIF2PNG_OUTPUT":  "/env/output",
+		"AVIF2PNG_QUALITY": "70",
+	})
+
+	config, err := parseFlags([]string{"-o", "/flag/output", "--quality", "50", "images"}, env)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.OutputDir != "/flag/output" || config.Quality != 50 {
+		t.Errorf("expected flags to take precedence, got output %s, quality %d", config.OutputDir, config.Quality)
+	}
+}
+
+func TestParseFlags_MultipleOutputs(t *testing.T) {
+	config, err := ParseFlags([]string{"images"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.OutputDir != DefaultOutputDir || len(config.TeeDirs) != 0 {
+		t.Errorf("expected the default output alone, got %s and %v", config.OutputDir, config.TeeDirs)
+	}
+
+	env := envLookup(map[string]string{"AVIF2PNG_OUTPUT": "/env/output"})
+	config, err = parseFlags([]string{"-o", "/web", "--output", "/backup", "-o", "/cdn", "images"}, env)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.OutputDir != "/web" || strings.Join(config.TeeDirs, ",") != "/backup,/cdn" {
+		t.Errorf("expected /web with copies in /backup and /cdn, got %s and %v", config.OutputDir, config.TeeDirs)
+	}
+
+	if _, err := ParseFlags([]string{"-o", "a", "-o", "b", "--pdf", "album.pdf", "images"}); err == nil {
+		t.Error("expected error for more than one --output with --pdf")
+	}
+}
+
+func TestRun_MultipleOutputs(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	createTestAVIF(t, inputPath)
+
+	outputs := []string{filepath.Join(testDir, "web"), filepath.Join(testDir, "backup")}
+	config, err := ParseFlags([]string{"-o", outputs[0], "-o", outputs[1], inputPath})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	for _, dir := range outputs {
+		if _, err := os.Stat(filepath.Join(dir, "test.png")); err != nil {
+			t.Errorf("expected test.png in %s, got: %v", dir, err)
+		}
+	}
+}
+
+func TestParseFlags_EnvIsNotGiven(t *testing.T) {
+	env := envLookup(map[string]string{
+		"AVIF2PNG_FORMAT":  "jpeg",
+		"AVIF2PNG_QUALITY": "70",
+	})
+
+	// Defaults from the environment do not conflict with flags
+	config, err := parseFlags([]string{"--auto-format", "images"}, env)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.AutoFormat {
+		t.Errorf("expected --auto-format, got: %+v", config)
+	}
+	if _, err := parseFlags([]string{"--gif", "images"}, env); err != nil {
+		t.Errorf("expected --gif to replace the environment format, got: %v", err)
+	}
+
+	// and --profile replaces them
+	config, err = parseFlags([]string{"--profile", "archive", "images"}, env)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Format != "png" || config.Quality != 70 {
+		t.Errorf("expected the profile format with the environment quality, got %s at %d", config.Format, config.Quality)
+	}
+}
+
+func TestParseFlags_InvalidEnv(t *testing.T) {
+	env := envLookup(map[string]string{"AVIF2PNG_QUALITY": "high"})
+
+	_, err := parseFlags([]string{"images"}, env)
+	if err == nil || !strings.Contains(err.Error(), "AVIF2PNG_QUALITY") {
+		t.Fatalf("expected an error naming AVIF2PNG_QUALITY, got: %v", err)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if got := envName("json-indent"); got != "AVIF2PNG_JSON_INDENT" {
+		t.Errorf("expected AVIF2PNG_JSON_INDENT, got: %s", got)
+	}
+}
+
+func TestParseFlags_WithCompare(t *testing.T) {
+	config, err := ParseFlags([]string{"--compare", "images"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.Compare || !config.converterOptions().Compare {
+		t.Error("expected Compare to be set")
+	}
+}
+
+func TestParseFlags_WithAutoFormat(t *testing.T) {
+	config, err := ParseFlags([]string{"--auto-format", "--quality", "70", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.AutoFormat || !config.converterOptions().AutoFormat {
+		t.Error("expected AutoFormat to be set")
+	}
+}
+
+func TestParseFlags_AutoFormatWithFormat(t *testing.T) {
+	if _, err := ParseFlags([]string{"--auto-format", "-f", "png", "image.avif"}); err == nil {
+		t.Fatal("expected error when combining --auto-format with --format")
+	}
+}
+
+func TestParseFlags_WithOptimize(t *testing.T) {
+	config, err := ParseFlags([]string{"--optimize", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.Optimize || !config.converterOptions().Optimize {
+		t.Error("expected Optimize to be set")
+	}
+}
+
+func TestParseFlags_WithDebug(t *testing.T) {
+	config, err := ParseFlags([]string{"-vv", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.Debug || !config.Verbose {
+		t.Errorf("expected -vv to set Debug and Verbose, got: %+v", config)
+	}
+	if opts := config.converterOptions(); !opts.Debug || !opts.Verbose {
+		t.Error("expected Debug and Verbose in the converter options")
+	}
+
+	if _, err := ParseFlags([]string{"-vv", "--json", "my-images/"}); err == nil {
+		t.Error("expected error when combining -vv with --json")
+	}
+}
+
+func TestParseFlags_BenchmarkWithoutInput(t *testing.T) {
+	config, err := ParseFlags([]string{"--benchmark", "5s", "--benchmark-workers", "4"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Benchmark != 5*time.Second || config.BenchmarkWorkers != 4 || config.InputPath != "" {
+		t.Errorf("expected a synthetic benchmark with 4 workers, got: %+v", config)
+	}
+
+	if _, err := ParseFlags([]string{"--benchmark", "5s", "--benchmark-workers", "0"}); err == nil {
+		t.Error("expected error for --benchmark-workers 0")
+	}
+	if _, err := ParseFlags(nil); err == nil {
+		t.Error("expected an input to be required without --benchmark")
+	}
+}
+
+func TestParseFlags_WithSharpen(t *testing.T) {
+	config, err := ParseFlags([]string{"--max-output-size", "100KB", "--sharpen", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	opts := config.converterOptions()
+	if opts.SharpenAmount != DefaultSharpenAmount || opts.SharpenRadius != converter.DefaultSharpenRadius {
+		t.Errorf("expected default sharpening, got amount %v radius %v", opts.SharpenAmount, opts.SharpenRadius)
+	}
+
+	config, err = ParseFlags([]string{"--max-output-size", "100KB", "--sharpen", "--sharpen-amount", "1.2", "--sharpen-radius", "2", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.SharpenAmount != 1.2 || config.SharpenRadius != 2 {
+		t.Errorf("expected amount 1.2 radius 2, got amount %v radius %v", config.SharpenAmount, config.SharpenRadius)
+	}
+
+	config, err = ParseFlags([]string{"image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.SharpenAmount != 0 {
+		t.Errorf("expected no sharpening by default, got amount %v", config.SharpenAmount)
+	}
+
+	// --canvas downscales too
+	config, err = ParseFlags([]string{"--canvas", "200x200", "--sharpen", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.SharpenAmount != DefaultSharpenAmount {
+		t.Errorf("expected sharpening with --canvas, got amount %v", config.SharpenAmount)
+	}
+}
+
+func TestParseFlags_SharpenValidation(t *testing.T) {
+	tests := [][]string{
+		{"--sharpen", "image.avif"},
+		{"--max-output-size", "100KB", "--sharpen", "--sharpen-amount", "0", "image.avif"},
+		{"--max-output-size", "100KB", "--sharpen", "--sharpen-radius", "-1", "image.avif"},
+		{"--max-output-size", "100KB", "--sharpen-amount", "1", "image.avif"},
+	}
+	for _, args := range tests {
+		if _, err := ParseFlags(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseFlags_WithTrim(t *testing.T) {
+	config, err := ParseFlags([]string{"--trim", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	opts := config.converterOptions()
+	if !opts.Trim || opts.TrimTolerance != converter.DefaultTrimTolerance {
+		t.Errorf("expected trimming with the default tolerance, got %v and %d", opts.Trim, opts.TrimTolerance)
+	}
+
+	config, err = ParseFlags([]string{"--trim", "--trim-tolerance", "0", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.TrimTolerance != 0 {
+		t.Errorf("expected tolerance 0, got: %d", config.TrimTolerance)
+	}
+
+	for _, args := range [][]string{
+		{"--trim", "--trim-tolerance", "256", "image.avif"},
+		{"--trim", "--trim-tolerance", "-1", "image.avif"},
+		{"--trim-tolerance", "4", "image.avif"},
+	} {
+		if _, err := ParseFlags(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseFlags_WithCropPct(t *testing.T) {
+	config, err := ParseFlags([]string{"--crop-pct", "10, 20%,90,80.5", "layouts/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := converter.CropPercent{Left: 10, Top: 20, Right: 90, Bottom: 80.5}
+	if got := config.converterOptions().CropPct; got != want {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	for _, value := range []string{"10,10,90", "10,10,90,90,1", "a,b,c,d", "90,10,10,90", "0,0,101,100", "-5,0,50,50", "NaN,0,50,50"} {
+		if _, err := ParseFlags([]string{"--crop-pct", value, "layouts/"}); err == nil {
+			t.Errorf("expected error for --crop-pct %s", value)
+		}
+	}
+}
+
+func TestParseFlags_WithDimensions(t *testing.T) {
+	config, err := ParseFlags([]string{"--min-width", "1024", "--max-height", "4000", "photos/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := converter.DimensionBounds{MinWidth: 1024, MaxHeight: 4000}
+	if opts := config.converterOptions(); opts.Dimensions != want {
+		t.Errorf("expected bounds %+v, got %+v", want, opts.Dimensions)
+	}
+
+	for _, args := range [][]string{
+		{"--min-width", "-1", "photos/"},
+		{"--min-height", "500", "--max-height", "100", "photos/"},
+		{"--min-width", "100", "--pdf", "album.pdf", "photos/"},
+	} {
+		if _, err := ParseFlags(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseFlags_WithCanvas(t *testing.T) {
+	config, err := ParseFlags([]string{"--canvas", "1920x1080", "--pad-color", "#ffffff80", "photos/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	opts := config.converterOptions()
+	if opts.Canvas != image.Pt(1920, 1080) || opts.PadColor != (color.NRGBA{255, 255, 255, 0x80}) {
+		t.Errorf("expected a 1920x1080 canvas padded with translucent white, got %v and %v", opts.Canvas, opts.PadColor)
+	}
+
+	config, err = ParseFlags([]string{"--canvas", "800X600", "photos/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.PadColor != (color.NRGBA{A: 255}) {
+		t.Errorf("expected an opaque black pad by default, got: %v", config.PadColor)
+	}
+
+	for _, args := range [][]string{
+		{"--canvas", "1920", "photos/"},
+		{"--canvas", "0x100", "photos/"},
+		{"--canvas", "axb", "photos/"},
+		{"--canvas", "10x10", "--pad-color", "fff", "photos/"},
+		{"--pad-color", "ffffff", "photos/"},
+	} {
+		if _, err := ParseFlags(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseFlags_WithRotate(t *testing.T) {
+	config, err := ParseFlags([]string{"--rotate", "270", "sideways/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.converterOptions().Rotate != 270 {
+		t.Errorf("expected a rotation of 270, got: %d", config.Rotate)
+	}
+
+	for _, degrees := range []string{"45", "-90", "360"} {
+		if _, err := ParseFlags([]string{"--rotate", degrees, "sideways/"}); err == nil {
+			t.Errorf("expected error for --rotate %s", degrees)
+		}
+	}
+}
+
+func TestParseFlags_WithGIF(t *testing.T) {
+	config, err := ParseFlags([]string{"--gif", "--dither", "anim.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	opts := config.converterOptions()
+	if config.Format != "gif" || opts.Format != "gif" || !opts.Dither {
+		t.Errorf("expected dithered GIF output, got format %q dither %v", opts.Format, opts.Dither)
+	}
+
+	if _, err := ParseFlags([]string{"--gif", "-f", "gif", "anim.avif"}); err != nil {
+		t.Errorf("expected --gif to be allowed with -f gif, got: %v", err)
+	}
+}
+
+func TestParseFlags_GIFValidation(t *testing.T) {
+	tests := [][]string{
+		{"--gif", "-f", "jpeg", "anim.avif"},
+		{"--gif", "--auto-format", "anim.avif"},
+		{"--dither", "anim.avif"},
+	}
+	for _, args := range tests {
+		if _, err := ParseFlags(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseFlags_WithInterlace(t *testing.T) {
+	config, err := ParseFlags([]string{"--interlace", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.Interlace || !config.converterOptions().Interlace {
+		t.Error("expected Interlace to be set")
+	}
+}
+
+func TestParseFlags_InterlaceRequiresPNG(t *testing.T) {
+	if _, err := ParseFlags([]string{"--interlace", "-f", "jpeg", "image.avif"}); err == nil {
+		t.Fatal("expected error when combining --interlace with JPEG output")
+	}
+	if _, err := ParseFlags([]string{"--interlace", "--auto-format", "image.avif"}); err != nil {
+		t.Fatalf("expected no error with --auto-format, got: %v", err)
+	}
+}
+
+func TestParseFlags_WithChroma(t *testing.T) {
+	config, err := ParseFlags([]string{"-f", "jpeg", "--chroma", "444", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Chroma != "444" || config.converterOptions().Chroma != "444" {
+		t.Errorf("expected Chroma to be 444, got: %q", config.Chroma)
+	}
+
+	config, err = ParseFlags([]string{"image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Chroma != "420" {
+		t.Errorf("expected default Chroma to be 420, got: %q", config.Chroma)
+	}
+}
+
+func TestParseFlags_ChromaValidation(t *testing.T) {
+	if _, err := ParseFlags([]string{"-f", "jpeg", "--chroma", "422", "image.avif"}); err == nil {
+		t.Error("expected error for unsupported --chroma value")
+	}
+	if _, err := ParseFlags([]string{"--chroma", "444", "image.avif"}); err == nil {
+		t.Error("expected error when combining --chroma with PNG output")
+	}
+	if _, err := ParseFlags([]string{"--chroma", "444", "--auto-format", "image.avif"}); err != nil {
+		t.Errorf("expected --chroma to be allowed with --auto-format, got: %v", err)
+	}
+}
+
+func TestParseFlags_WithReportFlags(t *testing.T) {
+	args := []string{"--report", "report.html", "--report-previews", "my-images/"}
+
+	config, err := ParseFlags(args)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.ReportPath != "report.html" {
+		t.Errorf("expected ReportPath 'report.html', got: %s", config.ReportPath)
+	}
+	if !config.ReportPreviews {
+		t.Error("expected ReportPreviews to be true")
+	}
+}
+
+func TestParseFlags_WithJSONFlags(t *testing.T) {
+	config, err := ParseFlags([]string{"--json", "--json-indent", "2", "my-images/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.JSON {
+		t.Error("expected JSON to be true")
+	}
+	if config.JSONIndent != 2 {
+		t.Errorf("expected JSONIndent 2, got: %d", config.JSONIndent)
+	}
+}
+
+func TestParseFlags_WithVersion(t *testing.T) {
+	config, err := ParseFlags([]string{"--version", "--json"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.ShowVersion || !config.JSON {
+		t.Errorf("expected ShowVersion and JSON to be true, got: %+v", config)
+	}
+
+	if _, err := ParseFlags([]string{"--version", "image.avif"}); err == nil {
+		t.Error("expected error for --version with an input, got nil")
+	}
+
+	// A version variable in the environment must not turn every run into --version
+	config, err = parseFlags([]string{"images"}, envLookup(map[string]string{"AVIF2PNG_VERSION": "true"}))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.ShowVersion {
+		t.Error("expected AVIF2PNG_VERSION to be ignored")
+	}
+}
+
+func TestParseFlags_JSONWithVerbose(t *testing.T) {
+	_, err := ParseFlags([]string{"--json", "-v", "my-images/"})
+
+	if err == nil {
+		t.Fatal("expected error for --json with --verbose, got nil")
+	}
+}
+
+func TestParseFlags_NegativeJSONIndent(t *testing.T) {
+	_, err := ParseFlags([]string{"--json-indent", "-1", "my-images/"})
+
+	if err == nil {
+		t.Fatal("expected error for negative --json-indent, got nil")
+	}
+}
+
+func TestParseFlags_NoArguments(t *testing.T) {
+	args := []string{}
+
+	_, err := ParseFlags(args)
+
+	if err == nil {
+		t.Fatal("expected error for no arguments, got nil")
+	}
+}
+
+func TestParseFlags_TooManyArguments(t *testing.T) {
+	args := []string{"image1.avif", "image2.avif"}
+
+	_, err := ParseFlags(args)
+
+	if err == nil {
+		t.Fatal("expected error for too many arguments, got nil")
+	}
+}
+
+func TestParseFlags_InvalidFlag(t *testing.T) {
+	args := []string{"--invalid-flag", "image.avif"}
+
+	_, err := ParseFlags(args)
+
+	if err == nil {
+		t.Fatal("expected error for invalid flag, got nil")
+	}
+}
+
+// ==================== parseSince Tests ====================
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"":        0,
+		"1048576": 1048576,
+		"500KB":   500000,
+		"500kb":   500000,
+		"500K":    500000,
+		"1.5MB":   1500000,
+		"2MiB":    2 << 20,
+		"64 KiB":  64 << 10,
+		"1GB":     1000000000,
+		"10B":     10,
+	}
+
+	for value, want := range tests {
+		got, err := parseSize(value)
+		if err != nil {
+			t.Errorf("parseSize(%q): expected no error, got: %v", value, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("parseSize(%q): expected %d, got: %d", value, want, got)
+		}
+	}
+}
+
+func TestParseSize_Invalid(t *testing.T) {
+	for _, value := range []string{"big", "-5KB", "0", "0.5B", "KB", "5TB"} {
+		if _, err := parseSize(value); err == nil {
+			t.Errorf("parseSize(%q): expected error, got nil", value)
+		}
+	}
+}
+
+func TestParseFlags_WithMaxOutputSize(t *testing.T) {
+	config, err := ParseFlags([]string{"--max-output-size", "500KB", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.MaxOutputSize != 500000 || config.converterOptions().MaxOutputSize != 500000 {
+		t.Errorf("expected MaxOutputSize 500000, got: %d", config.MaxOutputSize)
+	}
+
+	if _, err := ParseFlags([]string{"--max-output-size", "lots", "image.avif"}); err == nil {
+		t.Error("expected error for an invalid size")
+	}
+}
+
+func TestParseSince_Empty(t *testing.T) {
+	since, err := parseSince("", time.Now())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !since.IsZero() {
+		t.Errorf("expected zero time for empty value, got: %v", since)
+	}
+}
+
+func TestParseSince_Duration(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	since, err := parseSince("24h", now)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !since.Equal(now.Add(-24 * time.Hour)) {
+		t.Errorf("expected %v, got: %v", now.Add(-24*time.Hour), since)
+	}
+}
+
+func TestParseSince_Date(t *testing.T) {
+	since, err := parseSince("2024-01-01", time.Now())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if since.Year() != 2024 || since.Month() != time.January || since.Day() != 1 {
+		t.Errorf("expected 2024-01-01, got: %v", since)
+	}
+}
+
+func TestParseSince_Invalid(t *testing.T) {
+	for _, value := range []string{"yesterday", "-24h", "2024-13-01"} {
+		if _, err := parseSince(value, time.Now()); err == nil {
+			t.Errorf("expected error for %q, got nil", value)
+		}
+	}
+}
+
+func TestParseFlags_WithSinceFlag(t *testing.T) {
+	config, err := ParseFlags([]string{"--since", "2024-01-01", "my-images/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Since.IsZero() {
+		t.Error("expected Since to be set")
+	}
+}
+
+// ==================== ValidateInputPath Tests ====================
+
+func TestValidateInputPath_ValidFile(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	createTestAVIF(t, inputPath)
+
+	isDir, err := ValidateInputPath(inputPath)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if isDir {
+		t.Error("expected isDir to be false for file")
+	}
+}
+
+func TestValidateInputPath_ValidDirectory(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	isDir, err := ValidateInputPath(testDir)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !isDir {
+		t.Error("expected isDir to be true for directory")
+	}
+}
+
+func TestValidateInputPath_NonExistent(t *testing.T) {
+	_, err := ValidateInputPath("/nonexistent/path")
+
+	if err == nil {
+		t.Fatal("expected error for non-existent path, got nil")
+	}
+}
+
+func TestValidateInputPath_WrongExtension(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.png")
+	if err := os.WriteFile(inputPath, []byte("test"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	_, err := ValidateInputPath(inputPath)
+
+	if err == nil {
+		t.Fatal("expected error for wrong file extension, got nil")
+	}
+}
+
+// ==================== ValidateInputFile Tests ====================
+
+func TestValidateInputFile_ValidFile(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	createTestAVIF(t, inputPath)
+
+	err := ValidateInputFile(inputPath)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+}
+
+func TestValidateInputFile_NonExistent(t *testing.T) {
+	err := ValidateInputFile("/nonexistent/path/image.avif")
+
+	if err == nil {
+		t.Fatal("expected error for non-existent file, got nil")
+	}
+}
+
+func TestValidateInputFile_WrongExtension(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.png")
+	if err := os.WriteFile(inputPath, []byte("test"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	err := ValidateInputFile(inputPath)
+
+	if err == nil {
+		t.Fatal("expected error for wrong extension, got nil")
+	}
+}
+
+func TestValidateInputFile_UppercaseExtension(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.AVIF")
+	if err := os.WriteFile(inputPath, []byte("test"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	err := ValidateInputFile(inputPath)
+	if err != nil {
+		t.Fatalf("expected no error for uppercase extension, got: %v", err)
+	}
+}
+
+// ==================== normalizePath Tests ====================
+
+func TestNormalizePath_ExpandsTilde(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skipf("no home directory available: %v", err)
+	}
+
+	path, err := normalizePath("~/Pictures/")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if path != filepath.Join(home, "Pictures") {
+		t.Errorf("expected %s, got: %s", filepath.Join(home, "Pictures"), path)
+	}
+}
+
+func TestNormalizePath_BareTilde(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skipf("no home directory available: %v", err)
+	}
+
+	path, err := normalizePath("~")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if path != filepath.Clean(home) {
+		t.Errorf("expected %s, got: %s", home, path)
+	}
+}
+
+func TestNormalizePath_TrimsTrailingSlash(t *testing.T) {
+	path, err := normalizePath("my-images/")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if path != "my-images" {
+		t.Errorf("expected 'my-images', got: %s", path)
+	}
+}
+
+func TestNormalizePath_LeavesOtherTildesAlone(t *testing.T) {
+	path, err := normalizePath("~user/images")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if path != filepath.Clean("~user/images") {
+		t.Errorf("expected '~user/images' to be left alone, got: %s", path)
+	}
+}
+
+// ==================== Run Tests ====================
+
+func TestRun_Success(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestAVIF(t, inputPath)
+
+	config := &Config{
+		InputPath: inputPath,
+		OutputDir: outputDir,
+		Recursive: false,
+		Verbose:   false,
+	}
+
+	err := Run(config)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+}
+
+func TestRun_SingleFileSkippedIsNotAnError(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	config := &Config{InputPath: inputPath, OutputDir: outputDir}
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := Run(config); err != nil {
+		t.Fatalf("expected an existing output to be skipped without error, got: %v", err)
+	}
+}
+
+func TestRun_SingleFileOutsideDimensions(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	config := &Config{InputPath: inputPath, OutputDir: outputDir, Dimensions: converter.DimensionBounds{MinWidth: 100}}
+	if err := Run(config); err != nil {
+		t.Fatalf("expected a file outside the bounds to be skipped without error, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "test.png")); !os.IsNotExist(err) {
+		t.Errorf("expected no output for a skipped file, got: %v", err)
+	}
+}
+
+func TestRun_SkipIsError(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "b.avif"))
+
+	config := &Config{InputPath: inputDir, OutputDir: outputDir, SkipIsError: true}
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error into an empty output directory, got: %v", err)
+	}
+	err := Run(config)
+	if err == nil || !strings.Contains(err.Error(), "2 file(s) skipped") {
+		t.Fatalf("expected an error for the skipped files, got: %v", err)
+	}
+
+	config.InputPath = filepath.Join(inputDir, "a.avif")
+	if err := Run(config); err == nil {
+		t.Error("expected an error for a skipped single file, got nil")
+	}
+}
+
+func TestRun_OutputIsFile(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	createTestAVIF(t, inputPath)
+	outputPath := filepath.Join(testDir, "out.png")
+	if err := os.WriteFile(outputPath, []byte("existing"), 0644); err != nil {
+		t.Fatalf("failed to create output file: %v", err)
+	}
+
+	err := Run(&Config{InputPath: inputPath, OutputDir: outputPath})
+	if !errors.Is(err, converter.ErrOutputNotDir) {
+		t.Fatalf("expected ErrOutputNotDir, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "output path exists and is not a directory") {
+		t.Errorf("expected a clear message, got: %v", err)
+	}
+}
+
+func TestRun_InvalidInputFile(t *testing.T) {
+	config := &Config{
+		InputPath: "/nonexistent/image.avif",
+		OutputDir: "./output",
+		Recursive: false,
+		Verbose:   false,
+	}
+
+	err := Run(config)
+
+	if err == nil {
+		t.Fatal("expected error for invalid input, got nil")
+	}
+}
+
+func TestRun_WrongExtension(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.jpg")
+	if err := os.WriteFile(inputPath, []byte("test"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	config := &Config{
+		InputPath: inputPath,
+		OutputDir: filepath.Join(testDir, "output"),
+		Verbose:   false,
+	}
+
+	err := Run(config)
+
+	if err == nil {
+		t.Fatal("expected error for wrong extension, got nil")
+	}
+}
+
+func TestRun_AnyExt(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avifs")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	config := &Config{
+		InputPath: inputPath,
+		OutputDir: outputDir,
+		AnyExt:    true,
+	}
+
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "test.png")); err != nil {
+		t.Errorf("expected output file to exist: %v", err)
+	}
+}
+
+func TestRun_AnyExtNotAVIF(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.jpg")
+	if err := os.WriteFile(inputPath, []byte("this is not an avif image"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	config := &Config{
+		InputPath: inputPath,
+		OutputDir: filepath.Join(testDir, "output"),
+		AnyExt:    true,
+	}
+
+	if err := Run(config); err == nil {
+		t.Fatal("expected decoding error for a non-AVIF file, got nil")
+	}
+}
+
+func TestRun_DirectoryConversion(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+
+	// Create test files
+	createTestAVIF(t, filepath.Join(inputDir, "image1.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "image2.avif"))
+
+	config := &Config{
+		InputPath: inputDir,
+		OutputDir: outputDir,
+		Recursive: false,
+		Verbose:   false,
+	}
+
+	err := Run(config)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// Verify output files exist
+	if _, err := os.Stat(filepath.Join(outputDir, "image1.png")); os.IsNotExist(err) {
+		t.Error("expected image1.png to exist")
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "image2.png")); os.IsNotExist(err) {
+		t.Error("expected image2.png to exist")
+	}
+}
+
+func TestRun_RecursiveDirectoryConversion(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	subDir := filepath.Join(inputDir, "subfolder")
+
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatalf("failed to create subdirectory: %v", err)
+	}
+
+	// Create test files
+	createTestAVIF(t, filepath.Join(inputDir, "image1.avif"))
+	createTestAVIF(t, filepath.Join(subDir, "image2.avif"))
+
+	config := &Config{
+		InputPath: inputDir,
+		OutputDir: outputDir,
+		Recursive: true,
+		Verbose:   false,
+	}
+
+	err := Run(config)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// Verify both files were converted
+	if _, err := os.Stat(filepath.Join(outputDir, "image1.png")); os.IsNotExist(err) {
+		t.Error("expected image1.png to exist")
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "image2.png")); os.IsNotExist(err) {
+		t.Error("expected image2.png to exist (flattened)")
+	}
+}
+
+func TestRun_MaxDepth(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	os.MkdirAll(filepath.Join(inputDir, "sub", "deeper"), 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "top.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "sub", "middle.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "sub", "deeper", "bottom.avif"))
+
+	// The input directory is level 1
+	for depth, want := range map[string][]string{
+		"1": {"top.png"},
+		"2": {"top.png", "middle.png"},
+	} {
+		outputDir := filepath.Join(testDir, "output"+depth)
+		config, err := ParseFlags([]string{"--max-depth", depth, "-o", outputDir, inputDir})
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if err := Run(config); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		entries, _ := os.ReadDir(outputDir)
+		if len(entries) != len(want) {
+			t.Errorf("--max-depth %s: expected %v, got %d file(s)", depth, want, len(entries))
+		}
+		for _, name := range want {
+			if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+				t.Errorf("--max-depth %s: expected %s to exist, got: %v", depth, name, err)
+			}
+		}
+	}
+}
+
+func TestRun_DirectoryConversionWritesReport(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	reportPath := filepath.Join(testDir, "report.html")
+
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "image1.avif"))
+
+	config := &Config{
+		InputPath:  inputDir,
+		OutputDir:  outputDir,
+		ReportPath: reportPath,
+	}
+
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if _, err := os.Stat(reportPath); os.IsNotExist(err) {
+		t.Fatal("expected report file to exist")
+	}
+}
+
+func TestRun_ReportAfterFailure(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	reportPath := filepath.Join(testDir, "report.html")
+	os.MkdirAll(inputDir, 0755)
+	os.WriteFile(filepath.Join(inputDir, "broken.avif"), []byte("this is not an AVIF file at all"), 0644)
+
+	config := &Config{
+		InputPath:  inputDir,
+		OutputDir:  filepath.Join(testDir, "output"),
+		ReportPath: reportPath,
+		OnError:    converter.OnErrorStop,
+	}
+	if err := Run(config); err == nil {
+		t.Fatal("expected the run to stop on the broken file")
+	}
+	if _, err := os.Stat(reportPath); err != nil {
+		t.Errorf("expected a report of the stopped run, got: %v", err)
+	}
+}
+
+func TestRun_ReportRequiresDirectory(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	createTestAVIF(t, inputPath)
+
+	config := &Config{
+		InputPath:  inputPath,
+		OutputDir:  filepath.Join(testDir, "output"),
+		ReportPath: filepath.Join(testDir, "report.html"),
+	}
+	if err := Run(config); err == nil || !strings.Contains(err.Error(), "--report") {
+		t.Errorf("expected a --report error for a single file, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(testDir, "output", "test.png")); !os.IsNotExist(err) {
+		t.Error("expected nothing to be converted")
+	}
+}
+
+func TestRun_ExpandsTildeInPaths(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	t.Setenv("HOME", testDir)
+	createTestAVIF(t, filepath.Join(testDir, "test.avif"))
+
+	config := &Config{
+		InputPath: "~/test.avif",
+		OutputDir: "~/output/",
+	}
+
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(testDir, "output", "test.png")); os.IsNotExist(err) {
+		t.Error("expected test.png to be written to the expanded output directory")
+	}
+}
+
+func TestParseFlags_WithMode(t *testing.T) {
+	config, err := ParseFlags([]string{"images"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.InputMode != InputModeAuto {
+		t.Errorf("expected mode %s by default, got %s", InputModeAuto, config.InputMode)
+	}
+
+	config, err = ParseFlags([]string{"--mode", "dir", "images"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.InputMode != InputModeDir {
+		t.Errorf("expected mode %s, got %s", InputModeDir, config.InputMode)
+	}
+
+	for _, args := range [][]string{
+		{"--mode", "folder", "images"},
+		{"--mode", "file", "--tar", "images.tar"},
+		{"--mode", "file", "--map", "in/*.avif=out/{1}.png"},
+	} {
+		if _, err := ParseFlags(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestRun_Mode(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	os.MkdirAll(inputDir, 0755)
+	inputPath := filepath.Join(inputDir, "test.avif")
+	createTestAVIF(t, inputPath)
+	outputDir := filepath.Join(testDir, "output")
+
+	if err := Run(&Config{InputPath: inputDir, OutputDir: outputDir, InputMode: InputModeFile}); err == nil || !strings.Contains(err.Error(), "--mode file") {
+		t.Errorf("expected a --mode file error for a directory, got: %v", err)
+	}
+	if err := Run(&Config{InputPath: inputPath, OutputDir: outputDir, InputMode: InputModeDir}); err == nil || !strings.Contains(err.Error(), "--mode dir") {
+		t.Errorf("expected a --mode dir error for a file, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "test.png")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be converted on a mismatch, got: %v", err)
+	}
+
+	if err := Run(&Config{InputPath: inputPath, OutputDir: outputDir, InputMode: InputModeFile}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "test.png")); err != nil {
+		t.Errorf("expected test.png to exist, got: %v", err)
+	}
+
+	// Explicit modes read patterns literally
+	if err := Run(&Config{InputPath: filepath.Join(inputDir, "*.avif"), OutputDir: outputDir, InputMode: InputModeFile}); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("expected the pattern to be read as a missing file, got: %v", err)
+	}
+}
+
+func TestRun_MetaOnly(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	createTestAVIF(t, inputPath)
+	outputDir := filepath.Join(testDir, "output")
+
+	if err := Run(&Config{InputPath: inputPath, OutputDir: outputDir, Format: "png", MetaOnly: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "test.json")); err != nil {
+		t.Errorf("expected test.json to exist, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "test.png")); !os.IsNotExist(err) {
+		t.Errorf("expected no test.png, got: %v", err)
+	}
+}
+
+func TestRun_JSONRequiresDirectory(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	createTestAVIF(t, inputPath)
+
+	config := &Config{
+		InputPath: inputPath,
+		OutputDir: filepath.Join(testDir, "output"),
+		JSON:      true,
+	}
+
+	if err := Run(config); err == nil {
+		t.Fatal("expected error for --json with a single file, got nil")
+	}
+}
+
+func TestRun_FlattenConflictReport(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	if err := os.MkdirAll(filepath.Join(inputDir, "sub"), 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "image.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "sub", "image.avif"))
+
+	config := &Config{
+		InputPath:      inputDir,
+		OutputDir:      outputDir,
+		Recursive:      true,
+		ConflictReport: true,
+	}
+	if err := Run(config); err == nil {
+		t.Fatal("expected an error when outputs conflict")
+	}
+
+	config.FlattenSeparator = "_"
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error without conflicts, got: %v", err)
+	}
+
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Error("expected nothing to be converted")
+	}
+}
+
+func TestRun_OnCollision(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	if err := os.MkdirAll(filepath.Join(inputDir, "sub"), 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "image.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "sub", "image.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "sub", "other.avif"))
+
+	config := &Config{InputPath: inputDir, OutputDir: outputDir, Recursive: true, OnCollision: CollisionError}
+	if err := Run(config); err == nil || !strings.Contains(err.Error(), "collision") {
+		t.Fatalf("expected a collision error, got: %v", err)
+	}
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Error("expected nothing to be converted")
+	}
+
+	// Glob inputs are checked too
+	config.InputPath = filepath.Join(inputDir, "*", "image.avif")
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error for a single match, got: %v", err)
+	}
+	config.InputPath = filepath.Join(inputDir, "*image.avif")
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error without collisions, got: %v", err)
+	}
+
+	config.InputPath = inputDir
+	config.OnCollision = CollisionWarn
+	if err := Run(config); err != nil {
+		t.Fatalf("expected only a warning, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "other.png")); err != nil {
+		t.Errorf("expected the conversion to go ahead, got: %v", err)
+	}
+}
+
+func TestParseFlags_WithSkipIsError(t *testing.T) {
+	config, err := ParseFlags([]string{"--skip-is-error", "my-images/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.SkipIsError {
+		t.Error("expected SkipIsError to be true")
+	}
+}
+
+func TestParseFlags_WithOnCollision(t *testing.T) {
+	config, err := ParseFlags([]string{"my-images/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.OnCollision != CollisionSkip {
+		t.Errorf("expected %q by default, got: %q", CollisionSkip, config.OnCollision)
+	}
+
+	config, err = ParseFlags([]string{"--on-collision", "error", "my-images/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.OnCollision != CollisionError {
+		t.Errorf("expected %q, got: %q", CollisionError, config.OnCollision)
+	}
+
+	for _, args := range [][]string{
+		{"--on-collision", "overwrite", "my-images/"},
+		{"--on-collision", "warn", "--tar", "batch.tar"},
+		{"--on-collision", "warn", "--pdf", "album.pdf", "my-images/"},
+	} {
+		if _, err := ParseFlags(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestRun_CompareRequiresDirectory(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	createTestAVIF(t, inputPath)
+
+	config := &Config{
+		InputPath: inputPath,
+		OutputDir: filepath.Join(testDir, "output"),
+		Compare:   true,
+	}
+	if err := Run(config); err == nil {
+		t.Fatal("expected error for --compare with a single file")
+	}
+}
+
+func TestRun_PDF(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	pdfPath := filepath.Join(testDir, "album.pdf")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "image1.avif"))
+
+	config := &Config{
+		InputPath: inputDir,
+		OutputDir: outputDir,
+		PDFPath:   pdfPath,
+	}
+
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if _, err := os.Stat(pdfPath); err != nil {
+		t.Errorf("expected PDF to exist: %v", err)
+	}
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Error("expected no per-file outputs in PDF mode")
+	}
+}
+
+func TestRunContext_Interrupted(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
 	}
-}
+	createTestAVIF(t, filepath.Join(inputDir, "image1.avif"))
 
-func TestParseFlags_TooManyArguments(t *testing.T) {
-	args := []string{"image1.avif", "image2.avif"}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
 
-	_, err := ParseFlags(args)
+	config := &Config{
+		InputPath: inputDir,
+		OutputDir: filepath.Join(testDir, "output"),
+	}
 
-	if err == nil {
-		t.Fatal("expected error for too many arguments, got nil")
+	if err := RunContext(ctx, config); !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("expected ErrInterrupted, got: %v", err)
 	}
 }
 
-func TestParseFlags_InvalidFlag(t *testing.T) {
-	args := []string{"--invalid-flag", "image.avif"}
+func TestRun_PDFRequiresDirectory(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
 
-	_, err := ParseFlags(args)
+	inputPath := filepath.Join(testDir, "test.avif")
+	createTestAVIF(t, inputPath)
 
-	if err == nil {
-		t.Fatal("expected error for invalid flag, got nil")
+	config := &Config{
+		InputPath: inputPath,
+		OutputDir: filepath.Join(testDir, "output"),
+		PDFPath:   filepath.Join(testDir, "album.pdf"),
 	}
-}
 
-// ==================== ValidateInputPath Tests ====================
+	if err := Run(config); err == nil {
+		t.Fatal("expected error for --pdf with a single file, got nil")
+	}
+}
 
-func TestValidateInputPath_ValidFile(t *testing.T) {
+func TestRun_Tar(t *testing.T) {
 	testDir := setupTestDir(t)
 	defer os.RemoveAll(testDir)
 
-	inputPath := filepath.Join(testDir, "test.avif")
-	createTestAVIF(t, inputPath)
+	avifPath := filepath.Join(testDir, "source.avif")
+	createTestAVIF(t, avifPath)
+	data, err := os.ReadFile(avifPath)
+	if err != nil {
+		t.Fatalf("failed to read test AVIF: %v", err)
+	}
 
-	isDir, err := ValidateInputPath(inputPath)
+	archivePath := filepath.Join(testDir, "batch.tar")
+	archive, err := os.Create(archivePath)
 	if err != nil {
+		t.Fatalf("failed to create archive: %v", err)
+	}
+	tw := tar.NewWriter(archive)
+	if err := tw.WriteHeader(&tar.Header{Name: "album/photo.avif", Mode: 0644, Size: int64(len(data))}); err != nil {
+		t.Fatalf("failed to write tar header: %v", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		t.Fatalf("failed to write tar entry: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar writer: %v", err)
+	}
+	archive.Close()
+
+	outputDir := filepath.Join(testDir, "output")
+	if err := Run(&Config{InputPath: archivePath, OutputDir: outputDir, Tar: true}); err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
-	if isDir {
-		t.Error("expected isDir to be false for file")
+	if _, err := os.Stat(filepath.Join(outputDir, "album", "photo.png")); err != nil {
+		t.Errorf("expected album/photo.png to exist: %v", err)
+	}
+
+	if err := Run(&Config{InputPath: archivePath, OutputDir: outputDir, Tar: true, PDFPath: "album.pdf"}); err == nil {
+		t.Error("expected error for --pdf with --tar, got nil")
 	}
 }
 
-func TestValidateInputPath_ValidDirectory(t *testing.T) {
+func TestRun_Glob(t *testing.T) {
 	testDir := setupTestDir(t)
 	defer os.RemoveAll(testDir)
 
-	isDir, err := ValidateInputPath(testDir)
+	inputDir := filepath.Join(testDir, "photos")
+	if err := os.MkdirAll(filepath.Join(inputDir, "dir.avif"), 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "b.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "other.avif"))
+	if err := os.WriteFile(filepath.Join(inputDir, "notes.txt"), []byte("notes"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	outputDir := filepath.Join(testDir, "output")
+	config := &Config{InputPath: filepath.Join(inputDir, "[ab].avif"), OutputDir: outputDir}
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	entries, err := os.ReadDir(outputDir)
 	if err != nil {
+		t.Fatalf("failed to read output dir: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Name() != "a.png" || entries[1].Name() != "b.png" {
+		t.Errorf("expected a.png and b.png, got: %v", entries)
+	}
+
+	// Directories and other extensions never match
+	if err := Run(&Config{InputPath: filepath.Join(inputDir, "*"), OutputDir: filepath.Join(testDir, "all")}); err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
-	if !isDir {
-		t.Error("expected isDir to be true for directory")
+	if entries, _ := os.ReadDir(filepath.Join(testDir, "all")); len(entries) != 3 {
+		t.Errorf("expected 3 outputs, got: %v", entries)
 	}
 }
 
-func TestValidateInputPath_NonExistent(t *testing.T) {
-	_, err := ValidateInputPath("/nonexistent/path")
+func TestRun_GlobWithoutMatches(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
 
-	if err == nil {
-		t.Fatal("expected error for non-existent path, got nil")
+	config := &Config{InputPath: filepath.Join(testDir, "*.avif"), OutputDir: filepath.Join(testDir, "output")}
+	err := Run(config)
+	if err == nil || !strings.Contains(err.Error(), "no AVIF files match") {
+		t.Errorf("expected an error for a pattern without matches, got: %v", err)
+	}
+
+	config.InputPath = filepath.Join(testDir, "[.avif")
+	if err := Run(config); err == nil {
+		t.Error("expected error for a malformed pattern, got nil")
 	}
 }
 
-func TestValidateInputPath_WrongExtension(t *testing.T) {
+func TestIsGlobPattern(t *testing.T) {
 	testDir := setupTestDir(t)
 	defer os.RemoveAll(testDir)
 
-	inputPath := filepath.Join(testDir, "test.png")
-	if err := os.WriteFile(inputPath, []byte("test"), 0644); err != nil {
-		t.Fatalf("failed to create test file: %v", err)
+	// An existing file is taken literally, even with glob characters
+	literal := filepath.Join(testDir, "photo[1].avif")
+	createTestAVIF(t, literal)
+	if isGlobPattern(literal) {
+		t.Errorf("expected %s to be taken literally", literal)
 	}
-
-	_, err := ValidateInputPath(inputPath)
-
-	if err == nil {
-		t.Fatal("expected error for wrong file extension, got nil")
+	if !isGlobPattern(filepath.Join(testDir, "*.avif")) {
+		t.Error("expected *.avif to be a pattern")
+	}
+	if isGlobPattern(filepath.Join(testDir, "photo.avif")) {
+		t.Error("expected a plain path not to be a pattern")
 	}
 }
 
-// ==================== ValidateInputFile Tests ====================
-
-func TestValidateInputFile_ValidFile(t *testing.T) {
+func TestRun_Benchmark(t *testing.T) {
 	testDir := setupTestDir(t)
 	defer os.RemoveAll(testDir)
 
-	inputPath := filepath.Join(testDir, "test.avif")
+	inputPath := filepath.Join(testDir, "sample.avif")
 	createTestAVIF(t, inputPath)
+	outputDir := filepath.Join(testDir, "output")
 
-	err := ValidateInputFile(inputPath)
+	config := &Config{InputPath: inputPath, OutputDir: outputDir, Benchmark: 100 * time.Millisecond, BenchmarkWorkers: 1}
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Error("expected a benchmark not to write outputs")
+	}
+}
+
+func TestParseFlags_WithTar(t *testing.T) {
+	config, err := ParseFlags([]string{"--tar", "-"})
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
+	if !config.Tar || config.InputPath != "-" {
+		t.Errorf("expected Tar with stdin input, got: %+v", config)
+	}
 }
 
-func TestValidateInputFile_NonExistent(t *testing.T) {
-	err := ValidateInputFile("/nonexistent/path/image.avif")
+func TestParseFlags_WithStdinList(t *testing.T) {
+	config, err := ParseFlags([]string{"--stdin-list", "-o", "./converted"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.StdinList || config.InputPath != "-" {
+		t.Errorf("expected StdinList with stdin input, got: %+v", config)
+	}
 
-	if err == nil {
-		t.Fatal("expected error for non-existent file, got nil")
+	for _, args := range [][]string{
+		{"--stdin-list", "my-images/"},
+		{"--stdin-list", "--tar"},
+		{"--stdin-list", "--spec", "-"},
+		{"--stdin-list", "-i"},
+		{"--stdin-list", "--number"},
+		{"--stdin-list", "--on-collision", "error"},
+	} {
+		if _, err := ParseFlags(args); err == nil || !strings.Contains(err.Error(), "--stdin-list") {
+			t.Errorf("%v: expected a --stdin-list error, got: %v", args, err)
+		}
 	}
 }
 
-func TestValidateInputFile_WrongExtension(t *testing.T) {
+func TestRun_StdinList(t *testing.T) {
 	testDir := setupTestDir(t)
 	defer os.RemoveAll(testDir)
 
-	inputPath := filepath.Join(testDir, "test.png")
-	if err := os.WriteFile(inputPath, []byte("test"), 0644); err != nil {
-		t.Fatalf("failed to create test file: %v", err)
+	outputDir := filepath.Join(testDir, "output")
+	var list strings.Builder
+	for _, name := range []string{"a.avif", "b.avif"} {
+		createTestAVIF(t, filepath.Join(testDir, name))
+		list.WriteString(filepath.Join(testDir, name) + "\n")
+	}
+	listPath := filepath.Join(testDir, "list.txt")
+	if err := os.WriteFile(listPath, []byte(list.String()), 0644); err != nil {
+		t.Fatalf("failed to write list: %v", err)
 	}
 
-	err := ValidateInputFile(inputPath)
+	stdin, err := os.Open(listPath)
+	if err != nil {
+		t.Fatalf("failed to open list: %v", err)
+	}
+	defer stdin.Close()
+	oldStdin := os.Stdin
+	os.Stdin = stdin
+	defer func() { os.Stdin = oldStdin }()
 
-	if err == nil {
-		t.Fatal("expected error for wrong extension, got nil")
+	config := &Config{InputPath: "-", OutputDir: outputDir, StdinList: true}
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	for _, name := range []string{"a.png", "b.png"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+			t.Errorf("expected %s to exist, got: %v", name, err)
+		}
 	}
 }
 
-func TestValidateInputFile_UppercaseExtension(t *testing.T) {
-	testDir := setupTestDir(t)
-	defer os.RemoveAll(testDir)
+// ==================== Summary Tests ====================
 
-	inputPath := filepath.Join(testDir, "test.AVIF")
-	if err := os.WriteFile(inputPath, []byte("test"), 0644); err != nil {
-		t.Fatalf("failed to create test file: %v", err)
+func TestFormatSkipReasons(t *testing.T) {
+	reasons := map[string]int{
+		converter.SkipReasonExists: 2,
+		"filtered":                 1,
+		"unused":                   0,
 	}
 
-	err := ValidateInputFile(inputPath)
-	if err != nil {
-		t.Fatalf("expected no error for uppercase extension, got: %v", err)
+	if got := formatSkipReasons(reasons); got != "2 already exist, 1 filtered" {
+		t.Errorf("expected reasons sorted by name, got: %q", got)
 	}
 }
 
-// ==================== Run Tests ====================
-
-func TestRun_Success(t *testing.T) {
-	testDir := setupTestDir(t)
-	defer os.RemoveAll(testDir)
+func TestFormatSkipped(t *testing.T) {
+	tests := []struct {
+		skipped int
+		reasons map[string]int
+		want    string
+	}{
+		{3, map[string]int{converter.SkipReasonExists: 3}, "3 skipped: already exist"},
+		{1, map[string]int{converter.SkipReasonExists: 1, converter.SkipReasonDuplicate: 0}, "1 skipped: already exists"},
+		{3, map[string]int{converter.SkipReasonExists: 2, converter.SkipReasonDuplicate: 1}, "3 skipped: 2 already exist, 1 duplicate"},
+	}
+	for _, tt := range tests {
+		if got := formatSkipped(tt.skipped, tt.reasons); got != tt.want {
+			t.Errorf("expected %q, got: %q", tt.want, got)
+		}
+	}
+}
 
-	inputPath := filepath.Join(testDir, "test.avif")
-	outputDir := filepath.Join(testDir, "output")
+func TestFormatExtensionCounts(t *testing.T) {
+	counts := []converter.ExtensionCount{
+		{Extension: ".avif", Seen: 42, Converted: 40},
+		{Extension: ".AVIF", Seen: 3, Converted: 3},
+		{Seen: 1},
+	}
+	if got := formatExtensionCounts(counts); got != "42 .avif (40 converted), 3 .AVIF (3 converted), 1 without extension (0 converted)" {
+		t.Errorf("unexpected extension counts: %q", got)
+	}
+}
 
-	createTestAVIF(t, inputPath)
+func TestNoFilesMessage(t *testing.T) {
+	empty := &converter.ConversionResult{}
+	if got := noFilesMessage(empty, "directory"); got != "⚠️  No AVIF files found in directory" {
+		t.Errorf("unexpected message for an empty directory: %q", got)
+	}
 
-	config := &Config{
-		InputPath: inputPath,
-		OutputDir: outputDir,
-		Recursive: false,
-		Verbose:   false,
+	filtered := &converter.ConversionResult{FilteredReasons: map[string]int{
+		converter.FilterReasonHidden: 2,
+		converter.FilterReasonSince:  1,
+	}}
+	want := "⚠️  No AVIF files to convert in directory: 3 filtered out (2 hidden, 1 older than since)\n" +
+		"   Use --include-hidden to convert hidden files\n" +
+		"   Use an earlier --since to convert older files"
+	if got := noFilesMessage(filtered, "directory"); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
 	}
+}
 
-	err := Run(config)
+func TestParseFlags_WithProgressAddr(t *testing.T) {
+	config, err := ParseFlags([]string{"--progress-addr", "localhost:8080", "-r", "my-images/"})
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
+	if config.ProgressAddr != "localhost:8080" {
+		t.Errorf("expected progress address localhost:8080, got %q", config.ProgressAddr)
+	}
+
+	if _, err := ParseFlags([]string{"--progress-addr", "localhost:8080", "--pdf", "out.pdf", "my-images/"}); err == nil {
+		t.Error("expected error for --progress-addr with --pdf")
+	}
 }
 
-func TestRun_InvalidInputFile(t *testing.T) {
-	config := &Config{
-		InputPath: "/nonexistent/image.avif",
-		OutputDir: "./output",
-		Recursive: false,
-		Verbose:   false,
+func TestParseFlags_WithRawFormats(t *testing.T) {
+	for _, format := range []string{"ppm", "pam"} {
+		config, err := ParseFlags([]string{"-f", format, "--strip-metadata", "image.avif"})
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if config.Format != format {
+			t.Errorf("expected format %s, got %s", format, config.Format)
+		}
 	}
+}
 
-	err := Run(config)
+func TestParseFlags_WithNormalizeGamma(t *testing.T) {
+	config, err := ParseFlags([]string{"--normalize-gamma", "my-images/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.NormalizeGamma || !config.converterOptions().NormalizeGamma {
+		t.Error("expected gamma normalization to be enabled")
+	}
 
-	if err == nil {
-		t.Fatal("expected error for invalid input, got nil")
+	if _, err := ParseFlags([]string{"--normalize-gamma", "--to-avif", "my-images/"}); err == nil {
+		t.Error("expected error for --normalize-gamma with --to-avif")
 	}
 }
 
-func TestRun_WrongExtension(t *testing.T) {
-	testDir := setupTestDir(t)
-	defer os.RemoveAll(testDir)
+func TestParseFlags_WithFixAlpha(t *testing.T) {
+	config, err := ParseFlags([]string{"--fix-alpha", "my-images/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.FixAlpha || !config.converterOptions().FixAlpha {
+		t.Error("expected alpha fixing to be enabled")
+	}
 
-	inputPath := filepath.Join(testDir, "test.jpg")
-	if err := os.WriteFile(inputPath, []byte("test"), 0644); err != nil {
-		t.Fatalf("failed to create test file: %v", err)
+	if _, err := ParseFlags([]string{"--fix-alpha", "--spritesheet", "sprites.png", "my-images/"}); err == nil {
+		t.Error("expected error for --fix-alpha with --spritesheet")
 	}
+}
 
-	config := &Config{
-		InputPath: inputPath,
-		OutputDir: filepath.Join(testDir, "output"),
-		Verbose:   false,
+func TestParseFlags_WithMetaOnly(t *testing.T) {
+	config, err := ParseFlags([]string{"--meta-only", "-r", "photos/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.MetaOnly || !config.converterOptions().MetaOnly {
+		t.Error("expected metadata-only output to be enabled")
 	}
 
-	err := Run(config)
+	for _, args := range [][]string{
+		{"--meta-only", "-f", "jpeg", "photos/"},
+		{"--meta-only", "--verify", "photos/"},
+		{"--meta-only", "--pdf", "out.pdf", "photos/"},
+	} {
+		if _, err := ParseFlags(args); err == nil || !strings.Contains(err.Error(), "--meta-only") {
+			t.Errorf("expected a --meta-only error for %v, got: %v", args, err)
+		}
+	}
+}
 
-	if err == nil {
-		t.Fatal("expected error for wrong extension, got nil")
+func TestParseFlags_WithSpriteSheet(t *testing.T) {
+	config, err := ParseFlags([]string{"--spritesheet", "sprites.png", "--spritesheet-width", "512", "icons/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.SpriteSheet != "sprites.png" || config.SpriteSheetWidth != 512 {
+		t.Errorf("expected a 512-pixel-wide sprites.png, got %q and %d", config.SpriteSheet, config.SpriteSheetWidth)
+	}
+
+	for _, args := range [][]string{
+		{"--spritesheet", "sprites.jpg", "icons/"},
+		{"--spritesheet", "sprites.png", "--pdf", "album.pdf", "icons/"},
+		{"--spritesheet", "sprites.png", "--spritesheet-width", "0", "icons/"},
+	} {
+		if _, err := ParseFlags(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
 	}
 }
 
-func TestRun_DirectoryConversion(t *testing.T) {
+func TestRun_SpriteSheet(t *testing.T) {
 	testDir := setupTestDir(t)
 	defer os.RemoveAll(testDir)
 
-	inputDir := filepath.Join(testDir, "input")
-	outputDir := filepath.Join(testDir, "output")
+	inputDir := filepath.Join(testDir, "icons")
+	os.MkdirAll(inputDir, 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "b.avif"))
 
-	if err := os.MkdirAll(inputDir, 0755); err != nil {
-		t.Fatalf("failed to create input dir: %v", err)
+	sheetPath := filepath.Join(testDir, "sprites.png")
+	if err := Run(&Config{InputPath: inputDir, SpriteSheet: sheetPath, SpriteSheetWidth: 2048}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	for _, path := range []string{sheetPath, filepath.Join(testDir, "sprites.json")} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s to be written, got: %v", path, err)
+		}
 	}
 
-	// Create test files
-	createTestAVIF(t, filepath.Join(inputDir, "image1.avif"))
-	createTestAVIF(t, filepath.Join(inputDir, "image2.avif"))
+	if err := Run(&Config{InputPath: filepath.Join(inputDir, "a.avif"), SpriteSheet: sheetPath}); err == nil {
+		t.Error("expected error for --spritesheet with a single file")
+	}
+}
 
-	config := &Config{
-		InputPath: inputDir,
-		OutputDir: outputDir,
-		Recursive: false,
-		Verbose:   false,
+func TestParseFlags_WithToAVIF(t *testing.T) {
+	config, err := ParseFlags([]string{"--to-avif", "photos/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	opts := config.converterOptions()
+	if !opts.ToAVIF || config.Format != "avif" || opts.Quality != converter.DefaultAVIFQuality {
+		t.Errorf("expected AVIF output at the default AVIF quality, got %q at %d", config.Format, opts.Quality)
 	}
 
-	err := Run(config)
+	config, err = ParseFlags([]string{"--to-avif", "--avif-quality", "75", "--chroma", "444", "photos/"})
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
+	if opts := config.converterOptions(); opts.Quality != 75 || opts.Chroma != converter.Chroma444 {
+		t.Errorf("expected quality 75 with 444 chroma, got %d and %s", opts.Quality, opts.Chroma)
+	}
 
-	// Verify output files exist
-	if _, err := os.Stat(filepath.Join(outputDir, "image1.png")); os.IsNotExist(err) {
-		t.Error("expected image1.png to exist")
+	// --format avif is the same as --to-avif
+	config, err = ParseFlags([]string{"-f", "avif", "--avif-quality", "75", "photos/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
 	}
-	if _, err := os.Stat(filepath.Join(outputDir, "image2.png")); os.IsNotExist(err) {
-		t.Error("expected image2.png to exist")
+	if opts := config.converterOptions(); !opts.ToAVIF || opts.Format != "avif" || opts.Quality != 75 {
+		t.Errorf("expected AVIF output from PNG and JPEG inputs, got %+v", opts)
+	}
+
+	for _, args := range [][]string{
+		{"-f", "avif", "--quality", "80", "photos/"},
+		{"-f", "avif", "--use-thumbnail", "photos/"},
+		{"--to-avif", "-f", "png", "photos/"},
+		{"--to-avif", "--gif", "photos/"},
+		{"--to-avif", "--auto-format", "photos/"},
+		{"--to-avif", "--quality", "80", "photos/"},
+		{"--to-avif", "--use-thumbnail", "photos/"},
+		{"--to-avif", "--pdf", "out.pdf", "photos/"},
+		{"--to-avif", "--avif-quality", "0", "photos/"},
+		{"--avif-quality", "70", "photos/"},
+	} {
+		if _, err := ParseFlags(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
 	}
 }
 
-func TestRun_RecursiveDirectoryConversion(t *testing.T) {
+func TestRun_ToAVIF(t *testing.T) {
 	testDir := setupTestDir(t)
 	defer os.RemoveAll(testDir)
 
-	inputDir := filepath.Join(testDir, "input")
+	inputPath := filepath.Join(testDir, "photo.png")
 	outputDir := filepath.Join(testDir, "output")
-	subDir := filepath.Join(inputDir, "subfolder")
-
-	if err := os.MkdirAll(subDir, 0755); err != nil {
-		t.Fatalf("failed to create subdirectory: %v", err)
-	}
-
-	// Create test files
-	createTestAVIF(t, filepath.Join(inputDir, "image1.avif"))
-	createTestAVIF(t, filepath.Join(subDir, "image2.avif"))
-
-	config := &Config{
-		InputPath: inputDir,
-		OutputDir: outputDir,
-		Recursive: true,
-		Verbose:   false,
+	file, err := os.Create(inputPath)
+	if err != nil {
+		t.Fatalf("failed to create test PNG: %v", err)
 	}
+	png.Encode(file, image.NewNRGBA(image.Rect(0, 0, 4, 4)))
+	file.Close()
 
-	err := Run(config)
-	if err != nil {
+	if err := Run(&Config{InputPath: inputPath, OutputDir: outputDir, ToAVIF: true}); err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
-
-	// Verify both files were converted
-	if _, err := os.Stat(filepath.Join(outputDir, "image1.png")); os.IsNotExist(err) {
-		t.Error("expected image1.png to exist")
+	if _, err := os.Stat(filepath.Join(outputDir, "photo.avif")); err != nil {
+		t.Errorf("expected photo.avif to be written, got: %v", err)
 	}
-	if _, err := os.Stat(filepath.Join(outputDir, "image2.png")); os.IsNotExist(err) {
-		t.Error("expected image2.png to exist (flattened)")
+
+	// Without --to-avif, PNG inputs are rejected by their extension
+	if err := Run(&Config{InputPath: inputPath, OutputDir: outputDir}); err == nil || !strings.Contains(err.Error(), ".avif") {
+		t.Errorf("expected an extension error, got: %v", err)
 	}
 }
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is the default time limit for downloading a URL input
+const DefaultTimeout = 30 * time.Second
+
+// isURL reports whether an input is an http(s) URL rather than a local path
+func isURL(input string) bool {
+	lower := strings.ToLower(input)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// downloadName returns the local file name for a URL, taken from the last
+// segment of its path, which url.Parse has already percent-decoded
+// The name always ends in .avif, so it passes input validation
+func downloadName(u *url.URL) string {
+	name := path.Base(u.Path)
+
+	// Guard against separators smuggled in through percent-encoding
+	name = filepath.Base(filepath.FromSlash(name))
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		name = "download"
+	}
+
+	if strings.ToLower(filepath.Ext(name)) != ".avif" {
+		name += ".avif"
+	}
+	return name
+}
+
+// downloadInput fetches an AVIF URL into a temporary directory
+// Redirects are followed, and timeout bounds the whole request
+// The returned cleanup function removes the temporary directory
+func downloadInput(rawURL string, timeout time.Duration) (string, func(), error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid input URL: %w", err)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to download input: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("failed to download input: %s", resp.Status)
+	}
+
+	tempDir, err := os.MkdirTemp("", "avif2png-download-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(tempDir) }
+
+	localPath := filepath.Join(tempDir, downloadName(u))
+	file, err := os.Create(localPath)
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+
+	_, err = io.Copy(file, resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to download input: %w", err)
+	}
+
+	return localPath, cleanup, nil
+}
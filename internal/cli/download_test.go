@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// serveTestAVIF starts a server that serves a test AVIF at /images/<name>
+// and redirects /latest to /images/photo.avif
+func serveTestAVIF(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	avifPath := filepath.Join(t.TempDir(), "served.avif")
+	createTestAVIF(t, avifPath)
+	data, err := os.ReadFile(avifPath)
+	if err != nil {
+		t.Fatalf("failed to read test AVIF: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/images/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	})
+	mux.HandleFunc("/latest", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/images/photo.avif", http.StatusFound)
+	})
+	mux.HandleFunc("/slow.avif", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+		w.Write(data)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// ==================== Download Tests ====================
+
+func TestIsURL(t *testing.T) {
+	tests := map[string]bool{
+		"https://example.com/a.avif": true,
+		"HTTP://example.com/a.avif":  true,
+		"image.avif":                 false,
+		"ftp://example.com/a.avif":   false,
+		"./http/image.avif":          false,
+	}
+
+	for input, want := range tests {
+		if got := isURL(input); got != want {
+			t.Errorf("isURL(%q): expected %v, got %v", input, want, got)
+		}
+	}
+}
+
+func TestDownloadName(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/images/photo.avif":       "photo.avif",
+		"https://example.com/images/my%20photo.AVIF":  "my photo.AVIF",
+		"https://example.com/images/photo?size=large": "photo.avif",
+		"https://example.com/":                        "download.avif",
+		"https://example.com/a/..%2F..%2Fetc%2Fpwd":   "pwd.avif",
+		"https://example.com/a%2525.avif":             "a%25.avif",
+	}
+
+	for rawURL, want := range tests {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", rawURL, err)
+		}
+		if got := downloadName(u); got != want {
+			t.Errorf("downloadName(%q): expected %q, got %q", rawURL, want, got)
+		}
+	}
+}
+
+func TestDownloadInput_Success(t *testing.T) {
+	server := serveTestAVIF(t)
+
+	localPath, cleanup, err := downloadInput(server.URL+"/images/my%20image.avif", DefaultTimeout)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if filepath.Base(localPath) != "my image.avif" {
+		t.Errorf("expected file to be named after the URL, got: %s", filepath.Base(localPath))
+	}
+	if info, err := os.Stat(localPath); err != nil || info.Size() == 0 {
+		t.Fatalf("expected downloaded file to exist, got: %v", err)
+	}
+
+	cleanup()
+	if _, err := os.Stat(localPath); !os.IsNotExist(err) {
+		t.Error("expected cleanup to remove the downloaded file")
+	}
+}
+
+func TestDownloadInput_FollowsRedirects(t *testing.T) {
+	server := serveTestAVIF(t)
+
+	localPath, cleanup, err := downloadInput(server.URL+"/latest", DefaultTimeout)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer cleanup()
+
+	// The name comes from the URL that was requested, not the redirect target
+	if filepath.Base(localPath) != "latest.avif" {
+		t.Errorf("expected latest.avif, got: %s", filepath.Base(localPath))
+	}
+}
+
+func TestDownloadInput_NotFound(t *testing.T) {
+	server := serveTestAVIF(t)
+
+	if _, _, err := downloadInput(server.URL+"/missing.avif", DefaultTimeout); err == nil {
+		t.Fatal("expected error for a missing URL, got nil")
+	}
+}
+
+func TestDownloadInput_Timeout(t *testing.T) {
+	server := serveTestAVIF(t)
+
+	if _, _, err := downloadInput(server.URL+"/slow.avif", 50*time.Millisecond); err == nil {
+		t.Fatal("expected timeout error, got nil")
+	}
+}
+
+func TestRun_URLInput(t *testing.T) {
+	server := serveTestAVIF(t)
+	outputDir := filepath.Join(t.TempDir(), "output")
+
+	config := &Config{
+		InputPath: server.URL + "/images/photo.avif",
+		OutputDir: outputDir,
+	}
+
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "photo.png")); err != nil {
+		t.Errorf("expected photo.png to exist: %v", err)
+	}
+}
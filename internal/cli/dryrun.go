@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"avif2png/internal/converter"
+	"avif2png/internal/report"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// reportPlan prints what a --dry-run of a directory, archive or single file
+// (the kind of input) would do with each file, as a JSON array with --json,
+// and returns the error to exit with
+// Planned failures, such as output names that cannot be created, fail the
+// run like failed conversions, so a CI step can check a plan before running it
+func reportPlan(config *Config, result *converter.ConversionResult, err error, kind string) error {
+	if config.JSON {
+		if jsonErr := report.WritePlanJSON(config.stdout(), result, config.JSONIndent); jsonErr != nil && err == nil {
+			err = fmt.Errorf("failed to write JSON output: %w", jsonErr)
+		}
+	} else {
+		printPlan(config.stdout(), result)
+	}
+
+	if errors.Is(err, context.Canceled) {
+		printFileErrors(config.stderr(), result)
+		return ErrInterrupted
+	}
+	if err != nil {
+		printFileErrors(config.stderr(), result)
+		return err
+	}
+	if len(result.Errors) > 0 {
+		printFileErrors(config.stderr(), result)
+		return fmt.Errorf("dry run found %d error(s)", len(result.Errors))
+	}
+	if config.SkipIsError {
+		exists := 0
+		for _, file := range result.Files {
+			if file.SkipReason == converter.SkipReasonExists {
+				exists++
+			}
+		}
+		if exists > 0 {
+			return fmt.Errorf("%d file(s) would be skipped because their output %s", exists, converter.SkipReasonExists)
+		}
+	}
+	if result.TotalFiles == 0 && !config.JSON {
+		fmt.Fprintln(config.stdout(), noFilesMessage(result, kind))
+	}
+	return nil
+}
+
+// printPlan lists the files of a dry run with their outputs, and what would
+// be done with them
+func printPlan(w io.Writer, result *converter.ConversionResult) {
+	if len(result.Files) == 0 {
+		return
+	}
+
+	convert, skip := 0, 0
+	for _, file := range result.Files {
+		switch file.Status {
+		case converter.StatusSkipped:
+			skip++
+			fmt.Fprintf(w, "⏭️  %s → %s (skip: %s)\n", file.InputPath, file.OutputPath, file.SkipReason)
+		case converter.StatusFailed:
+			fmt.Fprintf(w, "❌ %s → %s (%v)\n", file.InputPath, file.OutputPath, file.Error)
+		case converter.StatusOverwritten:
+			convert++
+			fmt.Fprintf(w, "🔮 %s → %s (overwrite)\n", file.InputPath, file.OutputPath)
+		default:
+			convert++
+			fmt.Fprintf(w, "🔮 %s → %s\n", file.InputPath, file.OutputPath)
+		}
+	}
+	fmt.Fprintf(w, "📋 Dry run: %d file(s) would be converted, %d skipped; nothing was written\n", convert, skip)
+}
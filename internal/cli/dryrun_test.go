@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"avif2png/internal/converter"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ==================== Dry Run Tests ====================
+
+func TestParseFlags_WithDryRun(t *testing.T) {
+	config, err := ParseFlags([]string{"--dry-run", "--json", "--shard", "name", "my-images/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !config.DryRun || !config.JSON {
+		t.Errorf("expected a JSON dry run, got: %+v", config)
+	}
+	if opts := config.converterOptions(); !opts.DryRun {
+		t.Error("expected the dry run to reach the converter options")
+	}
+
+	for _, args := range [][]string{
+		{"--dry-run", "--auto-format", "my-images/"},
+		{"--dry-run", "--dedupe", "my-images/"},
+		{"--dry-run", "--min-width", "100", "my-images/"},
+		{"--dry-run", "-i", "my-images/"},
+		{"--dry-run", "--report", "report.html", "my-images/"},
+		{"--dry-run", "--exec", "echo {output}", "my-images/"},
+		{"--dry-run", "--pdf", "album.pdf", "my-images/"},
+	} {
+		if _, err := ParseFlags(args); err == nil || !strings.Contains(err.Error(), "--dry-run") {
+			t.Errorf("%v: expected a --dry-run error, got: %v", args, err)
+		}
+	}
+}
+
+func TestRun_DryRun(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	os.MkdirAll(inputDir, 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "b.avif"))
+
+	config := &Config{InputPath: inputDir, OutputDir: outputDir, DryRun: true, JSON: true}
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Error("expected a dry run to create nothing")
+	}
+
+	// Single files are planned too, even as JSON
+	config.InputPath = filepath.Join(inputDir, "a.avif")
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// Strict pipelines fail on outputs that would be skipped
+	os.MkdirAll(outputDir, 0755)
+	os.WriteFile(filepath.Join(outputDir, "a.png"), []byte("old"), 0644)
+	config = &Config{InputPath: inputDir, OutputDir: outputDir, DryRun: true, SkipIsError: true}
+	if err := Run(config); err == nil || !strings.Contains(err.Error(), "1 file(s) would be skipped") {
+		t.Errorf("expected the planned skip to fail the run, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "b.png")); !os.IsNotExist(err) {
+		t.Error("expected a dry run to convert nothing")
+	}
+}
+
+func TestPrintPlan(t *testing.T) {
+	result := &converter.ConversionResult{
+		Files: []converter.FileResult{
+			{InputPath: "in/a.avif", OutputPath: "out/a.png", Status: converter.StatusConverted},
+			{InputPath: "in/b.avif", OutputPath: "out/b.png", Status: converter.StatusOverwritten},
+			{InputPath: "in/c.avif", OutputPath: "out/c.png", Status: converter.StatusSkipped, SkipReason: converter.SkipReasonExists},
+			{InputPath: "in/d.avif", OutputPath: "out/d.png", Status: converter.StatusFailed, Error: errors.New("bad name")},
+		},
+	}
+
+	var buf bytes.Buffer
+	printPlan(&buf, result)
+	for _, want := range []string{
+		"in/a.avif → out/a.png\n",
+		"in/b.avif → out/b.png (overwrite)",
+		"in/c.avif → out/c.png (skip: already exists)",
+		"in/d.avif → out/d.png (bad name)",
+		"2 file(s) would be converted, 1 skipped",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in the plan, got:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// logTimeLayout is the timestamp at the start of each line of a --log-file
+const logTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// LogFile copies the console output of a run to a file, with a timestamp on
+// each line, while still writing it to the console
+type LogFile struct {
+	config         *Config
+	file           *os.File
+	console        [2]io.Writer
+	stdout, stderr *timestampWriter
+}
+
+// OpenLogFile opens config.LogFile, which is truncated unless
+// config.LogAppend is set, and until Close writes config.Stdout and
+// config.Stderr, and so the Options.Log of the conversions, to both the
+// console and the file
+func OpenLogFile(config *Config) (*LogFile, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if config.LogAppend {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	file, err := os.OpenFile(config.LogFile, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each stream keeps its own unfinished line, so the two never mix
+	mu := &sync.Mutex{}
+	l := &LogFile{
+		config:  config,
+		file:    file,
+		console: [2]io.Writer{config.Stdout, config.Stderr},
+		stdout:  &timestampWriter{mu: mu, w: file, now: time.Now},
+		stderr:  &timestampWriter{mu: mu, w: file, now: time.Now},
+	}
+	config.Stdout = io.MultiWriter(config.stdout(), l.stdout)
+	config.Stderr = io.MultiWriter(config.stderr(), l.stderr)
+	return l, nil
+}
+
+// Close writes the unfinished lines of both streams to the log, points the
+// output of config back at the console alone, and closes the log file
+func (l *LogFile) Close() error {
+	l.config.Stdout, l.config.Stderr = l.console[0], l.console[1]
+	l.stdout.flush()
+	l.stderr.flush()
+	return l.file.Close()
+}
+
+// stdout returns where the standard output of a run goes
+func (c *Config) stdout() io.Writer {
+	if c.Stdout != nil {
+		return c.Stdout
+	}
+	return os.Stdout
+}
+
+// stderr returns where the errors and warnings of a run go
+func (c *Config) stderr() io.Writer {
+	if c.Stderr != nil {
+		return c.Stderr
+	}
+	return os.Stderr
+}
+
+// timestampWriter writes whole lines to w, each with the time it was
+// started at; a line is held until it ends, and mu is shared by the writers
+// of one file, so the lines of several writers are kept apart
+// Write errors of the log are not returned, so the console output goes on
+type timestampWriter struct {
+	mu    *sync.Mutex
+	w     io.Writer
+	now   func() time.Time
+	line  []byte
+	start time.Time
+}
+
+func (t *timestampWriter) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := len(p)
+	for len(p) > 0 {
+		if len(t.line) == 0 {
+			t.start = t.now()
+		}
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			t.line = append(t.line, p...)
+			break
+		}
+		t.line = append(t.line, p[:i+1]...)
+		t.writeLine()
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// flush writes the held line, ending it if it is unfinished
+func (t *timestampWriter) flush() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.line) > 0 && t.line[len(t.line)-1] != '\n' {
+		t.line = append(t.line, '\n')
+	}
+	t.writeLine()
+}
+
+// writeLine writes the held line with its timestamp, with mu held
+func (t *timestampWriter) writeLine() {
+	if len(t.line) == 0 {
+		return
+	}
+	t.w.Write(append([]byte(t.start.Format(logTimeLayout)+" "), t.line...))
+	t.line = t.line[:0]
+}
@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ==================== LogFile Tests ====================
+
+func TestParseFlags_WithLogFile(t *testing.T) {
+	config, err := ParseFlags([]string{"--log-file", "convert.log", "--log-append", "my-images/"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.LogFile != "convert.log" || !config.LogAppend {
+		t.Errorf("expected an appended convert.log, got %q (append %v)", config.LogFile, config.LogAppend)
+	}
+
+	if _, err := ParseFlags([]string{"--log-append", "my-images/"}); err == nil {
+		t.Error("expected error for --log-append without --log-file")
+	}
+}
+
+func TestTimestampWriter(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	mu := &sync.Mutex{}
+	stdout := &timestampWriter{mu: mu, w: &buf, now: clock}
+	stderr := &timestampWriter{mu: mu, w: &buf, now: clock}
+
+	fmt.Fprint(stdout, "first ")
+	// Lines of the other stream never land in the middle of a line
+	fmt.Fprint(stderr, "warning\n")
+	fmt.Fprint(stdout, "line\nsecond line\nthird")
+	fmt.Fprint(stdout, " line\n")
+	fmt.Fprint(stderr, "unfinished")
+	stderr.flush()
+
+	want := "2024-05-01T12:30:00.000Z warning\n" +
+		"2024-05-01T12:30:00.000Z first line\n" +
+		"2024-05-01T12:30:00.000Z second line\n" +
+		"2024-05-01T12:30:00.000Z third line\n" +
+		"2024-05-01T12:30:00.000Z unfinished\n"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestOpenLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "convert.log")
+	if err := os.WriteFile(path, []byte("old run\n"), 0644); err != nil {
+		t.Fatalf("failed to write log: %v", err)
+	}
+
+	logTo := func(config *Config, stdout, stderr string) {
+		t.Helper()
+		logFile, err := OpenLogFile(config)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		fmt.Fprint(config.stdout(), stdout)
+		fmt.Fprint(config.stderr(), stderr)
+		if err := logFile.Close(); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+
+	var console bytes.Buffer
+	logTo(&Config{LogFile: path, Stdout: &console, Stderr: &console}, "✅ a.avif -> a.png\n", "❌ Error: b.avif\n")
+	if console.String() != "✅ a.avif -> a.png\n❌ Error: b.avif\n" {
+		t.Errorf("expected the output on the console too, got %q", console.String())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read log: %v", err)
+	}
+	log := string(data)
+	if strings.Contains(log, "old run") {
+		t.Errorf("expected the log to be truncated, got %q", log)
+	}
+	stamped := regexp.MustCompile(`(?m)^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{3}\S* (✅ a\.avif -> a\.png|❌ Error: b\.avif)$`)
+	if n := len(stamped.FindAllString(log, -1)); n != 2 {
+		t.Errorf("expected 2 timestamped lines, got %q", log)
+	}
+
+	logTo(&Config{LogFile: path, LogAppend: true, Stdout: &console, Stderr: &console}, "✅ c.avif -> c.png\n", "")
+	data, _ = os.ReadFile(path)
+	if !strings.Contains(string(data), "a.png") || !strings.Contains(string(data), "c.png") {
+		t.Errorf("expected the log to be appended to, got %q", data)
+	}
+}
+
+func TestOpenLogFile_RestoresConsole(t *testing.T) {
+	var console bytes.Buffer
+	config := &Config{LogFile: filepath.Join(t.TempDir(), "convert.log"), Stdout: &console}
+	logFile, err := OpenLogFile(config)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Stdout == io.Writer(&console) || config.Stderr == nil {
+		t.Error("expected the output to be copied to the log")
+	}
+	if opts := config.converterOptions(); opts.Log != config.Stdout {
+		t.Error("expected the conversions to write to the log too")
+	}
+	logFile.Close()
+	if config.Stdout != io.Writer(&console) || config.Stderr != nil {
+		t.Error("expected the console to be restored after Close")
+	}
+
+	if _, err := OpenLogFile(&Config{LogFile: filepath.Join(t.TempDir(), "missing", "convert.log")}); err == nil {
+		t.Error("expected error for a log file in a missing directory")
+	}
+}
+
+func TestRun_LogFile(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	os.MkdirAll(inputDir, 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+	logPath := filepath.Join(testDir, "convert.log")
+
+	// Verbose progress of the converter reaches the log through Options.Log,
+	// with each file on one line
+	var console bytes.Buffer
+	config := &Config{InputPath: inputDir, OutputDir: filepath.Join(testDir, "output"), Verbose: true, LogFile: logPath, Stdout: &console, Stderr: &console}
+	logFile, err := OpenLogFile(config)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	logFile.Close()
+
+	data, _ := os.ReadFile(logPath)
+	if !regexp.MustCompile(`(?m)^\S+ .*Converting .*a\.avif\.\.\. ✅`).Match(data) {
+		t.Errorf("expected the conversion of a.avif on one log line, got %q", data)
+	}
+	if !strings.Contains(console.String(), "a.avif") {
+		t.Errorf("expected the output on the console too, got %q", console.String())
+	}
+}
@@ -0,0 +1,211 @@
+package cli
+
+import (
+	"avif2png/internal/converter"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// mapToken matches a {N} token in the output template of a --map
+var mapToken = regexp.MustCompile(`\{[^{}]*\}`)
+
+// globMapping is a --map transform from an input glob to an output path
+// template, such as "in/**/*.avif=out/{1}/{2}.png"
+// Each wildcard of the glob (**, *, ? or a [class]) captures what it
+// matched, numbered from 1 left to right, for the {N} tokens of the output;
+// ** matches any number of directories, and captures them without the
+// trailing slash
+type globMapping struct {
+	input  string
+	output string
+	// root is the directory of the leading segments of the glob without
+	// wildcards, which is all that is scanned
+	root string
+	// depth is the number of segments a match has below root, or -1 when
+	// the glob has a ** and matches at any depth
+	depth int
+	// pattern matches the slash-separated paths of files relative to root
+	pattern *regexp.Regexp
+}
+
+// parseMapping parses a --map value of the form INPUT_GLOB=OUTPUT_TEMPLATE
+func parseMapping(spec string) (*globMapping, error) {
+	input, output, ok := strings.Cut(spec, "=")
+	if !ok || input == "" || output == "" {
+		return nil, fmt.Errorf("--map must be INPUT_GLOB=OUTPUT_TEMPLATE, got: %s", spec)
+	}
+
+	segments := strings.Split(filepath.ToSlash(input), "/")
+	literal := 0
+	for literal < len(segments) && !strings.ContainsAny(segments[literal], "*?[") {
+		literal++
+	}
+	if literal == len(segments) {
+		return nil, fmt.Errorf("--map input %s has no wildcards", input)
+	}
+
+	m := &globMapping{input: input, output: output, root: ".", depth: len(segments) - literal}
+	if literal > 0 {
+		// The root of "/photos/*.avif" is "/"
+		m.root = strings.Join(segments[:literal], "/")
+		if m.root == "" {
+			m.root = "/"
+		}
+		m.root = filepath.FromSlash(m.root)
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	groups := 0
+	rest := segments[literal:]
+	for i, segment := range rest {
+		last := i == len(rest)-1
+		if segment == "**" {
+			m.depth = -1
+			groups++
+			if last {
+				expr.WriteString("(.*)")
+			} else {
+				expr.WriteString("(?:(.*)/)?")
+			}
+			continue
+		}
+
+		n, err := translateSegment(&expr, segment)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --map input %s: %w", input, err)
+		}
+		groups += n
+		if !last {
+			expr.WriteString("/")
+		}
+	}
+	expr.WriteString("$")
+	// Classes are copied as written, so ranges such as [z-a] only fail here
+	pattern, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid --map input %s: %w", input, err)
+	}
+	m.pattern = pattern
+
+	for _, token := range mapToken.FindAllString(output, -1) {
+		n, err := strconv.Atoi(token[1 : len(token)-1])
+		if err != nil || n < 1 || n > groups {
+			return nil, fmt.Errorf("unknown --map output token %s (the input has %d wildcard(s), {1} to {%d})", token, groups, groups)
+		}
+	}
+	return m, nil
+}
+
+// translateSegment writes the regular expression of one segment of a glob
+// to expr and returns the number of wildcards it captures
+func translateSegment(expr *strings.Builder, segment string) (int, error) {
+	groups := 0
+	for i := 0; i < len(segment); i++ {
+		switch c := segment[i]; c {
+		case '*':
+			expr.WriteString("([^/]*)")
+		case '?':
+			expr.WriteString("([^/])")
+		case '[':
+			end := strings.IndexByte(segment[i+1:], ']')
+			if end < 0 {
+				return 0, errors.New("unterminated [ character class")
+			}
+			class := segment[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			if class == "" || class == "^" {
+				return 0, errors.New("empty [] character class")
+			}
+			expr.WriteString("([" + strings.ReplaceAll(class, `\`, `\\`) + "])")
+			i += end + 1
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+			continue
+		}
+		groups++
+	}
+	return groups, nil
+}
+
+// expand returns the files matching the input glob, in lexical order, each
+// with its output path
+// Files without one of exts are left out unless exts is empty
+func (m *globMapping) expand(exts []string) ([]converter.MappedFile, error) {
+	var files []converter.MappedFile
+	err := filepath.WalkDir(m.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, relErr := filepath.Rel(m.root, path)
+		if relErr != nil || rel == "." {
+			return relErr
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if m.depth >= 0 && strings.Count(rel, "/")+1 >= m.depth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		captures := m.pattern.FindStringSubmatch(rel)
+		if captures == nil || (len(exts) > 0 && !hasExtension(path, exts)) {
+			return nil
+		}
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			return nil
+		}
+
+		output := mapToken.ReplaceAllStringFunc(m.output, func(token string) string {
+			n, _ := strconv.Atoi(token[1 : len(token)-1])
+			return captures[n]
+		})
+		files = append(files, converter.MappedFile{InputPath: path, OutputPath: filepath.Clean(filepath.FromSlash(output))})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", m.root, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no %s files match %s", inputKind(exts), m.input)
+	}
+	return files, nil
+}
+
+// runMappedConversion converts the files matching the input glob of --map
+// to the paths given by its output template
+func runMappedConversion(ctx context.Context, config *Config) error {
+	mapping, err := parseMapping(config.Map)
+	if err != nil {
+		return err
+	}
+	files, err := mapping.expand(config.inputExtensions())
+	if err != nil {
+		return err
+	}
+
+	if config.Verbose {
+		fmt.Fprintf(config.stdout(), "🔎 Mapping %s matched %d file(s)\n", mapping.input, len(files))
+	}
+
+	opts := config.converterOptions()
+	opts.Prompt = config.overwritePrompt()
+	if config.OnCollision == CollisionWarn || config.OnCollision == CollisionError {
+		if err := checkCollisions(config, converter.FindMappedConflicts(files, opts)); err != nil {
+			return err
+		}
+	}
+
+	result, err := converter.ConvertMapped(ctx, files, opts)
+	return reportConversion(config, result, err, "mapping")
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ==================== Mapping Tests ====================
+
+func TestParseMapping(t *testing.T) {
+	m, err := parseMapping("in/**/*.avif=out/{1}/{2}.png")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if m.root != "in" || m.depth != -1 {
+		t.Errorf("expected root in at any depth, got %q, %d", m.root, m.depth)
+	}
+
+	m, err = parseMapping("shots/day-?/img_[0-9]*.avif=flat/{1}-{2}{3}.png")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if m.root != "shots" || m.depth != 2 {
+		t.Errorf("expected root shots at depth 2, got %q, %d", m.root, m.depth)
+	}
+
+	for _, spec := range []string{
+		"in/*.avif",
+		"=out/{1}.png",
+		"in/*.avif=",
+		"in/a.avif=out/a.png",
+		"in/*.avif=out/{2}.png",
+		"in/*.avif=out/{0}.png",
+		"in/*.avif=out/{name}.png",
+		"in/[a-.avif=out/{1}.png",
+		"x/[z-a].avif=out/{1}.png",
+	} {
+		if _, err := parseMapping(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestGlobMapping_Expand(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "in")
+	for _, path := range []string{"a.avif", "b.txt", "x/c.avif", "x/y/d.AVIF"} {
+		path = filepath.Join(inputDir, filepath.FromSlash(path))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("failed to create test file: %v", err)
+		}
+	}
+
+	m, err := parseMapping(filepath.ToSlash(inputDir) + "/**/*=" + filepath.ToSlash(testDir) + "/out/{1}/{2}.png")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	files, err := m.expand([]string{".avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := map[string]string{
+		filepath.Join(inputDir, "a.avif"):           filepath.Join(testDir, "out", "a.avif.png"),
+		filepath.Join(inputDir, "x", "c.avif"):      filepath.Join(testDir, "out", "x", "c.avif.png"),
+		filepath.Join(inputDir, "x", "y", "d.AVIF"): filepath.Join(testDir, "out", "x", "y", "d.AVIF.png"),
+	}
+	if len(files) != len(want) {
+		t.Fatalf("expected %d files, got %v", len(want), files)
+	}
+	for _, file := range files {
+		if want[file.InputPath] != file.OutputPath {
+			t.Errorf("expected %s -> %s, got %s", file.InputPath, want[file.InputPath], file.OutputPath)
+		}
+	}
+
+	// Without **, only files at the depth of the glob match
+	m, _ = parseMapping(filepath.ToSlash(inputDir) + "/*/*.avif=" + filepath.ToSlash(testDir) + "/out/{1}_{2}.png")
+	files, err = m.expand([]string{".avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(files) != 1 || files[0].OutputPath != filepath.Join(testDir, "out", "x_c.png") {
+		t.Errorf("expected x/c.avif -> x_c.png, got %v", files)
+	}
+
+	m, _ = parseMapping(filepath.ToSlash(inputDir) + "/*.jpg=out/{1}.png")
+	if _, err := m.expand([]string{".avif"}); err == nil {
+		t.Error("expected error when nothing matches")
+	}
+}
+
+func TestRun_Map(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "in")
+	os.MkdirAll(filepath.Join(inputDir, "2024"), 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "2024", "beach.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "top.avif"))
+
+	config, err := ParseFlags([]string{"--map", filepath.ToSlash(inputDir) + "/**/*.avif=" + filepath.ToSlash(testDir) + "/out/{1}/photo-{2}.png"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	for _, path := range []string{filepath.Join("2024", "photo-beach.png"), "photo-top.png"} {
+		if _, err := os.Stat(filepath.Join(testDir, "out", path)); err != nil {
+			t.Errorf("expected %s to exist, got: %v", path, err)
+		}
+	}
+}
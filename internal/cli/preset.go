@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"avif2png/internal/converter"
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PresetFileName is the name of the project preset file, looked up in the
+// directory of the input and then in each of its parents
+const PresetFileName = "avif2png.preset"
+
+// presetPathFlags are the flags whose values in a preset are relative to
+// the directory of the preset, not to the working directory, so a preset
+// works from wherever the tool is run
+// They must stay within that directory: a preset next to a downloaded input
+// cannot write anywhere else
+var presetPathFlags = map[string]bool{
+	"output":      true,
+	"report":      true,
+	"pdf":         true,
+	"spritesheet": true,
+}
+
+// presetNameChecks check the preset values of the flags that shape output
+// paths below the output directory, so they cannot climb out of it either
+var presetNameChecks = map[string]func(string) error{
+	"output-template":   converter.ValidateOutputTemplate,
+	"flatten-separator": converter.ValidateFlattenSeparator,
+}
+
+// presetExcluded are the flags a preset cannot set: commands, the options
+// that choose what to convert or how the preset is found, and those a
+// preset next to a downloaded input could abuse: --exec would run its
+// commands, --overwrite and --on-error would replace or move files, and
+// --log-file and --progress-addr would write or listen outside the project
+var presetExcluded = map[string]bool{
+	"exec":           true,
+	"version":        true,
+	"no-preset":      true,
+	"map":            true,
+	"spec":           true,
+	"benchmark":      true,
+	"overwrite":      true,
+	"on-error":       true,
+	"quarantine-dir": true,
+	"log-file":       true,
+	"progress-addr":  true,
+}
+
+// presetShorthands are the shorthands of the flags a preset can set, which
+// override the preset as their long names do; -f is in profileOverrides
+var presetShorthands = map[string]string{
+	"output":      "o",
+	"recursive":   "r",
+	"interactive": "i",
+	"verbose":     "v",
+}
+
+// presetSetting is an option = value line of a preset file
+type presetSetting struct {
+	line  int
+	flag  string
+	value string
+}
+
+// findPreset returns the path of the nearest preset file, in the directory
+// of input (input itself when it is a directory) or in one of its parents,
+// or "" when there is none
+// Inputs that do not exist, such as glob patterns, start the search at
+// their parent directory
+func findPreset(input string) (string, error) {
+	dir, err := filepath.Abs(input)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+
+	for {
+		path := filepath.Join(dir, PresetFileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// parsePreset reads the settings of a preset file: an "option = value" line
+// per setting, options being long flag names without dashes, such as
+// "format = jpeg" or "recursive = true"; blank lines and lines starting
+// with # or ; are ignored, and repeated options are set again, as repeated
+// flags are
+func parsePreset(r io.Reader) ([]presetSetting, error) {
+	var settings []presetSetting
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimPrefix(strings.TrimSpace(name), "--")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("line %d: expected option = value, got: %s", n, line)
+		}
+		settings = append(settings, presetSetting{line: n, flag: name, value: strings.TrimSpace(value)})
+	}
+	return settings, scanner.Err()
+}
+
+// applyPreset sets the flags of the preset file at path that were not given
+// on the command line or by --profile, so both still take precedence; values
+// from the environment are replaced
+func applyPreset(fs *flag.FlagSet, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open preset: %w", err)
+	}
+	defer file.Close()
+
+	settings, err := parsePreset(file)
+	if err != nil {
+		return fmt.Errorf("invalid preset %s: %w", path, err)
+	}
+
+	// Flags set before the preset keep their values; those the preset sets
+	// are not in this list, so repeated options all apply
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	for _, setting := range settings {
+		if fs.Lookup(setting.flag) == nil || len(setting.flag) == 1 || presetExcluded[setting.flag] {
+			return fmt.Errorf("invalid preset %s: line %d: unknown option %q", path, setting.line, setting.flag)
+		}
+		if given[setting.flag] || anyGiven(given, profileOverrides[setting.flag]) || given[presetShorthands[setting.flag]] {
+			continue
+		}
+		value := setting.value
+		if presetPathFlags[setting.flag] && value != "" {
+			if !filepath.IsLocal(value) || strings.HasPrefix(value, "~") {
+				return fmt.Errorf("invalid preset %s: line %d: %s must be a path within the directory of the preset, got: %s", path, setting.line, setting.flag, value)
+			}
+			value = filepath.Join(filepath.Dir(path), value)
+		}
+		if check := presetNameChecks[setting.flag]; check != nil {
+			if err := check(value); err != nil {
+				return fmt.Errorf("invalid preset %s: line %d: %s: %w", path, setting.line, setting.flag, err)
+			}
+		}
+		if err := fs.Set(setting.flag, value); err != nil {
+			return fmt.Errorf("invalid preset %s: line %d: %s=%s: %w", path, setting.line, setting.flag, setting.value, err)
+		}
+	}
+	return nil
+}
+
+// anyGiven reports whether any of names is in given
+func anyGiven(given map[string]bool, names []string) bool {
+	for _, name := range names {
+		if given[name] {
+			return true
+		}
+	}
+	return false
+}
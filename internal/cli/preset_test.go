@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePreset writes a preset file with the given lines into dir
+func writePreset(t *testing.T, dir string, lines ...string) string {
+	t.Helper()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	path := filepath.Join(dir, PresetFileName)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("failed to write preset: %v", err)
+	}
+	return path
+}
+
+// ==================== findPreset Tests ====================
+
+func TestFindPreset(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	project := filepath.Join(testDir, "project")
+	nested := filepath.Join(project, "images", "2024")
+	os.MkdirAll(nested, 0755)
+	createTestAVIF(t, filepath.Join(nested, "photo.avif"))
+	projectPreset := writePreset(t, project, "format = jpeg")
+
+	for _, input := range []string{
+		project,
+		nested,
+		filepath.Join(nested, "photo.avif"),
+		filepath.Join(nested, "*.avif"),
+	} {
+		path, err := findPreset(input)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if path != projectPreset {
+			t.Errorf("%s: expected %s, got %q", input, projectPreset, path)
+		}
+	}
+
+	// The nearest preset wins
+	nestedPreset := writePreset(t, nested, "format = webp")
+	if path, err := findPreset(filepath.Join(nested, "photo.avif")); err != nil || path != nestedPreset {
+		t.Errorf("expected %s, got %q, %v", nestedPreset, path, err)
+	}
+
+	// Directories named like presets are not presets
+	other := filepath.Join(testDir, "other")
+	os.MkdirAll(filepath.Join(other, PresetFileName), 0755)
+	if path, err := findPreset(other); err != nil || strings.HasPrefix(path, other) {
+		t.Errorf("expected no preset in %s, got %q, %v", other, path, err)
+	}
+}
+
+// ==================== parsePreset Tests ====================
+
+func TestParsePreset(t *testing.T) {
+	settings, err := parsePreset(strings.NewReader("# Web images\n\nformat = jpeg\n; lossy\n--quality=70\n  recursive =  \n"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := []presetSetting{{3, "format", "jpeg"}, {5, "quality", "70"}, {6, "recursive", ""}}
+	if len(settings) != len(want) {
+		t.Fatalf("expected %d settings, got: %+v", len(want), settings)
+	}
+	for i, setting := range settings {
+		if setting != want[i] {
+			t.Errorf("expected %+v, got %+v", want[i], setting)
+		}
+	}
+
+	if _, err := parsePreset(strings.NewReader("format = jpeg\nrecursive\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error for line 2, got: %v", err)
+	}
+	if _, err := parsePreset(strings.NewReader("= jpeg\n")); err == nil {
+		t.Error("expected an error for a setting without an option")
+	}
+}
+
+// ==================== Preset Tests ====================
+
+func TestParseFlags_WithPreset(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	project := filepath.Join(testDir, "project")
+	preset := writePreset(t, project,
+		"# Shared by the whole team",
+		"format = jpeg",
+		"quality = 70",
+		"output = converted",
+		"recursive = true",
+		"report = reports/report.json",
+	)
+
+	config, err := ParseFlags([]string{filepath.Join(project, "images")})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Preset != preset {
+		t.Errorf("expected preset %s, got %q", preset, config.Preset)
+	}
+	if config.Format != "jpeg" || config.Quality != 70 || !config.Recursive {
+		t.Errorf("expected the preset options, got: %+v", config)
+	}
+	if want := filepath.Join(project, "converted"); config.OutputDir != want {
+		t.Errorf("expected output relative to the preset %s, got: %s", want, config.OutputDir)
+	}
+	if want := filepath.Join(project, "reports", "report.json"); config.ReportPath != want {
+		t.Errorf("expected the report relative to the preset %s, got: %s", want, config.ReportPath)
+	}
+}
+
+func TestParseFlags_PresetPrecedence(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	writePreset(t, testDir, "quality = 70", "output = preset-output", "recursive = false", "max-output-size = 2MB", "profile = web")
+	env := map[string]string{"AVIF2PNG_MAX_OUTPUT_SIZE": "1MB"}
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	config, err := parseFlags([]string{"-o", "./flag-output", "-r", testDir}, lookupEnv)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.OutputDir != "./flag-output" || !config.Recursive {
+		t.Errorf("expected the shorthand flags to override the preset, got output %s, recursive %v", config.OutputDir, config.Recursive)
+	}
+	if config.MaxOutputSize != 2*1000*1000 {
+		t.Errorf("expected the preset to override the environment, got %d bytes", config.MaxOutputSize)
+	}
+	// The profile of the preset fills in what the preset leaves out
+	if config.Profile != "web" || config.Quality != 70 || config.Format != "jpeg" || !config.StripMetadata {
+		t.Errorf("expected the preset over its web profile, got: %+v", config)
+	}
+
+	// --profile comes before the preset and its profile
+	config, err = parseFlags([]string{"--profile", "archive", testDir}, lookupEnv)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Profile != "archive" || config.Format != "png" || config.StripMetadata {
+		t.Errorf("expected the archive profile, got: %+v", config)
+	}
+}
+
+func TestParseFlags_PresetInvalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown option":  "colour = red",
+		"shorthand":       "r = true",
+		"command":         "version = true",
+		"exec":            "exec = rm {input}",
+		"invalid value":   "quality = high",
+		"no value":        "format",
+		"unknown profile": "profile = print",
+		"overwrite":       "overwrite = true",
+		"on-error":        "on-error = quarantine",
+		"quarantine-dir":  "quarantine-dir = broken",
+		"log-file":        "log-file = avif2png.log",
+		"progress-addr":   "progress-addr = :8080",
+		"absolute path":   "output = /tmp/converted",
+		"home path":       "report = ~/report.json",
+		"parent path":     "pdf = ../album.pdf",
+		"escaping path":   "spritesheet = sprites/../../sheet.png",
+		"parent template": "output-template = ../../../pwned",
+		"path separator":  "flatten-separator = /../../",
+	}
+	for name, line := range tests {
+		testDir := setupTestDir(t)
+		writePreset(t, testDir, line)
+		if _, err := ParseFlags([]string{testDir}); err == nil || !strings.Contains(err.Error(), PresetFileName) {
+			t.Errorf("%s: expected an error naming the preset, got: %v", name, err)
+		}
+		os.RemoveAll(testDir)
+	}
+}
+
+func TestParseFlags_PresetOutputTemplate(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	// The template is checked when the preset is read, naming its line,
+	// before anything is converted
+	writePreset(t, testDir, "format = jpeg", "output-template = ../../../pwned")
+	_, err := ParseFlags([]string{testDir})
+	if err == nil || !strings.Contains(err.Error(), "line 2: output-template") {
+		t.Fatalf("expected an error for line 2 of the preset, got: %v", err)
+	}
+
+	writePreset(t, testDir, "output-template = {yyyy}/{mm}")
+	config, err := ParseFlags([]string{testDir})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.OutputTemplate != "{yyyy}/{mm}" {
+		t.Errorf("expected the preset template, got: %q", config.OutputTemplate)
+	}
+}
+
+func TestParseFlags_NoPreset(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	writePreset(t, testDir, "format = jpeg")
+	config, err := ParseFlags([]string{"--no-preset", testDir})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Preset != "" || config.Format != "png" {
+		t.Errorf("expected the preset to be ignored, got: %+v", config)
+	}
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// profiles are the bundles of flag values set by --profile, by name
+// Resizing comes from --max-output-size, which downscales PNGs to fit
+var profiles = map[string][]profileSetting{
+	// Small JPEGs for pages and uploads
+	"web": {
+		{"format", "jpeg"},
+		{"quality", "80"},
+		{"max-output-size", "500KB"},
+		{"strip-metadata", "true"},
+	},
+	// Lossless PNGs, checked and dated like their inputs
+	"archive": {
+		{"format", "png"},
+		{"optimize", "true"},
+		{"verify", "true"},
+		{"preserve-mtime", "true"},
+	},
+	// PNGs downscaled and sharpened to fit in 64 KB
+	"thumbnail": {
+		{"format", "png"},
+		{"max-output-size", "64KB"},
+		{"sharpen", "true"},
+		{"strip-metadata", "true"},
+	},
+}
+
+// profileSetting is a flag value set by a profile
+type profileSetting struct {
+	flag  string
+	value string
+}
+
+// profileOverrides are the flags, other than a setting's own, that keep a
+// profile from applying that setting, since they choose it another way
+var profileOverrides = map[string][]string{
+	"format":  {"f", "gif", "to-avif", "auto-format"},
+	"quality": {"to-avif"},
+}
+
+// ProfileNames returns the names of the --profile bundles, sorted
+func ProfileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyProfile sets the flags of the named profile that were not given on
+// the command line, which still take precedence; values from the
+// environment are replaced
+func applyProfile(fs *flag.FlagSet, name string) error {
+	settings, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown --profile %q (available: %s)", name, strings.Join(ProfileNames(), ", "))
+	}
+
+	for _, setting := range settings {
+		if flagSet(fs, append([]string{setting.flag}, profileOverrides[setting.flag]...)...) {
+			continue
+		}
+		if err := fs.Set(setting.flag, setting.value); err != nil {
+			return fmt.Errorf("invalid --profile %s setting %s=%s: %w", name, setting.flag, setting.value, err)
+		}
+	}
+	return nil
+}
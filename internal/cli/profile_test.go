@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"testing"
+)
+
+// ==================== Profile Tests ====================
+
+func TestParseFlags_WithProfile(t *testing.T) {
+	config, err := ParseFlags([]string{"--profile", "web", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Profile != "web" || config.Format != "jpeg" || config.Quality != 80 || config.MaxOutputSize != 500*1000 || !config.StripMetadata {
+		t.Errorf("expected the web profile, got: %+v", config)
+	}
+}
+
+func TestParseFlags_ProfileOverrides(t *testing.T) {
+	env := map[string]string{"AVIF2PNG_MAX_OUTPUT_SIZE": "1MB"}
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	config, err := parseFlags([]string{"--profile", "web", "--quality", "95", "image.avif"}, lookupEnv)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Quality != 95 {
+		t.Errorf("expected the flag to override the profile, got quality %d", config.Quality)
+	}
+	if config.MaxOutputSize != 500*1000 {
+		t.Errorf("expected the profile to override the environment, got %d bytes", config.MaxOutputSize)
+	}
+
+	config, err = ParseFlags([]string{"--profile", "web", "--gif", "image.avif"})
+	if err != nil {
+		t.Fatalf("expected --gif to replace the profile format, got: %v", err)
+	}
+	if config.Format != "gif" {
+		t.Errorf("expected gif, got: %s", config.Format)
+	}
+}
+
+func TestParseFlags_AllProfiles(t *testing.T) {
+	for _, name := range ProfileNames() {
+		if _, err := ParseFlags([]string{"--profile", name, "image.avif"}); err != nil {
+			t.Errorf("expected profile %s to be valid, got: %v", name, err)
+		}
+	}
+	if _, err := ParseFlags([]string{"--profile", "print", "image.avif"}); err == nil {
+		t.Error("expected an unknown profile to be rejected")
+	}
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"avif2png/internal/converter"
+	"avif2png/internal/report"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// unixPrefix marks a --progress-addr that is a Unix socket path
+const unixPrefix = "unix:"
+
+// progressServer reports the progress of a conversion over HTTP
+type progressServer struct {
+	listener net.Listener
+	server   *http.Server
+}
+
+// serveProgress starts serving the progress of result as JSON at /status on
+// addr, a TCP address such as "localhost:8080" or "unix:" followed by a
+// socket path
+// The server runs until Close is called
+func serveProgress(addr string, result *converter.ConversionResult) (*progressServer, error) {
+	network := "tcp"
+	if strings.HasPrefix(addr, unixPrefix) {
+		network, addr = "unix", strings.TrimPrefix(addr, unixPrefix)
+	}
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on --progress-addr: %w", err)
+	}
+
+	start := time.Now()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		report.WriteProgressJSON(w, result, time.Since(start), 0)
+	})
+
+	s := &progressServer{
+		listener: listener,
+		server:   &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second},
+	}
+	go s.server.Serve(listener)
+	return s, nil
+}
+
+// Location describes where the status endpoint is served, e.g.
+// "http://127.0.0.1:8080/status"
+func (s *progressServer) Location() string {
+	addr := s.listener.Addr()
+	if addr.Network() == "unix" {
+		return "/status on Unix socket " + addr.String()
+	}
+	return "http://" + addr.String() + "/status"
+}
+
+// Close shuts the server down, letting requests in flight finish for up to
+// a second; a Unix socket is removed
+func (s *progressServer) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.server.Shutdown(ctx)
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"avif2png/internal/converter"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// getStatus fetches and decodes the /status document of a progress server
+// through client
+func getStatus(t *testing.T, client *http.Client, url string) map[string]any {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON content type, got %q", ct)
+	}
+	var status map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("expected valid JSON, got: %v", err)
+	}
+	return status
+}
+
+// ==================== Progress Server Tests ====================
+
+func TestServeProgress(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "b.avif"))
+
+	result := &converter.ConversionResult{}
+	server, err := serveProgress("127.0.0.1:0", result)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer server.Close()
+
+	if status := getStatus(t, http.DefaultClient, server.Location()); status["total"] != 0.0 {
+		t.Errorf("expected no files before the conversion, got: %v", status)
+	}
+
+	if err := converter.ConvertDirectoryInto(context.Background(), inputDir, filepath.Join(testDir, "output"), converter.Options{}, result); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	status := getStatus(t, http.DefaultClient, server.Location())
+	if status["processed"] != 2.0 || status["total"] != 2.0 || status["progress"] != 1.0 {
+		t.Errorf("expected 2/2 files processed, got: %v", status)
+	}
+	if _, ok := status["elapsed_ms"]; !ok {
+		t.Errorf("expected elapsed_ms, got: %v", status)
+	}
+
+	server.Close()
+	if _, err := http.Get(server.Location()); err == nil {
+		t.Error("expected the server to be shut down")
+	}
+}
+
+func TestServeProgress_UnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix sockets are not tested on Windows")
+	}
+
+	// Socket paths are limited to about 100 bytes, too short for t.TempDir
+	dir, err := os.MkdirTemp("", "avif2png")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "progress.sock")
+
+	server, err := serveProgress(unixPrefix+socket, &converter.ConversionResult{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	if status := getStatus(t, client, "http://progress/status"); status["processed"] != 0.0 {
+		t.Errorf("expected no files processed, got: %v", status)
+	}
+
+	server.Close()
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("expected the socket to be removed, got: %v", err)
+	}
+}
+
+func TestServeProgress_InvalidAddr(t *testing.T) {
+	if _, err := serveProgress("not an address", &converter.ConversionResult{}); err == nil {
+		t.Error("expected an error for an invalid address")
+	}
+}
+
+func TestRun_ProgressAddr(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	inputPath := filepath.Join(inputDir, "a.avif")
+	createTestAVIF(t, inputPath)
+
+	config := &Config{InputPath: inputDir, OutputDir: filepath.Join(testDir, "output"), ProgressAddr: "127.0.0.1:0"}
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	config.InputPath = inputPath
+	if err := Run(config); err == nil {
+		t.Error("expected an error for a single file input")
+	}
+}
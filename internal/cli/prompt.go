@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"avif2png/internal/converter"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// isTerminal reports whether f is attached to a terminal rather than a pipe or file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// newOverwritePrompt returns a prompt that asks on out and reads answers from in
+// Unrecognised answers are asked again; end of input skips the file
+func newOverwritePrompt(in io.Reader, out io.Writer) converter.OverwritePrompt {
+	scanner := bufio.NewScanner(in)
+
+	return func(outputPath string) converter.OverwriteDecision {
+		for {
+			fmt.Fprintf(out, "%s already exists. Overwrite? [y]es/[n]o/[a]ll/[q]uit: ", outputPath)
+			if !scanner.Scan() {
+				fmt.Fprintln(out)
+				return converter.OverwriteNo
+			}
+
+			switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+			case "y", "yes":
+				return converter.OverwriteYes
+			case "n", "no":
+				return converter.OverwriteNo
+			case "a", "all":
+				return converter.OverwriteAll
+			case "q", "quit":
+				return converter.OverwriteQuit
+			}
+		}
+	}
+}
+
+// overwritePrompt returns the prompt used for --interactive, or nil if
+// prompting is disabled
+// Prompting requires a terminal on stdin; otherwise existing files are skipped
+func (c *Config) overwritePrompt() converter.OverwritePrompt {
+	if !c.Interactive {
+		return nil
+	}
+
+	if !isTerminal(os.Stdin) {
+		fmt.Fprintln(c.stderr(), "⚠️  stdin is not a terminal, existing files will be skipped")
+		return nil
+	}
+
+	// Prompts go to stderr so they never mix with JSON output
+	return newOverwritePrompt(os.Stdin, os.Stderr)
+}
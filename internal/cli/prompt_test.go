@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"avif2png/internal/converter"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ==================== Overwrite Prompt Tests ====================
+
+func TestOverwritePrompt_Answers(t *testing.T) {
+	tests := []struct {
+		input string
+		want  converter.OverwriteDecision
+	}{
+		{"y\n", converter.OverwriteYes},
+		{"YES\n", converter.OverwriteYes},
+		{"n\n", converter.OverwriteNo},
+		{"a\n", converter.OverwriteAll},
+		{"q\n", converter.OverwriteQuit},
+		{"maybe\nq\n", converter.OverwriteQuit},
+		{"", converter.OverwriteNo},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		prompt := newOverwritePrompt(strings.NewReader(tt.input), &out)
+
+		if got := prompt("out/image.png"); got != tt.want {
+			t.Errorf("input %q: expected decision %d, got: %d", tt.input, tt.want, got)
+		}
+		if !strings.Contains(out.String(), "out/image.png already exists") {
+			t.Errorf("input %q: expected prompt to name the file, got: %q", tt.input, out.String())
+		}
+	}
+}
+
+func TestOverwritePrompt_ReadsSuccessiveAnswers(t *testing.T) {
+	var out bytes.Buffer
+	prompt := newOverwritePrompt(strings.NewReader("y\nn\n"), &out)
+
+	if got := prompt("a.png"); got != converter.OverwriteYes {
+		t.Errorf("expected first answer to be yes, got: %d", got)
+	}
+	if got := prompt("b.png"); got != converter.OverwriteNo {
+		t.Errorf("expected second answer to be no, got: %d", got)
+	}
+}
+
+func TestOverwritePrompt_DisabledWithoutInteractive(t *testing.T) {
+	config := &Config{}
+	if config.overwritePrompt() != nil {
+		t.Error("expected no prompt without --interactive")
+	}
+}
+
+func TestIsTerminal_RegularFile(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "stdin"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer file.Close()
+
+	if isTerminal(file) {
+		t.Error("expected a regular file not to be a terminal")
+	}
+}
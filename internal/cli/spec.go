@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"avif2png/internal/converter"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// specJob is a job of a --spec, an input and its output path with options
+// that override those given on the command line
+// Fields are pointers so that a zero value, such as "trim": false, still
+// overrides the flag
+type specJob struct {
+	Input    string  `json:"input"`
+	Output   string  `json:"output"`
+	Format   *string `json:"format"`
+	Quality  *int    `json:"quality"`
+	Canvas   *string `json:"canvas"`
+	PadColor *string `json:"pad_color"`
+	CropPct  *string `json:"crop_pct"`
+	Rotate   *int    `json:"rotate"`
+	Trim     *bool   `json:"trim"`
+}
+
+// batchSpec is the JSON document read by --spec
+type batchSpec struct {
+	Jobs []specJob `json:"jobs"`
+}
+
+// parseSpec decodes a --spec document from r into the jobs it lists, each
+// with the options of config overridden by its own
+// Unknown fields are rejected, so that a misspelled option is not silently
+// ignored
+func parseSpec(r io.Reader, config *Config) ([]converter.Job, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var spec batchSpec
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("invalid --spec: %w", err)
+	}
+	if len(spec.Jobs) == 0 {
+		return nil, errors.New("--spec lists no jobs")
+	}
+
+	base := config.converterOptions()
+	base.Prompt = config.overwritePrompt()
+	jobs := make([]converter.Job, len(spec.Jobs))
+	for i, job := range spec.Jobs {
+		opts, err := job.options(base, config.ToAVIF)
+		if err != nil {
+			return nil, fmt.Errorf("--spec job %d: %w", i+1, err)
+		}
+		jobs[i] = converter.Job{
+			InputPath:  filepath.FromSlash(job.Input),
+			OutputPath: filepath.Clean(filepath.FromSlash(job.Output)),
+			Options:    opts,
+		}
+	}
+	return jobs, nil
+}
+
+// options returns base with the options set by j, checked like their flags
+func (j specJob) options(base converter.Options, toAVIF bool) (converter.Options, error) {
+	if j.Input == "" || j.Output == "" {
+		return base, errors.New("input and output are required")
+	}
+
+	opts := base
+	if j.Format != nil {
+		format := strings.ToLower(*j.Format)
+		if !isKnownFormat(format) {
+			return base, fmt.Errorf("unsupported output format %q (supported: %s)", *j.Format, strings.Join(converter.Formats(), ", "))
+		}
+		if format == "avif" && !toAVIF {
+			return base, errors.New("AVIF output requires --to-avif, which converts PNG and JPEG inputs")
+		}
+		if format != "avif" && toAVIF {
+			return base, fmt.Errorf("--to-avif only writes AVIF, got: %s", format)
+		}
+		opts.Format, opts.AutoFormat = format, false
+	}
+	if j.Quality != nil {
+		if *j.Quality < 1 || *j.Quality > 100 {
+			return base, fmt.Errorf("quality must be between 1 and 100, got: %d", *j.Quality)
+		}
+		opts.Quality = *j.Quality
+	}
+	if j.Canvas != nil {
+		canvas, err := parseCanvas(*j.Canvas)
+		if err != nil {
+			return base, err
+		}
+		opts.Canvas = canvas
+	}
+	if j.PadColor != nil {
+		pad, err := parsePadColor(*j.PadColor)
+		if err != nil {
+			return base, err
+		}
+		opts.PadColor = pad
+	}
+	if j.CropPct != nil {
+		crop, err := parseCropPct(*j.CropPct)
+		if err != nil {
+			return base, err
+		}
+		opts.CropPct = crop
+	}
+	if j.Rotate != nil {
+		if !converter.IsValidRotation(*j.Rotate) {
+			return base, fmt.Errorf("rotate must be 0, 90, 180 or 270, got: %d", *j.Rotate)
+		}
+		opts.Rotate = *j.Rotate
+	}
+	if j.Trim != nil {
+		opts.Trim = *j.Trim
+	}
+	return opts, nil
+}
+
+// runSpecConversion converts the jobs of the --spec document, read from
+// stdin for "-", and reports them together like a directory conversion
+func runSpecConversion(ctx context.Context, config *Config) error {
+	var r io.Reader = os.Stdin
+	if config.Spec != "-" {
+		f, err := os.Open(config.Spec)
+		if err != nil {
+			return fmt.Errorf("failed to open --spec: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	jobs, err := parseSpec(r, config)
+	if err != nil {
+		return err
+	}
+	if config.Verbose {
+		fmt.Fprintf(config.stdout(), "📋 Spec lists %d job(s)\n", len(jobs))
+	}
+
+	result, err := converter.ConvertJobs(ctx, jobs)
+	return reportConversion(config, result, err, "spec")
+}
@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ==================== Spec Tests ====================
+
+func TestParseSpec(t *testing.T) {
+	config, err := ParseFlags([]string{"--spec", "-", "--quality", "80", "--trim"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	jobs, err := parseSpec(strings.NewReader(`{"jobs": [
+		{"input": "in/a.avif", "output": "out/a.png"},
+		{"input": "in/b.avif", "output": "out/b.jpg", "format": "jpeg", "quality": 40, "canvas": "64x64", "pad_color": "ffffff", "crop_pct": "10,10,90,90", "rotate": 90, "trim": false}
+	]}`), config)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(jobs))
+	}
+
+	first := jobs[0].Options
+	if first.Format != "png" || first.Quality != 80 || !first.Trim {
+		t.Errorf("expected the first job to keep the flags, got %+v", first)
+	}
+	second := jobs[1].Options
+	if second.Format != "jpeg" || second.Quality != 40 || second.Trim || second.Rotate != 90 || second.Canvas != image.Pt(64, 64) || second.CropPct.Left != 10 {
+		t.Errorf("expected the second job to override the flags, got %+v", second)
+	}
+	if jobs[1].OutputPath != filepath.Join("out", "b.jpg") {
+		t.Errorf("expected output out/b.jpg, got %s", jobs[1].OutputPath)
+	}
+}
+
+func TestParseSpec_Invalid(t *testing.T) {
+	config, err := ParseFlags([]string{"--spec", "-"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	for _, spec := range []string{
+		`{"jobs": []}`,
+		`not json`,
+		`{"jobs": [{"input": "a.avif"}]}`,
+		`{"jobs": [{"input": "a.avif", "output": "a.png", "qualty": 80}]}`,
+		`{"jobs": [{"input": "a.avif", "output": "a.png", "format": "bmp"}]}`,
+		`{"jobs": [{"input": "a.avif", "output": "a.avif", "format": "avif"}]}`,
+		`{"jobs": [{"input": "a.avif", "output": "a.png", "quality": 0}]}`,
+		`{"jobs": [{"input": "a.avif", "output": "a.png", "canvas": "big"}]}`,
+		`{"jobs": [{"input": "a.avif", "output": "a.png", "rotate": 45}]}`,
+	} {
+		if _, err := parseSpec(strings.NewReader(spec), config); err == nil {
+			t.Errorf("expected error for %s", spec)
+		}
+	}
+}
+
+func TestParseFlags_WithSpec(t *testing.T) {
+	config, err := ParseFlags([]string{"--spec", "jobs.json"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Spec != "jobs.json" || config.InputPath != "" {
+		t.Errorf("expected the spec without an input, got %q, %q", config.Spec, config.InputPath)
+	}
+
+	for _, args := range [][]string{
+		{"--spec", "jobs.json", "in/"},
+		{"--spec", "jobs.json", "-o", "out"},
+		{"--spec", "jobs.json", "--map", "in/*.avif=out/{1}.png"},
+		{"--spec", "jobs.json", "--pdf", "album.pdf"},
+		{"--spec", "jobs.json", "--dedupe"},
+		{"--spec", "-", "--interactive"},
+	} {
+		if _, err := ParseFlags(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestRun_Spec(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "in", "photo.avif")
+	os.MkdirAll(filepath.Dir(inputPath), 0755)
+	createTestAVIF(t, inputPath)
+
+	in := filepath.ToSlash(inputPath)
+	out := filepath.ToSlash(filepath.Join(testDir, "out"))
+	specPath := filepath.Join(testDir, "jobs.json")
+	spec := `{"jobs": [
+		{"input": "` + in + `", "output": "` + out + `/photo.png"},
+		{"input": "` + in + `", "output": "` + out + `/thumb/photo.jpg", "format": "jpeg", "canvas": "8x8"}
+	]}`
+	if err := os.WriteFile(specPath, []byte(spec), 0644); err != nil {
+		t.Fatalf("failed to write spec: %v", err)
+	}
+
+	config, err := ParseFlags([]string{"--spec", specPath})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := Run(config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(testDir, "out", "photo.png")); err != nil {
+		t.Errorf("expected photo.png to exist, got: %v", err)
+	}
+	f, err := os.Open(filepath.Join(testDir, "out", "thumb", "photo.jpg"))
+	if err != nil {
+		t.Fatalf("expected thumb/photo.jpg to exist, got: %v", err)
+	}
+	defer f.Close()
+	cfg, err := jpeg.DecodeConfig(f)
+	if err != nil {
+		t.Fatalf("expected a JPEG, got: %v", err)
+	}
+	if cfg.Width != 8 || cfg.Height != 8 {
+		t.Errorf("expected an 8x8 canvas, got %dx%d", cfg.Width, cfg.Height)
+	}
+}
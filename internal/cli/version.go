@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"avif2png/internal/converter"
+	"avif2png/internal/report"
+	"flag"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"strings"
+)
+
+// Version is the version of avif2png, set when building a release:
+//
+//	go build -ldflags "-X avif2png/internal/cli.Version=v1.2.3" ./cmd/avif2png
+//
+// Other builds fall back to the module version, e.g. from go install
+var Version = "dev"
+
+// version returns Version, or the module version for builds that did not set it
+func version() string {
+	if Version != "dev" {
+		return Version
+	}
+	if build, ok := debug.ReadBuildInfo(); ok && build.Main.Version != "" && build.Main.Version != "(devel)" {
+		return build.Main.Version
+	}
+	return Version
+}
+
+// printVersion writes the version and capabilities of the binary to w, as
+// JSON with --json
+func printVersion(w io.Writer, config *Config) error {
+	caps := converter.DetectCapabilities(version(), config.Features)
+	if config.JSON {
+		return report.WriteCapabilitiesJSON(w, caps, config.JSONIndent)
+	}
+
+	fmt.Fprintf(w, "avif2png %s (%s, %s)\n", caps.Version, caps.GoVersion, caps.Platform)
+	fmt.Fprintf(w, "Decoder:  %s %s (%s)\n", caps.Decoder.Module, caps.Decoder.Version, caps.Decoder.Backend)
+	fmt.Fprintf(w, "Formats:  %s\n", strings.Join(caps.Formats, ", "))
+	fmt.Fprintf(w, "Features: %s\n", strings.Join(caps.Features, ", "))
+	return nil
+}
+
+// flagNames returns the long names of the flags of fs, sorted, which
+// --version lists as the features of the build
+func flagNames(fs *flag.FlagSet) []string {
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if len(f.Name) > 1 {
+			names = append(names, f.Name)
+		}
+	})
+	return names
+}
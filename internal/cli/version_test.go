@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// ==================== Version Tests ====================
+
+func TestVersion_SetAtBuildTime(t *testing.T) {
+	defer func(original string) { Version = original }(Version)
+
+	Version = "v1.2.3"
+	if got := version(); got != "v1.2.3" {
+		t.Errorf("expected v1.2.3, got: %s", got)
+	}
+}
+
+func TestPrintVersion(t *testing.T) {
+	defer func(original string) { Version = original }(Version)
+	Version = "v1.2.3"
+
+	config, err := ParseFlags([]string{"--version"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := printVersion(&buf, config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	for _, want := range []string{"avif2png v1.2.3", "github.com/gen2brain/avif", "png", "strip-metadata"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in the output, got: %s", want, buf.String())
+		}
+	}
+}
+
+func TestPrintVersion_JSON(t *testing.T) {
+	defer func(original string) { Version = original }(Version)
+	Version = "v1.2.3"
+
+	config, err := ParseFlags([]string{"--version", "--json"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := printVersion(&buf, config); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var decoded struct {
+		Version string `json:"version"`
+		Decoder struct {
+			Module string `json:"module"`
+		} `json:"decoder"`
+		Formats  []string `json:"formats"`
+		Features []string `json:"features"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("expected valid JSON, got: %v", err)
+	}
+	if decoded.Version != "v1.2.3" || decoded.Decoder.Module != "github.com/gen2brain/avif" {
+		t.Errorf("expected the version and decoder, got: %s", buf.String())
+	}
+	if len(decoded.Formats) == 0 || len(decoded.Features) == 0 {
+		t.Errorf("expected formats and features, got: %s", buf.String())
+	}
+}
+
+func TestParseFlags_FeaturesListEveryFlag(t *testing.T) {
+	config, err := ParseFlags([]string{"--version"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// Every long flag is a feature, so a new flag is listed without
+	// touching --version
+	features := map[string]bool{}
+	for _, feature := range config.Features {
+		features[feature] = true
+	}
+	for _, name := range []string{"gif", "interlace", "optimize", "compare", "exec", "rotate", "jobs", "spritesheet", "meta-only", "stdin-list", "normalize-gamma", "fix-alpha", "use-thumbnail"} {
+		if !features[name] {
+			t.Errorf("expected %s in the features, got: %v", name, config.Features)
+		}
+	}
+	for _, shorthand := range []string{"o", "r", "v", "i", "f"} {
+		if features[shorthand] {
+			t.Errorf("expected shorthand -%s not to be listed", shorthand)
+		}
+	}
+}
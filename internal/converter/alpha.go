@@ -0,0 +1,66 @@
+package converter
+
+import (
+	"avif2png/internal/isobmff"
+	"image"
+	"image/draw"
+)
+
+// alphaSignal reports whether the primary image of an AVIF file has an
+// alpha image, and whether a prem reference marks its color as
+// premultiplied by that alpha
+func alphaSignal(data []byte) (hasAlpha, premultiplied bool) {
+	f, err := isobmff.Parse(data)
+	if err != nil {
+		return false, false
+	}
+	primary := f.Item(f.PrimaryItemID)
+	if primary == nil {
+		return false, false
+	}
+	for _, id := range f.ReferencesTo("auxl", primary.ID) {
+		if item := f.Item(id); item != nil && auxiliaryNames[item.AuxiliaryType()] == "alpha" {
+			return true, f.IsPremultiplied(primary.ID)
+		}
+	}
+	return false, false
+}
+
+// unpremultiply divides the color of img by its alpha once, for sources
+// whose color was premultiplied without a prem reference to say so: the
+// decoder then takes the stored color as straight and multiplies it by
+// alpha again, which darkens semi-transparent pixels
+// Colors are capped at alpha, as premultiplied colors are; images of more
+// than 8 bits per channel keep their depth
+func unpremultiply(img image.Image) image.Image {
+	b := img.Bounds()
+	if isDeep(img) {
+		out := image.NewRGBA64(b)
+		draw.Draw(out, b, img, b.Min, draw.Src)
+		for i := 0; i < len(out.Pix); i += 8 {
+			a := uint32(out.Pix[i+6])<<8 | uint32(out.Pix[i+7])
+			if a == 0 || a == 0xffff {
+				continue
+			}
+			for c := i; c < i+6; c += 2 {
+				v := uint32(out.Pix[c])<<8 | uint32(out.Pix[c+1])
+				v = min((v*0xffff+a/2)/a, a)
+				out.Pix[c], out.Pix[c+1] = uint8(v>>8), uint8(v)
+			}
+		}
+		return out
+	}
+
+	out := image.NewRGBA(b)
+	draw.Draw(out, b, img, b.Min, draw.Src)
+	for i := 0; i < len(out.Pix); i += 4 {
+		a := uint32(out.Pix[i+3])
+		if a == 0 || a == 0xff {
+			continue
+		}
+		for c := i; c < i+3; c++ {
+			out.Pix[c] = uint8(min((uint32(out.Pix[c])*0xff+a/2)/a, a))
+		}
+	}
+	return out
+}
@@ -0,0 +1,264 @@
+package converter
+
+import (
+	"avif2png/internal/isobmff"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gen2brain/avif"
+)
+
+// encodePremultipliedAVIF encodes red that fades in with alpha, as an
+// encoder that premultiplies without a prem reference would store it: the
+// color is the premultiplied one, saved as if it were straight
+func encodePremultipliedAVIF(t *testing.T) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 25), 0, 0, uint8(x * 25)})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := avif.Encode(&buf, img, avif.Options{Quality: 100, QualityAlpha: 100, Speed: avif.DefaultSpeed}); err != nil {
+		t.Fatalf("failed to encode test AVIF: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// withPremReference returns a copy of an encoded AVIF file with a prem
+// reference from its primary item to its alpha item, appended to the iref
+// box; the sizes of the enclosing boxes and the iloc offsets past the new
+// reference are moved along
+func withPremReference(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	f, err := isobmff.Parse(data)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	alpha := f.ReferencesTo("auxl", f.PrimaryItemID)
+	if len(alpha) != 1 {
+		t.Fatalf("expected one alpha item, got: %v", alpha)
+	}
+	boxes, err := isobmff.Walk(data)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	find := func(boxType string) isobmff.WalkedBox {
+		for _, box := range boxes {
+			if box.Type == boxType {
+				return box
+			}
+		}
+		t.Fatalf("expected a %s box", boxType)
+		return isobmff.WalkedBox{}
+	}
+	meta, iref, iloc := find("meta"), find("iref"), find("iloc")
+	if iref.Payload[0] != 0 {
+		t.Fatalf("expected a version 0 iref box, got version %d", iref.Payload[0])
+	}
+
+	prem := make([]byte, 14)
+	binary.BigEndian.PutUint32(prem, uint32(len(prem)))
+	copy(prem[4:], "prem")
+	binary.BigEndian.PutUint16(prem[8:], uint16(f.PrimaryItemID))
+	binary.BigEndian.PutUint16(prem[10:], 1)
+	binary.BigEndian.PutUint16(prem[12:], uint16(alpha[0]))
+	at := iref.Offset + iref.Size()
+
+	out := append([]byte(nil), data...)
+	for _, box := range []isobmff.WalkedBox{meta, iref} {
+		binary.BigEndian.PutUint32(out[box.Offset:], uint32(box.Size()+len(prem)))
+	}
+
+	// Offsets are moved in place, before the reference is inserted
+	version := iloc.Payload[0]
+	pos := iloc.Offset + iloc.HeaderSize + 4
+	offsetSize, lengthSize := int(out[pos]>>4), int(out[pos]&0xf)
+	baseSize, indexSize := int(out[pos+1]>>4), 0
+	if version > 0 {
+		indexSize = int(out[pos+1] & 0xf)
+	}
+	pos += 2
+	read := func(size int) int {
+		v := 0
+		for i := 0; i < size; i++ {
+			v = v<<8 | int(out[pos+i])
+		}
+		return v
+	}
+	shift := func(size int) {
+		if v := read(size); size > 0 && v >= at {
+			v += len(prem)
+			for i := 0; i < size; i++ {
+				out[pos+i] = byte(v >> (8 * (size - 1 - i)))
+			}
+		}
+		pos += size
+	}
+	idSize := 2
+	if version == 2 {
+		idSize = 4
+	}
+	itemCount := read(idSize)
+	pos += idSize
+	for i := 0; i < itemCount; i++ {
+		pos += idSize + 2
+		if version > 0 {
+			pos += 2
+		}
+		shift(baseSize)
+		extents := read(2)
+		pos += 2
+		for e := 0; e < extents; e++ {
+			pos += indexSize
+			shift(offsetSize)
+			pos += lengthSize
+		}
+	}
+
+	return append(out[:at], append(prem, out[at:]...)...)
+}
+
+// decodePNGFile decodes a PNG output of a test
+func decodePNGFile(t *testing.T, path string) image.Image {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open output: %v", err)
+	}
+	defer file.Close()
+	img, err := png.Decode(file)
+	if err != nil {
+		t.Fatalf("failed to decode output: %v", err)
+	}
+	return img
+}
+
+// ==================== unpremultiply Tests ====================
+
+func TestUnpremultiply(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 1))
+	img.Pix = []uint8{
+		50, 25, 0, 128, // 100,50,0 stored, multiplied by alpha again
+		120, 0, 0, 100, // capped at alpha
+		40, 80, 120, 255, // opaque
+		0, 0, 0, 0, // transparent
+	}
+
+	got := unpremultiply(img).(*image.RGBA).Pix
+	want := []uint8{100, 50, 0, 128, 100, 0, 0, 100, 40, 80, 120, 255, 0, 0, 0, 0}
+	if !bytes.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if img.Pix[0] != 50 {
+		t.Error("expected the input image to be left untouched")
+	}
+}
+
+func TestUnpremultiply_KeepsDepth(t *testing.T) {
+	img := image.NewRGBA64(image.Rect(0, 0, 1, 1))
+	img.SetRGBA64(0, 0, color.RGBA64{0x2000, 0, 0, 0x8000})
+
+	out, ok := unpremultiply(img).(*image.RGBA64)
+	if !ok {
+		t.Fatalf("expected a 16-bit image, got %T", unpremultiply(img))
+	}
+	if c := out.RGBA64At(0, 0); c.R < 0x3ff0 || c.R > 0x4010 || c.A != 0x8000 {
+		t.Errorf("expected red to double to about 0x4000, got %+v", c)
+	}
+}
+
+// ==================== alphaSignal Tests ====================
+
+func TestAlphaSignal(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	if hasAlpha, premultiplied := alphaSignal(testAVIFData(t, testDir)); hasAlpha || premultiplied {
+		t.Errorf("expected an opaque image to have no alpha, got %v, %v", hasAlpha, premultiplied)
+	}
+
+	data := encodePremultipliedAVIF(t)
+	if hasAlpha, premultiplied := alphaSignal(data); !hasAlpha || premultiplied {
+		t.Errorf("expected alpha without a prem reference, got %v, %v", hasAlpha, premultiplied)
+	}
+	if hasAlpha, premultiplied := alphaSignal(withPremReference(t, data)); !hasAlpha || !premultiplied {
+		t.Errorf("expected alpha with a prem reference, got %v, %v", hasAlpha, premultiplied)
+	}
+}
+
+// ==================== FixAlpha Tests ====================
+
+func TestConvert_FixAlpha(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	if err := os.WriteFile(inputPath, encodePremultipliedAVIF(t), 0644); err != nil {
+		t.Fatalf("failed to write test AVIF: %v", err)
+	}
+
+	red := func(fixAlpha bool) color.NRGBA {
+		outputDir := filepath.Join(testDir, "output")
+		os.RemoveAll(outputDir)
+		if _, err := Convert(inputPath, outputDir, Options{FixAlpha: fixAlpha}); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		img := decodePNGFile(t, filepath.Join(outputDir, "test.png"))
+		return color.NRGBAModel.Convert(img.At(5, 0)).(color.NRGBA)
+	}
+
+	// Half-transparent red comes out dark unless its color is divided by
+	// alpha once more
+	if c := red(false); c.R > 160 {
+		t.Errorf("expected dark red without FixAlpha, got %+v", c)
+	}
+	if c := red(true); c.R < 220 || c.A < 115 || c.A > 135 {
+		t.Errorf("expected bright red with FixAlpha, got %+v", c)
+	}
+}
+
+func TestConvert_FixAlphaLeavesPremReference(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	if err := os.WriteFile(inputPath, withPremReference(t, encodePremultipliedAVIF(t)), 0644); err != nil {
+		t.Fatalf("failed to write test AVIF: %v", err)
+	}
+
+	var outputs [2][]byte
+	for i, fixAlpha := range []bool{false, true} {
+		outputDir := filepath.Join(testDir, "output")
+		os.RemoveAll(outputDir)
+		if _, err := Convert(inputPath, outputDir, Options{FixAlpha: fixAlpha}); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(outputDir, "test.png"))
+		if err != nil {
+			t.Fatalf("failed to read output: %v", err)
+		}
+		outputs[i] = data
+	}
+
+	if !bytes.Equal(outputs[0], outputs[1]) {
+		t.Error("expected FixAlpha to leave an image with a prem reference as the decoder returns it")
+	}
+
+	// The decoder already takes the reference into account
+	img := decodePNGFile(t, filepath.Join(testDir, "output", "test.png"))
+	if c := color.NRGBAModel.Convert(img.At(5, 0)).(color.NRGBA); c.R < 220 {
+		t.Errorf("expected bright red with a prem reference, got %+v", c)
+	}
+}
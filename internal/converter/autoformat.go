@@ -0,0 +1,78 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+	"path/filepath"
+	"strings"
+)
+
+// autoFormats are the candidates of opts.AutoFormat, in order of preference
+// when two encodings have the same size
+var autoFormats = []string{"png", "jpeg"}
+
+// chooseFormat encodes img in each candidate format and returns the smallest
+// JPEG is encoded at opts.Quality, which acts as a quality floor, and is only
+// a candidate for opaque images since it cannot store transparency
+func chooseFormat(img image.Image, opts Options) (string, []byte, error) {
+	var bestFormat string
+	var best *bytes.Buffer
+	for _, format := range autoFormats {
+		if format == "jpeg" && !isOpaque(img) {
+			continue
+		}
+
+		encode, err := lookupEncoder(format)
+		if err != nil {
+			return "", nil, err
+		}
+
+		buf := getBuffer()
+		if err := encode(buf, img, opts.encodeOptions()); err != nil {
+			putBuffer(buf)
+			return "", nil, err
+		}
+		if best == nil || buf.Len() < best.Len() {
+			buf, best, bestFormat = best, buf, format
+		}
+		if buf != nil {
+			putBuffer(buf)
+		}
+	}
+
+	// The chosen encoding is returned, so its buffer is not pooled again
+	return bestFormat, best.Bytes(), nil
+}
+
+// isOpaque reports whether img is known to have no transparent pixels
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// withFormat replaces the extension of outputPath with format
+func withFormat(outputPath, format string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + "." + format
+}
+
+// existingOutput returns the path of an existing output for outputPath, or ""
+// In auto-format mode an output in any candidate format counts, so re-runs
+// skip files whichever format was chosen before
+func existingOutput(outputPath string, opts Options) string {
+	candidates := []string{outputPath}
+	if opts.AutoFormat {
+		candidates = candidates[:0]
+		for _, format := range autoFormats {
+			candidates = append(candidates, withFormat(outputPath, format))
+		}
+	}
+
+	for _, path := range candidates {
+		if _, err := opts.Output.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
@@ -0,0 +1,122 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// noiseImage returns an image of random pixels, which compresses poorly as PNG
+func noiseImage(size int, alpha uint8) *image.NRGBA {
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.Set(x, y, color.NRGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), alpha})
+		}
+	}
+	return img
+}
+
+// ==================== chooseFormat Tests ====================
+
+func TestChooseFormat_FlatImageIsPNG(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xFF
+	}
+
+	format, data, err := chooseFormat(img, Options{Quality: DefaultQuality})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if format != "png" || len(data) == 0 {
+		t.Errorf("expected png for a flat image, got: %s (%d bytes)", format, len(data))
+	}
+}
+
+func TestChooseFormat_NoisyImageIsJPEG(t *testing.T) {
+	format, _, err := chooseFormat(noiseImage(64, 0xFF), Options{Quality: 50})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if format != "jpeg" {
+		t.Errorf("expected jpeg for a noisy opaque image, got: %s", format)
+	}
+}
+
+func TestChooseFormat_TransparentImageIsPNG(t *testing.T) {
+	format, _, err := chooseFormat(noiseImage(64, 0x80), Options{Quality: 50})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if format != "png" {
+		t.Errorf("expected png for a transparent image, got: %s", format)
+	}
+}
+
+// ==================== AutoFormat Tests ====================
+
+func TestConvertDirectory_AutoFormatRecordsFormat(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "image.avif"))
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{AutoFormat: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Successful != 1 {
+		t.Fatalf("expected 1 successful conversion, got: %d", result.Successful)
+	}
+
+	file := result.Files[0]
+	if file.Format != "png" && file.Format != "jpeg" {
+		t.Fatalf("expected png or jpeg to be chosen, got: %q", file.Format)
+	}
+	if want := filepath.Join(outputDir, "image."+file.Format); file.OutputPath != want {
+		t.Errorf("expected output path %s, got: %s", want, file.OutputPath)
+	}
+	if _, err := os.Stat(file.OutputPath); err != nil {
+		t.Errorf("expected output file to exist: %v", err)
+	}
+}
+
+func TestConvert_AutoFormatSkipsAnyExistingCandidate(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "image.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		t.Fatalf("failed to create output dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "image.jpeg"), []byte("existing"), 0644); err != nil {
+		t.Fatalf("failed to create existing output: %v", err)
+	}
+
+	status, err := Convert(inputPath, outputDir, Options{AutoFormat: true})
+	if err != nil || status != StatusSkipped {
+		t.Fatalf("expected StatusSkipped without error, got: %s, %v", status, err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "image.png")); !os.IsNotExist(err) {
+		t.Error("expected no PNG to be written next to the existing JPEG")
+	}
+}
+
+func TestWithFormat(t *testing.T) {
+	if got := withFormat(filepath.Join("out", "a.b.png"), "jpeg"); got != filepath.Join("out", "a.b.jpeg") {
+		t.Errorf("expected the last extension to be replaced, got: %s", got)
+	}
+}
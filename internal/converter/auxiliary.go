@@ -0,0 +1,100 @@
+package converter
+
+import (
+	"avif2png/internal/isobmff"
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"path/filepath"
+	"strings"
+
+	"github.com/gen2brain/avif"
+)
+
+// auxiliaryNames maps auxC URNs to the suffix used for the extracted file
+var auxiliaryNames = map[string]string{
+	"urn:mpeg:mpegB:cicp:systems:auxiliary:alpha": "alpha",
+	"urn:mpeg:mpegB:cicp:systems:auxiliary:depth": "depth",
+	"urn:mpeg:hevc:2015:auxid:1":                  "alpha",
+	"urn:mpeg:hevc:2015:auxid:2":                  "depth",
+}
+
+// auxiliaryName returns the output suffix for an auxiliary item
+// Unknown auxiliary types are named after their item ID
+func auxiliaryName(item *isobmff.Item) string {
+	if name, ok := auxiliaryNames[item.AuxiliaryType()]; ok {
+		return name
+	}
+	return fmt.Sprintf("aux%d", item.ID)
+}
+
+// auxiliaryOutputPath returns the path an auxiliary image is written to,
+// next to the main output: "img.png" becomes "img_depth.png"
+func auxiliaryOutputPath(outputPath, name string) string {
+	ext := filepath.Ext(outputPath)
+	return strings.TrimSuffix(outputPath, ext) + "_" + name + ext
+}
+
+// extractAuxiliary writes the auxiliary images (alpha masks, depth maps) of
+// the primary item of an AVIF file, held in data, next to outputPath
+// libavif only decodes the primary item, so each auxiliary item is decoded
+// from a copy of the file whose primary item points at it
+// Existing auxiliary outputs are left untouched unless opts.Overwrite is set
+// It returns the paths of the files it wrote
+func extractAuxiliary(data []byte, outputPath string, encode EncoderFunc, opts Options) ([]string, error) {
+	container, err := isobmff.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse AVIF container: %w", err)
+	}
+
+	var written []string
+	for _, id := range container.ReferencesTo("auxl", container.PrimaryItemID) {
+		item := container.Item(id)
+		if item == nil {
+			continue
+		}
+
+		auxPath := auxiliaryOutputPath(outputPath, auxiliaryName(item))
+		if _, err := opts.Output.Stat(auxPath); err == nil && !opts.Overwrite {
+			continue
+		}
+
+		patched, err := container.WithPrimaryItem(data, id)
+		if err != nil {
+			return written, err
+		}
+
+		img, err := avif.Decode(bytes.NewReader(patched))
+		if err != nil {
+			return written, fmt.Errorf("failed to decode auxiliary image %d: %w", id, err)
+		}
+
+		if err := writeImage(auxPath, toGray(img), encode, opts, nil); err != nil {
+			return written, err
+		}
+		written = append(written, auxPath)
+
+		if opts.Verbose {
+			opts.logf("✅ Saved auxiliary: %s\n", auxPath)
+		}
+	}
+
+	return written, nil
+}
+
+// toGray converts a monochrome auxiliary image to a greyscale image,
+// keeping 16 bits per sample for high bit depth sources
+func toGray(img image.Image) image.Image {
+	bounds := img.Bounds()
+
+	var gray draw.Image
+	if _, ok := img.(*image.RGBA64); ok {
+		gray = image.NewGray16(bounds)
+	} else {
+		gray = image.NewGray(bounds)
+	}
+
+	draw.Draw(gray, bounds, img, bounds.Min, draw.Src)
+	return gray
+}
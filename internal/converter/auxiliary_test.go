@@ -0,0 +1,135 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gen2brain/avif"
+)
+
+// createTestAVIFWithAlpha creates a semi-transparent AVIF, which the encoder
+// stores as a colour item plus an auxiliary alpha item
+func createTestAVIFWithAlpha(t *testing.T, path string) {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			img.Set(x, y, color.NRGBA{255, 0, 0, uint8(x * 25)})
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create test AVIF file: %v", err)
+	}
+	defer file.Close()
+
+	if err := avif.Encode(file, img); err != nil {
+		t.Fatalf("failed to encode test AVIF: %v", err)
+	}
+}
+
+// ==================== ExtractAux Tests ====================
+
+func TestConvert_ExtractAuxAlpha(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIFWithAlpha(t, inputPath)
+
+	if _, err := Convert(inputPath, outputDir, Options{ExtractAux: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	file, err := os.Open(filepath.Join(outputDir, "test_alpha.png"))
+	if err != nil {
+		t.Fatalf("expected alpha output to exist, got: %v", err)
+	}
+	defer file.Close()
+
+	img, err := png.Decode(file)
+	if err != nil {
+		t.Fatalf("expected valid PNG, got: %v", err)
+	}
+
+	gray, ok := img.(*image.Gray)
+	if !ok {
+		t.Fatalf("expected greyscale alpha image, got: %T", img)
+	}
+	if v := gray.GrayAt(9, 0).Y; v < 215 || v > 235 {
+		t.Errorf("expected alpha value near 225, got: %d", v)
+	}
+}
+
+func TestConvert_ExtractAuxWithoutAuxiliaryItems(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	if _, err := Convert(inputPath, outputDir, Options{ExtractAux: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the main output, got %d file(s)", len(entries))
+	}
+}
+
+func TestConvert_WithoutExtractAux(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIFWithAlpha(t, inputPath)
+
+	if _, err := Convert(inputPath, outputDir, Options{}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "test_alpha.png")); !os.IsNotExist(err) {
+		t.Error("expected no alpha output without ExtractAux")
+	}
+}
+
+func TestConvert_PrimaryOnly(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIFWithAlpha(t, inputPath)
+
+	if _, err := Convert(inputPath, outputDir, Options{PrimaryOnly: true, ExtractAux: true, AllItems: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "test.png" {
+		t.Errorf("expected only the primary image, got: %v", entries)
+	}
+}
+
+func TestAuxiliaryOutputPath(t *testing.T) {
+	got := auxiliaryOutputPath(filepath.Join("out", "img.png"), "depth")
+	if want := filepath.Join("out", "img_depth.png"); got != want {
+		t.Errorf("expected %q, got: %q", want, got)
+	}
+}
@@ -0,0 +1,219 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gen2brain/avif"
+)
+
+// memorySampleInterval is how often Benchmark samples the heap for its peak
+const memorySampleInterval = 20 * time.Millisecond
+
+// BenchmarkOptions controls a Benchmark run
+type BenchmarkOptions struct {
+	// Duration is how long new conversions are started for
+	Duration time.Duration
+	// Workers is the number of conversions run at the same time, at least 1
+	Workers int
+}
+
+// BenchmarkResult summarizes a Benchmark run
+type BenchmarkResult struct {
+	Conversions int
+	Failed      int
+	Workers     int
+	Elapsed     time.Duration
+	// PerSecond is the number of successful conversions per second of Elapsed
+	PerSecond float64
+	// P50 and P99 are percentiles of the time spent on one conversion
+	P50 time.Duration
+	P99 time.Duration
+	// PeakHeap is the largest heap in use observed during the run, in bytes
+	PeakHeap uint64
+	// Width, Height and InputSize describe the sample image
+	Width     int
+	Height    int
+	InputSize int
+	// OutputSize is the size of the last encoded output
+	OutputSize int64
+}
+
+// Benchmark repeatedly decodes the AVIF file held in data and encodes it
+// as selected by opts, for capacity planning
+// Outputs are encoded in memory and discarded, so the disk is not measured
+// It stops starting conversions after bench.Duration or once ctx is done,
+// and fails if the sample itself cannot be converted
+func Benchmark(ctx context.Context, data []byte, opts Options, bench BenchmarkOptions) (*BenchmarkResult, error) {
+	opts = opts.withDefaults()
+	encode, err := lookupEncoder(opts.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := bench.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	result := &BenchmarkResult{Workers: workers, InputSize: len(data)}
+
+	// Convert once up front, so a bad sample fails fast instead of being
+	// counted as failures for the whole run
+	img, err := decodeInputData(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	result.Width, result.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	if err := encode(io.Discard, img, opts.encodeOptions()); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", opts.Format, err)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, bench.Duration)
+	defer cancel()
+
+	stopSampling := sampleHeap(&result.PeakHeap)
+	start := time.Now()
+
+	var mu sync.Mutex
+	var latencies []time.Duration
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for runCtx.Err() == nil {
+				began := time.Now()
+				size, err := benchmarkOnce(data, encode, opts)
+				elapsed := time.Since(began)
+
+				mu.Lock()
+				if err != nil {
+					result.Failed++
+				} else {
+					result.Conversions++
+					result.OutputSize = size
+					latencies = append(latencies, elapsed)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	result.Elapsed = time.Since(start)
+	stopSampling()
+
+	if result.Elapsed > 0 {
+		result.PerSecond = float64(result.Conversions) / result.Elapsed.Seconds()
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	result.P50 = percentile(latencies, 50)
+	result.P99 = percentile(latencies, 99)
+
+	// Cancelling the caller's context ends the run early but still reports it
+	if err := ctx.Err(); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return result, err
+	}
+	return result, nil
+}
+
+// benchmarkOnce converts data in memory and returns the size of the output
+func benchmarkOnce(data []byte, encode EncoderFunc, opts Options) (int64, error) {
+	img, err := decodeInputData(data, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	var out countingWriter
+	if err := encode(&out, img, opts.encodeOptions()); err != nil {
+		return 0, err
+	}
+	return out.n, nil
+}
+
+// countingWriter discards what is written to it, counting the bytes
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// sampleHeap records the peak heap in use into peak until the returned
+// function is called
+func sampleHeap(peak *uint64) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	sample := func() {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		if stats.HeapInuse > *peak {
+			*peak = stats.HeapInuse
+		}
+	}
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(memorySampleInterval)
+		defer ticker.Stop()
+		for {
+			sample()
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// percentile returns the p-th percentile of sorted durations, by nearest rank
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// SyntheticAVIF encodes a width x height test image with gradients and
+// detail, as a benchmark sample when no real file is at hand
+func SyntheticAVIF(width, height int) ([]byte, error) {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(x * 255 / max(1, width-1)),
+				G: uint8(y * 255 / max(1, height-1)),
+				B: uint8((x ^ y) & 0xFF),
+				A: 255,
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := avif.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode synthetic AVIF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,77 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// ==================== Benchmark Tests ====================
+
+func TestBenchmark_Synthetic(t *testing.T) {
+	data, err := SyntheticAVIF(32, 16)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	result, err := Benchmark(context.Background(), data, Options{}, BenchmarkOptions{Duration: 300 * time.Millisecond, Workers: 2})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.Conversions == 0 || result.Failed != 0 {
+		t.Fatalf("expected successful conversions, got: %d (%d failed)", result.Conversions, result.Failed)
+	}
+	if result.Width != 32 || result.Height != 16 || result.InputSize != len(data) {
+		t.Errorf("expected the sample to be described, got: %+v", result)
+	}
+	if result.PerSecond <= 0 || result.P50 <= 0 || result.P99 < result.P50 {
+		t.Errorf("expected throughput and ordered percentiles, got: %+v", result)
+	}
+	if result.PeakHeap == 0 || result.OutputSize == 0 || result.Workers != 2 {
+		t.Errorf("expected peak heap, output size and workers, got: %+v", result)
+	}
+}
+
+func TestBenchmark_InvalidSample(t *testing.T) {
+	_, err := Benchmark(context.Background(), []byte("not an AVIF file at all"), Options{}, BenchmarkOptions{Duration: time.Second})
+	if err == nil {
+		t.Fatal("expected error for an invalid sample")
+	}
+}
+
+func TestBenchmark_Cancelled(t *testing.T) {
+	data, err := SyntheticAVIF(8, 8)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := Benchmark(ctx, data, Options{}, BenchmarkOptions{Duration: time.Minute})
+	if !errors.Is(err, context.Canceled) || result == nil {
+		t.Fatalf("expected context.Canceled with a result, got: %v", err)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	if got := percentile(sorted, 50); got != 50*time.Millisecond {
+		t.Errorf("expected p50 of 50ms, got: %v", got)
+	}
+	if got := percentile(sorted, 99); got != 99*time.Millisecond {
+		t.Errorf("expected p99 of 99ms, got: %v", got)
+	}
+	if got := percentile(sorted[:1], 99); got != time.Millisecond {
+		t.Errorf("expected the only sample, got: %v", got)
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("expected 0 for no samples, got: %v", got)
+	}
+}
@@ -0,0 +1,45 @@
+package converter
+
+import "image"
+
+// blankSampleSize is the largest number of rows, and of columns, of pixels
+// that blankWarning looks at, which keeps it cheap on large images
+const blankSampleSize = 128
+
+// Warnings for converted images that look blank, which often point at a bug
+// in the tool that exported the AVIF; they are values of FileResult.Warning
+const (
+	WarningTransparent = "fully transparent"
+	WarningSingleColor = "single color"
+)
+
+// blankWarning samples img on an evenly spaced grid and returns
+// WarningTransparent if every sampled pixel is fully transparent,
+// WarningSingleColor if they all have the same color, and "" otherwise
+// A detail that falls between sampled pixels can go unnoticed
+func blankWarning(img image.Image) string {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return ""
+	}
+	stepX := max(1, bounds.Dx()/blankSampleSize)
+	stepY := max(1, bounds.Dy()/blankSampleSize)
+
+	r0, g0, b0, a0 := img.At(bounds.Min.X, bounds.Min.Y).RGBA()
+	transparent, uniform := true, true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += stepY {
+		for x := bounds.Min.X; x < bounds.Max.X; x += stepX {
+			r, g, b, a := img.At(x, y).RGBA()
+			transparent = transparent && a == 0
+			uniform = uniform && r == r0 && g == g0 && b == b0 && a == a0
+			if !transparent && !uniform {
+				return ""
+			}
+		}
+	}
+
+	if transparent {
+		return WarningTransparent
+	}
+	return WarningSingleColor
+}
@@ -0,0 +1,56 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ==================== Blank Warning Tests ====================
+
+func TestBlankWarning(t *testing.T) {
+	detailed := solidImage(300, 200, color.NRGBA{255, 255, 255, 255})
+	for x := 100; x < 200; x++ {
+		detailed.SetNRGBA(x, 100, color.NRGBA{0, 0, 0, 255})
+	}
+
+	tests := []struct {
+		name string
+		img  image.Image
+		want string
+	}{
+		{"transparent", image.NewNRGBA(image.Rect(0, 0, 300, 200)), WarningTransparent},
+		{"single color", solidImage(300, 200, color.NRGBA{0, 128, 0, 255}), WarningSingleColor},
+		{"detailed", detailed, ""},
+		{"noise", noiseImage(64, 255), ""},
+		{"empty", image.NewNRGBA(image.Rectangle{}), ""},
+	}
+
+	for _, tt := range tests {
+		if got := blankWarning(tt.img); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestConvertDirectory_RecordsBlankWarning(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	// The test image is a single color
+	inputDir := filepath.Join(testDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "blank.avif"))
+
+	result, err := ConvertDirectory(inputDir, filepath.Join(testDir, "output"), Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(result.Files) != 1 || result.Files[0].Warning != WarningSingleColor {
+		t.Errorf("expected a single color warning, got: %+v", result.Files)
+	}
+}
@@ -0,0 +1,133 @@
+package converter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+)
+
+// ErrOverBudget is returned when an output cannot be made smaller than
+// opts.MaxOutputSize
+var ErrOverBudget = errors.New("output does not fit the size budget")
+
+// lossyFormats are the formats whose size opts.MaxOutputSize controls through
+// quality; other formats are downscaled instead
+var lossyFormats = map[string]bool{"jpeg": true}
+
+// maxBudgetScales is how many sizes a lossless output is tried at before
+// giving up on a budget
+const maxBudgetScales = 12
+
+// budgetFit is an encoding of an image that fits opts.MaxOutputSize
+type budgetFit struct {
+	data []byte
+	// bounds are those of the encoded image, which are smaller than the
+	// original when it was downscaled
+	bounds image.Rectangle
+	// quality is the quality of a lossy encoding, and scale the fraction of
+	// the original width and height kept by a lossless one
+	quality int
+	scale   float64
+}
+
+// String describes the quality or scale that was chosen, for verbose output
+func (f budgetFit) String() string {
+	if f.quality > 0 {
+		return fmt.Sprintf("quality %d", f.quality)
+	}
+	return fmt.Sprintf("%.0f%% scale, %dx%d", f.scale*100, f.bounds.Dx(), f.bounds.Dy())
+}
+
+// fitBudget encodes img no larger than opts.MaxOutputSize bytes
+// Lossy formats search for the highest quality up to opts.Quality that fits;
+// lossless ones are downscaled until they fit
+func fitBudget(img image.Image, encode EncoderFunc, opts Options) (budgetFit, error) {
+	if lossyFormats[opts.Format] {
+		return fitQuality(img, encode, opts)
+	}
+	return fitScale(img, encode, opts)
+}
+
+// fitQuality binary searches the quality of a lossy encoding, assuming
+// that the size grows with quality
+func fitQuality(img image.Image, encode EncoderFunc, opts Options) (budgetFit, error) {
+	fit := budgetFit{bounds: img.Bounds(), scale: 1}
+	smallest := -1
+	var best *bytes.Buffer
+	low, high := 1, opts.Quality
+	for low <= high {
+		quality := (low + high) / 2
+		encodeOpts := opts.encodeOptions()
+		encodeOpts.Quality = quality
+
+		buf := getBuffer()
+		if err := encode(buf, img, encodeOpts); err != nil {
+			putBuffer(buf)
+			return fit, err
+		}
+		if smallest < 0 || buf.Len() < smallest {
+			smallest = buf.Len()
+		}
+
+		// Only the buffer of the best fit so far is kept; the others go back
+		// to the pool, as the fit does once a higher quality fits
+		if int64(buf.Len()) <= opts.MaxOutputSize {
+			if best != nil {
+				putBuffer(best)
+			}
+			best, fit.quality = buf, quality
+			low = quality + 1
+		} else {
+			putBuffer(buf)
+			high = quality - 1
+		}
+	}
+	if best != nil {
+		fit.data = best.Bytes()
+	}
+
+	if fit.data == nil {
+		return fit, fmt.Errorf("%w: %d bytes at minimum quality, budget is %d", ErrOverBudget, smallest, opts.MaxOutputSize)
+	}
+	return fit, nil
+}
+
+// fitScale shrinks img until its lossless encoding fits, guessing each new
+// scale from how far the last attempt was over budget
+// Downscaled images are sharpened with opts.SharpenAmount
+func fitScale(img image.Image, encode EncoderFunc, opts Options) (budgetFit, error) {
+	bounds := img.Bounds()
+	fit := budgetFit{bounds: bounds, scale: 1}
+	scaled := img
+	for attempt := 0; attempt < maxBudgetScales; attempt++ {
+		buf := getBuffer()
+		if err := encode(buf, scaled, opts.encodeOptions()); err != nil {
+			putBuffer(buf)
+			return fit, err
+		}
+		if int64(buf.Len()) <= opts.MaxOutputSize {
+			fit.data, fit.bounds = buf.Bytes(), scaled.Bounds()
+			return fit, nil
+		}
+		size := buf.Len()
+		putBuffer(buf)
+		if scaled.Bounds().Dx() == 1 && scaled.Bounds().Dy() == 1 {
+			return fit, fmt.Errorf("%w: %d bytes at 1x1, budget is %d", ErrOverBudget, size, opts.MaxOutputSize)
+		}
+
+		// The size is roughly proportional to the area; aim a little lower so
+		// that compression differences rarely need another attempt
+		shrink := math.Min(0.9, math.Sqrt(float64(opts.MaxOutputSize)/float64(size))*0.95)
+		fit.scale *= shrink
+		width := max(1, int(math.Round(float64(bounds.Dx())*fit.scale)))
+		height := max(1, int(math.Round(float64(bounds.Dy())*fit.scale)))
+		scaled = downscale(img, width, height)
+		if opts.SharpenAmount > 0 {
+			scaled = sharpen(scaled, opts.SharpenAmount, opts.SharpenRadius)
+		}
+	}
+
+	return fit, fmt.Errorf("%w: still over %d bytes at %.0f%% scale", ErrOverBudget, opts.MaxOutputSize, fit.scale*100)
+}
@@ -0,0 +1,147 @@
+package converter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ==================== Size Budget Tests ====================
+
+func TestFitBudget_LowersJPEGQuality(t *testing.T) {
+	img := noiseImage(64, 255)
+	encode, _ := lookupEncoder("jpeg")
+	opts := Options{Format: "jpeg", Quality: 90, MaxOutputSize: 4000}
+
+	fit, err := fitBudget(img, encode, opts)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(fit.data) > 4000 {
+		t.Errorf("expected at most 4000 bytes, got: %d", len(fit.data))
+	}
+	if fit.quality < 1 || fit.quality >= 90 {
+		t.Errorf("expected a quality below 90, got: %d", fit.quality)
+	}
+	if fit.bounds != img.Bounds() {
+		t.Errorf("expected JPEG outputs to keep their size, got: %v", fit.bounds)
+	}
+	if !strings.Contains(fit.String(), "quality") {
+		t.Errorf("expected the quality in the description, got: %s", fit)
+	}
+}
+
+func TestFitBudget_KeepsQualityWhenItFits(t *testing.T) {
+	encode, _ := lookupEncoder("jpeg")
+	fit, err := fitBudget(noiseImage(8, 255), encode, Options{Format: "jpeg", Quality: 75, MaxOutputSize: 1 << 20})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if fit.quality != 75 {
+		t.Errorf("expected the requested quality 75, got: %d", fit.quality)
+	}
+}
+
+func TestFitBudget_DownscalesPNG(t *testing.T) {
+	img := noiseImage(64, 255)
+	encode, _ := lookupEncoder("png")
+
+	fit, err := fitBudget(img, encode, Options{Format: "png", MaxOutputSize: 4000})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(fit.data) > 4000 {
+		t.Errorf("expected at most 4000 bytes, got: %d", len(fit.data))
+	}
+	if fit.bounds.Dx() >= 64 || fit.scale >= 1 {
+		t.Errorf("expected a downscaled image, got: %v at scale %v", fit.bounds, fit.scale)
+	}
+}
+
+func TestFitBudget_SharpensDownscaledPNG(t *testing.T) {
+	img := noiseImage(64, 255)
+	encode, _ := lookupEncoder("png")
+
+	opts := Options{Format: "png", MaxOutputSize: 4000, SharpenAmount: 1}.withDefaults()
+	fit, err := fitBudget(img, encode, opts)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(fit.data) > 4000 || fit.scale >= 1 {
+		t.Errorf("expected a downscaled output of at most 4000 bytes, got: %d bytes at scale %v", len(fit.data), fit.scale)
+	}
+}
+
+func TestFitBudget_Impossible(t *testing.T) {
+	img := noiseImage(16, 255)
+	for _, format := range []string{"jpeg", "png"} {
+		encode, _ := lookupEncoder(format)
+		_, err := fitBudget(img, encode, Options{Format: format, Quality: 90, MaxOutputSize: 10})
+		if !errors.Is(err, ErrOverBudget) {
+			t.Errorf("%s: expected ErrOverBudget, got: %v", format, err)
+		}
+	}
+}
+
+func TestConvert_MaxOutputSize(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	// A solid 10x10 image fits comfortably, so it is written unchanged
+	opts := Options{Format: "jpeg", MaxOutputSize: 100000, Verify: true}
+	if _, err := Convert(inputPath, outputDir, opts); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(outputDir, "test.jpeg"))
+	if err != nil {
+		t.Fatalf("expected output file: %v", err)
+	}
+	if info.Size() > 100000 {
+		t.Errorf("expected output within budget, got: %d bytes", info.Size())
+	}
+
+	status, err := Convert(inputPath, filepath.Join(testDir, "tiny"), Options{MaxOutputSize: 10})
+	if !errors.Is(err, ErrOverBudget) || status != StatusFailed {
+		t.Errorf("expected ErrOverBudget, got: %s, %v", status, err)
+	}
+	if _, err := os.Stat(filepath.Join(testDir, "tiny", "test.png")); !os.IsNotExist(err) {
+		t.Error("expected no output when the budget cannot be met")
+	}
+}
+
+func TestConvertDirectory_MaxOutputSizeDownscalesAndVerifies(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "image.avif"))
+
+	// Measure the full-size PNG, then ask for less than that
+	full, err := ConvertDirectory(inputDir, filepath.Join(testDir, "full"), Options{})
+	if err != nil || full.Successful != 1 {
+		t.Fatalf("expected a full-size conversion, got: %v", err)
+	}
+	budget := full.Files[0].OutputSize - 1
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{MaxOutputSize: budget, Verify: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Successful != 1 {
+		t.Fatalf("expected 1 successful conversion, got: %d (%v)", result.Successful, result.Errors)
+	}
+	if size := result.Files[0].OutputSize; size > budget {
+		t.Errorf("expected at most %d bytes, got: %d", budget, size)
+	}
+}
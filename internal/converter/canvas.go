@@ -0,0 +1,45 @@
+package converter
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// fitCanvas scales img to fit within opts.Canvas, keeping its aspect ratio,
+// and centers it on a canvas of exactly that size filled with
+// opts.PadColor, so inputs of any shape give outputs of one size
+// (letterboxing)
+// Transparent pixels of img show the pad; images already of that size are
+// only flattened onto it, and downscaled ones are sharpened with
+// opts.SharpenAmount. The zero size returns img as is
+func fitCanvas(img image.Image, opts Options) image.Image {
+	size, pad := opts.Canvas, opts.PadColor
+	bounds := img.Bounds()
+	if size.X <= 0 || size.Y <= 0 || bounds.Empty() {
+		return img
+	}
+
+	scale := math.Min(float64(size.X)/float64(bounds.Dx()), float64(size.Y)/float64(bounds.Dy()))
+	width := min(size.X, max(1, int(math.Round(float64(bounds.Dx())*scale))))
+	height := min(size.Y, max(1, int(math.Round(float64(bounds.Dy())*scale))))
+	scaled := img
+	if width != bounds.Dx() || height != bounds.Dy() {
+		scaled = downscale(img, width, height)
+		if opts.SharpenAmount > 0 && width < bounds.Dx() {
+			scaled = sharpen(scaled, opts.SharpenAmount, opts.SharpenRadius)
+		}
+	}
+
+	var canvas draw.Image
+	if _, depth := pngColorType(img); depth == 16 {
+		canvas = image.NewNRGBA64(image.Rect(0, 0, size.X, size.Y))
+	} else {
+		canvas = image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+	}
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(pad), image.Point{}, draw.Src)
+
+	offset := image.Pt((size.X-width)/2, (size.Y-height)/2)
+	draw.Draw(canvas, image.Rectangle{Min: offset, Max: offset.Add(image.Pt(width, height))}, scaled, scaled.Bounds().Min, draw.Over)
+	return canvas
+}
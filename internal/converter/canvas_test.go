@@ -0,0 +1,112 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sameColors reports whether a and b have the same bounds and colors
+func sameColors(a, b image.Image) bool {
+	if a.Bounds() != b.Bounds() {
+		return false
+	}
+	for y := a.Bounds().Min.Y; y < a.Bounds().Max.Y; y++ {
+		for x := a.Bounds().Min.X; x < a.Bounds().Max.X; x++ {
+			if color.NRGBA64Model.Convert(a.At(x, y)) != color.NRGBA64Model.Convert(b.At(x, y)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ==================== Canvas Tests ====================
+
+func TestFitCanvas_Letterbox(t *testing.T) {
+	red, blue := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}
+	img := fitCanvas(solidImage(40, 20, red), Options{Canvas: image.Pt(30, 30), PadColor: blue})
+
+	if img.Bounds() != image.Rect(0, 0, 30, 30) {
+		t.Fatalf("expected a 30x30 canvas, got: %v", img.Bounds())
+	}
+	// The image is scaled to 30x15 and centered from y = 7
+	for _, tt := range []struct {
+		x, y int
+		want color.NRGBA
+	}{{15, 0, blue}, {15, 6, blue}, {15, 7, red}, {0, 21, red}, {15, 22, blue}, {29, 29, blue}} {
+		if got := color.NRGBAModel.Convert(img.At(tt.x, tt.y)); got != tt.want {
+			t.Errorf("pixel %d,%d: expected %v, got %v", tt.x, tt.y, tt.want, got)
+		}
+	}
+}
+
+func TestFitCanvas_TransparentShowsPad(t *testing.T) {
+	white := color.NRGBA{255, 255, 255, 255}
+	img := fitCanvas(solidImage(10, 10, color.NRGBA{}), Options{Canvas: image.Pt(10, 10), PadColor: white})
+
+	if got := color.NRGBAModel.Convert(img.At(5, 5)); got != white {
+		t.Errorf("expected transparent pixels to show the pad, got: %v", got)
+	}
+}
+
+func TestFitCanvas_Upscales(t *testing.T) {
+	img := fitCanvas(solidImage(4, 2, color.NRGBA{0, 255, 0, 255}), Options{Canvas: image.Pt(100, 100), PadColor: color.NRGBA{}})
+
+	if got := color.NRGBAModel.Convert(img.At(50, 50)).(color.NRGBA); got.G != 255 || got.A != 255 {
+		t.Errorf("expected the image to be scaled up to the canvas width, got: %v", got)
+	}
+	if got := color.NRGBAModel.Convert(img.At(50, 10)).(color.NRGBA); got.A != 0 {
+		t.Errorf("expected a transparent pad, got: %v", got)
+	}
+}
+
+func TestFitCanvas_Sharpen(t *testing.T) {
+	img := noiseImage(64, 255)
+	opts := Options{Canvas: image.Pt(32, 32), PadColor: color.NRGBA{}, SharpenRadius: DefaultSharpenRadius}
+	plain := fitCanvas(img, opts)
+
+	opts.SharpenAmount = 1
+	if sameColors(fitCanvas(img, opts), plain) {
+		t.Error("expected the downscaled image to be sharpened")
+	}
+
+	// Images scaled up are left as they are
+	small := noiseImage(16, 255)
+	opts.SharpenAmount = 0
+	plain = fitCanvas(small, opts)
+	opts.SharpenAmount = 1
+	if !sameColors(fitCanvas(small, opts), plain) {
+		t.Error("expected an upscaled image not to be sharpened")
+	}
+}
+
+func TestConvert_Canvas(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	// Verify checks the output against the canvas size
+	if _, err := Convert(inputPath, outputDir, Options{Canvas: image.Pt(16, 9), PadColor: color.NRGBA{A: 255}, Verify: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	file, err := os.Open(filepath.Join(outputDir, "test.png"))
+	if err != nil {
+		t.Fatalf("expected output to exist, got: %v", err)
+	}
+	defer file.Close()
+	cfg, err := png.DecodeConfig(file)
+	if err != nil {
+		t.Fatalf("expected a valid PNG, got: %v", err)
+	}
+	if cfg.Width != 16 || cfg.Height != 9 {
+		t.Errorf("expected a 16x9 output, got %dx%d", cfg.Width, cfg.Height)
+	}
+}
@@ -0,0 +1,85 @@
+package converter
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sort"
+
+	"github.com/gen2brain/avif"
+)
+
+// decoderModule is the module path of the AVIF decoder
+const decoderModule = "github.com/gen2brain/avif"
+
+// Decoder backends reported in DecoderInfo.Backend
+const (
+	// BackendLibavif is a shared libavif found on the system
+	BackendLibavif = "libavif"
+	// BackendWasm is the libavif build embedded as WebAssembly
+	BackendWasm = "wasm"
+)
+
+// Capabilities describes what this build of the converter supports, so
+// scripts can check for a feature before using it
+type Capabilities struct {
+	// Version is the version of the tool, filled in by the caller
+	Version   string
+	GoVersion string
+	// Platform is the operating system and architecture, e.g. "linux/amd64"
+	Platform string
+	Decoder  DecoderInfo
+	// Formats are the registered output formats, see Formats
+	Formats []string
+	// Features are the names of the optional features available, sorted:
+	// those of the caller, such as its flags, and "cpu-time" where
+	// ResourceUsage.CPUTime is measured
+	Features []string
+}
+
+// DecoderInfo describes the AVIF decoder linked into the binary
+type DecoderInfo struct {
+	Module string
+	// Version is the module version, or "unknown" without build information
+	Version string
+	// Backend is BackendLibavif or BackendWasm
+	Backend string
+}
+
+// DetectCapabilities reports the capabilities of the running binary, with
+// the features of the caller, such as the names of its flags
+func DetectCapabilities(version string, features []string) *Capabilities {
+	features = append([]string(nil), features...)
+	if _, ok := processCPUTime(); ok {
+		features = append(features, "cpu-time")
+	}
+	sort.Strings(features)
+
+	return &Capabilities{
+		Version:   version,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Decoder:   detectDecoder(),
+		Formats:   Formats(),
+		Features:  features,
+	}
+}
+
+// detectDecoder reads the decoder version from the build information and
+// whether it loaded a shared libavif
+func detectDecoder() DecoderInfo {
+	info := DecoderInfo{Module: decoderModule, Version: "unknown", Backend: BackendWasm}
+	if avif.Dynamic() == nil {
+		info.Backend = BackendLibavif
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range build.Deps {
+			if dep.Path == decoderModule {
+				info.Version = dep.Version
+				if dep.Replace != nil {
+					info.Version = dep.Replace.Version
+				}
+			}
+		}
+	}
+	return info
+}
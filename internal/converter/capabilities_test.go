@@ -0,0 +1,44 @@
+package converter
+
+import (
+	"runtime"
+	"sort"
+	"testing"
+)
+
+// ==================== Capabilities Tests ====================
+
+func TestDetectCapabilities(t *testing.T) {
+	caps := DetectCapabilities("v1.2.3", []string{"tar", "jobs"})
+
+	if caps.Version != "v1.2.3" {
+		t.Errorf("expected version v1.2.3, got: %s", caps.Version)
+	}
+	if caps.GoVersion != runtime.Version() {
+		t.Errorf("expected Go version %s, got: %s", runtime.Version(), caps.GoVersion)
+	}
+	if caps.Decoder.Module != decoderModule {
+		t.Errorf("expected decoder module %s, got: %s", decoderModule, caps.Decoder.Module)
+	}
+	if caps.Decoder.Backend != BackendLibavif && caps.Decoder.Backend != BackendWasm {
+		t.Errorf("expected a known decoder backend, got: %s", caps.Decoder.Backend)
+	}
+	if len(caps.Formats) < 3 {
+		t.Errorf("expected the built-in formats, got: %v", caps.Formats)
+	}
+	if !sort.StringsAreSorted(caps.Features) {
+		t.Errorf("expected sorted features, got: %v", caps.Features)
+	}
+	if i := sort.SearchStrings(caps.Features, "jobs"); i == len(caps.Features) || caps.Features[i] != "jobs" {
+		t.Errorf("expected the features of the caller, got: %v", caps.Features)
+	}
+
+	_, tracksCPU := processCPUTime()
+	hasCPU := false
+	for _, feature := range caps.Features {
+		hasCPU = hasCPU || feature == "cpu-time"
+	}
+	if hasCPU != tracksCPU {
+		t.Errorf("expected cpu-time to be listed only where CPU time is measured, got: %v", caps.Features)
+	}
+}
@@ -0,0 +1,84 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// checksumAlgorithms are the hashes of Options.Checksum by name
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// ChecksumAlgorithms returns the names of the supported checksums, sorted
+func ChecksumAlgorithms() []string {
+	names := make([]string, 0, len(checksumAlgorithms))
+	for name := range checksumAlgorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsValidChecksum reports whether name is a supported checksum
+func IsValidChecksum(name string) bool {
+	_, ok := checksumAlgorithms[name]
+	return ok
+}
+
+// newChecksum returns a hash for opts.Checksum, or nil without one
+func newChecksum(opts Options) hash.Hash {
+	if newHash, ok := checksumAlgorithms[opts.Checksum]; ok {
+		return newHash()
+	}
+	return nil
+}
+
+// ChecksumLine formats the checksum of a file like sha256sum and its
+// relatives, e.g. "9f86d0...  image.png\n", so the line can be checked
+// with "sha256sum -c"
+func ChecksumLine(sum, name string) string {
+	return sum + "  " + filepath.ToSlash(name) + "\n"
+}
+
+// checksumSidecarPath returns the path of the checksum file written next to
+// an output, e.g. "image.png.sha256"
+func checksumSidecarPath(outputPath, algorithm string) string {
+	return outputPath + "." + algorithm
+}
+
+// rehashOutput replaces the state of sum with the checksum of the output at
+// path, for outputs changed after they were written
+func rehashOutput(out OutputFS, path string, sum hash.Hash) error {
+	file, err := out.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	sum.Reset()
+	_, err = io.Copy(sum, file)
+	return err
+}
+
+// finishChecksum records the checksum of the output of c, and writes it
+// next to the output unless opts.ChecksumManifest is set
+func finishChecksum(c *conversion, sum hash.Hash, opts Options) error {
+	c.checksum = hex.EncodeToString(sum.Sum(nil))
+	if opts.ChecksumManifest {
+		return nil
+	}
+
+	line := ChecksumLine(c.checksum, filepath.Base(c.outputPath))
+	if err := writeFile(opts.Output, checksumSidecarPath(c.outputPath, opts.Checksum), []byte(line)); err != nil {
+		return fmt.Errorf("failed to write checksum: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ==================== Checksum Tests ====================
+
+func TestIsValidChecksum(t *testing.T) {
+	for name, want := range map[string]bool{"sha256": true, "sha512": true, "md5": false, "SHA256": false, "": false} {
+		if got := IsValidChecksum(name); got != want {
+			t.Errorf("IsValidChecksum(%q): expected %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestChecksumLine(t *testing.T) {
+	if got := ChecksumLine("abc", filepath.Join("sub", "image.png")); got != "abc  sub/image.png\n" {
+		t.Errorf("expected a sha256sum line, got %q", got)
+	}
+}
+
+// sha256Of returns the hex SHA-256 of the file at path
+func sha256Of(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestConvert_Checksum(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	// Streamed and buffered outputs are hashed alike
+	for _, opts := range []Options{{Checksum: "sha256"}, {Checksum: "sha256", StripMetadata: true, Overwrite: true}} {
+		if _, err := Convert(inputPath, outputDir, opts); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		outputPath := filepath.Join(outputDir, "test.png")
+		sidecar, err := os.ReadFile(outputPath + ".sha256")
+		if err != nil {
+			t.Fatalf("expected a checksum file, got: %v", err)
+		}
+		if want := ChecksumLine(sha256Of(t, outputPath), "test.png"); string(sidecar) != want {
+			t.Errorf("expected %q, got %q", want, sidecar)
+		}
+	}
+}
+
+func TestConvertDirectory_ChecksumManifest(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	os.MkdirAll(inputDir, 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{Checksum: "sha256", ChecksumManifest: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got, want := result.Files[0].Checksum, sha256Of(t, filepath.Join(outputDir, "a.png")); got != want {
+		t.Errorf("expected checksum %s, got %s", want, got)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "a.png.sha256")); !os.IsNotExist(err) {
+		t.Errorf("expected no checksum file next to the output, got: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package converter
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// SkipReasonIdentical is the skip reason in Compare mode for existing
+	// outputs whose pixels match the new conversion
+	SkipReasonIdentical = "identical"
+	// SkipReasonChanged is the skip reason in Compare mode for existing
+	// outputs whose pixels differ from the new conversion
+	SkipReasonChanged = "changed"
+)
+
+// compareOutput encodes img like a new output would be and compares the
+// result with the existing output at path
+// It returns the PSNR between the two, see psnr
+func compareOutput(path string, img image.Image, opts Options) (float64, error) {
+	// With AutoFormat the existing output's extension tells which format it has
+	format := opts.Format
+	if opts.AutoFormat {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	encode, err := lookupEncoder(format)
+	if err != nil {
+		return 0, err
+	}
+
+	// Lossy formats are compared after a round trip, so that an unchanged
+	// conversion compares as identical
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := encode(buf, img, opts.encodeOptions()); err != nil {
+		return 0, fmt.Errorf("failed to encode %s: %w", strings.ToUpper(format), err)
+	}
+	converted, _, err := image.Decode(buf)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode new output: %w", err)
+	}
+
+	file, err := opts.Output.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open existing output: %w", err)
+	}
+	defer file.Close()
+
+	existing, _, err := image.Decode(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode existing output: %w", err)
+	}
+
+	return psnr(existing, converted), nil
+}
+
+// psnr returns the peak signal-to-noise ratio between two images in dB,
+// over all four 16-bit channels
+// Identical images give +Inf and images of different sizes give 0
+func psnr(a, b image.Image) float64 {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Dx() != bb.Dx() || ab.Dy() != bb.Dy() {
+		return 0
+	}
+
+	var sum float64
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			r1, g1, b1, a1 := a.At(ab.Min.X+x, ab.Min.Y+y).RGBA()
+			r2, g2, b2, a2 := b.At(bb.Min.X+x, bb.Min.Y+y).RGBA()
+			for _, d := range []float64{
+				float64(r1) - float64(r2),
+				float64(g1) - float64(g2),
+				float64(b1) - float64(b2),
+				float64(a1) - float64(a2),
+			} {
+				sum += d * d
+			}
+		}
+	}
+
+	if sum == 0 {
+		return math.Inf(1)
+	}
+
+	mse := sum / float64(ab.Dx()*ab.Dy()*4)
+	return 10 * math.Log10(math.MaxUint16*math.MaxUint16/mse)
+}
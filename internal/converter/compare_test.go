@@ -0,0 +1,121 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ==================== psnr Tests ====================
+
+func TestPSNR(t *testing.T) {
+	a := image.NewGray(image.Rect(0, 0, 4, 4))
+	b := image.NewGray(image.Rect(0, 0, 4, 4))
+
+	if got := psnr(a, b); !math.IsInf(got, 1) {
+		t.Errorf("expected +Inf for identical images, got: %v", got)
+	}
+
+	b.SetGray(0, 0, color.Gray{Y: 16})
+	if got := psnr(a, b); math.IsInf(got, 0) || got <= 0 {
+		t.Errorf("expected a finite positive PSNR for a small difference, got: %v", got)
+	}
+
+	if got := psnr(a, image.NewGray(image.Rect(0, 0, 4, 5))); got != 0 {
+		t.Errorf("expected 0 for different sizes, got: %v", got)
+	}
+}
+
+// ==================== Compare Tests ====================
+
+func TestConvertDirectory_Compare(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "same.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "changed.avif"))
+
+	if _, err := ConvertDirectory(inputDir, outputDir, Options{}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// Replace one output with a different image
+	changedPath := filepath.Join(outputDir, "changed.png")
+	file, err := os.Create(changedPath)
+	if err != nil {
+		t.Fatalf("failed to replace output: %v", err)
+	}
+	blue := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for i := 2; i < len(blue.Pix); i += 4 {
+		blue.Pix[i], blue.Pix[i+1] = 0xFF, 0xFF
+	}
+	if err := png.Encode(file, blue); err != nil {
+		t.Fatalf("failed to replace output: %v", err)
+	}
+	file.Close()
+	before, _ := os.ReadFile(changedPath)
+
+	createTestAVIF(t, filepath.Join(inputDir, "new.avif"))
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{Compare: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.Successful != 1 || result.SkippedReasons[SkipReasonIdentical] != 1 || result.SkippedReasons[SkipReasonChanged] != 1 {
+		t.Fatalf("expected 1 converted, 1 identical and 1 changed, got: %d converted, %v", result.Successful, result.SkippedReasons)
+	}
+
+	for _, file := range result.Files {
+		switch filepath.Base(file.InputPath) {
+		case "same.avif":
+			if !math.IsInf(file.PSNR, 1) {
+				t.Errorf("expected +Inf PSNR for an identical output, got: %v", file.PSNR)
+			}
+		case "changed.avif":
+			if file.PSNR <= 0 || math.IsInf(file.PSNR, 0) {
+				t.Errorf("expected a finite PSNR for a changed output, got: %v", file.PSNR)
+			}
+		}
+	}
+
+	if after, _ := os.ReadFile(changedPath); string(after) != string(before) {
+		t.Error("expected the changed output not to be overwritten")
+	}
+}
+
+func TestConvertDirectory_CompareLossy(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "image.avif"))
+
+	opts := Options{Format: "jpeg", Quality: 80}
+	if _, err := ConvertDirectory(inputDir, outputDir, opts); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// An unchanged lossy conversion compares as identical
+	opts.Compare = true
+	result, err := ConvertDirectory(inputDir, outputDir, opts)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.SkippedReasons[SkipReasonIdentical] != 1 {
+		t.Errorf("expected the JPEG output to be identical, got: %v", result.SkippedReasons)
+	}
+}
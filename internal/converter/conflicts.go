@@ -0,0 +1,89 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// OutputConflict is an output path claimed by more than one input file
+type OutputConflict struct {
+	OutputPath string
+	InputPaths []string
+}
+
+// FindOutputConflicts scans inputDir like ConvertDirectory and reports the
+// output paths that more than one input would be written to
+// Without a flatten separator, "a.avif" and "sub/a.avif" both become "a.png",
+// so all but the first would be skipped as already existing
+// Nothing is decoded or written; conflicts are sorted by output path
+// Numbered outputs never conflict
+func FindOutputConflicts(inputDir, outputDir string, opts Options) ([]OutputConflict, error) {
+	opts = opts.withDefaults()
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if opts.Number {
+		return nil, nil
+	}
+
+	avifFiles, err := collectAVIFFiles(inputDir, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan directory: %w", err)
+	}
+
+	now := time.Now()
+	claims := map[string][]string{}
+	for _, filePath := range avifFiles {
+		outputPath := directoryOutputPath(inputDir, filePath, templatedOutputDir(outputDir, filePath, now, opts), opts)
+		outputPath = cleanOutputPath(outputPath, opts)
+		claims[outputPath] = append(claims[outputPath], filePath)
+	}
+
+	return conflictsOf(claims), nil
+}
+
+// FindFileConflicts reports the output paths that more than one of paths
+// would be written to by ConvertFiles, such as files of the same name in
+// different directories
+func FindFileConflicts(paths []string, outputDir string, opts Options) []OutputConflict {
+	opts = opts.withDefaults()
+
+	now := time.Now()
+	claims := map[string][]string{}
+	for _, path := range paths {
+		outputPath := outputPathFor(path, templatedOutputDir(outputDir, path, now, opts), opts.Format)
+		outputPath = cleanOutputPath(outputPath, opts)
+		claims[outputPath] = append(claims[outputPath], path)
+	}
+
+	return conflictsOf(claims)
+}
+
+// FindMappedConflicts reports the output paths that more than one of files
+// would be written to by ConvertMapped
+func FindMappedConflicts(files []MappedFile, opts Options) []OutputConflict {
+	claims := map[string][]string{}
+	for _, file := range files {
+		outputPath := file.OutputPath
+		outputPath = cleanOutputPath(outputPath, opts)
+		claims[outputPath] = append(claims[outputPath], file.InputPath)
+	}
+
+	return conflictsOf(claims)
+}
+
+// conflictsOf returns the output paths claimed by more than one input,
+// sorted by output path
+func conflictsOf(claims map[string][]string) []OutputConflict {
+	var conflicts []OutputConflict
+	for outputPath, inputPaths := range claims {
+		if len(inputPaths) > 1 {
+			conflicts = append(conflicts, OutputConflict{OutputPath: outputPath, InputPaths: inputPaths})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].OutputPath < conflicts[j].OutputPath
+	})
+	return conflicts
+}
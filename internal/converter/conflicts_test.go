@@ -0,0 +1,114 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// ==================== FindOutputConflicts Tests ====================
+
+func TestFindOutputConflicts(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	for _, name := range []string{"a.avif", "b.avif", "sub/a.avif", "sub/deeper/a.avif", "sub/c.avif"} {
+		path := filepath.Join(inputDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create input dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("not decoded"), 0644); err != nil {
+			t.Fatalf("failed to create input file: %v", err)
+		}
+	}
+
+	conflicts, err := FindOutputConflicts(inputDir, outputDir, Options{Recursive: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	want := []OutputConflict{{
+		OutputPath: filepath.Join(outputDir, "a.png"),
+		InputPaths: []string{
+			filepath.Join(inputDir, "a.avif"),
+			filepath.Join(inputDir, "sub", "a.avif"),
+			filepath.Join(inputDir, "sub", "deeper", "a.avif"),
+		},
+	}}
+	if !reflect.DeepEqual(conflicts, want) {
+		t.Errorf("expected %v, got: %v", want, conflicts)
+	}
+
+	// A flatten separator gives each file a distinct name
+	conflicts, err = FindOutputConflicts(inputDir, outputDir, Options{Recursive: true, FlattenSeparator: "_"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Errorf("expected no conflicts with a flatten separator, got: %v", conflicts)
+	}
+
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Error("expected nothing to be written")
+	}
+}
+
+func TestFindOutputConflicts_SeparatorCollision(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	// "a_b/c.avif" and "a/b_c.avif" both flatten to "a_b_c.png"
+	inputDir := filepath.Join(testDir, "input")
+	for _, name := range []string{"a_b/c.avif", "a/b_c.avif"} {
+		path := filepath.Join(inputDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create input dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("not decoded"), 0644); err != nil {
+			t.Fatalf("failed to create input file: %v", err)
+		}
+	}
+
+	conflicts, err := FindOutputConflicts(inputDir, filepath.Join(testDir, "output"), Options{Recursive: true, FlattenSeparator: "_"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(conflicts) != 1 || filepath.Base(conflicts[0].OutputPath) != "a_b_c.png" {
+		t.Errorf("expected a_b_c.png to conflict, got: %v", conflicts)
+	}
+}
+
+func TestFindFileConflicts(t *testing.T) {
+	outputDir := "out"
+	paths := []string{
+		filepath.Join("a", "photo.avif"),
+		filepath.Join("b", "photo.avif"),
+		filepath.Join("b", "other.avif"),
+	}
+
+	conflicts := FindFileConflicts(paths, outputDir, Options{Format: "jpeg"})
+	want := []OutputConflict{{
+		OutputPath: filepath.Join(outputDir, "photo.jpeg"),
+		InputPaths: paths[:2],
+	}}
+	if !reflect.DeepEqual(conflicts, want) {
+		t.Errorf("expected %v, got: %v", want, conflicts)
+	}
+
+	if conflicts := FindFileConflicts(paths[1:], outputDir, Options{}); len(conflicts) != 0 {
+		t.Errorf("expected no conflicts, got: %v", conflicts)
+	}
+
+	// Names only collide once their spaces are replaced
+	spaced := []string{"my photo.avif", "my-photo.avif"}
+	if conflicts := FindFileConflicts(spaced, outputDir, Options{}); len(conflicts) != 0 {
+		t.Errorf("expected no conflicts, got: %v", conflicts)
+	}
+	conflicts = FindFileConflicts(spaced, outputDir, Options{ReplaceSpaces: "-"})
+	if len(conflicts) != 1 || conflicts[0].OutputPath != filepath.Join(outputDir, "my-photo.png") {
+		t.Errorf("expected my-photo.png to conflict, got: %v", conflicts)
+	}
+}
@@ -1,71 +1,670 @@
 package converter
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"image"
-	"image/png"
+	"image/color"
+	"io"
+	"io/fs"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	_ "github.com/gen2brain/avif"
 )
 
-// ErrFileExists is returned when an output file already exists
+// ErrFileExists is returned by ConvertDirectoryToPDF when the PDF already exists
+// Convert and ConvertDirectory report existing outputs as StatusSkipped instead
 var ErrFileExists = errors.New("output file already exists")
 
+// ErrEmptyFile is returned when an input file is too small to be a valid AVIF
+var ErrEmptyFile = errors.New("input file is empty or truncated")
+
+// ErrVerificationFailed is returned when a written output does not decode
+// back to an image of the expected size
+var ErrVerificationFailed = errors.New("output verification failed")
+
+// ErrOutputNotDir is returned when the output directory exists as a file
+var ErrOutputNotDir = errors.New("output path exists and is not a directory")
+
+// ErrOutsideOutputDir is returned for an output path that options would put
+// outside the output directory
+var ErrOutsideOutputDir = errors.New("output path is outside the output directory")
+
+// ErrAborted is returned when the user quits an interactive conversion
+var ErrAborted = errors.New("conversion aborted by user")
+
+// scanBufferSize is how many scanned paths may wait for conversion before a
+// directory scan pauses
+const scanBufferSize = 64
+
+// minAVIFSize is the size of the smallest possible ftyp box, which every
+// AVIF file starts with
+const minAVIFSize = 16
+
+const (
+	// DefaultFormat is the output format used when none is selected
+	DefaultFormat = "png"
+	// DefaultQuality is the quality used by lossy output formats
+	DefaultQuality = 90
+	// DefaultSharpenRadius is the blur radius of the unsharp mask, in pixels
+	DefaultSharpenRadius = 1.0
+	// DefaultNumberPadding is the number of digits of numbered outputs
+	DefaultNumberPadding = 4
+)
+
+// Options controls how files are converted
+type Options struct {
+	Format    string
+	Quality   int
+	Recursive bool
+	Verbose   bool
+	// Debug, with Verbose, also describes the container and codec of each
+	// input, which helps with files that convert with odd colors or fail
+	Debug bool
+	// Log receives the output of Verbose conversions, os.Stdout when nil
+	// Messages are written whole and one at a time, so a writer may be shared
+	// by concurrent conversions, or set to io.Discard to silence them
+	Log io.Writer
+	// MaxDepth limits recursive scans to files at most this many levels below
+	// the input directory; 1 only scans the directory itself and 0 is unlimited
+	MaxDepth int
+	// ScanWorkers is the number of directories a recursive scan reads at the
+	// same time; above 1, files are found in no particular order, which
+	// speeds up the scan of huge trees on slow or networked storage
+	ScanWorkers int
+	// Workers is the number of files a directory, glob, list or mapped
+	// conversion converts at the same time, one when unset, or WorkersAuto;
+	// above one, files finish, and are listed in ConversionResult.Files, in
+	// no particular order, see workerPool
+	Workers int
+	// IncludeHidden includes files whose name starts with '.' in scans
+	IncludeHidden bool
+	// Since, when set, excludes files last modified before this time
+	Since time.Time
+	// FlattenSeparator, when set, joins the subdirectories of a file
+	// (relative to the input directory) into its output name
+	FlattenSeparator string
+	// SanitizeNames makes output file names valid on every common operating
+	// system, replacing invalid and control characters and renaming reserved
+	// names such as "CON", see sanitizeName
+	SanitizeNames bool
+	// ReplaceSpaces, when set, replaces each run of spaces in output file
+	// names with it, e.g. "my photo.png" becomes "my-photo.png" with "-";
+	// it applies after SanitizeNames
+	ReplaceSpaces string
+	// FlattenHash gives an output path already claimed by an earlier input
+	// of the same run a name with a short hash of its image instead, such as
+	// "img_a1b2c3.png", so inputs sharing a name are all converted
+	FlattenHash bool
+	// Shard moves each output into subdirectories named after the first
+	// characters of its name, ShardName, or of a hash of it, ShardHash,
+	// e.g. "ab/abcdef.png", for file systems that slow down with many files
+	// in one directory; ShardWidth characters (DefaultShardWidth when 0)
+	// for each of ShardDepth levels (1 when 0)
+	Shard      string
+	ShardWidth int
+	ShardDepth int
+	// Number names the outputs of a directory conversion 1.png, 2.png, ...
+	// in the order of their sorted input paths, zero-padded to NumberPadding
+	// digits, e.g. "0001.png"; the whole directory is scanned before the
+	// first conversion, and subdirectories are flattened into the output
+	Number        bool
+	NumberPadding int
+	// Limit, when positive, converts only the first Limit files of a bulk
+	// conversion in sorted path order, the whole directory being scanned
+	// first like with Number; the rest are counted as FilterReasonLimit
+	Limit int
+	// OnError is what a bulk conversion does with files that fail,
+	// OnErrorSkip (the default when empty), OnErrorStop or
+	// OnErrorQuarantine, which moves the inputs that fail to decode to
+	// QuarantineDir
+	OnError       string
+	QuarantineDir string
+	// Checksum, when set to one of ChecksumAlgorithms, writes the checksum of
+	// each output next to it, e.g. "image.png.sha256", in the format of
+	// sha256sum; auxiliary images and items are left out
+	// ChecksumManifest records the checksums in FileResult.Checksum only,
+	// for a caller that lists them in one file
+	Checksum         string
+	ChecksumManifest bool
+	// SkipNonImages skips image sequences and files without a primary image
+	// that fail to decode, with SkipReasonSequence or SkipReasonNoImage,
+	// instead of failing them with ErrSequence or ErrNoPrimaryImage
+	SkipNonImages bool
+	// Dedupe skips files whose decoded image matches one converted earlier
+	// in the same run, with SkipReasonDuplicate; their FileResult points to
+	// the output of the first occurrence
+	Dedupe bool
+	// OutputTemplate, when set, is a subdirectory of the output directory
+	// built from date tokens, e.g. "{yyyy}/{mm}"
+	OutputTemplate string
+	// OutputTemplateNow resolves template dates from the current time
+	// instead of the input's modification time
+	OutputTemplateNow bool
+	// ExtractAux also writes auxiliary images such as alpha masks and depth
+	// maps next to the output, e.g. "img_alpha.png"
+	ExtractAux bool
+	// AllItems also writes every top-level image item of multi-image files
+	// next to the output, e.g. "img_item0.png", "img_item1.png"
+	// The main output holds the primary item either way
+	AllItems bool
+	// MetaOnly writes the ImageMetadata of each input as JSON, e.g.
+	// "img.json", read from its container without decoding the image, and
+	// no image output; options that change or encode the image do not apply
+	MetaOnly bool
+	// DryRun computes the output path of each input, and whether it would be
+	// converted or skipped, without reading the input or writing anything;
+	// options decided by decoding, such as AutoFormat or Dedupe, do not apply
+	DryRun bool
+	// PrimaryOnly converts only the image that image.Decode returns, the
+	// quickest path for previews: ExtractAux, AllItems and UseThumbnail are
+	// ignored, and GIF outputs of animated inputs keep only the first frame
+	// Grids are still reassembled, which libavif does while decoding
+	PrimaryOnly bool
+	// Passthrough sniffs the signature of inputs that decode, to find
+	// PNG, JPEG and GIF files misnamed as AVIF: those already in the output
+	// format are copied as they are, unless an option changes the image
+	// (see changesImage), and the others are converted like any input;
+	// FileResult.SourceFormat records what they were
+	Passthrough bool
+	// Overwrite replaces existing output files instead of skipping them
+	Overwrite bool
+	// Optimize tries several PNG compression levels and keeps the smallest output
+	Optimize bool
+	// MaxOutputSize, when set, is the largest output in bytes; lossy formats
+	// lower their quality to fit and lossless ones are downscaled, see
+	// ErrOverBudget
+	MaxOutputSize int64
+	// SharpenAmount, when above zero, applies an unsharp mask of that
+	// strength to downscaled images, which otherwise look soft; 0.5 is
+	// subtle and 1.5 strong
+	SharpenAmount float64
+	// SharpenRadius is the blur radius of the unsharp mask in pixels, with
+	// DefaultSharpenRadius when unset; larger radii enhance broader edges
+	SharpenRadius float64
+	// CropPct keeps a region of each image, in percentages of its decoded
+	// size, before it is rotated; the zero value keeps the whole image
+	CropPct CropPercent
+	// Rotate turns images clockwise by 90, 180 or 270 degrees right after
+	// decoding, see IsValidRotation; 0 keeps them as decoded
+	Rotate int
+	// Trim crops away uniform borders, matching the top-left pixel within
+	// TrimTolerance per 8-bit channel, before encoding; animated GIF outputs
+	// are not trimmed
+	Trim          bool
+	TrimTolerance int
+	// Canvas, when set, centers each image on a canvas of this size filled
+	// with PadColor, scaled to fit within it, after cropping, rotating and
+	// trimming; see fitCanvas
+	Canvas   image.Point
+	PadColor color.NRGBA
+	// Dimensions skips files whose decoded image is outside the bounds,
+	// before it is cropped or otherwise changed, with SkipReasonDimensions
+	Dimensions DimensionBounds
+	// NormalizeGamma converts images whose nclx colr property has another
+	// transfer function than sRGB, such as BT.709, gamma 2.2, PQ or HLG, to
+	// the sRGB one before encoding, so outputs of mixed sources look alike;
+	// color primaries are left as they are, see normalizeGamma
+	NormalizeGamma bool
+	// FixAlpha takes the color of AVIF inputs with alpha as premultiplied
+	// when no prem reference says so, and divides it by alpha before
+	// encoding, for encoders that premultiply without marking it; inputs
+	// with the reference are left as they are, since the decoder honors it
+	FixAlpha bool
+	// Interlace writes Adam7-interlaced PNG outputs, which browsers can show
+	// progressively while loading
+	Interlace bool
+	// Chroma is the chroma subsampling of JPEG and AVIF outputs, Chroma420
+	// (the default when empty) or Chroma444 for full color resolution
+	Chroma string
+	// ToAVIF reverses the conversion: PNG and JPEG files are picked up
+	// instead of AVIF files and encoded as AVIF, see InputExtensions; Format
+	// is ignored, and Quality defaults to DefaultAVIFQuality
+	ToAVIF bool
+	// Extensions are the lowercase extensions, with their dot, of the files
+	// picked up in directories and archives, such as ".avifs"; the
+	// InputExtensions of ToAVIF when empty
+	Extensions []string
+	// Dither applies Floyd–Steinberg dithering when an output is reduced to a
+	// palette, as GIF outputs are; it has no effect on outputs that keep full
+	// color
+	Dither bool
+	// Compare decodes existing outputs and compares them with a new conversion
+	// instead of skipping them; outputs are still never overwritten
+	Compare bool
+	// AutoFormat writes each image as PNG or JPEG, whichever is smaller,
+	// replacing the extension of Format in the output name
+	AutoFormat bool
+	// Exec, when set, is a command run after each successful conversion
+	// Its arguments may contain {input} and {output}; see SplitCommand
+	Exec []string
+	// Verify re-decodes each written output and checks its dimensions
+	Verify bool
+	// VerifyExisting checks existing outputs like Verify before skipping
+	// them, and converts the input again when they are not valid images of
+	// the expected size, e.g. after an interrupted write
+	VerifyExisting bool
+	// UseThumbnail converts the thumbnail embedded in the input instead of the
+	// full-resolution image, falling back to the full image if there is none
+	UseThumbnail bool
+	// ThumbnailRequired makes inputs without a thumbnail fail with
+	// ErrNoThumbnail instead of falling back
+	ThumbnailRequired bool
+	// PreserveMtime sets the modification time of outputs to that of the input
+	// It requires an Output that implements ChtimesFS
+	PreserveMtime bool
+	// StripMetadata removes any metadata, such as EXIF or ICC profiles, from
+	// encoded outputs before writing them; PPM and PAM outputs have none, and
+	// formats other than these, PNG, JPEG and GIF fail with
+	// ErrStripUnsupported
+	StripMetadata bool
+	// TrackResources measures the time, CPU and memory used by a directory
+	// conversion into ConversionResult.Resources
+	TrackResources bool
+	// Prompt, when set, is asked what to do with each existing output file
+	Prompt OverwritePrompt
+	// Output is where outputs are written, OSFS when nil
+	Output OutputFS
+	// FileMode is the permission of output files written to the default
+	// Output, see OSFS; other outputs ignore it
+	FileMode fs.FileMode
+	// TeeDirs are more output directories that each output is copied to,
+	// at the same path relative to them as to the output directory, so the
+	// image is decoded and encoded once; copies that already exist are
+	// skipped unless Overwrite, see teeOutput. ConvertMapped and ConvertJobs
+	// have no output directory and fail with them
+	TeeDirs []string
+
+	// outputBase is the output directory of a conversion, which its outputs
+	// must stay within and the TeeDirs mirror
+	outputBase string
+	// dedupe holds the images seen with Dedupe, shared by the files of a run
+	dedupe *dedupeIndex
+	// claims holds the output paths taken with FlattenHash, shared by the
+	// files of a run
+	claims *outputClaims
+	// planned holds the outputs of a DryRun, shared by the files of a run
+	planned *plannedOutputs
+}
+
+// OverwriteDecision is the answer to an overwrite prompt
+type OverwriteDecision int
+
+const (
+	// OverwriteNo skips the file
+	OverwriteNo OverwriteDecision = iota
+	// OverwriteYes replaces the file
+	OverwriteYes
+	// OverwriteAll replaces the file and every later existing file without asking
+	OverwriteAll
+	// OverwriteQuit skips the file and stops the conversion
+	OverwriteQuit
+)
+
+// OverwritePrompt decides what to do with an output file that already exists
+type OverwritePrompt func(outputPath string) OverwriteDecision
+
+// withDefaults returns a copy of opts with unset fields filled in
+func (o Options) withDefaults() Options {
+	if o.Format == "" {
+		o.Format = DefaultFormat
+	}
+	if o.ToAVIF {
+		o.Format = avifFormat
+	}
+	if o.Quality <= 0 && o.ToAVIF {
+		o.Quality = DefaultAVIFQuality
+	} else if o.Quality <= 0 {
+		o.Quality = DefaultQuality
+	}
+	if o.SharpenRadius <= 0 {
+		o.SharpenRadius = DefaultSharpenRadius
+	}
+	if o.Output == nil {
+		o.Output = OSFS{FileMode: o.FileMode}
+	}
+	if o.Dedupe && o.dedupe == nil {
+		o.dedupe = newDedupeIndex()
+	}
+	if o.FlattenHash && o.claims == nil {
+		o.claims = newOutputClaims()
+	}
+	if o.DryRun && o.planned == nil {
+		o.planned = newPlannedOutputs()
+	}
+	if o.PrimaryOnly {
+		o.ExtractAux, o.AllItems, o.UseThumbnail = false, false, false
+	}
+	return o
+}
+
+// validate checks the options that shape output paths, as the CLI does, so
+// that library callers cannot write outside the output directory either
+func (o Options) validate() error {
+	if o.FlattenSeparator != "" {
+		if err := ValidateFlattenSeparator(o.FlattenSeparator); err != nil {
+			return err
+		}
+	}
+	return ValidateOutputTemplate(o.OutputTemplate)
+}
+
+// encodeOptions returns the settings passed to the encoder
+func (o Options) encodeOptions() EncodeOptions {
+	return EncodeOptions{Quality: o.Quality, Optimize: o.Optimize, Interlace: o.Interlace, Chroma: o.Chroma, Dither: o.Dither}
+}
+
 // FileError represents an error that occurred while processing a specific file
 type FileError struct {
 	FilePath string
-	Error    error
+	// OutputPath is the output that was attempted, which may have been partly
+	// written; it is empty when the file has no output of its own, as in PDF mode
+	OutputPath string
+	Error      error
+}
+
+// FileStatus describes the outcome of converting a single file
+type FileStatus string
+
+const (
+	StatusConverted FileStatus = "converted"
+	// StatusOverwritten is a conversion that replaced an existing output
+	StatusOverwritten FileStatus = "overwritten"
+	StatusSkipped     FileStatus = "skipped"
+	StatusFailed      FileStatus = "failed"
+)
+
+// Succeeded reports whether the status is a successful conversion
+func (s FileStatus) Succeeded() bool {
+	return s == StatusConverted || s == StatusOverwritten
 }
 
+// FileResult records what happened to a single file during a bulk conversion
+type FileResult struct {
+	InputPath  string
+	OutputPath string
+	Status     FileStatus
+	InputSize  int64
+	OutputSize int64
+	// Width and Height are the dimensions of the decoded image, after any
+	// trimming, zero if decoding failed
+	Width  int
+	Height int
+	// Duration is the time spent decoding and writing the file
+	Duration time.Duration
+	// Format is the output format of a converted file, which varies per file
+	// with AutoFormat
+	Format string
+	// SkipReason explains why a skipped file was not converted
+	SkipReason string
+	// SourceFormat is the format of an input found not to be AVIF with
+	// Options.Passthrough, e.g. "png"
+	SourceFormat string
+	// QuarantinePath is where a failed input was moved with OnErrorQuarantine
+	QuarantinePath string
+	// Checksum is the hex checksum of the output with Options.Checksum, for
+	// converted files
+	Checksum string
+	// DuplicateOf is the input whose image a file with SkipReasonDuplicate
+	// repeats; OutputPath is then the output of that input
+	DuplicateOf string
+	// Warning is set for converted images that look blank, either
+	// WarningTransparent or WarningSingleColor
+	Warning string
+	// PSNR compares a new conversion with the existing output in Compare
+	// mode, in dB; it is +Inf for identical outputs, see SkipReasonChanged
+	PSNR  float64
+	Error error
+}
+
+// SkipReasonExists is the skip reason for files whose output already exists
+const SkipReasonExists = "already exists"
+
+// Reasons an AVIF file is left out of a scan, which are keys of
+// ConversionResult.FilteredReasons
+const (
+	// FilterReasonHidden is for hidden files, without opts.IncludeHidden
+	FilterReasonHidden = "hidden"
+	// FilterReasonSince is for files modified before opts.Since
+	FilterReasonSince = "older than since"
+	// FilterReasonLimit is for files not processed once opts.Limit is reached
+	FilterReasonLimit = "limit reached"
+)
+
 // ConversionResult holds the results of a bulk conversion operation
+// Empty counts the subset of failed files that were empty or truncated
+// SkippedReasons counts skipped files by reason; Skipped returns the total
+// FilteredReasons counts the AVIF files left out of the scan by reason, which
+// are not part of TotalFiles; Filtered returns the total
+// Only Progress may be called while the conversion is still running
 type ConversionResult struct {
-	TotalFiles int
-	Successful int
-	Skipped    int
-	Failed     int
-	Errors     []FileError
+	TotalFiles      int
+	Successful      int
+	SkippedReasons  map[string]int
+	FilteredReasons map[string]int
+	Failed          int
+	Empty           int
+	Errors          []FileError
+	Files           []FileResult
+	// Resources is the usage measured with Options.TrackResources, or nil
+	Resources *ResourceUsage
+
+	// processed and total back Progress and are updated atomically
+	processed atomic.Int64
+	total     atomic.Int64
+	// avgDuration is the moving average behind ETA, in nanoseconds
+	avgDuration atomic.Int64
+	// workers is the number of files converted at the same time, by which
+	// ETA divides the time left
+	workers atomic.Int64
+	// mu guards the other fields while several files are converted at the
+	// same time
+	mu sync.Mutex
+}
+
+// Skipped returns the total number of skipped files, across all reasons
+func (r *ConversionResult) Skipped() int {
+	total := 0
+	for _, count := range r.SkippedReasons {
+		total += count
+	}
+	return total
+}
+
+// Filtered returns the total number of AVIF files left out of the scan
+func (r *ConversionResult) Filtered() int {
+	total := 0
+	for _, count := range r.FilteredReasons {
+		total += count
+	}
+	return total
+}
+
+// ExtensionCount is the number of files of a conversion with one extension
+type ExtensionCount struct {
+	// Extension is written as in the file names, e.g. ".AVIF", so names
+	// that differ in case are counted apart; empty for names without one
+	Extension string
+	// Seen counts the files processed, whatever their status, and Converted
+	// those converted or overwritten
+	Seen      int
+	Converted int
+}
+
+// ExtensionCounts tallies Files by extension, most seen first, which shows
+// which spellings of the input extensions a scan picked up
+func (r *ConversionResult) ExtensionCounts() []ExtensionCount {
+	index := map[string]int{}
+	var counts []ExtensionCount
+	for _, file := range r.Files {
+		ext := filepath.Ext(file.InputPath)
+		i, ok := index[ext]
+		if !ok {
+			i = len(counts)
+			index[ext] = i
+			counts = append(counts, ExtensionCount{Extension: ext})
+		}
+		counts[i].Seen++
+		if file.Status == StatusConverted || file.Status == StatusOverwritten {
+			counts[i].Converted++
+		}
+	}
+
+	sort.Slice(counts, func(a, b int) bool {
+		if counts[a].Seen != counts[b].Seen {
+			return counts[a].Seen > counts[b].Seen
+		}
+		return counts[a].Extension < counts[b].Extension
+	})
+	return counts
+}
+
+// addSkip counts a skipped file under reason
+func (r *ConversionResult) addSkip(reason string) {
+	if r.SkippedReasons == nil {
+		r.SkippedReasons = map[string]int{}
+	}
+	r.SkippedReasons[reason]++
+}
+
+// Progress returns the fraction of files processed so far, from 0 to 1
+// It is safe to call from another goroutine while a conversion is in flight
+// Files are counted as the input directory is scanned, so while the scan is
+// still running the total grows and Progress may move backwards
+func (r *ConversionResult) Progress() float64 {
+	total := r.total.Load()
+	if total == 0 {
+		return 0
+	}
+	return float64(r.processed.Load()) / float64(total)
+}
+
+// Counts returns the number of files processed so far and the total, which
+// grows while the scan runs, like Progress
+// It is safe to call from another goroutine while a conversion is in flight
+func (r *ConversionResult) Counts() (processed, total int) {
+	return int(r.processed.Load()), int(r.total.Load())
 }
 
 // collectAVIFFiles scans a directory for AVIF files
-// If recursive is true, it scans subdirectories as well
-// Hidden files (starting with '.') are skipped
-func collectAVIFFiles(rootDir string, recursive bool) ([]string, error) {
+// If opts.Recursive is true, it scans subdirectories as well, down to
+// opts.MaxDepth levels when it is set
+// Hidden files (starting with '.') are skipped unless opts.IncludeHidden is set
+func collectAVIFFiles(rootDir string, opts Options) ([]string, error) {
+	return collectAVIFFilesFiltered(rootDir, opts, nil)
+}
+
+// collectAVIFFilesFiltered is like collectAVIFFiles but passes the files
+// left out by a filter to filtered, like walkAVIFFiles
+func collectAVIFFilesFiltered(rootDir string, opts Options, filtered func(reason string)) ([]string, error) {
+	var avifFiles []string
+	err := walkAVIFFiles(rootDir, opts, func(path string) error {
+		avifFiles = append(avifFiles, path)
+		return nil
+	}, filtered)
+	if err != nil {
+		return nil, err
+	}
+	return avifFiles, nil
+}
+
+// walkAVIFFiles calls fn with each AVIF file in a directory as it is found,
+// selecting files like collectAVIFFiles
+// AVIF files left out by a filter are passed to filtered with the reason,
+// unless it is nil
+// An error returned by fn stops the walk and is returned
+func walkAVIFFiles(rootDir string, opts Options, fn func(path string) error, filtered func(reason string)) error {
+	return walkAVIFFilesFS(os.DirFS(rootDir), opts, func(relPath string) error {
+		return fn(filepath.Join(rootDir, filepath.FromSlash(relPath)))
+	}, filtered)
+}
+
+// walkAVIFFilesSorted is like walkAVIFFiles but scans the whole directory
+// first, then calls fn with each AVIF file in path order
+func walkAVIFFilesSorted(rootDir string, opts Options, fn func(path string) error, filtered func(reason string)) error {
+	avifFiles, err := collectAVIFFilesFiltered(rootDir, opts, filtered)
+	if err != nil {
+		return err
+	}
+	sort.Strings(avifFiles)
+	for _, path := range avifFiles {
+		if err := fn(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectAVIFFilesFS scans fsys for AVIF files, starting at its root
+// The returned paths are slash-separated and relative to the root of fsys
+func collectAVIFFilesFS(fsys fs.FS, opts Options) ([]string, error) {
 	var avifFiles []string
+	err := walkAVIFFilesFS(fsys, opts, func(path string) error {
+		avifFiles = append(avifFiles, path)
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return avifFiles, nil
+}
 
-	if recursive {
-		err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+// walkAVIFFilesFS is like walkAVIFFiles for the files of fsys, passing
+// slash-separated paths relative to its root
+func walkAVIFFilesFS(fsys fs.FS, opts Options, fn func(path string) error, filtered func(reason string)) error {
+	// visit passes entry to fn if it is selected, or its reason to filtered
+	visit := func(path string, entry fs.DirEntry) error {
+		if !isInputName(entry.Name(), opts) {
+			return nil
+		}
+
+		reason, err := filterReason(entry, opts)
+		if err != nil {
+			return err
+		}
+		if reason == "" {
+			return fn(path)
+		}
+		if filtered != nil {
+			filtered(reason)
+		}
+		return nil
+	}
+
+	if opts.Recursive && opts.ScanWorkers > 1 {
+		return walkParallel(fsys, opts.ScanWorkers, opts.MaxDepth, visit)
+	}
+	if opts.Recursive {
+		return fs.WalkDir(fsys, ".", func(path string, entry fs.DirEntry, err error) error {
 			if err != nil {
 				return err
 			}
 
-			// Skip directories
-			if info.IsDir() {
-				return nil
-			}
-
-			// Skip hidden files
-			if strings.HasPrefix(info.Name(), ".") {
+			// Skip directories, and do not descend below opts.MaxDepth
+			if entry.IsDir() {
+				if opts.MaxDepth > 0 && path != "." && strings.Count(path, "/")+1 >= opts.MaxDepth {
+					return fs.SkipDir
+				}
 				return nil
 			}
 
-			// Check for .avif extension (case-insensitive)
-			if strings.ToLower(filepath.Ext(info.Name())) == ".avif" {
-				avifFiles = append(avifFiles, path)
-			}
-
-			return nil
+			return visit(path, entry)
 		})
-		return avifFiles, err
 	}
 
 	// Non-recursive: only scan immediate directory
-	entries, err := os.ReadDir(rootDir)
+	entries, err := fs.ReadDir(fsys, ".")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read directory: %w", err)
+		return fmt.Errorf("failed to read directory: %w", err)
 	}
 
 	for _, entry := range entries {
@@ -74,132 +673,1136 @@ func collectAVIFFiles(rootDir string, recursive bool) ([]string, error) {
 			continue
 		}
 
-		// Skip hidden files
-		if strings.HasPrefix(entry.Name(), ".") {
-			continue
+		if err := visit(entry.Name(), entry); err != nil {
+			return err
 		}
+	}
 
-		// Check for .avif extension (case-insensitive)
-		if strings.ToLower(filepath.Ext(entry.Name())) == ".avif" {
-			avifFiles = append(avifFiles, filepath.Join(rootDir, entry.Name()))
-		}
+	return nil
+}
+
+// hiddenFilter returns FilterReasonHidden for hidden files (starting with
+// '.') unless opts.IncludeHidden is set, and "" otherwise
+func hiddenFilter(name string, opts Options) string {
+	if !opts.IncludeHidden && strings.HasPrefix(name, ".") {
+		return FilterReasonHidden
 	}
+	return ""
+}
 
-	return avifFiles, nil
+// filterReason returns why an AVIF file is left out of a scan, or "" if it
+// is selected
+func filterReason(entry fs.DirEntry, opts Options) (string, error) {
+	if reason := hiddenFilter(entry.Name(), opts); reason != "" {
+		return reason, nil
+	}
+
+	recent, err := isRecentEnough(entry, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file info: %w", err)
+	}
+	if !recent {
+		return FilterReasonSince, nil
+	}
+	return "", nil
 }
 
-// ConvertDirectory converts all AVIF files in a directory to PNG format
-// It returns a ConversionResult with statistics about the operation
-func ConvertDirectory(inputDir, outputDir string, recursive, verbose bool) (*ConversionResult, error) {
-	// Collect all AVIF files
-	avifFiles, err := collectAVIFFiles(inputDir, recursive)
+// isRecentEnough reports whether a file passes the opts.Since filter
+func isRecentEnough(entry fs.DirEntry, opts Options) (bool, error) {
+	if opts.Since.IsZero() {
+		return true, nil
+	}
+
+	info, err := entry.Info()
 	if err != nil {
-		return nil, fmt.Errorf("failed to scan directory: %w", err)
+		return false, err
 	}
 
-	result := &ConversionResult{
-		TotalFiles: len(avifFiles),
-		Errors:     []FileError{},
+	return !info.ModTime().Before(opts.Since), nil
+}
+
+// ConvertDirectory converts all AVIF files in a directory to the output format
+// It returns a ConversionResult with statistics about the operation
+// The result is never nil, even when an error is returned, so callers can
+// inspect what was accomplished before the failure
+func ConvertDirectory(inputDir, outputDir string, opts Options) (*ConversionResult, error) {
+	return ConvertDirectoryContext(context.Background(), inputDir, outputDir, opts)
+}
+
+// ConvertDirectoryContext is like ConvertDirectory but stops before the next
+// file once ctx is cancelled, returning the partial result and ctx.Err()
+func ConvertDirectoryContext(ctx context.Context, inputDir, outputDir string, opts Options) (*ConversionResult, error) {
+	result := &ConversionResult{}
+	err := ConvertDirectoryInto(ctx, inputDir, outputDir, opts, result)
+	return result, err
+}
+
+// ConvertDirectoryInto is like ConvertDirectoryContext but records into a
+// caller-provided result, so its Progress can be polled while converting
+func ConvertDirectoryInto(ctx context.Context, inputDir, outputDir string, opts Options, result *ConversionResult) error {
+	if result.Errors == nil {
+		result.Errors = []FileError{}
+	}
+	if result.Files == nil {
+		result.Files = []FileResult{}
+	}
+	if result.SkippedReasons == nil {
+		result.SkippedReasons = map[string]int{}
+	}
+	if result.FilteredReasons == nil {
+		result.FilteredReasons = map[string]int{}
 	}
 
-	// If no files found, return early
-	if result.TotalFiles == 0 {
-		return result, nil
+	opts = opts.withDefaults()
+	opts.outputBase = outputDir
+	if _, err := lookupEncoder(opts.Format); err != nil {
+		return err
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	if err := checkOutputDir(opts.Output, outputDir); err != nil {
+		return err
 	}
 
-	if verbose {
+	if opts.Verbose {
 		recursiveMsg := ""
-		if recursive {
+		if opts.Recursive {
 			recursiveMsg = " (recursive)"
 		}
-		fmt.Printf("📂 Processing directory: %s%s\n", inputDir, recursiveMsg)
-		fmt.Printf("📊 Found %d AVIF file(s)\n", result.TotalFiles)
+		opts.logf("📂 Processing directory: %s%s\n", inputDir, recursiveMsg)
+	}
+
+	if opts.TrackResources {
+		stopTracking := trackResources()
+		defer func() { result.Resources = stopTracking() }()
+	}
+
+	// Files are converted as the scan finds them, so the first conversion
+	// starts right away and large trees are never listed in memory at once
+	scanCtx, stopScan := context.WithCancel(ctx)
+	defer stopScan()
+	avifFiles := make(chan string, scanBufferSize)
+	scanDone := make(chan error, 1)
+
+	// The scan may still be running when this returns early, so filtered
+	// files are counted apart and copied into result under the lock
+	var filteredMu sync.Mutex
+	filtered := map[string]int{}
+	// Numbered outputs and limits follow the sorted list of inputs, so it is
+	// built first
+	walk := walkAVIFFiles
+	if opts.Number || opts.Limit > 0 {
+		walk = walkAVIFFilesSorted
 	}
+	go func() {
+		defer close(avifFiles)
+		found := 0
+		scanDone <- walk(inputDir, opts, func(path string) error {
+			if opts.Limit > 0 && found >= opts.Limit {
+				filteredMu.Lock()
+				filtered[FilterReasonLimit]++
+				filteredMu.Unlock()
+				return nil
+			}
+			found++
+
+			// Count the file before handing it over so Progress never exceeds 1
+			// Prefer a free buffer slot, so files found before a cancellation still count
+			result.total.Add(1)
+			select {
+			case avifFiles <- path:
+				return nil
+			default:
+			}
+			select {
+			case avifFiles <- path:
+				return nil
+			case <-scanCtx.Done():
+				result.total.Add(-1)
+				return scanCtx.Err()
+			}
+		}, func(reason string) {
+			filteredMu.Lock()
+			filtered[reason]++
+			filteredMu.Unlock()
+		})
+	}()
+	defer func() {
+		result.TotalFiles = int(result.total.Load())
+		filteredMu.Lock()
+		defer filteredMu.Unlock()
+		for reason, count := range filtered {
+			result.FilteredReasons[reason] += count
+		}
+	}()
+
+	// Per-file progress is reported here, not by convertFile
+	fileOpts := opts
+	fileOpts.Verbose = false
+
+	// Template dates taken from the current time are fixed for the whole run
+	now := time.Now()
+
+	pool := newWorkerPool(result, opts, &fileOpts)
+	defer pool.wait()
 
 	// Process each file
-	for i, filePath := range avifFiles {
-		if verbose {
-			fmt.Printf("  [%d/%d] Converting %s... ", i+1, result.TotalFiles, filepath.Base(filePath))
+	i := 0
+	for filePath := range avifFiles {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// The total is the number of files found so far while the scan runs
+		i++
+		line := ""
+		if opts.Verbose {
+			line = fmt.Sprintf("  %s Converting %s... ", result.progressLabel(i, result.total.Load()), filepath.Base(filePath))
+		}
+
+		fileResult := FileResult{
+			InputPath:  filePath,
+			OutputPath: directoryOutputPath(inputDir, filePath, templatedOutputDir(outputDir, filePath, now, opts), opts),
+		}
+		if opts.Number {
+			fileResult.OutputPath = numberedOutputPath(outputDir, i, opts)
+		}
+		if info, statErr := os.Stat(filePath); statErr == nil {
+			fileResult.InputSize = info.Size()
+		}
+
+		if err := pool.convert(source{path: filePath}, fileResult, line); err != nil {
+			return err
+		}
+	}
+
+	if err := pool.wait(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := <-scanDone; err != nil {
+		return fmt.Errorf("failed to scan directory: %w", err)
+	}
+
+	return nil
+}
+
+// ConvertFiles converts a list of AVIF files, such as the matches of a glob
+// pattern, into outputDir like ConvertDirectoryInto; each output is named
+// after its input, as with Convert, so inputs sharing a name are skipped
+// after the first
+// It stops before the next file once ctx is cancelled, returning the
+// partial result and ctx.Err()
+func ConvertFiles(ctx context.Context, paths []string, outputDir string, opts Options) (*ConversionResult, error) {
+	opts = opts.withDefaults()
+	if err := opts.validate(); err != nil {
+		return newFilesResult(len(paths)), err
+	}
+	if err := checkOutputDir(opts.Output, outputDir); err != nil {
+		return newFilesResult(len(paths)), err
+	}
+	opts.outputBase = outputDir
+
+	now := time.Now()
+	files := make([]MappedFile, len(paths))
+	for i, path := range paths {
+		files[i] = MappedFile{
+			InputPath:  path,
+			OutputPath: outputPathFor(path, templatedOutputDir(outputDir, path, now, opts), opts.Format),
+		}
+	}
+	return ConvertMapped(ctx, files, opts)
+}
+
+// MappedFile is an input file and the path its output is written to
+type MappedFile struct {
+	InputPath  string
+	OutputPath string
+}
+
+// ConvertMapped converts each file to its own output path, creating the
+// directories it needs, like ConvertFiles
+// With Options.AutoFormat, the extension of an output path is replaced by
+// the format picked for its image
+// With Options.Limit, only the first files are converted, in the given order
+func ConvertMapped(ctx context.Context, files []MappedFile, opts Options) (*ConversionResult, error) {
+	limited := 0
+	if opts.Limit > 0 && len(files) > opts.Limit {
+		files, limited = files[:opts.Limit], len(files)-opts.Limit
+	}
+	result := newFilesResult(len(files))
+	if limited > 0 {
+		result.FilteredReasons[FilterReasonLimit] = limited
+	}
+
+	opts = opts.withDefaults()
+	if _, err := lookupEncoder(opts.Format); err != nil {
+		return result, err
+	}
+
+	if opts.TrackResources {
+		stopTracking := trackResources()
+		defer func() { result.Resources = stopTracking() }()
+	}
+
+	// Per-file progress is reported here, not by convertFile
+	fileOpts := opts
+	fileOpts.Verbose = false
+
+	pool := newWorkerPool(result, opts, &fileOpts)
+	defer pool.wait()
+
+	for i, file := range files {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		line := ""
+		if opts.Verbose {
+			line = fmt.Sprintf("  %s Converting %s... ", result.progressLabel(i+1, int64(len(files))), file.InputPath)
+		}
+
+		fileResult := FileResult{InputPath: file.InputPath, OutputPath: file.OutputPath}
+		if info, statErr := os.Stat(file.InputPath); statErr == nil {
+			fileResult.InputSize = info.Size()
+		}
+
+		if err := pool.convert(source{path: file.InputPath}, fileResult, line); err != nil {
+			return result, err
+		}
+	}
+
+	return result, pool.wait()
+}
+
+// newFilesResult returns the empty result of a conversion of n files
+func newFilesResult(n int) *ConversionResult {
+	result := &ConversionResult{
+		Errors:          []FileError{},
+		Files:           []FileResult{},
+		SkippedReasons:  map[string]int{},
+		FilteredReasons: map[string]int{},
+	}
+	result.total.Store(int64(n))
+	result.TotalFiles = n
+	return result
+}
+
+// convertInto converts src to fileResult.OutputPath and records the outcome
+// in result, printing it in verbose mode
+// An OverwriteAll answer sets fileOpts.Overwrite for the files that follow
+func convertInto(result *ConversionResult, src source, fileResult FileResult, fileOpts *Options, verbose bool) OverwriteDecision {
+	// Bulk conversions stop between files, so a file is never left half done
+	c, decision, err := convertWithPrompt(context.Background(), src, fileResult.OutputPath, *fileOpts)
+	fileResult.OutputPath = c.outputPath
+	fileResult.Status = c.status
+	fileResult.Width, fileResult.Height = c.width, c.height
+	fileResult.Duration = c.duration
+	if c.compared {
+		fileResult.PSNR = c.psnr
+	}
+	if decision == OverwriteAll {
+		fileOpts.Overwrite = true
+	}
+
+	result.mu.Lock()
+	defer result.mu.Unlock()
+	switch c.status {
+	case StatusSkipped:
+		result.addSkip(c.skipReason)
+		fileResult.SkipReason = c.skipReason
+		fileResult.DuplicateOf = c.duplicateOf
+		if c.duplicateOf != "" {
+			if info, statErr := fileOpts.Output.Stat(fileResult.OutputPath); statErr == nil {
+				fileResult.OutputSize = info.Size()
+			}
+		}
+		if verbose && c.duplicateOf != "" {
+			fileOpts.logf("⚠️  Skipped (%s of %s)\n", c.skipReason, filepath.Base(c.duplicateOf))
+		} else if verbose {
+			fileOpts.logf("⚠️  Skipped (%s)\n", c.skipReason)
+		}
+	case StatusFailed:
+		result.Failed++
+		if errors.Is(err, ErrEmptyFile) {
+			result.Empty++
+		}
+		if fileOpts.OnError == OnErrorQuarantine && c.decodeFailed {
+			if dest, qErr := quarantine(src, fileOpts.QuarantineDir); qErr != nil {
+				err = fmt.Errorf("%w (failed to quarantine: %v)", err, qErr)
+			} else {
+				fileResult.QuarantinePath = dest
+			}
+		}
+		fileResult.Error = err
+		result.Errors = append(result.Errors, FileError{
+			FilePath:   src.path,
+			OutputPath: c.outputPath,
+			Error:      err,
+		})
+		if verbose && fileResult.QuarantinePath != "" {
+			fileOpts.logf("❌ Failed: %v (quarantined)\n", err)
+		} else if verbose {
+			fileOpts.logf("❌ Failed: %v\n", err)
+		}
+	default:
+		result.Successful++
+		fileResult.Format = strings.TrimPrefix(filepath.Ext(c.outputPath), ".")
+		fileResult.Warning = c.warning
+		fileResult.Checksum = c.checksum
+		fileResult.SourceFormat = c.sourceFormat
+		if info, statErr := fileOpts.Output.Stat(fileResult.OutputPath); statErr == nil {
+			fileResult.OutputSize = info.Size()
+		}
+		var notes []string
+		if fileOpts.AutoFormat {
+			notes = append(notes, fileResult.Format)
+		}
+		if c.budget != "" {
+			notes = append(notes, c.budget)
 		}
+		if c.renamed {
+			notes = append(notes, "saved as "+filepath.Base(c.outputPath))
+		}
+		if c.passedThrough {
+			notes = append(notes, c.sourceFormat+" input copied as is")
+		} else if c.sourceFormat != "" {
+			notes = append(notes, c.sourceFormat+" input")
+		}
+		if c.warning != "" {
+			notes = append(notes, "looks blank: "+c.warning)
+		}
+		if verbose && len(notes) > 0 {
+			fileOpts.logf("✅ (%s)\n", strings.Join(notes, ", "))
+		} else if verbose {
+			fileOpts.logf("✅\n")
+		}
+	}
+
+	if verbose && c.sourceInfo != "" {
+		fileOpts.logf("      🔬 %s\n", c.sourceInfo)
+	}
+	if verbose && len(c.boxes) > 0 {
+		fileOpts.logf("      🧱 %s\n", strings.Join(c.boxes, "\n         "))
+	}
+
+	result.Files = append(result.Files, fileResult)
+	result.recordDuration(c.duration)
+	result.processed.Add(1)
+	return decision
+}
+
+// CheckOutputDir returns ErrOutputNotDir if dir exists but is not a
+// directory; a missing directory is fine, since it is created when needed
+func CheckOutputDir(dir string) error {
+	return checkOutputDir(OSFS{}, dir)
+}
+
+// outputPathFor returns the path that an input file is written to for a given format
+func outputPathFor(inputPath, outputDir, format string) string {
+	baseName := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
+	return filepath.Join(outputDir, baseName+"."+format)
+}
+
+// numberedOutputPath returns the path of the n-th output of a numbered
+// directory conversion, e.g. "0007.png" with a padding of 4 digits
+func numberedOutputPath(outputDir string, n int, opts Options) string {
+	return filepath.Join(outputDir, fmt.Sprintf("%0*d.%s", opts.NumberPadding, n, opts.Format))
+}
+
+// ValidateFlattenSeparator checks that a flatten separator keeps flattened
+// names within the output directory: no path separators, no "..", no NUL,
+// and more than spaces
+func ValidateFlattenSeparator(separator string) error {
+	if strings.TrimSpace(separator) == "" || strings.ContainsAny(separator, "/\\\x00") || strings.Contains(separator, "..") {
+		return fmt.Errorf("invalid flatten separator %q: use characters allowed in file names, such as _ or -", separator)
+	}
+	return nil
+}
+
+// directoryOutputPath returns the output path for a file found in inputDir
+// With a flatten separator, "sub/dir/img.avif" becomes "sub_dir_img.png"
+func directoryOutputPath(inputDir, inputPath, outputDir string, opts Options) string {
+	if opts.FlattenSeparator == "" {
+		return outputPathFor(inputPath, outputDir, opts.Format)
+	}
+
+	relPath, err := filepath.Rel(inputDir, inputPath)
+	if err != nil {
+		return outputPathFor(inputPath, outputDir, opts.Format)
+	}
+
+	relDir := filepath.Dir(relPath)
+	if relDir == "." {
+		return outputPathFor(inputPath, outputDir, opts.Format)
+	}
+
+	prefix := strings.Join(strings.Split(relDir, string(filepath.Separator)), opts.FlattenSeparator)
+	baseName := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
+	return filepath.Join(outputDir, prefix+opts.FlattenSeparator+baseName+"."+opts.Format)
+}
+
+// AVIFToPNG converts an AVIF file to PNG format
+// It returns the outcome like Convert
+func AVIFToPNG(inputPath, outputDir string, verbose bool) (FileStatus, error) {
+	return AVIFToPNGContext(context.Background(), inputPath, outputDir, Options{Verbose: verbose})
+}
+
+// AVIFToPNGContext is like AVIFToPNG but gives up once ctx is cancelled,
+// like ConvertContext, and takes the other options of the conversion in
+// opts, whose format is always PNG
+func AVIFToPNGContext(ctx context.Context, inputPath, outputDir string, opts Options) (FileStatus, error) {
+	opts.Format, opts.ToAVIF, opts.AutoFormat = "png", false, false
+	return ConvertContext(ctx, inputPath, outputDir, opts)
+}
+
+// Convert converts an AVIF file to the format selected in opts
+// The output file is named after the input, with the format as extension
+// It returns StatusSkipped and a nil error when the output already exists,
+// and StatusFailed alongside any error
+func Convert(inputPath, outputDir string, opts Options) (FileStatus, error) {
+	return ConvertContext(context.Background(), inputPath, outputDir, opts)
+}
+
+// ConvertContext is like Convert but gives up once ctx is cancelled, e.g.
+// when the client of an HTTP handler goes away, returning StatusFailed and
+// ctx.Err()
+// Decoding cannot be interrupted, so the context is checked before and after
+// it; encoding stops at its next write, and a partly written output is
+// removed
+func ConvertContext(ctx context.Context, inputPath, outputDir string, opts Options) (FileStatus, error) {
+	file, err := ConvertFileContext(ctx, inputPath, outputDir, opts)
+	return file.Status, err
+}
+
+// ConvertFileContext is like ConvertContext but describes the outcome in a
+// FileResult, whose SkipReason tells why a skipped file was not converted
+// Only the paths, status, skip reason, dimensions and duration are set
+func ConvertFileContext(ctx context.Context, inputPath, outputDir string, opts Options) (FileResult, error) {
+	file := FileResult{InputPath: inputPath, Status: StatusFailed}
+	opts = opts.withDefaults()
+	if err := opts.validate(); err != nil {
+		return file, err
+	}
+	if err := checkOutputDir(opts.Output, outputDir); err != nil {
+		return file, err
+	}
+	opts.outputBase = outputDir
+	outputDir = templatedOutputDir(outputDir, inputPath, time.Now(), opts)
+	c, decision, err := convertWithPrompt(ctx, source{path: inputPath}, outputPathFor(inputPath, outputDir, opts.Format), opts)
+	file.OutputPath, file.Status, file.SkipReason = c.outputPath, c.status, c.skipReason
+	file.Width, file.Height, file.Duration = c.width, c.height, c.duration
+	if decision == OverwriteQuit {
+		return file, ErrAborted
+	}
+	return file, err
+}
+
+// convertWithPrompt converts a file, asking opts.Prompt whether to replace
+// the output if it already exists
+// It describes the conversion like convertFile and sets its status; a file
+// whose output already exists is skipped without an error
+// The decision is OverwriteNo when no prompt was shown
+func convertWithPrompt(ctx context.Context, src source, outputPath string, opts Options) (conversion, OverwriteDecision, error) {
+	decision := OverwriteNo
+	c, err := convertFile(ctx, src, outputPath, opts)
+	if errors.Is(err, ErrFileExists) && opts.Prompt != nil && !opts.Overwrite && !c.compared {
+		decision = opts.Prompt(c.outputPath)
+		if decision == OverwriteYes || decision == OverwriteAll {
+			// The image decoded for the first run is converted again as is
+			opts.Overwrite = true
+			if c.decoded != nil {
+				src.decoded, src.modTime = c.decoded, c.modTime
+			}
+			c, err = convertFile(ctx, src, outputPath, opts)
+		}
+	}
+
+	switch {
+	case errors.Is(err, ErrFileExists) && c.compared:
+		c.status, c.skipReason, err = StatusSkipped, SkipReasonIdentical, nil
+		if !math.IsInf(c.psnr, 1) {
+			c.skipReason = SkipReasonChanged
+		}
+	case errors.Is(err, ErrFileExists):
+		c.status, c.skipReason, err = StatusSkipped, SkipReasonExists, nil
+	case errors.Is(err, errDuplicate):
+		c.status, c.skipReason, err = StatusSkipped, SkipReasonDuplicate, nil
+	case errors.Is(err, errOutsideDimensions):
+		c.status, c.skipReason, err = StatusSkipped, SkipReasonDimensions, nil
+	case opts.SkipNonImages && nonImageSkipReason(err) != "":
+		c.status, c.skipReason, err = StatusSkipped, nonImageSkipReason(err), nil
+	case err != nil:
+		c.status = StatusFailed
+	case c.overwritten:
+		c.status = StatusOverwritten
+	default:
+		c.status = StatusConverted
+	}
+
+	return c, decision, err
+}
 
-		err := AVIFToPNG(filePath, outputDir, false)
+// source is an input to convert
+// data, when set, holds its contents so that nothing is read from path, as
+// for archive entries; path then only names the input, and modTime stands in
+// for the modification time of the file
+// decoded, when set, is the image decoded from the source by an earlier
+// run, with modTime as its modification time, so it is not decoded again
+type source struct {
+	path    string
+	data    []byte
+	modTime time.Time
+	decoded image.Image
+}
 
+// read returns the contents of the source
+func (s source) read() ([]byte, error) {
+	if s.data != nil {
+		return s.data, nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+	return data, nil
+}
+
+// decode decodes the image of the source selected by opts, like decodeInput,
+// and returns it with the modification time of the source
+func (s source) decode(opts Options) (image.Image, time.Time, error) {
+	if s.decoded != nil {
+		return s.decoded, s.modTime, nil
+	}
+	if s.data == nil {
+		img, info, err := decodeInput(s.path, opts)
 		if err != nil {
-			if errors.Is(err, ErrFileExists) {
-				// File already exists, skip it
-				result.Skipped++
-				if verbose {
-					fmt.Println("⚠️  Skipped (already exists)")
+			return nil, time.Time{}, err
+		}
+		return img, info.ModTime(), nil
+	}
+
+	img, err := decodeInputData(s.data, opts)
+	return img, s.modTime, err
+}
+
+// conversion describes a single run of convertFile
+type conversion struct {
+	// outputPath differs from the requested path when opts.AutoFormat picks
+	// another format or an existing output is found
+	outputPath string
+	// width and height are those of the decoded image, zero if decoding failed
+	width, height int
+	// overwritten is set when an existing output was replaced
+	overwritten bool
+	duration    time.Duration
+	// compared is set when an existing output was compared in Compare mode,
+	// with psnr as the result
+	compared bool
+	psnr     float64
+	// budget describes the quality or scale chosen to fit opts.MaxOutputSize
+	budget string
+	// sourceInfo describes the container and codec of the input with opts.Debug
+	sourceInfo string
+	// boxes lists the box structure of an input that failed to decode, with
+	// opts.Debug, see describeBoxes
+	boxes []string
+	// renamed is set when opts.SanitizeNames or opts.ReplaceSpaces changed
+	// the output name
+	renamed bool
+	// sourceFormat is the format of inputs that turned out not to be AVIF
+	// with opts.Passthrough, such as "png"
+	sourceFormat string
+	// passedThrough is set when the input was copied as the output
+	passedThrough bool
+	// warning is the blankWarning of the decoded image
+	warning string
+	// checksum is the hex checksum of the output with opts.Checksum
+	checksum string
+	// decodeFailed is set when the input could not be decoded
+	decodeFailed bool
+	// duplicateOf is the first input with the same image, see errDuplicate
+	duplicateOf string
+	// decoded is the image as decoded, before any changes, with the
+	// modification time of the source
+	decoded image.Image
+	modTime time.Time
+	// status and skipReason are set by convertWithPrompt
+	status     FileStatus
+	skipReason string
+}
+
+// convertFile decodes an AVIF source and writes it to outputPath
+// The conversion is described even when an error is returned
+// Once ctx is cancelled it stops at the next step and returns ctx.Err(),
+// removing the output if it was being written
+func convertFile(ctx context.Context, src source, outputPath string, opts Options) (c conversion, err error) {
+	start := time.Now()
+	defer func() { c.duration = time.Since(start) }()
+	if opts.MetaOnly {
+		outputPath = withFormat(outputPath, metadataFormat)
+	}
+	c.outputPath = outputPath
+
+	encode, err := lookupEncoder(opts.Format)
+	if err != nil {
+		return c, err
+	}
+	encode = withContext(ctx, encode)
+	if err := ctx.Err(); err != nil {
+		return c, err
+	}
+
+	if opts.Verbose {
+		opts.logf("📂 Reading: %s\n", src.path)
+	}
+
+	if clean := cleanOutputPath(outputPath, opts); clean != outputPath {
+		outputPath, c.outputPath, c.renamed = clean, clean, true
+		if opts.Verbose {
+			opts.logf("🔤 Renamed output to %s\n", filepath.Base(clean))
+		}
+	}
+	// Outputs are sharded by their final name, once it is sanitized
+	outputPath = shardedOutputPath(outputPath, opts)
+	c.outputPath = outputPath
+	if opts.outputBase != "" && !withinDir(opts.outputBase, outputPath) {
+		return c, fmt.Errorf("%w: %s", ErrOutsideOutputDir, outputPath)
+	}
+
+	if opts.DryRun {
+		return c, planOutput(src, outputPath, opts, &c)
+	}
+
+	// The container is described before decoding, so files that fail to
+	// decode are described too
+	if opts.Debug {
+		if data, err := src.read(); err == nil {
+			c.sourceInfo = describeSource(data)
+			if opts.Verbose {
+				opts.logf("🔬 Source: %s\n", c.sourceInfo)
+			}
+		}
+	}
+	if opts.MetaOnly {
+		return convertMetadata(ctx, src, outputPath, opts, c)
+	}
+
+	img, modTime, err := src.decode(opts)
+	if err != nil {
+		// A missing thumbnail is not a fault of the file
+		c.decodeFailed = !errors.Is(err, ErrNoThumbnail)
+		if opts.Debug && c.decodeFailed {
+			if data, readErr := src.read(); readErr == nil {
+				c.boxes = describeBoxes(data)
+				if opts.Verbose {
+					opts.logf("🧱 %s\n", strings.Join(c.boxes, "\n   "))
 				}
-			} else {
-				// Actual error occurred
-				result.Failed++
-				result.Errors = append(result.Errors, FileError{
-					FilePath: filePath,
-					Error:    err,
-				})
-				if verbose {
-					fmt.Printf("❌ Failed: %v\n", err)
+			}
+		}
+		return c, explainDecodeError(src, err)
+	}
+	c.decoded, c.modTime = img, modTime
+	if err := ctx.Err(); err != nil {
+		return c, err
+	}
+	if width, height := img.Bounds().Dx(), img.Bounds().Dy(); !opts.Dimensions.Contains(width, height) {
+		c.width, c.height = width, height
+		if opts.Verbose {
+			opts.logf("📐 %dx%d is outside the dimension bounds\n", width, height)
+		}
+		return c, errOutsideDimensions
+	}
+	if opts.Passthrough && !opts.ToAVIF {
+		if data, err := src.read(); err == nil {
+			c.sourceFormat = sniffFormat(data)
+		}
+		if c.sourceFormat != "" && opts.Verbose {
+			opts.logf("🔎 Not an AVIF file: %s data\n", strings.ToUpper(c.sourceFormat))
+		}
+	}
+	if opts.FixAlpha && !opts.ToAVIF {
+		if data, err := src.read(); err == nil {
+			hasAlpha, premultiplied := alphaSignal(data)
+			if hasAlpha && !premultiplied {
+				img = unpremultiply(img)
+				if opts.Verbose {
+					opts.logf("🫧 Un-premultiplied alpha\n")
 				}
+			} else if premultiplied && opts.Verbose {
+				opts.logf("🫧 Alpha already marked as premultiplied\n")
 			}
-		} else {
-			result.Successful++
-			if verbose {
-				fmt.Println("✅")
+		}
+	}
+	if opts.NormalizeGamma && !opts.ToAVIF {
+		if data, err := src.read(); err == nil {
+			transfer, ok := sourceTransfer(data)
+			if _, known := toLinear(transfer); ok && known && transfer != transferSRGB {
+				img = normalizeGamma(img, transfer)
+				if opts.Verbose {
+					opts.logf("🌗 Converted %s gamma to sRGB\n", transferNames[transfer])
+				}
 			}
 		}
 	}
+	if c.warning = blankWarning(img); c.warning != "" && opts.Verbose {
+		opts.logf("⚠️  Looks blank: %s\n", c.warning)
+	}
+	if !opts.CropPct.IsZero() {
+		img = cropPercent(img, opts.CropPct)
+		if opts.Verbose {
+			opts.logf("✂️  Cropped to %dx%d\n", img.Bounds().Dx(), img.Bounds().Dy())
+		}
+	}
+	if opts.Rotate != 0 {
+		img = rotate(img, opts.Rotate)
+	}
+	if opts.Trim {
+		img = trim(img, opts.TrimTolerance)
+		if opts.Verbose {
+			opts.logf("✂️  Trimmed to %dx%d\n", img.Bounds().Dx(), img.Bounds().Dy())
+		}
+	}
+	if opts.Canvas != (image.Point{}) {
+		img = fitCanvas(img, opts)
+	}
+	c.width, c.height = img.Bounds().Dx(), img.Bounds().Dy()
 
-	return result, nil
+	// Images are recorded once their output exists, whether written now or
+	// before, so later copies point to it
+	var h pixelHash
+	if opts.dedupe != nil || opts.claims != nil {
+		h = hashPixels(img)
+	}
+	if opts.dedupe != nil {
+		if first, ok := opts.dedupe.lookup(h, src.path); ok {
+			c.outputPath, c.duplicateOf = first.outputPath, first.inputPath
+			if opts.Verbose {
+				opts.logf("♻️  Duplicate of %s\n", first.inputPath)
+			}
+			return c, errDuplicate
+		}
+		defer func() {
+			if err == nil || errors.Is(err, ErrFileExists) {
+				opts.dedupe.add(h, src.path, c.outputPath)
+			}
+		}()
+	}
+
+	if opts.claims != nil {
+		if claimed := opts.claims.claim(outputPath, src.path, h); claimed != outputPath {
+			outputPath, c.outputPath, c.renamed = claimed, claimed, true
+			if opts.Verbose {
+				opts.logf("🔤 Name taken, renamed output to %s\n", filepath.Base(claimed))
+			}
+		}
+	}
+
+	// Create output directory if it doesn't exist
+	if err := opts.Output.MkdirAll(filepath.Dir(outputPath)); err != nil {
+		return c, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// Check if output file already exists (overwrite protection)
+	// In Compare mode existing outputs are compared instead, never replaced
+	if existing := existingOutput(outputPath, opts); existing != "" {
+		if opts.Compare {
+			c.outputPath = existing
+			if c.psnr, err = compareOutput(existing, img, opts); err != nil {
+				return c, fmt.Errorf("failed to compare with existing output: %w", err)
+			}
+			c.compared = true
+			return c, ErrFileExists
+		}
+		if !opts.Overwrite {
+			// Copies missing from the tee directories are still made
+			if !opts.VerifyExisting {
+				c.outputPath = existing
+				if err := teeOutput(existing, modTime, opts); err != nil {
+					return c, err
+				}
+				return c, ErrFileExists
+			}
+			verifyErr := verifyExisting(existing, img.Bounds(), opts)
+			if verifyErr == nil {
+				c.outputPath = existing
+				if err := teeOutput(existing, modTime, opts); err != nil {
+					return c, err
+				}
+				return c, ErrFileExists
+			}
+			if opts.Verbose {
+				opts.logf("🩹 Replacing invalid output %s: %v\n", existing, verifyErr)
+			}
+
+			// The output may be written under another name, as with AutoFormat
+			if err := opts.Output.Remove(existing); err != nil {
+				return c, fmt.Errorf("failed to remove invalid output: %w", err)
+			}
+		}
+		c.overwritten = true
+	}
+
+	// Encode and write the image
+	// Auto-format and size budgets need the encoded data before writing it
+	var data []byte
+	outputBounds := img.Bounds()
+	if opts.AutoFormat {
+		var format string
+		if format, data, err = chooseFormat(img, opts); err != nil {
+			return c, fmt.Errorf("failed to encode: %w", err)
+		}
+		if encode, err = lookupEncoder(format); err != nil {
+			return c, err
+		}
+		opts.Format = format
+		c.outputPath = withFormat(outputPath, format)
+	}
+	// Misnamed inputs already in the output format are copied byte for byte
+	if c.sourceFormat == opts.Format && !changesImage(opts) {
+		if data, err = src.read(); err != nil {
+			return c, err
+		}
+		c.passedThrough = true
+		if opts.Verbose {
+			opts.logf("📋 Copied as is\n")
+		}
+	}
+	// GIF outputs of animated AVIF inputs keep every frame, unless
+	// PrimaryOnly; they cannot be downscaled to fit a size budget
+	if opts.Format == gifFormat && !opts.UseThumbnail && !opts.PrimaryOnly && c.sourceFormat == "" {
+		var frames int
+		if data, frames, err = encodeAnimation(src, opts); err != nil {
+			return c, err
+		}
+		if data != nil && opts.Verbose {
+			opts.logf("🎞️  Animated: %d frames\n", frames)
+		}
+		if data != nil && opts.MaxOutputSize > 0 && int64(len(data)) > opts.MaxOutputSize {
+			return c, fmt.Errorf("%w: animated GIF is %d bytes, budget is %d", ErrOverBudget, len(data), opts.MaxOutputSize)
+		}
+	}
+	if opts.MaxOutputSize > 0 && (data == nil || int64(len(data)) > opts.MaxOutputSize) {
+		fit, err := fitBudget(img, encode, opts)
+		if err != nil {
+			return c, err
+		}
+		data, outputBounds, c.budget = fit.data, fit.bounds, fit.String()
+		if opts.Verbose {
+			opts.logf("📉 Fitted to %d bytes at %s\n", opts.MaxOutputSize, c.budget)
+		}
+	}
+	if opts.StripMetadata {
+		if data == nil {
+			var buf bytes.Buffer
+			if err := encode(&buf, img, opts.encodeOptions()); err != nil {
+				return c, fmt.Errorf("failed to encode %s: %w", strings.ToUpper(opts.Format), err)
+			}
+			data = buf.Bytes()
+		}
+		if data, err = stripMetadata(opts.Format, data); err != nil {
+			return c, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return c, err
+	}
+	// The checksum is taken from the bytes written, without reading them back
+	sum := newChecksum(opts)
+	if data != nil {
+		if err := writeFile(opts.Output, c.outputPath, data); err != nil {
+			return c, fmt.Errorf("failed to write output file: %w", err)
+		}
+		if sum != nil {
+			sum.Write(data)
+		}
+	} else if err := writeImage(outputPath, img, encode, opts, sum); err != nil {
+		// A cancelled encode leaves a partial output behind
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			opts.Output.Remove(outputPath)
+			return c, ctxErr
+		}
+		return c, err
+	}
+
+	// An invalid output left in place would be skipped as existing by the
+	// next run, so it is removed for that run to convert the file again
+	if opts.Verify {
+		if err := verifyOutput(opts.Output, c.outputPath, outputBounds); err != nil {
+			if removeErr := opts.Output.Remove(c.outputPath); removeErr != nil {
+				return c, fmt.Errorf("%w (failed to remove the output: %v)", err, removeErr)
+			}
+			if opts.Verbose {
+				opts.logf("🗑️  Removed invalid output: %s\n", c.outputPath)
+			}
+			return c, err
+		}
+	}
+
+	if opts.Verbose {
+		opts.logf("✅ Saved: %s\n", c.outputPath)
+	}
+
+	written := []string{c.outputPath}
+	if opts.ExtractAux {
+		data, err := src.read()
+		if err != nil {
+			return c, err
+		}
+		auxPaths, err := extractAuxiliary(data, c.outputPath, encode, opts)
+		if err != nil {
+			return c, fmt.Errorf("failed to extract auxiliary images: %w", err)
+		}
+		written = append(written, auxPaths...)
+	}
+	if opts.AllItems {
+		data, err := src.read()
+		if err != nil {
+			return c, err
+		}
+		itemPaths, err := extractItems(data, c.outputPath, encode, opts)
+		if err != nil {
+			return c, fmt.Errorf("failed to extract image items: %w", err)
+		}
+		written = append(written, itemPaths...)
+	}
+
+	// Outputs inherit the input's modification time, used for both atime and
+	// mtime since the input's access time is not portably available
+	if opts.PreserveMtime {
+		for _, path := range written {
+			if err := chtimes(opts.Output, path, modTime); err != nil {
+				return c, fmt.Errorf("failed to preserve modification time: %w", err)
+			}
+		}
+	}
+
+	if len(opts.Exec) > 0 {
+		if err := runHook(opts.Exec, src.path, c.outputPath); err != nil {
+			return c, err
+		}
+		// The hook may have rewritten the output, as optimizers do
+		if sum != nil {
+			if err := rehashOutput(opts.Output, c.outputPath, sum); err != nil {
+				return c, fmt.Errorf("failed to checksum output: %w", err)
+			}
+		}
+	}
+
+	if err := teeOutput(c.outputPath, modTime, opts); err != nil {
+		return c, err
+	}
+
+	if sum != nil {
+		if err := finishChecksum(&c, sum, opts); err != nil {
+			return c, err
+		}
+	}
+
+	return c, nil
 }
 
-// AVIFToPNG converts an AVIF file to PNG format
-func AVIFToPNG(inputPath, outputDir string, verbose bool) error {
+// decodeFile decodes the primary image of an AVIF file
+// It also returns the file info of the input
+func decodeFile(inputPath string) (image.Image, os.FileInfo, error) {
 	// Open the input AVIF file
 	inputFile, err := os.Open(inputPath)
 	if err != nil {
-		return fmt.Errorf("failed to open input file: %w", err)
+		return nil, nil, fmt.Errorf("failed to open input file: %w", err)
 	}
 	defer inputFile.Close()
 
-	if verbose {
-		fmt.Printf("📂 Reading: %s\n", inputPath)
+	// Catch empty and truncated files before they reach the decoder
+	info, err := inputFile.Stat()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to stat input file: %w", err)
+	}
+	if info.Size() < minAVIFSize {
+		return nil, nil, ErrEmptyFile
 	}
 
-	// Decode the AVIF image
-	img, _, err := image.Decode(inputFile)
+	img, err := DecodeAVIFAt(inputFile, info.Size())
 	if err != nil {
-		return fmt.Errorf("failed to decode AVIF image: %w", err)
+		return nil, nil, err
 	}
 
-	// Create output directory if it doesn't exist
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
-	}
+	return img, info, nil
+}
 
-	// Generate output file path
-	baseName := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
-	outputPath := filepath.Join(outputDir, baseName+".png")
+// DecodeAVIFAt decodes the primary image of an AVIF file of size bytes read
+// from r, such as an open *os.File or an object in remote storage, without
+// the caller buffering it first
+// Reads never go past size, so r may hold more data after the file
+// The decoder still copies the file into its own memory while decoding;
+// like the inputs of a conversion, PNG, JPEG and GIF data misnamed as AVIF
+// is decoded too, see Options.Passthrough
+func DecodeAVIFAt(r io.ReaderAt, size int64) (image.Image, error) {
+	if size < minAVIFSize {
+		return nil, ErrEmptyFile
+	}
 
-	// Check if output file already exists (overwrite protection)
-	if _, err := os.Stat(outputPath); err == nil {
-		return ErrFileExists
+	// libavif reassembles grid (tiled) primary images, so img is always the full image
+	img, _, err := image.Decode(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode AVIF image: %w", err)
 	}
+	return img, nil
+}
 
-	// Create the output PNG file
-	outputFile, err := os.Create(outputPath)
+// writeImage encodes img to a new file at path
+// The encoded bytes are also written to sum unless it is nil
+func writeImage(path string, img image.Image, encode EncoderFunc, opts Options, sum io.Writer) error {
+	file, err := opts.Output.Create(path)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
 	}
-	defer outputFile.Close()
 
-	// Encode and write PNG
-	if err := png.Encode(outputFile, img); err != nil {
-		return fmt.Errorf("failed to encode PNG: %w", err)
+	var w io.Writer = file
+	if sum != nil {
+		w = io.MultiWriter(file, sum)
+	}
+	if err := encode(w, img, opts.encodeOptions()); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to encode %s: %w", strings.ToUpper(opts.Format), err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	return nil
+}
+
+// verifyExisting checks an existing output like verifyOutput, for an image
+// of size want
+// Outputs fitted to opts.MaxOutputSize may have been downscaled, so only
+// their decoding is checked
+func verifyExisting(path string, want image.Rectangle, opts Options) error {
+	if opts.MaxOutputSize > 0 {
+		want = image.Rectangle{}
+	}
+	return verifyOutput(opts.Output, path, want)
+}
+
+// verifyOutput decodes the file at path and checks that it has the size of
+// want, unless want is empty
+// Custom formats need a decoder registered with the image package to be verified
+func verifyOutput(out OutputFS, path string, want image.Rectangle) error {
+	file, err := out.Open(path)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrVerificationFailed, err)
+	}
+	defer file.Close()
+
+	decoded, _, err := image.Decode(file)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrVerificationFailed, err)
 	}
 
-	if verbose {
-		fmt.Printf("✅ Saved: %s\n", outputPath)
+	got := decoded.Bounds()
+	if !want.Empty() && (got.Dx() != want.Dx() || got.Dy() != want.Dy()) {
+		return fmt.Errorf("%w: expected %dx%d, got %dx%d", ErrVerificationFailed, want.Dx(), want.Dy(), got.Dx(), got.Dy())
 	}
 
 	return nil
@@ -1,12 +1,24 @@
 package converter
 
 import (
+	"avif2png/internal/isobmff"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"image"
 	"image/color"
+	"image/jpeg"
 	"image/png"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/gen2brain/avif"
 )
@@ -58,7 +70,7 @@ func TestAVIFToPNG_Success(t *testing.T) {
 
 	createTestAVIF(t, inputPath)
 
-	err := AVIFToPNG(inputPath, outputDir, false)
+	_, err := AVIFToPNG(inputPath, outputDir, false)
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
@@ -78,7 +90,7 @@ func TestAVIFToPNG_SuccessVerbose(t *testing.T) {
 
 	createTestAVIF(t, inputPath)
 
-	err := AVIFToPNG(inputPath, outputDir, true)
+	_, err := AVIFToPNG(inputPath, outputDir, true)
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
@@ -96,7 +108,7 @@ func TestAVIFToPNG_NonExistentInput(t *testing.T) {
 	inputPath := filepath.Join(testDir, "nonexistent.avif")
 	outputDir := filepath.Join(testDir, "output")
 
-	err := AVIFToPNG(inputPath, outputDir, false)
+	_, err := AVIFToPNG(inputPath, outputDir, false)
 
 	if err == nil {
 		t.Fatal("expected error for non-existent file, got nil")
@@ -114,13 +126,232 @@ func TestAVIFToPNG_InvalidAVIF(t *testing.T) {
 		t.Fatalf("failed to create invalid test file: %v", err)
 	}
 
-	err := AVIFToPNG(inputPath, outputDir, false)
+	_, err := AVIFToPNG(inputPath, outputDir, false)
 
 	if err == nil {
 		t.Fatal("expected error for invalid AVIF file, got nil")
 	}
 }
 
+func TestAVIFToPNG_EmptyFile(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "empty.avif")
+	outputDir := filepath.Join(testDir, "output")
+
+	if err := os.WriteFile(inputPath, nil, 0644); err != nil {
+		t.Fatalf("failed to create empty test file: %v", err)
+	}
+
+	_, err := AVIFToPNG(inputPath, outputDir, false)
+
+	if !errors.Is(err, ErrEmptyFile) {
+		t.Fatalf("expected ErrEmptyFile, got: %v", err)
+	}
+}
+
+// boundedReaderAt fails reads past its limit, like a ranged request beyond
+// the object of a remote store
+type boundedReaderAt struct {
+	r     io.ReaderAt
+	limit int64
+}
+
+func (b boundedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > b.limit {
+		return 0, fmt.Errorf("read of %d bytes at %d past %d", len(p), off, b.limit)
+	}
+	return b.r.ReadAt(p, off)
+}
+
+func TestDecodeAVIFAt(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	data := testAVIFData(t, testDir)
+	// Trailing data after the file is never read
+	blob := append(append([]byte(nil), data...), make([]byte, 1024)...)
+	img, err := DecodeAVIFAt(boundedReaderAt{r: bytes.NewReader(blob), limit: int64(len(data))}, int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 10, 10) {
+		t.Errorf("expected a 10x10 image, got %v", img.Bounds())
+	}
+
+	file, err := os.Open(filepath.Join(testDir, "source.avif"))
+	if err != nil {
+		t.Fatalf("failed to open test AVIF: %v", err)
+	}
+	defer file.Close()
+	if _, err := DecodeAVIFAt(file, int64(len(data))); err != nil {
+		t.Errorf("expected an *os.File to decode, got: %v", err)
+	}
+
+	if _, err := DecodeAVIFAt(bytes.NewReader(data[:8]), 8); !errors.Is(err, ErrEmptyFile) {
+		t.Errorf("expected ErrEmptyFile for a truncated file, got: %v", err)
+	}
+	if _, err := DecodeAVIFAt(strings.NewReader("not a valid avif file"), 21); err == nil {
+		t.Error("expected error for invalid data")
+	}
+}
+
+// buildGridAVIF returns an AVIF file whose primary item is a grid of two
+// 64x64 tiles side by side, red on the left and blue on the right, with an
+// output size of 120x50 that crops the right and bottom edges
+// Each tile is the coded image of a file from avif.Encode, with its
+// properties
+func buildGridAVIF(t *testing.T) []byte {
+	t.Helper()
+
+	type tile struct {
+		data       []byte
+		properties []isobmff.Box
+	}
+	var tiles []tile
+	for _, c := range []color.RGBA{{255, 0, 0, 255}, {0, 0, 255, 255}} {
+		img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+		for y := 0; y < 64; y++ {
+			for x := 0; x < 64; x++ {
+				img.Set(x, y, c)
+			}
+		}
+		var buf bytes.Buffer
+		if err := avif.Encode(&buf, img, avif.Options{Quality: 100, Speed: avif.DefaultSpeed}); err != nil {
+			t.Fatalf("failed to encode test AVIF: %v", err)
+		}
+		f, err := isobmff.Parse(buf.Bytes())
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		data, err := f.ItemData(buf.Bytes(), f.PrimaryItemID)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		tiles = append(tiles, tile{data: data, properties: f.Item(f.PrimaryItemID).Properties})
+	}
+
+	be := binary.BigEndian
+	box := func(boxType string, payload ...[]byte) []byte {
+		body := bytes.Join(payload, nil)
+		return append(append(be.AppendUint32(nil, uint32(8+len(body))), boxType...), body...)
+	}
+	infe := func(id uint16, itemType string) []byte {
+		return box("infe", []byte{2, 0, 0, 0}, be.AppendUint16(nil, id), []byte{0, 0}, []byte(itemType), []byte{0})
+	}
+
+	// Version 0 with 16-bit sizes: one row, two columns, 120x50
+	grid := []byte{0, 0, 0, 1, 0, 120, 0, 50}
+
+	// Property 1 is the size of the grid, followed by those of the tiles;
+	// the grid shares the pixel information of the first tile
+	properties := [][]byte{box("ispe", []byte{0, 0, 0, 0}, be.AppendUint32(nil, 120), be.AppendUint32(nil, 50))}
+	gridAssoc := []byte{0, 1, 1, 1}
+	var tileAssoc [][]byte
+	for i, tile := range tiles {
+		assoc := []byte{0, byte(i + 2), byte(len(tile.properties))}
+		for _, property := range tile.properties {
+			properties = append(properties, box(property.Type, property.Payload))
+			index := byte(len(properties))
+			switch property.Type {
+			case "av1C":
+				index |= 0x80
+			case "pixi":
+				if i == 0 {
+					gridAssoc = append(gridAssoc, index)
+					gridAssoc[2]++
+				}
+			}
+			assoc = append(assoc, index)
+		}
+		tileAssoc = append(tileAssoc, assoc)
+	}
+
+	ftyp := box("ftyp", []byte("avif\x00\x00\x00\x00mif1avifmiaf"))
+	build := func(mdatOffset uint32) []byte {
+		// 4-byte offsets and lengths, one extent per item, all in mdat
+		iloc := [][]byte{{0, 0, 0, 0, 0x44, 0, 0, 3}}
+		offset := mdatOffset
+		for i, data := range [][]byte{grid, tiles[0].data, tiles[1].data} {
+			iloc = append(iloc, be.AppendUint16(nil, uint16(i+1)), []byte{0, 0, 0, 1},
+				be.AppendUint32(nil, offset), be.AppendUint32(nil, uint32(len(data))))
+			offset += uint32(len(data))
+		}
+
+		meta := box("meta", []byte{0, 0, 0, 0},
+			box("hdlr", make([]byte, 8), []byte("pict"), make([]byte, 13)),
+			box("pitm", []byte{0, 0, 0, 0, 0, 1}),
+			box("iinf", []byte{0, 0, 0, 0, 0, 3}, infe(1, "grid"), infe(2, "av01"), infe(3, "av01")),
+			box("iref", []byte{0, 0, 0, 0}, box("dimg", []byte{0, 1, 0, 2, 0, 2, 0, 3})),
+			box("iloc", iloc...),
+			box("iprp",
+				box("ipco", properties...),
+				box("ipma", []byte{0, 0, 0, 0, 0, 0, 0, 3}, gridAssoc, tileAssoc[0], tileAssoc[1])),
+		)
+		return append(append(ftyp, meta...), box("mdat", grid, tiles[0].data, tiles[1].data)...)
+	}
+
+	// The item data follows the mdat header at the end of the file
+	data := build(0)
+	return build(uint32(len(data) - len(grid) - len(tiles[0].data) - len(tiles[1].data)))
+}
+
+func TestConvert_GridImage(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "grid.avif")
+	outputDir := filepath.Join(testDir, "output")
+	if err := os.WriteFile(inputPath, buildGridAVIF(t), 0644); err != nil {
+		t.Fatalf("failed to write test AVIF: %v", err)
+	}
+
+	// The tiles are reassembled and cropped to the output size of the grid
+	img, _, err := decodeFile(inputPath)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 120, 50) {
+		t.Errorf("expected the 120x50 grid, got %v", img.Bounds())
+	}
+
+	if _, err := Convert(inputPath, outputDir, Options{}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	out := decodePNGFile(t, filepath.Join(outputDir, "grid.png"))
+	if out.Bounds() != image.Rect(0, 0, 120, 50) {
+		t.Errorf("expected a 120x50 PNG, got %v", out.Bounds())
+	}
+	for _, check := range []struct {
+		x, y int
+		red  bool
+	}{{10, 10, true}, {100, 40, false}} {
+		r, _, b, _ := out.At(check.x, check.y).RGBA()
+		if (r > b) != check.red {
+			t.Errorf("expected the pixel at %d,%d to come from the %s tile, got %v", check.x, check.y, map[bool]string{true: "red", false: "blue"}[check.red], out.At(check.x, check.y))
+		}
+	}
+}
+
+func TestAVIFToPNG_TruncatedFile(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "truncated.avif")
+	outputDir := filepath.Join(testDir, "output")
+
+	if err := os.WriteFile(inputPath, []byte("\x00\x00\x00\x1cftyp"), 0644); err != nil {
+		t.Fatalf("failed to create truncated test file: %v", err)
+	}
+
+	_, err := AVIFToPNG(inputPath, outputDir, false)
+
+	if !errors.Is(err, ErrEmptyFile) {
+		t.Fatalf("expected ErrEmptyFile, got: %v", err)
+	}
+}
+
 func TestAVIFToPNG_OutputAlreadyExists(t *testing.T) {
 	testDir := setupTestDir(t)
 	defer os.RemoveAll(testDir)
@@ -138,10 +369,18 @@ func TestAVIFToPNG_OutputAlreadyExists(t *testing.T) {
 		t.Fatalf("failed to create existing output file: %v", err)
 	}
 
-	err := AVIFToPNG(inputPath, outputDir, false)
+	status, err := AVIFToPNG(inputPath, outputDir, false)
 
-	if err == nil {
-		t.Fatal("expected error for existing output file, got nil")
+	if err != nil {
+		t.Fatalf("expected no error for existing output file, got: %v", err)
+	}
+	if status != StatusSkipped {
+		t.Errorf("expected StatusSkipped, got: %s", status)
+	}
+
+	// The existing file is left untouched
+	if data, _ := os.ReadFile(outputPath); string(data) != "existing file" {
+		t.Error("expected existing output file to be preserved")
 	}
 }
 
@@ -154,7 +393,7 @@ func TestAVIFToPNG_CreatesOutputDirectory(t *testing.T) {
 
 	createTestAVIF(t, inputPath)
 
-	err := AVIFToPNG(inputPath, outputDir, false)
+	_, err := AVIFToPNG(inputPath, outputDir, false)
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
@@ -173,7 +412,7 @@ func TestAVIFToPNG_FileNameWithSpaces(t *testing.T) {
 
 	createTestAVIF(t, inputPath)
 
-	err := AVIFToPNG(inputPath, outputDir, false)
+	_, err := AVIFToPNG(inputPath, outputDir, false)
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
@@ -193,7 +432,7 @@ func TestAVIFToPNG_FileNameWithSpecialChars(t *testing.T) {
 
 	createTestAVIF(t, inputPath)
 
-	err := AVIFToPNG(inputPath, outputDir, false)
+	_, err := AVIFToPNG(inputPath, outputDir, false)
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
@@ -213,7 +452,7 @@ func TestAVIFToPNG_OutputPNGIsValid(t *testing.T) {
 
 	createTestAVIF(t, inputPath)
 
-	err := AVIFToPNG(inputPath, outputDir, false)
+	_, err := AVIFToPNG(inputPath, outputDir, false)
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
@@ -232,259 +471,1144 @@ func TestAVIFToPNG_OutputPNGIsValid(t *testing.T) {
 	}
 }
 
-// ==================== collectAVIFFiles Tests ====================
-
-func TestCollectAVIFFiles_SingleDirectory(t *testing.T) {
+func TestAVIFToPNGContext_Success(t *testing.T) {
 	testDir := setupTestDir(t)
 	defer os.RemoveAll(testDir)
 
-	// Create test files
-	createTestAVIF(t, filepath.Join(testDir, "image1.avif"))
-	createTestAVIF(t, filepath.Join(testDir, "image2.avif"))
-	if err := os.WriteFile(filepath.Join(testDir, "other.png"), []byte("test"), 0644); err != nil {
-		t.Fatalf("failed to create test file: %v", err)
-	}
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
 
-	files, err := collectAVIFFiles(testDir, false)
+	status, err := AVIFToPNGContext(context.Background(), inputPath, outputDir, Options{Format: "jpeg", Rotate: 90})
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
+	if status != StatusConverted {
+		t.Errorf("expected status %q, got %q", StatusConverted, status)
+	}
 
-	if len(files) != 2 {
-		t.Errorf("expected 2 AVIF files, got: %d", len(files))
+	// The output is a PNG whatever the format in opts, with the other
+	// options applied
+	if _, err := os.Stat(filepath.Join(outputDir, "test.png")); err != nil {
+		t.Errorf("expected test.png to exist, got: %v", err)
 	}
 }
 
-func TestCollectAVIFFiles_RecursiveDirectory(t *testing.T) {
+// ==================== Convert Tests ====================
+
+func TestConvertContext_Cancelled(t *testing.T) {
 	testDir := setupTestDir(t)
 	defer os.RemoveAll(testDir)
 
-	// Create test files in root
-	createTestAVIF(t, filepath.Join(testDir, "image1.avif"))
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
 
-	// Create subdirectory with files
-	subDir := filepath.Join(testDir, "subfolder")
-	if err := os.MkdirAll(subDir, 0755); err != nil {
-		t.Fatalf("failed to create subdirectory: %v", err)
-	}
-	createTestAVIF(t, filepath.Join(subDir, "image2.avif"))
-	createTestAVIF(t, filepath.Join(subDir, "image3.avif"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
 
-	// Non-recursive should find only 1 file
-	files, err := collectAVIFFiles(testDir, false)
-	if err != nil {
-		t.Fatalf("expected no error, got: %v", err)
-	}
-	if len(files) != 1 {
-		t.Errorf("non-recursive: expected 1 AVIF file, got: %d", len(files))
+	status, err := ConvertContext(ctx, inputPath, outputDir, Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got: %v", err)
 	}
-
-	// Recursive should find all 3 files
-	files, err = collectAVIFFiles(testDir, true)
-	if err != nil {
-		t.Fatalf("expected no error, got: %v", err)
+	if status != StatusFailed {
+		t.Errorf("expected status %q, got %q", StatusFailed, status)
 	}
-	if len(files) != 3 {
-		t.Errorf("recursive: expected 3 AVIF files, got: %d", len(files))
+	if _, err := os.Stat(filepath.Join(outputDir, "test.png")); !os.IsNotExist(err) {
+		t.Errorf("expected no output, got: %v", err)
 	}
 }
 
-func TestCollectAVIFFiles_SkipsHiddenFiles(t *testing.T) {
+func TestConvertContext_CancelledWhileEncoding(t *testing.T) {
 	testDir := setupTestDir(t)
 	defer os.RemoveAll(testDir)
 
-	// Create visible file
-	createTestAVIF(t, filepath.Join(testDir, "image.avif"))
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
 
-	// Create hidden file
-	createTestAVIF(t, filepath.Join(testDir, ".hidden.avif"))
+	// The request goes away after the encoder's first write
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	RegisterEncoder("slow-test", func(w io.Writer, img image.Image, opts EncodeOptions) error {
+		if _, err := w.Write([]byte("partial")); err != nil {
+			return err
+		}
+		cancel()
+		_, err := w.Write([]byte("rest"))
+		return err
+	})
 
-	files, err := collectAVIFFiles(testDir, false)
-	if err != nil {
-		t.Fatalf("expected no error, got: %v", err)
+	_, err := ConvertContext(ctx, inputPath, outputDir, Options{Format: "slow-test"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got: %v", err)
 	}
-
-	if len(files) != 1 {
-		t.Errorf("expected 1 AVIF file (hidden should be skipped), got: %d", len(files))
+	if _, err := os.Stat(filepath.Join(outputDir, "test.slow-test")); !os.IsNotExist(err) {
+		t.Errorf("expected the partial output to be removed, got: %v", err)
 	}
 }
 
-func TestCollectAVIFFiles_MixedFileTypes(t *testing.T) {
+func TestConvert_JPEGFormat(t *testing.T) {
 	testDir := setupTestDir(t)
 	defer os.RemoveAll(testDir)
 
-	// Create various file types
-	createTestAVIF(t, filepath.Join(testDir, "image1.avif"))
-	createTestAVIF(t, filepath.Join(testDir, "image2.AVIF")) // uppercase extension
-	if err := os.WriteFile(filepath.Join(testDir, "photo.jpg"), []byte("test"), 0644); err != nil {
-		t.Fatalf("failed to create test file: %v", err)
-	}
-	if err := os.WriteFile(filepath.Join(testDir, "doc.txt"), []byte("test"), 0644); err != nil {
-		t.Fatalf("failed to create test file: %v", err)
-	}
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
 
-	files, err := collectAVIFFiles(testDir, false)
+	createTestAVIF(t, inputPath)
+
+	_, err := Convert(inputPath, outputDir, Options{Format: "jpeg", Quality: 80})
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
 
-	if len(files) != 2 {
-		t.Errorf("expected 2 AVIF files, got: %d", len(files))
+	file, err := os.Open(filepath.Join(outputDir, "test.jpeg"))
+	if err != nil {
+		t.Fatalf("expected output JPEG file to exist: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := jpeg.Decode(file); err != nil {
+		t.Fatalf("output is not a valid JPEG image: %v", err)
 	}
 }
 
-func TestCollectAVIFFiles_EmptyDirectory(t *testing.T) {
+func TestConvert_UnknownFormat(t *testing.T) {
 	testDir := setupTestDir(t)
 	defer os.RemoveAll(testDir)
 
-	files, err := collectAVIFFiles(testDir, false)
-	if err != nil {
-		t.Fatalf("expected no error, got: %v", err)
-	}
-
-	if len(files) != 0 {
-		t.Errorf("expected 0 AVIF files in empty directory, got: %d", len(files))
-	}
-}
+	inputPath := filepath.Join(testDir, "test.avif")
+	createTestAVIF(t, inputPath)
 
-func TestCollectAVIFFiles_NonExistentDirectory(t *testing.T) {
-	_, err := collectAVIFFiles("/nonexistent/directory", false)
+	_, err := Convert(inputPath, filepath.Join(testDir, "output"), Options{Format: "nonexistent"})
 
-	if err == nil {
-		t.Fatal("expected error for non-existent directory, got nil")
+	if !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("expected ErrUnknownFormat, got: %v", err)
 	}
 }
 
-// ==================== ConvertDirectory Tests ====================
-
-func TestConvertDirectory_Success(t *testing.T) {
+func TestConvert_OutputIsFile(t *testing.T) {
 	testDir := setupTestDir(t)
 	defer os.RemoveAll(testDir)
 
-	inputDir := filepath.Join(testDir, "input")
-	outputDir := filepath.Join(testDir, "output")
-
-	if err := os.MkdirAll(inputDir, 0755); err != nil {
-		t.Fatalf("failed to create input dir: %v", err)
-	}
-
-	// Create test files
-	createTestAVIF(t, filepath.Join(inputDir, "image1.avif"))
-	createTestAVIF(t, filepath.Join(inputDir, "image2.avif"))
-
-	result, err := ConvertDirectory(inputDir, outputDir, false, false)
-	if err != nil {
-		t.Fatalf("expected no error, got: %v", err)
+	inputPath := filepath.Join(testDir, "test.avif")
+	createTestAVIF(t, inputPath)
+	outputPath := filepath.Join(testDir, "output.png")
+	if err := os.WriteFile(outputPath, []byte("existing"), 0644); err != nil {
+		t.Fatalf("failed to create output file: %v", err)
 	}
 
-	if result.TotalFiles != 2 {
-		t.Errorf("expected 2 total files, got: %d", result.TotalFiles)
-	}
-	if result.Successful != 2 {
-		t.Errorf("expected 2 successful conversions, got: %d", result.Successful)
-	}
-	if result.Skipped != 0 {
-		t.Errorf("expected 0 skipped files, got: %d", result.Skipped)
-	}
-	if result.Failed != 0 {
-		t.Errorf("expected 0 failed conversions, got: %d", result.Failed)
+	status, err := Convert(inputPath, outputPath, Options{})
+	if !errors.Is(err, ErrOutputNotDir) || status != StatusFailed {
+		t.Fatalf("expected ErrOutputNotDir, got: %s, %v", status, err)
 	}
 
-	// Verify output files exist
-	if _, err := os.Stat(filepath.Join(outputDir, "image1.png")); os.IsNotExist(err) {
-		t.Error("expected image1.png to exist")
-	}
-	if _, err := os.Stat(filepath.Join(outputDir, "image2.png")); os.IsNotExist(err) {
-		t.Error("expected image2.png to exist")
+	result, err := ConvertDirectory(testDir, outputPath, Options{})
+	if !errors.Is(err, ErrOutputNotDir) || result == nil {
+		t.Fatalf("expected ErrOutputNotDir with a result, got: %v", err)
 	}
 }
 
-func TestConvertDirectory_SkipsExistingFiles(t *testing.T) {
+func TestConvert_ReturnsStatus(t *testing.T) {
 	testDir := setupTestDir(t)
 	defer os.RemoveAll(testDir)
 
-	inputDir := filepath.Join(testDir, "input")
+	inputPath := filepath.Join(testDir, "test.avif")
 	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
 
-	if err := os.MkdirAll(inputDir, 0755); err != nil {
-		t.Fatalf("failed to create input dir: %v", err)
-	}
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		t.Fatalf("failed to create output dir: %v", err)
-	}
-
-	// Create test files
-	createTestAVIF(t, filepath.Join(inputDir, "image1.avif"))
-	createTestAVIF(t, filepath.Join(inputDir, "image2.avif"))
-
-	// Create existing output file
-	if err := os.WriteFile(filepath.Join(outputDir, "image1.png"), []byte("existing"), 0644); err != nil {
-		t.Fatalf("failed to create existing file: %v", err)
-	}
-
-	result, err := ConvertDirectory(inputDir, outputDir, false, false)
-	if err != nil {
-		t.Fatalf("expected no error, got: %v", err)
+	steps := []struct {
+		opts Options
+		want FileStatus
+	}{
+		{Options{}, StatusConverted},
+		{Options{}, StatusSkipped},
+		{Options{Overwrite: true}, StatusOverwritten},
+		{Options{Format: "nonexistent"}, StatusFailed},
 	}
 
-	if result.Successful != 1 {
-		t.Errorf("expected 1 successful conversion, got: %d", result.Successful)
-	}
-	if result.Skipped != 1 {
-		t.Errorf("expected 1 skipped file, got: %d", result.Skipped)
-	}
-	if result.Failed != 0 {
-		t.Errorf("expected 0 failed conversions, got: %d", result.Failed)
+	for _, step := range steps {
+		status, err := Convert(inputPath, outputDir, step.opts)
+		if status != step.want {
+			t.Errorf("expected %s, got: %s", step.want, status)
+		}
+		if (err != nil) != (step.want == StatusFailed) {
+			t.Errorf("expected an error only for failures, got: %v (status %s)", err, status)
+		}
 	}
 }
 
-func TestConvertDirectory_EmptyDirectory(t *testing.T) {
+func TestConvert_PreserveMtime(t *testing.T) {
 	testDir := setupTestDir(t)
 	defer os.RemoveAll(testDir)
 
-	inputDir := filepath.Join(testDir, "input")
+	inputPath := filepath.Join(testDir, "test.avif")
 	outputDir := filepath.Join(testDir, "output")
+	createTestAVIFWithAlpha(t, inputPath)
 
-	if err := os.MkdirAll(inputDir, 0755); err != nil {
-		t.Fatalf("failed to create input dir: %v", err)
+	mtime := time.Date(2020, 5, 17, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(inputPath, mtime, mtime); err != nil {
+		t.Fatalf("failed to set input mtime: %v", err)
 	}
 
-	result, err := ConvertDirectory(inputDir, outputDir, false, false)
-	if err != nil {
+	if _, err := Convert(inputPath, outputDir, Options{PreserveMtime: true, ExtractAux: true}); err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
 
-	if result.TotalFiles != 0 {
-		t.Errorf("expected 0 total files, got: %d", result.TotalFiles)
+	for _, name := range []string{"test.png", "test_alpha.png"} {
+		info, err := os.Stat(filepath.Join(outputDir, name))
+		if err != nil {
+			t.Fatalf("expected %s to exist: %v", name, err)
+		}
+		if !info.ModTime().Equal(mtime) {
+			t.Errorf("expected %s mtime %v, got: %v", name, mtime, info.ModTime())
+		}
 	}
 }
 
-func TestConvertDirectory_RecursiveMode(t *testing.T) {
+func TestConvert_WithoutPreserveMtime(t *testing.T) {
 	testDir := setupTestDir(t)
 	defer os.RemoveAll(testDir)
 
-	inputDir := filepath.Join(testDir, "input")
+	inputPath := filepath.Join(testDir, "test.avif")
 	outputDir := filepath.Join(testDir, "output")
-	subDir := filepath.Join(inputDir, "subfolder")
+	createTestAVIF(t, inputPath)
 
-	if err := os.MkdirAll(subDir, 0755); err != nil {
-		t.Fatalf("failed to create subdirectory: %v", err)
+	mtime := time.Date(2020, 5, 17, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(inputPath, mtime, mtime); err != nil {
+		t.Fatalf("failed to set input mtime: %v", err)
 	}
 
-	// Create test files
-	createTestAVIF(t, filepath.Join(inputDir, "image1.avif"))
-	createTestAVIF(t, filepath.Join(subDir, "image2.avif"))
-
-	result, err := ConvertDirectory(inputDir, outputDir, true, false)
-	if err != nil {
+	if _, err := Convert(inputPath, outputDir, Options{}); err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
 
-	if result.TotalFiles != 2 {
-		t.Errorf("expected 2 total files (recursive), got: %d", result.TotalFiles)
+	info, err := os.Stat(filepath.Join(outputDir, "test.png"))
+	if err != nil {
+		t.Fatalf("expected output to exist: %v", err)
 	}
-	if result.Successful != 2 {
-		t.Errorf("expected 2 successful conversions, got: %d", result.Successful)
+	if info.ModTime().Equal(mtime) {
+		t.Error("expected output to keep its own modification time")
+	}
+}
+
+func TestConvert_VerifySuccess(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	createTestAVIF(t, inputPath)
+
+	for _, format := range []string{"png", "jpeg"} {
+		if _, err := Convert(inputPath, filepath.Join(testDir, format), Options{Format: format, Verify: true}); err != nil {
+			t.Errorf("expected %s output to verify, got: %v", format, err)
+		}
+	}
+}
+
+func TestConvert_VerifyCorruptOutput(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	RegisterEncoder("corrupt-test", func(w io.Writer, img image.Image, opts EncodeOptions) error {
+		_, err := w.Write([]byte("\x89PNG garbage"))
+		return err
+	})
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	createTestAVIF(t, inputPath)
+
+	_, err := Convert(inputPath, filepath.Join(testDir, "output"), Options{Format: "corrupt-test", Verify: true})
+	if !errors.Is(err, ErrVerificationFailed) {
+		t.Fatalf("expected ErrVerificationFailed, got: %v", err)
+	}
+
+	// Without verification the corrupt output goes unnoticed
+	_, err = Convert(inputPath, filepath.Join(testDir, "unverified"), Options{Format: "corrupt-test"})
+	if err != nil {
+		t.Fatalf("expected no error without verification, got: %v", err)
+	}
+}
+
+func TestConvertFiles(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	first := filepath.Join(testDir, "a", "image.avif")
+	second := filepath.Join(testDir, "b", "image.avif")
+	other := filepath.Join(testDir, "b", "other.avif")
+	for _, path := range []string{first, second, other} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create input dir: %v", err)
+		}
+		createTestAVIF(t, path)
+	}
+
+	outputDir := filepath.Join(testDir, "output")
+	result, err := ConvertFiles(context.Background(), []string{first, second, other}, outputDir, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.TotalFiles != 3 || result.Successful != 2 || result.Skipped() != 1 {
+		t.Errorf("expected 2 converted and 1 skipped of 3, got %+v", result)
+	}
+	// Outputs are named after their input, so the second image.avif is skipped
+	if result.Files[1].Status != StatusSkipped {
+		t.Errorf("expected an input sharing a name to be skipped, got %q", result.Files[1].Status)
+	}
+	for _, name := range []string{"image.png", "other.png"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+			t.Errorf("expected %s to be written, got: %v", name, err)
+		}
+	}
+}
+
+func TestConvertMapped(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	first := filepath.Join(testDir, "a", "image.avif")
+	second := filepath.Join(testDir, "b", "image.avif")
+	for _, path := range []string{first, second} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create input dir: %v", err)
+		}
+		createTestAVIF(t, path)
+	}
+
+	files := []MappedFile{
+		{InputPath: first, OutputPath: filepath.Join(testDir, "out", "a", "first.png")},
+		{InputPath: second, OutputPath: filepath.Join(testDir, "out", "second.png")},
+	}
+	result, err := ConvertMapped(context.Background(), files, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.TotalFiles != 2 || result.Successful != 2 {
+		t.Errorf("expected 2 of 2 converted, got %+v", result)
+	}
+	for _, file := range files {
+		if _, err := os.Stat(file.OutputPath); err != nil {
+			t.Errorf("expected %s to be written, got: %v", file.OutputPath, err)
+		}
+	}
+
+	if conflicts := FindMappedConflicts(append(files, MappedFile{InputPath: "c.avif", OutputPath: files[1].OutputPath}), Options{}); len(conflicts) != 1 {
+		t.Errorf("expected 1 conflict, got: %v", conflicts)
+	}
+}
+
+func TestConvert_VerifyExisting(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	outputPath := filepath.Join(outputDir, "test.png")
+	createTestAVIF(t, inputPath)
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		t.Fatalf("failed to create output dir: %v", err)
+	}
+	if err := os.WriteFile(outputPath, []byte("\x89PNG truncated"), 0644); err != nil {
+		t.Fatalf("failed to write output: %v", err)
+	}
+
+	// Without verification the truncated output is skipped
+	status, err := Convert(inputPath, outputDir, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if status != StatusSkipped {
+		t.Errorf("expected status %q, got %q", StatusSkipped, status)
+	}
+
+	status, err = Convert(inputPath, outputDir, Options{VerifyExisting: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if status != StatusOverwritten {
+		t.Errorf("expected status %q, got %q", StatusOverwritten, status)
+	}
+	if err := verifyOutput(OSFS{}, outputPath, image.Rect(0, 0, 10, 10)); err != nil {
+		t.Fatalf("expected the output to be converted again, got: %v", err)
+	}
+
+	// A valid output is still skipped
+	status, err = Convert(inputPath, outputDir, Options{VerifyExisting: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if status != StatusSkipped {
+		t.Errorf("expected a valid output to be skipped, got %q", status)
+	}
+}
+
+func TestConvertDirectory_VerifyWrongDimensions(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	RegisterEncoder("tiny-test", func(w io.Writer, img image.Image, opts EncodeOptions) error {
+		return png.Encode(w, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	})
+
+	inputDir := filepath.Join(testDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "image.avif"))
+
+	result, err := ConvertDirectory(inputDir, filepath.Join(testDir, "output"), Options{Format: "tiny-test", Verify: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.Failed != 1 || result.Successful != 0 {
+		t.Fatalf("expected the file to fail verification, got: %d failed", result.Failed)
+	}
+	if !errors.Is(result.Errors[0].Error, ErrVerificationFailed) {
+		t.Errorf("expected ErrVerificationFailed, got: %v", result.Errors[0].Error)
+	}
+	if want := filepath.Join(testDir, "output", "image.tiny-test"); result.Errors[0].OutputPath != want {
+		t.Errorf("expected the attempted output path %s, got: %s", want, result.Errors[0].OutputPath)
+	}
+	if _, err := os.Stat(result.Errors[0].OutputPath); !os.IsNotExist(err) {
+		t.Errorf("expected the invalid output to be removed, got: %v", err)
+	}
+
+	// The next run converts the file again instead of skipping it as existing
+	result, err = ConvertDirectory(inputDir, filepath.Join(testDir, "output"), Options{Format: "tiny-test", Verify: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Failed != 1 || result.Skipped() != 0 {
+		t.Errorf("expected the file to be retried and fail again, got: %d failed, %d skipped", result.Failed, result.Skipped())
+	}
+}
+
+func TestConvertDirectory_FileErrorOutputPathOnDecodeFailure(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "broken.avif"), []byte("this is not an avif image"), 0644); err != nil {
+		t.Fatalf("failed to create broken file: %v", err)
+	}
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(result.Errors) != 1 {
+		t.Fatalf("expected 1 error, got: %d", len(result.Errors))
+	}
+	fileErr := result.Errors[0]
+	if fileErr.FilePath != filepath.Join(inputDir, "broken.avif") {
+		t.Errorf("expected input path to be recorded, got: %s", fileErr.FilePath)
+	}
+	if want := filepath.Join(outputDir, "broken.png"); fileErr.OutputPath != want {
+		t.Errorf("expected output path %s, got: %s", want, fileErr.OutputPath)
+	}
+}
+
+// ==================== collectAVIFFiles Tests ====================
+
+func TestCollectAVIFFiles_SingleDirectory(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	// Create test files
+	createTestAVIF(t, filepath.Join(testDir, "image1.avif"))
+	createTestAVIF(t, filepath.Join(testDir, "image2.avif"))
+	if err := os.WriteFile(filepath.Join(testDir, "other.png"), []byte("test"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	files, err := collectAVIFFiles(testDir, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(files) != 2 {
+		t.Errorf("expected 2 AVIF files, got: %d", len(files))
+	}
+}
+
+func TestCollectAVIFFiles_RecursiveDirectory(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	// Create test files in root
+	createTestAVIF(t, filepath.Join(testDir, "image1.avif"))
+
+	// Create subdirectory with files
+	subDir := filepath.Join(testDir, "subfolder")
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatalf("failed to create subdirectory: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(subDir, "image2.avif"))
+	createTestAVIF(t, filepath.Join(subDir, "image3.avif"))
+
+	// Non-recursive should find only 1 file
+	files, err := collectAVIFFiles(testDir, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("non-recursive: expected 1 AVIF file, got: %d", len(files))
+	}
+
+	// Recursive should find all 3 files
+	files, err = collectAVIFFiles(testDir, Options{Recursive: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(files) != 3 {
+		t.Errorf("recursive: expected 3 AVIF files, got: %d", len(files))
+	}
+}
+
+func TestCollectAVIFFiles_SkipsHiddenFiles(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	// Create visible file
+	createTestAVIF(t, filepath.Join(testDir, "image.avif"))
+
+	// Create hidden file
+	createTestAVIF(t, filepath.Join(testDir, ".hidden.avif"))
+
+	files, err := collectAVIFFiles(testDir, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(files) != 1 {
+		t.Errorf("expected 1 AVIF file (hidden should be skipped), got: %d", len(files))
+	}
+}
+
+func TestCollectAVIFFiles_IncludeHidden(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	createTestAVIF(t, filepath.Join(testDir, "image.avif"))
+	createTestAVIF(t, filepath.Join(testDir, ".hidden.avif"))
+
+	cacheDir := filepath.Join(testDir, ".cache")
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		t.Fatalf("failed to create hidden directory: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(cacheDir, ".asset.avif"))
+
+	files, err := collectAVIFFiles(testDir, Options{IncludeHidden: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("non-recursive: expected 2 AVIF files (hidden included), got: %d", len(files))
+	}
+
+	files, err = collectAVIFFiles(testDir, Options{Recursive: true, IncludeHidden: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(files) != 3 {
+		t.Errorf("recursive: expected 3 AVIF files (hidden included), got: %d", len(files))
+	}
+}
+
+func TestCollectAVIFFiles_SinceFilter(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	subDir := filepath.Join(testDir, "subfolder")
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatalf("failed to create subdirectory: %v", err)
+	}
+
+	oldPath := filepath.Join(testDir, "old.avif")
+	oldNestedPath := filepath.Join(subDir, "old-nested.avif")
+	createTestAVIF(t, oldPath)
+	createTestAVIF(t, oldNestedPath)
+	createTestAVIF(t, filepath.Join(testDir, "new.avif"))
+	createTestAVIF(t, filepath.Join(subDir, "new-nested.avif"))
+
+	lastWeek := time.Now().Add(-7 * 24 * time.Hour)
+	for _, path := range []string{oldPath, oldNestedPath} {
+		if err := os.Chtimes(path, lastWeek, lastWeek); err != nil {
+			t.Fatalf("failed to set file times: %v", err)
+		}
+	}
+
+	since := time.Now().Add(-24 * time.Hour)
+
+	files, err := collectAVIFFiles(testDir, Options{Since: since})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("non-recursive: expected 1 recent AVIF file, got: %d", len(files))
+	}
+
+	files, err = collectAVIFFiles(testDir, Options{Recursive: true, Since: since})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("recursive: expected 2 recent AVIF files, got: %d", len(files))
+	}
+}
+
+func TestCollectAVIFFiles_MixedFileTypes(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	// Create various file types
+	createTestAVIF(t, filepath.Join(testDir, "image1.avif"))
+	createTestAVIF(t, filepath.Join(testDir, "image2.AVIF")) // uppercase extension
+	if err := os.WriteFile(filepath.Join(testDir, "photo.jpg"), []byte("test"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "doc.txt"), []byte("test"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	files, err := collectAVIFFiles(testDir, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(files) != 2 {
+		t.Errorf("expected 2 AVIF files, got: %d", len(files))
+	}
+}
+
+func TestCollectAVIFFiles_Extensions(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	createTestAVIF(t, filepath.Join(testDir, "image1.avif"))
+	createTestAVIF(t, filepath.Join(testDir, "clip.AVIFS"))
+	createTestAVIF(t, filepath.Join(testDir, "image2.heic"))
+
+	files, err := collectAVIFFiles(testDir, Options{Extensions: []string{".avif", ".avifs"}})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(files) != 2 {
+		t.Errorf("expected the .avif and .avifs files, got: %v", files)
+	}
+}
+
+func TestCollectAVIFFiles_EmptyDirectory(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	files, err := collectAVIFFiles(testDir, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(files) != 0 {
+		t.Errorf("expected 0 AVIF files in empty directory, got: %d", len(files))
+	}
+}
+
+func TestCollectAVIFFiles_NonExistentDirectory(t *testing.T) {
+	_, err := collectAVIFFiles("/nonexistent/directory", Options{})
+
+	if err == nil {
+		t.Fatal("expected error for non-existent directory, got nil")
+	}
+}
+
+// ==================== collectAVIFFilesFS Tests ====================
+
+func TestCollectAVIFFilesFS_SyntheticTree(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.avif":             {Data: []byte("a")},
+		"B.AVIF":             {Data: []byte("b")},
+		".hidden.avif":       {Data: []byte("h")},
+		"notes.txt":          {Data: []byte("n")},
+		"sub/c.avif":         {Data: []byte("c")},
+		"sub/deeper/d.avif":  {Data: []byte("d")},
+		"sub/deeper/e.png":   {Data: []byte("e")},
+		"folder.avif/f.avif": {Data: []byte("f")},
+	}
+
+	files, err := collectAVIFFilesFS(fsys, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{"B.AVIF", "a.avif"}) {
+		t.Errorf("non-recursive: unexpected files: %v", files)
+	}
+
+	files, err = collectAVIFFilesFS(fsys, Options{Recursive: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := []string{"B.AVIF", "a.avif", "folder.avif/f.avif", "sub/c.avif", "sub/deeper/d.avif"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("recursive: expected %v, got: %v", want, files)
+	}
+}
+
+func TestCollectAVIFFilesFS_MaxDepth(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.avif":                 {Data: []byte("a")},
+		"sub/b.avif":             {Data: []byte("b")},
+		"sub/deeper/c.avif":      {Data: []byte("c")},
+		"sub/deeper/more/d.avif": {Data: []byte("d")},
+	}
+
+	tests := []struct {
+		maxDepth int
+		want     []string
+	}{
+		{1, []string{"a.avif"}},
+		{2, []string{"a.avif", "sub/b.avif"}},
+		{3, []string{"a.avif", "sub/b.avif", "sub/deeper/c.avif"}},
+		{0, []string{"a.avif", "sub/b.avif", "sub/deeper/c.avif", "sub/deeper/more/d.avif"}},
+	}
+
+	for _, tt := range tests {
+		files, err := collectAVIFFilesFS(fsys, Options{Recursive: true, MaxDepth: tt.maxDepth})
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if !reflect.DeepEqual(files, tt.want) {
+			t.Errorf("max depth %d: expected %v, got: %v", tt.maxDepth, tt.want, files)
+		}
+	}
+}
+
+func TestCollectAVIFFilesFS_EmptyFilesystem(t *testing.T) {
+	fsys := fstest.MapFS{}
+
+	files, err := collectAVIFFilesFS(fsys, Options{Recursive: true})
+	if err != nil {
+		t.Fatalf("expected no error for empty filesystem, got: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("expected no files, got: %v", files)
+	}
+}
+
+func TestWalkAVIFFilesFS_StopsOnError(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.avif":     {Data: []byte("a")},
+		"b.avif":     {Data: []byte("b")},
+		"sub/c.avif": {Data: []byte("c")},
+	}
+	stop := errors.New("stop")
+
+	for _, recursive := range []bool{false, true} {
+		var visited []string
+		err := walkAVIFFilesFS(fsys, Options{Recursive: recursive}, func(path string) error {
+			visited = append(visited, path)
+			return stop
+		}, nil)
+		if !errors.Is(err, stop) {
+			t.Errorf("recursive %v: expected the callback error, got: %v", recursive, err)
+		}
+		if !reflect.DeepEqual(visited, []string{"a.avif"}) {
+			t.Errorf("recursive %v: expected the walk to stop after a.avif, got: %v", recursive, visited)
+		}
+	}
+}
+
+func TestWalkAVIFFilesFS_ReportsFiltered(t *testing.T) {
+	now, old := time.Now(), time.Now().Add(-48*time.Hour)
+	fsys := fstest.MapFS{
+		"a.avif":       {Data: []byte("a"), ModTime: now},
+		".hidden.avif": {Data: []byte("h"), ModTime: now},
+		"old.avif":     {Data: []byte("o"), ModTime: old},
+		"notes.txt":    {Data: []byte("n")},
+		".notes.txt":   {Data: []byte("n")},
+	}
+	opts := Options{Since: time.Now().Add(-time.Hour)}
+
+	var visited []string
+	filtered := map[string]int{}
+	err := walkAVIFFilesFS(fsys, opts, func(path string) error {
+		visited = append(visited, path)
+		return nil
+	}, func(reason string) {
+		filtered[reason]++
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if !reflect.DeepEqual(visited, []string{"a.avif"}) {
+		t.Errorf("expected only a.avif to be selected, got: %v", visited)
+	}
+	want := map[string]int{FilterReasonHidden: 1, FilterReasonSince: 1}
+	if !reflect.DeepEqual(filtered, want) {
+		t.Errorf("expected filtered %v, got: %v", want, filtered)
+	}
+}
+
+// ==================== ConvertDirectory Tests ====================
+
+func TestConversionResult_ExtensionCounts(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	os.MkdirAll(inputDir, 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "b.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "c.AVIF"))
+	os.WriteFile(filepath.Join(inputDir, "bad.avif"), []byte("not a valid avif file"), 0644)
+
+	result, err := ConvertDirectory(inputDir, filepath.Join(testDir, "output"), Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	want := []ExtensionCount{
+		{Extension: ".avif", Seen: 3, Converted: 2},
+		{Extension: ".AVIF", Seen: 1, Converted: 1},
+	}
+	if got := result.ExtensionCounts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if got := (&ConversionResult{}).ExtensionCounts(); len(got) != 0 {
+		t.Errorf("expected no counts without files, got %+v", got)
+	}
+}
+
+func TestConvertDirectory_Success(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+
+	// Create test files
+	createTestAVIF(t, filepath.Join(inputDir, "image1.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "image2.avif"))
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.TotalFiles != 2 {
+		t.Errorf("expected 2 total files, got: %d", result.TotalFiles)
+	}
+	if result.Successful != 2 {
+		t.Errorf("expected 2 successful conversions, got: %d", result.Successful)
+	}
+	if result.Skipped() != 0 {
+		t.Errorf("expected 0 skipped files, got: %d", result.Skipped())
+	}
+	if result.Failed != 0 {
+		t.Errorf("expected 0 failed conversions, got: %d", result.Failed)
+	}
+
+	// Verify output files exist
+	if _, err := os.Stat(filepath.Join(outputDir, "image1.png")); os.IsNotExist(err) {
+		t.Error("expected image1.png to exist")
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "image2.png")); os.IsNotExist(err) {
+		t.Error("expected image2.png to exist")
+	}
+}
+
+func TestConvertDirectory_SkipsExistingFiles(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		t.Fatalf("failed to create output dir: %v", err)
+	}
+
+	// Create test files
+	createTestAVIF(t, filepath.Join(inputDir, "image1.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "image2.avif"))
+
+	// Create existing output file
+	if err := os.WriteFile(filepath.Join(outputDir, "image1.png"), []byte("existing"), 0644); err != nil {
+		t.Fatalf("failed to create existing file: %v", err)
+	}
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.Successful != 1 {
+		t.Errorf("expected 1 successful conversion, got: %d", result.Successful)
+	}
+	if result.Skipped() != 1 {
+		t.Errorf("expected 1 skipped file, got: %d", result.Skipped())
+	}
+	if result.SkippedReasons[SkipReasonExists] != 1 {
+		t.Errorf("expected 1 file skipped because it exists, got: %v", result.SkippedReasons)
+	}
+	if result.Files[0].SkipReason != SkipReasonExists {
+		t.Errorf("expected skip reason on the file result, got: %q", result.Files[0].SkipReason)
+	}
+	if result.Failed != 0 {
+		t.Errorf("expected 0 failed conversions, got: %d", result.Failed)
+	}
+}
+
+// setupExistingOutputs creates AVIF inputs and placeholder outputs for each name
+func setupExistingOutputs(t *testing.T, inputDir, outputDir string, names ...string) {
+	t.Helper()
+
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		t.Fatalf("failed to create output dir: %v", err)
+	}
+
+	for _, name := range names {
+		createTestAVIF(t, filepath.Join(inputDir, name+".avif"))
+		if err := os.WriteFile(filepath.Join(outputDir, name+".png"), []byte("existing"), 0644); err != nil {
+			t.Fatalf("failed to create existing file: %v", err)
+		}
+	}
+}
+
+func TestConvertDirectory_PromptOverwrite(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	setupExistingOutputs(t, inputDir, outputDir, "image1", "image2")
+
+	answers := map[string]OverwriteDecision{
+		filepath.Join(outputDir, "image1.png"): OverwriteYes,
+		filepath.Join(outputDir, "image2.png"): OverwriteNo,
+	}
+	prompt := func(outputPath string) OverwriteDecision {
+		return answers[outputPath]
+	}
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{Prompt: prompt})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.Successful != 1 || result.Skipped() != 1 {
+		t.Errorf("expected 1 successful and 1 skipped, got: %d and %d", result.Successful, result.Skipped())
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "image1.png"))
+	if err != nil || string(data) == "existing" {
+		t.Error("expected image1.png to be overwritten")
+	}
+	data, err = os.ReadFile(filepath.Join(outputDir, "image2.png"))
+	if err != nil || string(data) != "existing" {
+		t.Error("expected image2.png to be left untouched")
+	}
+}
+
+func TestConvertDirectory_PromptAll(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	setupExistingOutputs(t, inputDir, outputDir, "image1", "image2", "image3")
+
+	prompts := 0
+	prompt := func(string) OverwriteDecision {
+		prompts++
+		return OverwriteAll
+	}
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{Prompt: prompt})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if prompts != 1 {
+		t.Errorf("expected a single prompt, got: %d", prompts)
+	}
+	if result.Successful != 3 {
+		t.Errorf("expected 3 successful conversions, got: %d", result.Successful)
+	}
+}
+
+func TestConvertDirectory_PromptQuit(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	setupExistingOutputs(t, inputDir, outputDir, "image1", "image2")
+
+	prompt := func(string) OverwriteDecision {
+		return OverwriteQuit
+	}
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{Prompt: prompt})
+	if !errors.Is(err, ErrAborted) {
+		t.Fatalf("expected ErrAborted, got: %v", err)
+	}
+
+	if len(result.Files) != 1 || result.Skipped() != 1 {
+		t.Errorf("expected to stop after the first file, got %d file(s)", len(result.Files))
+	}
+}
+
+func TestConvert_PromptNotAskedForNewFiles(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	createTestAVIF(t, inputPath)
+
+	prompt := func(string) OverwriteDecision {
+		t.Error("expected no prompt for a new output file")
+		return OverwriteNo
+	}
+
+	if _, err := Convert(inputPath, filepath.Join(testDir, "output"), Options{Prompt: prompt}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+}
+
+func TestConvert_PromptDeclined(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	setupExistingOutputs(t, inputDir, outputDir, "image")
+
+	prompt := func(string) OverwriteDecision {
+		return OverwriteNo
+	}
+
+	status, err := Convert(filepath.Join(inputDir, "image.avif"), outputDir, Options{Prompt: prompt})
+	if err != nil || status != StatusSkipped {
+		t.Errorf("expected StatusSkipped without error, got: %s, %v", status, err)
+	}
+}
+
+func TestConvert_PromptDecodesOnce(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	setupExistingOutputs(t, inputDir, outputDir, "image")
+
+	// The input is gone once the user answers, so decoding it again would fail
+	inputPath := filepath.Join(inputDir, "image.avif")
+	prompt := func(string) OverwriteDecision {
+		os.Remove(inputPath)
+		return OverwriteYes
+	}
+
+	status, err := Convert(inputPath, outputDir, Options{Prompt: prompt})
+	if err != nil || status != StatusOverwritten {
+		t.Fatalf("expected StatusOverwritten without error, got: %s, %v", status, err)
+	}
+	if img := decodePNGFile(t, filepath.Join(outputDir, "image.png")); img.Bounds() != image.Rect(0, 0, 10, 10) {
+		t.Errorf("expected a 10x10 image, got %v", img.Bounds())
+	}
+}
+
+func TestConvertDirectory_EmptyDirectory(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.TotalFiles != 0 {
+		t.Errorf("expected 0 total files, got: %d", result.TotalFiles)
+	}
+}
+
+func TestConvertDirectory_OnlyFilteredFiles(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, ".hidden.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, ".other.avif"))
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.TotalFiles != 0 {
+		t.Errorf("expected 0 total files, got: %d", result.TotalFiles)
+	}
+	if result.Filtered() != 2 || result.FilteredReasons[FilterReasonHidden] != 2 {
+		t.Errorf("expected 2 hidden files to be filtered, got: %v", result.FilteredReasons)
+	}
+}
+
+func TestConvertDirectory_RecursiveMode(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	subDir := filepath.Join(inputDir, "subfolder")
+
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatalf("failed to create subdirectory: %v", err)
+	}
+
+	// Create test files
+	createTestAVIF(t, filepath.Join(inputDir, "image1.avif"))
+	createTestAVIF(t, filepath.Join(subDir, "image2.avif"))
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{Recursive: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.TotalFiles != 2 {
+		t.Errorf("expected 2 total files (recursive), got: %d", result.TotalFiles)
+	}
+	if result.Successful != 2 {
+		t.Errorf("expected 2 successful conversions, got: %d", result.Successful)
 	}
 }
 
@@ -494,32 +1618,207 @@ func TestConvertDirectory_FlattenStructure(t *testing.T) {
 
 	inputDir := filepath.Join(testDir, "input")
 	outputDir := filepath.Join(testDir, "output")
-	subDir := filepath.Join(inputDir, "subfolder")
+	subDir := filepath.Join(inputDir, "subfolder")
+
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatalf("failed to create subdirectory: %v", err)
+	}
+
+	// Create test file in subdirectory
+	createTestAVIF(t, filepath.Join(subDir, "nested.avif"))
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{Recursive: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.Successful != 1 {
+		t.Errorf("expected 1 successful conversion, got: %d", result.Successful)
+	}
+
+	// Verify output is flattened (directly in output dir, not in subfolder)
+	if _, err := os.Stat(filepath.Join(outputDir, "nested.png")); os.IsNotExist(err) {
+		t.Error("expected nested.png to exist in flat output directory")
+	}
+
+	// Verify it's NOT in a subdirectory
+	if _, err := os.Stat(filepath.Join(outputDir, "subfolder", "nested.png")); !os.IsNotExist(err) {
+		t.Error("expected output to be flattened, not preserve directory structure")
+	}
+}
+
+func TestConvertDirectory_FlattenSeparator(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	subDir := filepath.Join(inputDir, "sub", "dir")
 
 	if err := os.MkdirAll(subDir, 0755); err != nil {
 		t.Fatalf("failed to create subdirectory: %v", err)
 	}
 
-	// Create test file in subdirectory
-	createTestAVIF(t, filepath.Join(subDir, "nested.avif"))
+	// Same base name at two levels would collide without a separator
+	createTestAVIF(t, filepath.Join(inputDir, "img.avif"))
+	createTestAVIF(t, filepath.Join(subDir, "img.avif"))
 
-	result, err := ConvertDirectory(inputDir, outputDir, true, false)
+	result, err := ConvertDirectory(inputDir, outputDir, Options{Recursive: true, FlattenSeparator: "_"})
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
 
-	if result.Successful != 1 {
-		t.Errorf("expected 1 successful conversion, got: %d", result.Successful)
+	if result.Successful != 2 {
+		t.Errorf("expected 2 successful conversions, got: %d", result.Successful)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "img.png")); os.IsNotExist(err) {
+		t.Error("expected img.png to exist for the top-level file")
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "sub_dir_img.png")); os.IsNotExist(err) {
+		t.Error("expected sub_dir_img.png to exist for the nested file")
 	}
+}
 
-	// Verify output is flattened (directly in output dir, not in subfolder)
-	if _, err := os.Stat(filepath.Join(outputDir, "nested.png")); os.IsNotExist(err) {
-		t.Error("expected nested.png to exist in flat output directory")
+func TestValidateFlattenSeparator(t *testing.T) {
+	for _, separator := range []string{"_", "-", "__", ".", " - "} {
+		if err := ValidateFlattenSeparator(separator); err != nil {
+			t.Errorf("expected %q to be valid, got: %v", separator, err)
+		}
+	}
+	for _, separator := range []string{"", "  ", "/", "/../../", "\\", "..", "a\x00b"} {
+		if err := ValidateFlattenSeparator(separator); err == nil {
+			t.Errorf("expected %q to be invalid", separator)
+		}
 	}
+}
 
-	// Verify it's NOT in a subdirectory
-	if _, err := os.Stat(filepath.Join(outputDir, "subfolder", "nested.png")); !os.IsNotExist(err) {
-		t.Error("expected output to be flattened, not preserve directory structure")
+func TestConvertFile_OutsideOutputDir(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "img.avif")
+	createTestAVIF(t, inputPath)
+
+	// Whatever options built the path, nothing is written outside the
+	// output directory of the conversion
+	opts := Options{}.withDefaults()
+	opts.outputBase = filepath.Join(testDir, "output")
+	outputPath := filepath.Join(testDir, "output", "..", "escaped.png")
+	if _, err := convertFile(context.Background(), source{path: inputPath}, outputPath, opts); !errors.Is(err, ErrOutsideOutputDir) {
+		t.Fatalf("expected ErrOutsideOutputDir, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(testDir, "escaped.png")); !os.IsNotExist(err) {
+		t.Error("expected nothing to be written outside the output directory")
+	}
+}
+
+func TestConvertDirectory_FlattenSeparatorOutsideOutput(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	os.MkdirAll(filepath.Join(inputDir, "sub"), 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "sub", "img.avif"))
+
+	if _, err := ConvertDirectory(inputDir, outputDir, Options{Recursive: true, FlattenSeparator: "/../../"}); err == nil {
+		t.Fatal("expected error for a separator leaving the output directory, got nil")
+	}
+	if _, err := os.Stat(filepath.Join(testDir, "img.png")); !os.IsNotExist(err) {
+		t.Error("expected nothing to be written outside the output directory")
+	}
+}
+
+func TestConvertDirectory_Number(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	if err := os.MkdirAll(filepath.Join(inputDir, "sub"), 0755); err != nil {
+		t.Fatalf("failed to create subdirectory: %v", err)
+	}
+	for _, name := range []string{"c.avif", "a.avif", filepath.Join("sub", "b.avif")} {
+		createTestAVIF(t, filepath.Join(inputDir, name))
+	}
+
+	opts := Options{Recursive: true, Number: true, NumberPadding: DefaultNumberPadding, ScanWorkers: 4}
+	result, err := ConvertDirectory(inputDir, outputDir, opts)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	want := map[string]string{
+		"a.avif":                       "0001.png",
+		"c.avif":                       "0002.png",
+		filepath.Join("sub", "b.avif"): "0003.png",
+	}
+	if len(result.Files) != len(want) {
+		t.Fatalf("expected %d files, got: %d", len(want), len(result.Files))
+	}
+	for _, file := range result.Files {
+		relPath, _ := filepath.Rel(inputDir, file.InputPath)
+		if got := filepath.Base(file.OutputPath); got != want[relPath] {
+			t.Errorf("expected %s to be written to %s, got: %s", relPath, want[relPath], got)
+		}
+		if _, err := os.Stat(file.OutputPath); err != nil {
+			t.Errorf("expected %s to exist, got: %v", file.OutputPath, err)
+		}
+	}
+
+	if got := numberedOutputPath("out", 12, Options{Format: "jpeg"}); got != filepath.Join("out", "12.jpeg") {
+		t.Errorf("expected no padding by default, got: %s", got)
+	}
+}
+
+func TestConvertDirectory_Limit(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	os.MkdirAll(inputDir, 0755)
+	for _, name := range []string{"d.avif", "b.avif", "a.avif", "c.avif"} {
+		createTestAVIF(t, filepath.Join(inputDir, name))
+	}
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{Limit: 2})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.TotalFiles != 2 || result.Successful != 2 {
+		t.Errorf("expected 2 files converted, got %d of %d", result.Successful, result.TotalFiles)
+	}
+	if got := result.FilteredReasons[FilterReasonLimit]; got != 2 {
+		t.Errorf("expected 2 files over the limit, got: %d", got)
+	}
+	for _, name := range []string{"a.png", "b.png"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+			t.Errorf("expected the first files in sorted order, got: %v", err)
+		}
+	}
+}
+
+func TestConvertMapped_Limit(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	var files []MappedFile
+	for _, name := range []string{"a", "b", "c"} {
+		inputPath := filepath.Join(testDir, name+".avif")
+		createTestAVIF(t, inputPath)
+		files = append(files, MappedFile{InputPath: inputPath, OutputPath: filepath.Join(testDir, "out", name+".png")})
+	}
+
+	result, err := ConvertMapped(context.Background(), files, Options{Limit: 1})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.TotalFiles != 1 || result.Files[0].InputPath != files[0].InputPath {
+		t.Errorf("expected only the first file, got: %+v", result.Files)
+	}
+	if got := result.FilteredReasons[FilterReasonLimit]; got != 2 {
+		t.Errorf("expected 2 files over the limit, got: %d", got)
 	}
 }
 
@@ -542,7 +1841,7 @@ func TestConvertDirectory_PartialFailure(t *testing.T) {
 		t.Fatalf("failed to create invalid file: %v", err)
 	}
 
-	result, err := ConvertDirectory(inputDir, outputDir, false, false)
+	result, err := ConvertDirectory(inputDir, outputDir, Options{})
 	if err != nil {
 		t.Fatalf("expected no error from ConvertDirectory, got: %v", err)
 	}
@@ -557,3 +1856,320 @@ func TestConvertDirectory_PartialFailure(t *testing.T) {
 		t.Errorf("expected 1 error in result, got: %d", len(result.Errors))
 	}
 }
+
+func TestConvertDirectory_RecordsFileResults(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+
+	createTestAVIF(t, filepath.Join(inputDir, "valid.avif"))
+	if err := os.WriteFile(filepath.Join(inputDir, "invalid.avif"), []byte("not a valid avif"), 0644); err != nil {
+		t.Fatalf("failed to create invalid file: %v", err)
+	}
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(result.Files) != 2 {
+		t.Fatalf("expected 2 file results, got: %d", len(result.Files))
+	}
+
+	for _, file := range result.Files {
+		switch filepath.Base(file.InputPath) {
+		case "valid.avif":
+			if file.Status != StatusConverted {
+				t.Errorf("expected valid.avif to be converted, got: %s", file.Status)
+			}
+			if file.OutputPath != filepath.Join(outputDir, "valid.png") {
+				t.Errorf("unexpected output path: %s", file.OutputPath)
+			}
+			if file.InputSize == 0 || file.OutputSize == 0 {
+				t.Error("expected input and output sizes to be recorded")
+			}
+			if file.Width != 10 || file.Height != 10 {
+				t.Errorf("expected 10x10 dimensions, got: %dx%d", file.Width, file.Height)
+			}
+			if file.Duration <= 0 {
+				t.Errorf("expected a positive duration, got: %v", file.Duration)
+			}
+		case "invalid.avif":
+			if file.Status != StatusFailed {
+				t.Errorf("expected invalid.avif to fail, got: %s", file.Status)
+			}
+			if file.Error == nil {
+				t.Error("expected error to be recorded for failed file")
+			}
+			if file.Width != 0 || file.Height != 0 {
+				t.Errorf("expected no dimensions for a failed decode, got: %dx%d", file.Width, file.Height)
+			}
+		}
+	}
+}
+
+func TestConvertDirectory_RecordsOverwrittenStatus(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "existing.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "new.avif"))
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		t.Fatalf("failed to create output dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "existing.png"), []byte("old"), 0644); err != nil {
+		t.Fatalf("failed to create existing output: %v", err)
+	}
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{Overwrite: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Successful != 2 {
+		t.Fatalf("expected 2 successful conversions, got: %d", result.Successful)
+	}
+
+	statuses := map[string]FileStatus{}
+	for _, file := range result.Files {
+		statuses[filepath.Base(file.InputPath)] = file.Status
+		if !file.Status.Succeeded() {
+			t.Errorf("expected %s to count as succeeded", file.InputPath)
+		}
+	}
+	if statuses["existing.avif"] != StatusOverwritten || statuses["new.avif"] != StatusConverted {
+		t.Errorf("expected existing.avif overwritten and new.avif converted, got: %v", statuses)
+	}
+}
+
+func TestConvertDirectory_UnknownFormat(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	result, err := ConvertDirectory(testDir, filepath.Join(testDir, "output"), Options{Format: "nonexistent"})
+
+	if !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("expected ErrUnknownFormat, got: %v", err)
+	}
+	if result == nil {
+		t.Fatal("expected a non-nil result alongside the error")
+	}
+}
+
+func TestConvertDirectory_ScanErrorReturnsResult(t *testing.T) {
+	result, err := ConvertDirectory("/nonexistent/directory", "./output", Options{})
+
+	if err == nil {
+		t.Fatal("expected error for non-existent directory, got nil")
+	}
+	if result == nil {
+		t.Fatal("expected a non-nil result alongside the error")
+	}
+	if result.TotalFiles != 0 {
+		t.Errorf("expected 0 total files, got: %d", result.TotalFiles)
+	}
+}
+
+func TestConvertDirectory_CountsEmptyFiles(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+
+	createTestAVIF(t, filepath.Join(inputDir, "valid.avif"))
+	if err := os.WriteFile(filepath.Join(inputDir, "empty.avif"), nil, 0644); err != nil {
+		t.Fatalf("failed to create empty file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "corrupt.avif"), []byte("not a valid avif file"), 0644); err != nil {
+		t.Fatalf("failed to create corrupt file: %v", err)
+	}
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.Failed != 2 {
+		t.Errorf("expected 2 failed conversions, got: %d", result.Failed)
+	}
+	if result.Empty != 1 {
+		t.Errorf("expected 1 empty file, got: %d", result.Empty)
+	}
+}
+
+// ==================== Progress Tests ====================
+
+func TestConversionResult_SkippedSumsReasons(t *testing.T) {
+	result := &ConversionResult{}
+	if result.Skipped() != 0 {
+		t.Errorf("expected 0 skipped without reasons, got: %d", result.Skipped())
+	}
+
+	result.addSkip(SkipReasonExists)
+	result.addSkip(SkipReasonExists)
+	result.addSkip("filtered")
+	if result.Skipped() != 3 {
+		t.Errorf("expected 3 skipped, got: %d", result.Skipped())
+	}
+}
+
+func TestConversionResult_ProgressBeforeScan(t *testing.T) {
+	result := &ConversionResult{}
+	if got := result.Progress(); got != 0 {
+		t.Errorf("expected progress 0, got: %v", got)
+	}
+}
+
+func TestConvertDirectoryInto_ProgressWhileConverting(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	for _, name := range []string{"a.avif", "b.avif", "c.avif", "d.avif"} {
+		createTestAVIF(t, filepath.Join(inputDir, name))
+	}
+
+	// The encoder runs while a file is in flight, so it sees the files before it as processed
+	result := &ConversionResult{}
+	var seen []float64
+	RegisterEncoder("progress-test", func(w io.Writer, img image.Image, opts EncodeOptions) error {
+		seen = append(seen, result.Progress())
+		return nil
+	})
+
+	if err := ConvertDirectoryInto(context.Background(), inputDir, outputDir, Options{Format: "progress-test"}, result); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// The scan may still be running, so the total seen by each file varies
+	if len(seen) != 4 || seen[0] != 0 {
+		t.Fatalf("expected progress to start at 0 for 4 files, got: %v", seen)
+	}
+	for _, p := range seen[1:] {
+		if p <= 0 || p >= 1 {
+			t.Errorf("expected progress strictly between 0 and 1 during conversion, got: %v", seen)
+			break
+		}
+	}
+	if got := result.Progress(); got != 1 {
+		t.Errorf("expected progress 1 after conversion, got: %v", got)
+	}
+	if processed, total := result.Counts(); processed != 4 || total != 4 {
+		t.Errorf("expected 4/4 files processed, got %d/%d", processed, total)
+	}
+	if result.Successful != 4 || len(result.Files) != 4 {
+		t.Errorf("expected 4 recorded conversions, got: %d", result.Successful)
+	}
+}
+
+func TestConvertDirectoryInto_ProgressPolledConcurrently(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "b.avif"))
+
+	result := &ConversionResult{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for result.Progress() < 1 {
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	if err := ConvertDirectoryInto(context.Background(), inputDir, filepath.Join(testDir, "output"), Options{}, result); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected poller to observe completed progress")
+	}
+}
+
+// ==================== Cancellation Tests ====================
+
+func TestConvertDirectoryContext_CancelledMidRun(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	for _, name := range []string{"a.avif", "b.avif", "c.avif"} {
+		createTestAVIF(t, filepath.Join(inputDir, name))
+	}
+
+	// Cancel while the first file is being encoded; it still completes
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	RegisterEncoder("cancel-test", func(w io.Writer, img image.Image, opts EncodeOptions) error {
+		cancel()
+		return nil
+	})
+
+	result, err := ConvertDirectoryContext(ctx, inputDir, filepath.Join(testDir, "output"), Options{Format: "cancel-test"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+
+	if result.TotalFiles != 3 {
+		t.Errorf("expected 3 total files, got: %d", result.TotalFiles)
+	}
+	if result.Successful != 1 || len(result.Files) != 1 {
+		t.Errorf("expected only the first file to be converted, got: %d successful, %d files", result.Successful, len(result.Files))
+	}
+}
+
+func TestConvertDirectoryContext_AlreadyCancelled(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := ConvertDirectoryContext(ctx, inputDir, outputDir, Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+	if result == nil || result.TotalFiles != 1 || result.Successful != 0 {
+		t.Errorf("expected a partial result with no conversions, got: %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "a.png")); !os.IsNotExist(err) {
+		t.Error("expected no output after cancellation")
+	}
+}
@@ -0,0 +1,67 @@
+package converter
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// CropPercent is a region of an image given in percentages of its width and
+// height from its top-left corner, so "10,10,90,90" keeps the middle 80% of
+// any image whatever its size
+// The zero value keeps the whole image
+type CropPercent struct {
+	Left, Top, Right, Bottom float64
+}
+
+// IsZero reports whether c is the zero value, which does not crop
+func (c CropPercent) IsZero() bool {
+	return c == CropPercent{}
+}
+
+// IsValid reports whether c is a non-empty region within 0-100%
+func (c CropPercent) IsValid() bool {
+	return c.Left >= 0 && c.Top >= 0 && c.Right <= 100 && c.Bottom <= 100 &&
+		c.Left < c.Right && c.Top < c.Bottom
+}
+
+// String formats c like the --crop-pct flag, e.g. "10,10,90,90"
+func (c CropPercent) String() string {
+	return fmt.Sprintf("%g,%g,%g,%g", c.Left, c.Top, c.Right, c.Bottom)
+}
+
+// Rect returns the pixels of bounds covered by c, rounded to the nearest
+// pixel and never less than one pixel wide and high
+func (c CropPercent) Rect(bounds image.Rectangle) image.Rectangle {
+	edge := func(min, size int, pct float64) int {
+		return min + int(math.Round(float64(size)*pct/100))
+	}
+	w, h := bounds.Dx(), bounds.Dy()
+	r := image.Rect(
+		edge(bounds.Min.X, w, c.Left), edge(bounds.Min.Y, h, c.Top),
+		edge(bounds.Min.X, w, c.Right), edge(bounds.Min.Y, h, c.Bottom),
+	)
+
+	// Thin regions of small images round to nothing
+	if r.Dx() == 0 {
+		r.Max.X = r.Min.X + 1
+		if r.Max.X > bounds.Max.X {
+			r.Min.X, r.Max.X = bounds.Max.X-1, bounds.Max.X
+		}
+	}
+	if r.Dy() == 0 {
+		r.Max.Y = r.Min.Y + 1
+		if r.Max.Y > bounds.Max.Y {
+			r.Min.Y, r.Max.Y = bounds.Max.Y-1, bounds.Max.Y
+		}
+	}
+	return r
+}
+
+// cropPercent returns the region c of img, or img as is for the zero value
+func cropPercent(img image.Image, c CropPercent) image.Image {
+	if c.IsZero() || img.Bounds().Empty() {
+		return img
+	}
+	return subImage(img, c.Rect(img.Bounds()))
+}
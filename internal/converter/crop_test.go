@@ -0,0 +1,86 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ==================== CropPercent Tests ====================
+
+func TestCropPercent_IsValid(t *testing.T) {
+	tests := map[CropPercent]bool{
+		{10, 10, 90, 90}:   true,
+		{0, 0, 100, 100}:   true,
+		{0, 0, 50, 100}:    true,
+		{50, 10, 50, 90}:   false,
+		{60, 10, 40, 90}:   false,
+		{-1, 0, 100, 100}:  false,
+		{0, 0, 100, 100.5}: false,
+	}
+
+	for crop, want := range tests {
+		if got := crop.IsValid(); got != want {
+			t.Errorf("%v: expected %v, got %v", crop, want, got)
+		}
+	}
+}
+
+func TestCropPercent_Rect(t *testing.T) {
+	tests := []struct {
+		crop   CropPercent
+		bounds image.Rectangle
+		want   image.Rectangle
+	}{
+		{CropPercent{10, 10, 90, 90}, image.Rect(0, 0, 200, 100), image.Rect(20, 10, 180, 90)},
+		{CropPercent{0, 0, 50, 100}, image.Rect(0, 0, 11, 4), image.Rect(0, 0, 6, 4)},
+		{CropPercent{10, 10, 90, 90}, image.Rect(5, 5, 105, 55), image.Rect(15, 10, 95, 50)},
+		// Thin regions keep at least one pixel, inside the image
+		{CropPercent{40, 0, 41, 100}, image.Rect(0, 0, 10, 10), image.Rect(4, 0, 5, 10)},
+		{CropPercent{99, 0, 100, 100}, image.Rect(0, 0, 10, 10), image.Rect(9, 0, 10, 10)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.crop.Rect(tt.bounds); got != tt.want {
+			t.Errorf("%v of %v: expected %v, got %v", tt.crop, tt.bounds, tt.want, got)
+		}
+	}
+}
+
+func TestCropPercent_Zero(t *testing.T) {
+	img := solidImage(10, 10, color.NRGBA{255, 0, 0, 255})
+	if got := cropPercent(img, CropPercent{}); got != image.Image(img) {
+		t.Error("expected the zero value to keep the image as is")
+	}
+}
+
+func TestConvert_CropPct(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	// The crop is taken from the decoded image, then rotated
+	opts := Options{CropPct: CropPercent{Left: 0, Top: 0, Right: 50, Bottom: 100}, Rotate: 90}
+	if _, err := Convert(inputPath, outputDir, opts); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	file, err := os.Open(filepath.Join(outputDir, "test.png"))
+	if err != nil {
+		t.Fatalf("expected output to exist, got: %v", err)
+	}
+	defer file.Close()
+	cfg, err := png.DecodeConfig(file)
+	if err != nil {
+		t.Fatalf("expected a valid PNG, got: %v", err)
+	}
+	if cfg.Width != 10 || cfg.Height != 5 {
+		t.Errorf("expected a 10x5 output, got %dx%d", cfg.Width, cfg.Height)
+	}
+}
@@ -0,0 +1,100 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"image"
+	"sync"
+)
+
+// SkipReasonDuplicate is the skip reason with Options.Dedupe for files whose
+// decoded image matches one converted earlier in the same run
+const SkipReasonDuplicate = "duplicate"
+
+// errDuplicate is returned by convertFile for an image already converted
+// in the same run
+var errDuplicate = errors.New("duplicate image")
+
+// pixelHash identifies the decoded image of a file
+type pixelHash [sha256.Size]byte
+
+// occurrence is the first file of a run with a given pixelHash
+type occurrence struct {
+	inputPath  string
+	outputPath string
+}
+
+// dedupeIndex maps the images converted in a run to their first occurrence
+type dedupeIndex struct {
+	mu    sync.Mutex
+	first map[pixelHash]occurrence
+}
+
+func newDedupeIndex() *dedupeIndex {
+	return &dedupeIndex{first: map[pixelHash]occurrence{}}
+}
+
+// lookup returns the first occurrence of h, unless it is inputPath itself,
+// as when a file is converted again after an overwrite prompt
+func (d *dedupeIndex) lookup(h pixelHash, inputPath string) (occurrence, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	first, ok := d.first[h]
+	return first, ok && first.inputPath != inputPath
+}
+
+// add records the first occurrence of h
+func (d *dedupeIndex) add(h pixelHash, inputPath, outputPath string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.first[h]; !ok {
+		d.first[h] = occurrence{inputPath: inputPath, outputPath: outputPath}
+	}
+}
+
+// hashPixels hashes the size and pixels of img
+// Images are compared after decoding, so identical pictures saved with
+// different encoder settings or metadata only match if they decode alike;
+// images of different in-memory types never match
+func hashPixels(img image.Image) pixelHash {
+	h := sha256.New()
+	b := img.Bounds()
+	binary.Write(h, binary.LittleEndian, [2]int64{int64(b.Dx()), int64(b.Dy())})
+
+	switch img := img.(type) {
+	case *image.RGBA:
+		hashRows(h, img.Pix, img.PixOffset(b.Min.X, b.Min.Y), img.Stride, b.Dx()*4, b.Dy())
+	case *image.NRGBA:
+		hashRows(h, img.Pix, img.PixOffset(b.Min.X, b.Min.Y), img.Stride, b.Dx()*4, b.Dy())
+	case *image.RGBA64:
+		hashRows(h, img.Pix, img.PixOffset(b.Min.X, b.Min.Y), img.Stride, b.Dx()*8, b.Dy())
+	case *image.NRGBA64:
+		hashRows(h, img.Pix, img.PixOffset(b.Min.X, b.Min.Y), img.Stride, b.Dx()*8, b.Dy())
+	default:
+		var px [8]byte
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				r, g, bl, a := img.At(x, y).RGBA()
+				binary.LittleEndian.PutUint16(px[0:], uint16(r))
+				binary.LittleEndian.PutUint16(px[2:], uint16(g))
+				binary.LittleEndian.PutUint16(px[4:], uint16(bl))
+				binary.LittleEndian.PutUint16(px[6:], uint16(a))
+				h.Write(px[:])
+			}
+		}
+	}
+
+	var sum pixelHash
+	h.Sum(sum[:0])
+	return sum
+}
+
+// hashRows writes rows of width bytes, stride bytes apart, from pix to h
+func hashRows(h hash.Hash, pix []byte, offset, stride, width, rows int) {
+	for y := 0; y < rows; y++ {
+		h.Write(pix[offset : offset+width])
+		offset += stride
+	}
+}
@@ -0,0 +1,114 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ==================== Dedupe Tests ====================
+
+func TestHashPixels(t *testing.T) {
+	red := solidImage(20, 10, color.NRGBA{255, 0, 0, 255})
+	if hashPixels(red) != hashPixels(solidImage(20, 10, color.NRGBA{255, 0, 0, 255})) {
+		t.Error("expected identical images to hash alike")
+	}
+	if hashPixels(red) == hashPixels(solidImage(20, 10, color.NRGBA{0, 0, 255, 255})) {
+		t.Error("expected different pixels to hash differently")
+	}
+	if hashPixels(red) == hashPixels(solidImage(10, 20, color.NRGBA{255, 0, 0, 255})) {
+		t.Error("expected different sizes to hash differently")
+	}
+
+	// Sub-images hash only their own pixels
+	sub := red.SubImage(image.Rect(5, 0, 15, 10))
+	if hashPixels(sub) != hashPixels(solidImage(10, 10, color.NRGBA{255, 0, 0, 255})) {
+		t.Error("expected a sub-image to hash like a copy of its pixels")
+	}
+}
+
+func TestConvertDirectory_Dedupe(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	os.MkdirAll(inputDir, 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "b.avif"))
+	createTestAVIFWithAlpha(t, filepath.Join(inputDir, "c.avif"))
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{Dedupe: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Successful != 2 {
+		t.Errorf("expected 2 successful conversions, got %d", result.Successful)
+	}
+	if result.SkippedReasons[SkipReasonDuplicate] != 1 {
+		t.Errorf("expected 1 duplicate, got %v", result.SkippedReasons)
+	}
+
+	// Files are converted in scan order, so b.avif repeats a.avif
+	if _, err := os.Stat(filepath.Join(outputDir, "b.png")); !os.IsNotExist(err) {
+		t.Errorf("expected no output for the duplicate, got: %v", err)
+	}
+	for _, file := range result.Files {
+		if file.SkipReason != SkipReasonDuplicate {
+			continue
+		}
+		if file.DuplicateOf != filepath.Join(inputDir, "a.avif") {
+			t.Errorf("expected duplicate of a.avif, got %q", file.DuplicateOf)
+		}
+		if file.OutputPath != filepath.Join(outputDir, "a.png") {
+			t.Errorf("expected output of a.avif, got %q", file.OutputPath)
+		}
+		if file.OutputSize == 0 {
+			t.Error("expected the size of the shared output")
+		}
+	}
+}
+
+func TestConvertDirectory_DedupeExistingOutput(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	os.MkdirAll(inputDir, 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "b.avif"))
+
+	// A re-run still points duplicates to the output written before
+	if _, err := ConvertDirectory(inputDir, outputDir, Options{Dedupe: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	result, err := ConvertDirectory(inputDir, outputDir, Options{Dedupe: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.SkippedReasons[SkipReasonExists] != 1 || result.SkippedReasons[SkipReasonDuplicate] != 1 {
+		t.Errorf("expected 1 existing and 1 duplicate, got %v", result.SkippedReasons)
+	}
+}
+
+func TestConvertDirectory_WithoutDedupe(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	os.MkdirAll(inputDir, 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "b.avif"))
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Successful != 2 {
+		t.Errorf("expected 2 successful conversions, got %d", result.Successful)
+	}
+}
@@ -0,0 +1,110 @@
+package converter
+
+import (
+	"avif2png/internal/isobmff"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// av1Profiles names the AV1 profiles by seq_profile
+var av1Profiles = []string{"Main", "High", "Professional"}
+
+// describeSource summarizes the container and codec of an AVIF file for
+// opts.Debug, e.g. "brand avif (avif, mif1, miaf), AV1 Main profile,
+// YUV 4:2:0, 8-bit, 640x480, sRGB transfer, with alpha"
+// It describes what it can and never fails, since it is only informational
+func describeSource(data []byte) string {
+	f, err := isobmff.Parse(data)
+	if err != nil {
+		return fmt.Sprintf("unreadable container (%v)", err)
+	}
+
+	parts := []string{fmt.Sprintf("brand %s (%s)", f.MajorBrand, strings.Join(f.CompatibleBrands, ", "))}
+
+	primary := f.Item(f.PrimaryItemID)
+	if primary == nil {
+		return strings.Join(append(parts, "no primary image"), ", ")
+	}
+
+	// Grids are made of AV1 tiles, which carry the codec configuration
+	coded := primary
+	if primary.Type == "grid" {
+		parts = append(parts, "grid")
+		for _, ref := range f.References {
+			if ref.Type == "dimg" && ref.FromID == primary.ID && len(ref.ToIDs) > 0 {
+				if tile := f.Item(ref.ToIDs[0]); tile != nil {
+					coded = tile
+				}
+				parts[len(parts)-1] = fmt.Sprintf("grid of %d tiles", len(ref.ToIDs))
+				break
+			}
+		}
+	}
+
+	if config, ok := coded.AV1Config(); ok {
+		profile := fmt.Sprintf("profile %d", config.Profile)
+		if config.Profile < len(av1Profiles) {
+			profile = av1Profiles[config.Profile] + " profile"
+		}
+		parts = append(parts, "AV1 "+profile, config.PixelFormat(), fmt.Sprintf("%d-bit", config.BitDepth))
+	} else {
+		parts = append(parts, fmt.Sprintf("codec %q", coded.Type))
+	}
+
+	if w, h, ok := primary.ImageSize(); ok {
+		parts = append(parts, fmt.Sprintf("%dx%d", w, h))
+	}
+
+	if transfer, ok := sourceTransfer(data); ok {
+		name := transferNames[transfer]
+		if name == "" {
+			name = strconv.Itoa(transfer)
+		}
+		parts = append(parts, name+" transfer")
+	}
+
+	for _, id := range f.ReferencesTo("auxl", primary.ID) {
+		if item := f.Item(id); item != nil && auxiliaryNames[item.AuxiliaryType()] == "alpha" {
+			if f.IsPremultiplied(primary.ID) {
+				parts = append(parts, "with premultiplied alpha")
+			} else {
+				parts = append(parts, "with alpha")
+			}
+			break
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// describeBoxes lists the box structure of an input that failed to decode,
+// for opts.Debug: a line per box, indented by its nesting level, such as
+// "meta at 32, 245 bytes", then what stopped the walk, if anything
+// Only box headers are read, so damaged files are listed up to the damage
+func describeBoxes(data []byte) []string {
+	boxes, err := isobmff.Walk(data)
+
+	lines := []string{fmt.Sprintf("Box structure of %d bytes:", len(data))}
+	for _, box := range boxes {
+		lines = append(lines, fmt.Sprintf("%s%s at %d, %d bytes", strings.Repeat("  ", box.Depth+1), boxTypeName(box.Type), box.Offset, box.Size()))
+	}
+	if len(boxes) == 0 && err == nil {
+		lines = append(lines, "  no boxes")
+	}
+	if err != nil {
+		lines = append(lines, "  stopped: "+err.Error())
+	}
+	return lines
+}
+
+// boxTypeName returns a box type as is, or quoted when it is not printable
+// ASCII, as in files that are not ISOBMFF at all
+func boxTypeName(boxType string) string {
+	for _, r := range boxType {
+		if r < ' ' || r > '~' {
+			return strconv.Quote(boxType)
+		}
+	}
+	return boxType
+}
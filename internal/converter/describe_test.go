@@ -0,0 +1,114 @@
+package converter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ==================== describeSource Tests ====================
+
+func TestDescribeSource(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	got := describeSource(testAVIFData(t, testDir))
+	for _, want := range []string{"brand avif", "AV1", "YUV 4:", "8-bit", "10x10", "unspecified transfer"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in the description, got: %s", want, got)
+		}
+	}
+	if strings.Contains(got, "with alpha") {
+		t.Errorf("expected an opaque image not to mention alpha, got: %s", got)
+	}
+}
+
+func TestDescribeSource_PremultipliedAlpha(t *testing.T) {
+	data := encodePremultipliedAVIF(t)
+	if got := describeSource(data); !strings.HasSuffix(got, ", with alpha") {
+		t.Errorf("expected straight alpha, got: %s", got)
+	}
+	if got := describeSource(withPremReference(t, data)); !strings.HasSuffix(got, ", with premultiplied alpha") {
+		t.Errorf("expected premultiplied alpha, got: %s", got)
+	}
+}
+
+func TestDescribeSource_Unreadable(t *testing.T) {
+	if got := describeSource([]byte("not an AVIF file")); !strings.HasPrefix(got, "unreadable container") {
+		t.Errorf("expected an unreadable container, got: %s", got)
+	}
+}
+
+func TestConvertFile_DebugDescribesSource(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	createTestAVIF(t, inputPath)
+
+	opts := Options{Debug: true}.withDefaults()
+	c, err := convertFile(context.Background(), source{path: inputPath}, filepath.Join(testDir, "output", "test.png"), opts)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !strings.HasPrefix(c.sourceInfo, "brand avif") {
+		t.Errorf("expected the source to be described, got: %q", c.sourceInfo)
+	}
+
+	c, _ = convertFile(context.Background(), source{path: inputPath}, filepath.Join(testDir, "plain", "test.png"), Options{}.withDefaults())
+	if c.sourceInfo != "" {
+		t.Errorf("expected no description without Debug, got: %q", c.sourceInfo)
+	}
+}
+
+// ==================== describeBoxes Tests ====================
+
+func TestDescribeBoxes(t *testing.T) {
+	meta := append([]byte{0, 0, 0, 0}, containerBox("hdlr", []byte("pict"))...)
+	data := append(containerBox("ftyp", []byte("avif")), containerBox("meta", meta)...)
+	data = append(data, containerBox("mdat", make([]byte, 16))...)
+
+	got := describeBoxes(data[:len(data)-4])
+	want := []string{"Box structure of 56 bytes:", "  ftyp at 0, 12 bytes", "  meta at 12, 24 bytes", "    hdlr at 24, 12 bytes"}
+	if len(got) != len(want)+1 {
+		t.Fatalf("expected %d lines, got: %q", len(want)+1, got)
+	}
+	for i, line := range want {
+		if got[i] != line {
+			t.Errorf("line %d: expected %q, got %q", i, line, got[i])
+		}
+	}
+	if !strings.HasPrefix(got[len(want)], "  stopped: ") || !strings.Contains(got[len(want)], "mdat") {
+		t.Errorf("expected the walk to stop at the mdat box, got: %q", got[len(want)])
+	}
+}
+
+func TestDescribeBoxes_NotISOBMFF(t *testing.T) {
+	got := describeBoxes([]byte("\x00\x00\x00\x10\x89PNG\r\n\x1a\nrest"))
+	if len(got) != 2 || !strings.Contains(got[1], `"\x89PNG"`) {
+		t.Errorf("expected the unprintable box type to be quoted, got: %q", got)
+	}
+}
+
+func TestConvertFile_DebugDescribesBoxes(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "broken.avif")
+	os.WriteFile(inputPath, containerBox("ftyp", []byte("avif")), 0644)
+
+	c, err := convertFile(context.Background(), source{path: inputPath}, filepath.Join(testDir, "output", "broken.png"), Options{Debug: true}.withDefaults())
+	if err == nil {
+		t.Fatal("expected a decode error")
+	}
+	if len(c.boxes) != 2 || c.boxes[1] != "  ftyp at 0, 12 bytes" {
+		t.Errorf("expected the box structure, got: %q", c.boxes)
+	}
+
+	c, _ = convertFile(context.Background(), source{path: inputPath}, filepath.Join(testDir, "plain", "broken.png"), Options{}.withDefaults())
+	if c.boxes != nil {
+		t.Errorf("expected no box structure without Debug, got: %q", c.boxes)
+	}
+}
@@ -0,0 +1,30 @@
+package converter
+
+import "errors"
+
+// SkipReasonDimensions is the skip reason with Options.Dimensions for files
+// whose decoded image is outside the bounds
+const SkipReasonDimensions = "outside dimensions"
+
+// errOutsideDimensions is returned by convertFile for an image outside
+// Options.Dimensions
+var errOutsideDimensions = errors.New("image outside dimension bounds")
+
+// DimensionBounds limits the width and height in pixels of the images to
+// convert; a zero field does not limit, so the zero value lets every image
+// through
+type DimensionBounds struct {
+	MinWidth, MinHeight, MaxWidth, MaxHeight int
+}
+
+// IsZero reports whether b is the zero value, which does not limit
+func (b DimensionBounds) IsZero() bool {
+	return b == DimensionBounds{}
+}
+
+// Contains reports whether an image of width by height pixels is within b
+func (b DimensionBounds) Contains(width, height int) bool {
+	return width >= b.MinWidth && height >= b.MinHeight &&
+		(b.MaxWidth == 0 || width <= b.MaxWidth) &&
+		(b.MaxHeight == 0 || height <= b.MaxHeight)
+}
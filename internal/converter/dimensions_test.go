@@ -0,0 +1,60 @@
+package converter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ==================== Dimensions Tests ====================
+
+func TestDimensionBounds_Contains(t *testing.T) {
+	tests := []struct {
+		bounds DimensionBounds
+		want   bool
+	}{
+		{DimensionBounds{}, true},
+		{DimensionBounds{MinWidth: 10, MinHeight: 10}, true},
+		{DimensionBounds{MinWidth: 11}, false},
+		{DimensionBounds{MaxWidth: 10, MaxHeight: 10}, true},
+		{DimensionBounds{MaxHeight: 9}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.bounds.Contains(10, 10); got != tt.want {
+			t.Errorf("expected %+v to contain 10x10: %v, got %v", tt.bounds, tt.want, got)
+		}
+	}
+}
+
+func TestConvertDirectory_Dimensions(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	os.MkdirAll(inputDir, 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "thumb.avif"))
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{Dimensions: DimensionBounds{MinWidth: 64}})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Successful != 0 || result.SkippedReasons[SkipReasonDimensions] != 1 {
+		t.Errorf("expected the 10x10 image to be skipped as %s, got %+v", SkipReasonDimensions, result.SkippedReasons)
+	}
+	if file := result.Files[0]; file.Width != 10 || file.Height != 10 {
+		t.Errorf("expected the skipped file to record its 10x10 size, got %dx%d", file.Width, file.Height)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "thumb.png")); !os.IsNotExist(err) {
+		t.Errorf("expected no output for a skipped file, got: %v", err)
+	}
+
+	file, err := ConvertFileContext(context.Background(), filepath.Join(inputDir, "thumb.avif"), outputDir, Options{Dimensions: DimensionBounds{MaxWidth: 64}})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if file.Status != StatusConverted {
+		t.Errorf("expected the image within bounds to be converted, got %s", file.Status)
+	}
+}
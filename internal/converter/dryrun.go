@@ -0,0 +1,47 @@
+package converter
+
+import "sync"
+
+// plannedOutputs holds the output paths of a DryRun and the inputs that
+// would write them, shared by the files of a run
+type plannedOutputs struct {
+	mu    sync.Mutex
+	owner map[string]string
+}
+
+func newPlannedOutputs() *plannedOutputs {
+	return &plannedOutputs{owner: map[string]string{}}
+}
+
+// take records that inputPath would write outputPath, and reports whether
+// no other input of the run would write it first
+func (p *plannedOutputs) take(outputPath, inputPath string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	owner, ok := p.owner[outputPath]
+	if ok && owner != inputPath {
+		return false
+	}
+	p.owner[outputPath] = inputPath
+	return true
+}
+
+// planOutput decides what a DryRun would do with the output of src at
+// outputPath, as convertFile would once converting: outputs that exist, or
+// that an earlier input of the run would write, are skipped with
+// ErrFileExists unless Overwrite
+// Nothing is read or written, so decoding errors are not found
+func planOutput(src source, outputPath string, opts Options, c *conversion) error {
+	first := opts.planned.take(outputPath, src.path)
+	_, err := opts.Output.Stat(outputPath)
+	exists := err == nil || !first
+	if exists && !opts.Overwrite {
+		return ErrFileExists
+	}
+	c.overwritten = exists
+	if opts.Verbose {
+		opts.logf("🔮 Would write: %s\n", outputPath)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package converter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// ==================== DryRun Tests ====================
+
+func TestConvert_DryRun(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	file, err := ConvertFileContext(context.Background(), inputPath, outputDir, Options{DryRun: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if file.Status != StatusConverted || file.OutputPath != filepath.Join(outputDir, "test.png") {
+		t.Errorf("expected test.png to be planned, got %+v", file)
+	}
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Error("expected a dry run to create nothing")
+	}
+
+	// Existing outputs are skipped, or overwritten with Overwrite
+	os.MkdirAll(outputDir, 0755)
+	os.WriteFile(filepath.Join(outputDir, "test.png"), []byte("old"), 0644)
+	if status, err := Convert(inputPath, outputDir, Options{DryRun: true}); err != nil || status != StatusSkipped {
+		t.Errorf("expected the existing output to be skipped, got %v, %v", status, err)
+	}
+	if status, err := Convert(inputPath, outputDir, Options{DryRun: true, Overwrite: true}); err != nil || status != StatusOverwritten {
+		t.Errorf("expected the existing output to be overwritten, got %v, %v", status, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(outputDir, "test.png")); string(data) != "old" {
+		t.Error("expected a dry run to leave the existing output as is")
+	}
+}
+
+func TestConvertDirectory_DryRun(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	os.MkdirAll(filepath.Join(inputDir, "sub"), 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "my photo.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "sub", "my photo.avif"))
+	// Not an image: a dry run never reads its inputs
+	os.WriteFile(filepath.Join(inputDir, "sub", "broken.avif"), []byte("not an image"), 0644)
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	for _, name := range []string{"my photo.avif", filepath.Join("sub", "my photo.avif"), filepath.Join("sub", "broken.avif")} {
+		os.Chtimes(filepath.Join(inputDir, name), modTime, modTime)
+	}
+
+	opts := Options{DryRun: true, Recursive: true, ReplaceSpaces: "-", Shard: ShardName, OutputTemplate: "{yyyy}"}
+	result, err := ConvertDirectory(inputDir, outputDir, opts)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Successful != 2 || result.Skipped() != 1 || result.Failed != 0 {
+		t.Errorf("expected 2 planned and 1 skipped, got %d, %d and %d failed", result.Successful, result.Skipped(), result.Failed)
+	}
+
+	// Outputs follow the naming options, and the second input named like
+	// the first is skipped, as it would be once the first is written
+	want := map[string]string{
+		filepath.Join(inputDir, "my photo.avif"):        filepath.Join(outputDir, "2024", "my", "my-photo.png"),
+		filepath.Join(inputDir, "sub", "my photo.avif"): filepath.Join(outputDir, "2024", "my", "my-photo.png"),
+		filepath.Join(inputDir, "sub", "broken.avif"):   filepath.Join(outputDir, "2024", "br", "broken.png"),
+	}
+	for _, file := range result.Files {
+		if file.OutputPath != want[file.InputPath] {
+			t.Errorf("expected %s for %s, got %s", want[file.InputPath], file.InputPath, file.OutputPath)
+		}
+	}
+	if result.SkippedReasons[SkipReasonExists] != 1 {
+		t.Errorf("expected the repeated output to be skipped, got: %v", result.SkippedReasons)
+	}
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Error("expected a dry run to create nothing")
+	}
+}
@@ -0,0 +1,178 @@
+package converter
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownFormat is returned when no encoder is registered for an output format
+var ErrUnknownFormat = errors.New("unknown output format")
+
+// EncodeOptions holds settings passed to an encoder
+type EncodeOptions struct {
+	// Quality is used by lossy formats, in the range 1-100
+	Quality int
+	// Optimize asks lossless formats to spend extra CPU time on a smaller output
+	Optimize bool
+	// Interlace asks formats that support it for progressive output, which
+	// is Adam7 interlacing for PNG
+	Interlace bool
+	// Chroma is the chroma subsampling of JPEG and AVIF outputs, Chroma420
+	// or Chroma444; empty means Chroma420
+	Chroma string
+	// Dither applies Floyd–Steinberg dithering when formats reduce the image
+	// to a palette, as GIF does
+	Dither bool
+}
+
+// EncoderFunc writes an image to w in a specific output format
+type EncoderFunc func(w io.Writer, img image.Image, opts EncodeOptions) error
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[string]EncoderFunc{}
+)
+
+func init() {
+	RegisterEncoder("png", encodePNG)
+	RegisterEncoder("jpeg", encodeJPEG)
+	RegisterEncoder(gifFormat, encodeGIF)
+	RegisterEncoder(avifFormat, encodeAVIF)
+}
+
+// RegisterEncoder makes an output format available under the given name
+// The name is also used as the output file extension
+// Registering an existing name replaces its encoder
+func RegisterEncoder(name string, fn EncoderFunc) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	encoders[name] = fn
+}
+
+// Formats returns the names of all registered output formats, sorted
+func Formats() []string {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+
+	names := make([]string, 0, len(encoders))
+	for name := range encoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// lookupEncoder returns the encoder registered for a format
+func lookupEncoder(format string) (EncoderFunc, error) {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+
+	fn, ok := encoders[format]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+	return fn, nil
+}
+
+// withContext returns an encoder that fails once ctx is done, which is
+// checked on every write, so long encodes stop early
+func withContext(ctx context.Context, encode EncoderFunc) EncoderFunc {
+	return func(w io.Writer, img image.Image, opts EncodeOptions) error {
+		return encode(ctxWriter{ctx: ctx, w: w}, img, opts)
+	}
+}
+
+// ctxWriter writes to w until ctx is done
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (w ctxWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
+
+// optimizeLevels are the PNG compression levels tried by opts.Optimize
+var optimizeLevels = []png.CompressionLevel{
+	png.BestSpeed,
+	png.DefaultCompression,
+	png.BestCompression,
+}
+
+// zlibLevels maps PNG compression levels to those of the interlaced encoder
+var zlibLevels = map[png.CompressionLevel]int{
+	png.BestSpeed:          zlib.BestSpeed,
+	png.DefaultCompression: zlib.DefaultCompression,
+	png.BestCompression:    zlib.BestCompression,
+}
+
+// encodePNG writes img as a PNG image
+// With opts.Optimize, img is encoded once per compression level in memory
+// and the smallest result is written, at roughly three times the CPU cost
+// With opts.Interlace, the image is written Adam7-interlaced
+func encodePNG(w io.Writer, img image.Image, opts EncodeOptions) error {
+	if !opts.Optimize {
+		return encodePNGLevel(w, img, png.DefaultCompression, opts.Interlace)
+	}
+
+	var smallest *bytes.Buffer
+	for _, level := range optimizeLevels {
+		buf := getBuffer()
+		if err := encodePNGLevel(buf, img, level, opts.Interlace); err != nil {
+			putBuffer(buf)
+			if smallest != nil {
+				putBuffer(smallest)
+			}
+			return err
+		}
+		if smallest == nil || buf.Len() < smallest.Len() {
+			buf, smallest = smallest, buf
+		}
+		if buf != nil {
+			putBuffer(buf)
+		}
+	}
+
+	defer putBuffer(smallest)
+	_, err := w.Write(smallest.Bytes())
+	return err
+}
+
+// encodePNGLevel writes img as a PNG image at a compression level
+func encodePNGLevel(w io.Writer, img image.Image, level png.CompressionLevel, interlace bool) error {
+	if interlace {
+		return encodeInterlacedPNG(w, img, zlibLevels[level])
+	}
+	encoder := png.Encoder{CompressionLevel: level}
+	if pool, ok := pngBuffers[level]; ok {
+		encoder.BufferPool = pool
+	}
+	return encoder.Encode(w, img)
+}
+
+// encodeJPEG writes img as a JPEG image
+// With opts.Chroma set to Chroma444, color images keep full-resolution chroma;
+// *image.Gray images are written without chroma by image/jpeg either way
+func encodeJPEG(w io.Writer, img image.Image, opts EncodeOptions) error {
+	quality := opts.Quality
+	if quality <= 0 {
+		quality = jpeg.DefaultQuality
+	}
+	if _, gray := img.(*image.Gray); opts.Chroma == Chroma444 && !gray {
+		return encodeJPEG444(w, img, quality)
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+}
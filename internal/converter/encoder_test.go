@@ -0,0 +1,136 @@
+package converter
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// ==================== Registry Tests ====================
+
+func TestFormats_IncludesBuiltins(t *testing.T) {
+	formats := Formats()
+
+	for _, want := range []string{"jpeg", "png"} {
+		found := false
+		for _, format := range formats {
+			if format == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected built-in format %q to be registered, got: %v", want, formats)
+		}
+	}
+}
+
+func TestLookupEncoder_UnknownFormat(t *testing.T) {
+	_, err := lookupEncoder("nonexistent")
+
+	if !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("expected ErrUnknownFormat, got: %v", err)
+	}
+}
+
+func TestRegisterEncoder_CustomFormat(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	RegisterEncoder("raw-test", func(w io.Writer, img image.Image, opts EncodeOptions) error {
+		_, err := w.Write([]byte("custom"))
+		return err
+	})
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	if _, err := Convert(inputPath, outputDir, Options{Format: "raw-test"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "test.raw-test"))
+	if err != nil {
+		t.Fatalf("expected custom output file to exist: %v", err)
+	}
+	if string(data) != "custom" {
+		t.Errorf("expected custom encoder output, got: %q", data)
+	}
+}
+
+// ==================== Built-in Encoder Tests ====================
+
+func TestEncodePNG_ProducesValidPNG(t *testing.T) {
+	var buf bytes.Buffer
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+
+	if err := encodePNG(&buf, img, EncodeOptions{}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := png.Decode(&buf); err != nil {
+		t.Fatalf("expected valid PNG, got: %v", err)
+	}
+}
+
+func TestEncodePNG_OptimizeKeepsSmallest(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i % 7 * 40)
+		if i%4 == 3 {
+			img.Pix[i] = 0xFF
+		}
+	}
+
+	var optimized bytes.Buffer
+	if err := encodePNG(&optimized, img, EncodeOptions{Optimize: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	for _, level := range optimizeLevels {
+		var buf bytes.Buffer
+		encoder := png.Encoder{CompressionLevel: level}
+		if err := encoder.Encode(&buf, img); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if optimized.Len() > buf.Len() {
+			t.Errorf("expected optimized output (%d bytes) to be no larger than level %d (%d bytes)", optimized.Len(), level, buf.Len())
+		}
+	}
+
+	decoded, err := png.Decode(&optimized)
+	if err != nil {
+		t.Fatalf("expected valid PNG, got: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.(*image.RGBA).Pix, img.Pix) {
+		t.Error("expected optimized PNG to be lossless")
+	}
+}
+
+func TestEncodeJPEG_QualityAffectsSize(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 31)
+	}
+
+	var low, high bytes.Buffer
+	if err := encodeJPEG(&low, img, EncodeOptions{Quality: 10}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := encodeJPEG(&high, img, EncodeOptions{Quality: 100}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if low.Len() >= high.Len() {
+		t.Errorf("expected lower quality to produce a smaller file, got %d >= %d", low.Len(), high.Len())
+	}
+	if _, err := jpeg.Decode(&high); err != nil {
+		t.Fatalf("expected valid JPEG, got: %v", err)
+	}
+}
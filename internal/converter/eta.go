@@ -0,0 +1,51 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// etaSmoothing is the weight of the latest file in the moving average of the
+// time per file behind ETA; older files fade out, so the estimate follows
+// runs of larger or smaller images
+const etaSmoothing = 0.2
+
+// recordDuration adds the time a file took to the moving average of ETA
+// Files are recorded one at a time, under the lock of r when several are
+// converted at the same time, and the average is stored atomically for ETA
+// to read it
+func (r *ConversionResult) recordDuration(d time.Duration) {
+	avg := r.avgDuration.Load()
+	if avg == 0 {
+		avg = int64(d)
+	} else {
+		avg = int64(math.Round(etaSmoothing*float64(d) + (1-etaSmoothing)*float64(avg)))
+	}
+	// Zero means no file was recorded yet
+	r.avgDuration.Store(max(avg, 1))
+}
+
+// ETA estimates the time left to process the remaining files from a moving
+// average of the time each file took, shared by the files converted at the
+// same time; ok is false until a file has been processed
+// Like Progress, it is safe to call from another goroutine while a
+// conversion is in flight, and the estimate grows while the scan runs
+func (r *ConversionResult) ETA() (eta time.Duration, ok bool) {
+	avg := r.avgDuration.Load()
+	if avg == 0 {
+		return 0, false
+	}
+	processed, total := r.Counts()
+	return time.Duration(avg * int64(max(total-processed, 0)) / max(r.workers.Load(), 1)), true
+}
+
+// progressLabel is the "[3/120, ETA 3m12s]" at the start of the verbose
+// line of the i-th of total files
+func (r *ConversionResult) progressLabel(i int, total int64) string {
+	eta, ok := r.ETA()
+	if !ok {
+		return fmt.Sprintf("[%d/%d, estimating…]", i, total)
+	}
+	return fmt.Sprintf("[%d/%d, ETA %s]", i, total, eta.Round(time.Second))
+}
@@ -0,0 +1,62 @@
+package converter
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ==================== ETA Tests ====================
+
+func TestConversionResult_ETA(t *testing.T) {
+	result := newFilesResult(10)
+	if _, ok := result.ETA(); ok {
+		t.Fatal("expected no estimate before any file")
+	}
+	if got := result.progressLabel(1, 10); got != "[1/10, estimating…]" {
+		t.Errorf("expected an estimating label, got %q", got)
+	}
+
+	result.recordDuration(2 * time.Second)
+	result.processed.Add(1)
+	eta, ok := result.ETA()
+	if !ok || eta != 18*time.Second {
+		t.Errorf("expected 18s for 9 files at 2s, got %v, %v", eta, ok)
+	}
+	if got := result.progressLabel(2, 10); got != "[2/10, ETA 18s]" {
+		t.Errorf("expected an ETA label, got %q", got)
+	}
+
+	// The average moves towards slower files without jumping to them
+	result.recordDuration(12 * time.Second)
+	result.processed.Add(1)
+	if eta, _ := result.ETA(); eta != 8*4*time.Second {
+		t.Errorf("expected 32s for 8 files at 4s, got %v", eta)
+	}
+}
+
+func TestConvertDirectory_LogETA(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	os.MkdirAll(inputDir, 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "b.avif"))
+
+	var log bytes.Buffer
+	result, err := ConvertDirectory(inputDir, filepath.Join(testDir, "output"), Options{Verbose: true, Log: &log})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	got := log.String()
+	if !strings.Contains(got, ", estimating…] Converting a.avif") || !strings.Contains(got, ", ETA ") {
+		t.Errorf("expected the first file to be estimating and the second to have an ETA, got: %q", got)
+	}
+	if eta, ok := result.ETA(); !ok || eta != 0 {
+		t.Errorf("expected no time left after the conversion, got %v, %v", eta, ok)
+	}
+}
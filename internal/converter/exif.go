@@ -0,0 +1,147 @@
+package converter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// errExif is returned for Exif data that cannot be read
+var errExif = errors.New("invalid Exif data")
+
+// exifTags names the Exif fields that metadata outputs keep, by tag; the
+// others, such as maker notes and thumbnails, are left out
+var exifTags = map[uint16]string{
+	0x010f: "Make",
+	0x0110: "Model",
+	0x0112: "Orientation",
+	0x0131: "Software",
+	0x0132: "DateTime",
+	0x013b: "Artist",
+	0x8298: "Copyright",
+	0x829a: "ExposureTime",
+	0x829d: "FNumber",
+	0x8827: "ISOSpeedRatings",
+	0x9003: "DateTimeOriginal",
+	0x9004: "DateTimeDigitized",
+	0x920a: "FocalLength",
+	0xa002: "PixelXDimension",
+	0xa003: "PixelYDimension",
+	0xa433: "LensMake",
+	0xa434: "LensModel",
+}
+
+// exifIFDPointer is the tag of IFD0 that points at the Exif IFD, which
+// holds the fields of the capture
+const exifIFDPointer = 0x8769
+
+// Exif field types
+const (
+	exifASCII    = 2
+	exifShort    = 3
+	exifLong     = 4
+	exifRational = 5
+)
+
+// exifTypeSizes are the sizes in bytes of the values of the field types
+// parseExif reads
+var exifTypeSizes = map[uint16]uint64{exifASCII: 1, exifShort: 2, exifLong: 4, exifRational: 8}
+
+// parseExif reads the fields of exifTags from Exif data, a TIFF header
+// followed by its IFDs, as the values of strings: text as is, numbers in
+// decimal and rationals as "1/250"
+// Fields of other types than text, numbers and rationals are left out
+func parseExif(data []byte) (map[string]string, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("%w: %d bytes", errExif, len(data))
+	}
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("%w: unknown byte order %q", errExif, data[:2])
+	}
+	if order.Uint16(data[2:]) != 42 {
+		return nil, fmt.Errorf("%w: not a TIFF header", errExif)
+	}
+
+	fields := map[string]string{}
+	exifIFD, err := readIFD(data, order, order.Uint32(data[4:]), fields)
+	if err != nil {
+		return nil, err
+	}
+	if exifIFD != 0 {
+		if _, err := readIFD(data, order, exifIFD, fields); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// readIFD reads the fields of exifTags from the IFD at offset into fields,
+// and returns the offset of the Exif IFD if it points at one
+func readIFD(data []byte, order binary.ByteOrder, offset uint32, fields map[string]string) (exifIFD uint32, err error) {
+	if uint64(offset)+2 > uint64(len(data)) {
+		return 0, fmt.Errorf("%w: IFD at %d is past the end", errExif, offset)
+	}
+	count := int(order.Uint16(data[offset:]))
+	entries := data[offset+2:]
+	if len(entries) < count*12 {
+		return 0, fmt.Errorf("%w: IFD at %d is truncated", errExif, offset)
+	}
+
+	for i := 0; i < count; i++ {
+		entry := entries[i*12 : i*12+12]
+		tag, kind, n := order.Uint16(entry), order.Uint16(entry[2:]), order.Uint32(entry[4:])
+		if tag == exifIFDPointer && kind == exifLong {
+			exifIFD = order.Uint32(entry[8:])
+			continue
+		}
+		name, ok := exifTags[tag]
+		if !ok {
+			continue
+		}
+
+		size := exifTypeSizes[kind]
+		if size == 0 || n == 0 {
+			continue
+		}
+		// Values of up to 4 bytes are held in the entry itself
+		value := entry[8:12]
+		if total := size * uint64(n); total > 4 {
+			start := uint64(order.Uint32(entry[8:]))
+			if start+total > uint64(len(data)) {
+				return 0, fmt.Errorf("%w: %s is past the end", errExif, name)
+			}
+			value = data[start : start+total]
+		} else {
+			value = value[:total]
+		}
+
+		var parts []string
+		switch kind {
+		case exifASCII:
+			fields[name] = strings.TrimSpace(strings.TrimRight(string(value), "\x00"))
+			continue
+		case exifShort:
+			for j := 0; j < len(value); j += 2 {
+				parts = append(parts, strconv.Itoa(int(order.Uint16(value[j:]))))
+			}
+		case exifLong:
+			for j := 0; j < len(value); j += 4 {
+				parts = append(parts, strconv.FormatUint(uint64(order.Uint32(value[j:])), 10))
+			}
+		case exifRational:
+			for j := 0; j < len(value); j += 8 {
+				parts = append(parts, fmt.Sprintf("%d/%d", order.Uint32(value[j:]), order.Uint32(value[j+4:])))
+			}
+		}
+		fields[name] = strings.Join(parts, ", ")
+	}
+	return exifIFD, nil
+}
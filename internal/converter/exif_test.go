@@ -0,0 +1,115 @@
+package converter
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// exifEntry is a field of a test IFD: its tag, type, count and value, held
+// in the entry when it fits in 4 bytes
+type exifEntry struct {
+	tag, kind uint16
+	count     uint32
+	value     []byte
+}
+
+// buildExif returns little-endian Exif data with the entries of IFD0, and
+// those of an Exif IFD when exif is not empty
+func buildExif(ifd0, exif []exifEntry) []byte {
+	if len(exif) > 0 {
+		ifd0 = append(ifd0, exifEntry{tag: exifIFDPointer, kind: exifLong, count: 1})
+	}
+	data, pointer := appendIFD([]byte("II\x2a\x00\x08\x00\x00\x00"), ifd0)
+	if len(exif) > 0 {
+		binary.LittleEndian.PutUint32(data[pointer:], uint32(len(data)))
+		data, _ = appendIFD(data, exif)
+	}
+	return data
+}
+
+// appendIFD appends an IFD of entries to data, followed by the values that
+// do not fit in their entry, and returns the position of the value of its
+// Exif IFD pointer, if it has one
+func appendIFD(data []byte, entries []exifEntry) ([]byte, int) {
+	le := binary.LittleEndian
+	valuesAt := len(data) + 2 + len(entries)*12 + 4
+	var values []byte
+	pointer := 0
+	data = le.AppendUint16(data, uint16(len(entries)))
+	for _, e := range entries {
+		data = le.AppendUint16(data, e.tag)
+		data = le.AppendUint16(data, e.kind)
+		data = le.AppendUint32(data, e.count)
+		if e.tag == exifIFDPointer {
+			pointer = len(data)
+		}
+		if len(e.value) <= 4 {
+			data = append(data, e.value...)
+			data = append(data, make([]byte, 4-len(e.value))...)
+		} else {
+			data = le.AppendUint32(data, uint32(valuesAt+len(values)))
+			values = append(values, e.value...)
+		}
+	}
+	data = le.AppendUint32(data, 0)
+	return append(data, values...), pointer
+}
+
+// ==================== parseExif Tests ====================
+
+func TestParseExif(t *testing.T) {
+	le := binary.LittleEndian
+	data := buildExif([]exifEntry{
+		{0x010f, exifASCII, 6, []byte("Canon\x00")},
+		{0x0110, exifASCII, 12, []byte("EOS R5 Mk2 \x00")},
+		{0x0112, exifShort, 1, le.AppendUint16(nil, 6)},
+		{0x9999, exifASCII, 4, []byte("xyz\x00")},
+	}, []exifEntry{
+		{0x829a, exifRational, 1, le.AppendUint32(le.AppendUint32(nil, 1), 250)},
+		{0x8827, exifShort, 1, le.AppendUint16(nil, 400)},
+		{0x9003, exifASCII, 20, []byte("2024:05:01 12:30:00\x00")},
+	})
+
+	fields, err := parseExif(data)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := map[string]string{
+		"Make":             "Canon",
+		"Model":            "EOS R5 Mk2",
+		"Orientation":      "6",
+		"ExposureTime":     "1/250",
+		"ISOSpeedRatings":  "400",
+		"DateTimeOriginal": "2024:05:01 12:30:00",
+	}
+	if len(fields) != len(want) {
+		t.Errorf("expected %d fields, got: %v", len(want), fields)
+	}
+	for name, value := range want {
+		if fields[name] != value {
+			t.Errorf("expected %s %q, got %q", name, value, fields[name])
+		}
+	}
+}
+
+func TestParseExif_Invalid(t *testing.T) {
+	tests := map[string][]byte{
+		"short":        []byte("II*"),
+		"byte order":   []byte("XX\x2a\x00\x08\x00\x00\x00"),
+		"magic":        []byte("II\x2b\x00\x08\x00\x00\x00"),
+		"IFD past end": []byte("II\x2a\x00\xff\x00\x00\x00"),
+		"truncated":    []byte("II\x2a\x00\x08\x00\x00\x00\x05\x00"),
+	}
+	for name, data := range tests {
+		if _, err := parseExif(data); !errors.Is(err, errExif) {
+			t.Errorf("%s: expected errExif, got: %v", name, err)
+		}
+	}
+
+	// A value past the end of the data
+	data := buildExif([]exifEntry{{0x010f, exifASCII, 6, []byte("Canon\x00")}}, nil)
+	if _, err := parseExif(data[:len(data)-3]); !errors.Is(err, errExif) {
+		t.Errorf("expected errExif for a truncated value, got: %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package converter
+
+import (
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// flattenHashLength is the number of bytes of the pixel hash in the names
+// of Options.FlattenHash, written as twice as many hex digits
+const flattenHashLength = 3
+
+// outputClaims maps the output paths of a run to the input that took each
+// first, see Options.FlattenHash
+type outputClaims struct {
+	mu    sync.Mutex
+	owner map[string]claimOwner
+}
+
+// claimOwner is the input that took an output path, with its image hash
+type claimOwner struct {
+	inputPath string
+	h         pixelHash
+}
+
+func newOutputClaims() *outputClaims {
+	return &outputClaims{owner: map[string]claimOwner{}}
+}
+
+// claim returns the path that inputPath, whose image hashes to h, is
+// written to: outputPath if no other input of the run took it first, and
+// otherwise hashedOutputPath, which is then taken by inputPath
+// Inputs whose images are identical share a hashed path, and so are
+// skipped like any existing output; a different image whose short hash is
+// taken gets the full hash instead
+func (o *outputClaims) claim(outputPath, inputPath string, h pixelHash) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	owner, ok := o.owner[outputPath]
+	if !ok || owner.inputPath == inputPath {
+		o.owner[outputPath] = claimOwner{inputPath: inputPath, h: h}
+		return outputPath
+	}
+
+	hashed := hashedOutputPath(outputPath, h, flattenHashLength)
+	if owner, ok := o.owner[hashed]; ok && owner.h != h && owner.inputPath != inputPath {
+		hashed = hashedOutputPath(outputPath, h, len(h))
+	}
+	if _, ok := o.owner[hashed]; !ok {
+		o.owner[hashed] = claimOwner{inputPath: inputPath, h: h}
+	}
+	return hashed
+}
+
+// hashedOutputPath appends the first n bytes of the hash of an image, in
+// hex, to the name of an output path, e.g. "out/img_a1b2c3.png" for
+// "out/img.png"
+func hashedOutputPath(outputPath string, h pixelHash, n int) string {
+	ext := filepath.Ext(outputPath)
+	return strings.TrimSuffix(outputPath, ext) + "_" + hex.EncodeToString(h[:n]) + ext
+}
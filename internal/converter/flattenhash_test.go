@@ -0,0 +1,103 @@
+package converter
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gen2brain/avif"
+)
+
+// ==================== FlattenHash Tests ====================
+
+func TestHashedOutputPath(t *testing.T) {
+	h := pixelHash{0xa1, 0xb2, 0xc3, 0xd4}
+	if got, want := hashedOutputPath(filepath.Join("out", "img.png"), h, flattenHashLength), filepath.Join("out", "img_a1b2c3.png"); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestOutputClaims(t *testing.T) {
+	claims := newOutputClaims()
+	h := pixelHash{1, 2, 3}
+
+	if got := claims.claim("img.png", "a/img.avif", h); got != "img.png" {
+		t.Errorf("expected the first input to keep its name, got %s", got)
+	}
+	if got := claims.claim("img.png", "a/img.avif", h); got != "img.png" {
+		t.Errorf("expected the same input to keep its name, got %s", got)
+	}
+	if got := claims.claim("img.png", "b/img.avif", h); got != "img_010203.png" {
+		t.Errorf("expected a hashed name, got %s", got)
+	}
+	if got := claims.claim("img.png", "c/img.avif", h); got != "img_010203.png" {
+		t.Errorf("expected an identical image to share the hashed name, got %s", got)
+	}
+}
+
+func TestOutputClaims_ShortHashCollision(t *testing.T) {
+	claims := newOutputClaims()
+	first, second := pixelHash{1, 2, 3, 4}, pixelHash{1, 2, 3, 5}
+
+	claims.claim("img.png", "a/img.avif", pixelHash{9})
+	if got := claims.claim("img.png", "b/img.avif", first); got != "img_010203.png" {
+		t.Errorf("expected a hashed name, got %s", got)
+	}
+
+	// A different image with the same short hash gets the full hash, so it
+	// is not skipped as the output of the first
+	want := hashedOutputPath("img.png", second, len(second))
+	if got := claims.claim("img.png", "c/img.avif", second); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if got := claims.claim("img.png", "c/img.avif", second); got != want {
+		t.Errorf("expected the same input to keep its name, got %s", got)
+	}
+}
+
+func TestConvertDirectory_FlattenHash(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	os.MkdirAll(filepath.Join(inputDir, "sub"), 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "img.avif"))
+
+	file, err := os.Create(filepath.Join(inputDir, "sub", "img.avif"))
+	if err != nil {
+		t.Fatalf("failed to create test AVIF file: %v", err)
+	}
+	err = avif.Encode(file, solidImage(10, 10, color.NRGBA{0, 0, 255, 255}))
+	file.Close()
+	if err != nil {
+		t.Fatalf("failed to encode test AVIF: %v", err)
+	}
+
+	opts := Options{Recursive: true, FlattenHash: true}
+	result, err := ConvertDirectory(inputDir, outputDir, opts)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Successful != 2 {
+		t.Fatalf("expected both inputs to be converted, got %d", result.Successful)
+	}
+
+	hashed := result.Files[1].OutputPath
+	if filepath.Dir(hashed) != outputDir || len(filepath.Base(hashed)) != len("img_a1b2c3.png") {
+		t.Errorf("expected a hashed name for the second input, got %s", hashed)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "img.png")); err != nil {
+		t.Errorf("expected img.png to exist, got: %v", err)
+	}
+
+	// Hashed names do not depend on the run, so a second run skips both
+	result, err = ConvertDirectory(inputDir, outputDir, opts)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Skipped() != 2 || result.Files[1].OutputPath != hashed {
+		t.Errorf("expected both inputs to be skipped as existing, got %d skipped, %s", result.Skipped(), result.Files[1].OutputPath)
+	}
+}
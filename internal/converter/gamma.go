@@ -0,0 +1,183 @@
+package converter
+
+import (
+	"avif2png/internal/isobmff"
+	"image"
+	"image/draw"
+	"math"
+)
+
+// Transfer characteristics of ITU-T H.273, as found in nclx colr properties
+const (
+	transferBT709     = 1
+	transferUnknown   = 2
+	transferGamma22   = 4
+	transferGamma28   = 5
+	transferBT601     = 6
+	transferSMPTE240  = 7
+	transferLinear    = 8
+	transferXVYCC     = 11
+	transferBT1361    = 12
+	transferSRGB      = 13
+	transferBT2020    = 14
+	transferBT2020_12 = 15
+	transferPQ        = 16
+	transferSMPTE428  = 17
+	transferHLG       = 18
+)
+
+// transferNames names the transfer characteristics for messages
+var transferNames = map[int]string{
+	transferBT709:     "BT.709",
+	transferUnknown:   "unspecified",
+	transferGamma22:   "gamma 2.2",
+	transferGamma28:   "gamma 2.8",
+	transferBT601:     "BT.601",
+	transferSMPTE240:  "SMPTE 240M",
+	transferLinear:    "linear",
+	transferXVYCC:     "xvYCC",
+	transferBT1361:    "BT.1361",
+	transferSRGB:      "sRGB",
+	transferBT2020:    "BT.2020",
+	transferBT2020_12: "BT.2020",
+	transferPQ:        "PQ",
+	transferSMPTE428:  "SMPTE 428",
+	transferHLG:       "HLG",
+}
+
+// hlgReferenceWhite is the HLG signal of diffuse white, 75% as in ITU-R
+// BT.2408, and pqReferenceWhite its luminance in nits for PQ
+const (
+	hlgReferenceWhite = 0.75
+	pqReferenceWhite  = 203
+)
+
+// sourceTransfer returns the transfer characteristics of the primary image
+// of an AVIF file, or of the first tile of a grid without its own colr
+// property; ok is false when the file has none
+func sourceTransfer(data []byte) (transfer int, ok bool) {
+	f, err := isobmff.Parse(data)
+	if err != nil {
+		return 0, false
+	}
+	primary := f.Item(f.PrimaryItemID)
+	if primary == nil {
+		return 0, false
+	}
+	if info, ok := primary.ColorInfo(); ok {
+		return info.Transfer, true
+	}
+
+	for _, ref := range f.References {
+		if ref.Type == "dimg" && ref.FromID == primary.ID && len(ref.ToIDs) > 0 {
+			if tile := f.Item(ref.ToIDs[0]); tile != nil {
+				if info, ok := tile.ColorInfo(); ok {
+					return info.Transfer, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// toLinear returns the inverse of the transfer function of transfer, which
+// maps a signal in [0, 1] to relative linear light, 1 being reference white
+// HDR transfers have light above reference white, which sRGB clips
+func toLinear(transfer int) (func(v float64) float64, bool) {
+	switch transfer {
+	case transferBT709, transferBT601, transferXVYCC, transferBT1361, transferBT2020, transferBT2020_12:
+		return func(v float64) float64 {
+			if v < 0.081 {
+				return v / 4.5
+			}
+			return math.Pow((v+0.099)/1.099, 1/0.45)
+		}, true
+	case transferGamma22:
+		return func(v float64) float64 { return math.Pow(v, 2.2) }, true
+	case transferGamma28:
+		return func(v float64) float64 { return math.Pow(v, 2.8) }, true
+	case transferSMPTE240:
+		return func(v float64) float64 {
+			if v < 0.0913 {
+				return v / 4
+			}
+			return math.Pow((v+0.1115)/1.1115, 1/0.45)
+		}, true
+	case transferLinear:
+		return func(v float64) float64 { return v }, true
+	case transferSMPTE428:
+		return func(v float64) float64 { return math.Pow(v, 2.6) * 52.37 / 48 }, true
+	case transferPQ:
+		const m1, m2 = 2610.0 / 16384, 2523.0 / 4096 * 128
+		const c1, c2, c3 = 3424.0 / 4096, 2413.0 / 4096 * 32, 2392.0 / 4096 * 32
+		return func(v float64) float64 {
+			p := math.Pow(v, 1/m2)
+			nits := 10000 * math.Pow(math.Max(p-c1, 0)/(c2-c3*p), 1/m1)
+			return nits / pqReferenceWhite
+		}, true
+	case transferHLG:
+		const a, b, c = 0.17883277, 0.28466892, 0.55991073
+		hlg := func(v float64) float64 {
+			if v <= 0.5 {
+				return v * v / 3
+			}
+			return (math.Exp((v-c)/a) + b) / 12
+		}
+		white := hlg(hlgReferenceWhite)
+		return func(v float64) float64 { return hlg(v) / white }, true
+	}
+	return nil, false
+}
+
+// fromLinearSRGB is the sRGB transfer function, clipping to [0, 1]
+func fromLinearSRGB(l float64) float64 {
+	switch {
+	case l <= 0:
+		return 0
+	case l >= 1:
+		return 1
+	case l <= 0.0031308:
+		return 12.92 * l
+	}
+	return 1.055*math.Pow(l, 1/2.4) - 0.055
+}
+
+// normalizeGamma converts img from transfer to the sRGB transfer function,
+// leaving alpha as is; images already in sRGB, or in a transfer it does
+// not know, are returned as they are
+// Images of more than 8 bits per channel keep their depth
+func normalizeGamma(img image.Image, transfer int) image.Image {
+	linear, ok := toLinear(transfer)
+	if !ok || transfer == transferSRGB {
+		return img
+	}
+
+	b := img.Bounds()
+	switch img.(type) {
+	case *image.RGBA64, *image.NRGBA64:
+		lut := make([]uint16, 1<<16)
+		for i := range lut {
+			lut[i] = uint16(math.Round(fromLinearSRGB(linear(float64(i)/0xffff)) * 0xffff))
+		}
+		out := image.NewNRGBA64(b)
+		draw.Draw(out, b, img, b.Min, draw.Src)
+		for i := 0; i < len(out.Pix); i += 8 {
+			for c := i; c < i+6; c += 2 {
+				v := lut[uint16(out.Pix[c])<<8|uint16(out.Pix[c+1])]
+				out.Pix[c], out.Pix[c+1] = uint8(v>>8), uint8(v)
+			}
+		}
+		return out
+	default:
+		var lut [256]uint8
+		for i := range lut {
+			lut[i] = uint8(math.Round(fromLinearSRGB(linear(float64(i)/0xff)) * 0xff))
+		}
+		out := image.NewNRGBA(b)
+		draw.Draw(out, b, img, b.Min, draw.Src)
+		for i := 0; i < len(out.Pix); i += 4 {
+			out.Pix[i], out.Pix[i+1], out.Pix[i+2] = lut[out.Pix[i]], lut[out.Pix[i+1]], lut[out.Pix[i+2]]
+		}
+		return out
+	}
+}
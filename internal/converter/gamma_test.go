@@ -0,0 +1,124 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gen2brain/avif"
+)
+
+// withTransfer returns a copy of an encoded AVIF file whose nclx colr
+// property has the given transfer characteristics
+func withTransfer(t *testing.T, data []byte, transfer int) []byte {
+	t.Helper()
+
+	i := bytes.Index(data, []byte("colrnclx"))
+	if i < 0 {
+		t.Fatal("expected the test AVIF to have an nclx colr property")
+	}
+	out := append([]byte(nil), data...)
+	out[i+10], out[i+11] = byte(transfer>>8), byte(transfer)
+	return out
+}
+
+// ==================== NormalizeGamma Tests ====================
+
+func TestNormalizeGamma(t *testing.T) {
+	tests := []struct {
+		transfer int
+		in, want uint8
+	}{
+		{transferLinear, 128, 188},
+		{transferGamma22, 128, 129},
+		{transferBT709, 128, 140},
+		{transferHLG, 192, 255},
+		{transferSRGB, 128, 128},
+		{3, 128, 128},
+	}
+
+	for _, tt := range tests {
+		img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+		img.Pix = []uint8{tt.in, 0, 255, 100}
+		got := normalizeGamma(img, tt.transfer).(*image.NRGBA).Pix
+		if got[0] != tt.want || got[1] != 0 || got[2] != 255 || got[3] != 100 {
+			t.Errorf("transfer %d: expected %d,0,255,100, got %v", tt.transfer, tt.want, got)
+		}
+	}
+}
+
+func TestNormalizeGamma_KeepsDepth(t *testing.T) {
+	img := image.NewRGBA64(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA64{0x8000, 0x8000, 0x8000, 0xffff})
+
+	out, ok := normalizeGamma(img, transferLinear).(*image.NRGBA64)
+	if !ok {
+		t.Fatalf("expected a 16-bit image, got %T", normalizeGamma(img, transferLinear))
+	}
+	if c := out.NRGBA64At(0, 0); c.R < 0xbc00 || c.R > 0xbd00 || c.A != 0xffff {
+		t.Errorf("expected linear gray to brighten to about 0xbc..., got %+v", c)
+	}
+}
+
+func TestToLinear_PQReferenceWhite(t *testing.T) {
+	linear, ok := toLinear(transferPQ)
+	if !ok {
+		t.Fatal("expected PQ to be supported")
+	}
+	// 203 nits, the reference white of BT.2408, is a PQ signal of 0.58
+	if got := linear(0.5807); got < 0.99 || got > 1.01 {
+		t.Errorf("expected reference white to map to 1, got %f", got)
+	}
+	if got := linear(1); got < 49 {
+		t.Errorf("expected 10000 nits to be far above reference white, got %f", got)
+	}
+}
+
+func TestConvert_NormalizeGamma(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	var buf bytes.Buffer
+	if err := avif.Encode(&buf, solidImage(8, 8, color.NRGBA{128, 128, 128, 255}), avif.Options{Quality: 100}); err != nil {
+		t.Fatalf("failed to encode test AVIF: %v", err)
+	}
+	// The encoder leaves the transfer unspecified, which is left as is
+	if transfer, ok := sourceTransfer(buf.Bytes()); !ok || transfer != transferUnknown {
+		t.Fatalf("expected encoded images to have an unspecified transfer, got %d", transfer)
+	}
+	inputPath := filepath.Join(testDir, "linear.avif")
+	if err := os.WriteFile(inputPath, withTransfer(t, buf.Bytes(), transferLinear), 0644); err != nil {
+		t.Fatalf("failed to write test AVIF: %v", err)
+	}
+
+	gray := func(opts Options) uint32 {
+		t.Helper()
+		outputDir := filepath.Join(testDir, "output")
+		os.RemoveAll(outputDir)
+		if _, err := Convert(inputPath, outputDir, opts); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		file, err := os.Open(filepath.Join(outputDir, "linear.png"))
+		if err != nil {
+			t.Fatalf("expected output to exist, got: %v", err)
+		}
+		defer file.Close()
+		img, err := png.Decode(file)
+		if err != nil {
+			t.Fatalf("expected a valid PNG, got: %v", err)
+		}
+		r, _, _, _ := img.At(4, 4).RGBA()
+		return r >> 8
+	}
+
+	if got := gray(Options{}); got < 124 || got > 132 {
+		t.Errorf("expected gray to be kept without --normalize-gamma, got %d", got)
+	}
+	if got := gray(Options{NormalizeGamma: true}); got < 184 || got > 192 {
+		t.Errorf("expected linear gray to be brightened to sRGB, got %d", got)
+	}
+}
@@ -0,0 +1,272 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/gen2brain/avif"
+)
+
+// gifFormat is the name of the GIF output format, the only one that keeps
+// the frames of animated inputs
+const gifFormat = "gif"
+
+// maxPaletteSamples is the most pixels a palette is built from; larger
+// images are sampled evenly
+const maxPaletteSamples = 1 << 16
+
+// localPaletteGain is how much lower the error of a frame's own palette has
+// to be than that of the shared palette for the frame to get a local one
+const localPaletteGain = 1.25
+
+// opaqueThreshold is the alpha from which a pixel is opaque in a GIF, which
+// has fully transparent pixels only
+const opaqueThreshold = 0x80
+
+// encodeGIF writes img as a single-frame GIF image, reduced to a palette of
+// at most 256 colors built from the image (median cut)
+func encodeGIF(w io.Writer, img image.Image, opts EncodeOptions) error {
+	return encodeAnimatedGIF(w, []image.Image{img}, nil, opts.Dither)
+}
+
+// encodeAnimatedGIF writes frames as an animated GIF that loops forever,
+// showing each frame for the matching delay in seconds
+// The frames share one global palette when it represents each of them about
+// as well as a palette of its own would; frames that would lose too much
+// color get a local palette instead, which costs 768 bytes per frame
+func encodeAnimatedGIF(w io.Writer, frames []image.Image, delays []float64, dither bool) error {
+	flattened := make([]*image.NRGBA, len(frames))
+	transparent := false
+	for i, frame := range frames {
+		var hasAlpha bool
+		flattened[i], hasAlpha = binaryAlpha(frame)
+		transparent = transparent || hasAlpha
+	}
+
+	global := buildPalette(flattened, transparent)
+	anim := &gif.GIF{Config: image.Config{ColorModel: global}}
+	for i, frame := range flattened {
+		p := global
+		if len(flattened) > 1 {
+			local := buildPalette(flattened[i:i+1], transparent)
+			if paletteError(frame, global) > paletteError(frame, local)*localPaletteGain {
+				p = local
+			}
+		}
+
+		anim.Image = append(anim.Image, quantize(frame, p, dither))
+		delay := 0
+		if i < len(delays) {
+			delay = max(1, int(math.Round(delays[i]*100)))
+		}
+		anim.Delay = append(anim.Delay, delay)
+
+		// Transparent pixels must show the background, not the last frame
+		disposal := byte(gif.DisposalNone)
+		if transparent {
+			disposal = gif.DisposalBackground
+		}
+		anim.Disposal = append(anim.Disposal, disposal)
+	}
+
+	bounds := frames[0].Bounds()
+	anim.Config.Width, anim.Config.Height = bounds.Dx(), bounds.Dy()
+	return gif.EncodeAll(w, anim)
+}
+
+// encodeAnimation encodes the frames of an animated source as a GIF and
+// returns it with the number of frames
+// Still images give nil data, so they are encoded like any other output
+func encodeAnimation(src source, opts Options) ([]byte, int, error) {
+	data, err := src.read()
+	if err != nil {
+		return nil, 0, err
+	}
+	anim, err := decodeFrames(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(anim.Image) < 2 {
+		return nil, len(anim.Image), nil
+	}
+	for i, frame := range anim.Image {
+		anim.Image[i] = fitCanvas(rotate(cropPercent(frame, opts.CropPct), opts.Rotate), opts)
+	}
+
+	var buf bytes.Buffer
+	if err := encodeAnimatedGIF(&buf, anim.Image, anim.Delay, opts.Dither); err != nil {
+		return nil, 0, fmt.Errorf("failed to encode GIF: %w", err)
+	}
+	return buf.Bytes(), len(anim.Image), nil
+}
+
+// decodeFrames decodes every frame of an AVIF image sequence in data, with
+// their delays in seconds; still images have a single frame
+func decodeFrames(data []byte) (*avif.AVIF, error) {
+	if len(data) < minAVIFSize {
+		return nil, ErrEmptyFile
+	}
+	anim, err := avif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode AVIF image: %w", err)
+	}
+	return anim, nil
+}
+
+// binaryAlpha returns img, moved to the origin, with every pixel made either
+// opaque or fully transparent, and whether any pixel is transparent
+func binaryAlpha(img image.Image) (*image.NRGBA, bool) {
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+
+	transparent := false
+	for i := 3; i < len(dst.Pix); i += 4 {
+		if dst.Pix[i] < opaqueThreshold {
+			copy(dst.Pix[i-3:i+1], []byte{0, 0, 0, 0})
+			transparent = true
+		} else {
+			dst.Pix[i] = 0xff
+		}
+	}
+	return dst, transparent
+}
+
+// buildPalette returns a palette of at most 256 colors for the opaque pixels
+// of imgs, with color.Transparent first when transparent is set
+func buildPalette(imgs []*image.NRGBA, transparent bool) color.Palette {
+	total := 0
+	for _, img := range imgs {
+		total += len(img.Pix) / 4
+	}
+	step := max(1, total/maxPaletteSamples)
+
+	var samples []color.NRGBA
+	n := 0
+	for _, img := range imgs {
+		for i := 0; i < len(img.Pix); i += 4 {
+			if n++; n%step != 0 || img.Pix[i+3] == 0 {
+				continue
+			}
+			samples = append(samples, color.NRGBA{img.Pix[i], img.Pix[i+1], img.Pix[i+2], 0xff})
+		}
+	}
+
+	size := 256
+	var p color.Palette
+	if transparent {
+		p = append(p, color.Transparent)
+		size--
+	}
+	for _, c := range medianCut(samples, size) {
+		p = append(p, c)
+	}
+	if len(p) == 0 {
+		p = append(p, color.Black)
+	}
+	return p
+}
+
+// medianCut splits samples into at most n boxes, each time halving the box
+// with the widest channel range at its median, and returns the mean color of
+// each box
+func medianCut(samples []color.NRGBA, n int) []color.NRGBA {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	boxes := [][]color.NRGBA{samples}
+	for len(boxes) < n {
+		// Split the box whose widest channel spans the largest range
+		best, bestChannel, bestRange := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			channel, span := widestChannel(box)
+			if span > bestRange {
+				best, bestChannel, bestRange = i, channel, span
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		sort.Slice(box, func(i, j int) bool {
+			return channelOf(box[i], bestChannel) < channelOf(box[j], bestChannel)
+		})
+		mid := len(box) / 2
+		boxes[best] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+
+	colors := make([]color.NRGBA, len(boxes))
+	for i, box := range boxes {
+		var r, g, b int
+		for _, c := range box {
+			r, g, b = r+int(c.R), g+int(c.G), b+int(c.B)
+		}
+		count := len(box)
+		colors[i] = color.NRGBA{uint8((r + count/2) / count), uint8((g + count/2) / count), uint8((b + count/2) / count), 0xff}
+	}
+	return colors
+}
+
+// widestChannel returns the channel (0 red, 1 green, 2 blue) whose values
+// spread the most in box, and that spread
+func widestChannel(box []color.NRGBA) (channel, span int) {
+	for ch := 0; ch < 3; ch++ {
+		lo, hi := 255, 0
+		for _, c := range box {
+			v := int(channelOf(c, ch))
+			lo, hi = min(lo, v), max(hi, v)
+		}
+		if hi-lo > span {
+			channel, span = ch, hi-lo
+		}
+	}
+	return channel, span
+}
+
+func channelOf(c color.NRGBA, channel int) uint8 {
+	switch channel {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	default:
+		return c.B
+	}
+}
+
+// paletteError returns the mean squared distance between the opaque pixels
+// of img, sampled like buildPalette, and the nearest colors of p
+func paletteError(img *image.NRGBA, p color.Palette) float64 {
+	step := 4 * max(1, len(img.Pix)/4/maxPaletteSamples)
+	var sum float64
+	var count int
+	for i := 0; i < len(img.Pix); i += step {
+		if img.Pix[i+3] == 0 {
+			continue
+		}
+		c := color.NRGBA{img.Pix[i], img.Pix[i+1], img.Pix[i+2], 0xff}
+		r, g, b, _ := p[p.Index(c)].RGBA()
+		dr := float64(c.R) - float64(r>>8)
+		dg := float64(c.G) - float64(g>>8)
+		db := float64(c.B) - float64(b>>8)
+		sum += dr*dr + dg*dg + db*db
+		count++
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
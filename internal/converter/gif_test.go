@@ -0,0 +1,184 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ==================== GIF Tests ====================
+
+// solidImage returns a w x h image filled with c
+func solidImage(w, h int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestEncodeAnimatedGIF_FramesAndDelays(t *testing.T) {
+	frames := []image.Image{
+		solidImage(8, 6, color.NRGBA{255, 0, 0, 255}),
+		solidImage(8, 6, color.NRGBA{0, 255, 0, 255}),
+		solidImage(8, 6, color.NRGBA{0, 0, 255, 255}),
+	}
+
+	var buf bytes.Buffer
+	if err := encodeAnimatedGIF(&buf, frames, []float64{0.1, 0.25, 1}, false); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("expected a valid GIF, got: %v", err)
+	}
+
+	if len(anim.Image) != 3 {
+		t.Fatalf("expected 3 frames, got: %d", len(anim.Image))
+	}
+	for i, want := range []int{10, 25, 100} {
+		if anim.Delay[i] != want {
+			t.Errorf("frame %d: expected delay %d, got %d", i, want, anim.Delay[i])
+		}
+	}
+	if anim.LoopCount != 0 {
+		t.Errorf("expected the animation to loop forever, got loop count %d", anim.LoopCount)
+	}
+	for i, want := range []color.NRGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}} {
+		if got := color.NRGBAModel.Convert(anim.Image[i].At(3, 3)); got != want {
+			t.Errorf("frame %d: expected %v, got %v", i, want, got)
+		}
+	}
+}
+
+func TestEncodeAnimatedGIF_LocalPaletteForDistinctFrames(t *testing.T) {
+	// Each frame is a gradient of its own hue, so a shared palette spreads
+	// its colors over all three and loses detail in each
+	gradient := func(c func(v uint8) color.NRGBA) image.Image {
+		img := image.NewNRGBA(image.Rect(0, 0, 256, 4))
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 256; x++ {
+				img.SetNRGBA(x, y, c(uint8(x)))
+			}
+		}
+		return img
+	}
+	frames := []image.Image{
+		gradient(func(v uint8) color.NRGBA { return color.NRGBA{v, 0, 0, 255} }),
+		gradient(func(v uint8) color.NRGBA { return color.NRGBA{0, v, 0, 255} }),
+		gradient(func(v uint8) color.NRGBA { return color.NRGBA{0, 0, v, 255} }),
+	}
+
+	var buf bytes.Buffer
+	if err := encodeAnimatedGIF(&buf, frames, nil, false); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("expected a valid GIF, got: %v", err)
+	}
+	// Frames without a local palette are decoded with the global one
+	global := paletteBytes(anim.Config.ColorModel.(color.Palette))
+	for i, frame := range anim.Image {
+		if bytes.Equal(paletteBytes(frame.Palette), global) {
+			t.Errorf("frame %d: expected a palette of its own", i)
+		}
+	}
+
+	// Identical frames share the global palette
+	buf.Reset()
+	if err := encodeAnimatedGIF(&buf, []image.Image{frames[0], frames[0]}, nil, false); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	shared, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("expected a valid GIF, got: %v", err)
+	}
+	global = paletteBytes(shared.Config.ColorModel.(color.Palette))
+	for i, frame := range shared.Image {
+		if !bytes.Equal(paletteBytes(frame.Palette), global) {
+			t.Errorf("frame %d: expected the global palette", i)
+		}
+	}
+}
+
+// paletteBytes returns the RGBA values of a palette, for comparison
+func paletteBytes(p color.Palette) []byte {
+	var b []byte
+	for _, c := range p {
+		r, g, bl, a := c.RGBA()
+		b = append(b, byte(r>>8), byte(g>>8), byte(bl>>8), byte(a>>8))
+	}
+	return b
+}
+
+func TestEncodeGIF_Transparency(t *testing.T) {
+	img := solidImage(4, 4, color.NRGBA{10, 200, 30, 255})
+	img.SetNRGBA(0, 0, color.NRGBA{0, 0, 0, 0})
+	img.SetNRGBA(1, 0, color.NRGBA{10, 200, 30, 0x40})
+
+	var buf bytes.Buffer
+	if err := encodeGIF(&buf, img, EncodeOptions{}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	decoded, err := gif.Decode(&buf)
+	if err != nil {
+		t.Fatalf("expected a valid GIF, got: %v", err)
+	}
+
+	for _, x := range []int{0, 1} {
+		if _, _, _, a := decoded.At(x, 0).RGBA(); a != 0 {
+			t.Errorf("pixel %d: expected a transparent pixel, got alpha %d", x, a)
+		}
+	}
+	if got := color.NRGBAModel.Convert(decoded.At(2, 2)); got != (color.NRGBA{10, 200, 30, 255}) {
+		t.Errorf("expected an opaque pixel to keep its color, got %v", got)
+	}
+}
+
+func TestMedianCut_LimitsColors(t *testing.T) {
+	img := noiseImage(64, 255)
+	var samples []color.NRGBA
+	for i := 0; i < len(img.Pix); i += 4 {
+		samples = append(samples, color.NRGBA{img.Pix[i], img.Pix[i+1], img.Pix[i+2], 255})
+	}
+
+	if colors := medianCut(samples, 16); len(colors) != 16 {
+		t.Errorf("expected 16 colors, got %d", len(colors))
+	}
+	if colors := medianCut(samples[:1], 16); len(colors) != 1 {
+		t.Errorf("expected a single color for a single sample, got %d", len(colors))
+	}
+}
+
+func TestConvert_GIF(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	if _, err := Convert(inputPath, outputDir, Options{Format: "gif", Dither: true, Verify: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	file, err := os.Open(filepath.Join(outputDir, "test.gif"))
+	if err != nil {
+		t.Fatalf("failed to open output: %v", err)
+	}
+	defer file.Close()
+	anim, err := gif.DecodeAll(file)
+	if err != nil {
+		t.Fatalf("expected a valid GIF, got: %v", err)
+	}
+	if len(anim.Image) != 1 {
+		t.Errorf("expected a single frame for a still image, got: %d", len(anim.Image))
+	}
+}
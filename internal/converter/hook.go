@@ -0,0 +1,99 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ErrHookFailed is returned when the post-conversion command exits unsuccessfully
+var ErrHookFailed = errors.New("exec hook failed")
+
+// maxHookOutput is the amount of command output included in a hook error
+const maxHookOutput = 512
+
+// SplitCommand splits a command line into arguments, honoring single and
+// double quotes and backslash escapes like a POSIX shell, without expanding
+// anything else
+func SplitCommand(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\\' && quote != '\'':
+			if i+1 >= len(runes) {
+				return nil, errors.New("command ends with a backslash")
+			}
+			i++
+			current.WriteRune(runes[i])
+			inArg = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in command", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	if len(args) == 0 {
+		return nil, errors.New("command is empty")
+	}
+
+	return args, nil
+}
+
+// runHook runs the post-conversion command for a converted file
+// {input} and {output} are substituted within each argument, so paths with
+// spaces or shell metacharacters stay a single argument
+func runHook(command []string, inputPath, outputPath string) error {
+	replacer := strings.NewReplacer("{input}", inputPath, "{output}", outputPath)
+	args := make([]string, len(command))
+	for i, arg := range command {
+		args[i] = replacer.Replace(arg)
+	}
+
+	output, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+	if err == nil {
+		return nil
+	}
+
+	message := strings.TrimSpace(string(output))
+	if len(message) > maxHookOutput {
+		message = message[:maxHookOutput] + "..."
+	}
+	if message == "" {
+		return fmt.Errorf("%w: %v", ErrHookFailed, err)
+	}
+	return fmt.Errorf("%w: %v: %s", ErrHookFailed, err, message)
+}
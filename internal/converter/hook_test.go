@@ -0,0 +1,113 @@
+package converter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ==================== SplitCommand Tests ====================
+
+func TestSplitCommand(t *testing.T) {
+	tests := map[string][]string{
+		"pngquant {output}":                {"pngquant", "{output}"},
+		"  cp   {output}  /backup/ ":       {"cp", "{output}", "/backup/"},
+		`upload --name "my file" {output}`: {"upload", "--name", "my file", "{output}"},
+		`sh -c 'echo "$1"' _ {output}`:     {"sh", "-c", `echo "$1"`, "_", "{output}"},
+		`echo a\ b ""`:                     {"echo", "a b", ""},
+	}
+
+	for command, want := range tests {
+		got, err := SplitCommand(command)
+		if err != nil {
+			t.Errorf("SplitCommand(%q): expected no error, got: %v", command, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("SplitCommand(%q): expected %q, got %q", command, want, got)
+		}
+	}
+}
+
+func TestSplitCommand_Invalid(t *testing.T) {
+	for _, command := range []string{"", "   ", `echo "unterminated`, `echo 'open`, `echo \`} {
+		if _, err := SplitCommand(command); err == nil {
+			t.Errorf("SplitCommand(%q): expected error, got nil", command)
+		}
+	}
+}
+
+// ==================== Exec Hook Tests ====================
+
+func TestConvertDirectory_ExecHook(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input dir")
+	outputDir := filepath.Join(testDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "my image.avif"))
+
+	// Paths with spaces must reach the command as single arguments
+	opts := Options{Exec: []string{"cp", "{output}", "{output}.bak"}}
+	result, err := ConvertDirectory(inputDir, outputDir, opts)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.Successful != 1 {
+		t.Fatalf("expected 1 successful conversion, got: %d (%v)", result.Successful, result.Errors)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "my image.png.bak")); err != nil {
+		t.Errorf("expected hook to run on the output: %v", err)
+	}
+}
+
+func TestConvertDirectory_ExecHookFailure(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "image.avif"))
+
+	opts := Options{Exec: []string{"sh", "-c", "echo boom >&2; exit 3"}}
+	result, err := ConvertDirectory(inputDir, filepath.Join(testDir, "output"), opts)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.Failed != 1 || len(result.Errors) != 1 {
+		t.Fatalf("expected the hook failure to fail the file, got: %d failed", result.Failed)
+	}
+	hookErr := result.Errors[0].Error
+	if !errors.Is(hookErr, ErrHookFailed) || !strings.Contains(hookErr.Error(), "exit status 3") || !strings.Contains(hookErr.Error(), "boom") {
+		t.Errorf("expected exit status and output in the error, got: %v", hookErr)
+	}
+}
+
+func TestConvert_ExecHookNotRunOnSkip(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	setupExistingOutputs(t, inputDir, outputDir, "image")
+
+	marker := filepath.Join(testDir, "ran")
+	status, err := Convert(filepath.Join(inputDir, "image.avif"), outputDir, Options{Exec: []string{"touch", marker}})
+	if err != nil || status != StatusSkipped {
+		t.Fatalf("expected StatusSkipped without error, got: %s, %v", status, err)
+	}
+
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("expected hook not to run for skipped files")
+	}
+}
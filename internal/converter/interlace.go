@@ -0,0 +1,252 @@
+package converter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"io"
+)
+
+// pngSignature starts every PNG file
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// adam7Passes are the start and step of each Adam7 pass, as x0, y0, dx, dy
+var adam7Passes = [7][4]int{
+	{0, 0, 8, 8},
+	{4, 0, 8, 8},
+	{0, 4, 4, 8},
+	{2, 0, 4, 4},
+	{0, 2, 2, 4},
+	{1, 0, 2, 2},
+	{0, 1, 1, 2},
+}
+
+// maxIDATSize is the largest IDAT chunk written by encodeInterlacedPNG
+const maxIDATSize = 1 << 20
+
+// PNG color types
+const (
+	pngGray     = 0
+	pngRGB      = 2
+	pngRGBAlpha = 6
+)
+
+// pngFilterCount is the number of PNG row filters, None through Paeth
+const pngFilterCount = 5
+
+// encodeInterlacedPNG writes img as an Adam7-interlaced PNG image
+// image/png can read interlaced images but not write them, so this is a
+// small encoder of its own; it writes 8- or 16-bit gray, RGB or RGBA
+// depending on the color model of img, and compresses at zlib level
+func encodeInterlacedPNG(w io.Writer, img image.Image, level int) error {
+	bounds := img.Bounds()
+	colorType, depth := pngColorType(img)
+	bpp := pngChannels(colorType) * depth / 8
+
+	var idat bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&idat, level)
+	if err != nil {
+		return err
+	}
+
+	for _, pass := range adam7Passes {
+		x0, y0, dx, dy := pass[0], pass[1], pass[2], pass[3]
+		width := (bounds.Dx() - x0 + dx - 1) / dx
+		height := (bounds.Dy() - y0 + dy - 1) / dy
+		if width <= 0 || height <= 0 {
+			continue
+		}
+
+		// Filtering starts over with an empty previous row in every pass
+		prev := make([]byte, width*bpp)
+		cur := make([]byte, width*bpp)
+		for y := y0; y < bounds.Dy(); y += dy {
+			for i, x := 0, x0; x < bounds.Dx(); i, x = i+1, x+dx {
+				putPixel(cur[i*bpp:], img.At(bounds.Min.X+x, bounds.Min.Y+y), colorType, depth)
+			}
+			if _, err := zw.Write(filterRow(cur, prev, bpp)); err != nil {
+				return err
+			}
+			prev, cur = cur, prev
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(pngSignature); err != nil {
+		return err
+	}
+
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(bounds.Dx()))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(bounds.Dy()))
+	ihdr[8] = byte(depth)
+	ihdr[9] = byte(colorType)
+	ihdr[12] = 1 // Adam7
+	if err := writeChunk(bw, "IHDR", ihdr); err != nil {
+		return err
+	}
+
+	data := idat.Bytes()
+	for len(data) > 0 {
+		n := min(len(data), maxIDATSize)
+		if err := writeChunk(bw, "IDAT", data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+
+	if err := writeChunk(bw, "IEND", nil); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// pngColorType picks the PNG color type and bit depth that hold img losslessly
+func pngColorType(img image.Image) (colorType, depth int) {
+	depth = 8
+	switch img.ColorModel() {
+	case color.GrayModel:
+		return pngGray, depth
+	case color.Gray16Model:
+		return pngGray, 16
+	case color.RGBA64Model, color.NRGBA64Model:
+		depth = 16
+	}
+
+	if isOpaque(img) {
+		return pngRGB, depth
+	}
+	return pngRGBAlpha, depth
+}
+
+// pngChannels returns the number of samples per pixel of a color type
+func pngChannels(colorType int) int {
+	switch colorType {
+	case pngGray:
+		return 1
+	case pngRGB:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// putPixel writes the samples of c to b, big-endian for 16-bit depths
+func putPixel(b []byte, c color.Color, colorType, depth int) {
+	if colorType == pngGray {
+		g := color.Gray16Model.Convert(c).(color.Gray16).Y
+		if depth == 16 {
+			binary.BigEndian.PutUint16(b, g)
+		} else {
+			b[0] = byte(g >> 8)
+		}
+		return
+	}
+
+	// Non-premultiplied colors are taken as they are, since converting them
+	// through premultiplied alpha would lose precision in translucent pixels
+	var samples [4]uint16
+	switch n := c.(type) {
+	case color.NRGBA:
+		samples = [4]uint16{uint16(n.R) * 0x101, uint16(n.G) * 0x101, uint16(n.B) * 0x101, uint16(n.A) * 0x101}
+	default:
+		n64 := color.NRGBA64Model.Convert(c).(color.NRGBA64)
+		samples = [4]uint16{n64.R, n64.G, n64.B, n64.A}
+	}
+	for i := 0; i < pngChannels(colorType); i++ {
+		if depth == 16 {
+			binary.BigEndian.PutUint16(b[i*2:], samples[i])
+		} else {
+			b[i] = byte(samples[i] >> 8)
+		}
+	}
+}
+
+// filterRow returns cur prefixed with the PNG filter that minimizes the sum
+// of absolute differences, the heuristic also used by image/png
+func filterRow(cur, prev []byte, bpp int) []byte {
+	var best []byte
+	bestSum := -1
+	for filter := 0; filter < pngFilterCount; filter++ {
+		out := make([]byte, len(cur)+1)
+		out[0] = byte(filter)
+		sum := 0
+		for i := range cur {
+			var a, c byte
+			if i >= bpp {
+				a, c = cur[i-bpp], prev[i-bpp]
+			}
+			b := prev[i]
+
+			var predicted byte
+			switch filter {
+			case 1:
+				predicted = a
+			case 2:
+				predicted = b
+			case 3:
+				predicted = byte((int(a) + int(b)) / 2)
+			case 4:
+				predicted = paeth(a, b, c)
+			}
+			out[i+1] = cur[i] - predicted
+			sum += abs8(out[i+1])
+		}
+		if bestSum < 0 || sum < bestSum {
+			best, bestSum = out, sum
+		}
+	}
+	return best
+}
+
+// paeth is the Paeth predictor of the PNG specification
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := absInt(p-int(a)), absInt(p-int(b)), absInt(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+// abs8 returns the magnitude of a filtered byte read as a signed value
+func abs8(v byte) int {
+	return absInt(int(int8(v)))
+}
+
+// absInt returns the absolute value of v
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// writeChunk writes a PNG chunk with its length and CRC
+func writeChunk(w io.Writer, chunkType string, data []byte) error {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], chunkType)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	footer := binary.BigEndian.AppendUint32(nil, crc.Sum32())
+
+	for _, b := range [][]byte{header, data, footer} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
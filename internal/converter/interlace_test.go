@@ -0,0 +1,140 @@
+package converter
+
+import (
+	"bytes"
+	"compress/zlib"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ==================== Interlaced PNG Tests ====================
+
+// pngInterlaceMethod returns the interlace method stored in the IHDR of a PNG
+func pngInterlaceMethod(t *testing.T, data []byte) byte {
+	t.Helper()
+	if len(data) < 29 || !bytes.Equal(data[:8], pngSignature) || string(data[12:16]) != "IHDR" {
+		t.Fatalf("expected a PNG starting with IHDR, got: %q", data[:min(len(data), 16)])
+	}
+	return data[28]
+}
+
+// patternImages returns images of several color models, with a pattern that
+// exercises every filter
+func patternImages(w, h int) map[string]image.Image {
+	nrgba := image.NewNRGBA(image.Rect(0, 0, w, h))
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	gray := image.NewGray(image.Rect(0, 0, w, h))
+	gray16 := image.NewGray16(image.Rect(0, 0, w, h))
+	nrgba64 := image.NewNRGBA64(image.Rect(2, 3, w+2, h+3))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(x*37 + y*11)
+			nrgba.SetNRGBA(x, y, color.NRGBA{v, uint8(x * 5), uint8(y * 9), uint8(x + y*3)})
+			rgba.SetRGBA(x, y, color.RGBA{v, uint8(y * 7), uint8(x * 3), 255})
+			gray.SetGray(x, y, color.Gray{v})
+			gray16.SetGray16(x, y, color.Gray16{uint16(x*4099 + y*257)})
+			nrgba64.SetNRGBA64(x+2, y+3, color.NRGBA64{uint16(x * 3001), uint16(y * 1999), 0x1234, uint16(0xffff - x*100)})
+		}
+	}
+	return map[string]image.Image{"nrgba": nrgba, "rgba": rgba, "gray": gray, "gray16": gray16, "nrgba64": nrgba64}
+}
+
+func TestEncodeInterlacedPNG_RoundTrip(t *testing.T) {
+	for _, size := range [][2]int{{1, 1}, {3, 5}, {9, 10}, {17, 4}} {
+		for name, img := range patternImages(size[0], size[1]) {
+			var buf bytes.Buffer
+			if err := encodeInterlacedPNG(&buf, img, zlib.DefaultCompression); err != nil {
+				t.Fatalf("%s %v: expected no error, got: %v", name, size, err)
+			}
+			if method := pngInterlaceMethod(t, buf.Bytes()); method != 1 {
+				t.Errorf("%s %v: expected Adam7 interlacing, got method %d", name, size, method)
+			}
+
+			decoded, err := png.Decode(&buf)
+			if err != nil {
+				t.Fatalf("%s %v: expected a valid PNG, got: %v", name, size, err)
+			}
+			if decoded.Bounds().Size() != img.Bounds().Size() {
+				t.Fatalf("%s %v: expected size %v, got: %v", name, size, img.Bounds().Size(), decoded.Bounds().Size())
+			}
+			if p := psnr(img, decoded); p < 1e9 {
+				t.Errorf("%s %v: expected a lossless round trip, got PSNR %v", name, size, p)
+			}
+		}
+	}
+}
+
+func TestEncodeInterlacedPNG_KeepsBitDepth(t *testing.T) {
+	images := patternImages(4, 4)
+	tests := map[string]color.Model{
+		"gray":    color.GrayModel,
+		"gray16":  color.Gray16Model,
+		"rgba":    color.RGBAModel,
+		"nrgba":   color.NRGBAModel,
+		"nrgba64": color.NRGBA64Model,
+	}
+
+	for name, want := range tests {
+		var buf bytes.Buffer
+		if err := encodeInterlacedPNG(&buf, images[name], zlib.DefaultCompression); err != nil {
+			t.Fatalf("%s: expected no error, got: %v", name, err)
+		}
+		decoded, err := png.Decode(&buf)
+		if err != nil {
+			t.Fatalf("%s: expected a valid PNG, got: %v", name, err)
+		}
+		if decoded.ColorModel() != want {
+			t.Errorf("%s: expected the decoded color model to match the source", name)
+		}
+	}
+}
+
+func TestEncodePNG_Interlace(t *testing.T) {
+	img := patternImages(8, 8)["nrgba"]
+
+	for _, optimize := range []bool{false, true} {
+		var buf bytes.Buffer
+		if err := encodePNG(&buf, img, EncodeOptions{Interlace: true, Optimize: optimize}); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if method := pngInterlaceMethod(t, buf.Bytes()); method != 1 {
+			t.Errorf("optimize %v: expected Adam7 interlacing, got method %d", optimize, method)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := encodePNG(&buf, img, EncodeOptions{}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if method := pngInterlaceMethod(t, buf.Bytes()); method != 0 {
+		t.Errorf("expected no interlacing by default, got method %d", method)
+	}
+}
+
+func TestConvert_Interlace(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	if _, err := Convert(inputPath, outputDir, Options{Interlace: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "test.png"))
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if method := pngInterlaceMethod(t, data); method != 1 {
+		t.Errorf("expected Adam7 interlacing, got method %d", method)
+	}
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Errorf("expected a valid PNG, got: %v", err)
+	}
+}
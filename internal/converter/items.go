@@ -0,0 +1,63 @@
+package converter
+
+import (
+	"avif2png/internal/isobmff"
+	"bytes"
+	"fmt"
+
+	"github.com/gen2brain/avif"
+)
+
+// itemOutputName returns the suffix of the output of the index-th top-level
+// image item, e.g. "item1" for "img_item1.png"
+func itemOutputName(index int) string {
+	return fmt.Sprintf("item%d", index)
+}
+
+// extractItems writes every top-level image item of an AVIF file, held in
+// data, next to outputPath as "img_item0.png", "img_item1.png", ... in item
+// order; this covers multi-image files such as bursts, not animation frames
+// or auxiliary images
+// Files with a single image item get no extra outputs
+// Existing item outputs are left untouched unless opts.Overwrite is set
+// It returns the paths of the files it wrote
+func extractItems(data []byte, outputPath string, encode EncoderFunc, opts Options) ([]string, error) {
+	container, err := isobmff.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse AVIF container: %w", err)
+	}
+
+	ids := container.TopLevelImages()
+	if len(ids) < 2 {
+		return nil, nil
+	}
+
+	var written []string
+	for i, id := range ids {
+		itemPath := auxiliaryOutputPath(outputPath, itemOutputName(i))
+		if _, err := opts.Output.Stat(itemPath); err == nil && !opts.Overwrite {
+			continue
+		}
+
+		patched, err := container.WithPrimaryItem(data, id)
+		if err != nil {
+			return written, err
+		}
+
+		img, err := avif.Decode(bytes.NewReader(patched))
+		if err != nil {
+			return written, fmt.Errorf("failed to decode image item %d: %w", id, err)
+		}
+
+		if err := writeImage(itemPath, img, encode, opts, nil); err != nil {
+			return written, err
+		}
+		written = append(written, itemPath)
+
+		if opts.Verbose {
+			opts.logf("✅ Saved item: %s\n", itemPath)
+		}
+	}
+
+	return written, nil
+}
@@ -0,0 +1,91 @@
+package converter
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// createTestMultiImageAVIF creates an AVIF with two top-level image items by
+// detaching the alpha item of a semi-transparent file from its colour item
+func createTestMultiImageAVIF(t *testing.T, path string) {
+	t.Helper()
+
+	createTestAVIFWithAlpha(t, path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read test AVIF: %v", err)
+	}
+	data = bytes.Replace(data, []byte("auxl"), []byte("free"), 1)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write test AVIF: %v", err)
+	}
+}
+
+// ==================== AllItems Tests ====================
+
+func TestConvert_AllItems(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestMultiImageAVIF(t, inputPath)
+
+	if _, err := Convert(inputPath, outputDir, Options{AllItems: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	for _, name := range []string{"test.png", "test_item0.png", "test_item1.png"} {
+		file, err := os.Open(filepath.Join(outputDir, name))
+		if err != nil {
+			t.Fatalf("expected %s to exist, got: %v", name, err)
+		}
+		_, err = png.DecodeConfig(file)
+		file.Close()
+		if err != nil {
+			t.Errorf("expected %s to be a valid PNG, got: %v", name, err)
+		}
+	}
+}
+
+func TestConvert_AllItemsWithSingleItem(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	// The alpha item belongs to the colour item, so there is one image
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIFWithAlpha(t, inputPath)
+
+	if _, err := Convert(inputPath, outputDir, Options{AllItems: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the main output, got %d file(s)", len(entries))
+	}
+}
+
+func TestConvert_WithoutAllItems(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestMultiImageAVIF(t, inputPath)
+
+	if _, err := Convert(inputPath, outputDir, Options{}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "test_item0.png")); !os.IsNotExist(err) {
+		t.Error("expected no item outputs without AllItems")
+	}
+}
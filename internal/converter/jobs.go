@@ -0,0 +1,70 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Job is a file converted to its own output path with its own options, as
+// listed by a batch job spec
+type Job struct {
+	InputPath  string
+	OutputPath string
+	Options    Options
+}
+
+// ConvertJobs converts each job in turn with its options, creating the
+// directories it needs, and aggregates the outcomes in one result, like
+// ConvertMapped
+// Progress is written to the Log of each job that is Verbose; an
+// OverwriteAll answer to the Prompt of a job carries over to the jobs that
+// follow, and a job that fails with OnErrorStop stops the batch
+// The formats of all jobs are checked before any is converted
+// Each job has its own run-scoped state, so Dedupe and FlattenHash only
+// see the file of their job
+func ConvertJobs(ctx context.Context, jobs []Job) (*ConversionResult, error) {
+	result := newFilesResult(len(jobs))
+	for i, job := range jobs {
+		if _, err := lookupEncoder(job.Options.withDefaults().Format); err != nil {
+			return result, fmt.Errorf("job %d: %w", i+1, err)
+		}
+		if err := job.Options.validate(); err != nil {
+			return result, fmt.Errorf("job %d: %w", i+1, err)
+		}
+	}
+
+	overwriteAll := false
+	for i, job := range jobs {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		opts := job.Options.withDefaults()
+		if overwriteAll {
+			opts.Overwrite = true
+		}
+		if opts.Verbose {
+			opts.logf("  %s Converting %s... ", result.progressLabel(i+1, int64(len(jobs))), job.InputPath)
+		}
+
+		// Per-file progress is reported here, not by convertFile
+		fileOpts := opts
+		fileOpts.Verbose = false
+
+		fileResult := FileResult{InputPath: job.InputPath, OutputPath: job.OutputPath}
+		if info, statErr := os.Stat(job.InputPath); statErr == nil {
+			fileResult.InputSize = info.Size()
+		}
+
+		if convertInto(result, source{path: job.InputPath}, fileResult, &fileOpts, opts.Verbose) == OverwriteQuit {
+			return result, ErrAborted
+		}
+		overwriteAll = overwriteAll || (fileOpts.Overwrite && !opts.Overwrite)
+		if err := stopsAfterLast(result, opts); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
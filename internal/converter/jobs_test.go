@@ -0,0 +1,84 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ==================== ConvertJobs Tests ====================
+
+func TestConvertJobs(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "image.avif")
+	createTestAVIF(t, inputPath)
+
+	jobs := []Job{
+		{InputPath: inputPath, OutputPath: filepath.Join(testDir, "out", "image.png")},
+		{InputPath: inputPath, OutputPath: filepath.Join(testDir, "out", "small", "image.jpg"), Options: Options{Format: "jpeg", Quality: 40, Canvas: image.Pt(4, 4)}},
+		{InputPath: filepath.Join(testDir, "missing.avif"), OutputPath: filepath.Join(testDir, "out", "missing.png")},
+	}
+	result, err := ConvertJobs(context.Background(), jobs)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.TotalFiles != 3 || result.Successful != 2 || result.Failed != 1 {
+		t.Errorf("expected 2 of 3 converted and 1 failed, got %+v", result)
+	}
+	for _, job := range jobs[:2] {
+		if _, err := os.Stat(job.OutputPath); err != nil {
+			t.Errorf("expected %s to be written, got: %v", job.OutputPath, err)
+		}
+	}
+	if files := result.Files; len(files) != 3 || files[1].Width != 4 || files[1].Height != 4 {
+		t.Errorf("expected the second job to use its own canvas, got %+v", files)
+	}
+}
+
+func TestConvertJobs_UnknownFormat(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "image.avif")
+	createTestAVIF(t, inputPath)
+
+	jobs := []Job{
+		{InputPath: inputPath, OutputPath: filepath.Join(testDir, "image.png")},
+		{InputPath: inputPath, OutputPath: filepath.Join(testDir, "image.xyz"), Options: Options{Format: "xyz"}},
+	}
+	result, err := ConvertJobs(context.Background(), jobs)
+	if err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+	if result.Successful != 0 {
+		t.Errorf("expected no job to run, got %d converted", result.Successful)
+	}
+	if _, err := os.Stat(jobs[0].OutputPath); !os.IsNotExist(err) {
+		t.Errorf("expected %s not to be written, got: %v", jobs[0].OutputPath, err)
+	}
+}
+
+func TestConvertJobs_Stop(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "image.avif")
+	createTestAVIF(t, inputPath)
+
+	jobs := []Job{
+		{InputPath: filepath.Join(testDir, "missing.avif"), OutputPath: filepath.Join(testDir, "missing.png"), Options: Options{OnError: OnErrorStop}},
+		{InputPath: inputPath, OutputPath: filepath.Join(testDir, "image.png")},
+	}
+	result, err := ConvertJobs(context.Background(), jobs)
+	if !errors.Is(err, ErrStopped) {
+		t.Fatalf("expected ErrStopped, got: %v", err)
+	}
+	if result.Successful != 0 || result.Failed != 1 {
+		t.Errorf("expected the batch to stop after the failure, got %+v", result)
+	}
+}
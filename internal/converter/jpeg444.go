@@ -0,0 +1,385 @@
+package converter
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+)
+
+// Chroma subsampling modes of JPEG outputs
+const (
+	// Chroma420 halves the color resolution in both directions, the
+	// image/jpeg default, which gives the smallest files
+	Chroma420 = "420"
+	// Chroma444 keeps full color resolution, avoiding color fringes around
+	// text and sharp edges at the cost of larger files
+	Chroma444 = "444"
+)
+
+// zigzag maps the position of a coefficient in zig-zag order to its
+// position in natural (row-major) order
+var zigzag = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// baseQuant are the example quantization tables of the JPEG specification
+// (Annex K), for luminance and chrominance, in natural order
+var baseQuant = [2][64]int{
+	{
+		16, 11, 10, 16, 24, 40, 51, 61,
+		12, 12, 14, 19, 26, 58, 60, 55,
+		14, 13, 16, 24, 40, 57, 69, 56,
+		14, 17, 22, 29, 51, 87, 80, 62,
+		18, 22, 37, 56, 68, 109, 103, 77,
+		24, 35, 55, 64, 81, 104, 113, 92,
+		49, 64, 78, 87, 103, 121, 120, 101,
+		72, 92, 95, 98, 112, 100, 103, 99,
+	},
+	{
+		17, 18, 24, 47, 99, 99, 99, 99,
+		18, 21, 26, 66, 99, 99, 99, 99,
+		24, 26, 56, 99, 99, 99, 99, 99,
+		47, 66, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+	},
+}
+
+// huffmanSpec is a Huffman table as stored in a DHT segment: the number of
+// codes of each length from 1 to 16, followed by the symbols in code order
+type huffmanSpec struct {
+	counts [16]byte
+	values []byte
+}
+
+// huffmanSpecs are the standard tables of Annex K: luminance DC and AC,
+// then chrominance DC and AC
+var huffmanSpecs = [4]huffmanSpec{
+	{
+		[16]byte{0, 1, 5, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	{
+		[16]byte{0, 2, 1, 3, 3, 2, 4, 3, 5, 5, 4, 4, 0, 0, 1, 125},
+		[]byte{
+			0x01, 0x02, 0x03, 0x00, 0x04, 0x11, 0x05, 0x12,
+			0x21, 0x31, 0x41, 0x06, 0x13, 0x51, 0x61, 0x07,
+			0x22, 0x71, 0x14, 0x32, 0x81, 0x91, 0xa1, 0x08,
+			0x23, 0x42, 0xb1, 0xc1, 0x15, 0x52, 0xd1, 0xf0,
+			0x24, 0x33, 0x62, 0x72, 0x82, 0x09, 0x0a, 0x16,
+			0x17, 0x18, 0x19, 0x1a, 0x25, 0x26, 0x27, 0x28,
+			0x29, 0x2a, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39,
+			0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49,
+			0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59,
+			0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69,
+			0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79,
+			0x7a, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89,
+			0x8a, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98,
+			0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7,
+			0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6,
+			0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3, 0xc4, 0xc5,
+			0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2, 0xd3, 0xd4,
+			0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda, 0xe1, 0xe2,
+			0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea,
+			0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+	{
+		[16]byte{0, 3, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	{
+		[16]byte{0, 2, 1, 2, 4, 4, 3, 4, 7, 5, 4, 4, 0, 1, 2, 119},
+		[]byte{
+			0x00, 0x01, 0x02, 0x03, 0x11, 0x04, 0x05, 0x21,
+			0x31, 0x06, 0x12, 0x41, 0x51, 0x07, 0x61, 0x71,
+			0x13, 0x22, 0x32, 0x81, 0x08, 0x14, 0x42, 0x91,
+			0xa1, 0xb1, 0xc1, 0x09, 0x23, 0x33, 0x52, 0xf0,
+			0x15, 0x62, 0x72, 0xd1, 0x0a, 0x16, 0x24, 0x34,
+			0xe1, 0x25, 0xf1, 0x17, 0x18, 0x19, 0x1a, 0x26,
+			0x27, 0x28, 0x29, 0x2a, 0x35, 0x36, 0x37, 0x38,
+			0x39, 0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48,
+			0x49, 0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58,
+			0x59, 0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68,
+			0x69, 0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78,
+			0x79, 0x7a, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
+			0x88, 0x89, 0x8a, 0x92, 0x93, 0x94, 0x95, 0x96,
+			0x97, 0x98, 0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5,
+			0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4,
+			0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3,
+			0xc4, 0xc5, 0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2,
+			0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda,
+			0xe2, 0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9,
+			0xea, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+}
+
+// huffmanCode is the code of a symbol and its length in bits
+type huffmanCode struct {
+	code uint32
+	size uint8
+}
+
+// huffmanTable returns the codes of each symbol of spec, built as in
+// Annex C of the JPEG specification
+func huffmanTable(spec huffmanSpec) [256]huffmanCode {
+	var table [256]huffmanCode
+	code, k := uint32(0), 0
+	for length := 1; length <= 16; length++ {
+		for i := 0; i < int(spec.counts[length-1]); i++ {
+			table[spec.values[k]] = huffmanCode{code, uint8(length)}
+			code++
+			k++
+		}
+		code <<= 1
+	}
+	return table
+}
+
+// dctCos holds cos((2x+1)uπ/16) for the forward DCT
+var dctCos = func() [8][8]float64 {
+	var c [8][8]float64
+	for x := 0; x < 8; x++ {
+		for u := 0; u < 8; u++ {
+			c[x][u] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / 16)
+		}
+	}
+	return c
+}()
+
+// maxJPEGSize is the largest width or height a JPEG frame header can hold
+const maxJPEGSize = 65535
+
+// encodeJPEG444 writes img as a baseline JPEG with full-resolution chroma
+// image/jpeg always subsamples chroma to 4:2:0, so this is a small encoder
+// of its own; it uses the same quality scaling and standard tables
+func encodeJPEG444(w io.Writer, img image.Image, quality int) error {
+	// The frame header holds 16-bit sizes, as image/jpeg checks too
+	if width, height := img.Bounds().Dx(), img.Bounds().Dy(); width > maxJPEGSize || height > maxJPEGSize {
+		return fmt.Errorf("image of %dx%d is too large for JPEG (at most %d pixels per side)", width, height, maxJPEGSize)
+	}
+
+	var quant [2][64]int
+	for t := range quant {
+		for i, base := range baseQuant[t] {
+			quant[t][i] = scaleQuant(base, quality)
+		}
+	}
+
+	var codes [4][256]huffmanCode
+	for i, spec := range huffmanSpecs {
+		codes[i] = huffmanTable(spec)
+	}
+
+	bw := bufio.NewWriter(w)
+	e := &jpegWriter{w: bw}
+	e.writeHeaders(img.Bounds(), quant)
+
+	// Blocks of 8x8 pixels, each coded as Y, Cb and Cr in turn
+	bounds := img.Bounds()
+	var dc [3]int
+	var blocks [3][64]float64
+	for by := bounds.Min.Y; by < bounds.Max.Y; by += 8 {
+		for bx := bounds.Min.X; bx < bounds.Max.X; bx += 8 {
+			for i := 0; i < 64; i++ {
+				// Edge blocks repeat the last row and column of the image
+				x := min(bx+i%8, bounds.Max.X-1)
+				y := min(by+i/8, bounds.Max.Y-1)
+				r, g, b, _ := img.At(x, y).RGBA()
+				yy, cb, cr := color.RGBToYCbCr(uint8(r>>8), uint8(g>>8), uint8(b>>8))
+				blocks[0][i] = float64(yy) - 128
+				blocks[1][i] = float64(cb) - 128
+				blocks[2][i] = float64(cr) - 128
+			}
+			for c := 0; c < 3; c++ {
+				t := min(c, 1)
+				dc[c] = e.writeBlock(&blocks[c], &quant[t], dc[c], &codes[2*t], &codes[2*t+1])
+			}
+		}
+	}
+
+	e.flushBits()
+	e.write([]byte{0xFF, 0xD9})
+	if e.err != nil {
+		return e.err
+	}
+	return bw.Flush()
+}
+
+// scaleQuant scales a base quantization value for quality, as image/jpeg does
+func scaleQuant(base, quality int) int {
+	quality = max(1, min(quality, 100))
+	scale := 200 - quality*2
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+	return max(1, min((base*scale+50)/100, 255))
+}
+
+// jpegWriter writes JPEG segments and entropy-coded data, keeping the first
+// error
+type jpegWriter struct {
+	w     *bufio.Writer
+	err   error
+	bits  uint32
+	nBits uint8
+}
+
+func (e *jpegWriter) write(p []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(p)
+	}
+}
+
+// writeSegment writes a marker segment with its length
+func (e *jpegWriter) writeSegment(marker byte, payload []byte) {
+	n := len(payload) + 2
+	e.write([]byte{0xFF, marker, byte(n >> 8), byte(n)})
+	e.write(payload)
+}
+
+// writeHeaders writes everything before the entropy-coded data
+func (e *jpegWriter) writeHeaders(bounds image.Rectangle, quant [2][64]int) {
+	e.write([]byte{0xFF, 0xD8})
+
+	// DQT: both tables in zig-zag order
+	dqt := make([]byte, 0, 130)
+	for t := range quant {
+		dqt = append(dqt, byte(t))
+		for _, natural := range zigzag {
+			dqt = append(dqt, byte(quant[t][natural]))
+		}
+	}
+	e.writeSegment(0xDB, dqt)
+
+	// SOF0: 8-bit samples, three components without subsampling (0x11)
+	w, h := bounds.Dx(), bounds.Dy()
+	e.writeSegment(0xC0, []byte{
+		8, byte(h >> 8), byte(h), byte(w >> 8), byte(w), 3,
+		1, 0x11, 0,
+		2, 0x11, 1,
+		3, 0x11, 1,
+	})
+
+	// DHT: class 0 is DC and class 1 is AC, table 0 for luma and 1 for chroma
+	var dht []byte
+	for i, spec := range huffmanSpecs {
+		dht = append(dht, byte((i%2)<<4|i/2))
+		dht = append(dht, spec.counts[:]...)
+		dht = append(dht, spec.values...)
+	}
+	e.writeSegment(0xC4, dht)
+
+	// SOS: all three components in one interleaved scan
+	e.writeSegment(0xDA, []byte{3, 1, 0x00, 2, 0x11, 3, 0x11, 0, 63, 0})
+}
+
+// writeBlock transforms, quantizes and codes one 8x8 block, returning its
+// DC coefficient, which the next block of the component is coded against
+func (e *jpegWriter) writeBlock(block *[64]float64, quant *[64]int, prevDC int, dcCodes, acCodes *[256]huffmanCode) int {
+	var coef [64]int
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			var sum float64
+			for y := 0; y < 8; y++ {
+				for x := 0; x < 8; x++ {
+					sum += block[y*8+x] * dctCos[x][u] * dctCos[y][v]
+				}
+			}
+			cu, cv := 1.0, 1.0
+			if u == 0 {
+				cu = math.Sqrt2 / 2
+			}
+			if v == 0 {
+				cv = math.Sqrt2 / 2
+			}
+			i := v*8 + u
+			coef[i] = int(math.Round(sum * cu * cv / 4 / float64(quant[i])))
+		}
+	}
+
+	dc := coef[0]
+	e.emitValue(dc-prevDC, 0, dcCodes)
+
+	run := 0
+	for k := 1; k < 64; k++ {
+		ac := coef[zigzag[k]]
+		if ac == 0 {
+			run++
+			continue
+		}
+		for run > 15 {
+			e.emitCode(acCodes[0xF0])
+			run -= 16
+		}
+		e.emitValue(ac, run, acCodes)
+		run = 0
+	}
+	if run > 0 {
+		e.emitCode(acCodes[0x00])
+	}
+
+	return dc
+}
+
+// emitValue codes a coefficient as the symbol (run, size) followed by size
+// bits of the value, with negative values stored as v-1 in ones' complement
+func (e *jpegWriter) emitValue(v, run int, codes *[256]huffmanCode) {
+	magnitude, bits := v, v
+	if v < 0 {
+		magnitude, bits = -v, v-1
+	}
+	size := 0
+	for magnitude > 0 {
+		size++
+		magnitude >>= 1
+	}
+
+	e.emitCode(codes[run<<4|size])
+	if size > 0 {
+		e.emitBits(uint32(bits)&(1<<size-1), uint8(size))
+	}
+}
+
+func (e *jpegWriter) emitCode(c huffmanCode) {
+	e.emitBits(c.code, c.size)
+}
+
+// emitBits appends the low n bits of bits to the entropy-coded data,
+// stuffing a zero byte after every 0xFF
+func (e *jpegWriter) emitBits(bits uint32, n uint8) {
+	e.bits = e.bits<<n | bits
+	e.nBits += n
+	for e.nBits >= 8 {
+		b := byte(e.bits >> (e.nBits - 8))
+		e.write([]byte{b})
+		if b == 0xFF {
+			e.write([]byte{0})
+		}
+		e.nBits -= 8
+	}
+	e.bits &= 1<<e.nBits - 1
+}
+
+// flushBits pads the last byte of entropy-coded data with one bits
+func (e *jpegWriter) flushBits() {
+	if e.nBits > 0 {
+		pad := 8 - e.nBits
+		e.emitBits(1<<pad-1, pad)
+	}
+}
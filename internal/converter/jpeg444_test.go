@@ -0,0 +1,146 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ==================== JPEG 4:4:4 Tests ====================
+
+// jpegSubsampling decodes a JPEG and returns its chroma subsampling
+func jpegSubsampling(t *testing.T, data []byte) image.YCbCrSubsampleRatio {
+	t.Helper()
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("expected a valid JPEG, got: %v", err)
+	}
+	ycbcr, ok := img.(*image.YCbCr)
+	if !ok {
+		t.Fatalf("expected a YCbCr JPEG, got %T", img)
+	}
+	return ycbcr.SubsampleRatio
+}
+
+func TestEncodeJPEG_Chroma(t *testing.T) {
+	img := noiseImage(37, 255)
+
+	tests := []struct {
+		chroma string
+		want   image.YCbCrSubsampleRatio
+	}{
+		{"", image.YCbCrSubsampleRatio420},
+		{Chroma420, image.YCbCrSubsampleRatio420},
+		{Chroma444, image.YCbCrSubsampleRatio444},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := encodeJPEG(&buf, img, EncodeOptions{Quality: 90, Chroma: tt.chroma}); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if got := jpegSubsampling(t, buf.Bytes()); got != tt.want {
+			t.Errorf("chroma %q: expected subsampling %v, got %v", tt.chroma, tt.want, got)
+		}
+	}
+}
+
+func TestEncodeJPEG444_KeepsColorDetail(t *testing.T) {
+	// One-pixel red and blue stripes average out under 4:2:0
+	img := image.NewRGBA(image.Rect(2, 1, 42, 27))
+	for y := 1; y < 27; y++ {
+		for x := 2; x < 42; x++ {
+			c := color.RGBA{255, 0, 0, 255}
+			if x%2 == 1 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := encodeJPEG444(&buf, img, 100); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	decoded, err := jpeg.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("expected a valid JPEG, got: %v", err)
+	}
+	if got := decoded.Bounds().Size(); got != img.Bounds().Size() {
+		t.Fatalf("expected size %v, got %v", img.Bounds().Size(), got)
+	}
+
+	for y := 0; y < 26; y++ {
+		for x := 0; x < 40; x++ {
+			want := img.RGBAAt(x+2, y+1)
+			r, _, b, _ := decoded.At(x, y).RGBA()
+			if absDiff(uint8(r>>8), want.R) > 16 || absDiff(uint8(b>>8), want.B) > 16 {
+				t.Fatalf("pixel (%d, %d): expected %v, got r=%d b=%d", x, y, want, r>>8, b>>8)
+			}
+		}
+	}
+}
+
+func TestEncodeJPEG444_QualityAffectsSize(t *testing.T) {
+	img := noiseImage(64, 255)
+
+	var low, high bytes.Buffer
+	if err := encodeJPEG444(&low, img, 20); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := encodeJPEG444(&high, img, 95); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if low.Len() >= high.Len() {
+		t.Errorf("expected quality 20 (%d bytes) to be smaller than quality 95 (%d bytes)", low.Len(), high.Len())
+	}
+}
+
+func TestEncodeJPEG444_TooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	if err := encodeJPEG444(&buf, image.NewGray(image.Rect(0, 0, 65536, 1)), 90); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("expected a width above 65535 to fail, got: %v", err)
+	}
+	if err := encodeJPEG444(&buf, image.NewGray(image.Rect(0, 0, 1, 65536)), 90); err == nil {
+		t.Error("expected a height above 65535 to fail")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing written, got %d bytes", buf.Len())
+	}
+	if err := encodeJPEG444(&buf, image.NewGray(image.Rect(0, 0, 65535, 1)), 90); err != nil {
+		t.Errorf("expected a width of 65535 to be encoded, got: %v", err)
+	}
+}
+
+func TestConvert_Chroma444(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	if _, err := Convert(inputPath, outputDir, Options{Format: "jpeg", Chroma: Chroma444}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "test.jpeg"))
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if got := jpegSubsampling(t, data); got != image.YCbCrSubsampleRatio444 {
+		t.Errorf("expected 4:4:4 subsampling, got %v", got)
+	}
+}
+
+// absDiff returns the distance between two samples
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
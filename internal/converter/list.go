@@ -0,0 +1,159 @@
+package converter
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ConvertList converts the files listed in list, one path per line, into
+// outputDir like ConvertFiles, as the lines arrive: the first file is
+// converted as soon as its line is read, so a list written by a slow
+// program, such as find on a large tree, is converted while it is written
+// Blank lines are ignored, and so are listed files whose names are not
+// inputs, as in a directory scan; the other lines are used as they are, so
+// paths may hold spaces
+// It records into result like ConvertDirectoryInto, and stops before the
+// next file once ctx is cancelled, returning ctx.Err()
+func ConvertList(ctx context.Context, list io.Reader, outputDir string, opts Options, result *ConversionResult) error {
+	if result.Errors == nil {
+		result.Errors = []FileError{}
+	}
+	if result.Files == nil {
+		result.Files = []FileResult{}
+	}
+	if result.SkippedReasons == nil {
+		result.SkippedReasons = map[string]int{}
+	}
+	if result.FilteredReasons == nil {
+		result.FilteredReasons = map[string]int{}
+	}
+
+	opts = opts.withDefaults()
+	opts.outputBase = outputDir
+	if _, err := lookupEncoder(opts.Format); err != nil {
+		return err
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	if err := checkOutputDir(opts.Output, outputDir); err != nil {
+		return err
+	}
+
+	if opts.TrackResources {
+		stopTracking := trackResources()
+		defer func() { result.Resources = stopTracking() }()
+	}
+
+	// The list is read apart from the conversion, like a directory scan, and
+	// files past the limit are counted apart and copied into result at the end
+	readCtx, stopReading := context.WithCancel(ctx)
+	defer stopReading()
+	paths := make(chan string, scanBufferSize)
+	readDone := make(chan error, 1)
+	limited := 0
+	go func() {
+		defer close(paths)
+		scanner := bufio.NewScanner(list)
+		found := 0
+		for scanner.Scan() {
+			path := strings.TrimSuffix(scanner.Text(), "\r")
+			if strings.TrimSpace(path) == "" || !isInputName(filepath.Base(path), opts) {
+				continue
+			}
+			if opts.Limit > 0 && found >= opts.Limit {
+				limited++
+				continue
+			}
+			found++
+
+			result.total.Add(1)
+			select {
+			case paths <- path:
+			case <-readCtx.Done():
+				result.total.Add(-1)
+				readDone <- readCtx.Err()
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			readDone <- fmt.Errorf("failed to read file list: %w", err)
+			return
+		}
+		readDone <- nil
+	}()
+	defer func() { result.TotalFiles = int(result.total.Load()) }()
+
+	// Per-file progress is reported here, not by convertFile
+	fileOpts := opts
+	fileOpts.Verbose = false
+
+	// Template dates taken from the current time are fixed for the whole run
+	now := time.Now()
+	// Listed paths are relative to the working directory, which plays the
+	// part of the input directory for FlattenSeparator
+	workDir, _ := os.Getwd()
+
+	pool := newWorkerPool(result, opts, &fileOpts)
+	defer pool.wait()
+
+	i := 0
+	for path := range paths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// The total is the number of files read so far while the list arrives
+		i++
+		line := ""
+		if opts.Verbose {
+			line = fmt.Sprintf("  %s Converting %s... ", result.progressLabel(i, result.total.Load()), path)
+		}
+
+		fileResult := FileResult{
+			InputPath:  path,
+			OutputPath: listOutputPath(workDir, path, templatedOutputDir(outputDir, path, now, opts), opts),
+		}
+		if info, statErr := os.Stat(path); statErr == nil {
+			fileResult.InputSize = info.Size()
+		}
+
+		if err := pool.convert(source{path: path}, fileResult, line); err != nil {
+			return err
+		}
+	}
+
+	if err := pool.wait(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := <-readDone; err != nil {
+		return err
+	}
+	if limited > 0 {
+		result.FilteredReasons[FilterReasonLimit] += limited
+	}
+	return nil
+}
+
+// listOutputPath returns the output path of a listed file, named like a file
+// of a recursive scan of workDir, so FlattenSeparator encodes its directories
+// Files outside workDir are named after the file alone
+func listOutputPath(workDir, path, outputDir string, opts Options) string {
+	absPath, err := filepath.Abs(path)
+	if err != nil || workDir == "" {
+		return outputPathFor(path, outputDir, opts.Format)
+	}
+	if rel, err := filepath.Rel(workDir, absPath); err != nil || !filepath.IsLocal(rel) {
+		return outputPathFor(path, outputDir, opts.Format)
+	}
+	return directoryOutputPath(workDir, absPath, outputDir, opts)
+}
@@ -0,0 +1,149 @@
+package converter
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ==================== ConvertList Tests ====================
+
+func TestConvertList(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	outputDir := filepath.Join(testDir, "output")
+	first := filepath.Join(testDir, "my photos", "first.avif")
+	second := filepath.Join(testDir, "second.avif")
+	os.MkdirAll(filepath.Dir(first), 0755)
+	createTestAVIF(t, first)
+	createTestAVIF(t, second)
+	os.WriteFile(filepath.Join(testDir, "notes.txt"), []byte("notes"), 0644)
+
+	list := strings.Join([]string{first, "", second + "\r", filepath.Join(testDir, "notes.txt"), "  "}, "\n")
+	result := &ConversionResult{}
+	if err := ConvertList(context.Background(), strings.NewReader(list), outputDir, Options{}, result); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.TotalFiles != 2 || result.Successful != 2 {
+		t.Errorf("expected 2 files converted, got %d of %d", result.Successful, result.TotalFiles)
+	}
+	for _, name := range []string{"first.png", "second.png"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+			t.Errorf("expected %s to exist, got: %v", name, err)
+		}
+	}
+
+	// Missing files fail like any other unreadable input
+	result = &ConversionResult{}
+	missing := filepath.Join(testDir, "missing.avif")
+	if err := ConvertList(context.Background(), strings.NewReader(missing+"\n"), outputDir, Options{}, result); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Failed != 1 || len(result.Errors) != 1 || result.Errors[0].FilePath != missing {
+		t.Errorf("expected the missing file to fail, got: %+v", result.Errors)
+	}
+}
+
+func TestConvertList_SharedName(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	for _, dir := range []string{"a", "b"} {
+		os.MkdirAll(filepath.Join(testDir, dir), 0755)
+		createTestAVIF(t, filepath.Join(testDir, dir, "x.avif"))
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := os.Chdir(testDir); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	// The second file named like the first is skipped, as in a flat scan
+	list := strings.Join([]string{"./a/x.avif", "./b/x.avif"}, "\n")
+	result := &ConversionResult{}
+	if err := ConvertList(context.Background(), strings.NewReader(list), "out", Options{}, result); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Successful != 1 || result.SkippedReasons[SkipReasonExists] != 1 {
+		t.Errorf("expected 1 converted and 1 skipped, got %d and %v", result.Successful, result.SkippedReasons)
+	}
+
+	// FlattenSeparator names them after their directories instead
+	result = &ConversionResult{}
+	if err := ConvertList(context.Background(), strings.NewReader(list), "flat", Options{FlattenSeparator: "_"}, result); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Successful != 2 {
+		t.Errorf("expected 2 files converted, got %d", result.Successful)
+	}
+	for _, name := range []string{"a_x.png", "b_x.png"} {
+		if _, err := os.Stat(filepath.Join(testDir, "flat", name)); err != nil {
+			t.Errorf("expected %s to exist, got: %v", name, err)
+		}
+	}
+}
+
+func TestConvertList_Streaming(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	outputDir := filepath.Join(testDir, "output")
+	for _, name := range []string{"a.avif", "b.avif"} {
+		createTestAVIF(t, filepath.Join(testDir, name))
+	}
+
+	// The first file is converted while the list is still open
+	r, w := io.Pipe()
+	done := make(chan error, 1)
+	result := &ConversionResult{}
+	go func() { done <- ConvertList(context.Background(), r, outputDir, Options{}, result) }()
+
+	io.WriteString(w, filepath.Join(testDir, "a.avif")+"\n")
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err := os.Stat(filepath.Join(outputDir, "a.png")); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the first file to be converted before the list ends")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	io.WriteString(w, filepath.Join(testDir, "b.avif")+"\n")
+	w.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.TotalFiles != 2 || result.Successful != 2 {
+		t.Errorf("expected 2 files converted, got %d of %d", result.Successful, result.TotalFiles)
+	}
+}
+
+func TestConvertList_Limit(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	var lines []string
+	for _, name := range []string{"a.avif", "b.avif", "c.avif"} {
+		createTestAVIF(t, filepath.Join(testDir, name))
+		lines = append(lines, filepath.Join(testDir, name))
+	}
+
+	result := &ConversionResult{}
+	err := ConvertList(context.Background(), strings.NewReader(strings.Join(lines, "\n")), filepath.Join(testDir, "output"), Options{Limit: 2}, result)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Successful != 2 || result.FilteredReasons[FilterReasonLimit] != 1 {
+		t.Errorf("expected 2 converted and 1 over the limit, got %d and %v", result.Successful, result.FilteredReasons)
+	}
+}
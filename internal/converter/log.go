@@ -0,0 +1,27 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// logMu serializes the writes of every conversion to its Options.Log, so
+// conversions that share a writer never garble each other's lines
+var logMu sync.Mutex
+
+// logWriter returns where opts sends its output, os.Stdout by default
+func (opts Options) logWriter() io.Writer {
+	if opts.Log != nil {
+		return opts.Log
+	}
+	return os.Stdout
+}
+
+// logf formats a message to the output of opts in a single write
+func (opts Options) logf(format string, args ...any) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	fmt.Fprintf(opts.logWriter(), format, args...)
+}
@@ -0,0 +1,77 @@
+package converter
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// ==================== Log Tests ====================
+
+func TestConvertDirectory_Log(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	os.MkdirAll(inputDir, 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "test.avif"))
+
+	var log bytes.Buffer
+	if _, err := ConvertDirectory(inputDir, filepath.Join(testDir, "output"), Options{Verbose: true, Log: &log}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := log.String(); !strings.Contains(got, "Converting test.avif... ✅") {
+		t.Errorf("expected the progress in the log, got: %q", got)
+	}
+}
+
+func TestConvert_SharedLog(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	var log syncBuffer
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		inputPath := filepath.Join(testDir, "test"+string(rune('a'+i))+".avif")
+		createTestAVIF(t, inputPath)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := Convert(inputPath, filepath.Join(testDir, "output"), Options{Verbose: true, Log: &log}); err != nil {
+				t.Errorf("expected no error, got: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got := log.String()
+	if strings.Count(got, "📂 Reading: ") != 4 || strings.Count(got, "✅ Saved: ") != 4 {
+		t.Errorf("expected every conversion in the log, got: %q", got)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(got, "\n"), "\n") {
+		if !strings.HasPrefix(line, "📂 Reading: ") && !strings.HasPrefix(line, "✅ Saved: ") && !strings.HasPrefix(line, "⚠️  Looks blank: ") {
+			t.Errorf("expected whole lines, got: %q", line)
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer that may be read while it is written to
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
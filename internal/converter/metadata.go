@@ -0,0 +1,227 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrStripUnsupported is returned when metadata is to be stripped from an
+// output format whose structure is unknown, such as a custom format
+var ErrStripUnsupported = errors.New("cannot strip metadata from this format")
+
+// errMalformed is returned for encoded outputs that cannot be walked
+var errMalformed = errors.New("malformed output")
+
+// pngKeptChunks are the PNG chunks kept when stripping metadata: the
+// critical chunks, and tRNS, without which transparency would be lost
+var pngKeptChunks = map[string]bool{"IHDR": true, "PLTE": true, "tRNS": true, "IDAT": true, "IEND": true}
+
+// gifLoopApplications are the GIF application extensions kept when stripping
+// metadata, since they hold the loop count of animations
+var gifLoopApplications = [][]byte{[]byte("NETSCAPE2.0"), []byte("ANIMEXTS1.0")}
+
+// JPEG markers
+const (
+	jpegAPP0  = 0xe0
+	jpegAPP14 = 0xee
+	jpegAPP15 = 0xef
+	jpegRST0  = 0xd0
+	jpegRST7  = 0xd7
+	jpegSOS   = 0xda
+	jpegEOI   = 0xd9
+	jpegCOM   = 0xfe
+)
+
+// CanStripMetadata reports whether stripMetadata supports format
+// PPM and PAM outputs never carry metadata
+func CanStripMetadata(format string) bool {
+	return format == "png" || format == "jpeg" || format == gifFormat || format == ppmFormat || format == pamFormat
+}
+
+// stripMetadata removes everything but the image itself from data, an
+// encoded output of format: ancillary PNG chunks (text, EXIF, ICC profiles,
+// timestamps, ...), JPEG APPn and comment segments, and GIF comments and
+// application extensions other than the loop count
+// The built-in encoders write no metadata, so this mostly guards against
+// custom encoders and later changes; the pixels are never re-encoded
+func stripMetadata(format string, data []byte) ([]byte, error) {
+	if !CanStripMetadata(format) {
+		return nil, fmt.Errorf("%w: %s", ErrStripUnsupported, format)
+	}
+
+	var stripped []byte
+	var err error
+	switch format {
+	case "png":
+		stripped, err = stripPNG(data)
+	case "jpeg":
+		stripped, err = stripJPEG(data)
+	case ppmFormat, pamFormat:
+		return data, nil
+	default:
+		stripped, err = stripGIF(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to strip metadata from %s output: %w", format, err)
+	}
+	return stripped, nil
+}
+
+// stripPNG keeps the signature and the chunks in pngKeptChunks
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errMalformed
+	}
+
+	out := append([]byte(nil), pngSignature...)
+	for rest := data[len(pngSignature):]; len(rest) > 0; {
+		if len(rest) < 12 {
+			return nil, errMalformed
+		}
+		size := int(binary.BigEndian.Uint32(rest))
+		if size > len(rest)-12 {
+			return nil, errMalformed
+		}
+
+		// A chunk is its length, type, data and CRC, copied as is
+		chunk := rest[:12+size]
+		if pngKeptChunks[string(chunk[4:8])] {
+			out = append(out, chunk...)
+		}
+		rest = rest[len(chunk):]
+	}
+	return out, nil
+}
+
+// stripJPEG drops the APPn and COM segments of a JPEG stream, except APP14,
+// which tells decoders how to convert the colors of Adobe JPEGs
+func stripJPEG(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return nil, errMalformed
+	}
+
+	out := append([]byte(nil), data[:2]...)
+	for i := 2; i < len(data); {
+		if data[i] != 0xff || i+1 >= len(data) {
+			return nil, errMalformed
+		}
+		marker := data[i+1]
+		if marker == 0xff {
+			// Fill bytes may precede a marker
+			i++
+			continue
+		}
+		if marker == jpegEOI {
+			return append(out, data[i:i+2]...), nil
+		}
+		if i+4 > len(data) {
+			return nil, errMalformed
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) {
+			return nil, errMalformed
+		}
+
+		// Entropy-coded data follows a scan header up to the next marker
+		// that is neither a stuffed 0xff nor a restart marker
+		if marker == jpegSOS {
+			for end < len(data)-1 {
+				if data[end] == 0xff && data[end+1] != 0 && (data[end+1] < jpegRST0 || data[end+1] > jpegRST7) {
+					break
+				}
+				end++
+			}
+		}
+
+		isMetadata := (marker >= jpegAPP0 && marker <= jpegAPP15 && marker != jpegAPP14) || marker == jpegCOM
+		if !isMetadata {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	return nil, errMalformed
+}
+
+// stripGIF drops the comment extensions of a GIF stream and its application
+// extensions other than gifLoopApplications
+func stripGIF(data []byte) ([]byte, error) {
+	// Header and logical screen descriptor, then the global color table
+	if len(data) < 13 || !bytes.HasPrefix(data, []byte("GIF8")) {
+		return nil, errMalformed
+	}
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+	if i > len(data) {
+		return nil, errMalformed
+	}
+	out := append([]byte(nil), data[:i]...)
+
+	for i < len(data) {
+		start := i
+		keep := true
+		switch data[i] {
+		case 0x3b: // Trailer
+			return append(out, data[i]), nil
+		case 0x21: // Extension: label, then sub-blocks
+			if i+2 > len(data) {
+				return nil, errMalformed
+			}
+			switch data[i+1] {
+			case 0xfe:
+				keep = false
+			case 0xff:
+				keep = isLoopApplication(data[i+2:])
+			}
+			i += 2
+		case 0x2c: // Image descriptor, local color table and LZW code size
+			if i+10 > len(data) {
+				return nil, errMalformed
+			}
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			i++
+		default:
+			return nil, errMalformed
+		}
+
+		// Sub-blocks end with an empty one
+		for {
+			if i >= len(data) {
+				return nil, errMalformed
+			}
+			size := int(data[i])
+			i += 1 + size
+			if size == 0 {
+				break
+			}
+		}
+		if i > len(data) {
+			return nil, errMalformed
+		}
+		if keep {
+			out = append(out, data[start:i]...)
+		}
+	}
+	return nil, errMalformed
+}
+
+// isLoopApplication reports whether the sub-blocks of an application
+// extension start with one of gifLoopApplications
+func isLoopApplication(blocks []byte) bool {
+	if len(blocks) < 12 || blocks[0] != 11 {
+		return false
+	}
+	for _, id := range gifLoopApplications {
+		if bytes.Equal(blocks[1:12], id) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,176 @@
+package converter
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ==================== Strip Metadata Tests ====================
+
+// pngChunkTypes returns the chunk types of a PNG stream, in order
+func pngChunkTypes(t *testing.T, data []byte) []string {
+	t.Helper()
+	var types []string
+	for rest := data[len(pngSignature):]; len(rest) >= 12; {
+		size := int(rest[0])<<24 | int(rest[1])<<16 | int(rest[2])<<8 | int(rest[3])
+		types = append(types, string(rest[4:8]))
+		rest = rest[12+size:]
+	}
+	return types
+}
+
+func TestStripMetadata_PNG(t *testing.T) {
+	img := solidImage(4, 4, color.NRGBA{10, 20, 30, 255})
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// Insert text, EXIF and ICC chunks after IHDR (8 + 25 bytes)
+	var tagged bytes.Buffer
+	tagged.Write(encoded.Bytes()[:33])
+	for _, chunk := range []string{"tEXt", "eXIf", "iCCP"} {
+		if err := writeChunk(&tagged, chunk, []byte("GPS 52.37N 4.89E")); err != nil {
+			t.Fatalf("failed to write chunk: %v", err)
+		}
+	}
+	tagged.Write(encoded.Bytes()[33:])
+
+	stripped, err := stripMetadata("png", tagged.Bytes())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !bytes.Equal(stripped, encoded.Bytes()) {
+		t.Errorf("expected only the image chunks to remain, got %v", pngChunkTypes(t, stripped))
+	}
+}
+
+func TestStripMetadata_PNGKeepsTransparency(t *testing.T) {
+	paletted := image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{color.Transparent, color.Black})
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, paletted); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	stripped, err := stripMetadata("png", encoded.Bytes())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	decoded, err := png.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatalf("expected a valid PNG, got: %v", err)
+	}
+	if _, _, _, a := decoded.At(0, 0).RGBA(); a != 0 {
+		t.Errorf("expected the pixel to stay transparent, got alpha %d", a)
+	}
+}
+
+func TestStripMetadata_JPEG(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, noiseImage(16, 255), nil); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// Insert an EXIF segment and a comment after SOI
+	var tagged bytes.Buffer
+	tagged.Write(encoded.Bytes()[:2])
+	exif := append([]byte("Exif\x00\x00"), bytes.Repeat([]byte{0xff, 0x00}, 8)...)
+	for _, segment := range []struct {
+		marker  byte
+		payload []byte
+	}{{0xe1, exif}, {jpegCOM, []byte("shot on a phone")}} {
+		tagged.Write([]byte{0xff, segment.marker, 0, byte(len(segment.payload) + 2)})
+		tagged.Write(segment.payload)
+	}
+	tagged.Write(encoded.Bytes()[2:])
+
+	stripped, err := stripMetadata("jpeg", tagged.Bytes())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !bytes.Equal(stripped, encoded.Bytes()) {
+		t.Errorf("expected the EXIF and comment segments to be removed (%d bytes, want %d)", len(stripped), encoded.Len())
+	}
+}
+
+func TestStripMetadata_GIF(t *testing.T) {
+	frames := []image.Image{
+		solidImage(4, 4, color.NRGBA{255, 0, 0, 255}),
+		solidImage(4, 4, color.NRGBA{0, 0, 255, 255}),
+	}
+	var encoded bytes.Buffer
+	if err := encodeAnimatedGIF(&encoded, frames, []float64{0.1, 0.1}, false); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// Append a comment and an XMP-like application extension before the trailer
+	data := encoded.Bytes()
+	var tagged bytes.Buffer
+	tagged.Write(data[:len(data)-1])
+	tagged.Write([]byte{0x21, 0xfe, 5, 'h', 'e', 'l', 'l', 'o', 0})
+	tagged.Write(append([]byte{0x21, 0xff, 11}, []byte("XMP DataXMP")...))
+	tagged.Write([]byte{3, 'x', 'm', 'p', 0})
+	tagged.WriteByte(0x3b)
+
+	stripped, err := stripMetadata(gifFormat, tagged.Bytes())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !bytes.Equal(stripped, data) {
+		t.Errorf("expected the comment and application extension to be removed (%d bytes, want %d)", len(stripped), len(data))
+	}
+
+	// The loop count survives
+	anim, err := gif.DecodeAll(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatalf("expected a valid GIF, got: %v", err)
+	}
+	if len(anim.Image) != 2 || anim.LoopCount != 0 {
+		t.Errorf("expected 2 looping frames, got %d frames with loop count %d", len(anim.Image), anim.LoopCount)
+	}
+}
+
+func TestStripMetadata_Malformed(t *testing.T) {
+	for _, format := range []string{"png", "jpeg", gifFormat} {
+		if _, err := stripMetadata(format, []byte("not an image")); !errors.Is(err, errMalformed) {
+			t.Errorf("%s: expected errMalformed, got: %v", format, err)
+		}
+	}
+}
+
+func TestConvert_StripMetadata(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	// Custom formats cannot be walked, so nothing is guaranteed for them
+	RegisterEncoder("custom-test", func(w io.Writer, img image.Image, opts EncodeOptions) error {
+		return png.Encode(w, img)
+	})
+	if _, err := Convert(inputPath, outputDir, Options{Format: "custom-test", StripMetadata: true}); !errors.Is(err, ErrStripUnsupported) {
+		t.Fatalf("expected ErrStripUnsupported for a custom format, got: %v", err)
+	}
+
+	if _, err := Convert(inputPath, outputDir, Options{Format: "jpeg", StripMetadata: true, Verify: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(outputDir, "test.jpeg"))
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if clean, err := stripJPEG(data); err != nil || !bytes.Equal(clean, data) {
+		t.Errorf("expected an output without metadata, got: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package converter
+
+import (
+	"avif2png/internal/isobmff"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gen2brain/avif"
+)
+
+// metadataFormat is the extension of the outputs of Options.MetaOnly
+const metadataFormat = "json"
+
+// ImageMetadata describes an AVIF input without its pixels, as written by
+// Options.MetaOnly
+type ImageMetadata struct {
+	Input  string `json:"input"`
+	Format string `json:"format"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	// BitDepth is that of the coded image, 8, 10 or 12
+	BitDepth   int  `json:"bit_depth"`
+	HasAlpha   bool `json:"has_alpha"`
+	ICCProfile bool `json:"icc_profile"`
+	// Exif holds the fields of exifTags found in the Exif item of the image,
+	// such as "Make" and "DateTimeOriginal"; unreadable Exif data is left out
+	Exif map[string]string `json:"exif,omitempty"`
+}
+
+// readMetadata describes the primary image of an AVIF file from its
+// container, without decoding it
+// Images without an ispe property are sized by the decoder, which only
+// reads the headers of the coded image
+func readMetadata(src source, data []byte) (ImageMetadata, error) {
+	meta := ImageMetadata{Input: src.path, Format: avifFormat}
+	if len(data) < minAVIFSize {
+		return meta, ErrEmptyFile
+	}
+	f, err := isobmff.Parse(data)
+	if err != nil {
+		return meta, fmt.Errorf("failed to read AVIF container: %w", err)
+	}
+	primary := f.Item(f.PrimaryItemID)
+	if primary == nil || !primary.IsImage() {
+		return meta, explainDecodeError(src, fmt.Errorf("%w: no primary image", isobmff.ErrInvalid))
+	}
+
+	var ok bool
+	if meta.Width, meta.Height, ok = primary.ImageSize(); !ok {
+		config, err := avif.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return meta, fmt.Errorf("failed to decode: %w", err)
+		}
+		meta.Width, meta.Height = config.Width, config.Height
+	}
+
+	// Grids are made of AV1 tiles, which carry the codec configuration and,
+	// when the grid has none, the color properties
+	coded := primary
+	for _, ref := range f.References {
+		if ref.Type == "dimg" && ref.FromID == primary.ID && len(ref.ToIDs) > 0 {
+			if tile := f.Item(ref.ToIDs[0]); tile != nil {
+				coded = tile
+			}
+			break
+		}
+	}
+	if config, ok := coded.AV1Config(); ok {
+		meta.BitDepth = config.BitDepth
+	}
+	_, primaryICC := primary.ICCProfile()
+	_, codedICC := coded.ICCProfile()
+	meta.ICCProfile = primaryICC || codedICC
+	meta.HasAlpha, _ = alphaSignal(data)
+
+	// Exif items describe the image they reference with cdsc, behind a
+	// 32-bit offset to the TIFF header
+	for _, id := range f.ReferencesTo("cdsc", primary.ID) {
+		if item := f.Item(id); item == nil || item.Type != "Exif" {
+			continue
+		}
+		payload, err := f.ItemData(data, id)
+		if err != nil || len(payload) < 4 {
+			continue
+		}
+		start := 4 + uint64(binary.BigEndian.Uint32(payload))
+		if start > uint64(len(payload)) {
+			continue
+		}
+		if fields, err := parseExif(payload[start:]); err == nil && len(fields) > 0 {
+			meta.Exif = fields
+			break
+		}
+	}
+
+	return meta, nil
+}
+
+// convertMetadata writes the ImageMetadata of src to outputPath as JSON
+// instead of converting it, for Options.MetaOnly, following convertFile for
+// existing outputs and tee directories
+func convertMetadata(ctx context.Context, src source, outputPath string, opts Options, c conversion) (conversion, error) {
+	data, err := src.read()
+	if err != nil {
+		return c, err
+	}
+	modTime := src.modTime
+	if src.data == nil {
+		if info, err := os.Stat(src.path); err == nil {
+			modTime = info.ModTime()
+		}
+	}
+
+	meta, err := readMetadata(src, data)
+	if err != nil {
+		c.decodeFailed = true
+		return c, err
+	}
+	c.width, c.height = meta.Width, meta.Height
+	if !opts.Dimensions.Contains(meta.Width, meta.Height) {
+		if opts.Verbose {
+			opts.logf("📐 %dx%d is outside the dimension bounds\n", meta.Width, meta.Height)
+		}
+		return c, errOutsideDimensions
+	}
+	if err := ctx.Err(); err != nil {
+		return c, err
+	}
+
+	if err := opts.Output.MkdirAll(filepath.Dir(outputPath)); err != nil {
+		return c, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if _, err := opts.Output.Stat(outputPath); err == nil {
+		if !opts.Overwrite {
+			if err := teeOutput(outputPath, modTime, opts); err != nil {
+				return c, err
+			}
+			return c, ErrFileExists
+		}
+		c.overwritten = true
+	}
+
+	encoded, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return c, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	if err := writeFile(opts.Output, outputPath, append(encoded, '\n')); err != nil {
+		return c, fmt.Errorf("failed to write output file: %w", err)
+	}
+	if opts.Verbose {
+		opts.logf("✅ Saved: %s\n", outputPath)
+	}
+
+	if opts.PreserveMtime {
+		if err := chtimes(opts.Output, outputPath, modTime); err != nil {
+			return c, fmt.Errorf("failed to preserve modification time: %w", err)
+		}
+	}
+	if err := teeOutput(outputPath, modTime, opts); err != nil {
+		return c, err
+	}
+	return c, nil
+}
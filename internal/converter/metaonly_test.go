@@ -0,0 +1,160 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildMetadataAVIF returns an AVIF container whose primary item is a
+// 640x480 image with an ICC profile and an Exif item, but no coded data:
+// enough for readMetadata, which never decodes the image
+func buildMetadataAVIF(exif []byte) []byte {
+	be := binary.BigEndian
+	box := func(boxType string, payload ...[]byte) []byte {
+		body := bytes.Join(payload, nil)
+		return append(append(be.AppendUint32(nil, uint32(8+len(body))), boxType...), body...)
+	}
+	infe := func(id uint16, itemType string) []byte {
+		return box("infe", []byte{2, 0, 0, 0}, be.AppendUint16(nil, id), []byte{0, 0}, []byte(itemType), []byte{0})
+	}
+
+	ftyp := box("ftyp", []byte("avif\x00\x00\x00\x00mif1avif"))
+	build := func(exifOffset uint32) []byte {
+		meta := box("meta", []byte{0, 0, 0, 0},
+			box("pitm", []byte{0, 0, 0, 0, 0, 1}),
+			box("iinf", []byte{0, 0, 0, 0, 0, 2}, infe(1, "av01"), infe(2, "Exif")),
+			box("iref", []byte{0, 0, 0, 0}, box("cdsc", []byte{0, 2, 0, 1, 0, 1})),
+			// 4-byte offsets and lengths, one extent for item 2
+			box("iloc", []byte{0, 0, 0, 0, 0x44, 0, 0, 1, 0, 2, 0, 0, 0, 1},
+				be.AppendUint32(nil, exifOffset), be.AppendUint32(nil, uint32(4+len(exif)))),
+			box("iprp",
+				box("ipco",
+					box("ispe", []byte{0, 0, 0, 0}, be.AppendUint32(nil, 640), be.AppendUint32(nil, 480)),
+					box("colr", []byte("prof"), []byte("not really a profile"))),
+				box("ipma", []byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 1, 2, 0x81, 0x02})),
+		)
+		return append(append(ftyp, meta...), box("mdat", []byte{0, 0, 0, 0}, exif)...)
+	}
+
+	// The Exif data is at the start of mdat, after the header
+	data := build(0)
+	return build(uint32(len(data) - len(exif) - 4))
+}
+
+// readMetadataFile reads a metadata output of a test
+func readMetadataFile(t *testing.T, path string) ImageMetadata {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected metadata output, got: %v", err)
+	}
+	var meta ImageMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		t.Fatalf("expected valid JSON, got: %v", err)
+	}
+	return meta
+}
+
+// ==================== readMetadata Tests ====================
+
+func TestReadMetadata_ExifAndICC(t *testing.T) {
+	exif := buildExif([]exifEntry{{0x010f, exifASCII, 6, []byte("Canon\x00")}}, nil)
+	data := buildMetadataAVIF(exif)
+
+	meta, err := readMetadata(source{path: "photo.avif"}, data)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if meta.Input != "photo.avif" || meta.Format != "avif" || meta.Width != 640 || meta.Height != 480 {
+		t.Errorf("expected a 640x480 AVIF named photo.avif, got %+v", meta)
+	}
+	if !meta.ICCProfile || meta.HasAlpha {
+		t.Errorf("expected an ICC profile and no alpha, got %+v", meta)
+	}
+	if meta.Exif["Make"] != "Canon" {
+		t.Errorf("expected the Exif make, got: %v", meta.Exif)
+	}
+
+	// Unreadable Exif data is left out
+	meta, err = readMetadata(source{path: "photo.avif"}, buildMetadataAVIF([]byte("not exif")))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if meta.Exif != nil {
+		t.Errorf("expected no Exif fields, got: %v", meta.Exif)
+	}
+}
+
+func TestReadMetadata_Invalid(t *testing.T) {
+	if _, err := readMetadata(source{path: "empty.avif"}, nil); !errors.Is(err, ErrEmptyFile) {
+		t.Errorf("expected ErrEmptyFile, got: %v", err)
+	}
+	if _, err := readMetadata(source{path: "text.avif"}, []byte("this is not an AVIF file")); err == nil {
+		t.Error("expected an error for data that is not AVIF")
+	}
+}
+
+// ==================== MetaOnly Tests ====================
+
+func TestConvert_MetaOnly(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIFWithAlpha(t, inputPath)
+
+	status, err := Convert(inputPath, outputDir, Options{MetaOnly: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if status != StatusConverted {
+		t.Errorf("expected StatusConverted, got: %v", status)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "test.png")); !os.IsNotExist(err) {
+		t.Error("expected no PNG output")
+	}
+
+	meta := readMetadataFile(t, filepath.Join(outputDir, "test.json"))
+	if meta.Input != inputPath || meta.Width != 10 || meta.Height != 10 || meta.BitDepth != 8 {
+		t.Errorf("expected an 8-bit 10x10 image, got %+v", meta)
+	}
+	if !meta.HasAlpha || meta.ICCProfile || meta.Exif != nil {
+		t.Errorf("expected alpha only, got %+v", meta)
+	}
+
+	// Existing outputs are skipped like images
+	if status, err := Convert(inputPath, outputDir, Options{MetaOnly: true}); err != nil || status != StatusSkipped {
+		t.Errorf("expected the existing output to be skipped, got %v, %v", status, err)
+	}
+}
+
+func TestConvertDirectory_MetaOnly(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	os.MkdirAll(filepath.Join(inputDir, "sub"), 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "sub", "b.avif"))
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{MetaOnly: true, Recursive: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Successful != 2 {
+		t.Errorf("expected 2 successful, got: %d", result.Successful)
+	}
+	for _, name := range []string{"a.json", "b.json"} {
+		if meta := readMetadataFile(t, filepath.Join(outputDir, name)); meta.Width != 10 {
+			t.Errorf("expected %s to describe a 10-pixel-wide image, got %+v", name, meta)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package converter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Netpbm output formats, raw pixels behind a short text header: binary PPM
+// (P6) holds RGB, and PAM (P7) RGB with alpha
+const (
+	ppmFormat = "ppm"
+	pamFormat = "pam"
+)
+
+// errNetpbm is returned for PPM and PAM data that cannot be decoded
+var errNetpbm = errors.New("invalid netpbm data")
+
+func init() {
+	RegisterEncoder(ppmFormat, encodePPM)
+	RegisterEncoder(pamFormat, encodePAM)
+
+	// Decoders let --verify and --compare read the outputs back
+	image.RegisterFormat(ppmFormat, "P6", decodeNetpbm, decodeNetpbmConfig)
+	image.RegisterFormat(pamFormat, "P7", decodeNetpbm, decodeNetpbmConfig)
+}
+
+// isDeep reports whether img has more than 8 bits per channel, which the
+// netpbm encoders keep as 16-bit samples
+func isDeep(img image.Image) bool {
+	switch img.(type) {
+	case *image.RGBA64, *image.NRGBA64, *image.Gray16:
+		return true
+	}
+	return false
+}
+
+// encodePPM writes img as a binary PPM (P6), 16 bits per sample for deep
+// images and 8 otherwise
+// PPM has no alpha channel, so transparent areas become black, as in JPEG
+func encodePPM(w io.Writer, img image.Image, _ EncodeOptions) error {
+	b := img.Bounds()
+	maxval, size := 255, 1
+	var pix []uint8
+	var stride int
+	if isDeep(img) {
+		maxval, size = 65535, 2
+		rgba := image.NewRGBA64(b)
+		draw.Draw(rgba, b, img, b.Min, draw.Src)
+		pix, stride = rgba.Pix, rgba.Stride
+	} else {
+		rgba := image.NewRGBA(b)
+		draw.Draw(rgba, b, img, b.Min, draw.Src)
+		pix, stride = rgba.Pix, rgba.Stride
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "P6\n%d %d\n%d\n", b.Dx(), b.Dy(), maxval)
+	row := make([]uint8, 0, b.Dx()*3*size)
+	for y := 0; y < b.Dy(); y++ {
+		row = row[:0]
+		line := pix[y*stride : y*stride+b.Dx()*4*size]
+		for x := 0; x < len(line); x += 4 * size {
+			row = append(row, line[x:x+3*size]...)
+		}
+		if _, err := bw.Write(row); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// encodePAM writes img as a PAM (P7) with TUPLTYPE RGB_ALPHA, 16 bits per
+// sample for deep images and 8 otherwise; alpha is not premultiplied
+func encodePAM(w io.Writer, img image.Image, _ EncodeOptions) error {
+	b := img.Bounds()
+	maxval, size := 255, 1
+	var pix []uint8
+	var stride int
+	if isDeep(img) {
+		maxval, size = 65535, 2
+		nrgba := image.NewNRGBA64(b)
+		draw.Draw(nrgba, b, img, b.Min, draw.Src)
+		pix, stride = nrgba.Pix, nrgba.Stride
+	} else {
+		nrgba := image.NewNRGBA(b)
+		draw.Draw(nrgba, b, img, b.Min, draw.Src)
+		pix, stride = nrgba.Pix, nrgba.Stride
+	}
+
+	// The pixels of NRGBA and NRGBA64 images are laid out like PAM's
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "P7\nWIDTH %d\nHEIGHT %d\nDEPTH 4\nMAXVAL %d\nTUPLTYPE RGB_ALPHA\nENDHDR\n", b.Dx(), b.Dy(), maxval)
+	for y := 0; y < b.Dy(); y++ {
+		if _, err := bw.Write(pix[y*stride : y*stride+b.Dx()*4*size]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// netpbmHeader is the header of a PPM or PAM image
+type netpbmHeader struct {
+	width, height int
+	// depth is the number of channels: 1 gray, 2 gray and alpha, 3 RGB or
+	// 4 RGB and alpha
+	depth  int
+	maxval int
+}
+
+// readNetpbmHeader reads the header of a binary PPM (P6) or a PAM (P7)
+func readNetpbmHeader(r *bufio.Reader) (netpbmHeader, error) {
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return netpbmHeader{}, err
+	}
+
+	switch string(magic) {
+	case "P6":
+		var fields [3]int
+		for i := range fields {
+			token, err := readNetpbmToken(r)
+			if err != nil {
+				return netpbmHeader{}, err
+			}
+			if fields[i], err = strconv.Atoi(token); err != nil {
+				return netpbmHeader{}, fmt.Errorf("%w: %q", errNetpbm, token)
+			}
+		}
+		h := netpbmHeader{width: fields[0], height: fields[1], depth: 3, maxval: fields[2]}
+		return h, h.validate()
+
+	case "P7":
+		h := netpbmHeader{}
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return netpbmHeader{}, fmt.Errorf("%w: missing ENDHDR", errNetpbm)
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			if fields[0] == "ENDHDR" {
+				return h, h.validate()
+			}
+			if len(fields) < 2 || fields[0] == "TUPLTYPE" {
+				continue
+			}
+			n, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return netpbmHeader{}, fmt.Errorf("%w: %q", errNetpbm, strings.TrimSpace(line))
+			}
+			switch fields[0] {
+			case "WIDTH":
+				h.width = n
+			case "HEIGHT":
+				h.height = n
+			case "DEPTH":
+				h.depth = n
+			case "MAXVAL":
+				h.maxval = n
+			}
+		}
+	}
+	return netpbmHeader{}, fmt.Errorf("%w: unknown magic %q", errNetpbm, magic)
+}
+
+// readNetpbmToken reads a whitespace-separated token of a PPM header,
+// skipping comments, and the single whitespace character after it
+func readNetpbmToken(r *bufio.Reader) (string, error) {
+	var token []byte
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case c == '#' && len(token) == 0:
+			if _, err := r.ReadString('\n'); err != nil {
+				return "", err
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if len(token) > 0 {
+				return string(token), nil
+			}
+		default:
+			token = append(token, c)
+		}
+	}
+}
+
+// validate checks that h describes an image this package can decode
+func (h netpbmHeader) validate() error {
+	if h.width <= 0 || h.height <= 0 || h.depth < 1 || h.depth > 4 || h.maxval < 1 || h.maxval > 65535 {
+		return fmt.Errorf("%w: %dx%d, depth %d, maxval %d", errNetpbm, h.width, h.height, h.depth, h.maxval)
+	}
+	return nil
+}
+
+// decodeNetpbmConfig returns the size and color model of a PPM or PAM image
+func decodeNetpbmConfig(r io.Reader) (image.Config, error) {
+	h, err := readNetpbmHeader(bufio.NewReader(r))
+	if err != nil {
+		return image.Config{}, err
+	}
+	model := color.NRGBAModel
+	if h.maxval > 255 {
+		model = color.NRGBA64Model
+	}
+	return image.Config{ColorModel: model, Width: h.width, Height: h.height}, nil
+}
+
+// decodeNetpbm decodes a PPM or PAM image into an NRGBA image, or an
+// NRGBA64 one for samples of more than 8 bits
+func decodeNetpbm(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	h, err := readNetpbmHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	size := 1
+	if h.maxval > 255 {
+		size = 2
+	}
+	row := make([]uint8, h.width*h.depth*size)
+	var img draw.Image
+	if size == 2 {
+		img = image.NewNRGBA64(image.Rect(0, 0, h.width, h.height))
+	} else {
+		img = image.NewNRGBA(image.Rect(0, 0, h.width, h.height))
+	}
+
+	for y := 0; y < h.height; y++ {
+		if _, err := io.ReadFull(br, row); err != nil {
+			return nil, fmt.Errorf("%w: %v", errNetpbm, err)
+		}
+		for x := 0; x < h.width; x++ {
+			var samples [4]uint32
+			for c := 0; c < h.depth; c++ {
+				i := (x*h.depth + c) * size
+				v := uint32(row[i])
+				if size == 2 {
+					v = v<<8 | uint32(row[i+1])
+				}
+				samples[c] = v * 0xffff / uint32(h.maxval)
+			}
+			// Gray is spread over red, green and blue, and pixels are opaque
+			// without an alpha channel
+			red, green, blue, alpha := samples[0], samples[1], samples[2], uint32(0xffff)
+			switch h.depth {
+			case 1, 2:
+				green, blue = red, red
+				if h.depth == 2 {
+					alpha = samples[1]
+				}
+			case 4:
+				alpha = samples[3]
+			}
+			if size == 2 {
+				img.Set(x, y, color.NRGBA64{uint16(red), uint16(green), uint16(blue), uint16(alpha)})
+			} else {
+				img.Set(x, y, color.NRGBA{uint8(red >> 8), uint8(green >> 8), uint8(blue >> 8), uint8(alpha >> 8)})
+			}
+		}
+	}
+	return img, nil
+}
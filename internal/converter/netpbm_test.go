@@ -0,0 +1,122 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ==================== Netpbm Tests ====================
+
+func TestEncodePPM(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{10, 20, 30, 255})
+	img.SetNRGBA(1, 0, color.NRGBA{200, 100, 50, 0})
+
+	var buf bytes.Buffer
+	if err := encodePPM(&buf, img, EncodeOptions{}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	// Transparent pixels become black
+	want := append([]byte("P6\n2 1\n255\n"), 10, 20, 30, 0, 0, 0)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("expected %q, got %q", want, buf.Bytes())
+	}
+}
+
+func TestEncodePAM_RoundTrip(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 10)
+	}
+
+	var buf bytes.Buffer
+	if err := encodePAM(&buf, img, EncodeOptions{}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "P7\nWIDTH 3\nHEIGHT 2\nDEPTH 4\nMAXVAL 255\nTUPLTYPE RGB_ALPHA\nENDHDR\n") {
+		t.Errorf("unexpected PAM header: %q", buf.String()[:60])
+	}
+
+	decoded, format, err := image.Decode(&buf)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if format != "pam" {
+		t.Errorf("expected pam, got %s", format)
+	}
+	if got := decoded.(*image.NRGBA); !bytes.Equal(got.Pix, img.Pix) {
+		t.Errorf("expected the pixels to round-trip, got %v", got.Pix)
+	}
+}
+
+func TestEncodeNetpbm_KeepsDepth(t *testing.T) {
+	img := image.NewNRGBA64(image.Rect(0, 0, 1, 1))
+	img.SetNRGBA64(0, 0, color.NRGBA64{0x1234, 0x5678, 0x9abc, 0xffff})
+
+	for format, encode := range map[string]EncoderFunc{"ppm": encodePPM, "pam": encodePAM} {
+		var buf bytes.Buffer
+		if err := encode(&buf, img, EncodeOptions{}); err != nil {
+			t.Fatalf("%s: expected no error, got: %v", format, err)
+		}
+		if !bytes.Contains(buf.Bytes(), []byte("65535\n")) {
+			t.Errorf("%s: expected 16-bit samples, got %q", format, buf.Bytes())
+		}
+		decoded, _, err := image.Decode(&buf)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got: %v", format, err)
+		}
+		if got := color.NRGBA64Model.Convert(decoded.At(0, 0)); got != img.At(0, 0) {
+			t.Errorf("%s: expected %v, got %v", format, img.At(0, 0), got)
+		}
+	}
+}
+
+func TestDecodeNetpbm(t *testing.T) {
+	gray := append([]byte("P7\nWIDTH 2\nHEIGHT 1\nDEPTH 1\nMAXVAL 15\nTUPLTYPE GRAYSCALE\nENDHDR\n"), 0, 15)
+	img, _, err := image.Decode(bytes.NewReader(gray))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := img.At(1, 0).(color.NRGBA); got != (color.NRGBA{255, 255, 255, 255}) {
+		t.Errorf("expected maxval to scale to white, got %v", got)
+	}
+
+	commented := append([]byte("P6 # a comment\n1 1 255\n"), 1, 2, 3)
+	if img, _, err := image.Decode(bytes.NewReader(commented)); err != nil || img.At(0, 0) != (color.NRGBA{1, 2, 3, 255}) {
+		t.Errorf("expected a commented header to decode, got %v, %v", img, err)
+	}
+
+	for _, data := range []string{"P6\n1 1 255\n", "P7\nWIDTH 1\nENDHDR\n", "P6\n0 1 255\n\x00\x00\x00"} {
+		if _, _, err := image.Decode(strings.NewReader(data)); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestConvert_PPMVerify(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	for _, format := range []string{"ppm", "pam"} {
+		if _, err := Convert(inputPath, outputDir, Options{Format: format, Verify: true, StripMetadata: true}); err != nil {
+			t.Fatalf("%s: expected no error, got: %v", format, err)
+		}
+		data, err := os.ReadFile(filepath.Join(outputDir, "test."+format))
+		if err != nil {
+			t.Fatalf("%s: expected output to exist, got: %v", format, err)
+		}
+		config, got, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil || got != format || config.Width != 10 || config.Height != 10 {
+			t.Errorf("%s: expected a 10x10 %s, got %s %+v (%v)", format, format, got, config, err)
+		}
+	}
+}
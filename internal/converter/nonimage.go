@@ -0,0 +1,65 @@
+package converter
+
+import (
+	"avif2png/internal/isobmff"
+	"errors"
+	"fmt"
+)
+
+// ErrSequence is returned for an image sequence (brand avis) that failed to
+// decode and has no still image to fall back on
+var ErrSequence = errors.New("image sequence without a still image")
+
+// ErrNoPrimaryImage is returned for a file whose container has no primary
+// image to show, such as one holding only metadata
+var ErrNoPrimaryImage = errors.New("no primary image")
+
+// Skip reasons with Options.SkipNonImages
+const (
+	// SkipReasonSequence is for files that fail with ErrSequence
+	SkipReasonSequence = "image sequence"
+	// SkipReasonNoImage is for files that fail with ErrNoPrimaryImage
+	SkipReasonNoImage = "no primary image"
+)
+
+// explainDecodeError returns err wrapped in ErrSequence or ErrNoPrimaryImage
+// when the container of src shows why it could not be decoded, and err as
+// is otherwise
+// It only runs once decoding failed, so still images pay nothing for it
+func explainDecodeError(src source, err error) error {
+	if errors.Is(err, ErrEmptyFile) || errors.Is(err, ErrNoThumbnail) {
+		return err
+	}
+	data, readErr := src.read()
+	if readErr != nil {
+		return err
+	}
+	f, parseErr := isobmff.Parse(data)
+	if parseErr != nil {
+		return err
+	}
+
+	primary := f.Item(f.PrimaryItemID)
+	switch {
+	case primary != nil && primary.IsImage():
+		return err
+	case f.IsSequence():
+		return fmt.Errorf("%w: %v", ErrSequence, err)
+	case primary != nil:
+		return fmt.Errorf("%w: primary item is %q, not an image: %v", ErrNoPrimaryImage, primary.Type, err)
+	default:
+		return fmt.Errorf("%w: %v", ErrNoPrimaryImage, err)
+	}
+}
+
+// nonImageSkipReason returns the skip reason of Options.SkipNonImages for
+// err, or "" when err is no such failure
+func nonImageSkipReason(err error) string {
+	switch {
+	case errors.Is(err, ErrSequence):
+		return SkipReasonSequence
+	case errors.Is(err, ErrNoPrimaryImage):
+		return SkipReasonNoImage
+	}
+	return ""
+}
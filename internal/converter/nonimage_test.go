@@ -0,0 +1,81 @@
+package converter
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// containerBox returns an ISOBMFF box of the given type and payload
+func containerBox(boxType string, payload []byte) []byte {
+	box := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(box, uint32(8+len(payload)))
+	copy(box[4:], boxType)
+	return append(box, payload...)
+}
+
+// createNonImages writes a broken image sequence and a metadata-only file
+// to dir, and returns their paths
+func createNonImages(t *testing.T, dir string) (sequence, metaOnly string) {
+	t.Helper()
+
+	sequence = filepath.Join(dir, "clip.avif")
+	data := append(containerBox("ftyp", []byte("avis\x00\x00\x00\x00avismif1")), containerBox("moov", nil)...)
+	if err := os.WriteFile(sequence, data, 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	metaOnly = filepath.Join(dir, "meta.avif")
+	data = append(containerBox("ftyp", []byte("mif1\x00\x00\x00\x00mif1")), containerBox("meta", make([]byte, 4))...)
+	if err := os.WriteFile(metaOnly, data, 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+	return sequence, metaOnly
+}
+
+// ==================== Non-Image Tests ====================
+
+func TestConvert_NonImageErrors(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	sequence, metaOnly := createNonImages(t, testDir)
+	outputDir := filepath.Join(testDir, "output")
+
+	if _, err := Convert(sequence, outputDir, Options{}); !errors.Is(err, ErrSequence) {
+		t.Errorf("expected ErrSequence, got: %v", err)
+	}
+	if _, err := Convert(metaOnly, outputDir, Options{}); !errors.Is(err, ErrNoPrimaryImage) {
+		t.Errorf("expected ErrNoPrimaryImage, got: %v", err)
+	}
+
+	// Files that are not AVIF at all keep the decoder's error
+	invalid := filepath.Join(testDir, "invalid.avif")
+	os.WriteFile(invalid, []byte("not a valid avif file"), 0644)
+	if _, err := Convert(invalid, outputDir, Options{}); err == nil || nonImageSkipReason(err) != "" {
+		t.Errorf("expected a plain decode error, got: %v", err)
+	}
+}
+
+func TestConvertDirectory_SkipNonImages(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	os.MkdirAll(inputDir, 0755)
+	createNonImages(t, inputDir)
+	createTestAVIF(t, filepath.Join(inputDir, "photo.avif"))
+
+	result, err := ConvertDirectory(inputDir, filepath.Join(testDir, "output"), Options{SkipNonImages: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Successful != 1 || result.Failed != 0 {
+		t.Errorf("expected 1 converted and no failures, got %d converted, %d failed", result.Successful, result.Failed)
+	}
+	if result.SkippedReasons[SkipReasonSequence] != 1 || result.SkippedReasons[SkipReasonNoImage] != 1 {
+		t.Errorf("expected one file skipped for each reason, got: %v", result.SkippedReasons)
+	}
+}
@@ -0,0 +1,107 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Policies for files that fail in a bulk conversion, see Options.OnError
+const (
+	// OnErrorSkip records the failure and goes on with the next file
+	OnErrorSkip = "skip"
+	// OnErrorStop stops the conversion at the first failure with ErrStopped
+	OnErrorStop = "stop"
+	// OnErrorQuarantine moves inputs that fail to decode to
+	// Options.QuarantineDir, then goes on like OnErrorSkip
+	OnErrorQuarantine = "quarantine"
+)
+
+// ErrStopped is returned by bulk conversions that stop at a failed file
+// with OnErrorStop
+var ErrStopped = errors.New("stopped at a failed file")
+
+// IsValidOnError reports whether policy is a policy for Options.OnError;
+// empty means OnErrorSkip
+func IsValidOnError(policy string) bool {
+	return policy == "" || policy == OnErrorSkip || policy == OnErrorStop || policy == OnErrorQuarantine
+}
+
+// stopsAfterLast reports whether a bulk conversion stops after the file it
+// recorded last, which is when it failed with OnErrorStop
+// It returns the error to stop with
+func stopsAfterLast(result *ConversionResult, opts Options) error {
+	if opts.OnError != OnErrorStop || len(result.Files) == 0 {
+		return nil
+	}
+	last := result.Files[len(result.Files)-1]
+	if last.Status != StatusFailed {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrStopped, last.InputPath)
+}
+
+// quarantine moves the input of src into dir, creating it if needed, and
+// returns its new path
+// Sources held in memory, such as archive entries, are written there instead
+// Inputs of the same name are kept apart as name-1.avif, name-2.avif, ...
+func quarantine(src source, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	base := filepath.Base(filepath.FromSlash(src.path))
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	for n := 0; ; n++ {
+		dest := filepath.Join(dir, base)
+		if n > 0 {
+			dest = filepath.Join(dir, stem+"-"+strconv.Itoa(n)+ext)
+		}
+
+		// The file is created exclusively, so concurrent runs never share a name
+		file, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if src.data != nil {
+			_, err = file.Write(src.data)
+		} else {
+			err = moveInto(file, src.path)
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(dest)
+			return "", err
+		}
+		return dest, nil
+	}
+}
+
+// moveInto moves the file at path to the already created file dest,
+// renaming it when possible and copying it across file systems otherwise
+func moveInto(dest *os.File, path string) error {
+	if err := os.Rename(path, dest.Name()); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if _, err := io.Copy(dest, in); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
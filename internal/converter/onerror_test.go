@@ -0,0 +1,144 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ==================== OnError Tests ====================
+
+func TestIsValidOnError(t *testing.T) {
+	for policy, want := range map[string]bool{"": true, "skip": true, "stop": true, "quarantine": true, "abort": false, "STOP": false} {
+		if got := IsValidOnError(policy); got != want {
+			t.Errorf("IsValidOnError(%q): expected %v, got %v", policy, want, got)
+		}
+	}
+}
+
+func TestConvertDirectory_OnErrorStop(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	os.MkdirAll(inputDir, 0755)
+	if err := os.WriteFile(filepath.Join(inputDir, "a.avif"), []byte("not a valid avif file"), 0644); err != nil {
+		t.Fatalf("failed to create invalid test file: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "b.avif"))
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{OnError: OnErrorStop})
+	if !errors.Is(err, ErrStopped) {
+		t.Fatalf("expected ErrStopped, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "a.avif") {
+		t.Errorf("expected the error to name the failed file, got: %v", err)
+	}
+	if result.Failed != 1 || result.Successful != 0 {
+		t.Errorf("expected 1 failure and nothing converted, got %d failed, %d successful", result.Failed, result.Successful)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "b.png")); !os.IsNotExist(err) {
+		t.Errorf("expected b.avif not to be converted, got: %v", err)
+	}
+}
+
+func TestConvertDirectory_OnErrorSkip(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	os.MkdirAll(inputDir, 0755)
+	os.WriteFile(filepath.Join(inputDir, "a.avif"), []byte("not a valid avif file"), 0644)
+	createTestAVIF(t, filepath.Join(inputDir, "b.avif"))
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{OnError: OnErrorSkip})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Failed != 1 || result.Successful != 1 {
+		t.Errorf("expected 1 failure and 1 success, got %d failed, %d successful", result.Failed, result.Successful)
+	}
+	if _, err := os.Stat(filepath.Join(inputDir, "a.avif")); err != nil {
+		t.Errorf("expected the failed input to stay in place, got: %v", err)
+	}
+}
+
+func TestConvertDirectory_OnErrorQuarantine(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	quarantineDir := filepath.Join(testDir, "bad")
+	os.MkdirAll(filepath.Join(inputDir, "sub"), 0755)
+	os.MkdirAll(quarantineDir, 0755)
+	for _, path := range []string{"a.avif", filepath.Join("sub", "a.avif")} {
+		if err := os.WriteFile(filepath.Join(inputDir, path), []byte("not a valid avif file"), 0644); err != nil {
+			t.Fatalf("failed to create invalid test file: %v", err)
+		}
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "b.avif"))
+
+	// A file of the same name from an earlier run is kept
+	os.WriteFile(filepath.Join(quarantineDir, "a.avif"), []byte("earlier"), 0644)
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{Recursive: true, OnError: OnErrorQuarantine, QuarantineDir: quarantineDir})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Failed != 2 || result.Successful != 1 {
+		t.Errorf("expected 2 failures and 1 success, got %d failed, %d successful", result.Failed, result.Successful)
+	}
+
+	var moved []string
+	for _, file := range result.Files {
+		if file.QuarantinePath != "" {
+			moved = append(moved, filepath.Base(file.QuarantinePath))
+			if _, err := os.Stat(file.InputPath); !os.IsNotExist(err) {
+				t.Errorf("expected %s to be moved away, got: %v", file.InputPath, err)
+			}
+			if _, err := os.Stat(file.QuarantinePath); err != nil {
+				t.Errorf("expected %s to exist, got: %v", file.QuarantinePath, err)
+			}
+		}
+	}
+	if len(moved) != 2 || moved[0] != "a-1.avif" || moved[1] != "a-2.avif" {
+		t.Errorf("expected a-1.avif and a-2.avif, got %v", moved)
+	}
+	if data, _ := os.ReadFile(filepath.Join(quarantineDir, "a.avif")); string(data) != "earlier" {
+		t.Errorf("expected the earlier file to be kept, got %q", data)
+	}
+}
+
+func TestConvertTar_OnErrorQuarantine(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	quarantineDir := filepath.Join(testDir, "bad")
+	archive := buildTar(t, map[string][]byte{
+		"photos/bad.avif":  []byte("not a valid avif file"),
+		"photos/good.avif": testAVIFData(t, testDir),
+	}, false)
+
+	result, err := ConvertTar(context.Background(), bytes.NewReader(archive), filepath.Join(testDir, "output"), Options{OnError: OnErrorQuarantine, QuarantineDir: quarantineDir})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Failed != 1 || result.Successful != 1 {
+		t.Errorf("expected 1 failure and 1 success, got %d failed, %d successful", result.Failed, result.Successful)
+	}
+
+	data, err := os.ReadFile(filepath.Join(quarantineDir, "bad.avif"))
+	if err != nil {
+		t.Fatalf("expected the entry to be quarantined, got: %v", err)
+	}
+	if string(data) != "not a valid avif file" {
+		t.Errorf("expected the entry contents, got %q", data)
+	}
+}
@@ -0,0 +1,123 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// OutputFS is where outputs are written
+// Paths are the output paths the converter computes from the output
+// directory, such as "out/photo.png"; OSFS writes them to disk, while other
+// implementations may keep them in memory or upload them elsewhere
+type OutputFS interface {
+	// MkdirAll creates dir and any missing parents
+	MkdirAll(dir string) error
+	// Create creates or truncates the file at path for writing
+	Create(path string) (io.WriteCloser, error)
+	// Open opens the file at path for reading, to verify or compare it
+	Open(path string) (io.ReadCloser, error)
+	// Stat returns the file info of path, or an error satisfying
+	// errors.Is(err, fs.ErrNotExist) if it does not exist
+	Stat(path string) (fs.FileInfo, error)
+	// Remove removes the file at path
+	Remove(path string) error
+}
+
+// ChtimesFS is an OutputFS that can set modification times, as needed by
+// Options.PreserveMtime
+type ChtimesFS interface {
+	OutputFS
+	Chtimes(path string, atime, mtime time.Time) error
+}
+
+// OSFS is the OutputFS of the local file system, used when Options.Output is nil
+// FileMode, when set, is the permission of created files regardless of the
+// umask, also applied to existing files they replace; otherwise files are
+// created with 0666 before the umask, like os.Create
+type OSFS struct {
+	FileMode fs.FileMode
+}
+
+// MkdirAll creates dir like os.MkdirAll
+func (OSFS) MkdirAll(dir string) error {
+	return os.MkdirAll(dir, 0755)
+}
+
+// Create creates the file at path like os.Create, with o.FileMode if set
+func (o OSFS) Create(path string) (io.WriteCloser, error) {
+	if o.FileMode == 0 {
+		return os.Create(path)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, o.FileMode)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Chmod(o.FileMode); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// Open opens the file at path like os.Open
+func (OSFS) Open(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
+
+// Stat returns the file info of path like os.Stat
+func (OSFS) Stat(path string) (fs.FileInfo, error) {
+	return os.Stat(path)
+}
+
+// Remove removes the file at path like os.Remove
+func (OSFS) Remove(path string) error {
+	return os.Remove(path)
+}
+
+// Chtimes sets the access and modification times of path like os.Chtimes
+func (OSFS) Chtimes(path string, atime, mtime time.Time) error {
+	return os.Chtimes(path, atime, mtime)
+}
+
+// checkOutputDir is CheckOutputDir for an output file system
+func checkOutputDir(out OutputFS, dir string) error {
+	info, err := out.Stat(dir)
+	if err == nil && !info.IsDir() {
+		return fmt.Errorf("%w: %s", ErrOutputNotDir, dir)
+	}
+	return nil
+}
+
+// withinDir reports whether path is dir or a path below it
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// writeFile writes data to a new file at path
+func writeFile(out OutputFS, path string, data []byte) error {
+	file, err := out.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// chtimes sets the modification time of path, if out supports it
+func chtimes(out OutputFS, path string, t time.Time) error {
+	c, ok := out.(ChtimesFS)
+	if !ok {
+		return fmt.Errorf("output file system %T cannot set modification times", out)
+	}
+	return c.Chtimes(path, t, t)
+}
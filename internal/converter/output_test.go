@@ -0,0 +1,177 @@
+package converter
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// memFS is an in-memory OutputFS
+type memFS struct {
+	mu    sync.Mutex
+	files fstest.MapFS
+}
+
+func newMemFS() *memFS {
+	return &memFS{files: fstest.MapFS{}}
+}
+
+func (m *memFS) MkdirAll(dir string) error {
+	return nil
+}
+
+func (m *memFS) Create(path string) (io.WriteCloser, error) {
+	return &memFile{fs: m, path: filepath.ToSlash(path)}, nil
+}
+
+func (m *memFS) Open(path string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.files.Open(filepath.ToSlash(path))
+}
+
+func (m *memFS) Stat(path string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.files.Stat(filepath.ToSlash(path))
+}
+
+func (m *memFS) Remove(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.files, filepath.ToSlash(path))
+	return nil
+}
+
+// memFile stores its data in its memFS once closed
+type memFile struct {
+	bytes.Buffer
+	fs   *memFS
+	path string
+}
+
+func (f *memFile) Close() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	f.fs.files[f.path] = &fstest.MapFile{Data: f.Bytes()}
+	return nil
+}
+
+// ==================== OutputFS Tests ====================
+
+func TestConvert_OutputFS(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	createTestAVIF(t, inputPath)
+
+	out := newMemFS()
+	status, err := Convert(inputPath, "output", Options{Output: out, Verify: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if status != StatusConverted {
+		t.Errorf("expected status %v, got %v", StatusConverted, status)
+	}
+
+	file, err := out.Open(filepath.Join("output", "test.png"))
+	if err != nil {
+		t.Fatalf("expected output in memory, got: %v", err)
+	}
+	defer file.Close()
+	if _, err := png.DecodeConfig(file); err != nil {
+		t.Errorf("expected a valid PNG, got: %v", err)
+	}
+
+	if _, err := os.Stat("output"); !os.IsNotExist(err) {
+		t.Errorf("expected nothing written to disk, got: %v", err)
+	}
+}
+
+func TestConvert_OutputFSSkipsExisting(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	createTestAVIF(t, inputPath)
+
+	out := newMemFS()
+	out.files["output/test.png"] = &fstest.MapFile{Data: []byte("existing")}
+
+	status, err := Convert(inputPath, "output", Options{Output: out})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if status != StatusSkipped {
+		t.Errorf("expected status %v, got %v", StatusSkipped, status)
+	}
+	if got := string(out.files["output/test.png"].Data); got != "existing" {
+		t.Errorf("expected existing output to be kept, got %q", got)
+	}
+}
+
+func TestConvert_OutputFSPreserveMtimeUnsupported(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	createTestAVIF(t, inputPath)
+
+	_, err := Convert(inputPath, "output", Options{Output: newMemFS(), PreserveMtime: true})
+	if err == nil {
+		t.Fatal("expected an error for an output without modification times")
+	}
+}
+
+func TestCheckOutputDir_OutputFS(t *testing.T) {
+	out := newMemFS()
+	out.files["file"] = &fstest.MapFile{Data: []byte("x")}
+
+	if err := checkOutputDir(out, "file"); !errors.Is(err, ErrOutputNotDir) {
+		t.Errorf("expected ErrOutputNotDir, got: %v", err)
+	}
+	if err := checkOutputDir(out, "missing"); err != nil {
+		t.Errorf("expected no error for a missing directory, got: %v", err)
+	}
+}
+
+func TestConvert_FileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not supported on Windows")
+	}
+
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	// An existing output keeps its mode when truncated, so it is changed too
+	os.MkdirAll(outputDir, 0755)
+	outputPath := filepath.Join(outputDir, "test.png")
+	if err := os.WriteFile(outputPath, nil, 0644); err != nil {
+		t.Fatalf("failed to create existing output: %v", err)
+	}
+
+	if _, err := Convert(inputPath, outputDir, Options{FileMode: 0600, Overwrite: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		t.Fatalf("expected output to exist, got: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("expected mode 0600, got %o", perm)
+	}
+}
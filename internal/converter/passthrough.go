@@ -0,0 +1,37 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+)
+
+// signatures are the leading bytes of the image formats that inputs named
+// .avif sometimes turn out to be, by output format name
+var signatures = []struct {
+	format string
+	magic  []byte
+}{
+	{"png", []byte("\x89PNG\r\n\x1a\n")},
+	{"jpeg", []byte{0xff, 0xd8, 0xff}},
+	{"gif", []byte("GIF8")},
+}
+
+// sniffFormat returns the format of data when its signature is that of a
+// PNG, JPEG or GIF image, as for misnamed inputs, and "" otherwise
+func sniffFormat(data []byte) string {
+	for _, sig := range signatures {
+		if bytes.HasPrefix(data, sig.magic) {
+			return sig.format
+		}
+	}
+	return ""
+}
+
+// changesImage reports whether opts alter images beyond encoding them, so
+// that inputs already in the output format cannot be copied as they are
+// with Passthrough
+func changesImage(opts Options) bool {
+	return !opts.CropPct.IsZero() || opts.Rotate != 0 || opts.Trim ||
+		opts.Canvas != (image.Point{}) || opts.MaxOutputSize > 0 ||
+		opts.StripMetadata || opts.AutoFormat
+}
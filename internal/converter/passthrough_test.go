@@ -0,0 +1,101 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// createMisnamedPNG writes an uncompressed PNG to path, which the default
+// compression of the PNG encoder never reproduces
+func createMisnamedPNG(t *testing.T, path string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.NoCompression}
+	if err := encoder.Encode(&buf, solidImage(12, 8, color.NRGBA{0, 160, 0, 255})); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to write test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// ==================== Passthrough Tests ====================
+
+func TestSniffFormat(t *testing.T) {
+	tests := map[string]string{
+		"\x89PNG\r\n\x1a\nrest":            "png",
+		"\xff\xd8\xff\xe0rest":             "jpeg",
+		"GIF89arest":                       "gif",
+		"\x00\x00\x00\x1cftypavif\x00\x00": "",
+		"":                                 "",
+	}
+	for data, want := range tests {
+		if got := sniffFormat([]byte(data)); got != want {
+			t.Errorf("sniffFormat(%q): expected %q, got %q", data, want, got)
+		}
+	}
+}
+
+func TestConvertFile_Passthrough(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "photo.avif")
+	original := createMisnamedPNG(t, inputPath)
+
+	outputPath := filepath.Join(testDir, "copied", "photo.png")
+	c, err := convertFile(context.Background(), source{path: inputPath}, outputPath, Options{Passthrough: true, Verify: true}.withDefaults())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if c.sourceFormat != "png" || !c.passedThrough {
+		t.Errorf("expected a png input copied as is, got %q and %v", c.sourceFormat, c.passedThrough)
+	}
+	if data, _ := os.ReadFile(outputPath); !bytes.Equal(data, original) {
+		t.Error("expected the output to be a copy of the input")
+	}
+
+	// Options that change the image re-encode it
+	outputPath = filepath.Join(testDir, "rotated", "photo.png")
+	c, err = convertFile(context.Background(), source{path: inputPath}, outputPath, Options{Passthrough: true, Rotate: 90}.withDefaults())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if c.passedThrough || c.width != 8 || c.height != 12 {
+		t.Errorf("expected a rotated re-encode, got %dx%d, copied: %v", c.width, c.height, c.passedThrough)
+	}
+}
+
+func TestConvertDirectory_PassthroughOtherFormat(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	os.MkdirAll(inputDir, 0755)
+	createMisnamedPNG(t, filepath.Join(inputDir, "photo.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "real.avif"))
+
+	result, err := ConvertDirectory(inputDir, filepath.Join(testDir, "output"), Options{Format: "jpeg", Passthrough: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Successful != 2 {
+		t.Fatalf("expected both files to convert, got %d", result.Successful)
+	}
+	for _, file := range result.Files {
+		want := ""
+		if filepath.Base(file.InputPath) == "photo.avif" {
+			want = "png"
+		}
+		if file.SourceFormat != want || file.Format != "jpeg" {
+			t.Errorf("%s: expected source format %q converted to jpeg, got %q to %q", file.InputPath, want, file.SourceFormat, file.Format)
+		}
+	}
+}
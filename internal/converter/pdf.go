@@ -0,0 +1,139 @@
+package converter
+
+import (
+	"avif2png/internal/pdf"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// ConvertDirectoryToPDF converts all AVIF files in a directory into a single
+// PDF at pdfPath, one image per page in scan order
+// opts.Format, opts.Quality and the per-file output options do not apply
+// Like ConvertDirectoryContext, it stops once ctx is cancelled; the pages
+// added so far are still written, and the result is never nil
+func ConvertDirectoryToPDF(ctx context.Context, inputDir, pdfPath string, opts Options) (*ConversionResult, error) {
+	result := &ConversionResult{
+		SkippedReasons:  map[string]int{},
+		FilteredReasons: map[string]int{},
+		Errors:          []FileError{},
+		Files:           []FileResult{},
+	}
+
+	// Pages follow the order of the scan, so it must be sequential
+	opts = opts.withDefaults()
+	opts.ScanWorkers = 0
+	var avifFiles []string
+	err := walkAVIFFiles(inputDir, opts, func(path string) error {
+		avifFiles = append(avifFiles, path)
+		return nil
+	}, func(reason string) {
+		result.FilteredReasons[reason]++
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to scan directory: %w", err)
+	}
+
+	result.TotalFiles = len(avifFiles)
+	result.total.Store(int64(result.TotalFiles))
+
+	if result.TotalFiles == 0 {
+		return result, nil
+	}
+
+	if _, err := opts.Output.Stat(pdfPath); err == nil && !opts.Overwrite {
+		return result, ErrFileExists
+	}
+
+	if err := opts.Output.MkdirAll(filepath.Dir(pdfPath)); err != nil {
+		return result, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	file, err := opts.Output.Create(pdfPath)
+	if err != nil {
+		return result, fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer file.Close()
+
+	if opts.Verbose {
+		opts.logf("📂 Processing directory: %s\n", inputDir)
+		opts.logf("📊 Found %d AVIF file(s)\n", result.TotalFiles)
+	}
+
+	writer := pdf.NewWriter(file)
+	var cancelErr error
+	for i, filePath := range avifFiles {
+		if cancelErr = ctx.Err(); cancelErr != nil {
+			break
+		}
+
+		if opts.Verbose {
+			opts.logf("  %s Adding %s... ", result.progressLabel(i+1, int64(result.TotalFiles)), filepath.Base(filePath))
+		}
+
+		fileResult := FileResult{InputPath: filePath, OutputPath: pdfPath}
+
+		start := time.Now()
+		img, info, err := decodeInput(filePath, opts)
+		if err != nil {
+			err = explainDecodeError(source{path: filePath}, err)
+		} else {
+			img = fitCanvas(rotate(cropPercent(img, opts.CropPct), opts.Rotate), opts)
+			fileResult.InputSize = info.Size()
+			fileResult.Width, fileResult.Height = img.Bounds().Dx(), img.Bounds().Dy()
+			err = writer.AddImage(img)
+		}
+		fileResult.Duration = time.Since(start)
+
+		if reason := nonImageSkipReason(err); opts.SkipNonImages && reason != "" {
+			result.addSkip(reason)
+			fileResult.Status, fileResult.SkipReason = StatusSkipped, reason
+			if opts.Verbose {
+				opts.logf("⚠️  Skipped (%s)\n", reason)
+			}
+		} else if err != nil {
+			result.Failed++
+			if errors.Is(err, ErrEmptyFile) {
+				result.Empty++
+			}
+			fileResult.Status = StatusFailed
+			fileResult.Error = err
+			result.Errors = append(result.Errors, FileError{FilePath: filePath, Error: err})
+			if opts.Verbose {
+				opts.logf("❌ Failed: %v\n", err)
+			}
+		} else {
+			result.Successful++
+			fileResult.Status = StatusConverted
+			if opts.Verbose {
+				opts.logf("✅\n")
+			}
+		}
+
+		result.Files = append(result.Files, fileResult)
+		result.recordDuration(fileResult.Duration)
+		result.processed.Add(1)
+	}
+
+	// A document without pages is invalid, so nothing is left behind
+	if writer.PageCount() == 0 {
+		file.Close()
+		opts.Output.Remove(pdfPath)
+		return result, cancelErr
+	}
+
+	if err := writer.Close(); err != nil {
+		return result, fmt.Errorf("failed to write PDF: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return result, fmt.Errorf("failed to write PDF: %w", err)
+	}
+
+	if opts.Verbose {
+		opts.logf("✅ Saved: %s\n", pdfPath)
+	}
+
+	return result, cancelErr
+}
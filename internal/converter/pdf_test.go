@@ -0,0 +1,133 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ==================== ConvertDirectoryToPDF Tests ====================
+
+func TestConvertDirectoryToPDF_Success(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	pdfPath := filepath.Join(testDir, "out", "album.pdf")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "b.avif"))
+
+	result, err := ConvertDirectoryToPDF(context.Background(), inputDir, pdfPath, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.Successful != 2 || result.Progress() != 1 {
+		t.Errorf("expected 2 pages added, got: %d", result.Successful)
+	}
+
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		t.Fatalf("expected PDF to exist: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) || !bytes.Contains(data, []byte("/Count 2")) {
+		t.Error("expected a two-page PDF")
+	}
+}
+
+func TestConvertDirectoryToPDF_PartialFailure(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	pdfPath := filepath.Join(testDir, "album.pdf")
+	createTestAVIF(t, filepath.Join(testDir, "good.avif"))
+	if err := os.WriteFile(filepath.Join(testDir, "bad.avif"), []byte("not a valid avif file"), 0644); err != nil {
+		t.Fatalf("failed to create invalid file: %v", err)
+	}
+
+	result, err := ConvertDirectoryToPDF(context.Background(), testDir, pdfPath, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.Successful != 1 || result.Failed != 1 || len(result.Errors) != 1 {
+		t.Errorf("expected 1 page and 1 failure, got: %d and %d", result.Successful, result.Failed)
+	}
+}
+
+func TestConvertDirectoryToPDF_NoValidImages(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	pdfPath := filepath.Join(testDir, "album.pdf")
+	if err := os.WriteFile(filepath.Join(testDir, "empty.avif"), nil, 0644); err != nil {
+		t.Fatalf("failed to create empty file: %v", err)
+	}
+
+	result, err := ConvertDirectoryToPDF(context.Background(), testDir, pdfPath, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.Empty != 1 {
+		t.Errorf("expected 1 empty file, got: %d", result.Empty)
+	}
+	if _, err := os.Stat(pdfPath); !os.IsNotExist(err) {
+		t.Error("expected no PDF to be left behind")
+	}
+}
+
+func TestConvertDirectoryToPDF_ExistingOutput(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	pdfPath := filepath.Join(testDir, "album.pdf")
+	createTestAVIF(t, filepath.Join(testDir, "a.avif"))
+	if err := os.WriteFile(pdfPath, []byte("existing"), 0644); err != nil {
+		t.Fatalf("failed to create existing file: %v", err)
+	}
+
+	result, err := ConvertDirectoryToPDF(context.Background(), testDir, pdfPath, Options{})
+	if !errors.Is(err, ErrFileExists) {
+		t.Fatalf("expected ErrFileExists, got: %v", err)
+	}
+	if result == nil {
+		t.Fatal("expected a result even on error")
+	}
+
+	if _, err := ConvertDirectoryToPDF(context.Background(), testDir, pdfPath, Options{Overwrite: true}); err != nil {
+		t.Fatalf("expected overwrite to succeed, got: %v", err)
+	}
+}
+
+func TestConvertDirectoryToPDF_Cancelled(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	pdfPath := filepath.Join(testDir, "album.pdf")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := ConvertDirectoryToPDF(ctx, inputDir, pdfPath, Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+	if result.Successful != 0 {
+		t.Errorf("expected no pages added, got: %d", result.Successful)
+	}
+	if _, err := os.Stat(pdfPath); !os.IsNotExist(err) {
+		t.Error("expected no PDF without pages")
+	}
+}
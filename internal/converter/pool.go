@@ -0,0 +1,58 @@
+package converter
+
+import (
+	"bytes"
+	"image/png"
+	"sync"
+)
+
+// maxPooledBuffer is the capacity above which buffers are left to the
+// garbage collector instead of being pooled, so one huge image does not
+// pin its memory for the rest of a run
+const maxPooledBuffer = 64 << 20
+
+// bufferPool holds the in-memory buffers of encodes that are measured or
+// compared before one of them is written, as with Optimize, AutoFormat and
+// MaxOutputSize, which encode each image several times
+var bufferPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// getBuffer returns an empty buffer from bufferPool
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns buf to bufferPool
+// Its bytes must not be used afterwards
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// pngBufferPool reuses the compression state of PNG encoders, which is
+// otherwise allocated for every image
+type pngBufferPool struct {
+	pool sync.Pool
+}
+
+// pngBuffers are the png.EncoderBufferPool of PNG encodes by compression
+// level, since encoders only reuse the state of their own level, and
+// Optimize encodes each image at several levels
+var pngBuffers = map[png.CompressionLevel]*pngBufferPool{
+	png.DefaultCompression: {},
+	png.NoCompression:      {},
+	png.BestSpeed:          {},
+	png.BestCompression:    {},
+}
+
+func (p *pngBufferPool) Get() *png.EncoderBuffer {
+	buf, _ := p.pool.Get().(*png.EncoderBuffer)
+	return buf
+}
+
+func (p *pngBufferPool) Put(buf *png.EncoderBuffer) {
+	p.pool.Put(buf)
+}
@@ -0,0 +1,81 @@
+package converter
+
+import (
+	"bytes"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// ==================== Pool Tests ====================
+
+func TestGetBuffer_Reset(t *testing.T) {
+	buf := getBuffer()
+	buf.WriteString("stale")
+	putBuffer(buf)
+
+	if buf := getBuffer(); buf.Len() != 0 {
+		t.Errorf("expected an empty buffer, got %d bytes", buf.Len())
+	}
+}
+
+func TestPutBuffer_DropsLargeBuffers(t *testing.T) {
+	buf := bytes.NewBuffer(make([]byte, 0, maxPooledBuffer+1))
+	putBuffer(buf)
+	for i := 0; i < 10; i++ {
+		if getBuffer() == buf {
+			t.Fatal("expected a buffer over maxPooledBuffer not to be pooled")
+		}
+	}
+}
+
+func TestEncodePNG_PooledOutputsMatch(t *testing.T) {
+	img := noiseImage(64, 255)
+
+	var first, second bytes.Buffer
+	for _, buf := range []*bytes.Buffer{&first, &second} {
+		if err := encodePNG(buf, img, EncodeOptions{Optimize: true}); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Error("expected encodes with reused buffers to be identical")
+	}
+	if _, err := png.Decode(&first); err != nil {
+		t.Errorf("expected a valid PNG, got: %v", err)
+	}
+}
+
+// ==================== Pool Benchmarks ====================
+
+func BenchmarkEncodePNG(b *testing.B) {
+	img := noiseImage(256, 255)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := encodePNG(io.Discard, img, EncodeOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodePNG_Optimize(b *testing.B) {
+	img := noiseImage(256, 255)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := encodePNG(io.Discard, img, EncodeOptions{Optimize: true}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFitBudget_JPEG(b *testing.B) {
+	img := noiseImage(256, 255)
+	encode, _ := lookupEncoder("jpeg")
+	opts := Options{Format: "jpeg", Quality: 90, MaxOutputSize: 40000}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := fitBudget(img, encode, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// quantize reduces img to the colors of p
+// With dither, the quantization error is spread to neighbouring pixels
+// (Floyd–Steinberg), which avoids banding in gradients at the cost of noise
+func quantize(img image.Image, p color.Palette, dither bool) *image.Paletted {
+	bounds := img.Bounds()
+	paletted := image.NewPaletted(bounds, p)
+
+	var drawer draw.Drawer = draw.Src
+	if dither {
+		drawer = draw.FloydSteinberg
+	}
+	drawer.Draw(paletted, bounds, img, bounds.Min)
+
+	return paletted
+}
@@ -0,0 +1,47 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// ==================== quantize Tests ====================
+
+func TestQuantize_Dither(t *testing.T) {
+	// A flat mid-grey cannot be represented by a black and white palette
+	img := image.NewGray(image.Rect(0, 0, 16, 16))
+	for i := range img.Pix {
+		img.Pix[i] = 0x80
+	}
+	bw := color.Palette{color.Black, color.White}
+
+	count := func(p *image.Paletted) (white int) {
+		for _, index := range p.Pix {
+			if index == 1 {
+				white++
+			}
+		}
+		return white
+	}
+
+	// Without dithering every pixel snaps to the nearest color
+	if white := count(quantize(img, bw, false)); white != 0 && white != len(img.Pix) {
+		t.Errorf("expected a flat result without dithering, got %d white pixels", white)
+	}
+
+	// With dithering roughly half the pixels are white
+	white := count(quantize(img, bw, true))
+	if white < len(img.Pix)/3 || white > len(img.Pix)*2/3 {
+		t.Errorf("expected about half the pixels to be white with dithering, got: %d of %d", white, len(img.Pix))
+	}
+}
+
+func TestQuantize_KeepsBounds(t *testing.T) {
+	img := image.NewRGBA(image.Rect(2, 3, 12, 8))
+
+	p := quantize(img, color.Palette{color.Black}, true)
+	if p.Bounds() != img.Bounds() {
+		t.Errorf("expected bounds %v, got: %v", img.Bounds(), p.Bounds())
+	}
+}
@@ -0,0 +1,154 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// downscale shrinks img to width x height by averaging the source pixels
+// covered by each output pixel (a box filter)
+// The result is 16-bit for 16-bit sources and 8-bit otherwise, so encoders
+// keep the bit depth of the original
+func downscale(img image.Image, width, height int) image.Image {
+	src := img.Bounds()
+	_, depth := pngColorType(img)
+
+	var dst draw.Image
+	if depth == 16 {
+		dst = image.NewNRGBA64(image.Rect(0, 0, width, height))
+	} else {
+		dst = image.NewNRGBA(image.Rect(0, 0, width, height))
+	}
+
+	for y := 0; y < height; y++ {
+		y0, y1 := span(y, height, src.Dy())
+		for x := 0; x < width; x++ {
+			x0, x1 := span(x, width, src.Dx())
+
+			// Average in premultiplied space so transparent pixels do not
+			// bleed their color into the result
+			var r, g, b, a uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(src.Min.X+sx, src.Min.Y+sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+				}
+			}
+			n := uint64((x1 - x0) * (y1 - y0))
+			dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(b / n), uint16(a / n)})
+		}
+	}
+
+	return dst
+}
+
+// span returns the range of source coordinates covered by output coordinate i
+// when scaling srcLen down to dstLen; it is never empty
+func span(i, dstLen, srcLen int) (start, end int) {
+	start = i * srcLen / dstLen
+	end = (i + 1) * srcLen / dstLen
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
+
+// sharpen applies an unsharp mask to img: each color channel is pushed away
+// from a Gaussian blur of itself by amount times the difference, so edges
+// gain contrast; radius is the standard deviation of the blur in pixels
+// Alpha is left unchanged, and the result has the bit depth of img like
+// downscale
+func sharpen(img image.Image, amount, radius float64) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	_, depth := pngColorType(img)
+
+	// Work on non-premultiplied 16-bit samples, one plane per channel
+	planes := [4][]float64{}
+	for c := range planes {
+		planes[c] = make([]float64, w*h)
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := color.NRGBA64Model.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA64)
+			i := y*w + x
+			planes[0][i], planes[1][i], planes[2][i], planes[3][i] = float64(p.R), float64(p.G), float64(p.B), float64(p.A)
+		}
+	}
+
+	kernel := gaussianKernel(radius)
+	for c := 0; c < 3; c++ {
+		blurred := blur(planes[c], w, h, kernel)
+		for i, v := range planes[c] {
+			planes[c][i] = math.Max(0, math.Min(0xffff, v+amount*(v-blurred[i])))
+		}
+	}
+
+	var dst draw.Image
+	if depth == 16 {
+		dst = image.NewNRGBA64(image.Rect(0, 0, w, h))
+	} else {
+		dst = image.NewNRGBA(image.Rect(0, 0, w, h))
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			dst.Set(x, y, color.NRGBA64{
+				uint16(math.Round(planes[0][i])),
+				uint16(math.Round(planes[1][i])),
+				uint16(math.Round(planes[2][i])),
+				uint16(planes[3][i]),
+			})
+		}
+	}
+	return dst
+}
+
+// gaussianKernel returns a normalized 1D Gaussian of standard deviation
+// sigma, reaching three deviations to each side of the center
+func gaussianKernel(sigma float64) []float64 {
+	reach := max(1, int(math.Ceil(sigma*3)))
+	kernel := make([]float64, 2*reach+1)
+	var sum float64
+	for i := range kernel {
+		d := float64(i - reach)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+	return kernel
+}
+
+// blur convolves a w x h plane with kernel horizontally, then vertically,
+// repeating the edge samples beyond the borders
+func blur(plane []float64, w, h int, kernel []float64) []float64 {
+	reach := len(kernel) / 2
+	tmp := make([]float64, len(plane))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum float64
+			for k, weight := range kernel {
+				sx := max(0, min(x+k-reach, w-1))
+				sum += plane[y*w+sx] * weight
+			}
+			tmp[y*w+x] = sum
+		}
+	}
+
+	out := make([]float64, len(plane))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum float64
+			for k, weight := range kernel {
+				sy := max(0, min(y+k-reach, h-1))
+				sum += tmp[sy*w+x] * weight
+			}
+			out[y*w+x] = sum
+		}
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// ==================== Downscale Tests ====================
+
+func TestDownscale_AveragesPixels(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			if x < 2 {
+				img.SetNRGBA(x, y, color.NRGBA{255, 0, 0, 255})
+			} else {
+				img.SetNRGBA(x, y, color.NRGBA{0, 0, 255, 255})
+			}
+		}
+	}
+
+	scaled := downscale(img, 2, 1)
+	if scaled.Bounds() != image.Rect(0, 0, 2, 1) {
+		t.Fatalf("expected 2x1 bounds, got: %v", scaled.Bounds())
+	}
+	if got := color.NRGBAModel.Convert(scaled.At(0, 0)); got != (color.NRGBA{255, 0, 0, 255}) {
+		t.Errorf("expected the left half to stay red, got: %v", got)
+	}
+	if got := color.NRGBAModel.Convert(scaled.At(1, 0)); got != (color.NRGBA{0, 0, 255, 255}) {
+		t.Errorf("expected the right half to stay blue, got: %v", got)
+	}
+}
+
+func TestDownscale_TransparentPixelsDoNotBleed(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{255, 255, 255, 255})
+	img.SetNRGBA(1, 0, color.NRGBA{0, 0, 0, 0})
+
+	got := color.NRGBAModel.Convert(downscale(img, 1, 1).At(0, 0)).(color.NRGBA)
+	if got.R < 254 || got.G < 254 || got.B < 254 || got.A < 127 || got.A > 128 {
+		t.Errorf("expected half-transparent white, got: %v", got)
+	}
+}
+
+func TestDownscale_KeepsBitDepth(t *testing.T) {
+	if _, ok := downscale(image.NewNRGBA64(image.Rect(0, 0, 4, 4)), 2, 2).(*image.NRGBA64); !ok {
+		t.Error("expected a 16-bit result for a 16-bit source")
+	}
+	if _, ok := downscale(image.NewRGBA(image.Rect(0, 0, 4, 4)), 2, 2).(*image.NRGBA); !ok {
+		t.Error("expected an 8-bit result for an 8-bit source")
+	}
+}
+
+// ==================== Sharpen Tests ====================
+
+func TestSharpen_IncreasesEdgeContrast(t *testing.T) {
+	// A soft ramp from dark to light gray across the middle
+	img := image.NewNRGBA(image.Rect(0, 0, 12, 1))
+	for x, v := range []uint8{50, 50, 50, 50, 50, 100, 150, 200, 200, 200, 200, 200} {
+		img.SetNRGBA(x, 0, color.NRGBA{v, v, v, 255})
+	}
+
+	sharpened := sharpen(img, 1, 1)
+	dark := color.NRGBAModel.Convert(sharpened.At(4, 0)).(color.NRGBA)
+	light := color.NRGBAModel.Convert(sharpened.At(7, 0)).(color.NRGBA)
+	if dark.R >= 50 || light.R <= 200 {
+		t.Errorf("expected the edges of the ramp to move apart, got %d and %d", dark.R, light.R)
+	}
+	if flat := color.NRGBAModel.Convert(sharpened.At(0, 0)).(color.NRGBA); flat.R != 50 {
+		t.Errorf("expected flat areas to stay unchanged, got %d", flat.R)
+	}
+	if dark.A != 255 || light.A != 255 {
+		t.Errorf("expected alpha to stay unchanged, got %d and %d", dark.A, light.A)
+	}
+}
+
+func TestSharpen_KeepsBitDepthAndBounds(t *testing.T) {
+	src := image.NewNRGBA64(image.Rect(3, 4, 9, 8))
+	sharpened := sharpen(src, 0.5, 1)
+	if _, ok := sharpened.(*image.NRGBA64); !ok {
+		t.Errorf("expected a 16-bit result for a 16-bit source, got %T", sharpened)
+	}
+	if sharpened.Bounds().Size() != src.Bounds().Size() {
+		t.Errorf("expected size %v, got %v", src.Bounds().Size(), sharpened.Bounds().Size())
+	}
+}
+
+func TestGaussianKernel_IsNormalized(t *testing.T) {
+	for _, sigma := range []float64{0.3, 1, 2.5} {
+		kernel := gaussianKernel(sigma)
+		var sum float64
+		for _, weight := range kernel {
+			sum += weight
+		}
+		if len(kernel)%2 != 1 || sum < 0.999999 || sum > 1.000001 {
+			t.Errorf("sigma %v: expected an odd normalized kernel, got %d weights summing to %v", sigma, len(kernel), sum)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package converter
+
+import "time"
+
+// ResourceUsage describes the resources used by a bulk conversion, to help
+// choose how many conversions to run at once on a machine
+type ResourceUsage struct {
+	// Elapsed is the wall-clock time spent scanning and converting
+	Elapsed time.Duration
+	// CPUTime is the user and system CPU time of the process over Elapsed,
+	// zero on platforms that do not report it
+	CPUTime time.Duration
+	// PeakHeap is the largest heap in use observed over Elapsed, in bytes
+	PeakHeap uint64
+}
+
+// Parallelism returns the average number of CPUs kept busy, CPUTime over
+// Elapsed, or 0 if either is unknown
+func (u *ResourceUsage) Parallelism() float64 {
+	if u.Elapsed <= 0 || u.CPUTime <= 0 {
+		return 0
+	}
+	return u.CPUTime.Seconds() / u.Elapsed.Seconds()
+}
+
+// trackResources starts measuring the resources used by the process and
+// returns a function that stops and returns the usage so far
+func trackResources() (stop func() *ResourceUsage) {
+	usage := &ResourceUsage{}
+	stopSampling := sampleHeap(&usage.PeakHeap)
+	start := time.Now()
+	startCPU, _ := processCPUTime()
+
+	return func() *ResourceUsage {
+		usage.Elapsed = time.Since(start)
+		if cpu, ok := processCPUTime(); ok {
+			usage.CPUTime = cpu - startCPU
+		}
+		stopSampling()
+		return usage
+	}
+}
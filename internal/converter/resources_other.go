@@ -0,0 +1,10 @@
+//go:build !unix
+
+package converter
+
+import "time"
+
+// processCPUTime reports that the CPU time of the process is unknown
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
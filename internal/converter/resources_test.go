@@ -0,0 +1,59 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// ==================== Resource Usage Tests ====================
+
+func TestResourceUsage_Parallelism(t *testing.T) {
+	usage := &ResourceUsage{Elapsed: 2 * time.Second, CPUTime: 3 * time.Second}
+	if got := usage.Parallelism(); got != 1.5 {
+		t.Errorf("expected parallelism 1.5, got %v", got)
+	}
+
+	if got := (&ResourceUsage{Elapsed: time.Second}).Parallelism(); got != 0 {
+		t.Errorf("expected parallelism 0 without CPU time, got %v", got)
+	}
+}
+
+func TestConvertDirectory_TrackResources(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "b.avif"))
+
+	result, err := ConvertDirectory(inputDir, filepath.Join(testDir, "output"), Options{TrackResources: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	usage := result.Resources
+	if usage == nil {
+		t.Fatal("expected resources to be tracked")
+	}
+	if usage.Elapsed <= 0 || usage.PeakHeap == 0 {
+		t.Errorf("expected elapsed time and peak heap, got %+v", usage)
+	}
+	if _, ok := processCPUTime(); ok && usage.CPUTime <= 0 {
+		t.Errorf("expected CPU time on %s, got %+v", runtime.GOOS, usage)
+	}
+
+	// Tracking is off by default
+	result, err = ConvertDirectory(inputDir, filepath.Join(testDir, "untracked"), Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Resources != nil {
+		t.Errorf("expected no resources without TrackResources, got %+v", result.Resources)
+	}
+}
@@ -0,0 +1,50 @@
+package converter
+
+import (
+	"image"
+	"image/draw"
+)
+
+// IsValidRotation reports whether degrees is a rotation supported by
+// Options.Rotate
+func IsValidRotation(degrees int) bool {
+	return degrees == 0 || degrees == 90 || degrees == 180 || degrees == 270
+}
+
+// rotate returns img turned clockwise by degrees, 90, 180 or 270, remapping
+// its pixels into a new image at the origin; other values return img as is
+// High bit depth images keep 16 bits per channel
+func rotate(img image.Image, degrees int) image.Image {
+	if degrees != 90 && degrees != 180 && degrees != 270 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	size := image.Rect(0, 0, w, h)
+	if degrees != 180 {
+		size = image.Rect(0, 0, h, w)
+	}
+
+	var dst draw.Image
+	if _, ok := img.(*image.RGBA64); ok {
+		dst = image.NewRGBA64(size)
+	} else {
+		dst = image.NewRGBA(size)
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := img.At(bounds.Min.X+x, bounds.Min.Y+y)
+			switch degrees {
+			case 90:
+				dst.Set(h-1-y, x, c)
+			case 180:
+				dst.Set(w-1-x, h-1-y, c)
+			default:
+				dst.Set(y, w-1-x, c)
+			}
+		}
+	}
+	return dst
+}
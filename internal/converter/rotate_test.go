@@ -0,0 +1,115 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gen2brain/avif"
+)
+
+// ==================== Rotate Tests ====================
+
+// markedImage returns a 3x2 black image with a red top-left pixel
+func markedImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	return img
+}
+
+func TestRotate(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	tests := []struct {
+		degrees int
+		size    image.Point
+		marker  image.Point
+	}{
+		{90, image.Pt(2, 3), image.Pt(1, 0)},
+		{180, image.Pt(3, 2), image.Pt(2, 1)},
+		{270, image.Pt(2, 3), image.Pt(0, 2)},
+	}
+
+	for _, tt := range tests {
+		rotated := rotate(markedImage(), tt.degrees)
+		if got := rotated.Bounds().Size(); got != tt.size {
+			t.Errorf("%d: expected size %v, got %v", tt.degrees, tt.size, got)
+			continue
+		}
+		if got := rotated.At(tt.marker.X, tt.marker.Y); got != red {
+			t.Errorf("%d: expected the marker at %v, got %v there", tt.degrees, tt.marker, got)
+		}
+	}
+}
+
+func TestRotate_Unchanged(t *testing.T) {
+	img := markedImage()
+	if got := rotate(img, 0); got != image.Image(img) {
+		t.Error("expected no rotation to return the image as is")
+	}
+}
+
+func TestRotate_KeepsHighBitDepth(t *testing.T) {
+	img := image.NewRGBA64(image.Rect(0, 0, 2, 1))
+	img.SetRGBA64(0, 0, color.RGBA64{0x1234, 0, 0, 0xffff})
+
+	rotated, ok := rotate(img, 90).(*image.RGBA64)
+	if !ok {
+		t.Fatalf("expected a 16-bit image, got: %T", rotate(img, 90))
+	}
+	if got := rotated.RGBA64At(0, 0).R; got != 0x1234 {
+		t.Errorf("expected red 0x1234, got: %#x", got)
+	}
+}
+
+func TestIsValidRotation(t *testing.T) {
+	for _, degrees := range []int{0, 90, 180, 270} {
+		if !IsValidRotation(degrees) {
+			t.Errorf("expected %d to be valid", degrees)
+		}
+	}
+	for _, degrees := range []int{-90, 45, 360} {
+		if IsValidRotation(degrees) {
+			t.Errorf("expected %d to be invalid", degrees)
+		}
+	}
+}
+
+func TestConvert_Rotate(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	file, err := os.Create(inputPath)
+	if err != nil {
+		t.Fatalf("failed to create test AVIF file: %v", err)
+	}
+	err = avif.Encode(file, solidImage(16, 8, color.NRGBA{0, 0, 255, 255}))
+	file.Close()
+	if err != nil {
+		t.Fatalf("failed to encode test AVIF: %v", err)
+	}
+
+	if _, err := Convert(inputPath, outputDir, Options{Rotate: 90, Verify: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	file, err = os.Open(filepath.Join(outputDir, "test.png"))
+	if err != nil {
+		t.Fatalf("failed to open output: %v", err)
+	}
+	defer file.Close()
+	config, err := png.DecodeConfig(file)
+	if err != nil {
+		t.Fatalf("expected a valid PNG, got: %v", err)
+	}
+	if config.Width != 8 || config.Height != 16 {
+		t.Errorf("expected an 8x16 output, got %dx%d", config.Width, config.Height)
+	}
+}
@@ -0,0 +1,90 @@
+package converter
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// invalidNameChars are the characters Windows does not allow in file names;
+// control characters are not allowed either
+const invalidNameChars = `<>:"/\|?*`
+
+// reservedNames are the device names Windows reserves, with or without an
+// extension, in any case
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizeName returns a file name that is valid on every common operating
+// system: invalid and control characters become "_", trailing dots and
+// spaces are dropped, and reserved names such as "CON" get a trailing "_"
+// The extension, if any, is kept apart so it is never mangled
+func sanitizeName(name string) string {
+	ext := filepath.Ext(name)
+	stem := strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(invalidNameChars, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSuffix(name, ext))
+
+	stem = strings.TrimRight(stem, ". ")
+	if stem == "" {
+		stem = "_"
+	}
+
+	// "con.tar" is as reserved as "con"
+	device, _, _ := strings.Cut(stem, ".")
+	if reservedNames[strings.ToUpper(strings.TrimRight(device, " "))] {
+		stem = device + "_" + strings.TrimPrefix(stem, device)
+	}
+	return stem + ext
+}
+
+// sanitizeOutputPath applies sanitizeName to the file name of outputPath,
+// leaving its directory alone
+func sanitizeOutputPath(outputPath string) string {
+	dir, name := filepath.Split(outputPath)
+	return dir + sanitizeName(name)
+}
+
+// spaceRuns matches the runs of spaces replaced by Options.ReplaceSpaces
+var spaceRuns = regexp.MustCompile(` +`)
+
+// ValidateSpaceReplacement checks that the replacement of spaces in output
+// names is not empty and keeps them valid names: no spaces, path
+// separators, or characters that sanitizeName would replace
+func ValidateSpaceReplacement(replacement string) error {
+	if replacement == "" || sanitizeName(replacement) != replacement || strings.ContainsAny(replacement, " .") {
+		return fmt.Errorf("invalid space replacement %q: use characters allowed in file names, such as - or _", replacement)
+	}
+	return nil
+}
+
+// replaceSpaces replaces each run of spaces in the file name of outputPath
+// with replacement, e.g. "my  photo.png" becomes "my-photo.png", leaving
+// its directory and extension alone
+func replaceSpaces(outputPath, replacement string) string {
+	dir, name := filepath.Split(outputPath)
+	ext := filepath.Ext(name)
+	return dir + spaceRuns.ReplaceAllLiteralString(strings.TrimSuffix(name, ext), replacement) + ext
+}
+
+// cleanOutputPath applies the renaming options of opts to the file name of
+// outputPath: SanitizeNames, then ReplaceSpaces, so trailing spaces are
+// dropped rather than replaced
+func cleanOutputPath(outputPath string, opts Options) string {
+	if opts.SanitizeNames {
+		outputPath = sanitizeOutputPath(outputPath)
+	}
+	if opts.ReplaceSpaces != "" {
+		outputPath = replaceSpaces(outputPath, opts.ReplaceSpaces)
+	}
+	return outputPath
+}
@@ -0,0 +1,128 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ==================== Sanitize Names Tests ====================
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"photo.png", "photo.png"},
+		{"what?.png", "what_.png"},
+		{"a<b>c:d|e*.png", "a_b_c_d_e_.png"},
+		{"tab\there\x7f.png", "tab_here_.png"},
+		{"trailing. .png", "trailing.png"},
+		{"CON.png", "CON_.png"},
+		{"nul.png", "nul_.png"},
+		{"com1.backup.png", "com1_.backup.png"},
+		{"console.png", "console.png"},
+		{"....png", "_.png"},
+		{"naïve café.png", "naïve café.png"},
+	}
+
+	for _, tt := range tests {
+		if got := sanitizeName(tt.name); got != tt.want {
+			t.Errorf("sanitizeName(%q): expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestSanitizeOutputPath_KeepsDirectory(t *testing.T) {
+	got := sanitizeOutputPath(filepath.Join("out", "a:b", "aux.png"))
+	if want := filepath.Join("out", "a:b", "aux_.png"); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestConvert_SanitizeNames(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "aux.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	if _, err := Convert(inputPath, outputDir, Options{SanitizeNames: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "aux_.png")); err != nil {
+		t.Errorf("expected a sanitized output name, got: %v", err)
+	}
+
+	// The sanitized name is what an existing output is looked up by
+	status, err := Convert(inputPath, outputDir, Options{SanitizeNames: true})
+	if err != nil || status != StatusSkipped {
+		t.Errorf("expected the existing output to be skipped, got %s and %v", status, err)
+	}
+}
+
+// ==================== Replace Spaces Tests ====================
+
+func TestReplaceSpaces(t *testing.T) {
+	tests := []struct {
+		path, replacement, want string
+	}{
+		{"my photo.png", "-", "my-photo.png"},
+		{"my   summer photo.png", "_", "my_summer_photo.png"},
+		{" leading.png", "-", "-leading.png"},
+		{"no-spaces.png", "-", "no-spaces.png"},
+		{filepath.Join("my dir", "a b.png"), "-", filepath.Join("my dir", "a-b.png")},
+	}
+
+	for _, tt := range tests {
+		if got := replaceSpaces(tt.path, tt.replacement); got != tt.want {
+			t.Errorf("replaceSpaces(%q, %q): expected %q, got %q", tt.path, tt.replacement, tt.want, got)
+		}
+	}
+}
+
+func TestCleanOutputPath_SanitizesFirst(t *testing.T) {
+	// Trailing spaces are dropped by sanitizing before they can be replaced
+	got := cleanOutputPath("what? is this .png", Options{SanitizeNames: true, ReplaceSpaces: "-"})
+	if want := "what_-is-this.png"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if got := cleanOutputPath("a b.png", Options{}); got != "a b.png" {
+		t.Errorf("expected the name to be left alone, got %q", got)
+	}
+}
+
+func TestValidateSpaceReplacement(t *testing.T) {
+	for _, replacement := range []string{"-", "_", "--", "+"} {
+		if err := ValidateSpaceReplacement(replacement); err != nil {
+			t.Errorf("expected %q to be valid, got: %v", replacement, err)
+		}
+	}
+	for _, replacement := range []string{"", " ", "/", ":", ".", "a\tb"} {
+		if err := ValidateSpaceReplacement(replacement); err == nil {
+			t.Errorf("expected %q to be invalid", replacement)
+		}
+	}
+}
+
+func TestConvert_ReplaceSpaces(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "my test image.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	if _, err := Convert(inputPath, outputDir, Options{ReplaceSpaces: "-"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "my-test-image.png")); err != nil {
+		t.Errorf("expected spaces to be replaced, got: %v", err)
+	}
+
+	status, err := Convert(inputPath, outputDir, Options{ReplaceSpaces: "-"})
+	if err != nil || status != StatusSkipped {
+		t.Errorf("expected the existing output to be skipped, got %s and %v", status, err)
+	}
+}
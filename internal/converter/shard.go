@@ -0,0 +1,68 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Ways of choosing the shard directories of outputs, see Options.Shard
+const (
+	// ShardName shards outputs by the first characters of their name
+	ShardName = "name"
+	// ShardHash shards outputs by the first characters of the SHA-256 of
+	// their name, which spreads them evenly whatever the names look like
+	ShardHash = "hash"
+)
+
+// DefaultShardWidth is the number of characters of each shard directory
+const DefaultShardWidth = 2
+
+// shardPadding completes shard directories of names too short to fill them,
+// and replaces characters other than letters and digits
+const shardPadding = '_'
+
+// IsValidShard reports whether mode is a mode for Options.Shard; empty
+// means no sharding
+func IsValidShard(mode string) bool {
+	return mode == "" || mode == ShardName || mode == ShardHash
+}
+
+// shardedOutputPath returns outputPath moved into the shard directories of
+// opts, e.g. "out/ab/abcdef.png" for "out/abcdef.png" by name
+// Outputs that only differ in extension share their shard
+func shardedOutputPath(outputPath string, opts Options) string {
+	if opts.Shard == "" {
+		return outputPath
+	}
+	width, depth := opts.ShardWidth, opts.ShardDepth
+	if width <= 0 {
+		width = DefaultShardWidth
+	}
+	if depth <= 0 {
+		depth = 1
+	}
+
+	base := filepath.Base(outputPath)
+	key := strings.ToLower(strings.TrimSuffix(base, filepath.Ext(base)))
+	if opts.Shard == ShardHash {
+		sum := sha256.Sum256([]byte(key))
+		key = hex.EncodeToString(sum[:])
+	}
+
+	runes := []rune(key)
+	dirs := make([]string, depth)
+	for i := range dirs {
+		shard := make([]rune, width)
+		for j := range shard {
+			shard[j] = shardPadding
+			if n := i*width + j; n < len(runes) && (unicode.IsLetter(runes[n]) || unicode.IsDigit(runes[n])) {
+				shard[j] = runes[n]
+			}
+		}
+		dirs[i] = string(shard)
+	}
+	return filepath.Join(filepath.Dir(outputPath), filepath.Join(dirs...), base)
+}
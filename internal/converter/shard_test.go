@@ -0,0 +1,74 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ==================== Shard Tests ====================
+
+func TestShardedOutputPath(t *testing.T) {
+	out := filepath.Join("out", "sub")
+	tests := []struct {
+		name  string
+		opts  Options
+		input string
+		want  string
+	}{
+		{"off", Options{}, "abcdef.png", "abcdef.png"},
+		{"name", Options{Shard: ShardName}, "abcdef.png", "ab/abcdef.png"},
+		{"lowercase", Options{Shard: ShardName}, "ABcdef.png", "ab/ABcdef.png"},
+		{"depth", Options{Shard: ShardName, ShardWidth: 1, ShardDepth: 3}, "abcdef.png", "a/b/c/abcdef.png"},
+		{"short name", Options{Shard: ShardName, ShardWidth: 3}, "a.png", "a__/a.png"},
+		{"punctuation", Options{Shard: ShardName}, ".hidden.png", "_h/.hidden.png"},
+		{"hash", Options{Shard: ShardHash}, "abcdef.png", "be/abcdef.png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := shardedOutputPath(filepath.Join(out, tt.input), tt.opts)
+			if want := filepath.Join(out, filepath.FromSlash(tt.want)); got != want {
+				t.Errorf("expected %s, got %s", want, got)
+			}
+		})
+	}
+
+	// Outputs of the same name share their shard whatever their format
+	png := shardedOutputPath("img.png", Options{Shard: ShardHash})
+	jpeg := shardedOutputPath("img.jpeg", Options{Shard: ShardHash})
+	if filepath.Dir(png) != filepath.Dir(jpeg) {
+		t.Errorf("expected the same shard, got %s and %s", png, jpeg)
+	}
+}
+
+func TestConvertDirectory_Shard(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	os.MkdirAll(inputDir, 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "photo.avif"))
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{Shard: ShardName})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := filepath.Join(outputDir, "ph", "photo.png")
+	if len(result.Files) != 1 || result.Files[0].OutputPath != want {
+		t.Fatalf("expected the output in its shard, got: %+v", result.Files)
+	}
+	if _, err := os.Stat(want); err != nil {
+		t.Errorf("expected %s to exist, got: %v", want, err)
+	}
+
+	// Existing outputs are found in their shard
+	result, err = ConvertDirectory(inputDir, outputDir, Options{Shard: ShardName})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Skipped() != 1 {
+		t.Errorf("expected the existing output to be skipped, got %d skipped", result.Skipped())
+	}
+}
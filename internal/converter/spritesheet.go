@@ -0,0 +1,239 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultSpriteSheetWidth is the width in pixels a sprite sheet wraps at by
+// default
+const DefaultSpriteSheetWidth = 2048
+
+// Sprite is the region of a sprite sheet holding one image
+type Sprite struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// SpriteSheet is the metadata written next to a sprite sheet: the name of
+// the image, its size, and the sprite of each input by its slash-separated
+// path relative to the input directory, e.g. "icons/play.avif"
+type SpriteSheet struct {
+	Image   string            `json:"image"`
+	Width   int               `json:"width"`
+	Height  int               `json:"height"`
+	Sprites map[string]Sprite `json:"sprites"`
+}
+
+// SpriteSheetMetadataPath returns the path of the metadata of the sprite
+// sheet at sheetPath, the same path with a .json extension
+func SpriteSheetMetadataPath(sheetPath string) string {
+	return strings.TrimSuffix(sheetPath, filepath.Ext(sheetPath)) + ".json"
+}
+
+// packShelves places rectangles of the given sizes in rows from left to
+// right, starting a new row below the tallest of the current one when the
+// next rectangle would go past width
+// Sizes are placed tallest first, which wastes less space, in their order
+// otherwise; a rectangle wider than width gets a row of its own, and the
+// returned size of the sheet then grows to fit it
+func packShelves(sizes []image.Point, width int) ([]image.Point, image.Point) {
+	order := make([]int, len(sizes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return sizes[order[a]].Y > sizes[order[b]].Y
+	})
+
+	positions := make([]image.Point, len(sizes))
+	var sheet image.Point
+	x, y, rowHeight := 0, 0, 0
+	for _, i := range order {
+		size := sizes[i]
+		if x > 0 && x+size.X > width {
+			x, y, rowHeight = 0, y+rowHeight, 0
+		}
+		positions[i] = image.Pt(x, y)
+		x += size.X
+		rowHeight = max(rowHeight, size.Y)
+		sheet.X = max(sheet.X, x)
+		sheet.Y = max(sheet.Y, y+rowHeight)
+	}
+	return positions, sheet
+}
+
+// ConvertDirectoryToSpriteSheet packs all AVIF files in a directory into a
+// single PNG at sheetPath, wrapping into a new row at width pixels
+// (DefaultSpriteSheetWidth when 0), and writes the SpriteSheet of where
+// each image went to SpriteSheetMetadataPath(sheetPath)
+// Every image is held in memory until the sheet is drawn; opts.Format,
+// opts.Quality and the per-file output options do not apply
+// Like ConvertDirectoryToPDF, it stops once ctx is cancelled; the images
+// decoded so far are still packed, and the result is never nil
+func ConvertDirectoryToSpriteSheet(ctx context.Context, inputDir, sheetPath string, width int, opts Options) (*ConversionResult, error) {
+	result := &ConversionResult{
+		SkippedReasons:  map[string]int{},
+		FilteredReasons: map[string]int{},
+		Errors:          []FileError{},
+		Files:           []FileResult{},
+	}
+	if width <= 0 {
+		width = DefaultSpriteSheetWidth
+	}
+
+	// Packing follows the order of the scan, so it must be sequential
+	opts = opts.withDefaults()
+	opts.ScanWorkers = 0
+	var avifFiles []string
+	err := walkAVIFFiles(inputDir, opts, func(path string) error {
+		avifFiles = append(avifFiles, path)
+		return nil
+	}, func(reason string) {
+		result.FilteredReasons[reason]++
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to scan directory: %w", err)
+	}
+
+	result.TotalFiles = len(avifFiles)
+	result.total.Store(int64(result.TotalFiles))
+
+	if result.TotalFiles == 0 {
+		return result, nil
+	}
+
+	metadataPath := SpriteSheetMetadataPath(sheetPath)
+	for _, path := range []string{sheetPath, metadataPath} {
+		if _, err := opts.Output.Stat(path); err == nil && !opts.Overwrite {
+			return result, ErrFileExists
+		}
+	}
+
+	if opts.Verbose {
+		opts.logf("📂 Processing directory: %s\n", inputDir)
+		opts.logf("📊 Found %d AVIF file(s)\n", result.TotalFiles)
+	}
+
+	var images []image.Image
+	var names []string
+	var cancelErr error
+	for i, filePath := range avifFiles {
+		if cancelErr = ctx.Err(); cancelErr != nil {
+			break
+		}
+
+		if opts.Verbose {
+			opts.logf("  %s Adding %s... ", result.progressLabel(i+1, int64(result.TotalFiles)), filepath.Base(filePath))
+		}
+
+		fileResult := FileResult{InputPath: filePath, OutputPath: sheetPath}
+
+		start := time.Now()
+		img, info, err := decodeInput(filePath, opts)
+		if err != nil {
+			err = explainDecodeError(source{path: filePath}, err)
+		} else {
+			img = fitCanvas(rotate(cropPercent(img, opts.CropPct), opts.Rotate), opts)
+			fileResult.InputSize = info.Size()
+			fileResult.Width, fileResult.Height = img.Bounds().Dx(), img.Bounds().Dy()
+		}
+		fileResult.Duration = time.Since(start)
+
+		if reason := nonImageSkipReason(err); opts.SkipNonImages && reason != "" {
+			result.addSkip(reason)
+			fileResult.Status, fileResult.SkipReason = StatusSkipped, reason
+			if opts.Verbose {
+				opts.logf("⚠️  Skipped (%s)\n", reason)
+			}
+		} else if err != nil {
+			result.Failed++
+			if errors.Is(err, ErrEmptyFile) {
+				result.Empty++
+			}
+			fileResult.Status = StatusFailed
+			fileResult.Error = err
+			result.Errors = append(result.Errors, FileError{FilePath: filePath, Error: err})
+			if opts.Verbose {
+				opts.logf("❌ Failed: %v\n", err)
+			}
+		} else {
+			result.Successful++
+			fileResult.Status = StatusConverted
+			images = append(images, img)
+			name, relErr := filepath.Rel(inputDir, filePath)
+			if relErr != nil {
+				name = filepath.Base(filePath)
+			}
+			names = append(names, filepath.ToSlash(name))
+			if opts.Verbose {
+				opts.logf("✅\n")
+			}
+		}
+
+		result.Files = append(result.Files, fileResult)
+		result.recordDuration(fileResult.Duration)
+		result.processed.Add(1)
+	}
+
+	// An empty sheet is not worth writing
+	if len(images) == 0 {
+		return result, cancelErr
+	}
+
+	sizes := make([]image.Point, len(images))
+	for i, img := range images {
+		sizes[i] = img.Bounds().Size()
+	}
+	positions, size := packShelves(sizes, width)
+
+	sheet := image.NewNRGBA(image.Rectangle{Max: size})
+	metadata := SpriteSheet{
+		Image:   filepath.Base(sheetPath),
+		Width:   size.X,
+		Height:  size.Y,
+		Sprites: make(map[string]Sprite, len(images)),
+	}
+	for i, img := range images {
+		r := image.Rectangle{Min: positions[i], Max: positions[i].Add(sizes[i])}
+		draw.Draw(sheet, r, img, img.Bounds().Min, draw.Src)
+		metadata.Sprites[names[i]] = Sprite{X: r.Min.X, Y: r.Min.Y, W: r.Dx(), H: r.Dy()}
+	}
+
+	if err := opts.Output.MkdirAll(filepath.Dir(sheetPath)); err != nil {
+		return result, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := encodePNG(buf, sheet, opts.encodeOptions()); err != nil {
+		return result, fmt.Errorf("failed to encode sprite sheet: %w", err)
+	}
+	if err := writeFile(opts.Output, sheetPath, buf.Bytes()); err != nil {
+		return result, fmt.Errorf("failed to write sprite sheet: %w", err)
+	}
+
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return result, fmt.Errorf("failed to encode sprite sheet metadata: %w", err)
+	}
+	if err := writeFile(opts.Output, metadataPath, append(data, '\n')); err != nil {
+		return result, fmt.Errorf("failed to write sprite sheet metadata: %w", err)
+	}
+
+	if opts.Verbose {
+		opts.logf("✅ Saved: %s (%dx%d) and %s\n", sheetPath, size.X, size.Y, metadataPath)
+	}
+
+	return result, cancelErr
+}
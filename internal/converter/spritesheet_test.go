@@ -0,0 +1,97 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ==================== SpriteSheet Tests ====================
+
+func TestPackShelves(t *testing.T) {
+	sizes := []image.Point{{10, 5}, {20, 10}, {30, 8}, {50, 4}}
+	positions, size := packShelves(sizes, 40)
+
+	// Tallest first: 30x8 does not fit after 20x10 and wraps, 10x5 follows
+	// it, and 50x4 is wider than the sheet so it gets a row of its own
+	want := []image.Point{{30, 10}, {0, 0}, {0, 10}, {0, 18}}
+	for i := range want {
+		if positions[i] != want[i] {
+			t.Errorf("expected sprite %d at %v, got %v", i, want[i], positions[i])
+		}
+	}
+	if size != image.Pt(50, 22) {
+		t.Errorf("expected a 50x22 sheet, got %v", size)
+	}
+
+	if _, size := packShelves(nil, 40); size != (image.Point{}) {
+		t.Errorf("expected an empty sheet, got %v", size)
+	}
+}
+
+func TestSpriteSheetMetadataPath(t *testing.T) {
+	if got := SpriteSheetMetadataPath(filepath.Join("out", "sheet.png")); got != filepath.Join("out", "sheet.json") {
+		t.Errorf("expected sheet.json, got %s", got)
+	}
+}
+
+func TestConvertDirectoryToSpriteSheet(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	sheetPath := filepath.Join(testDir, "out", "sheet.png")
+	if err := os.MkdirAll(filepath.Join(inputDir, "icons"), 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "icons", "b.avif"))
+	createTestAVIF(t, filepath.Join(inputDir, "c.avif"))
+	if err := os.WriteFile(filepath.Join(inputDir, "bad.avif"), []byte("not a valid avif file"), 0644); err != nil {
+		t.Fatalf("failed to create invalid file: %v", err)
+	}
+
+	result, err := ConvertDirectoryToSpriteSheet(context.Background(), inputDir, sheetPath, 25, Options{Recursive: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Successful != 3 || result.Failed != 1 || result.Progress() != 1 {
+		t.Errorf("expected 3 sprites and 1 failure, got %d and %d", result.Successful, result.Failed)
+	}
+
+	file, err := os.Open(sheetPath)
+	if err != nil {
+		t.Fatalf("expected sprite sheet to exist: %v", err)
+	}
+	config, err := png.DecodeConfig(file)
+	file.Close()
+	if err != nil {
+		t.Fatalf("expected a valid PNG, got: %v", err)
+	}
+	if config.Width != 20 || config.Height != 20 {
+		t.Errorf("expected two rows of 10x10 sprites in a 20x20 sheet, got %dx%d", config.Width, config.Height)
+	}
+
+	data, err := os.ReadFile(filepath.Join(testDir, "out", "sheet.json"))
+	if err != nil {
+		t.Fatalf("expected metadata to exist: %v", err)
+	}
+	var sheet SpriteSheet
+	if err := json.Unmarshal(data, &sheet); err != nil {
+		t.Fatalf("expected valid JSON, got: %v", err)
+	}
+	if sheet.Image != "sheet.png" || sheet.Width != 20 || sheet.Height != 20 || len(sheet.Sprites) != 3 {
+		t.Errorf("unexpected metadata: %+v", sheet)
+	}
+	if got := sheet.Sprites["icons/b.avif"]; got != (Sprite{X: 0, Y: 10, W: 10, H: 10}) {
+		t.Errorf("expected icons/b.avif on the second row, got %+v", got)
+	}
+
+	if _, err := ConvertDirectoryToSpriteSheet(context.Background(), inputDir, sheetPath, 25, Options{Recursive: true}); err != ErrFileExists {
+		t.Errorf("expected ErrFileExists for an existing sheet, got: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package converter
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrUnsafeEntry is returned for archive entries whose name would place the
+// output outside the output directory, such as "../x.avif" or "/x.avif"
+var ErrUnsafeEntry = errors.New("unsafe archive entry name")
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// ConvertTar converts the AVIF entries of a tar archive read from r, which
+// may be gzip-compressed, without extracting it to disk
+// Each entry is decoded in memory and written under outputDir with the
+// directories of its entry name, e.g. "a/b.avif" becomes "outputDir/a/b.png",
+// unless opts.FlattenSeparator joins them into the name
+// Entries are selected like the files of a directory scan, by extension,
+// hidden name and opts.Since; the result is never nil, as for ConvertDirectory
+func ConvertTar(ctx context.Context, r io.Reader, outputDir string, opts Options) (*ConversionResult, error) {
+	result := &ConversionResult{
+		Errors:          []FileError{},
+		Files:           []FileResult{},
+		SkippedReasons:  map[string]int{},
+		FilteredReasons: map[string]int{},
+	}
+
+	opts = opts.withDefaults()
+	if _, err := lookupEncoder(opts.Format); err != nil {
+		return result, err
+	}
+	if err := opts.validate(); err != nil {
+		return result, err
+	}
+	if err := checkOutputDir(opts.Output, outputDir); err != nil {
+		return result, err
+	}
+	opts.outputBase = outputDir
+
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(gzipMagic)); string(magic) == string(gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return result, fmt.Errorf("failed to read gzip stream: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	if opts.TrackResources {
+		stopTracking := trackResources()
+		defer func() { result.Resources = stopTracking() }()
+	}
+
+	// Per-file progress is reported here, not by convertFile
+	fileOpts := opts
+	fileOpts.Verbose = false
+	now := time.Now()
+
+	tr := tar.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		header, err := tr.Next()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to read tar archive: %w", err)
+		}
+
+		if header.Typeflag != tar.TypeReg || !isInputName(header.Name, opts) {
+			continue
+		}
+		if reason := hiddenFilter(path.Base(header.Name), opts); reason != "" {
+			result.FilteredReasons[reason]++
+			continue
+		}
+		if !opts.Since.IsZero() && header.ModTime.Before(opts.Since) {
+			result.FilteredReasons[FilterReasonSince]++
+			continue
+		}
+
+		result.TotalFiles++
+		result.total.Add(1)
+		// The total is the number of entries found so far, as the archive is
+		// read while it is converted
+		if opts.Verbose {
+			opts.logf("  %s Converting %s... ", result.progressLabel(result.TotalFiles, result.total.Load()), header.Name)
+		}
+
+		name := strings.TrimPrefix(header.Name, "./")
+		fileResult := FileResult{InputPath: name, InputSize: header.Size}
+		if !fs.ValidPath(name) {
+			recordEntryError(result, fileResult, fmt.Errorf("%w: %s", ErrUnsafeEntry, header.Name), opts)
+			if err := stopsAfterLast(result, opts); err != nil {
+				return result, err
+			}
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return result, fmt.Errorf("failed to read %s from tar archive: %w", header.Name, err)
+		}
+
+		entryDir := outputDir
+		if opts.OutputTemplate != "" {
+			t := header.ModTime
+			if opts.OutputTemplateNow {
+				t = now
+			}
+			entryDir = filepath.Join(entryDir, filepath.FromSlash(expandOutputTemplate(opts.OutputTemplate, t)))
+		}
+		if opts.FlattenSeparator != "" {
+			fileResult.OutputPath = directoryOutputPath(".", filepath.FromSlash(name), entryDir, opts)
+		} else {
+			entryDir = filepath.Join(entryDir, filepath.FromSlash(path.Dir(name)))
+			fileResult.OutputPath = outputPathFor(path.Base(name), entryDir, opts.Format)
+		}
+
+		src := source{path: name, data: data, modTime: header.ModTime}
+		if decision := convertInto(result, src, fileResult, &fileOpts, opts.Verbose); decision == OverwriteQuit {
+			return result, ErrAborted
+		}
+		if err := stopsAfterLast(result, opts); err != nil {
+			return result, err
+		}
+	}
+}
+
+// recordEntryError records an archive entry that could not be converted
+func recordEntryError(result *ConversionResult, fileResult FileResult, err error, opts Options) {
+	result.Failed++
+	fileResult.Status = StatusFailed
+	fileResult.Error = err
+	result.Errors = append(result.Errors, FileError{FilePath: fileResult.InputPath, Error: err})
+	result.Files = append(result.Files, fileResult)
+	result.processed.Add(1)
+	if opts.Verbose {
+		opts.logf("❌ Failed: %v\n", err)
+	}
+}
@@ -0,0 +1,193 @@
+package converter
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// ==================== ConvertTar Tests ====================
+
+// buildTar returns a tar archive holding the given entries, gzipped if asked
+func buildTar(t *testing.T, entries map[string][]byte, gzipped bool) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var gz *gzip.Writer
+	var tw *tar.Writer
+	if gzipped {
+		gz = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gz)
+	} else {
+		tw = tar.NewWriter(&buf)
+	}
+
+	for name, data := range entries {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("failed to write tar header: %v", err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatalf("failed to write tar entry: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar writer: %v", err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			t.Fatalf("failed to close gzip writer: %v", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// testAVIFData returns the contents of a small AVIF file
+func testAVIFData(t *testing.T, dir string) []byte {
+	t.Helper()
+	path := filepath.Join(dir, "source.avif")
+	createTestAVIF(t, path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read test AVIF: %v", err)
+	}
+	return data
+}
+
+func TestConvertTar_PreservesEntryNames(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	data := testAVIFData(t, testDir)
+	outputDir := filepath.Join(testDir, "output")
+
+	for _, gzipped := range []bool{false, true} {
+		archive := buildTar(t, map[string][]byte{
+			"top.avif":           data,
+			"./album/inner.avif": data,
+			"notes.txt":          []byte("not an image"),
+			".hidden.avif":       data,
+		}, gzipped)
+
+		out := filepath.Join(outputDir, map[bool]string{false: "tar", true: "tgz"}[gzipped])
+		result, err := ConvertTar(context.Background(), bytes.NewReader(archive), out, Options{PreserveMtime: true})
+		if err != nil {
+			t.Fatalf("gzip %v: expected no error, got: %v", gzipped, err)
+		}
+
+		if result.TotalFiles != 2 || result.Successful != 2 {
+			t.Fatalf("gzip %v: expected 2 converted entries, got: %d of %d (%v)", gzipped, result.Successful, result.TotalFiles, result.Errors)
+		}
+		if result.FilteredReasons[FilterReasonHidden] != 1 {
+			t.Errorf("gzip %v: expected the hidden entry to be filtered, got: %v", gzipped, result.FilteredReasons)
+		}
+		for _, name := range []string{"top.png", filepath.Join("album", "inner.png")} {
+			info, err := os.Stat(filepath.Join(out, name))
+			if err != nil {
+				t.Errorf("gzip %v: expected %s to be written: %v", gzipped, name, err)
+				continue
+			}
+			if !info.ModTime().Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+				t.Errorf("gzip %v: expected %s to keep the entry time, got: %v", gzipped, name, info.ModTime())
+			}
+		}
+	}
+}
+
+func TestConvertTar_Flatten(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	archive := buildTar(t, map[string][]byte{"a/b/c.avif": testAVIFData(t, testDir)}, false)
+	outputDir := filepath.Join(testDir, "output")
+
+	result, err := ConvertTar(context.Background(), bytes.NewReader(archive), outputDir, Options{FlattenSeparator: "_"})
+	if err != nil || result.Successful != 1 {
+		t.Fatalf("expected 1 conversion, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "a_b_c.png")); err != nil {
+		t.Errorf("expected a flattened output name: %v", err)
+	}
+}
+
+func TestConvertTar_OutputTemplateOutsideOutput(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	archive := buildTar(t, map[string][]byte{"a.avif": testAVIFData(t, testDir)}, false)
+	outputDir := filepath.Join(testDir, "output")
+
+	_, err := ConvertTar(context.Background(), bytes.NewReader(archive), outputDir, Options{OutputTemplate: "../esc"})
+	if err == nil {
+		t.Fatal("expected error for a template outside the output directory, got nil")
+	}
+	if _, err := os.Stat(filepath.Join(testDir, "esc")); !os.IsNotExist(err) {
+		t.Error("expected nothing to be written outside the output directory")
+	}
+}
+
+func TestConvertTar_UnsafeAndBrokenEntries(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	archive := buildTar(t, map[string][]byte{
+		"../escape.avif": testAVIFData(t, testDir),
+		"broken.avif":    []byte("this is not an AVIF file"),
+	}, false)
+	outputDir := filepath.Join(testDir, "output")
+
+	result, err := ConvertTar(context.Background(), bytes.NewReader(archive), outputDir, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Failed != 2 || len(result.Errors) != 2 {
+		t.Fatalf("expected 2 failed entries, got: %d", result.Failed)
+	}
+
+	unsafe := false
+	for _, fileErr := range result.Errors {
+		if errors.Is(fileErr.Error, ErrUnsafeEntry) {
+			unsafe = true
+		}
+	}
+	if !unsafe {
+		t.Errorf("expected ErrUnsafeEntry for ../escape.avif, got: %v", result.Errors)
+	}
+	if _, err := os.Stat(filepath.Join(testDir, "escape.png")); !os.IsNotExist(err) {
+		t.Error("expected nothing to be written outside the output directory")
+	}
+}
+
+func TestConvertTar_SkipsExistingOutputs(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	archive := buildTar(t, map[string][]byte{"image.avif": testAVIFData(t, testDir)}, false)
+	outputDir := filepath.Join(testDir, "output")
+
+	for _, want := range []FileStatus{StatusConverted, StatusSkipped} {
+		result, err := ConvertTar(context.Background(), bytes.NewReader(archive), outputDir, Options{})
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if len(result.Files) != 1 || result.Files[0].Status != want {
+			t.Errorf("expected status %s, got: %+v", want, result.Files)
+		}
+	}
+}
+
+func TestConvertTar_InvalidArchive(t *testing.T) {
+	result, err := ConvertTar(context.Background(), bytes.NewReader([]byte("definitely not a tar archive, but long enough to look like a header block")), t.TempDir(), Options{})
+	if err == nil {
+		t.Fatal("expected error for an invalid archive")
+	}
+	if result == nil {
+		t.Fatal("expected a result even on error")
+	}
+}
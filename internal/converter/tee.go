@@ -0,0 +1,88 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+)
+
+// errNoTeeBase is returned for Options.TeeDirs when the conversion has no
+// output directory that the copies mirror, as with ConvertMapped
+var errNoTeeBase = errors.New("tee directories require an output directory to mirror")
+
+// teePaths returns the paths that the copies of the output at outputPath go
+// to, the same path relative to each of opts.TeeDirs as outputPath is to
+// the output directory of the conversion
+func teePaths(outputPath string, opts Options) ([]string, error) {
+	if opts.outputBase == "" {
+		return nil, errNoTeeBase
+	}
+	if !withinDir(opts.outputBase, outputPath) {
+		return nil, fmt.Errorf("output %s is outside the output directory %s", outputPath, opts.outputBase)
+	}
+	rel, _ := filepath.Rel(opts.outputBase, outputPath)
+	paths := make([]string, len(opts.TeeDirs))
+	for i, dir := range opts.TeeDirs {
+		paths[i] = filepath.Join(dir, rel)
+	}
+	return paths, nil
+}
+
+// teeOutput copies the output at outputPath to each of opts.TeeDirs, so the
+// image is decoded and encoded once for all of them
+// Each directory is checked on its own: a copy that already exists is left
+// alone unless opts.Overwrite, whether or not the output itself was written
+// now; copies get modTime with opts.PreserveMtime, like the output
+func teeOutput(outputPath string, modTime time.Time, opts Options) error {
+	if len(opts.TeeDirs) == 0 {
+		return nil
+	}
+	paths, err := teePaths(outputPath, opts)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	for _, path := range paths {
+		if _, err := opts.Output.Stat(path); err == nil && !opts.Overwrite {
+			if opts.Verbose {
+				opts.logf("⏭️  Copy exists: %s\n", path)
+			}
+			continue
+		}
+
+		// The output is read once, after any hook has rewritten it
+		if data == nil {
+			if data, err = readOutput(opts.Output, outputPath); err != nil {
+				return fmt.Errorf("failed to read output for copies: %w", err)
+			}
+		}
+		if err := opts.Output.MkdirAll(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		if err := writeFile(opts.Output, path, data); err != nil {
+			return fmt.Errorf("failed to write copy: %w", err)
+		}
+		if opts.PreserveMtime {
+			if err := chtimes(opts.Output, path, modTime); err != nil {
+				return fmt.Errorf("failed to preserve modification time: %w", err)
+			}
+		}
+		if opts.Verbose {
+			opts.logf("📑 Copied to %s\n", path)
+		}
+	}
+	return nil
+}
+
+// readOutput reads the whole file at path from out
+func readOutput(out OutputFS, path string) ([]byte, error) {
+	file, err := out.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
@@ -0,0 +1,81 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ==================== Tee Tests ====================
+
+func TestConvertDirectory_TeeDirs(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	os.MkdirAll(filepath.Join(inputDir, "sub"), 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "sub", "test.avif"))
+
+	outputDir := filepath.Join(testDir, "output")
+	backup := filepath.Join(testDir, "backup")
+	mirror := filepath.Join(testDir, "mirror")
+	opts := Options{Recursive: true, FlattenSeparator: "_", TeeDirs: []string{backup, mirror}}
+	result, err := ConvertDirectory(inputDir, outputDir, opts)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Successful != 1 {
+		t.Fatalf("expected 1 converted file, got %+v", result)
+	}
+
+	output, err := os.ReadFile(filepath.Join(outputDir, "sub_test.png"))
+	if err != nil {
+		t.Fatalf("expected the output to be written, got: %v", err)
+	}
+	for _, dir := range opts.TeeDirs {
+		copied, err := os.ReadFile(filepath.Join(dir, "sub_test.png"))
+		if err != nil {
+			t.Fatalf("expected a copy in %s, got: %v", dir, err)
+		}
+		if !bytes.Equal(copied, output) {
+			t.Errorf("expected the copy in %s to match the output", dir)
+		}
+	}
+
+	// Each directory is checked on its own: the missing copy is made again
+	// and the existing one is left alone
+	os.Remove(filepath.Join(backup, "sub_test.png"))
+	os.WriteFile(filepath.Join(mirror, "sub_test.png"), []byte("stale"), 0644)
+	result, err = ConvertDirectory(inputDir, outputDir, opts)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.SkippedReasons[SkipReasonExists] != 1 {
+		t.Errorf("expected the output to be skipped as existing, got %+v", result.SkippedReasons)
+	}
+	if copied, _ := os.ReadFile(filepath.Join(backup, "sub_test.png")); !bytes.Equal(copied, output) {
+		t.Error("expected the missing copy to be written again")
+	}
+	if copied, _ := os.ReadFile(filepath.Join(mirror, "sub_test.png")); string(copied) != "stale" {
+		t.Error("expected the existing copy to be left alone")
+	}
+}
+
+func TestConvertMapped_TeeDirs(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	createTestAVIF(t, inputPath)
+
+	files := []MappedFile{{InputPath: inputPath, OutputPath: filepath.Join(testDir, "out", "test.png")}}
+	result, err := ConvertMapped(context.Background(), files, Options{TeeDirs: []string{filepath.Join(testDir, "backup")}})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Failed != 1 {
+		t.Errorf("expected the file to fail without an output directory to mirror, got %+v", result)
+	}
+}
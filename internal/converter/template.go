@@ -0,0 +1,63 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// templateToken matches a {token} in an output template
+var templateToken = regexp.MustCompile(`\{[^{}]*\}`)
+
+// templateTokens maps output template tokens to their time layouts
+var templateTokens = map[string]string{
+	"{yyyy}": "2006",
+	"{mm}":   "01",
+	"{dd}":   "02",
+}
+
+// ValidateOutputTemplate checks that a template only uses known tokens and
+// names a subdirectory of the output directory: no absolute path, and no
+// ".." component that would climb out of it
+func ValidateOutputTemplate(template string) error {
+	for _, token := range templateToken.FindAllString(template, -1) {
+		if _, ok := templateTokens[token]; !ok {
+			return fmt.Errorf("unknown output template token %s (supported: {yyyy}, {mm}, {dd})", token)
+		}
+	}
+	if template != "" && !filepath.IsLocal(filepath.FromSlash(expandOutputTemplate(template, time.Time{}))) {
+		return fmt.Errorf("output template %s must be a relative path within the output directory", template)
+	}
+	return nil
+}
+
+// expandOutputTemplate replaces the date tokens of a template with t
+func expandOutputTemplate(template string, t time.Time) string {
+	return templateToken.ReplaceAllStringFunc(template, func(token string) string {
+		if layout, ok := templateTokens[token]; ok {
+			return t.Format(layout)
+		}
+		return token
+	})
+}
+
+// templatedOutputDir returns the directory an input is written to when
+// opts.OutputTemplate is set, e.g. "output/2024/01" for "{yyyy}/{mm}"
+// Dates come from the input's modification time, or from now with
+// opts.OutputTemplateNow
+func templatedOutputDir(outputDir, inputPath string, now time.Time, opts Options) string {
+	if opts.OutputTemplate == "" {
+		return outputDir
+	}
+
+	t := now
+	if !opts.OutputTemplateNow {
+		if info, err := os.Stat(inputPath); err == nil {
+			t = info.ModTime()
+		}
+	}
+
+	return filepath.Join(outputDir, filepath.FromSlash(expandOutputTemplate(opts.OutputTemplate, t)))
+}
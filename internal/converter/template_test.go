@@ -0,0 +1,127 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// ==================== Output Template Tests ====================
+
+func TestValidateOutputTemplate(t *testing.T) {
+	for _, template := range []string{"", "{yyyy}/{mm}/{dd}", "photos-{yyyy}", "static"} {
+		if err := ValidateOutputTemplate(template); err != nil {
+			t.Errorf("expected %q to be valid, got: %v", template, err)
+		}
+	}
+
+	if err := ValidateOutputTemplate("{yyyy}/{hh}"); err == nil {
+		t.Error("expected error for unknown token, got nil")
+	}
+
+	// Templates stay within the output directory
+	for _, template := range []string{"../../esc", "{yyyy}/../../esc", "/tmp/{yyyy}", "a/../.."} {
+		if err := ValidateOutputTemplate(template); err == nil {
+			t.Errorf("expected error for %q, got nil", template)
+		}
+	}
+}
+
+func TestExpandOutputTemplate(t *testing.T) {
+	date := time.Date(2024, 1, 5, 10, 0, 0, 0, time.UTC)
+
+	if got := expandOutputTemplate("{yyyy}/{mm}/{dd}", date); got != "2024/01/05" {
+		t.Errorf("expected 2024/01/05, got: %s", got)
+	}
+	if got := expandOutputTemplate("shots-{yyyy}{mm}", date); got != "shots-202401" {
+		t.Errorf("expected shots-202401, got: %s", got)
+	}
+}
+
+func TestConvertDirectory_OutputTemplateFromMtime(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("failed to create input dir: %v", err)
+	}
+
+	dates := map[string]time.Time{
+		"old.avif": time.Date(2023, 12, 31, 12, 0, 0, 0, time.Local),
+		"new.avif": time.Date(2024, 1, 2, 12, 0, 0, 0, time.Local),
+	}
+	for name, date := range dates {
+		path := filepath.Join(inputDir, name)
+		createTestAVIF(t, path)
+		if err := os.Chtimes(path, date, date); err != nil {
+			t.Fatalf("failed to set mtime: %v", err)
+		}
+	}
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{OutputTemplate: "{yyyy}/{mm}"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Successful != 2 {
+		t.Fatalf("expected 2 successful conversions, got: %d", result.Successful)
+	}
+
+	for _, want := range []string{
+		filepath.Join(outputDir, "2023", "12", "old.png"),
+		filepath.Join(outputDir, "2024", "01", "new.png"),
+	} {
+		if _, err := os.Stat(want); err != nil {
+			t.Errorf("expected %s to exist: %v", want, err)
+		}
+	}
+}
+
+func TestConvert_OutputTemplateNow(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	old := time.Date(2001, 1, 1, 0, 0, 0, 0, time.Local)
+	if err := os.Chtimes(inputPath, old, old); err != nil {
+		t.Fatalf("failed to set mtime: %v", err)
+	}
+
+	before := time.Now()
+	if _, err := Convert(inputPath, outputDir, Options{OutputTemplate: "{yyyy}", OutputTemplateNow: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// Accept either year in case the test runs across New Year
+	found := false
+	for _, year := range []string{before.Format("2006"), time.Now().Format("2006")} {
+		if _, err := os.Stat(filepath.Join(outputDir, year, "test.png")); err == nil {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected output in the current year's directory")
+	}
+}
+
+func TestConvertDirectory_OutputTemplateOutsideOutput(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	os.MkdirAll(inputDir, 0755)
+	createTestAVIF(t, filepath.Join(inputDir, "a.avif"))
+
+	if _, err := ConvertDirectory(inputDir, outputDir, Options{OutputTemplate: "../esc"}); err == nil {
+		t.Fatal("expected error for a template outside the output directory, got nil")
+	}
+	if _, err := os.Stat(filepath.Join(testDir, "esc", "a.png")); !os.IsNotExist(err) {
+		t.Error("expected nothing to be written outside the output directory")
+	}
+}
@@ -0,0 +1,113 @@
+package converter
+
+import (
+	"avif2png/internal/isobmff"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/gen2brain/avif"
+)
+
+// ErrNoThumbnail is returned when opts.ThumbnailRequired is set and an input
+// has no embedded thumbnail
+var ErrNoThumbnail = errors.New("input has no embedded thumbnail")
+
+// decodeInput decodes the image of inputPath selected by opts: the embedded
+// thumbnail with opts.UseThumbnail, otherwise the primary image
+// Inputs without a thumbnail fall back to the primary image unless
+// opts.ThumbnailRequired is set
+func decodeInput(inputPath string, opts Options) (image.Image, os.FileInfo, error) {
+	if !opts.UseThumbnail {
+		return decodeFile(inputPath)
+	}
+
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to stat input file: %w", err)
+	}
+	if info.Size() < minAVIFSize {
+		return nil, nil, ErrEmptyFile
+	}
+
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+
+	img, err := decodeThumbnail(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if img != nil {
+		return img, info, nil
+	}
+
+	if opts.ThumbnailRequired {
+		return nil, nil, ErrNoThumbnail
+	}
+	return decodeFile(inputPath)
+}
+
+// decodeInputData is decodeInput for an AVIF file held in memory
+func decodeInputData(data []byte, opts Options) (image.Image, error) {
+	if len(data) < minAVIFSize {
+		return nil, ErrEmptyFile
+	}
+
+	if opts.UseThumbnail {
+		img, err := decodeThumbnail(data)
+		if err != nil {
+			return nil, err
+		}
+		if img != nil {
+			return img, nil
+		}
+		if opts.ThumbnailRequired {
+			return nil, ErrNoThumbnail
+		}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode AVIF image: %w", err)
+	}
+	return img, nil
+}
+
+// decodeThumbnail decodes the first thumbnail of the primary item in data
+// It returns a nil image if there is none
+// Thumbnails are decoded like auxiliary images, from a copy of the file whose
+// primary item points at them, so the full image is never decoded
+// The copy also drops the thumbnail references, which decoders would
+// otherwise use to skip the item
+func decodeThumbnail(data []byte) (image.Image, error) {
+	container, err := isobmff.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse AVIF container: %w", err)
+	}
+
+	for _, id := range container.ReferencesTo("thmb", container.PrimaryItemID) {
+		if container.Item(id) == nil {
+			continue
+		}
+
+		patched, err := container.WithPrimaryItem(data, id)
+		if err != nil {
+			return nil, err
+		}
+		if patched, err = container.WithoutReferences(patched, "thmb"); err != nil {
+			return nil, err
+		}
+
+		img, err := avif.Decode(bytes.NewReader(patched))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode thumbnail %d: %w", id, err)
+		}
+		return img, nil
+	}
+
+	return nil, nil
+}
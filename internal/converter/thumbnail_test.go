@@ -0,0 +1,117 @@
+package converter
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gen2brain/avif"
+)
+
+// createTestAVIFWithThumbnail creates a red AVIF whose embedded thumbnail is
+// a greyscale gradient
+// The encoder cannot write thumbnails, so the alpha plane of an encoded image
+// is turned into one by retyping its "auxl" reference to "thmb"
+func createTestAVIFWithThumbnail(t *testing.T, path string) {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			img.Set(x, y, color.NRGBA{255, 0, 0, uint8(x * 25)})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := avif.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode test AVIF: %v", err)
+	}
+
+	data := buf.Bytes()
+	if bytes.Count(data, []byte("auxl")) != 1 {
+		t.Fatal("expected exactly one auxl reference in the encoded AVIF")
+	}
+	data = bytes.Replace(data, []byte("auxl"), []byte("thmb"), 1)
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to create test AVIF file: %v", err)
+	}
+}
+
+// decodeTestPNG decodes a PNG written by a test
+func decodeTestPNG(t *testing.T, path string) image.Image {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("expected output file to exist: %v", err)
+	}
+	defer file.Close()
+
+	img, err := png.Decode(file)
+	if err != nil {
+		t.Fatalf("failed to decode output: %v", err)
+	}
+	return img
+}
+
+// ==================== UseThumbnail Tests ====================
+
+func TestConvert_UseThumbnail(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "image.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIFWithThumbnail(t, inputPath)
+
+	if _, err := Convert(inputPath, outputDir, Options{UseThumbnail: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// The gradient starts black, where the primary image is red
+	r, g, _, _ := decodeTestPNG(t, filepath.Join(outputDir, "image.png")).At(0, 0).RGBA()
+	if r>>8 > 32 || r != g {
+		t.Errorf("expected the greyscale thumbnail to be converted, got r=%d g=%d", r>>8, g>>8)
+	}
+}
+
+func TestConvert_UseThumbnailFallsBackToFullImage(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "image.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	if _, err := Convert(inputPath, outputDir, Options{UseThumbnail: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	r, g, _, _ := decodeTestPNG(t, filepath.Join(outputDir, "image.png")).At(0, 0).RGBA()
+	if r>>8 < 200 || g>>8 > 50 {
+		t.Errorf("expected the red primary image, got r=%d g=%d", r>>8, g>>8)
+	}
+}
+
+func TestConvert_ThumbnailRequired(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "image.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	_, err := Convert(inputPath, outputDir, Options{UseThumbnail: true, ThumbnailRequired: true})
+	if !errors.Is(err, ErrNoThumbnail) {
+		t.Fatalf("expected ErrNoThumbnail, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "image.png")); !os.IsNotExist(err) {
+		t.Error("expected no output without a thumbnail")
+	}
+}
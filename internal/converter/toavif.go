@@ -0,0 +1,63 @@
+package converter
+
+import (
+	"image"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/gen2brain/avif"
+)
+
+// avifFormat is the output format of Options.ToAVIF
+const avifFormat = "avif"
+
+// DefaultAVIFQuality is the quality of AVIF outputs by default, which is
+// that of the AVIF encoder; lower than DefaultQuality, since AVIF keeps
+// more detail than JPEG at the same setting
+const DefaultAVIFQuality = avif.DefaultQuality
+
+// avifInputExts are the extensions of the inputs of a conversion to AVIF
+var avifInputExts = []string{".png", ".jpg", ".jpeg"}
+
+// InputExtensions returns the lowercase extensions of the files that a
+// conversion picks up in directories: ".avif", or the PNG and JPEG
+// extensions when toAVIF is set
+func InputExtensions(toAVIF bool) []string {
+	if toAVIF {
+		return append([]string(nil), avifInputExts...)
+	}
+	return []string{".avif"}
+}
+
+// isInputName reports whether a file name has one of the input extensions
+// of opts, in any case: opts.Extensions, or the InputExtensions of
+// opts.ToAVIF
+func isInputName(name string, opts Options) bool {
+	exts := opts.Extensions
+	if len(exts) == 0 {
+		exts = InputExtensions(opts.ToAVIF)
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, want := range exts {
+		if ext == want {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeAVIF encodes img as AVIF at opts.Quality, 100 being lossless
+// Chroma444 keeps full-resolution color, as for JPEG
+func encodeAVIF(w io.Writer, img image.Image, opts EncodeOptions) error {
+	chroma := image.YCbCrSubsampleRatio420
+	if opts.Chroma == Chroma444 {
+		chroma = image.YCbCrSubsampleRatio444
+	}
+	return avif.Encode(w, img, avif.Options{
+		Quality:           opts.Quality,
+		QualityAlpha:      opts.Quality,
+		Speed:             avif.DefaultSpeed,
+		ChromaSubsampling: chroma,
+	})
+}
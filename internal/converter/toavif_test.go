@@ -0,0 +1,117 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gen2brain/avif"
+)
+
+// createTestImage writes a 12x8 green image to path, as PNG or JPEG
+// depending on its extension
+func createTestImage(t *testing.T, path string) {
+	t.Helper()
+
+	img := solidImage(12, 8, color.NRGBA{0, 160, 0, 255})
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create test image: %v", err)
+	}
+	defer file.Close()
+
+	if filepath.Ext(path) == ".png" {
+		err = png.Encode(file, img)
+	} else {
+		err = jpeg.Encode(file, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+}
+
+// ==================== ToAVIF Tests ====================
+
+func TestInputExtensions(t *testing.T) {
+	if got := InputExtensions(false); len(got) != 1 || got[0] != ".avif" {
+		t.Errorf("expected .avif, got %v", got)
+	}
+	if got := InputExtensions(true); len(got) != 3 {
+		t.Errorf("expected PNG and JPEG extensions, got %v", got)
+	}
+
+	for name, want := range map[string]bool{"a.png": true, "b.JPG": true, "c.jpeg": true, "d.avif": false, "e.gif": false} {
+		if got := isInputName(name, Options{ToAVIF: true}); got != want {
+			t.Errorf("isInputName(%q): expected %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestConvertDirectory_ToAVIF(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	os.MkdirAll(inputDir, 0755)
+	createTestImage(t, filepath.Join(inputDir, "a.png"))
+	createTestImage(t, filepath.Join(inputDir, "b.jpg"))
+	createTestAVIF(t, filepath.Join(inputDir, "c.avif"))
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{ToAVIF: true})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.TotalFiles != 2 || result.Successful != 2 {
+		t.Errorf("expected the PNG and JPEG to be converted, got %d of %d", result.Successful, result.TotalFiles)
+	}
+
+	for _, name := range []string{"a.avif", "b.avif"} {
+		file, err := os.Open(filepath.Join(outputDir, name))
+		if err != nil {
+			t.Fatalf("expected %s to exist, got: %v", name, err)
+		}
+		img, err := avif.Decode(file)
+		file.Close()
+		if err != nil {
+			t.Fatalf("expected %s to be a valid AVIF, got: %v", name, err)
+		}
+		if img.Bounds() != image.Rect(0, 0, 12, 8) {
+			t.Errorf("expected %s to be 12x8, got %v", name, img.Bounds())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "c.avif")); !os.IsNotExist(err) {
+		t.Errorf("expected AVIF inputs to be left out, got: %v", err)
+	}
+}
+
+func TestConvert_ToAVIFLossless(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputPath := filepath.Join(testDir, "test.png")
+	outputDir := filepath.Join(testDir, "output")
+	createTestImage(t, inputPath)
+
+	if _, err := Convert(inputPath, outputDir, Options{ToAVIF: true, Quality: 100, Chroma: Chroma444, Verify: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	file, err := os.Open(filepath.Join(outputDir, "test.avif"))
+	if err != nil {
+		t.Fatalf("expected output to exist, got: %v", err)
+	}
+	defer file.Close()
+	img, err := avif.Decode(file)
+	if err != nil {
+		t.Fatalf("expected a valid AVIF, got: %v", err)
+	}
+	r, g, b, _ := img.At(5, 4).RGBA()
+	if r>>8 > 2 || g>>8 < 158 || g>>8 > 162 || b>>8 > 2 {
+		t.Errorf("expected green to survive a lossless round trip, got %d,%d,%d", r>>8, g>>8, b>>8)
+	}
+}
@@ -0,0 +1,93 @@
+package converter
+
+import (
+	"image"
+	"image/draw"
+)
+
+// DefaultTrimTolerance is how far, per 8-bit channel, a pixel may be from the
+// border color and still be trimmed, which absorbs compression noise
+const DefaultTrimTolerance = 8
+
+// trim crops away the uniform border of img: the rows and columns whose
+// pixels are all within tolerance of the color of its corners
+// Images whose four corners do not share a color have no uniform border and
+// are returned as they are; an image of a single color is reduced to its
+// top-left pixel
+func trim(img image.Image, tolerance int) image.Image {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return img
+	}
+	border := img.At(bounds.Min.X, bounds.Min.Y)
+	br, bg, bb, ba := border.RGBA()
+
+	// Channels are compared in 16 bits, so scale the 8-bit tolerance
+	limit := uint32(tolerance) * 0x101
+	isBorder := func(x, y int) bool {
+		r, g, b, a := img.At(x, y).RGBA()
+		return within(r, br, limit) && within(g, bg, limit) && within(b, bb, limit) && within(a, ba, limit)
+	}
+	if !isBorder(bounds.Max.X-1, bounds.Min.Y) || !isBorder(bounds.Min.X, bounds.Max.Y-1) || !isBorder(bounds.Max.X-1, bounds.Max.Y-1) {
+		return img
+	}
+	rowIsBorder := func(y, x0, x1 int) bool {
+		for x := x0; x < x1; x++ {
+			if !isBorder(x, y) {
+				return false
+			}
+		}
+		return true
+	}
+	columnIsBorder := func(x, y0, y1 int) bool {
+		for y := y0; y < y1; y++ {
+			if !isBorder(x, y) {
+				return false
+			}
+		}
+		return true
+	}
+
+	crop := bounds
+	for crop.Min.Y < crop.Max.Y && rowIsBorder(crop.Min.Y, crop.Min.X, crop.Max.X) {
+		crop.Min.Y++
+	}
+	if crop.Empty() {
+		return subImage(img, image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Min.X+1, bounds.Min.Y+1))
+	}
+	for rowIsBorder(crop.Max.Y-1, crop.Min.X, crop.Max.X) {
+		crop.Max.Y--
+	}
+	for columnIsBorder(crop.Min.X, crop.Min.Y, crop.Max.Y) {
+		crop.Min.X++
+	}
+	for columnIsBorder(crop.Max.X-1, crop.Min.Y, crop.Max.Y) {
+		crop.Max.X--
+	}
+
+	if crop == bounds {
+		return img
+	}
+	return subImage(img, crop)
+}
+
+// within reports whether a and b differ by at most limit
+func within(a, b, limit uint32) bool {
+	if a > b {
+		return a-b <= limit
+	}
+	return b-a <= limit
+}
+
+// subImage returns the part of img within r, sharing its pixels when the
+// image type allows it
+func subImage(img image.Image, r image.Rectangle) image.Image {
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(r)
+	}
+	dst := image.NewNRGBA64(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+	return dst
+}
@@ -0,0 +1,113 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ==================== Trim Tests ====================
+
+// paddedImage returns a w x h image of border color with a content-colored
+// rectangle at inner
+func paddedImage(w, h int, border, content color.NRGBA, inner image.Rectangle) *image.NRGBA {
+	img := solidImage(w, h, border)
+	for y := inner.Min.Y; y < inner.Max.Y; y++ {
+		for x := inner.Min.X; x < inner.Max.X; x++ {
+			img.SetNRGBA(x, y, content)
+		}
+	}
+	return img
+}
+
+func TestTrim_CropsUniformBorder(t *testing.T) {
+	white := color.NRGBA{255, 255, 255, 255}
+	inner := image.Rect(3, 2, 9, 7)
+	img := paddedImage(12, 10, white, color.NRGBA{200, 10, 10, 255}, inner)
+
+	if got := trim(img, 0).Bounds(); got != inner {
+		t.Errorf("expected bounds %v, got %v", inner, got)
+	}
+}
+
+func TestTrim_Tolerance(t *testing.T) {
+	white := color.NRGBA{255, 255, 255, 255}
+	inner := image.Rect(2, 2, 6, 6)
+	img := paddedImage(8, 8, white, color.NRGBA{0, 0, 0, 255}, inner)
+	// Compression noise in the border
+	img.SetNRGBA(3, 0, color.NRGBA{252, 255, 250, 255})
+	img.SetNRGBA(0, 7, color.NRGBA{250, 252, 255, 255})
+	img.SetNRGBA(7, 3, color.NRGBA{255, 249, 255, 255})
+
+	if got := trim(img, 0).Bounds(); got != img.Bounds() {
+		t.Errorf("expected no trimming without tolerance, got %v", got)
+	}
+	if got := trim(img, 8).Bounds(); got != inner {
+		t.Errorf("expected bounds %v with tolerance 8, got %v", inner, got)
+	}
+}
+
+func TestTrim_CornersDisagree(t *testing.T) {
+	white := color.NRGBA{255, 255, 255, 255}
+	inner := image.Rect(2, 2, 6, 6)
+	img := paddedImage(8, 8, white, color.NRGBA{0, 0, 0, 255}, inner)
+	img.SetNRGBA(7, 7, color.NRGBA{0, 0, 255, 255})
+
+	if got := trim(img, 8); got != image.Image(img) {
+		t.Errorf("expected no trimming when a corner differs, got %v", got.Bounds())
+	}
+
+	// The corners agree within the tolerance
+	img.SetNRGBA(7, 7, color.NRGBA{250, 252, 255, 255})
+	if got := trim(img, 8).Bounds(); got != inner {
+		t.Errorf("expected bounds %v, got %v", inner, got)
+	}
+}
+
+func TestTrim_SingleColor(t *testing.T) {
+	img := solidImage(5, 4, color.NRGBA{0, 128, 0, 255})
+	trimmed := trim(img, 0)
+	if got := trimmed.Bounds(); got.Dx() != 1 || got.Dy() != 1 {
+		t.Fatalf("expected a 1x1 image, got %v", got)
+	}
+	if got := color.NRGBAModel.Convert(trimmed.At(0, 0)); got != (color.NRGBA{0, 128, 0, 255}) {
+		t.Errorf("expected the image color, got %v", got)
+	}
+}
+
+func TestTrim_NoBorder(t *testing.T) {
+	img := noiseImage(16, 255)
+	if got := trim(img, 0); got != image.Image(img) {
+		t.Errorf("expected the image to be returned unchanged, got bounds %v", got.Bounds())
+	}
+}
+
+func TestConvert_Trim(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	// The test image is a single color, so it is trimmed to one pixel
+	inputPath := filepath.Join(testDir, "test.avif")
+	outputDir := filepath.Join(testDir, "output")
+	createTestAVIF(t, inputPath)
+
+	if _, err := Convert(inputPath, outputDir, Options{Trim: true, TrimTolerance: DefaultTrimTolerance, Verify: true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	file, err := os.Open(filepath.Join(outputDir, "test.png"))
+	if err != nil {
+		t.Fatalf("failed to open output: %v", err)
+	}
+	defer file.Close()
+	config, err := png.DecodeConfig(file)
+	if err != nil {
+		t.Fatalf("expected a valid PNG, got: %v", err)
+	}
+	if config.Width != 1 || config.Height != 1 {
+		t.Errorf("expected a 1x1 output, got %dx%d", config.Width, config.Height)
+	}
+}
@@ -0,0 +1,120 @@
+package converter
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// parallelWalk is a recursive scan of fsys reading up to workers directories
+// at the same time, for trees on high-latency storage such as network
+// filesystems, where a sequential walk spends most of its time waiting
+// Directories are read in no particular order, so files are found in no
+// particular order either; the files of one directory keep their order
+type parallelWalk struct {
+	fsys     fs.FS
+	maxDepth int
+	visit    func(path string, entry fs.DirEntry) error
+
+	mu      sync.Mutex
+	wake    *sync.Cond
+	pending []string
+	// active is the number of directories being read; the walk is over
+	// once nothing is active or pending
+	active int
+	err    error
+	// failed stops the workers still reading a directory after an error
+	failed atomic.Bool
+
+	// visitMu makes visit calls sequential, so callers need no locking
+	visitMu sync.Mutex
+}
+
+// walkParallel calls visit with every file below the root of fsys, down to
+// maxDepth levels when it is set, like fs.WalkDir with no order
+// The first error, from reading a directory or from visit, stops the walk
+// and is returned
+func walkParallel(fsys fs.FS, workers, maxDepth int, visit func(path string, entry fs.DirEntry) error) error {
+	w := &parallelWalk{fsys: fsys, maxDepth: maxDepth, visit: visit, pending: []string{"."}}
+	w.wake = sync.NewCond(&w.mu)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.work()
+		}()
+	}
+	wg.Wait()
+	return w.err
+}
+
+// work reads pending directories until the walk is over or has failed
+func (w *parallelWalk) work() {
+	for {
+		w.mu.Lock()
+		for len(w.pending) == 0 && w.active > 0 && w.err == nil {
+			w.wake.Wait()
+		}
+		if len(w.pending) == 0 || w.err != nil {
+			w.mu.Unlock()
+			w.wake.Broadcast()
+			return
+		}
+		// Depth first keeps the queue short on wide trees
+		dir := w.pending[len(w.pending)-1]
+		w.pending = w.pending[:len(w.pending)-1]
+		w.active++
+		w.mu.Unlock()
+
+		subdirs, err := w.readDir(dir)
+
+		w.mu.Lock()
+		w.active--
+		if err != nil && w.err == nil {
+			w.err = err
+			w.failed.Store(true)
+		}
+		// Subdirectories are pushed in reverse so they are read in order
+		// when workers keep up
+		for i := len(subdirs) - 1; i >= 0; i-- {
+			w.pending = append(w.pending, subdirs[i])
+		}
+		w.mu.Unlock()
+		w.wake.Broadcast()
+	}
+}
+
+// readDir visits the files of dir and returns its subdirectories to walk
+func (w *parallelWalk) readDir(dir string) ([]string, error) {
+	entries, err := fs.ReadDir(w.fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var subdirs []string
+	for _, entry := range entries {
+		entryPath := path.Join(dir, entry.Name())
+		if entry.IsDir() {
+			// Do not descend below maxDepth, like walkAVIFFilesFS
+			if w.maxDepth == 0 || strings.Count(entryPath, "/")+1 < w.maxDepth {
+				subdirs = append(subdirs, entryPath)
+			}
+			continue
+		}
+
+		if w.failed.Load() {
+			return nil, nil
+		}
+		w.visitMu.Lock()
+		err := w.visit(entryPath, entry)
+		w.visitMu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return subdirs, nil
+}
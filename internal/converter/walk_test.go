@@ -0,0 +1,126 @@
+package converter
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// ==================== walkParallel Tests ====================
+
+// deepTree returns a synthetic tree of AVIF files spread over nested
+// directories, with a few files that are not selected
+func deepTree() fstest.MapFS {
+	fsys := fstest.MapFS{
+		"root.avif":           {Data: []byte("r")},
+		"notes.txt":           {Data: []byte("n")},
+		"a/.hidden.avif":      {Data: []byte("h")},
+		"a/b/c/d/e/deep.avif": {Data: []byte("d")},
+	}
+	for _, dir := range []string{"a", "a/b", "a/b/c", "x", "x/y", "z"} {
+		for _, name := range []string{"1.avif", "2.AVIF", "3.png"} {
+			fsys[dir+"/"+name] = &fstest.MapFile{Data: []byte(name)}
+		}
+	}
+	return fsys
+}
+
+func TestWalkParallel_FindsSameFiles(t *testing.T) {
+	fsys := deepTree()
+
+	for _, maxDepth := range []int{0, 1, 2, 4} {
+		want, err := collectAVIFFilesFS(fsys, Options{Recursive: true, MaxDepth: maxDepth})
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		got, err := collectAVIFFilesFS(fsys, Options{Recursive: true, MaxDepth: maxDepth, ScanWorkers: 4})
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		sort.Strings(want)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("max depth %d: expected %v, got: %v", maxDepth, want, got)
+		}
+	}
+}
+
+func TestWalkParallel_ReportsFiltered(t *testing.T) {
+	filtered := map[string]int{}
+	err := walkAVIFFilesFS(deepTree(), Options{Recursive: true, ScanWorkers: 4}, func(string) error {
+		return nil
+	}, func(reason string) {
+		filtered[reason]++
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if filtered[FilterReasonHidden] != 1 {
+		t.Errorf("expected 1 hidden file, got: %v", filtered)
+	}
+}
+
+func TestWalkParallel_StopsOnError(t *testing.T) {
+	stop := errors.New("stop")
+
+	var mu sync.Mutex
+	visited := 0
+	err := walkAVIFFilesFS(deepTree(), Options{Recursive: true, ScanWorkers: 4}, func(string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		visited++
+		return stop
+	}, nil)
+	if !errors.Is(err, stop) {
+		t.Errorf("expected the callback error, got: %v", err)
+	}
+	if visited != 1 {
+		t.Errorf("expected the walk to stop after the first file, got %d visits", visited)
+	}
+}
+
+func TestWalkParallel_ReadError(t *testing.T) {
+	err := walkParallel(fstest.MapFS{}, 4, 0, func(string, fs.DirEntry) error { return nil })
+	if err != nil {
+		t.Fatalf("expected no error for an empty filesystem, got: %v", err)
+	}
+
+	missing, err := fs.Sub(fstest.MapFS{"file.avif": {Data: []byte("f")}}, "missing")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	err = walkParallel(missing, 4, 0, func(string, fs.DirEntry) error { return nil })
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a not-exist error, got: %v", err)
+	}
+}
+
+func TestConvertDirectory_ScanWorkers(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	for _, dir := range []string{"a", "a/b", "c"} {
+		if err := os.MkdirAll(filepath.Join(inputDir, dir), 0755); err != nil {
+			t.Fatalf("failed to create input dir: %v", err)
+		}
+		createTestAVIF(t, filepath.Join(inputDir, dir, strings.ReplaceAll(dir, "/", "_")+".avif"))
+	}
+
+	result, err := ConvertDirectory(inputDir, outputDir, Options{Recursive: true, ScanWorkers: 4})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.TotalFiles != 3 || result.Successful != 3 {
+		t.Errorf("expected 3 converted files, got %d of %d", result.Successful, result.TotalFiles)
+	}
+}
@@ -0,0 +1,142 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// WorkersAuto is the Options.Workers value that picks the number of files
+// converted at the same time from the CPU count and the size of each file
+const WorkersAuto = -1
+
+// autoWorkersMemory is the memory that the files converted at the same time
+// may take together with WorkersAuto
+const autoWorkersMemory = 1 << 30
+
+// memoryPerInputByte estimates the memory a conversion takes per byte of
+// input: AVIF files are a few percent of their decoded image, and a
+// conversion holds the image, its processed copies and the encoded output
+const memoryPerInputByte = 64
+
+// workerPool converts the files of a bulk conversion and records them into
+// result like convertInto, several at the same time with Options.Workers
+type workerPool struct {
+	result   *ConversionResult
+	opts     Options
+	fileOpts *Options
+	// limit is the number of files converted at the same time, and budget
+	// the memory they may take together by memoryPerInputByte, 0 for no limit
+	limit  int
+	budget int64
+	// errors is the number of errors of result before the pool started
+	errors int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	running int
+	used    int64
+	// outputs are the output paths of the files being converted
+	outputs map[string]bool
+	// err is the error that stops the conversion, set by a worker
+	err error
+	wg  sync.WaitGroup
+}
+
+// newWorkerPool returns the pool of a conversion with opts, whose files are
+// converted with fileOpts
+// Prompts are asked one at a time, and Dedupe and FlattenHash compare each
+// file with those converted before it, so they always convert one file at
+// a time
+func newWorkerPool(result *ConversionResult, opts Options, fileOpts *Options) *workerPool {
+	p := &workerPool{result: result, opts: opts, fileOpts: fileOpts, limit: opts.Workers, errors: len(result.Errors), outputs: map[string]bool{}}
+	p.cond = sync.NewCond(&p.mu)
+	if opts.Workers == WorkersAuto {
+		p.limit, p.budget = runtime.NumCPU(), autoWorkersMemory
+	}
+	if p.limit < 1 || opts.Prompt != nil || opts.Dedupe || opts.FlattenHash {
+		p.limit, p.budget = 1, 0
+	}
+	result.workers.Store(int64(p.limit))
+	return p
+}
+
+// convert converts src to fileResult.OutputPath after printing line, the
+// start of its progress line in verbose mode, and returns ErrAborted or the
+// error of stopsAfterLast when the conversion stops
+// With more than one worker, it returns once the file is started, or with
+// the error of a file converted earlier; files wait for a free worker, for
+// their memory to fit in the budget, unless no other file is running, and
+// for any other file writing the same output, which then skips them
+func (p *workerPool) convert(src source, fileResult FileResult, line string) error {
+	if p.limit == 1 {
+		if line != "" {
+			p.opts.logf("%s", line)
+		}
+		if convertInto(p.result, src, fileResult, p.fileOpts, p.opts.Verbose) == OverwriteQuit {
+			return ErrAborted
+		}
+		return stopsAfterLast(p.result, p.opts)
+	}
+
+	need := fileResult.InputSize * memoryPerInputByte
+	p.mu.Lock()
+	for p.err == nil && (p.outputs[fileResult.OutputPath] ||
+		p.running > 0 && (p.running >= p.limit || p.budget > 0 && p.used+need > p.budget)) {
+		p.cond.Wait()
+	}
+	if p.err != nil {
+		p.mu.Unlock()
+		return p.err
+	}
+	p.running++
+	p.used += need
+	p.outputs[fileResult.OutputPath] = true
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		// Each file prints its lines at once, so files converted at the same
+		// time never mix them
+		fileOpts := *p.fileOpts
+		var buf bytes.Buffer
+		fileOpts.Log = &buf
+		buf.WriteString(line)
+		convertInto(p.result, src, fileResult, &fileOpts, p.opts.Verbose)
+		if buf.Len() > 0 {
+			p.opts.logf("%s", buf.Bytes())
+		}
+
+		// Files finish in no particular order, so the last error is the one
+		// of a failed file, unlike the last file
+		var err error
+		p.result.mu.Lock()
+		if p.opts.OnError == OnErrorStop && len(p.result.Errors) > p.errors {
+			err = fmt.Errorf("%w: %s", ErrStopped, p.result.Errors[len(p.result.Errors)-1].FilePath)
+		}
+		p.result.mu.Unlock()
+
+		p.mu.Lock()
+		p.running--
+		p.used -= need
+		delete(p.outputs, fileResult.OutputPath)
+		if p.err == nil {
+			p.err = err
+		}
+		p.cond.Broadcast()
+		p.mu.Unlock()
+	}()
+	return nil
+}
+
+// wait waits for the files being converted and returns the error that
+// stopped the conversion, if any
+func (p *workerPool) wait() error {
+	p.wg.Wait()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
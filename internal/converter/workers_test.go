@@ -0,0 +1,119 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// ==================== Worker Pool Tests ====================
+
+func TestNewWorkerPool(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		limit  int
+		budget int64
+	}{
+		{"unset", Options{}, 1, 0},
+		{"fixed", Options{Workers: 4}, 4, 0},
+		{"auto", Options{Workers: WorkersAuto}, runtime.NumCPU(), autoWorkersMemory},
+		{"prompt", Options{Workers: 4, Prompt: func(string) OverwriteDecision { return OverwriteNo }}, 1, 0},
+		{"dedupe", Options{Workers: 4, Dedupe: true}, 1, 0},
+		{"flatten hash", Options{Workers: WorkersAuto, FlattenHash: true}, 1, 0},
+	}
+	for _, tt := range tests {
+		result := newFilesResult(0)
+		p := newWorkerPool(result, tt.opts, &tt.opts)
+		if p.limit != tt.limit || p.budget != tt.budget {
+			t.Errorf("%s: expected %d workers and a budget of %d, got %d and %d", tt.name, tt.limit, tt.budget, p.limit, p.budget)
+		}
+		if result.workers.Load() != int64(tt.limit) {
+			t.Errorf("%s: expected the ETA to divide by %d, got %d", tt.name, tt.limit, result.workers.Load())
+		}
+	}
+}
+
+func TestConvertDirectory_Workers(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	os.MkdirAll(inputDir, 0755)
+	names := []string{"a", "b", "c", "d", "e", "f"}
+	for _, name := range names {
+		createTestAVIF(t, filepath.Join(inputDir, name+".avif"))
+	}
+
+	var log bytes.Buffer
+	result, err := ConvertDirectory(inputDir, outputDir, Options{Workers: 3, Verbose: true, Log: &log})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.TotalFiles != len(names) || result.Successful != len(names) || len(result.Files) != len(names) {
+		t.Errorf("expected %d files converted, got %d of %d", len(names), result.Successful, result.TotalFiles)
+	}
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(outputDir, name+".png")); err != nil {
+			t.Errorf("expected %s.png to exist, got: %v", name, err)
+		}
+	}
+
+	// Each file is printed on a line of its own, whatever finishes first
+	lines := regexp.MustCompile(`(?m)^  \[\d/6, [^\]]+\] Converting [a-f]\.avif\.\.\. ✅( \(.*\))?$`).FindAllString(log.String(), -1)
+	if len(lines) != len(names) {
+		t.Errorf("expected a whole line per file, got:\n%s", log.String())
+	}
+}
+
+func TestConvertMapped_WorkersSharedOutput(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	// Files writing the same output wait for each other, so the second is
+	// skipped as it is one file at a time
+	output := filepath.Join(testDir, "output", "same.png")
+	var files []MappedFile
+	for _, name := range []string{"a.avif", "b.avif", "c.avif"} {
+		createTestAVIF(t, filepath.Join(testDir, name))
+		files = append(files, MappedFile{InputPath: filepath.Join(testDir, name), OutputPath: output})
+	}
+
+	result, err := ConvertMapped(context.Background(), files, Options{Workers: 3})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Successful != 1 || result.SkippedReasons[SkipReasonExists] != 2 {
+		t.Errorf("expected 1 converted and 2 skipped, got %d and %v", result.Successful, result.SkippedReasons)
+	}
+}
+
+func TestConvertMapped_WorkersStop(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	broken := filepath.Join(testDir, "broken.avif")
+	os.WriteFile(broken, []byte("not an image"), 0644)
+	files := []MappedFile{{InputPath: broken, OutputPath: filepath.Join(testDir, "output", "broken.png")}}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		createTestAVIF(t, filepath.Join(testDir, name+".avif"))
+		files = append(files, MappedFile{InputPath: filepath.Join(testDir, name+".avif"), OutputPath: filepath.Join(testDir, "output", name+".png")})
+	}
+
+	// Files started with the failed one finish, so the failure is reported
+	// even when they are the last ones
+	result, err := ConvertMapped(context.Background(), files, Options{Workers: 2, OnError: OnErrorStop})
+	if !errors.Is(err, ErrStopped) || !strings.Contains(err.Error(), "broken.avif") {
+		t.Fatalf("expected ErrStopped for broken.avif, got: %v", err)
+	}
+	if result.Failed != 1 {
+		t.Errorf("expected 1 failed file, got %d", result.Failed)
+	}
+}
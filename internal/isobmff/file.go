@@ -0,0 +1,642 @@
+package isobmff
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// File describes the brands and image items of an AVIF/HEIF file
+type File struct {
+	MajorBrand       string
+	MinorVersion     uint32
+	CompatibleBrands []string
+	// PrimaryItemID is the item shown by default; zero if the file has none
+	PrimaryItemID uint32
+	Items         []Item
+	References    []Reference
+	// Boxes are the top-level boxes of the file
+	Boxes []Box
+
+	// pitmOffset is the position of the primary item ID within the file
+	pitmOffset int
+	pitmSize   int
+	// ilocErr is why the item locations could not be read; only ItemData
+	// needs them, so it is returned from there rather than failing Parse
+	ilocErr error
+}
+
+// Item is an entry of the item information box
+type Item struct {
+	ID     uint32
+	Type   string
+	Name   string
+	Hidden bool
+	// Properties are the property boxes associated with the item, in order
+	Properties []Box
+	// Extents locate the data of the item within the file, in order; items
+	// stored in an idat box or in another file have none
+	Extents []Extent
+}
+
+// Extent is a run of bytes of an item's data, at Offset within the file
+// A Length of zero runs to the end of the file
+type Extent struct {
+	Offset uint64
+	Length uint64
+}
+
+// Reference is an item reference, e.g. an auxiliary image pointing at the
+// image it belongs to
+type Reference struct {
+	Type   string
+	FromID uint32
+	ToIDs  []uint32
+
+	// offset is the position of the reference box header within the file
+	offset int
+}
+
+// Parse reads the container structure of an AVIF/HEIF file
+func Parse(data []byte) (*File, error) {
+	boxes, err := ReadBoxes(data, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ftyp, ok := findBox(boxes, "ftyp")
+	if !ok {
+		return nil, fmt.Errorf("%w: missing ftyp box", ErrInvalid)
+	}
+
+	f := &File{Boxes: boxes}
+	if err := f.parseFtyp(ftyp.Payload); err != nil {
+		return nil, err
+	}
+
+	// Image sequences may carry no meta box at all
+	meta, ok := findBox(boxes, "meta")
+	if !ok {
+		return f, nil
+	}
+	if err := f.parseMeta(meta); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *File) parseFtyp(payload []byte) error {
+	r := &reader{data: payload}
+	f.MajorBrand = r.fourCC()
+	f.MinorVersion = r.u32()
+	for r.err == nil && r.pos+4 <= len(payload) {
+		f.CompatibleBrands = append(f.CompatibleBrands, r.fourCC())
+	}
+	return r.err
+}
+
+func (f *File) parseMeta(meta Box) error {
+	_, _, rest, err := fullBoxHeader(meta.Payload)
+	if err != nil {
+		return err
+	}
+
+	children, err := ReadBoxes(rest, meta.Offset+meta.HeaderSize+4)
+	if err != nil {
+		return err
+	}
+
+	for _, box := range children {
+		switch box.Type {
+		case "pitm":
+			err = f.parsePitm(box)
+		case "iinf":
+			err = f.parseIinf(box)
+		case "iref":
+			err = f.parseIref(box)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Locations and properties refer to items, so they are resolved once all
+	// items are known
+	if iloc, ok := findBox(children, "iloc"); ok {
+		f.ilocErr = f.parseIloc(iloc)
+	}
+	if iprp, ok := findBox(children, "iprp"); ok {
+		return f.parseIprp(iprp)
+	}
+
+	return nil
+}
+
+func (f *File) parsePitm(box Box) error {
+	version, _, _, err := fullBoxHeader(box.Payload)
+	if err != nil {
+		return err
+	}
+
+	r := &reader{data: box.Payload, pos: 4}
+	f.PrimaryItemID = r.id(version)
+	f.pitmOffset = box.Offset + box.HeaderSize + 4
+	f.pitmSize = 4
+	if version == 0 {
+		f.pitmSize = 2
+	}
+
+	return r.err
+}
+
+func (f *File) parseIinf(box Box) error {
+	version, _, _, err := fullBoxHeader(box.Payload)
+	if err != nil {
+		return err
+	}
+
+	r := &reader{data: box.Payload, pos: 4}
+	if version == 0 {
+		r.u16()
+	} else {
+		r.u32()
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	entries, err := ReadBoxes(r.rest(), 0)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.Type != "infe" {
+			continue
+		}
+
+		item, err := parseInfe(entry.Payload)
+		if err != nil {
+			return err
+		}
+		f.Items = append(f.Items, item)
+	}
+
+	return nil
+}
+
+func parseInfe(payload []byte) (Item, error) {
+	version, flags, _, err := fullBoxHeader(payload)
+	if err != nil {
+		return Item{}, err
+	}
+
+	r := &reader{data: payload, pos: 4}
+	item := Item{Hidden: flags&1 != 0}
+
+	switch {
+	case version < 2:
+		item.ID = uint32(r.u16())
+		r.u16() // item_protection_index
+		item.Name = r.cString()
+	default:
+		if version == 2 {
+			item.ID = uint32(r.u16())
+		} else {
+			item.ID = r.u32()
+		}
+		r.u16() // item_protection_index
+		item.Type = r.fourCC()
+		item.Name = r.cString()
+	}
+
+	return item, r.err
+}
+
+func (f *File) parseIref(box Box) error {
+	version, _, rest, err := fullBoxHeader(box.Payload)
+	if err != nil {
+		return err
+	}
+
+	refs, err := ReadBoxes(rest, box.Offset+box.HeaderSize+4)
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range refs {
+		r := &reader{data: ref.Payload}
+		reference := Reference{Type: ref.Type, FromID: r.id(version), offset: ref.Offset}
+		count := int(r.u16())
+		for i := 0; i < count && r.err == nil; i++ {
+			reference.ToIDs = append(reference.ToIDs, r.id(version))
+		}
+		if r.err != nil {
+			return r.err
+		}
+		f.References = append(f.References, reference)
+	}
+
+	return nil
+}
+
+func (f *File) parseIloc(box Box) error {
+	version, _, _, err := fullBoxHeader(box.Payload)
+	if err != nil {
+		return err
+	}
+
+	r := &reader{data: box.Payload, pos: 4}
+	sizes := r.u16()
+	offsetSize, lengthSize := int(sizes>>12), int(sizes>>8&0xF)
+	baseOffsetSize, indexSize := int(sizes>>4&0xF), 0
+	if version > 0 {
+		indexSize = int(sizes & 0xF)
+	}
+
+	var count int
+	if version < 2 {
+		count = int(r.u16())
+	} else {
+		count = int(r.u32())
+	}
+	for i := 0; i < count && r.err == nil; i++ {
+		var id uint32
+		if version < 2 {
+			id = uint32(r.u16())
+		} else {
+			id = r.u32()
+		}
+		method := 0
+		if version > 0 {
+			method = int(r.u16() & 0xF)
+		}
+		dataRef := r.u16()
+		base := r.sized(baseOffsetSize)
+
+		var extents []Extent
+		extentCount := int(r.u16())
+		for j := 0; j < extentCount && r.err == nil; j++ {
+			r.sized(indexSize)
+			offset := r.sized(offsetSize)
+			extents = append(extents, Extent{Offset: base + offset, Length: r.sized(lengthSize)})
+		}
+
+		// Only data in this file is located by file offset
+		if item := f.Item(id); item != nil && method == 0 && dataRef == 0 {
+			item.Extents = extents
+		}
+	}
+
+	return r.err
+}
+
+func (f *File) parseIprp(box Box) error {
+	children, err := ReadBoxes(box.Payload, 0)
+	if err != nil {
+		return err
+	}
+
+	var properties []Box
+	if ipco, ok := findBox(children, "ipco"); ok {
+		if properties, err = ReadBoxes(ipco.Payload, 0); err != nil {
+			return err
+		}
+	}
+
+	for _, ipma := range children {
+		if ipma.Type != "ipma" {
+			continue
+		}
+
+		version, flags, _, err := fullBoxHeader(ipma.Payload)
+		if err != nil {
+			return err
+		}
+
+		r := &reader{data: ipma.Payload, pos: 4}
+		entryCount := int(r.u32())
+		for i := 0; i < entryCount && r.err == nil; i++ {
+			item := f.Item(r.id(version))
+			associations := int(r.u8())
+			for j := 0; j < associations && r.err == nil; j++ {
+				// The top bit marks the property as essential
+				var index int
+				if flags&1 != 0 {
+					index = int(r.u16() & 0x7FFF)
+				} else {
+					index = int(r.u8() & 0x7F)
+				}
+				if item != nil && index > 0 && index <= len(properties) {
+					item.Properties = append(item.Properties, properties[index-1])
+				}
+			}
+		}
+		if r.err != nil {
+			return r.err
+		}
+	}
+
+	return nil
+}
+
+// Item returns the item with the given ID, or nil if there is none
+func (f *File) Item(id uint32) *Item {
+	for i := range f.Items {
+		if f.Items[i].ID == id {
+			return &f.Items[i]
+		}
+	}
+	return nil
+}
+
+// ReferencesTo returns the IDs of items that reference toID with refType
+// For example, ReferencesTo("auxl", primary) lists the auxiliary images of primary
+func (f *File) ReferencesTo(refType string, toID uint32) []uint32 {
+	var ids []uint32
+	for _, ref := range f.References {
+		if ref.Type != refType {
+			continue
+		}
+		for _, id := range ref.ToIDs {
+			if id == toID {
+				ids = append(ids, ref.FromID)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// ItemData returns the data of item id, read from data, the file f was
+// parsed from
+func (f *File) ItemData(data []byte, id uint32) ([]byte, error) {
+	item := f.Item(id)
+	if item == nil {
+		return nil, fmt.Errorf("%w: no item %d", ErrInvalid, id)
+	}
+	if f.ilocErr != nil {
+		return nil, f.ilocErr
+	}
+	if len(item.Extents) == 0 {
+		return nil, fmt.Errorf("%w: item %d is not stored in the file", ErrInvalid, id)
+	}
+
+	var out []byte
+	for _, extent := range item.Extents {
+		end := uint64(len(data))
+		if extent.Length > 0 {
+			end = extent.Offset + extent.Length
+		}
+		if extent.Offset > end || end > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: item %d extends past the end of the file", ErrInvalid, id)
+		}
+		out = append(out, data[extent.Offset:end]...)
+	}
+	return out, nil
+}
+
+// IsPremultiplied reports whether a prem reference marks the color of item
+// id as multiplied by its alpha image before encoding
+func (f *File) IsPremultiplied(id uint32) bool {
+	for _, ref := range f.References {
+		if ref.Type == "prem" && ref.FromID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// imageItemTypes are the item types that hold a whole image
+var imageItemTypes = map[string]bool{"av01": true, "grid": true}
+
+// TopLevelImages returns the IDs of the independent images of the file, in
+// item order, such as the shots of a burst capture
+// Hidden items are left out, as are images that belong to another image:
+// auxiliary images, thumbnails and the tiles of a grid
+func (f *File) TopLevelImages() []uint32 {
+	dependent := map[uint32]bool{}
+	for _, ref := range f.References {
+		switch ref.Type {
+		case "auxl", "thmb":
+			dependent[ref.FromID] = true
+		case "dimg":
+			for _, id := range ref.ToIDs {
+				dependent[id] = true
+			}
+		}
+	}
+
+	var ids []uint32
+	for _, item := range f.Items {
+		if imageItemTypes[item.Type] && !item.Hidden && !dependent[item.ID] {
+			ids = append(ids, item.ID)
+		}
+	}
+	return ids
+}
+
+// IsSequence reports whether the file is an image sequence, which has the
+// avis brand or a movie box for its tracks
+func (f *File) IsSequence() bool {
+	if f.MajorBrand == "avis" {
+		return true
+	}
+	for _, brand := range f.CompatibleBrands {
+		if brand == "avis" {
+			return true
+		}
+	}
+	_, ok := findBox(f.Boxes, "moov")
+	return ok
+}
+
+// WithPrimaryItem returns a copy of data whose primary item is id
+// Decoding the copy yields that item instead of the original primary image
+func (f *File) WithPrimaryItem(data []byte, id uint32) ([]byte, error) {
+	if f.pitmSize == 0 {
+		return nil, fmt.Errorf("%w: missing pitm box", ErrInvalid)
+	}
+	if f.pitmSize == 2 && id > 0xFFFF {
+		return nil, fmt.Errorf("%w: item ID %d does not fit the pitm box", ErrInvalid, id)
+	}
+	if f.pitmOffset+f.pitmSize > len(data) {
+		return nil, fmt.Errorf("%w: data does not match the parsed file", ErrInvalid)
+	}
+
+	patched := make([]byte, len(data))
+	copy(patched, data)
+
+	if f.pitmSize == 2 {
+		binary.BigEndian.PutUint16(patched[f.pitmOffset:], uint16(id))
+	} else {
+		binary.BigEndian.PutUint32(patched[f.pitmOffset:], id)
+	}
+
+	return patched, nil
+}
+
+// WithoutReferences returns a copy of data in which the references of refType
+// are retyped to "free", so that decoders ignore them
+// Decoders skip thumbnails when looking for the primary image, so a thumbnail
+// can only be decoded as primary once its "thmb" reference is removed
+func (f *File) WithoutReferences(data []byte, refType string) ([]byte, error) {
+	patched := make([]byte, len(data))
+	copy(patched, data)
+
+	for _, ref := range f.References {
+		if ref.Type != refType {
+			continue
+		}
+		// The type follows the 32-bit size at the start of the box header
+		if ref.offset+8 > len(data) || string(data[ref.offset+4:ref.offset+8]) != refType {
+			return nil, fmt.Errorf("%w: data does not match the parsed file", ErrInvalid)
+		}
+		copy(patched[ref.offset+4:], "free")
+	}
+
+	return patched, nil
+}
+
+// Property returns the first associated property of the given type
+func (it Item) Property(boxType string) (Box, bool) {
+	return findBox(it.Properties, boxType)
+}
+
+// AuxiliaryType returns the URN from the item's auxC property, which
+// identifies auxiliary images such as alpha masks and depth maps
+func (it Item) AuxiliaryType() string {
+	box, ok := it.Property("auxC")
+	if !ok {
+		return ""
+	}
+
+	_, _, rest, err := fullBoxHeader(box.Payload)
+	if err != nil {
+		return ""
+	}
+
+	r := &reader{data: rest}
+	return r.cString()
+}
+
+// IsImage reports whether the item holds a whole image, as opposed to
+// metadata such as Exif or XMP
+func (it Item) IsImage() bool {
+	return imageItemTypes[it.Type]
+}
+
+// ImageSize returns the dimensions from the item's ispe property
+func (it Item) ImageSize() (width, height int, ok bool) {
+	box, found := it.Property("ispe")
+	if !found {
+		return 0, 0, false
+	}
+
+	r := &reader{data: box.Payload, pos: 4}
+	width, height = int(r.u32()), int(r.u32())
+
+	return width, height, r.err == nil
+}
+
+// ColorInfo holds the fields of an nclx colr property, the code points of
+// ITU-T H.273 that describe the color of an image
+type ColorInfo struct {
+	// Primaries is colour_primaries, e.g. 1 for BT.709 and 9 for BT.2020
+	Primaries int
+	// Transfer is transfer_characteristics, e.g. 13 for sRGB and 16 for PQ
+	Transfer int
+	// Matrix is matrix_coefficients, e.g. 6 for BT.601
+	Matrix    int
+	FullRange bool
+}
+
+// ColorInfo returns the code points from the item's nclx colr property
+// Items may also have a colr property with an ICC profile, which is skipped
+func (it Item) ColorInfo() (ColorInfo, bool) {
+	for _, box := range it.Properties {
+		if box.Type != "colr" || len(box.Payload) < 11 || string(box.Payload[:4]) != "nclx" {
+			continue
+		}
+
+		r := &reader{data: box.Payload, pos: 4}
+		info := ColorInfo{
+			Primaries: int(r.u16()),
+			Transfer:  int(r.u16()),
+			Matrix:    int(r.u16()),
+			FullRange: r.u8()&0x80 != 0,
+		}
+		return info, r.err == nil
+	}
+	return ColorInfo{}, false
+}
+
+// ICCProfile returns the ICC profile from the item's colr property, if it
+// has one, restricted (rICC) or not (prof)
+func (it Item) ICCProfile() ([]byte, bool) {
+	for _, box := range it.Properties {
+		if box.Type == "colr" && len(box.Payload) >= 4 {
+			if kind := string(box.Payload[:4]); kind == "prof" || kind == "rICC" {
+				return box.Payload[4:], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// AV1Config holds the fields of an av1C property that describe how an AV1
+// image is coded
+type AV1Config struct {
+	// Profile is the AV1 seq_profile: 0 (Main), 1 (High) or 2 (Professional)
+	Profile  int
+	Level    int
+	BitDepth int
+	// Monochrome images have no chroma planes
+	Monochrome bool
+	// SubsamplingX and SubsamplingY are set when chroma is halved horizontally
+	// and vertically
+	SubsamplingX bool
+	SubsamplingY bool
+}
+
+// AV1Config returns the coding parameters from the item's av1C property
+func (it Item) AV1Config() (AV1Config, bool) {
+	box, found := it.Property("av1C")
+	if !found || len(box.Payload) < 3 || box.Payload[0]&0x80 == 0 {
+		return AV1Config{}, false
+	}
+
+	b := box.Payload
+	config := AV1Config{
+		Profile:      int(b[1] >> 5),
+		Level:        int(b[1] & 0x1F),
+		BitDepth:     8,
+		Monochrome:   b[2]&0x10 != 0,
+		SubsamplingX: b[2]&0x08 != 0,
+		SubsamplingY: b[2]&0x04 != 0,
+	}
+
+	// twelve_bit only applies to the Professional profile
+	if b[2]&0x40 != 0 {
+		config.BitDepth = 10
+		if config.Profile == 2 && b[2]&0x20 != 0 {
+			config.BitDepth = 12
+		}
+	}
+
+	return config, true
+}
+
+// PixelFormat describes the chroma layout, e.g. "YUV 4:2:0"
+func (c AV1Config) PixelFormat() string {
+	switch {
+	case c.Monochrome:
+		return "YUV 4:0:0 (monochrome)"
+	case c.SubsamplingX && c.SubsamplingY:
+		return "YUV 4:2:0"
+	case c.SubsamplingX:
+		return "YUV 4:2:2"
+	default:
+		return "YUV 4:4:4"
+	}
+}
@@ -0,0 +1,416 @@
+package isobmff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/gen2brain/avif"
+)
+
+// encodeTestAVIF encodes a semi-transparent image, which produces a primary
+// colour item and an auxiliary alpha item
+func encodeTestAVIF(t *testing.T) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, color.NRGBA{255, 0, 0, uint8(x * 30)})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := avif.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode test AVIF: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// ==================== Parse Tests ====================
+
+func TestParse_Items(t *testing.T) {
+	f, err := Parse(encodeTestAVIF(t))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if f.MajorBrand != "avif" {
+		t.Errorf("expected major brand avif, got: %q", f.MajorBrand)
+	}
+
+	primary := f.Item(f.PrimaryItemID)
+	if primary == nil {
+		t.Fatal("expected primary item to exist")
+	}
+	if primary.Type != "av01" {
+		t.Errorf("expected av01 primary item, got: %q", primary.Type)
+	}
+	if w, h, ok := primary.ImageSize(); !ok || w != 8 || h != 4 {
+		t.Errorf("expected 8x4 primary item, got: %dx%d (ok=%v)", w, h, ok)
+	}
+}
+
+func TestItem_AV1Config(t *testing.T) {
+	f, err := Parse(encodeTestAVIF(t))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	config, ok := f.Item(f.PrimaryItemID).AV1Config()
+	if !ok {
+		t.Fatal("expected the primary item to have an av1C property")
+	}
+	if config.BitDepth != 8 || config.Monochrome {
+		t.Errorf("expected 8-bit color, got: %+v", config)
+	}
+	if config.PixelFormat() == "" {
+		t.Error("expected a pixel format")
+	}
+}
+
+func TestAV1Config_Fields(t *testing.T) {
+	tests := []struct {
+		payload []byte
+		want    AV1Config
+		format  string
+	}{
+		{[]byte{0x81, 0x08, 0x0C}, AV1Config{Profile: 0, Level: 8, BitDepth: 8, SubsamplingX: true, SubsamplingY: true}, "YUV 4:2:0"},
+		{[]byte{0x81, 0x20, 0x40}, AV1Config{Profile: 1, BitDepth: 10}, "YUV 4:4:4"},
+		{[]byte{0x81, 0x40, 0x68}, AV1Config{Profile: 2, BitDepth: 12, SubsamplingX: true}, "YUV 4:2:2"},
+		{[]byte{0x81, 0x00, 0x1C}, AV1Config{BitDepth: 8, Monochrome: true, SubsamplingX: true, SubsamplingY: true}, "YUV 4:0:0 (monochrome)"},
+	}
+
+	for _, tt := range tests {
+		item := Item{Properties: []Box{{Type: "av1C", Payload: tt.payload}}}
+		got, ok := item.AV1Config()
+		if !ok {
+			t.Fatalf("% x: expected an AV1 config", tt.payload)
+		}
+		if got != tt.want {
+			t.Errorf("% x: expected %+v, got: %+v", tt.payload, tt.want, got)
+		}
+		if got.PixelFormat() != tt.format {
+			t.Errorf("% x: expected %s, got: %s", tt.payload, tt.format, got.PixelFormat())
+		}
+	}
+
+	if _, ok := (Item{Properties: []Box{{Type: "av1C", Payload: []byte{0x01, 0, 0}}}}).AV1Config(); ok {
+		t.Error("expected an av1C without its marker bit to be rejected")
+	}
+}
+
+func TestItem_ColorInfo(t *testing.T) {
+	item := Item{Properties: []Box{
+		{Type: "colr", Payload: []byte("prof....")},
+		{Type: "colr", Payload: []byte{'n', 'c', 'l', 'x', 0, 9, 0, 16, 0, 9, 0x80}},
+	}}
+	got, ok := item.ColorInfo()
+	if !ok {
+		t.Fatal("expected the nclx colr property to be found")
+	}
+	if want := (ColorInfo{Primaries: 9, Transfer: 16, Matrix: 9, FullRange: true}); got != want {
+		t.Errorf("expected %+v, got: %+v", want, got)
+	}
+
+	if _, ok := (Item{Properties: []Box{{Type: "colr", Payload: []byte("nclx")}}}).ColorInfo(); ok {
+		t.Error("expected a truncated nclx colr property to be rejected")
+	}
+
+	f, err := Parse(encodeTestAVIF(t))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, ok := f.Item(f.PrimaryItemID).ColorInfo(); !ok {
+		t.Error("expected encoded images to have an nclx colr property")
+	}
+}
+
+func TestParse_AuxiliaryAlpha(t *testing.T) {
+	f, err := Parse(encodeTestAVIF(t))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	aux := f.ReferencesTo("auxl", f.PrimaryItemID)
+	if len(aux) != 1 {
+		t.Fatalf("expected 1 auxiliary item, got: %d", len(aux))
+	}
+
+	if got := f.Item(aux[0]).AuxiliaryType(); got != "urn:mpeg:mpegB:cicp:systems:auxiliary:alpha" {
+		t.Errorf("expected alpha auxiliary type, got: %q", got)
+	}
+}
+
+func TestParse_MissingFtyp(t *testing.T) {
+	_, err := Parse(makeBox("free", nil))
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid, got: %v", err)
+	}
+}
+
+func TestParse_NoMeta(t *testing.T) {
+	f, err := Parse(makeBox("ftyp", []byte("avis\x00\x00\x00\x00avis")))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(f.Items) != 0 || f.PrimaryItemID != 0 {
+		t.Errorf("expected no items, got: %+v", f)
+	}
+	if len(f.CompatibleBrands) != 1 || f.CompatibleBrands[0] != "avis" {
+		t.Errorf("expected compatible brand avis, got: %v", f.CompatibleBrands)
+	}
+}
+
+func TestFile_IsSequence(t *testing.T) {
+	f, err := Parse(makeBox("ftyp", []byte("avis\x00\x00\x00\x00avis")))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !f.IsSequence() {
+		t.Error("expected the avis brand to make a sequence")
+	}
+
+	f, err = Parse(append(makeBox("ftyp", []byte("mif1\x00\x00\x00\x00mif1")), makeBox("moov", nil)...))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !f.IsSequence() {
+		t.Error("expected a movie box to make a sequence")
+	}
+
+	f, err = Parse(encodeTestAVIF(t))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if f.IsSequence() {
+		t.Error("expected a still image not to be a sequence")
+	}
+	if primary := f.Item(f.PrimaryItemID); primary == nil || !primary.IsImage() {
+		t.Errorf("expected the primary item to be an image, got: %+v", primary)
+	}
+	if (Item{Type: "Exif"}).IsImage() {
+		t.Error("expected Exif items not to be images")
+	}
+}
+
+// ==================== WithPrimaryItem Tests ====================
+
+func TestTopLevelImages(t *testing.T) {
+	data := encodeTestAVIF(t)
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// The alpha item belongs to the colour item
+	if ids := f.TopLevelImages(); len(ids) != 1 || ids[0] != f.PrimaryItemID {
+		t.Errorf("expected only the primary item %d, got: %v", f.PrimaryItemID, ids)
+	}
+
+	// Without its reference, the alpha item stands on its own
+	data = bytes.Replace(data, []byte("auxl"), []byte("free"), 1)
+	if f, err = Parse(data); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if ids := f.TopLevelImages(); len(ids) != 2 || ids[0] != f.PrimaryItemID {
+		t.Errorf("expected two items starting with the primary item %d, got: %v", f.PrimaryItemID, ids)
+	}
+}
+
+func TestWithPrimaryItem_DecodesAuxiliary(t *testing.T) {
+	data := encodeTestAVIF(t)
+
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	aux := f.ReferencesTo("auxl", f.PrimaryItemID)
+	patched, err := f.WithPrimaryItem(data, aux[0])
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	patchedFile, err := Parse(patched)
+	if err != nil {
+		t.Fatalf("expected patched file to parse, got: %v", err)
+	}
+	if patchedFile.PrimaryItemID != aux[0] {
+		t.Errorf("expected primary item %d, got: %d", aux[0], patchedFile.PrimaryItemID)
+	}
+	if original, _ := Parse(data); original.PrimaryItemID == aux[0] {
+		t.Error("expected original data to be left untouched")
+	}
+
+	img, err := avif.Decode(bytes.NewReader(patched))
+	if err != nil {
+		t.Fatalf("expected auxiliary image to decode, got: %v", err)
+	}
+
+	// The alpha plane decodes as a greyscale image of the original alpha values
+	r, _, _, _ := img.At(7, 0).RGBA()
+	if r>>8 < 200 || r>>8 > 220 {
+		t.Errorf("expected alpha value near 210, got: %d", r>>8)
+	}
+}
+
+func TestWithPrimaryItem_NoPitm(t *testing.T) {
+	f := &File{}
+
+	_, err := f.WithPrimaryItem(nil, 1)
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid, got: %v", err)
+	}
+}
+
+// ==================== WithoutReferences Tests ====================
+
+func TestWithoutReferences(t *testing.T) {
+	data := encodeTestAVIF(t)
+
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	patched, err := f.WithoutReferences(data, "auxl")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	patchedFile, err := Parse(patched)
+	if err != nil {
+		t.Fatalf("expected patched file to parse, got: %v", err)
+	}
+	if refs := patchedFile.ReferencesTo("auxl", patchedFile.PrimaryItemID); len(refs) != 0 {
+		t.Errorf("expected no auxl references, got: %v", refs)
+	}
+	if refs := patchedFile.ReferencesTo("free", patchedFile.PrimaryItemID); len(refs) != 1 {
+		t.Errorf("expected the reference to be retyped to free, got: %v", refs)
+	}
+	if original, _ := Parse(data); len(original.ReferencesTo("auxl", original.PrimaryItemID)) != 1 {
+		t.Error("expected original data to be left untouched")
+	}
+}
+
+func TestWithoutReferences_Mismatch(t *testing.T) {
+	data := encodeTestAVIF(t)
+
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	_, err = f.WithoutReferences(data[:16], "auxl")
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid, got: %v", err)
+	}
+}
+
+// ==================== IsPremultiplied Tests ====================
+
+func TestFile_IsPremultiplied(t *testing.T) {
+	f, err := Parse(encodeTestAVIF(t))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if f.IsPremultiplied(f.PrimaryItemID) {
+		t.Error("expected the encoder not to mark its colour as premultiplied")
+	}
+
+	// The reference goes from the colour item to its alpha item
+	f = &File{References: []Reference{{Type: "prem", FromID: 1, ToIDs: []uint32{2}}}}
+	if !f.IsPremultiplied(1) {
+		t.Error("expected item 1 to be premultiplied")
+	}
+	if f.IsPremultiplied(2) {
+		t.Error("expected the alpha item not to be premultiplied")
+	}
+}
+
+// ==================== ItemData Tests ====================
+
+func TestFile_ItemData(t *testing.T) {
+	// Item 1 is stored in two extents of mdat, item 2 in an idat box
+	ftyp := makeBox("ftyp", []byte("avif\x00\x00\x00\x00avif"))
+	iloc := func(offset uint32) []byte {
+		payload := []byte{1, 0, 0, 0, 0x44, 0, 0, 2}
+		payload = append(payload, 0, 1, 0, 0, 0, 0, 0, 2)
+		payload = binary.BigEndian.AppendUint32(payload, offset)
+		payload = binary.BigEndian.AppendUint32(payload, 3)
+		payload = binary.BigEndian.AppendUint32(payload, offset+5)
+		payload = binary.BigEndian.AppendUint32(payload, 2)
+		payload = append(payload, 0, 2, 0, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 4)
+		return makeBox("iloc", payload)
+	}
+	infe := func(id byte) []byte {
+		return makeBox("infe", []byte{2, 0, 0, 0, 0, id, 0, 0, 'E', 'x', 'i', 'f', 0})
+	}
+	build := func(offset uint32) []byte {
+		meta := makeBox("meta", append([]byte{0, 0, 0, 0}, append(makeBox("iinf", append([]byte{0, 0, 0, 0, 0, 2}, append(infe(1), infe(2)...)...)), iloc(offset)...)...))
+		return append(append(ftyp, meta...), makeBox("mdat", []byte("abc..de"))...)
+	}
+	data := build(0)
+	data = build(uint32(len(data) - 7))
+
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	got, err := f.ItemData(data, 1)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if string(got) != "abcde" {
+		t.Errorf("expected the extents to be joined, got: %q", got)
+	}
+
+	if _, err := f.ItemData(data, 2); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid for an item in idat, got: %v", err)
+	}
+	if _, err := f.ItemData(data, 3); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid for a missing item, got: %v", err)
+	}
+	if _, err := f.ItemData(data[:len(data)-1], 1); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid for an extent past the end, got: %v", err)
+	}
+
+	// A broken iloc fails only ItemData, so the rest of the file is still
+	// read; here it claims a third item past its end
+	locations := iloc(uint32(len(data) - 7))
+	truncated := bytes.Clone(locations)
+	truncated[15] = 3
+	broken := bytes.Replace(data, locations, truncated, 1)
+	if f, err = Parse(broken); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(f.Items) != 2 {
+		t.Errorf("expected 2 items, got %d", len(f.Items))
+	}
+	if _, err := f.ItemData(broken, 1); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid for a truncated iloc, got: %v", err)
+	}
+}
+
+func TestItem_ICCProfile(t *testing.T) {
+	f, err := Parse(encodeTestAVIF(t))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, ok := f.Item(f.PrimaryItemID).ICCProfile(); ok {
+		t.Error("expected the encoder to write no ICC profile")
+	}
+
+	item := Item{Properties: []Box{
+		{Type: "colr", Payload: []byte("nclx\x00\x01\x00\x0d\x00\x06\x80")},
+		{Type: "colr", Payload: []byte("profICC")},
+	}}
+	if profile, ok := item.ICCProfile(); !ok || string(profile) != "ICC" {
+		t.Errorf("expected the ICC profile after the nclx property, got %q, %v", profile, ok)
+	}
+}
@@ -0,0 +1,242 @@
+// Package isobmff is a lightweight reader for the ISO Base Media File Format
+// container used by AVIF. It only parses the boxes that describe images and
+// items, never the compressed image data itself.
+package isobmff
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalid is returned when the container structure cannot be parsed
+var ErrInvalid = errors.New("invalid ISOBMFF container")
+
+// Box is a single box within a container
+type Box struct {
+	Type string
+	// Offset is the position of the box header relative to the parsed buffer
+	Offset int
+	// HeaderSize is the size of the box header (8 or 16 bytes)
+	HeaderSize int
+	// Payload is the content of the box, without its header
+	Payload []byte
+}
+
+// Size returns the total size of the box, including its header
+func (b Box) Size() int {
+	return b.HeaderSize + len(b.Payload)
+}
+
+// ReadBoxes splits data into a sequence of boxes
+// baseOffset is added to each box's Offset, so nested boxes can report
+// positions relative to the start of the file
+func ReadBoxes(data []byte, baseOffset int) ([]Box, error) {
+	var boxes []Box
+
+	for pos := 0; pos < len(data); {
+		if len(data)-pos < 8 {
+			return boxes, fmt.Errorf("%w: truncated box header at offset %d", ErrInvalid, baseOffset+pos)
+		}
+
+		size := uint64(binary.BigEndian.Uint32(data[pos:]))
+		boxType := string(data[pos+4 : pos+8])
+		headerSize := 8
+
+		switch size {
+		case 0:
+			// The box extends to the end of the data
+			size = uint64(len(data) - pos)
+		case 1:
+			if len(data)-pos < 16 {
+				return boxes, fmt.Errorf("%w: truncated large box header at offset %d", ErrInvalid, baseOffset+pos)
+			}
+			size = binary.BigEndian.Uint64(data[pos+8:])
+			headerSize = 16
+		}
+
+		if size < uint64(headerSize) || size > uint64(len(data)-pos) {
+			return boxes, fmt.Errorf("%w: box %q at offset %d has invalid size %d", ErrInvalid, boxType, baseOffset+pos, size)
+		}
+
+		boxes = append(boxes, Box{
+			Type:       boxType,
+			Offset:     baseOffset + pos,
+			HeaderSize: headerSize,
+			Payload:    data[pos+headerSize : pos+int(size)],
+		})
+		pos += int(size)
+	}
+
+	return boxes, nil
+}
+
+// findBox returns the first box of the given type
+func findBox(boxes []Box, boxType string) (Box, bool) {
+	for _, box := range boxes {
+		if box.Type == boxType {
+			return box, true
+		}
+	}
+	return Box{}, false
+}
+
+// fullBoxHeader splits the version and flags from a full box payload
+func fullBoxHeader(payload []byte) (version uint8, flags uint32, rest []byte, err error) {
+	if len(payload) < 4 {
+		return 0, 0, nil, fmt.Errorf("%w: truncated full box", ErrInvalid)
+	}
+	return payload[0], binary.BigEndian.Uint32(payload) & 0xFFFFFF, payload[4:], nil
+}
+
+// reader is a bounds-checked big-endian reader over a box payload
+type reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *reader) need(n int) bool {
+	if r.err != nil {
+		return false
+	}
+	if len(r.data)-r.pos < n {
+		r.err = fmt.Errorf("%w: unexpected end of box", ErrInvalid)
+		return false
+	}
+	return true
+}
+
+func (r *reader) u8() uint8 {
+	if !r.need(1) {
+		return 0
+	}
+	v := r.data[r.pos]
+	r.pos++
+	return v
+}
+
+func (r *reader) u16() uint16 {
+	if !r.need(2) {
+		return 0
+	}
+	v := binary.BigEndian.Uint16(r.data[r.pos:])
+	r.pos += 2
+	return v
+}
+
+func (r *reader) u32() uint32 {
+	if !r.need(4) {
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return v
+}
+
+// sized reads an integer of 0, 4 or 8 bytes, the field sizes of iloc boxes
+func (r *reader) sized(n int) uint64 {
+	switch n {
+	case 0:
+		return 0
+	case 4:
+		return uint64(r.u32())
+	case 8:
+		hi := r.u32()
+		return uint64(hi)<<32 | uint64(r.u32())
+	}
+	if r.err == nil {
+		r.err = fmt.Errorf("%w: %d-byte iloc field", ErrInvalid, n)
+	}
+	return 0
+}
+
+// id reads an item ID, which is 16 bits wide in version 0 boxes
+func (r *reader) id(version uint8) uint32 {
+	if version == 0 {
+		return uint32(r.u16())
+	}
+	return r.u32()
+}
+
+func (r *reader) fourCC() string {
+	if !r.need(4) {
+		return ""
+	}
+	v := string(r.data[r.pos : r.pos+4])
+	r.pos += 4
+	return v
+}
+
+// cString reads a null-terminated string, or the rest of the data if unterminated
+func (r *reader) cString() string {
+	if r.err != nil {
+		return ""
+	}
+	start := r.pos
+	for r.pos < len(r.data) {
+		if r.data[r.pos] == 0 {
+			s := string(r.data[start:r.pos])
+			r.pos++
+			return s
+		}
+		r.pos++
+	}
+	return string(r.data[start:])
+}
+
+func (r *reader) rest() []byte {
+	if r.err != nil {
+		return nil
+	}
+	v := r.data[r.pos:]
+	r.pos = len(r.data)
+	return v
+}
+
+// containerBoxes are the box types whose payload is a sequence of boxes, by
+// the size of the header that precedes them (4 for full boxes)
+var containerBoxes = map[string]int{
+	"meta": 4, "iprp": 0, "ipco": 0, "dinf": 0,
+	"moov": 0, "trak": 0, "mdia": 0, "minf": 0, "stbl": 0, "edts": 0,
+}
+
+// WalkedBox is a box found by Walk
+type WalkedBox struct {
+	Box
+	// Depth is the nesting level of the box, 0 for top-level boxes
+	Depth int
+}
+
+// Walk lists the boxes of data in file order, descending into the boxes
+// that hold other boxes, such as meta and moov
+// Unlike Parse it only looks at box headers, so it lists what it can of
+// files that are too damaged to parse, returning the boxes read before the
+// first malformed one along with an error that describes it
+func Walk(data []byte) ([]WalkedBox, error) {
+	return walk(data, 0, 0)
+}
+
+func walk(data []byte, baseOffset, depth int) ([]WalkedBox, error) {
+	boxes, err := ReadBoxes(data, baseOffset)
+
+	var walked []WalkedBox
+	for _, box := range boxes {
+		walked = append(walked, WalkedBox{Box: box, Depth: depth})
+
+		skip, ok := containerBoxes[box.Type]
+		if !ok {
+			continue
+		}
+		if len(box.Payload) < skip {
+			return walked, fmt.Errorf("%w: truncated %s box at offset %d", ErrInvalid, box.Type, box.Offset)
+		}
+		children, childErr := walk(box.Payload[skip:], box.Offset+box.HeaderSize+skip, depth+1)
+		walked = append(walked, children...)
+		if childErr != nil {
+			return walked, childErr
+		}
+	}
+
+	return walked, err
+}
@@ -0,0 +1,131 @@
+package isobmff
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// makeBox builds a box with a regular 8-byte header
+func makeBox(boxType string, payload []byte) []byte {
+	box := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(box, uint32(8+len(payload)))
+	copy(box[4:], boxType)
+	return append(box, payload...)
+}
+
+// ==================== ReadBoxes Tests ====================
+
+func TestReadBoxes_Sequence(t *testing.T) {
+	data := append(makeBox("ftyp", []byte("avif")), makeBox("free", nil)...)
+
+	boxes, err := ReadBoxes(data, 0)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(boxes) != 2 {
+		t.Fatalf("expected 2 boxes, got: %d", len(boxes))
+	}
+	if boxes[0].Type != "ftyp" || string(boxes[0].Payload) != "avif" {
+		t.Errorf("unexpected first box: %+v", boxes[0])
+	}
+	if boxes[1].Type != "free" || boxes[1].Offset != 12 || boxes[1].Size() != 8 {
+		t.Errorf("unexpected second box: %+v", boxes[1])
+	}
+}
+
+func TestReadBoxes_BaseOffset(t *testing.T) {
+	boxes, err := ReadBoxes(makeBox("free", nil), 100)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if boxes[0].Offset != 100 {
+		t.Errorf("expected offset 100, got: %d", boxes[0].Offset)
+	}
+}
+
+func TestReadBoxes_SizeZeroExtendsToEnd(t *testing.T) {
+	data := makeBox("mdat", []byte{1, 2, 3})
+	binary.BigEndian.PutUint32(data, 0)
+
+	boxes, err := ReadBoxes(data, 0)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(boxes[0].Payload) != 3 {
+		t.Errorf("expected payload of 3 bytes, got: %d", len(boxes[0].Payload))
+	}
+}
+
+func TestReadBoxes_LargeSize(t *testing.T) {
+	data := make([]byte, 16, 18)
+	binary.BigEndian.PutUint32(data, 1)
+	copy(data[4:], "mdat")
+	binary.BigEndian.PutUint64(data[8:], 18)
+	data = append(data, 7, 8)
+
+	boxes, err := ReadBoxes(data, 0)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if boxes[0].HeaderSize != 16 || len(boxes[0].Payload) != 2 {
+		t.Errorf("unexpected large box: %+v", boxes[0])
+	}
+}
+
+func TestReadBoxes_Truncated(t *testing.T) {
+	data := makeBox("ftyp", []byte("avif"))
+
+	_, err := ReadBoxes(data[:len(data)-1], 0)
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid, got: %v", err)
+	}
+
+	_, err = ReadBoxes(data[:5], 0)
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid for truncated header, got: %v", err)
+	}
+}
+
+// ==================== Walk Tests ====================
+
+func TestWalk_Nested(t *testing.T) {
+	meta := append([]byte{0, 0, 0, 0}, makeBox("hdlr", []byte("pict"))...)
+	meta = append(meta, makeBox("iprp", makeBox("ipco", makeBox("ispe", make([]byte, 12))))...)
+	data := append(makeBox("ftyp", []byte("avif")), makeBox("meta", meta)...)
+
+	boxes, err := Walk(data)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	want := []struct {
+		boxType       string
+		depth, offset int
+	}{{"ftyp", 0, 0}, {"meta", 0, 12}, {"hdlr", 1, 24}, {"iprp", 1, 36}, {"ipco", 2, 44}, {"ispe", 3, 52}}
+	if len(boxes) != len(want) {
+		t.Fatalf("expected %d boxes, got %d: %+v", len(want), len(boxes), boxes)
+	}
+	for i, w := range want {
+		if boxes[i].Type != w.boxType || boxes[i].Depth != w.depth || boxes[i].Offset != w.offset {
+			t.Errorf("box %d: expected %s at depth %d offset %d, got %s at depth %d offset %d",
+				i, w.boxType, w.depth, w.offset, boxes[i].Type, boxes[i].Depth, boxes[i].Offset)
+		}
+	}
+}
+
+func TestWalk_Truncated(t *testing.T) {
+	data := append(makeBox("ftyp", []byte("avif")), makeBox("mdat", make([]byte, 16))...)
+
+	boxes, err := Walk(data[:len(data)-4])
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid, got: %v", err)
+	}
+	if len(boxes) != 1 || boxes[0].Type != "ftyp" {
+		t.Errorf("expected the boxes before the damage, got: %+v", boxes)
+	}
+}
@@ -0,0 +1,215 @@
+// Package pdf writes images to a minimal PDF document, one image per page.
+// Pages are sized to fit their image at 72 DPI, and images are embedded
+// losslessly with Flate compression, with alpha kept as a soft mask.
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// MaxPageSize is the largest page side PDF viewers are required to support,
+// in points; larger images are scaled down to fit
+const MaxPageSize = 14400
+
+// ErrNoPages is returned when a document is closed without any pages
+var ErrNoPages = errors.New("PDF has no pages")
+
+// Reserved object numbers, written when the document is closed
+const (
+	catalogObject = 1
+	pagesObject   = 2
+)
+
+// Writer assembles a PDF document, writing each page as it is added
+type Writer struct {
+	w       *bufio.Writer
+	offset  int64
+	offsets []int64 // offsets[n-1] is the position of object n
+	pages   []int
+	err     error
+}
+
+// NewWriter starts a PDF document on w
+// Close must be called to complete the document
+func NewWriter(w io.Writer) *Writer {
+	pw := &Writer{
+		w:       bufio.NewWriter(w),
+		offsets: make([]int64, pagesObject),
+	}
+	// The binary comment marks the file as binary for transfer tools
+	pw.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	return pw
+}
+
+// AddImage appends a page showing img
+func (pw *Writer) AddImage(img image.Image) error {
+	if pw.err != nil {
+		return pw.err
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return fmt.Errorf("cannot add empty image (%dx%d)", width, height)
+	}
+
+	rgb, alpha := splitPixels(img)
+
+	smask := 0
+	if alpha != nil {
+		smask = pw.writeImageObject(width, height, "/DeviceGray", alpha, 0)
+	}
+	imageRef := pw.writeImageObject(width, height, "/DeviceRGB", rgb, smask)
+
+	pageWidth, pageHeight := fitPage(width, height)
+	content := fmt.Sprintf("q %s 0 0 %s 0 0 cm /Im0 Do Q\n", formatNumber(pageWidth), formatNumber(pageHeight))
+	contentRef := pw.writeStreamObject("", []byte(content))
+
+	page := pw.beginObject()
+	pw.printf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>\nendobj\n",
+		pagesObject, formatNumber(pageWidth), formatNumber(pageHeight), imageRef, contentRef)
+	pw.pages = append(pw.pages, page)
+
+	return pw.err
+}
+
+// PageCount returns the number of pages added so far
+func (pw *Writer) PageCount() int {
+	return len(pw.pages)
+}
+
+// Close writes the page tree, cross-reference table and trailer
+func (pw *Writer) Close() error {
+	if pw.err != nil {
+		return pw.err
+	}
+	if len(pw.pages) == 0 {
+		return ErrNoPages
+	}
+
+	pw.offsets[pagesObject-1] = pw.offset
+	pw.printf("%d 0 obj\n<< /Type /Pages /Kids [", pagesObject)
+	for i, page := range pw.pages {
+		if i > 0 {
+			pw.printf(" ")
+		}
+		pw.printf("%d 0 R", page)
+	}
+	pw.printf("] /Count %d >>\nendobj\n", len(pw.pages))
+
+	pw.offsets[catalogObject-1] = pw.offset
+	pw.printf("%d 0 obj\n<< /Type /Catalog /Pages %d 0 R >>\nendobj\n", catalogObject, pagesObject)
+
+	xref := pw.offset
+	pw.printf("xref\n0 %d\n0000000000 65535 f \n", len(pw.offsets)+1)
+	for _, offset := range pw.offsets {
+		pw.printf("%010d 00000 n \n", offset)
+	}
+	pw.printf("trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(pw.offsets)+1, catalogObject, xref)
+
+	if pw.err != nil {
+		return pw.err
+	}
+	return pw.w.Flush()
+}
+
+// beginObject records the position of a new object and writes its header
+func (pw *Writer) beginObject() int {
+	pw.offsets = append(pw.offsets, pw.offset)
+	n := len(pw.offsets)
+	pw.printf("%d 0 obj\n", n)
+	return n
+}
+
+// writeStreamObject writes a stream object with extra dictionary entries
+func (pw *Writer) writeStreamObject(dict string, data []byte) int {
+	n := pw.beginObject()
+	pw.printf("<<%s /Length %d >>\nstream\n", dict, len(data))
+	pw.write(data)
+	pw.printf("\nendstream\nendobj\n")
+	return n
+}
+
+// writeImageObject writes Flate-compressed 8-bit samples as an image XObject
+func (pw *Writer) writeImageObject(width, height int, colorSpace string, samples []byte, smask int) int {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(samples); err != nil && pw.err == nil {
+		pw.err = err
+	}
+	if err := zw.Close(); err != nil && pw.err == nil {
+		pw.err = err
+	}
+
+	dict := fmt.Sprintf(" /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /FlateDecode",
+		width, height, colorSpace)
+	if smask != 0 {
+		dict += fmt.Sprintf(" /SMask %d 0 R", smask)
+	}
+
+	return pw.writeStreamObject(dict, buf.Bytes())
+}
+
+func (pw *Writer) printf(format string, args ...any) {
+	pw.write([]byte(fmt.Sprintf(format, args...)))
+}
+
+func (pw *Writer) write(data []byte) {
+	if pw.err != nil {
+		return
+	}
+	n, err := pw.w.Write(data)
+	pw.offset += int64(n)
+	pw.err = err
+}
+
+// splitPixels returns the RGB samples of img and its alpha samples, which
+// are nil when the image is fully opaque
+func splitPixels(img image.Image) (rgb, alpha []byte) {
+	bounds := img.Bounds()
+	pixels := bounds.Dx() * bounds.Dy()
+	rgb = make([]byte, 0, pixels*3)
+	alphaSamples := make([]byte, 0, pixels)
+	opaque := true
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			rgb = append(rgb, c.R, c.G, c.B)
+			alphaSamples = append(alphaSamples, c.A)
+			if c.A != 0xFF {
+				opaque = false
+			}
+		}
+	}
+
+	if opaque {
+		return rgb, nil
+	}
+	return rgb, alphaSamples
+}
+
+// fitPage returns the page size in points for an image, one point per pixel
+// unless that exceeds MaxPageSize
+func fitPage(width, height int) (float64, float64) {
+	scale := 1.0
+	if longest := max(width, height); longest > MaxPageSize {
+		scale = float64(MaxPageSize) / float64(longest)
+	}
+	return float64(width) * scale, float64(height) * scale
+}
+
+// formatNumber formats a PDF real number without a trailing fraction when integral
+func formatNumber(v float64) string {
+	if v == float64(int64(v)) {
+		return fmt.Sprintf("%d", int64(v))
+	}
+	return fmt.Sprintf("%.2f", v)
+}
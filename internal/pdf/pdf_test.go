@@ -0,0 +1,118 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// solidImage returns a width x height image filled with c
+func solidImage(width, height int, c color.Color) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// checkXref verifies that every cross-reference entry points at its object
+func checkXref(t *testing.T, doc []byte) {
+	t.Helper()
+
+	match := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(doc)
+	if match == nil {
+		t.Fatal("expected document to end with startxref and EOF marker")
+	}
+	xref, _ := strconv.Atoi(string(match[1]))
+	if !bytes.HasPrefix(doc[xref:], []byte("xref\n")) {
+		t.Fatalf("expected startxref to point at the xref table, got: %q", doc[xref:min(len(doc), xref+10)])
+	}
+
+	lines := strings.Split(string(doc[xref:]), "\n")
+	var count int
+	fmt.Sscanf(lines[1], "0 %d", &count)
+	for n := 1; n < count; n++ {
+		offset, _ := strconv.Atoi(lines[2+n][:10])
+		if want := fmt.Sprintf("%d 0 obj\n", n); !bytes.HasPrefix(doc[offset:], []byte(want)) {
+			t.Errorf("expected object %d at offset %d, got: %q", n, offset, doc[offset:min(len(doc), offset+10)])
+		}
+	}
+}
+
+// ==================== Writer Tests ====================
+
+func TestWriter_Pages(t *testing.T) {
+	var buf bytes.Buffer
+	pw := NewWriter(&buf)
+
+	if err := pw.AddImage(solidImage(20, 10, color.White)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := pw.AddImage(solidImage(5, 8, color.Black)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	doc := buf.String()
+	if !strings.HasPrefix(doc, "%PDF-1.4\n") {
+		t.Errorf("expected PDF header, got: %q", doc[:10])
+	}
+	if !strings.Contains(doc, "/Count 2") {
+		t.Error("expected 2 pages in the page tree")
+	}
+	if !strings.Contains(doc, "/MediaBox [0 0 20 10]") || !strings.Contains(doc, "/MediaBox [0 0 5 8]") {
+		t.Error("expected pages to be sized to their images")
+	}
+	if strings.Contains(doc, "/SMask") {
+		t.Error("expected no soft mask for opaque images")
+	}
+
+	checkXref(t, buf.Bytes())
+}
+
+func TestWriter_AlphaSoftMask(t *testing.T) {
+	var buf bytes.Buffer
+	pw := NewWriter(&buf)
+
+	if err := pw.AddImage(solidImage(4, 4, color.NRGBA{255, 0, 0, 128})); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "/DeviceGray") || !strings.Contains(buf.String(), "/SMask") {
+		t.Error("expected alpha to be embedded as a soft mask")
+	}
+
+	checkXref(t, buf.Bytes())
+}
+
+func TestWriter_NoPages(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewWriter(&buf).Close(); !errors.Is(err, ErrNoPages) {
+		t.Errorf("expected ErrNoPages, got: %v", err)
+	}
+}
+
+func TestFitPage_ScalesLargeImages(t *testing.T) {
+	width, height := fitPage(28800, 7200)
+	if width != MaxPageSize || height != 3600 {
+		t.Errorf("expected %dx3600, got: %vx%v", MaxPageSize, width, height)
+	}
+
+	width, height = fitPage(640, 480)
+	if width != 640 || height != 480 {
+		t.Errorf("expected 640x480, got: %vx%v", width, height)
+	}
+}
@@ -0,0 +1,291 @@
+package report
+
+import (
+	"avif2png/internal/converter"
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// jsonFile is the JSON representation of a single file result
+type jsonFile struct {
+	Input       string   `json:"input"`
+	Output      string   `json:"output"`
+	Status      string   `json:"status"`
+	Format      string   `json:"format,omitempty"`
+	Source      string   `json:"source_format,omitempty"`
+	SkipReason  string   `json:"skip_reason,omitempty"`
+	DuplicateOf string   `json:"duplicate_of,omitempty"`
+	Warning     string   `json:"warning,omitempty"`
+	Checksum    string   `json:"checksum,omitempty"`
+	PSNR        *float64 `json:"psnr,omitempty"`
+	InputSize   int64    `json:"input_size"`
+	OutputSize  int64    `json:"output_size,omitempty"`
+	Width       int      `json:"width,omitempty"`
+	Height      int      `json:"height,omitempty"`
+	DurationMS  int64    `json:"duration_ms"`
+	Error       string   `json:"error,omitempty"`
+	Quarantine  string   `json:"quarantined_to,omitempty"`
+}
+
+// jsonResult is the JSON representation of a bulk conversion
+type jsonResult struct {
+	TotalFiles     int            `json:"total_files"`
+	Successful     int            `json:"successful"`
+	Skipped        int            `json:"skipped"`
+	SkippedReasons map[string]int `json:"skipped_reasons"`
+	// FilteredReasons counts AVIF files left out of the scan, which are not
+	// part of TotalFiles
+	FilteredReasons map[string]int `json:"filtered_reasons,omitempty"`
+	Failed          int            `json:"failed"`
+	Empty           int            `json:"empty"`
+	Files           []jsonFile     `json:"files"`
+	Resources       *jsonResources `json:"resources,omitempty"`
+}
+
+// jsonResources is the JSON representation of the resources used by a bulk
+// conversion; CPU time and parallelism are omitted where unknown
+type jsonResources struct {
+	ElapsedMS   int64   `json:"elapsed_ms"`
+	CPUTimeMS   int64   `json:"cpu_time_ms,omitempty"`
+	Parallelism float64 `json:"parallelism,omitempty"`
+	PeakHeap    uint64  `json:"peak_heap_bytes"`
+}
+
+// WriteJSON writes a bulk conversion result to w as JSON
+// indent is the number of spaces used for pretty-printing; 0 writes compact JSON
+// The output always ends with exactly one newline
+func WriteJSON(w io.Writer, result *converter.ConversionResult, indent int) error {
+	data := jsonResult{
+		TotalFiles: result.TotalFiles,
+		Successful: result.Successful,
+		Skipped:    result.Skipped(),
+		Failed:     result.Failed,
+		Empty:      result.Empty,
+		Files:      make([]jsonFile, 0, len(result.Files)),
+	}
+
+	data.SkippedReasons = result.SkippedReasons
+	if data.SkippedReasons == nil {
+		data.SkippedReasons = map[string]int{}
+	}
+	if result.Filtered() > 0 {
+		data.FilteredReasons = result.FilteredReasons
+	}
+	if usage := result.Resources; usage != nil {
+		data.Resources = &jsonResources{
+			ElapsedMS:   usage.Elapsed.Milliseconds(),
+			CPUTimeMS:   usage.CPUTime.Milliseconds(),
+			Parallelism: math.Round(usage.Parallelism()*100) / 100,
+			PeakHeap:    usage.PeakHeap,
+		}
+	}
+
+	for _, file := range result.Files {
+		entry := jsonFile{
+			Input:       file.InputPath,
+			Output:      file.OutputPath,
+			Status:      string(file.Status),
+			Format:      file.Format,
+			Source:      file.SourceFormat,
+			SkipReason:  file.SkipReason,
+			DuplicateOf: file.DuplicateOf,
+			Warning:     file.Warning,
+			Checksum:    file.Checksum,
+			InputSize:   file.InputSize,
+			OutputSize:  file.OutputSize,
+			Width:       file.Width,
+			Height:      file.Height,
+			DurationMS:  file.Duration.Milliseconds(),
+		}
+		// JSON cannot represent the +Inf PSNR of identical outputs
+		if file.PSNR != 0 && !math.IsInf(file.PSNR, 0) {
+			psnr := file.PSNR
+			entry.PSNR = &psnr
+		}
+		if file.Error != nil {
+			entry.Error = file.Error.Error()
+			entry.Quarantine = file.QuarantinePath
+		}
+		data.Files = append(data.Files, entry)
+	}
+
+	return writeJSONValue(w, data, indent)
+}
+
+// jsonBenchmark is the JSON representation of a benchmark run
+type jsonBenchmark struct {
+	Conversions int     `json:"conversions"`
+	Failed      int     `json:"failed"`
+	Workers     int     `json:"workers"`
+	ElapsedMS   int64   `json:"elapsed_ms"`
+	PerSecond   float64 `json:"conversions_per_second"`
+	P50MS       float64 `json:"p50_ms"`
+	P99MS       float64 `json:"p99_ms"`
+	PeakHeap    uint64  `json:"peak_heap_bytes"`
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	InputSize   int     `json:"input_size"`
+	OutputSize  int64   `json:"output_size"`
+}
+
+// WriteBenchmarkJSON writes a benchmark result to w as JSON, like WriteJSON
+// Latencies are in fractional milliseconds, since fast conversions take
+// less than one
+func WriteBenchmarkJSON(w io.Writer, result *converter.BenchmarkResult, indent int) error {
+	return writeJSONValue(w, jsonBenchmark{
+		Conversions: result.Conversions,
+		Failed:      result.Failed,
+		Workers:     result.Workers,
+		ElapsedMS:   result.Elapsed.Milliseconds(),
+		PerSecond:   result.PerSecond,
+		P50MS:       float64(result.P50) / float64(time.Millisecond),
+		P99MS:       float64(result.P99) / float64(time.Millisecond),
+		PeakHeap:    result.PeakHeap,
+		Width:       result.Width,
+		Height:      result.Height,
+		InputSize:   result.InputSize,
+		OutputSize:  result.OutputSize,
+	}, indent)
+}
+
+// Statuses of the entries of a dry-run plan
+const (
+	PlanConvert = "would-convert"
+	PlanSkip    = "would-skip"
+	PlanFail    = "would-fail"
+)
+
+// jsonPlannedFile is the JSON representation of a file of a dry run
+type jsonPlannedFile struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	Status string `json:"status"`
+	// Reason explains skips and failures, and conversions that would
+	// replace an existing output
+	Reason string `json:"reason,omitempty"`
+}
+
+// reasonOverwrite is the reason of planned conversions that would replace
+// an existing output
+const reasonOverwrite = "overwrites existing output"
+
+// WritePlanJSON writes the files of a dry run to w as a JSON array, like
+// WriteJSON, with the output each one would be written to and whether it
+// would be converted (PlanConvert) or skipped (PlanSkip)
+func WritePlanJSON(w io.Writer, result *converter.ConversionResult, indent int) error {
+	plan := make([]jsonPlannedFile, 0, len(result.Files))
+	for _, file := range result.Files {
+		plan = append(plan, plannedFile(file))
+	}
+	return writeJSONValue(w, plan, indent)
+}
+
+// plannedFile describes what a dry run would do with a file
+func plannedFile(file converter.FileResult) jsonPlannedFile {
+	entry := jsonPlannedFile{Input: file.InputPath, Output: file.OutputPath, Status: PlanConvert}
+	switch file.Status {
+	case converter.StatusSkipped:
+		entry.Status, entry.Reason = PlanSkip, file.SkipReason
+	case converter.StatusFailed:
+		entry.Status = PlanFail
+		if file.Error != nil {
+			entry.Reason = file.Error.Error()
+		}
+	case converter.StatusOverwritten:
+		entry.Reason = reasonOverwrite
+	}
+	return entry
+}
+
+// jsonProgress is the JSON representation of a conversion in flight
+type jsonProgress struct {
+	Processed int     `json:"processed"`
+	Total     int     `json:"total"`
+	Progress  float64 `json:"progress"`
+	ElapsedMS int64   `json:"elapsed_ms"`
+	// ETAMS is the estimated time left, null until a file was processed
+	ETAMS *int64 `json:"eta_ms"`
+}
+
+// WriteProgressJSON writes the progress of a conversion in flight to w as
+// JSON, like WriteJSON
+// Only the counters behind ConversionResult.Progress are read, so it may be
+// called from another goroutine while the conversion runs
+func WriteProgressJSON(w io.Writer, result *converter.ConversionResult, elapsed time.Duration, indent int) error {
+	processed, total := result.Counts()
+	progress := jsonProgress{
+		Processed: processed,
+		Total:     total,
+		Progress:  result.Progress(),
+		ElapsedMS: elapsed.Milliseconds(),
+	}
+	if eta, ok := result.ETA(); ok {
+		ms := eta.Milliseconds()
+		progress.ETAMS = &ms
+	}
+	return writeJSONValue(w, progress, indent)
+}
+
+// jsonCapabilities is the JSON representation of the capabilities of a build
+type jsonCapabilities struct {
+	Version   string      `json:"version"`
+	GoVersion string      `json:"go_version"`
+	Platform  string      `json:"platform"`
+	Decoder   jsonDecoder `json:"decoder"`
+	Formats   []string    `json:"formats"`
+	Features  []string    `json:"features"`
+}
+
+// jsonDecoder is the JSON representation of the linked AVIF decoder
+type jsonDecoder struct {
+	Module  string `json:"module"`
+	Version string `json:"version"`
+	Backend string `json:"backend"`
+}
+
+// WriteCapabilitiesJSON writes the capabilities of a build to w as JSON,
+// like WriteJSON
+func WriteCapabilitiesJSON(w io.Writer, caps *converter.Capabilities, indent int) error {
+	data := jsonCapabilities{
+		Version:   caps.Version,
+		GoVersion: caps.GoVersion,
+		Platform:  caps.Platform,
+		Decoder: jsonDecoder{
+			Module:  caps.Decoder.Module,
+			Version: caps.Decoder.Version,
+			Backend: caps.Decoder.Backend,
+		},
+		Formats:  caps.Formats,
+		Features: caps.Features,
+	}
+	// Lists are always arrays, never null
+	if data.Formats == nil {
+		data.Formats = []string{}
+	}
+	if data.Features == nil {
+		data.Features = []string{}
+	}
+	return writeJSONValue(w, data, indent)
+}
+
+// writeJSONValue encodes v into a buffer and writes it to w in a single call,
+// so partial documents are never emitted if encoding fails
+func writeJSONValue(w io.Writer, v any, indent int) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	if indent > 0 {
+		encoder.SetIndent("", strings.Repeat(" ", indent))
+	}
+
+	// Encode terminates the document with a single newline
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,266 @@
+package report
+
+import (
+	"avif2png/internal/converter"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ==================== WriteJSON Tests ====================
+
+func TestWriteJSON_Compact(t *testing.T) {
+	result := &converter.ConversionResult{
+		TotalFiles:      5,
+		Successful:      1,
+		SkippedReasons:  map[string]int{converter.SkipReasonExists: 1, converter.SkipReasonChanged: 1, converter.SkipReasonIdentical: 1},
+		FilteredReasons: map[string]int{converter.FilterReasonHidden: 2},
+		Failed:          1,
+		Files: []converter.FileResult{
+			{InputPath: "a.avif", OutputPath: "out/a.png", Status: converter.StatusConverted, Format: "png", InputSize: 10, OutputSize: 20, Width: 4, Height: 3, Duration: 1500 * time.Millisecond, Warning: converter.WarningSingleColor},
+			{InputPath: "b.avif", OutputPath: "out/b.png", Status: converter.StatusFailed, Error: errors.New("bad data")},
+			{InputPath: "c.avif", OutputPath: "out/c.png", Status: converter.StatusSkipped, SkipReason: converter.SkipReasonExists},
+			{InputPath: "d.avif", OutputPath: "out/d.png", Status: converter.StatusSkipped, SkipReason: converter.SkipReasonChanged, PSNR: 41.5},
+			{InputPath: "e.avif", OutputPath: "out/e.png", Status: converter.StatusSkipped, SkipReason: converter.SkipReasonIdentical, PSNR: math.Inf(1)},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, result, 0); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	output := buf.String()
+	if strings.Count(output, "\n") != 1 || !strings.HasSuffix(output, "}\n") {
+		t.Errorf("expected compact JSON with exactly one trailing newline, got: %q", output)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("expected valid JSON, got: %v", err)
+	}
+	if decoded["total_files"].(float64) != 5 {
+		t.Errorf("expected total_files 5, got: %v", decoded["total_files"])
+	}
+
+	files := decoded["files"].([]any)
+	if files[0].(map[string]any)["format"] != "png" {
+		t.Errorf("expected format to be included, got: %v", files[0])
+	}
+	if first := files[0].(map[string]any); first["width"] != 4.0 || first["height"] != 3.0 || first["duration_ms"] != 1500.0 {
+		t.Errorf("expected dimensions and duration to be included, got: %v", first)
+	}
+	if files[0].(map[string]any)["warning"] != converter.WarningSingleColor {
+		t.Errorf("expected warning to be included, got: %v", files[0])
+	}
+	if _, ok := files[2].(map[string]any)["format"]; ok {
+		t.Errorf("expected format to be omitted for skipped files, got: %v", files[2])
+	}
+	if files[1].(map[string]any)["error"] != "bad data" {
+		t.Errorf("expected error message to be included, got: %v", files[1])
+	}
+	if files[2].(map[string]any)["skip_reason"] != converter.SkipReasonExists {
+		t.Errorf("expected skip reason to be included, got: %v", files[2])
+	}
+	if files[3].(map[string]any)["psnr"] != 41.5 {
+		t.Errorf("expected PSNR of a changed output to be included, got: %v", files[3])
+	}
+	if _, ok := files[4].(map[string]any)["psnr"]; ok {
+		t.Errorf("expected infinite PSNR to be omitted, got: %v", files[4])
+	}
+	if decoded["skipped"].(float64) != 3 {
+		t.Errorf("expected skipped total 3, got: %v", decoded["skipped"])
+	}
+	reasons := decoded["skipped_reasons"].(map[string]any)
+	if reasons[converter.SkipReasonExists].(float64) != 1 {
+		t.Errorf("expected skipped_reasons to be included, got: %v", reasons)
+	}
+	filtered := decoded["filtered_reasons"].(map[string]any)
+	if filtered[converter.FilterReasonHidden].(float64) != 2 {
+		t.Errorf("expected filtered_reasons to be included, got: %v", filtered)
+	}
+}
+
+func TestWriteJSON_Indented(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, &converter.ConversionResult{}, 4); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "\n    \"total_files\": 0") {
+		t.Errorf("expected 4-space indentation, got: %q", output)
+	}
+	if !strings.HasSuffix(output, "}\n") || strings.HasSuffix(output, "\n\n") {
+		t.Errorf("expected exactly one trailing newline, got: %q", output)
+	}
+}
+
+func TestWriteJSON_EmptyFilesIsArray(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, &converter.ConversionResult{}, 0); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), `"files":[]`) {
+		t.Errorf("expected files to be an empty array, got: %s", buf.String())
+	}
+}
+
+func TestWriteJSON_Resources(t *testing.T) {
+	result := &converter.ConversionResult{
+		Resources: &converter.ResourceUsage{Elapsed: 2 * time.Second, CPUTime: 3 * time.Second, PeakHeap: 1 << 20},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, result, 0); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"resources":{"elapsed_ms":2000,"cpu_time_ms":3000,"parallelism":1.5,"peak_heap_bytes":1048576}`) {
+		t.Errorf("expected resources to be included, got: %s", buf.String())
+	}
+
+	// Without --threads-report there is nothing to report
+	buf.Reset()
+	if err := WriteJSON(&buf, &converter.ConversionResult{}, 0); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if strings.Contains(buf.String(), "resources") {
+		t.Errorf("expected resources to be omitted, got: %s", buf.String())
+	}
+}
+
+// ==================== WriteBenchmarkJSON Tests ====================
+
+func TestWriteBenchmarkJSON(t *testing.T) {
+	result := &converter.BenchmarkResult{
+		Conversions: 120,
+		Workers:     2,
+		Elapsed:     2 * time.Second,
+		PerSecond:   60,
+		P50:         1500 * time.Microsecond,
+		P99:         4 * time.Millisecond,
+		PeakHeap:    1 << 20,
+		Width:       512,
+		Height:      512,
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBenchmarkJSON(&buf, result, 0); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("expected valid JSON, got: %v", err)
+	}
+	want := map[string]float64{
+		"conversions":            120,
+		"workers":                2,
+		"elapsed_ms":             2000,
+		"conversions_per_second": 60,
+		"p50_ms":                 1.5,
+		"p99_ms":                 4,
+		"peak_heap_bytes":        1 << 20,
+		"width":                  512,
+	}
+	for key, value := range want {
+		if decoded[key] != value {
+			t.Errorf("expected %s to be %v, got: %v", key, value, decoded[key])
+		}
+	}
+}
+
+func TestWritePlanJSON(t *testing.T) {
+	result := &converter.ConversionResult{
+		Files: []converter.FileResult{
+			{InputPath: "a.avif", OutputPath: "out/a.png", Status: converter.StatusConverted},
+			{InputPath: "b.avif", OutputPath: "out/b.png", Status: converter.StatusOverwritten},
+			{InputPath: "c.avif", OutputPath: "out/c.png", Status: converter.StatusSkipped, SkipReason: converter.SkipReasonExists},
+			{InputPath: "d.avif", OutputPath: "out/d.png", Status: converter.StatusFailed, Error: errors.New("bad name")},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WritePlanJSON(&buf, result, 0); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var plan []map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &plan); err != nil {
+		t.Fatalf("expected a valid JSON array, got: %v", err)
+	}
+	want := []map[string]string{
+		{"input": "a.avif", "output": "out/a.png", "status": PlanConvert},
+		{"input": "b.avif", "output": "out/b.png", "status": PlanConvert, "reason": "overwrites existing output"},
+		{"input": "c.avif", "output": "out/c.png", "status": PlanSkip, "reason": converter.SkipReasonExists},
+		{"input": "d.avif", "output": "out/d.png", "status": PlanFail, "reason": "bad name"},
+	}
+	if len(plan) != len(want) {
+		t.Fatalf("expected %d entries, got: %v", len(want), plan)
+	}
+	for i, entry := range plan {
+		if len(entry) != len(want[i]) {
+			t.Errorf("expected %v, got %v", want[i], entry)
+		}
+		for key, value := range want[i] {
+			if entry[key] != value {
+				t.Errorf("expected %s to be %q, got %q", key, value, entry[key])
+			}
+		}
+	}
+
+	// An empty plan is an array, never null
+	buf.Reset()
+	if err := WritePlanJSON(&buf, &converter.ConversionResult{}, 0); err != nil || buf.String() != "[]\n" {
+		t.Errorf("expected an empty array, got %q, %v", buf.String(), err)
+	}
+}
+
+func TestWriteCapabilitiesJSON(t *testing.T) {
+	caps := &converter.Capabilities{
+		Version:   "v1.2.3",
+		GoVersion: "go1.21.0",
+		Platform:  "linux/amd64",
+		Decoder:   converter.DecoderInfo{Module: "github.com/gen2brain/avif", Version: "v0.4.0", Backend: converter.BackendWasm},
+		Formats:   []string{"gif", "jpeg", "png"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCapabilitiesJSON(&buf, caps, 0); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	want := `{"version":"v1.2.3","go_version":"go1.21.0","platform":"linux/amd64",` +
+		`"decoder":{"module":"github.com/gen2brain/avif","version":"v0.4.0","backend":"wasm"},` +
+		`"formats":["gif","jpeg","png"],"features":[]}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("expected %s, got: %s", want, got)
+	}
+}
+
+// ==================== WriteProgressJSON Tests ====================
+
+func TestWriteProgressJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteProgressJSON(&buf, &converter.ConversionResult{}, 1500*time.Millisecond, 0); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("expected valid JSON, got: %v", err)
+	}
+	want := map[string]float64{"processed": 0, "total": 0, "progress": 0, "elapsed_ms": 1500}
+	for key, value := range want {
+		if decoded[key] != value {
+			t.Errorf("expected %s to be %v, got %v", key, value, decoded[key])
+		}
+	}
+	if eta, ok := decoded["eta_ms"]; !ok || eta != nil {
+		t.Errorf("expected eta_ms to be null while estimating, got %v", eta)
+	}
+}
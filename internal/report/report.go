@@ -0,0 +1,195 @@
+package report
+
+import (
+	"avif2png/internal/converter"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// PreviewSize is the maximum width or height of an embedded preview image
+const PreviewSize = 96
+
+// reportRow holds the template data for a single file in the report
+type reportRow struct {
+	Input      string
+	Output     string
+	InputSize  string
+	OutputSize string
+	Status     string
+	Error      string
+	Warning    string
+	Preview    template.URL
+}
+
+// reportData holds the template data for the whole report
+type reportData struct {
+	TotalFiles int
+	Successful int
+	Skipped    int
+	Failed     int
+	Rows       []reportRow
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>avif2png conversion report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+.converted, .overwritten { color: #1a7f37; }
+.skipped { color: #9a6700; }
+.failed { color: #cf222e; }
+</style>
+</head>
+<body>
+<h1>avif2png conversion report</h1>
+<p>{{.TotalFiles}} file(s): {{.Successful}} converted, {{.Skipped}} skipped, {{.Failed}} failed</p>
+<table>
+<tr><th>Preview</th><th>Input</th><th>Output</th><th>Input size</th><th>Output size</th><th>Status</th></tr>
+{{- range .Rows}}
+<tr>
+<td>{{if .Preview}}<img src="{{.Preview}}" alt="{{.Output}}">{{end}}</td>
+<td>{{.Input}}</td>
+<td>{{.Output}}</td>
+<td>{{.InputSize}}</td>
+<td>{{.OutputSize}}</td>
+<td class="{{.Status}}">{{.Status}}{{if .Error}}: {{.Error}}{{end}}{{if .Warning}} (looks blank: {{.Warning}}){{end}}</td>
+</tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// WriteHTML renders an HTML report of a bulk conversion to w
+// If previews is true, converted outputs are embedded as small PNG thumbnails
+func WriteHTML(w io.Writer, result *converter.ConversionResult, previews bool) error {
+	data := reportData{
+		TotalFiles: result.TotalFiles,
+		Successful: result.Successful,
+		Skipped:    result.Skipped(),
+		Failed:     result.Failed,
+	}
+
+	for _, file := range result.Files {
+		row := reportRow{
+			Input:     file.InputPath,
+			Output:    file.OutputPath,
+			InputSize: formatSize(file.InputSize),
+			Status:    string(file.Status),
+			Warning:   file.Warning,
+		}
+		if file.Status.Succeeded() {
+			row.OutputSize = formatSize(file.OutputSize)
+		}
+		if file.Error != nil {
+			row.Error = file.Error.Error()
+		}
+
+		// A missing preview should not prevent the report from being written
+		if previews && file.Status.Succeeded() {
+			if preview, err := previewDataURI(file.OutputPath); err == nil {
+				row.Preview = preview
+			}
+		}
+
+		data.Rows = append(data.Rows, row)
+	}
+
+	return htmlTemplate.Execute(w, data)
+}
+
+// WriteHTMLFile renders an HTML report of a bulk conversion to the file at path
+func WriteHTMLFile(path string, result *converter.ConversionResult, previews bool) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	defer file.Close()
+
+	if err := WriteHTML(file, result, previews); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	return nil
+}
+
+// previewDataURI loads an output image, scales it down and returns it as a PNG data URI
+func previewDataURI(path string) (template.URL, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, thumbnail(img, PreviewSize)); err != nil {
+		return "", err
+	}
+
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+// thumbnail scales img down so that neither side exceeds maxSize
+// Nearest-neighbour sampling is used, which is good enough for previews
+func thumbnail(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return img
+	}
+
+	newWidth, newHeight := maxSize, maxSize
+	if width > height {
+		newHeight = max(1, height*maxSize/width)
+	} else {
+		newWidth = max(1, width*maxSize/height)
+	}
+
+	thumb := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		for x := 0; x < newWidth; x++ {
+			srcX := bounds.Min.X + x*width/newWidth
+			srcY := bounds.Min.Y + y*height/newHeight
+			thumb.Set(x, y, img.At(srcX, srcY))
+		}
+	}
+
+	return thumb
+}
+
+// formatSize returns a human-readable byte size
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,173 @@
+package report
+
+import (
+	"avif2png/internal/converter"
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// createTestPNG creates a simple PNG image file for testing
+func createTestPNG(t *testing.T, path string, width, height int) {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	blue := color.RGBA{0, 0, 255, 255}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, blue)
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create test PNG file: %v", err)
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, img); err != nil {
+		t.Fatalf("failed to encode test PNG: %v", err)
+	}
+}
+
+// setupTestDir creates a temporary directory for tests
+func setupTestDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "avif2png-report-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	return dir
+}
+
+// ==================== WriteHTML Tests ====================
+
+func TestWriteHTML_ListsAllFiles(t *testing.T) {
+	result := &converter.ConversionResult{
+		TotalFiles:     3,
+		Successful:     1,
+		SkippedReasons: map[string]int{converter.SkipReasonExists: 1},
+		Failed:         1,
+		Files: []converter.FileResult{
+			{InputPath: "in/a.avif", OutputPath: "out/a.png", Status: converter.StatusConverted, InputSize: 2048, OutputSize: 4096, Warning: converter.WarningTransparent},
+			{InputPath: "in/b.avif", OutputPath: "out/b.png", Status: converter.StatusSkipped},
+			{InputPath: "in/c.avif", OutputPath: "out/c.png", Status: converter.StatusFailed, Error: errors.New("bad data")},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, result, false); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	html := buf.String()
+	for _, want := range []string{"in/a.avif", "out/b.png", "in/c.avif", "2.0 KB", "4.0 KB", "bad data", "3 file(s)", "looks blank: fully transparent"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected report to contain %q", want)
+		}
+	}
+	if strings.Contains(html, "data:image/png") {
+		t.Error("expected no previews when previews are disabled")
+	}
+}
+
+func TestWriteHTML_EscapesFileNames(t *testing.T) {
+	result := &converter.ConversionResult{
+		TotalFiles: 1,
+		Files: []converter.FileResult{
+			{InputPath: "<script>.avif", OutputPath: "out.png", Status: converter.StatusSkipped},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, result, false); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if strings.Contains(buf.String(), "<script>.avif") {
+		t.Error("expected file names to be HTML-escaped")
+	}
+}
+
+func TestWriteHTML_EmbedsPreviews(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	outputPath := filepath.Join(testDir, "a.png")
+	createTestPNG(t, outputPath, 300, 150)
+
+	result := &converter.ConversionResult{
+		TotalFiles: 1,
+		Successful: 1,
+		Files: []converter.FileResult{
+			{InputPath: "a.avif", OutputPath: outputPath, Status: converter.StatusConverted},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, result, true); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), `src="data:image/png;base64,`) {
+		t.Error("expected report to embed a PNG preview")
+	}
+}
+
+func TestWriteHTML_MissingPreviewIsIgnored(t *testing.T) {
+	result := &converter.ConversionResult{
+		TotalFiles: 1,
+		Successful: 1,
+		Files: []converter.FileResult{
+			{InputPath: "a.avif", OutputPath: "/nonexistent/a.png", Status: converter.StatusConverted},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, result, true); err != nil {
+		t.Fatalf("expected no error for missing preview, got: %v", err)
+	}
+}
+
+func TestWriteHTMLFile_CreatesFile(t *testing.T) {
+	testDir := setupTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	reportPath := filepath.Join(testDir, "reports", "report.html")
+
+	if err := WriteHTMLFile(reportPath, &converter.ConversionResult{}, false); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if _, err := os.Stat(reportPath); os.IsNotExist(err) {
+		t.Fatal("expected report file to exist")
+	}
+}
+
+// ==================== thumbnail Tests ====================
+
+func TestThumbnail_ScalesDown(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+
+	thumb := thumbnail(img, 100)
+
+	if thumb.Bounds().Dx() != 100 || thumb.Bounds().Dy() != 50 {
+		t.Errorf("expected 100x50 thumbnail, got: %dx%d", thumb.Bounds().Dx(), thumb.Bounds().Dy())
+	}
+}
+
+func TestThumbnail_KeepsSmallImages(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 10))
+
+	thumb := thumbnail(img, 100)
+
+	if thumb != image.Image(img) {
+		t.Error("expected small image to be returned unchanged")
+	}
+}